@@ -1,16 +1,26 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
 	"github.com/dotbrains/configsync/pkg/apps"
 	"github.com/spf13/cobra"
 )
 
 var (
-	listSupported bool
+	listSupported  bool
+	addPaths       []string
+	addBundleID    string
+	addInteractive bool
+	addRenames     []string
 )
 
 // addCmd represents the add command
@@ -20,12 +30,31 @@ var addCmd = &cobra.Command{
 	Long: `Add one or more applications to ConfigSync management.
 
 ConfigSync will automatically detect common configuration paths for known applications.
-You can also specify custom paths using the --path flag.
+Applications that cannot be detected can be registered with explicit --path flags.
+Each path may carry options separated by colons: type=file|directory|glob,
+dest=<path in store>, link=<another location> (repeatable), versioned,
+required, secret=keychain|1password, and plugin=<name>. Each link is symlinked to
+the same store copy as the path itself. A versioned path is a glob of versioned
+directories, such as ~/Library/Application Support/JetBrains/GoLand*; the newest is
+synced, and sync follows it to newer versions as they are installed. A secret file's
+content is kept in the keychain or 1Password, and the store only holds a reference
+to it. A plugin path's settings are exported into the store by the named plugin in
+~/.configsync/plugins, and imported back from it, instead of being linked.
+
+With --interactive, ConfigSync walks through every candidate path it detects and asks
+whether to include each one, then lets you enter additional paths.
+
+An application is refused when its paths collide with another application's, such as
+two apps storing Library/Application Support/Foo, since one would overwrite the other
+in the store. Use --rename-destination <old>=<new> to keep its files elsewhere in the store.
 
 Examples:
   configsync add vscode
   configsync add "Google Chrome" Firefox
   configsync add Terminal iTerm2
+  configsync add myapp --path ~/.myapprc --path ~/.config/myapp:type=directory --bundle-id com.foo.myapp
+  configsync add myapp --interactive
+  configsync add cursor --rename-destination "Library/Application Support/Code=Library/Application Support/Cursor"
   configsync add --list-supported`,
 	RunE: runAdd,
 }
@@ -39,11 +68,32 @@ func runAdd(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("at least one application name is required\nUse 'configsync add --list-supported' to see supported applications")
 	}
 
-	manager := config.NewManager(homeDir)
-	detector := apps.NewAppDetector(homeDir)
+	manager := newConfigManager()
+	detector, err := newAppDetector()
+	if err != nil {
+		return err
+	}
 
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	if err := refuseLockedStore(cfg, "add"); err != nil {
+		return err
+	}
+
+	if len(addRenames) > 0 && len(args) != 1 {
+		return fmt.Errorf("--rename-destination applies to a single application")
+	}
+
+	if len(addPaths) > 0 || addBundleID != "" || addInteractive {
+		if len(args) != 1 {
+			return fmt.Errorf("--path, --bundle-id, and --interactive apply to a single application")
+		}
+		return addCustomApplication(manager, detector, args[0])
 	}
 
 	successful, failed := addApplications(manager, detector, args)
@@ -68,43 +118,166 @@ func addApplications(manager *config.Manager, detector *apps.AppDetector, args [
 		appConfig, err := detector.DetectApp(appName)
 		if err != nil {
 			if verbose {
-				fmt.Printf("  ✗ Failed to detect %s: %v\n", appName, err)
+				printer.Failure("  %s", messages.Localize(messages.AppDetectFailed, messages.Data{"App": appName, "Error": err}))
 			}
 			failed = append(failed, appName)
 			continue
 		}
 
+		if err := applyDestinationRenames(appConfig); err != nil {
+			printer.Failure("  %v", err)
+			failed = append(failed, appName)
+			continue
+		}
+
+		undo := captureAppUndo(manager, history.Add, appConfig.Name, appConfig)
 		if err := manager.AddApp(appConfig); err != nil {
-			if verbose {
-				fmt.Printf("  ✗ Failed to add %s: %v\n", appName, err)
+			var collision *config.CollisionError
+			if errors.As(err, &collision) {
+				printer.Failure("  %s", messages.Localize(messages.AppAddRefused, messages.Data{"App": appName, "Error": err}))
+				fmt.Println("    Use --rename-destination <old>=<new> to store it elsewhere")
+			} else if verbose {
+				printer.Failure("  %s", messages.Localize(messages.AppAddFailed, messages.Data{"App": appName, "Error": err}))
 			}
 			failed = append(failed, appName)
 			continue
 		}
 
 		if verbose {
-			fmt.Printf("  ✓ Successfully added %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
+			printer.Success("  %s", messages.Localize(messages.AppAdded, messages.Data{"App": appConfig.DisplayName, "Count": len(appConfig.Paths)}))
 			for _, path := range appConfig.Paths {
 				fmt.Printf("    - %s\n", path.Source)
 			}
 		}
+		eventEmitter.Emit(events.AppAdded, appConfig.Name, map[string]interface{}{"source": "add"})
+		recordHistory(addHistoryEntry(appConfig, "add", undo))
 		successful = append(successful, appConfig.DisplayName)
 	}
 
 	return successful, failed
 }
 
+// addCustomApplication registers an application from --path flags and, with --interactive,
+// from the detected candidate paths the user accepts
+func addCustomApplication(manager *config.Manager, detector *apps.AppDetector, appName string) error {
+	var paths []apps.PathInfo
+	for _, spec := range addPaths {
+		path, err := detector.ParsePathSpec(appName, spec)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+
+	if addInteractive {
+		selected, err := selectPathsInteractively(detector, appName, paths)
+		if err != nil {
+			return err
+		}
+		paths = selected
+	}
+
+	appConfig, err := detector.CustomApp(appName, addBundleID, paths)
+	if err != nil {
+		return err
+	}
+
+	if err := applyDestinationRenames(appConfig); err != nil {
+		return err
+	}
+
+	undo := captureAppUndo(manager, history.Add, appConfig.Name, appConfig)
+	if err := manager.AddApp(appConfig); err != nil {
+		var collision *config.CollisionError
+		if errors.As(err, &collision) {
+			return fmt.Errorf("failed to add %s: %w\nUse --rename-destination <old>=<new> to store it elsewhere", appName, err)
+		}
+		return fmt.Errorf("failed to add %s: %w", appName, err)
+	}
+
+	if verbose {
+		for _, path := range appConfig.Paths {
+			fmt.Printf("  - %s -> %s (%s)\n", path.Source, path.Destination, path.Type)
+		}
+	}
+	eventEmitter.Emit(events.AppAdded, appConfig.Name, map[string]interface{}{"source": "add", "custom": true})
+	recordHistory(addHistoryEntry(appConfig, "add", undo))
+	showAddResults([]string{appConfig.DisplayName}, nil)
+	return nil
+}
+
+// applyDestinationRenames moves store destinations of an application as requested with --rename-destination
+func applyDestinationRenames(appConfig *config.AppConfig) error {
+	for _, rename := range addRenames {
+		from, to, ok := strings.Cut(rename, "=")
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("invalid --rename-destination %q: use <old>=<new>", rename)
+		}
+		if clean := filepath.Clean(to); filepath.IsAbs(to) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid --rename-destination %q: %s must be a relative path inside the store", rename, to)
+		}
+		if appConfig.RenameDestination(from, to) == 0 {
+			return fmt.Errorf("--rename-destination %s matches no store destination of %s", from, appConfig.DisplayName)
+		}
+	}
+	return nil
+}
+
+// selectPathsInteractively asks about each detected candidate path, then prompts for additional paths
+func selectPathsInteractively(detector *apps.AppDetector, appName string, paths []apps.PathInfo) ([]apps.PathInfo, error) {
+	if !isInteractive() {
+		return nil, fmt.Errorf("--interactive requires a terminal")
+	}
+
+	candidates := detector.CandidatePaths(appName, addBundleID)
+	if len(candidates) == 0 {
+		fmt.Printf("No configuration paths detected for %s\n", appName)
+	}
+
+	for _, candidate := range candidates {
+		if containsPath(paths, candidate.Source) {
+			continue
+		}
+		if promptYesNo(fmt.Sprintf("Include %s (%s)?", candidate.Source, candidate.Type)) {
+			paths = append(paths, candidate)
+		}
+	}
+
+	for {
+		spec := promptLine("Additional path (leave empty to finish): ")
+		if spec == "" {
+			return paths, nil
+		}
+		path, err := detector.ParsePathSpec(appName, spec)
+		if err != nil {
+			printer.Failure("  %v", err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+}
+
+// containsPath reports whether a path list already includes a source path
+func containsPath(paths []apps.PathInfo, source string) bool {
+	for _, path := range paths {
+		if path.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
 // showAddResults displays the add operation results
 func showAddResults(successful, failed []string) {
 	if len(successful) > 0 {
-		fmt.Printf("✓ Successfully added %d application(s):\n", len(successful))
+		printer.Success("%s", messages.Localize(messages.AppsAdded, messages.Data{"Count": len(successful)}))
 		for _, name := range successful {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
 	if len(failed) > 0 {
-		fmt.Printf("\n✗ Failed to add %d application(s):\n", len(failed))
+		printer.Failure("\n%s", messages.Localize(messages.AppsAddFailed, messages.Data{"Count": len(failed)}))
 		for _, name := range failed {
 			fmt.Printf("  - %s\n", name)
 		}
@@ -117,7 +290,10 @@ func showAddResults(successful, failed []string) {
 }
 
 func showSupportedApps() error {
-	detector := apps.NewAppDetector(homeDir)
+	detector, err := newAppDetector()
+	if err != nil {
+		return err
+	}
 	supportedApps := detector.GetSupportedApps()
 
 	if len(supportedApps) == 0 {
@@ -144,4 +320,8 @@ func showSupportedApps() error {
 
 func init() {
 	addCmd.Flags().BoolVar(&listSupported, "list-supported", false, "list all supported applications")
+	addCmd.Flags().StringArrayVar(&addPaths, "path", nil, "configuration path to manage, with optional :type=, :dest=, :link=, :versioned, :required, :secret=, and :plugin= options (repeatable)")
+	addCmd.Flags().StringVar(&addBundleID, "bundle-id", "", "bundle identifier of the application; its preferences plist is included when present")
+	addCmd.Flags().BoolVar(&addInteractive, "interactive", false, "choose from detected configuration paths interactively")
+	addCmd.Flags().StringArrayVar(&addRenames, "rename-destination", nil, "store destinations starting with <old> under <new> instead, as <old>=<new> (repeatable)")
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// backupExportCmd represents the backup export command
+var backupExportCmd = &cobra.Command{
+	Use:   "export <file> [app...]",
+	Short: "Write backups to a portable archive",
+	Long: `Write the backups of the given applications, or of every application, with
+their metadata to a single tar archive, so they can be moved to another machine
+with 'configsync backup import', e.g. before wiping this one.
+
+The archive is compressed according to its extension (.tar.gz, .tar.zst, or
+.tar), and with gzip otherwise. Every version of each path is included.
+
+Examples:
+  configsync backup export ~/Desktop/backups.tar.gz
+  configsync backup export backups.tar.zst vscode git`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBackupExport,
+}
+
+// backupImportCmd represents the backup import command
+var backupImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Add the backups in an archive made by backup export",
+	Long: `Add the backups in an archive written by 'configsync backup export' to this
+machine's backups, where 'configsync restore' finds them.
+
+Every backup is validated against the size and checksum recorded when it was
+taken before any is added, so a damaged archive adds nothing. Paths in the
+exporting machine's home directory are moved to this one's. Backups already
+present are skipped, so importing an archive twice is harmless.
+
+Examples:
+  configsync backup import ~/Desktop/backups.tar.gz
+  configsync backup import backups.tar.gz --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupImport,
+}
+
+// backupArchiveReport is the structured result of the backup export and import commands
+type backupArchiveReport struct {
+	Archive string           `json:"archive" yaml:"archive"`
+	Backups []archivedBackup `json:"backups" yaml:"backups"`
+	Skipped int              `json:"skipped,omitempty" yaml:"skipped,omitempty"` // Backups already present when importing
+}
+
+// archivedBackup describes a backup exported to or imported from an archive
+type archivedBackup struct {
+	App     string `json:"app" yaml:"app"`
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version" yaml:"version"`
+	Size    int64  `json:"size" yaml:"size"`
+}
+
+// newBackupArchiveReport describes the backups exported to or imported from an archive
+func newBackupArchiveReport(archive string, backups []*config.BackupInfo, skipped int) *backupArchiveReport {
+	report := &backupArchiveReport{Archive: archive, Backups: []archivedBackup{}, Skipped: skipped}
+	for _, info := range backups {
+		report.Backups = append(report.Backups, archivedBackup{App: info.AppName, Path: info.OriginalPath, Version: info.Version, Size: info.Size})
+	}
+	return report
+}
+
+func init() {
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupImportCmd)
+}
+
+// newBackupManagerFromConfig loads the configuration and returns a manager of its backups
+func newBackupManagerFromConfig() (*backup.Manager, error) {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return nil, messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	return backup.NewManager(cfg.BackupPath, homeDir, verbose).WithContext(runContext), nil
+}
+
+func runBackupExport(_ *cobra.Command, args []string) error {
+	backupManager, err := newBackupManagerFromConfig()
+	if err != nil {
+		return err
+	}
+	archive, apps := args[0], args[1:]
+
+	if dryRun {
+		if len(apps) == 0 {
+			if apps, err = backupManager.Apps(); err != nil {
+				return err
+			}
+		}
+		count := 0
+		for _, appName := range apps {
+			backups, err := backupManager.ListBackups(appName)
+			if err != nil {
+				return err
+			}
+			count += len(backups)
+		}
+		fmt.Printf("[DRY RUN] Would export %d backup(s) of %d application(s) to %s\n", count, len(apps), archive)
+		return nil
+	}
+
+	index, err := backupManager.Export(archive, apps)
+	if err != nil {
+		return fmt.Errorf("failed to export backups: %w", err)
+	}
+
+	report := newBackupArchiveReport(archive, index.Backups, 0)
+	if structuredOutput() {
+		return printStructured(report)
+	}
+	var size int64
+	for _, exported := range report.Backups {
+		size += exported.Size
+	}
+	printer.Success("%s", messages.Localize(messages.BackupsExported, messages.Data{"Count": len(report.Backups), "Size": fsutil.FormatSize(size), "Path": archive}))
+	fmt.Println("\nAdd them on another machine with: configsync backup import <file>")
+	return nil
+}
+
+func runBackupImport(_ *cobra.Command, args []string) error {
+	backupManager, err := newBackupManagerFromConfig()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		index, err := backupManager.ReadArchiveIndex(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read backup archive: %w", err)
+		}
+		fmt.Printf("[DRY RUN] Would import up to %d backup(s) exported from %s on %s\n",
+			len(index.Backups), index.Host, index.CreatedAt.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	imported, skipped, err := backupManager.Import(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to import backups: %w", err)
+	}
+
+	report := newBackupArchiveReport(args[0], imported, skipped)
+	if structuredOutput() {
+		return printStructured(report)
+	}
+	printer.Success("%s", messages.Localize(messages.BackupsImported, messages.Data{"Count": len(report.Backups)}))
+	if skipped > 0 {
+		fmt.Printf("  Skipped %d backup(s) already present\n", skipped)
+	}
+	if verbose {
+		for _, info := range report.Backups {
+			fmt.Printf("  %s: %s (%s)\n", info.App, info.Path, info.Version)
+		}
+	}
+	return nil
+}
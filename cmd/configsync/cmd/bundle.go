@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Inspect configuration bundles",
+	Long: `Inspect configuration bundles created with 'configsync export'.
+
+Examples:
+  configsync bundle inspect my-bundle.tar.gz  # Description, tags, and contents of a bundle
+  configsync bundle log                    # History of the last exported or imported bundle
+  configsync bundle log baseline.tar.gz    # History of a specific bundle
+  configsync bundle diff my-bundle.tar.gz  # What deploying a bundle would change here
+  configsync bundle keygen                 # Create a key pair for signing bundles
+  configsync bundle layers                 # Which layers deployed applications came from`,
+}
+
+// bundleInspectCmd represents the bundle inspect command
+var bundleInspectCmd = &cobra.Command{
+	Use:   "inspect <bundle>",
+	Short: "Show a bundle's description, tags, and contents",
+	Long: `Show what a bundle holds without extracting or importing it: its description
+and tags, when and where it was created, the oldest configsync version that may
+import it, the size of its files, and its applications with their paths.
+
+Bundles exported before bundle descriptions existed show their applications only.
+
+Examples:
+  configsync bundle inspect my-bundle.tar.gz
+  configsync bundle inspect --json ~/src/dotfiles-bundle`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBundleInspect,
+}
+
+// bundleInspection is the structured result of the bundle inspect command
+type bundleInspection struct {
+	Path          string             `json:"path" yaml:"path"`
+	Hash          string             `json:"hash" yaml:"hash"`
+	FormatVersion string             `json:"format_version" yaml:"format_version"`
+	CreatedAt     time.Time          `json:"created_at" yaml:"created_at"`
+	CreatedBy     string             `json:"created_by" yaml:"created_by"`
+	CreatedOn     string             `json:"created_on,omitempty" yaml:"created_on,omitempty"`
+	Platform      string             `json:"platform,omitempty" yaml:"platform,omitempty"`
+	ArchiveSize   int64              `json:"archive_size" yaml:"archive_size"`
+	Info          config.BundleInfo  `json:"info" yaml:"info"`
+	Apps          []bundleInspectApp `json:"apps" yaml:"apps"`
+	Compatible    bool               `json:"compatible" yaml:"compatible"` // Whether this configsync may import the bundle
+}
+
+// bundleInspectApp summarizes an application of an inspected bundle
+type bundleInspectApp struct {
+	Name        string `json:"name" yaml:"name"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	Paths       int    `json:"paths" yaml:"paths"`
+}
+
+func runBundleInspect(_ *cobra.Command, args []string) error {
+	bundle, hash, err := deploy.ReadBundleArchive(args[0])
+	if err != nil {
+		return err
+	}
+	archiveSize, err := fsutil.Size(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to measure bundle: %w", err)
+	}
+
+	inspection := &bundleInspection{
+		Path:          args[0],
+		Hash:          hash,
+		FormatVersion: bundle.Version,
+		CreatedAt:     bundle.CreatedAt,
+		CreatedBy:     bundle.CreatedBy,
+		CreatedOn:     bundle.Metadata["created_on"],
+		Platform:      bundle.Metadata[deploy.MetadataPlatform],
+		ArchiveSize:   archiveSize,
+		Info:          config.BundleInfo{AppCount: len(bundle.Apps)},
+		Apps:          []bundleInspectApp{},
+		Compatible:    deploy.CheckMinVersion(bundle, version) == nil && deploy.CheckBundleFormat(bundle.Version) == nil,
+	}
+	if bundle.Info != nil {
+		inspection.Info = *bundle.Info
+	}
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	for _, appName := range appNames {
+		appConfig := bundle.Apps[appName]
+		inspection.Apps = append(inspection.Apps, bundleInspectApp{Name: appName, DisplayName: appConfig.DisplayName, Paths: len(appConfig.Paths)})
+	}
+
+	if structuredOutput() {
+		return printStructured(inspection)
+	}
+	printBundleInspection(inspection, bundle)
+	return nil
+}
+
+// printBundleInspection displays what a bundle holds
+func printBundleInspection(inspection *bundleInspection, bundle *config.DeploymentBundle) {
+	info := inspection.Info
+	fmt.Printf("bundle %s\n", deploy.ShortHash(inspection.Hash))
+	if info.Description != "" {
+		fmt.Printf("Description: %s\n", info.Description)
+	}
+	if len(info.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(info.Tags, ", "))
+	}
+	fmt.Printf("Created: %s by %s", inspection.CreatedAt.Format("2006-01-02 15:04"), inspection.CreatedBy)
+	if inspection.CreatedOn != "" {
+		fmt.Printf(" on %s", inspection.CreatedOn)
+	}
+	fmt.Println()
+	if inspection.Platform != "" {
+		fmt.Printf("Platform: %s\n", inspection.Platform)
+	}
+	fmt.Printf("Format: %s\n", inspection.FormatVersion)
+	if info.MinVersion != "" {
+		fmt.Printf("Requires: configsync %s or newer\n", strings.TrimPrefix(info.MinVersion, "v"))
+	}
+	if info.TotalSize > 0 {
+		fmt.Printf("Size: %s of files, %s bundle\n", fsutil.FormatSize(info.TotalSize), fsutil.FormatSize(inspection.ArchiveSize))
+	} else {
+		fmt.Printf("Size: %s bundle\n", fsutil.FormatSize(inspection.ArchiveSize))
+	}
+	if bundle.Integrity == nil {
+		fmt.Println("Integrity: no manifest (exported by an older configsync)")
+	}
+
+	fmt.Printf("\nApplications (%d):\n", info.AppCount)
+	for _, app := range inspection.Apps {
+		fmt.Printf("  %s (%s): %d path(s)\n", app.Name, app.DisplayName, app.Paths)
+	}
+
+	if err := deploy.CheckBundleFormat(inspection.FormatVersion); err != nil {
+		printer.Warning("%v", err)
+	} else if err := deploy.CheckMinVersion(bundle, version); err != nil {
+		printer.Warning("%v", err)
+	}
+}
+
+// bundleLogCmd represents the bundle log command
+var bundleLogCmd = &cobra.Command{
+	Use:   "log [bundle.tar.gz]",
+	Short: "Show the provenance chain and changelog of a bundle",
+	Long: `Show a bundle's lineage, newest first: each bundle's hash, when and where it
+was created, the configsync version used, and the apps and paths added,
+removed, or modified since its parent.
+
+Without an argument, the last bundle exported or imported on this machine is shown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBundleLog,
+}
+
+// bundleDiffCmd represents the bundle diff command
+var bundleDiffCmd = &cobra.Command{
+	Use:   "diff <bundle>",
+	Short: "Compare a bundle with the current system",
+	Long: `Compare a bundle with the configuration and store of this system without
+importing or deploying it. For each application in the bundle, the report shows
+whether it is new, the paths the bundle adds or removes, the files whose
+contents differ from the store, and settings that differ (such as source paths,
+which are translated to this system first, as deploy does).
+
+Applications configured here but missing from the bundle are listed too;
+deploying the bundle leaves them untouched.
+
+Examples:
+  configsync bundle diff my-bundle.tar.gz
+  configsync bundle diff --json my-bundle.zip`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBundleDiff,
+}
+
+// bundleKeygenCmd represents the bundle keygen command
+var bundleKeygenCmd = &cobra.Command{
+	Use:   "keygen [private-key-path]",
+	Short: "Create an Ed25519 key pair for signing bundles",
+	Long: `Create an Ed25519 key pair for signing bundles. The private key is written to the
+given path (default: ~/.configsync/keys/bundle.key) and the public key next to it
+with a .pub suffix.
+
+Sign bundles with 'configsync export --sign <private key>' and share the public key
+so others can check them with 'configsync import --verify <public key>'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBundleKeygen,
+}
+
+// bundleLayersCmd represents the bundle layers command
+var bundleLayersCmd = &cobra.Command{
+	Use:   "layers",
+	Short: "Show the layers of the last layered deployment",
+	Long: `Show the baseline and overlay bundles of the last 'configsync deploy --layers'
+and, for each application deployed from them, the layers it came from and the
+store files the overlay replaced or merged over the baseline.`,
+	Args: cobra.NoArgs,
+	RunE: runBundleLayers,
+}
+
+func runBundleLayers(_ *cobra.Command, _ []string) error {
+	provenance, err := deploy.LoadLayerProvenance(configDir)
+	if err != nil {
+		return err
+	}
+	if provenance == nil {
+		fmt.Println("No layered deployment yet. Import layers with 'configsync import --layer' and run 'configsync deploy --layers'.")
+		return nil
+	}
+	if structuredOutput() {
+		return printStructured(provenance)
+	}
+
+	fmt.Printf("Deployed %s\n", provenance.DeployedAt.Format("2006-01-02 15:04"))
+	for _, layer := range deploy.Layers {
+		source, exists := provenance.Layers[layer]
+		if !exists {
+			continue
+		}
+		fmt.Printf("  %s: created %s by %s", layer, source.CreatedAt.Format("2006-01-02 15:04"), source.CreatedBy)
+		if source.Source != "" {
+			fmt.Printf(" (%s)", source.Source)
+		}
+		fmt.Println()
+	}
+
+	appNames := make([]string, 0, len(provenance.Apps))
+	for appName := range provenance.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	fmt.Println()
+	for _, appName := range appNames {
+		appLayers := provenance.Apps[appName]
+		fmt.Printf("%s: %s\n", appName, strings.Join(appLayers.Layers, " + "))
+		for _, relPath := range appLayers.Overridden {
+			fmt.Printf("    ~ %s\n", relPath)
+		}
+	}
+	return nil
+}
+
+func runBundleKeygen(_ *cobra.Command, args []string) error {
+	privatePath := filepath.Join(configDir, "keys", "bundle.key")
+	if len(args) == 1 {
+		privatePath = args[0]
+	}
+	publicPath := privatePath + ".pub"
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would create signing key %s and public key %s\n", privatePath, publicPath)
+		return nil
+	}
+
+	if err := deploy.GenerateSigningKey(privatePath, publicPath); err != nil {
+		return err
+	}
+
+	publicKey, err := deploy.LoadPublicKey(publicPath)
+	if err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.SigningKeyCreated, messages.Data{"Path": privatePath}))
+	fmt.Printf("  Public key: %s (key ID %s)\n", publicPath, deploy.KeyID(publicKey))
+	return nil
+}
+
+func runBundleDiff(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	diff, err := deployManager.DiffBundle(args[0], manager)
+	if err != nil {
+		return fmt.Errorf("failed to compare bundle: %w", err)
+	}
+
+	if structuredOutput() {
+		return printStructured(diff)
+	}
+	printBundleDiff(diff)
+	return nil
+}
+
+// printBundleDiff displays how each application in a bundle differs from this system
+func printBundleDiff(diff *deploy.SystemDiff) {
+	counts := make(map[string]int)
+	for _, app := range diff.Apps {
+		counts[app.Status]++
+
+		switch app.Status {
+		case deploy.DiffNew:
+			fmt.Printf("+ %s (new application)\n", app.DisplayName)
+		case deploy.DiffChanged:
+			fmt.Printf("~ %s\n", app.DisplayName)
+		default:
+			fmt.Printf("= %s (unchanged, %d file(s))\n", app.DisplayName, app.Unchanged)
+			continue
+		}
+
+		for _, destination := range app.AddedPaths {
+			fmt.Printf("    + path %s\n", destination)
+		}
+		for _, destination := range app.RemovedPaths {
+			fmt.Printf("    - path %s\n", destination)
+		}
+		for _, relPath := range app.NewFiles {
+			fmt.Printf("    + %s\n", relPath)
+		}
+		for _, relPath := range app.ChangedFiles {
+			fmt.Printf("    ~ %s (contents differ)\n", relPath)
+		}
+		for _, setting := range app.Metadata {
+			fmt.Printf("    ~ %s\n", setting)
+		}
+		if app.Status == deploy.DiffChanged && app.Unchanged > 0 {
+			fmt.Printf("    %d file(s) unchanged\n", app.Unchanged)
+		}
+	}
+
+	if len(diff.LocalOnly) > 0 {
+		fmt.Printf("\nOnly on this system (left untouched by deploy): %s\n", strings.Join(diff.LocalOnly, ", "))
+	}
+
+	fmt.Printf("\n%d new, %d changed, %d unchanged application(s)\n", counts[deploy.DiffNew], counts[deploy.DiffChanged], counts[deploy.DiffUnchanged])
+	if !diff.HasChanges() {
+		printer.Success("%s", messages.Localize(messages.BundleAlreadyMatches, nil))
+	}
+}
+
+func runBundleLog(_ *cobra.Command, args []string) error {
+	var bundle *config.DeploymentBundle
+	var hash string
+
+	if len(args) == 1 {
+		var err error
+		bundle, hash, err = deploy.ReadBundleArchive(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+	} else {
+		record, err := deploy.LoadParentRecord(configDir)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			fmt.Println("No bundle has been exported or imported yet. Pass a bundle file to inspect it.")
+			return nil
+		}
+		bundle, hash = record.Bundle, record.Hash
+	}
+
+	printBundleLogEntry(bundleLineage(bundle, hash))
+
+	if bundle.Provenance != nil {
+		for _, entry := range bundle.Provenance.History {
+			fmt.Println()
+			printBundleLogEntry(entry)
+		}
+	}
+
+	return nil
+}
+
+// bundleLineage summarizes a bundle in the same form as the entries of its history
+func bundleLineage(bundle *config.DeploymentBundle, hash string) config.BundleLineage {
+	entry := config.BundleLineage{
+		CreatedAt: bundle.CreatedAt,
+		Hash:      hash,
+		CreatedBy: bundle.CreatedBy,
+		Machine:   bundle.Metadata["created_on"],
+	}
+	if provenance := bundle.Provenance; provenance != nil {
+		entry.ParentHash = provenance.ParentHash
+		entry.Machine = provenance.Machine
+		entry.ToolVersion = provenance.ToolVersion
+		entry.Changes = provenance.Changes
+	}
+	return entry
+}
+
+// printBundleLogEntry displays one bundle in the provenance chain
+func printBundleLogEntry(entry config.BundleLineage) {
+	fmt.Printf("bundle %s\n", deploy.ShortHash(entry.Hash))
+	fmt.Printf("Created: %s by %s on %s\n", entry.CreatedAt.Format("2006-01-02 15:04"), entry.CreatedBy, entry.Machine)
+	if entry.ToolVersion != "" {
+		fmt.Printf("ConfigSync: %s\n", entry.ToolVersion)
+	}
+
+	if entry.ParentHash == "" {
+		fmt.Println("Parent: none (initial bundle)")
+		return
+	}
+	fmt.Printf("Parent: %s\n", deploy.ShortHash(entry.ParentHash))
+
+	if len(entry.Changes) == 0 {
+		fmt.Println("  No changes")
+		return
+	}
+	for _, change := range entry.Changes {
+		fmt.Printf("  %s %s\n", changeSymbol(change.Kind), describeChange(change))
+	}
+}
+
+// changeSymbol returns the marker shown for a kind of bundle change
+func changeSymbol(kind string) string {
+	switch kind {
+	case config.ChangeAdded:
+		return "+"
+	case config.ChangeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// describeChange formats the app and path affected by a bundle change
+func describeChange(change config.BundleChange) string {
+	if change.Path == "" {
+		return change.App
+	}
+	return fmt.Sprintf("%s: %s", change.App, change.Path)
+}
+
+func init() {
+	bundleCmd.AddCommand(bundleInspectCmd)
+	bundleCmd.AddCommand(bundleLogCmd)
+	bundleCmd.AddCommand(bundleDiffCmd)
+	bundleCmd.AddCommand(bundleKeygenCmd)
+	bundleCmd.AddCommand(bundleLayersCmd)
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/pkg/apps"
+	"github.com/spf13/cobra"
+)
+
+var (
+	catalogName       string
+	catalogURL        string
+	catalogSHA256     string
+	catalogTrustHooks bool
+)
+
+// catalogCmd represents the catalog command
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage the catalog of known applications",
+	Long: `Manage the catalog of application definitions used by 'configsync add'.
+
+The built-in catalog ships with ConfigSync. Catalog files in ~/.configsync/catalog/*.yaml
+add applications or override built-in definitions with the same name; the community
+catalog downloaded by 'catalog update' is applied first, so your own files take precedence.
+
+Examples:
+  configsync catalog list
+  configsync catalog add myapps.yaml
+  configsync catalog update
+  configsync catalog update --url https://example.com/catalog.yaml
+  configsync catalog update --url http://mirror.local/catalog.yaml --sha256 <digest>`,
+}
+
+// catalogListCmd represents the catalog list command
+var catalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the application definitions in the catalog",
+	Long: `List every application definition available to 'configsync add', including the
+catalog each one comes from.`,
+	Args: cobra.NoArgs,
+	RunE: runCatalogList,
+}
+
+// catalogAddCmd represents the catalog add command
+var catalogAddCmd = &cobra.Command{
+	Use:   "add <catalog.yaml>",
+	Short: "Install a catalog file",
+	Long: `Validate a catalog file and install it into ~/.configsync/catalog. Installing a file
+with the same name replaces it, so this also updates a previously added catalog.
+
+Post-sync commands that differ from the built-in definitions are shown first, since
+sync runs them in a shell for the applications added from the catalog. They are only
+installed when confirmed or with --trust-hooks, and left out otherwise.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCatalogAdd,
+}
+
+// catalogUpdateCmd represents the catalog update command
+var catalogUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the community catalog",
+	Long: `Download the community catalog and install it as ~/.configsync/catalog/community.yaml.
+The download is validated before the installed copy is replaced. URLs other than HTTPS
+need --sha256 to pin the catalog's digest, which is then verified. Post-sync commands
+are reviewed as with 'catalog add'.`,
+	Args: cobra.NoArgs,
+	RunE: runCatalogUpdate,
+}
+
+// catalogEntry is one application definition listed by catalog list
+type catalogEntry struct {
+	Name        string `json:"name" yaml:"name"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	BundleID    string `json:"bundle_id,omitempty" yaml:"bundle_id,omitempty"`
+	Origin      string `json:"origin" yaml:"origin"`
+	Paths       int    `json:"paths" yaml:"paths"`
+}
+
+// newAppDetector creates an application detector that includes the user's catalogs
+func newAppDetector() (*apps.AppDetector, error) {
+	detector := apps.NewAppDetector(homeDir)
+	detector.SetScanCache(filepath.Join(configDir, apps.ScanCacheFileName))
+	if err := detector.LoadUserCatalog(catalogDir()); err != nil {
+		return nil, fmt.Errorf("failed to load application catalog: %w", err)
+	}
+	found, err := findPlugins()
+	if err != nil {
+		return nil, err
+	}
+	detector.SetPlugins(found)
+	return detector, nil
+}
+
+// catalogDir returns the directory holding the user's catalogs
+func catalogDir() string {
+	return filepath.Join(configDir, apps.CatalogDirName)
+}
+
+func runCatalogList(_ *cobra.Command, _ []string) error {
+	detector, err := newAppDetector()
+	if err != nil {
+		return err
+	}
+
+	entries := []catalogEntry{}
+	for _, app := range detector.Catalog() {
+		entries = append(entries, catalogEntry{
+			Name:        app.Name,
+			DisplayName: app.DisplayName,
+			BundleID:    app.BundleID,
+			Origin:      app.Origin,
+			Paths:       len(app.Paths),
+		})
+	}
+
+	if structuredOutput() {
+		return printStructured(entries)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tDISPLAY NAME\tPATHS\tCATALOG")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", entry.Name, entry.DisplayName, entry.Paths, entry.Origin)
+	}
+	writer.Flush()
+
+	fmt.Printf("\nTotal: %d applications\n", len(entries))
+	return nil
+}
+
+func runCatalogAdd(_ *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	fileName := catalogName
+	if fileName == "" {
+		fileName = filepath.Base(args[0])
+	}
+	if fileName == apps.CommunityCatalogFile {
+		return fmt.Errorf("%s is reserved for 'configsync catalog update'; use --name to pick another name", apps.CommunityCatalogFile)
+	}
+
+	if dryRun {
+		definitions, err := apps.ParseCatalog(data, "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[DRY RUN] Would install %d application definition(s) as %s\n", len(definitions), filepath.Join(catalogDir(), fileName))
+		return nil
+	}
+
+	data, err = reviewCatalogHooks(data)
+	if err != nil {
+		return err
+	}
+
+	path, err := apps.InstallCatalog(catalogDir(), fileName, data)
+	if err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.CatalogInstalled, messages.Data{"Path": path}))
+	return nil
+}
+
+func runCatalogUpdate(_ *cobra.Command, _ []string) error {
+	if verbose {
+		fmt.Printf("Downloading catalog from %s\n", catalogURL)
+	}
+
+	data, err := apps.FetchCatalog(catalogURL, catalogSHA256)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		definitions, err := apps.ParseCatalog(data, "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[DRY RUN] Would install community catalog with %d application definition(s)\n", len(definitions))
+		return nil
+	}
+
+	data, err = reviewCatalogHooks(data)
+	if err != nil {
+		return err
+	}
+
+	path, err := apps.InstallCatalog(catalogDir(), apps.CommunityCatalogFile, data)
+	if err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.CatalogUpdated, messages.Data{"Path": path}))
+	return nil
+}
+
+// reviewCatalogHooks shows the post-sync commands a catalog brings and leaves them out unless
+// they are confirmed or --trust-hooks is given
+func reviewCatalogHooks(data []byte) ([]byte, error) {
+	hooks, err := apps.CatalogHooks(data)
+	if err != nil || len(hooks) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("The catalog defines post-sync commands, which sync runs in a shell:")
+	for _, name := range names {
+		fmt.Printf("  %s:\n", name)
+		for _, command := range hooks[name] {
+			fmt.Printf("    %s\n", command)
+		}
+	}
+	if catalogTrustHooks || promptYesNo("Install these commands?") {
+		return data, nil
+	}
+
+	fmt.Println("Leaving the post-sync commands out; install the catalog with --trust-hooks to keep them")
+	return apps.StripCatalogHooks(data, names)
+}
+
+func init() {
+	catalogCmd.AddCommand(catalogListCmd)
+	catalogCmd.AddCommand(catalogAddCmd)
+	catalogCmd.AddCommand(catalogUpdateCmd)
+
+	catalogAddCmd.Flags().StringVar(&catalogName, "name", "", "file name to install the catalog as (default: the source file name)")
+	catalogAddCmd.Flags().BoolVar(&catalogTrustHooks, "trust-hooks", false, "install the catalog's post-sync commands without asking")
+	catalogUpdateCmd.Flags().StringVar(&catalogURL, "url", apps.DefaultCatalogURL, "URL of the community catalog")
+	catalogUpdateCmd.Flags().StringVar(&catalogSHA256, "sha256", "", "require the catalog to have this SHA-256 digest; needed for URLs other than HTTPS")
+	catalogUpdateCmd.Flags().BoolVar(&catalogTrustHooks, "trust-hooks", false, "install the catalog's post-sync commands without asking")
+}
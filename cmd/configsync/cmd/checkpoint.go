@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var checkpointYes bool
+
+// checkpointCmd represents the checkpoint command
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Save named restore points of every managed path and return to them",
+	Long: `Save a named restore point of the configuration, the store, and every live
+path of the managed applications, and return to it in one step later.
+
+Unlike snapshots, which cover the store, checkpoints also keep the files of
+applications that are not synced, so they can be taken before a risky deploy or
+import. Checkpoints are kept in ~/.configsync/checkpoints.
+
+Examples:
+  configsync checkpoint create pre-deploy
+  configsync checkpoint list
+  configsync checkpoint restore pre-deploy
+  configsync checkpoint delete pre-deploy`,
+}
+
+// checkpointCreateCmd represents the checkpoint create command
+var checkpointCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Save the current state as a named checkpoint",
+	Long: `Save the configuration, the store, and the state of every live path of the
+managed applications as a named checkpoint: where each symlink points, or a copy
+of each file or directory.
+
+Examples:
+  configsync checkpoint create pre-deploy
+  configsync checkpoint create "before zsh rewrite"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheckpointCreate,
+}
+
+// checkpointListCmd represents the checkpoint list command
+var checkpointListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List checkpoints, newest first",
+	Long: `List the checkpoints, newest first, with the number of applications, live
+paths, and files they contain.
+
+Examples:
+  configsync checkpoint list
+  configsync checkpoint list --json`,
+	Args: cobra.NoArgs,
+	RunE: runCheckpointList,
+}
+
+// checkpointRestoreCmd represents the checkpoint restore command
+var checkpointRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Return the configuration, store, and live paths to a checkpoint",
+	Long: `Return the configuration, the store, and every live path recorded in a
+checkpoint to their state at the time.
+
+The restore is all or nothing: every copy is prepared beside the path it
+replaces before anything changes, the copies are then renamed into place, and if
+any step fails, everything swapped so far is put back. Paths the checkpoint does
+not record are left alone, except that their symlinks into the store are
+replaced with the files they point to.
+
+Examples:
+  configsync checkpoint restore pre-deploy
+  configsync checkpoint restore pre-deploy --yes
+  configsync checkpoint restore pre-deploy --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheckpointRestore,
+}
+
+// checkpointDeleteCmd represents the checkpoint delete command
+var checkpointDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a checkpoint",
+	Long: `Delete a checkpoint and the copies it keeps.
+
+Examples:
+  configsync checkpoint delete pre-deploy`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheckpointDelete,
+}
+
+func runCheckpointCreate(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+	if err := store.CheckName(args[0]); err != nil {
+		return err
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	checkpointer := store.NewCheckpointer(homeDir, manager.GetConfigDir(), verbose)
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would save the store %s and the live paths and configuration of %d application(s) as checkpoint %s\n", cfg.StorePath, len(cfg.Apps), args[0])
+		return nil
+	}
+
+	release, err := lockApps(manager, "checkpoint create", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	checkpoint, err := checkpointer.Create(manager, args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+
+	if structuredOutput() {
+		return printStructured(checkpoint)
+	}
+
+	printer.Success("%s", messages.Localize(messages.CheckpointCreated, messages.Data{"Name": checkpoint.Name}))
+	fmt.Printf("  Applications: %d\n", len(checkpoint.Apps))
+	fmt.Printf("  Live paths: %d\n", len(checkpoint.Paths))
+	fmt.Printf("  Files: %d (%s)\n", checkpoint.Files, fsutil.FormatSize(checkpoint.Size))
+	fmt.Printf("\nReturn to it with: configsync checkpoint restore %s\n", checkpoint.Name)
+	return nil
+}
+
+func runCheckpointList(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	checkpoints, err := store.NewCheckpointer(homeDir, manager.GetConfigDir(), verbose).List()
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if checkpoints == nil {
+			checkpoints = []*store.Checkpoint{}
+		}
+		return printStructured(checkpoints)
+	}
+
+	if len(checkpoints) == 0 {
+		fmt.Println("No checkpoints yet. Create one with 'configsync checkpoint create <name>'.")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-17s %6s %6s %7s %10s\n", "NAME", "CREATED", "APPS", "PATHS", "FILES", "SIZE")
+	for _, checkpoint := range checkpoints {
+		fmt.Printf("%-24s %-17s %6d %6d %7d %10s\n",
+			checkpoint.Name,
+			checkpoint.CreatedAt.Format("2006-01-02 15:04"),
+			len(checkpoint.Apps),
+			len(checkpoint.Paths),
+			checkpoint.Files,
+			fsutil.FormatSize(checkpoint.Size))
+	}
+	return nil
+}
+
+func runCheckpointRestore(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	checkpointer := store.NewCheckpointer(homeDir, manager.GetConfigDir(), verbose)
+	checkpoint, err := checkpointer.Find(args[0])
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would restore checkpoint %s from %s\n", checkpoint.Name, checkpoint.CreatedAt.Format("2006-01-02 15:04"))
+		fmt.Printf("[DRY RUN] Would restore the store, %d live path(s), and the configuration of %d application(s)\n", len(checkpoint.Paths), len(checkpoint.Apps))
+		return nil
+	}
+
+	if !checkpointYes {
+		question := fmt.Sprintf("Replace the configuration, store, and live paths with checkpoint %s?", checkpoint.Name)
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("checkpoint-restore", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "restore"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; nothing was restored")
+			return nil
+		}
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "checkpoint restore"); err != nil {
+		return err
+	}
+	release, err := lockApps(manager, "checkpoint restore", append(configuredApps(cfg, nil), checkpoint.Apps...))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	result, err := checkpointer.Restore(manager, checkpoint.Name)
+	recordCheckpointRestore(manager, checkpoint, err)
+	if err != nil {
+		return fmt.Errorf("failed to restore checkpoint: %w", err)
+	}
+
+	eventEmitter.Emit(events.RestorePerformed, "", map[string]interface{}{"source": "checkpoint", "checkpoint": checkpoint.Name, "apps": checkpoint.Apps})
+	printer.Success("%s", messages.Localize(messages.CheckpointRestored, messages.Data{"Name": checkpoint.Name}))
+	fmt.Printf("  Paths restored: %d\n", len(result.Replaced))
+	fmt.Printf("  Paths already matching: %d\n", result.Unchanged)
+	if verbose {
+		for _, source := range result.Replaced {
+			fmt.Printf("  - %s\n", source)
+		}
+	}
+	return nil
+}
+
+func runCheckpointDelete(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	checkpointer := store.NewCheckpointer(homeDir, manager.GetConfigDir(), verbose)
+	if dryRun {
+		if _, err := checkpointer.Find(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("[DRY RUN] Would delete checkpoint %s\n", args[0])
+		return nil
+	}
+
+	if err := checkpointer.Delete(manager, args[0]); err != nil {
+		return err
+	}
+	printer.Success("%s", messages.Localize(messages.CheckpointDeleted, messages.Data{"Name": args[0]}))
+	return nil
+}
+
+// recordCheckpointRestore adds the applications restored from a checkpoint to the history, with
+// the paths they have after the restore
+func recordCheckpointRestore(manager *config.Manager, checkpoint *store.Checkpoint, err error) {
+	var apps map[string]*config.AppConfig
+	if cfg, loadErr := manager.Load(); loadErr == nil {
+		apps = cfg.Apps
+	}
+
+	entries := make([]history.Entry, 0, len(checkpoint.Apps))
+	for _, appName := range checkpoint.Apps {
+		entry := appHistoryEntry(history.Restore, appName, apps[appName], err)
+		entry.Details = map[string]string{"checkpoint": checkpoint.Name}
+		entries = append(entries, entry)
+	}
+	recordHistory(entries...)
+}
+
+func init() {
+	checkpointRestoreCmd.Flags().BoolVarP(&checkpointYes, "yes", "y", false, "restore without asking for confirmation")
+
+	checkpointCmd.AddCommand(checkpointCreateCmd)
+	checkpointCmd.AddCommand(checkpointListCmd)
+	checkpointCmd.AddCommand(checkpointRestoreCmd)
+	checkpointCmd.AddCommand(checkpointDeleteCmd)
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+var cleanYes bool
+
+// cleanReport lists the artifacts found, and whether they were deleted
+type cleanReport struct {
+	Artifacts []deploy.Artifact `json:"artifacts" yaml:"artifacts"`
+	Size      int64             `json:"size" yaml:"size"`
+	Removed   bool              `json:"removed" yaml:"removed"`
+}
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Delete imported bundles and temporary files that are no longer needed",
+	Long: `Find the imported bundle in ~/.configsync/import and the temporary directories
+left behind by interrupted exports and imports, show how much space they take
+up, and delete them.
+
+Deploy removes the imported bundle itself once every application in it has
+been deployed, so an imported bundle that is still there was not fully
+deployed; its note says how much of it was. Temporary directories are only
+listed once they have not been modified for an hour, so those of a running
+export are left alone.
+
+Examples:
+  configsync clean --dry-run  # Show what would be deleted and its size
+  configsync clean
+  configsync clean --yes --json`,
+	Args: cobra.NoArgs,
+	RunE: runClean,
+}
+
+func runClean(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	artifacts, err := deployManager.FindArtifacts(filepath.Join(configDir, "import"), os.TempDir(), deploy.DefaultStaleAge)
+	if err != nil {
+		return err
+	}
+	report := &cleanReport{Artifacts: artifacts, Size: artifactsSize(artifacts)}
+	if report.Artifacts == nil {
+		report.Artifacts = []deploy.Artifact{}
+	}
+
+	if len(artifacts) == 0 {
+		if structuredOutput() {
+			return printStructured(report)
+		}
+		printer.Success("%s", messages.Localize(messages.NothingToClean, nil))
+		return nil
+	}
+
+	if !structuredOutput() {
+		if err := printArtifacts(artifacts); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	if dryRun {
+		if structuredOutput() {
+			return printStructured(report)
+		}
+		fmt.Printf("[DRY RUN] Would delete %d item(s), freeing %s\n", len(artifacts), fsutil.FormatSize(report.Size))
+		return nil
+	}
+
+	if !cleanYes {
+		question := fmt.Sprintf("Delete %d item(s) (%s)?", len(artifacts), fsutil.FormatSize(report.Size))
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("clean", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "delete"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; nothing was deleted")
+			return nil
+		}
+	}
+
+	var removed []deploy.Artifact
+	for _, artifact := range artifacts {
+		if err := os.RemoveAll(artifact.Path); err != nil {
+			printer.Warning("%s", messages.Localize(messages.DeleteFailed, messages.Data{"Path": artifact.Path, "Error": err}))
+			continue
+		}
+		removed = append(removed, artifact)
+	}
+	report.Artifacts, report.Size, report.Removed = removed, artifactsSize(removed), true
+	if report.Artifacts == nil {
+		report.Artifacts = []deploy.Artifact{}
+	}
+
+	if structuredOutput() {
+		return printStructured(report)
+	}
+	printer.Success("%s", messages.Localize(messages.ItemsDeleted, messages.Data{"Count": len(removed), "Size": fsutil.FormatSize(report.Size)}))
+	if len(removed) < len(artifacts) {
+		return fmt.Errorf("failed to delete %d item(s)", len(artifacts)-len(removed))
+	}
+	return nil
+}
+
+// printArtifacts lists imported bundles and temporary directories as a table
+func printArtifacts(artifacts []deploy.Artifact) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "KIND\tPATH\tSIZE\tMODIFIED\tNOTE")
+	for _, artifact := range artifacts {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			artifact.Kind, artifact.Path, fsutil.FormatSize(artifact.Size),
+			artifact.ModTime.Format("2006-01-02 15:04"), artifact.Note)
+	}
+	return w.Flush()
+}
+
+// artifactsSize adds up the space artifacts take up
+func artifactsSize(artifacts []deploy.Artifact) int64 {
+	var total int64
+	for _, artifact := range artifacts {
+		total += artifact.Size
+	}
+	return total
+}
+
+func init() {
+	cleanCmd.Flags().BoolVarP(&cleanYes, "yes", "y", false, "delete without asking for confirmation")
+}
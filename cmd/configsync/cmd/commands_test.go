@@ -2,12 +2,30 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/config"
 	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/migrate"
+	"github.com/dotbrains/configsync/internal/permissions"
+	"github.com/dotbrains/configsync/internal/statuscache"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/dotbrains/configsync/pkg/apps"
 	"github.com/spf13/cobra"
 )
 
@@ -87,6 +105,8 @@ func TestCommandStructure(t *testing.T) {
 		{initCmd, "init", true},
 		{addCmd, "add", true},
 		{removeCmd, "remove", true},
+		{enableCmd, "enable", true},
+		{disableCmd, "disable", true},
 		{syncCmd, "sync", true},
 		{statusCmd, "status", true},
 		{discoverCmd, "discover", true},
@@ -95,6 +115,29 @@ func TestCommandStructure(t *testing.T) {
 		{exportCmd, "export", true},
 		{importCmd, "import", true},
 		{deployCmd, "deploy", true},
+		{scheduleCmd, "schedule", false},
+		{storeCmd, "store", false},
+		{bundleCmd, "bundle", false},
+		{listCmd, "list", false},
+		{catalogCmd, "catalog", false},
+		{pluginsCmd, "plugins", false},
+		{systemCmd, "system", false},
+		{tuiCmd, "tui", true},
+		{configCmd, "config", false},
+		{doctorCmd, "doctor", true},
+		{migrateCmd, "migrate", true},
+		{snapshotCmd, "snapshot", false},
+		{checkpointCmd, "checkpoint", false},
+		{editCmd, "edit", false},
+		{duCmd, "du", true},
+		{verifyLinksCmd, "verify-links", true},
+		{serveCmd, "serve", true},
+		{pairCmd, "pair", true},
+		{historyCmd, "history", true},
+		{undoCmd, "undo", true},
+		{gcCmd, "gc", true},
+		{cleanCmd, "clean", true},
+		{upgradesCmd, "upgrades", true},
 	}
 
 	for _, tt := range tests {
@@ -126,7 +169,32 @@ func TestCommandStructure(t *testing.T) {
 func TestCommandRegistration(t *testing.T) {
 	expectedCommands := []string{
 		"init", "add", "remove", "sync", "status",
+		"enable", "disable",
 		"discover", "backup", "restore", "export", "import", "deploy",
+		"schedule",
+		"store",
+		"bundle",
+		"list",
+		"catalog",
+		"system",
+		"tui",
+		"config",
+		"doctor",
+		"migrate",
+		"snapshot",
+		"checkpoint",
+		"edit",
+		"du",
+		"verify-links",
+		"serve",
+		"pair",
+		"history",
+		"undo",
+		"gc",
+		"clean",
+		"upgrades",
+		"uninstall",
+		"plugins",
 	}
 
 	registeredCommands := make(map[string]bool)
@@ -168,6 +236,116 @@ func TestCommandFlags(t *testing.T) {
 		t.Error("Expected add command to have --list-supported flag")
 	}
 
+	for _, name := range []string{"path", "bundle-id", "interactive"} {
+		if addCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected add command to have --%s flag", name)
+		}
+	}
+
+	if syncCmd.Flags().Lookup("heal") == nil {
+		t.Error("Expected sync command to have --heal flag")
+	}
+
+	if doctorCmd.Flags().Lookup("fix-permissions") == nil {
+		t.Error("Expected doctor command to have --fix-permissions flag")
+	}
+
+	for _, name := range []string{"format", "layout", "stow"} {
+		if exportCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected export command to have --%s flag", name)
+		}
+	}
+
+	for _, name := range []string{"from-stow", "from-chezmoi"} {
+		if migrateCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected migrate command to have --%s flag", name)
+		}
+	}
+
+	for _, name := range []string{"path", "interactive"} {
+		if restoreCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected restore command to have --%s flag", name)
+		}
+	}
+
+	if snapshotCreateCmd.Flags().Lookup("label") == nil {
+		t.Error("Expected snapshot create command to have --label flag")
+	}
+	if snapshotRestoreCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected snapshot restore command to have --yes flag")
+	}
+	if checkpointRestoreCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected checkpoint restore command to have --yes flag")
+	}
+
+	if duCmd.Flags().Lookup("threshold") == nil {
+		t.Error("Expected du command to have --threshold flag")
+	}
+
+	if statusCmd.Flags().Lookup("format") == nil {
+		t.Error("Expected status command to have --format flag")
+	}
+
+	if syncCmd.Flags().Lookup("notify") == nil {
+		t.Error("Expected sync command to have --notify flag")
+	}
+
+	for _, flag := range []string{"repair", "yes"} {
+		if verifyLinksCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected verify-links command to have --%s flag", flag)
+		}
+	}
+
+	if listen := serveCmd.Flags().Lookup("listen"); listen == nil || listen.DefValue != "127.0.0.1:8080" {
+		t.Error("Expected serve command to have --listen flag defaulting to 127.0.0.1:8080")
+	}
+
+	for _, flag := range []string{"listen", "code", "name", "discover", "list", "forget"} {
+		if pairCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected pair command to have --%s flag", flag)
+		}
+	}
+	if syncCmd.Flags().Lookup("peer") == nil {
+		t.Error("Expected sync command to have --peer flag")
+	}
+
+	for _, flag := range []string{"since", "until", "on", "operation", "path", "limit"} {
+		if historyCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected history command to have --%s flag", flag)
+		}
+	}
+	if undoCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected undo command to have --yes flag")
+	}
+	if gcCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected gc command to have --yes flag")
+	}
+	for _, flag := range []string{"purge-store", "purge-backups"} {
+		if removeCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected remove command to have --%s flag", flag)
+		}
+	}
+	if cleanCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected clean command to have --yes flag")
+	}
+	if deployCmd.Flags().Lookup("keep-import") == nil {
+		t.Error("Expected deploy command to have --keep-import flag")
+	}
+	if discoverCmd.Flags().Lookup("refresh") == nil {
+		t.Error("Expected discover command to have --refresh flag")
+	}
+	for _, flag := range []string{"migrate", "yes"} {
+		if upgradesCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected upgrades command to have --%s flag", flag)
+		}
+	}
+
+	for _, command := range []*cobra.Command{syncCmd, exportCmd, backupCmd} {
+		if command.Flags().Lookup("include-caches") == nil {
+			t.Errorf("Expected %s command to have --include-caches flag", command.Name())
+		}
+	}
+
 	// Test discover command flags
 	autoAddFlag := discoverCmd.Flags().Lookup("auto-add")
 	if autoAddFlag == nil {
@@ -192,14 +370,28 @@ func TestCommandFlags(t *testing.T) {
 
 	// Test export command flags
 	outputFlag := exportCmd.Flags().Lookup("output")
-	if outputFlag == nil {
-		t.Error("Expected export command to have --output flag")
+	if outputFlag == nil || outputFlag.Shorthand != "o" {
+		t.Error("Expected export command to have -o/--output flag")
+	}
+	if exportCmd.Flags().Lookup("result-format") == nil {
+		t.Error("Expected export command to have --result-format flag")
 	}
 
 	appsFlag := exportCmd.Flags().Lookup("apps")
 	if appsFlag == nil {
 		t.Error("Expected export command to have --apps flag")
 	}
+
+	// Test enable and disable command flags
+	if enableCmd.Flags().Lookup("all") == nil || disableCmd.Flags().Lookup("all") == nil {
+		t.Error("Expected enable and disable commands to have --all flag")
+	}
+	if addCmd.Flags().Lookup("rename-destination") == nil {
+		t.Error("Expected add command to have --rename-destination flag")
+	}
+	if disableCmd.Flags().Lookup("unsync") == nil {
+		t.Error("Expected disable command to have --unsync flag")
+	}
 }
 
 // Test initConfig function
@@ -208,6 +400,7 @@ func TestInitConfig(t *testing.T) {
 	originalHome := homeDir
 	originalConfigDir := configDir
 	originalHomeEnv := os.Getenv("HOME")
+	t.Setenv(config.EnvXDGConfigHome, "")
 
 	defer func() {
 		homeDir = originalHome
@@ -344,6 +537,8 @@ func TestCommandUsage(t *testing.T) {
 	}{
 		{addCmd, "add", "add [app1] [app2] ..."},
 		{removeCmd, "remove", "remove [app1] [app2] ..."},
+		{enableCmd, "enable", "enable [app1] [app2] ..."},
+		{disableCmd, "disable", "disable [app1] [app2] ..."},
 		{syncCmd, "sync", "sync [app1] [app2] ..."},
 		{backupCmd, "backup", "backup [app1] [app2] ..."},
 		{restoreCmd, "restore", "restore [app1] [app2] ..."},
@@ -442,3 +637,1696 @@ func TestCobraInit(t *testing.T) {
 		t.Error("configDir variable should be accessible and not empty")
 	}
 }
+
+// Test sync worker count resolution
+func TestResolveSyncWorkers(t *testing.T) {
+	original := syncWorkers
+	defer func() { syncWorkers = original }()
+
+	syncWorkers = 0
+	if workers := resolveSyncWorkers(nil); workers < 1 {
+		t.Errorf("Expected at least one worker by default, got %d", workers)
+	}
+
+	if workers := resolveSyncWorkers(&config.Settings{SyncWorkers: 3}); workers != 3 {
+		t.Errorf("Expected workers from settings, got %d", workers)
+	}
+
+	syncWorkers = 7
+	if workers := resolveSyncWorkers(&config.Settings{SyncWorkers: 3}); workers != 7 {
+		t.Errorf("Expected --workers flag to take precedence, got %d", workers)
+	}
+}
+
+func TestResolveOutputFormat(t *testing.T) {
+	originalFormat, originalJSON := outputFormat, outputAsJSON
+	defer func() { outputFormat, outputAsJSON = originalFormat, originalJSON }()
+
+	tests := []struct {
+		format     string
+		asJSON     bool
+		expected   string
+		structured bool
+		wantErr    bool
+	}{
+		{"", false, outputTable, false, false},
+		{"table", false, outputTable, false, false},
+		{"yaml", false, outputYAML, true, false},
+		{"table", true, outputJSON, true, false},
+		{"xml", false, "", false, true},
+	}
+
+	for _, tt := range tests {
+		outputFormat, outputAsJSON = tt.format, tt.asJSON
+		err := resolveOutputFormat()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("format %q: expected error %t, got %v", tt.format, tt.wantErr, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if outputFormat != tt.expected || structuredOutput() != tt.structured {
+			t.Errorf("format %q: expected %s (structured %t), got %s", tt.format, tt.expected, tt.structured, outputFormat)
+		}
+	}
+}
+
+func TestExportOutputFlags(t *testing.T) {
+	originalOutput, originalFormat := exportOutput, outputFormat
+	defer func() { exportOutput, outputFormat = originalOutput, originalFormat }()
+
+	if err := exportCmd.ParseFlags([]string{"--output", "my-config.tar.gz", "--result-format", "yaml"}); err != nil {
+		t.Fatalf("Failed to parse export flags: %v", err)
+	}
+	if exportOutput != "my-config.tar.gz" || outputFormat != outputYAML {
+		t.Errorf("Expected bundle path my-config.tar.gz and yaml results, got %q and %q", exportOutput, outputFormat)
+	}
+	if err := checkExportOutput(exportOutput); err != nil {
+		t.Errorf("Expected a bundle path to be accepted, got %v", err)
+	}
+
+	for _, format := range []string{outputJSON, outputYAML, outputTable} {
+		err := checkExportOutput(format)
+		if err == nil || !strings.Contains(err.Error(), "--result-format "+format) {
+			t.Errorf("Expected --output %s to be refused with a pointer to --result-format, got %v", format, err)
+		}
+	}
+}
+
+func TestBuildStatusReport(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg := config.NewDefaultConfig(filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), filepath.Join(tempDir, "logs"))
+	app := config.NewAppConfig("zeta", "Zeta")
+	app.AddPath(filepath.Join(tempDir, "missing.conf"), "missing.conf", config.PathTypeFile, false)
+	cfg.Apps["zeta"] = app
+	cfg.Apps["alpha"] = config.NewAppConfig("alpha", "Alpha")
+
+	report := buildStatusReport(cfg, filepath.Join(tempDir, "config.yaml"))
+
+	if len(report.Apps) != 2 || report.Apps[0].Name != "alpha" {
+		t.Fatalf("Expected apps sorted by name, got %+v", report.Apps)
+	}
+	if report.LastSync != nil {
+		t.Error("Expected no last sync time for a new configuration")
+	}
+	zeta := report.Apps[1]
+	if len(zeta.Paths) != 1 || zeta.Paths[0].Status != "missing" || zeta.Synced != 0 {
+		t.Errorf("Unexpected path status: %+v", zeta.Paths)
+	}
+}
+
+func TestBuildStatusReportReplacedSymlink(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for _, file := range []string{filepath.Join(storeDir, "settings.json"), filepath.Join(tempDir, "settings.json")} {
+		if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+
+	cfg := config.NewDefaultConfig(storeDir, filepath.Join(tempDir, "backup"), filepath.Join(tempDir, "logs"))
+	app := config.NewAppConfig("editor", "Editor")
+	app.AddPath(filepath.Join(tempDir, "settings.json"), "settings.json", config.PathTypeFile, false)
+	cfg.Apps["editor"] = app
+
+	if status := buildStatusReport(cfg, "").Apps[0].Paths[0].Status; status != statusNotSynced {
+		t.Errorf("Expected a path that was never synced to be %s, got %s", statusNotSynced, status)
+	}
+
+	app.Paths[0].MarkSynced()
+	if status := buildStatusReport(cfg, "").Apps[0].Paths[0].Status; status != statusReplacedSymlink {
+		t.Errorf("Expected a synced path holding a regular file to be %s, got %s", statusReplacedSymlink, status)
+	}
+}
+
+func TestBuildStatusReportLinks(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	storeDir := filepath.Join(tempDir, "store")
+	storeFile := filepath.Join(storeDir, "settings.json")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	source, legacy := filepath.Join(tempDir, "settings.json"), filepath.Join(tempDir, "legacy.json")
+	if err := os.Symlink(storeFile, source); err != nil {
+		t.Fatalf("Failed to link source: %v", err)
+	}
+
+	cfg := config.NewDefaultConfig(storeDir, filepath.Join(tempDir, "backup"), filepath.Join(tempDir, "logs"))
+	app := config.NewAppConfig("editor", "Editor")
+	app.AddPath(source, "settings.json", config.PathTypeFile, false)
+	app.Paths[0].Links = []string{legacy}
+	cfg.Apps["editor"] = app
+
+	path := buildStatusReport(cfg, "").Apps[0].Paths[0]
+	if path.Status != statusPartiallyLinked || len(path.Links) != 1 || path.Links[0].Status != statusNotSynced {
+		t.Errorf("Expected a path with an unlinked link to be %s, got %+v", statusPartiallyLinked, path)
+	}
+
+	if err := os.Symlink(storeFile, legacy); err != nil {
+		t.Fatalf("Failed to link legacy location: %v", err)
+	}
+	report := buildStatusReport(cfg, "")
+	if path := report.Apps[0].Paths[0]; path.Status != statusSynced || path.Links[0].Status != statusSynced || report.Apps[0].Synced != 1 {
+		t.Errorf("Expected a fully linked path to be synced, got %+v", report.Apps[0])
+	}
+}
+
+func TestBuildStatusReportHosts(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	host := config.CurrentHost
+	config.CurrentHost = "laptop"
+	defer func() { config.CurrentHost = host }()
+
+	storeDir := filepath.Join(tempDir, "store")
+	storeFile := filepath.Join(storeDir, ".editorconfig")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte("root = true"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "Projects"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Symlink(storeFile, filepath.Join(tempDir, "Projects", ".editorconfig")); err != nil {
+		t.Fatalf("Failed to link source: %v", err)
+	}
+
+	cfg := config.NewDefaultConfig(storeDir, filepath.Join(tempDir, "backup"), filepath.Join(tempDir, "logs"))
+	editor := config.NewAppConfig("editor", "Editor")
+	editor.AddPath("~/Work/.editorconfig", ".editorconfig", config.PathTypeFile, false)
+	cfg.Apps["editor"] = editor
+	iterm := config.NewAppConfig("iterm2", "iTerm2")
+	iterm.AddPath("~/.iterm2", ".iterm2", config.PathTypeDirectory, false)
+	cfg.Apps["iterm2"] = iterm
+	cfg.Hosts = map[string]*config.HostOverride{"laptop": {
+		Exclude: []string{"iterm2"},
+		Paths:   []config.PathSubstitution{{From: "~/Work", To: "~/Projects"}},
+	}}
+
+	report := buildStatusReport(cfg, "")
+	if app := report.Apps[0]; app.Synced != 1 || app.Host != "" {
+		t.Errorf("Expected the substituted location to be synced, got %+v", app)
+	}
+	if app := report.Apps[1]; app.Host != "laptop" || app.Paths[0].Status != statusOtherHost || xbarExpectedPaths(app) != 0 {
+		t.Errorf("Expected iterm2 to be reported as not used on this host, got %+v", app)
+	}
+}
+
+func TestWriteXbarStatus(t *testing.T) {
+	now := time.Now()
+	lastSync := now.Add(-5 * time.Minute)
+	report := &statusReport{
+		LastSync:    &lastSync,
+		Permissions: &permissions.Report{},
+		Apps: []appStatus{
+			{Name: "git", DisplayName: "Git", Enabled: true, Synced: 1, Paths: []pathStatus{
+				{Source: "~/.gitconfig", Status: statusSynced},
+				{Source: "~/AppData/git", Status: statusOtherPlatform},
+			}},
+			{Name: "vscode", DisplayName: "VS Code | Insiders", Enabled: true, Paths: []pathStatus{
+				{Source: "~/Library/Application Support/Code/User", Status: statusNotSynced},
+			}},
+			{Name: "zed", DisplayName: "Zed", Paths: []pathStatus{{Source: "~/.config/zed", Status: statusNotSynced}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	writeXbarStatus(&buf, report, "/usr/local/bin/configsync", now)
+	output := buf.String()
+	lines := strings.Split(output, "\n")
+
+	if lines[0] != "⚠ 1 | color=orange" {
+		t.Errorf("Expected one issue in the menubar line, got %q", lines[0])
+	}
+	for _, want := range []string{
+		"3 app(s), last sync 5m ago",
+		"✓ Git\n",
+		"✗ VS Code ¦ Insiders (0/1) | color=red",
+		"Zed (disabled) | color=gray",
+		"param1=sync param2=vscode",
+		"Sync all | bash=/usr/local/bin/configsync param1=sync",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "AppData") {
+		t.Error("Expected paths used on other platforms to be left out")
+	}
+	if strings.Contains(output, "param2=zed") {
+		t.Error("Expected no sync action for a disabled app")
+	}
+}
+
+func TestBuildAppList(t *testing.T) {
+	now := time.Now()
+	apps := map[string]*config.AppConfig{
+		"vscode": {Name: "vscode", DisplayName: "Visual Studio Code", Enabled: true, LastSynced: now.Add(-time.Hour), Paths: []config.Path{{}, {}}},
+		"git":    {Name: "git", DisplayName: "Git", Enabled: true, LastSynced: now, Paths: []config.Path{{}}},
+		"zsh":    {Name: "zsh", DisplayName: "Zsh", Enabled: false},
+	}
+
+	byName, err := buildAppList(apps, "", listSortName, false)
+	if err != nil {
+		t.Fatalf("buildAppList failed: %v", err)
+	}
+	if len(byName) != 3 || byName[0].Name != "git" || byName[2].Name != "zsh" {
+		t.Errorf("Expected apps sorted by name, got %+v", byName)
+	}
+
+	bySynced, err := buildAppList(apps, "", listSortLastSynced, false)
+	if err != nil {
+		t.Fatalf("buildAppList failed: %v", err)
+	}
+	if bySynced[0].Name != "git" || bySynced[1].Name != "vscode" || bySynced[2].LastSynced != nil {
+		t.Errorf("Expected most recently synced first and never synced last, got %+v", bySynced)
+	}
+
+	enabled, err := buildAppList(apps, "", listSortPaths, true)
+	if err != nil {
+		t.Fatalf("buildAppList failed: %v", err)
+	}
+	if len(enabled) != 2 || enabled[0].Name != "vscode" {
+		t.Errorf("Expected enabled apps sorted by path count, got %+v", enabled)
+	}
+
+	filtered, err := buildAppList(apps, "studio", listSortName, false)
+	if err != nil {
+		t.Fatalf("buildAppList failed: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Name != "vscode" {
+		t.Errorf("Expected filter to match display name, got %+v", filtered)
+	}
+
+	if _, err := buildAppList(apps, "", "size", false); err == nil {
+		t.Error("Expected error for invalid sort key")
+	}
+}
+
+func TestSyncThenList(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, ".foorc"), []byte("foo"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		app := config.NewAppConfig(name, name)
+		app.AddPath("~/."+name+"rc", "."+name+"rc", config.PathTypeFile, false)
+		if err := manager.AddApp(app); err != nil {
+			t.Fatalf("Failed to add app: %v", err)
+		}
+	}
+
+	before := time.Now()
+	if err := runSync(syncCmd, []string{"foo"}); err != nil {
+		t.Fatalf("runSync failed: %v", err)
+	}
+
+	cfg, err := config.NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	listed, err := buildAppList(cfg.Apps, "", listSortLastSynced, false)
+	if err != nil {
+		t.Fatalf("buildAppList failed: %v", err)
+	}
+	if listed[0].Name != "foo" || listed[0].LastSynced == nil || listed[0].LastSynced.Before(before) {
+		t.Errorf("Expected the synced app to be listed with its sync time, got %+v", listed[0])
+	}
+	if listed[1].LastSynced != nil {
+		t.Errorf("Expected the app not synced to have no sync time, got %+v", listed[1])
+	}
+}
+
+func TestAddCustomApplication(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+
+	originalPaths := addPaths
+	addPaths = []string{"~/.myapprc", "~/.config/myapp:type=directory"}
+	defer func() { addPaths = originalPaths }()
+
+	if err := runAdd(addCmd, []string{"myapp"}); err != nil {
+		t.Fatalf("runAdd failed: %v", err)
+	}
+
+	app, err := config.NewManager(tempDir).GetApp("myapp")
+	if err != nil {
+		t.Fatalf("Expected myapp to be added: %v", err)
+	}
+	if len(app.Paths) != 2 || app.Paths[1].Type != config.PathTypeDirectory || app.Paths[1].Destination != ".config/myapp" {
+		t.Errorf("Unexpected paths: %+v", app.Paths)
+	}
+
+	if err := runAdd(addCmd, []string{"one", "two"}); err == nil {
+		t.Error("Expected error when --path is used with several applications")
+	}
+}
+
+func TestCatalogAdd(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	catalogFile := filepath.Join(tempDir, "myapps.yaml")
+	content := `apps:
+  - name: myapp
+    display_name: My App
+    paths:
+      - source: ~/.myapprc
+        destination: .myapprc
+        type: file
+    post_sync:
+      - curl https://example.com/setup.sh | sh
+`
+	if err := os.WriteFile(catalogFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write catalog: %v", err)
+	}
+
+	// Without a terminal to confirm them, the post-sync commands are left out
+	for _, trust := range []bool{false, true} {
+		catalogTrustHooks = trust
+		if err := runCatalogAdd(catalogAddCmd, []string{catalogFile}); err != nil {
+			t.Fatalf("runCatalogAdd failed: %v", err)
+		}
+
+		detector, err := newAppDetector()
+		if err != nil {
+			t.Fatalf("newAppDetector failed: %v", err)
+		}
+		var found *apps.AppInfo
+		for _, app := range detector.Catalog() {
+			if app.Name == "myapp" && app.Origin == "myapps" {
+				found = app
+			}
+		}
+		if found == nil {
+			t.Fatal("Expected installed catalog entry to be available to the detector")
+		}
+		if hooked := len(found.PostSync) != 0; hooked != trust {
+			t.Errorf("Expected post-sync commands to be installed only when trusted (trusted: %t), got %v", trust, found.PostSync)
+		}
+	}
+	catalogTrustHooks = false
+}
+
+func TestSetAppsEnabled(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Sync git so disabling it with --unsync has a symlink to remove
+	source := filepath.Join(tempDir, ".gitconfig")
+	if err := os.WriteFile(source, []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	vim := config.NewAppConfig("vim", "Vim")
+	for _, app := range []*config.AppConfig{git, vim} {
+		if err := manager.AddApp(app); err != nil {
+			t.Fatalf("Failed to add app: %v", err)
+		}
+	}
+	if err := symlink.NewManager(tempDir, cfg.StorePath, cfg.BackupPath, false, false).SyncApp(git); err != nil {
+		t.Fatalf("Failed to sync git: %v", err)
+	}
+
+	enabled := func(appName string) bool {
+		t.Helper()
+		cfg, err := config.NewManager(tempDir).Load()
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		return cfg.Apps[appName].IsEnabled()
+	}
+
+	if err := runDisable(disableCmd, nil); err == nil {
+		t.Error("Expected error without app names or --all")
+	}
+	if err := runDisable(disableCmd, []string{"unknown"}); err == nil {
+		t.Error("Expected error for an unknown app")
+	}
+
+	disableUnsync = true
+	defer func() { disableUnsync = false }()
+	if err := runDisable(disableCmd, []string{"git"}); err != nil {
+		t.Fatalf("runDisable failed: %v", err)
+	}
+	if enabled("git") || !enabled("vim") {
+		t.Error("Expected only git to be disabled")
+	}
+	if isSymlink(source) {
+		t.Error("Expected --unsync to replace the symlink with the original file")
+	}
+
+	enableAll = true
+	defer func() { enableAll = false }()
+	if err := runEnable(enableCmd, nil); err != nil {
+		t.Fatalf("runEnable --all failed: %v", err)
+	}
+	if !enabled("git") || !enabled("vim") {
+		t.Error("Expected all apps to be enabled")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	historyJournal = history.Open(manager.GetConfigDir())
+	defer func() { historyJournal = nil }()
+	if err := runDisable(disableCmd, []string{"git"}); err != nil {
+		t.Fatalf("runDisable failed: %v", err)
+	}
+	if err := runEnable(enableCmd, []string{"git"}); err != nil {
+		t.Fatalf("runEnable failed: %v", err)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	filter, err := historyFilter(cfg, []string{"GIT"})
+	if err != nil {
+		t.Fatalf("historyFilter failed: %v", err)
+	}
+	if filter.App != "git" {
+		t.Errorf("Expected the display name to resolve to git, got %q", filter.App)
+	}
+	entries, err := historyJournal.Read(filter)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Operation != history.Disable || entries[1].Operation != history.Enable {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+	if len(entries[0].Paths) != 1 || entries[0].Paths[0] != "~/.gitconfig" {
+		t.Errorf("Expected the app's paths to be recorded, got %v", entries[0].Paths)
+	}
+
+	historyOperation = "rename"
+	if _, err := historyFilter(cfg, nil); err == nil {
+		t.Error("Expected an unknown operation to be rejected")
+	}
+	historyOperation = ""
+	historyOn, historySince = "today", "7d"
+	if _, err := historyFilter(cfg, nil); err == nil {
+		t.Error("Expected --on to be rejected together with --since")
+	}
+	historyOn, historySince = "", ""
+}
+
+func TestUndo(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	historyJournal = history.Open(manager.GetConfigDir())
+	undoYes = true
+	defer func() { historyJournal, undoYes = nil, false }()
+
+	if err := runUndo(undoCmd, nil); err == nil {
+		t.Error("Expected undo to fail with no recorded operations")
+	}
+
+	if err := runDisable(disableCmd, []string{"git"}); err != nil {
+		t.Fatalf("runDisable failed: %v", err)
+	}
+	if err := runUndo(undoCmd, nil); err != nil {
+		t.Fatalf("runUndo failed: %v", err)
+	}
+	if app, err := manager.GetApp("git"); err != nil || !app.IsEnabled() {
+		t.Errorf("Expected undo to enable git again, got %+v %v", app, err)
+	}
+
+	entries, err := historyJournal.Read(history.Filter{Operation: history.Undo})
+	if err != nil || len(entries) != 1 || entries[0].App != "git" || entries[0].Details["operation"] != history.Disable {
+		t.Fatalf("Expected the undo to be recorded, got %+v %v", entries, err)
+	}
+	if err := runUndo(undoCmd, nil); err == nil {
+		t.Error("Expected an undone operation not to be undone again")
+	}
+
+	if err := historyJournal.Record(history.Entry{Operation: history.StoreMove}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	all, _ := historyJournal.Read(history.Filter{})
+	if _, err := lastUndoableOperation(all); err == nil || !strings.Contains(err.Error(), "cannot be undone") {
+		t.Errorf("Expected a store move not to be undoable, got %v", err)
+	}
+}
+
+func TestSyncAndUndoKeepIgnoredEntries(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	sourceDir := filepath.Join(tempDir, ".config", "foo")
+	cacheFile := filepath.Join(sourceDir, "Cache", "c")
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for path, content := range map[string]string{cacheFile: "cache", filepath.Join(sourceDir, "settings.json"): "{}"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	foo := config.NewAppConfig("foo", "Foo")
+	foo.AddPath("~/.config/foo", "foo", config.PathTypeDirectory, false)
+	if err := manager.AddApp(foo); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	historyJournal = history.Open(manager.GetConfigDir())
+	undoYes = true
+	defer func() { historyJournal, undoYes = nil, false }()
+
+	if err := runSync(syncCmd, []string{"foo"}); err != nil {
+		t.Fatalf("runSync failed: %v", err)
+	}
+	if info, err := os.Lstat(sourceDir); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected the directory to be linked to the store, got %v", err)
+	}
+	if data, err := os.ReadFile(cacheFile); err != nil || string(data) != "cache" {
+		t.Errorf("Expected the ignored cache to survive the sync, got %q, %v", data, err)
+	}
+
+	if err := runUndo(undoCmd, nil); err != nil {
+		t.Fatalf("runUndo failed: %v", err)
+	}
+	if info, err := os.Lstat(sourceDir); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Expected undo to move the directory back, got %v", err)
+	}
+	if data, err := os.ReadFile(cacheFile); err != nil || string(data) != "cache" {
+		t.Errorf("Expected the ignored cache to survive the undo, got %q, %v", data, err)
+	}
+}
+
+func TestRemovePurgeAndGC(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	storeCopy := filepath.Join(cfg.StorePath, "git", ".gitconfig")
+	orphan := filepath.Join(cfg.StorePath, "oldapp", "settings.json")
+	for _, path := range []string{storeCopy, orphan} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("[user]"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	removePurgeStore = true
+	defer func() { removePurgeStore = false }()
+	if err := runRemove(removeCmd, []string{"git"}); err != nil {
+		t.Fatalf("runRemove failed: %v", err)
+	}
+	if _, err := os.Stat(storeCopy); !os.IsNotExist(err) {
+		t.Errorf("Expected --purge-store to delete the store copy, got %v", err)
+	}
+
+	gcYes = true
+	defer func() { gcYes = false }()
+	if err := runGC(gcCmd, nil); err != nil {
+		t.Fatalf("runGC failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(orphan)); !os.IsNotExist(err) {
+		t.Errorf("Expected gc to delete the unreferenced directory, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StorePath, "Library", "Preferences")); err != nil {
+		t.Errorf("Expected gc to keep the store layout, got %v", err)
+	}
+}
+
+func TestStoreLockdown(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	storeCopy := filepath.Join(cfg.StorePath, "git", ".gitconfig")
+	if err := os.MkdirAll(filepath.Dir(storeCopy), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(storeCopy, []byte("[user]"), 0644); err != nil {
+		t.Fatalf("Failed to write store copy: %v", err)
+	}
+
+	if err := runStoreLockdown(storeLockdownCmd, nil); err != nil {
+		t.Fatalf("runStoreLockdown failed: %v", err)
+	}
+	defer func() { _, _ = store.Unlock(cfg.StorePath) }()
+	if cfg, _ := manager.Load(); !cfg.Settings.StoreReadOnly() {
+		t.Error("Expected lockdown to mark the store read-only in the settings")
+	}
+	if info, _ := os.Stat(storeCopy); info.Mode().Perm()&0222 != 0 {
+		t.Errorf("Expected the store copy to be read-only, got %04o", info.Mode().Perm())
+	}
+	if checksums, err := manifest.LoadChecksums(cfg.StorePath); err != nil || len(checksums.Files) == 0 {
+		t.Errorf("Expected lockdown to record the store checksums, got %v", err)
+	}
+
+	removePurgeStore = true
+	defer func() { removePurgeStore = false }()
+	if err := runRemove(removeCmd, []string{"git"}); err == nil || !strings.Contains(err.Error(), "store unlock") {
+		t.Errorf("Expected remove to refuse to change a locked-down store, got %v", err)
+	}
+	if _, err := os.Stat(storeCopy); err != nil {
+		t.Errorf("Expected the store copy to be kept, got %v", err)
+	}
+
+	if err := runStoreUnlock(storeUnlockCmd, nil); err != nil {
+		t.Fatalf("runStoreUnlock failed: %v", err)
+	}
+	if cfg, _ := manager.Load(); cfg.Settings.StoreReadOnly() {
+		t.Error("Expected unlock to clear the read-only setting")
+	}
+	if err := os.WriteFile(storeCopy, []byte("[core]"), 0644); err != nil {
+		t.Errorf("Expected the unlocked store copy to be writable: %v", err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	t.Setenv("TMPDIR", filepath.Join(tempDir, "tmp"))
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	importDir := filepath.Join(configDir, "import")
+	stale := filepath.Join(tempDir, "tmp", "configsync-bundle-123")
+	fresh := filepath.Join(tempDir, "tmp", "configsync-bundle-456")
+	for _, dir := range []string{importDir, stale, fresh} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "bundle.yaml"), []byte("version: \"1\"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write bundle: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Failed to age directory: %v", err)
+	}
+
+	cleanYes = true
+	defer func() { cleanYes = false }()
+	if err := runClean(cleanCmd, nil); err != nil {
+		t.Fatalf("runClean failed: %v", err)
+	}
+	for _, dir := range []string{importDir, stale} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected clean to delete %s, got %v", dir, err)
+		}
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("Expected clean to keep a recently used directory, got %v", err)
+	}
+}
+
+func TestUpgradesMigratesMovedPath(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	// Sublime Text 4 keeps its packages in "Sublime Text" rather than "Sublime Text 3"
+	oldDir := filepath.Join(".config", "sublime-text-3", "Packages", "User")
+	newDir := filepath.Join(".config", "sublime-text", "Packages", "User")
+	if config.CurrentPlatform == config.PlatformDarwin {
+		oldDir = filepath.Join("Library", "Application Support", "Sublime Text 3", "Packages", "User")
+		newDir = filepath.Join("Library", "Application Support", "Sublime Text", "Packages", "User")
+	}
+	oldSource, newSource := filepath.Join(tempDir, oldDir), filepath.Join(tempDir, newDir)
+	for source, content := range map[string]string{oldSource: "old settings", newSource: "fresh defaults"} {
+		if err := os.MkdirAll(source, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(source, "Preferences.sublime-settings"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write settings: %v", err)
+		}
+	}
+
+	app := config.NewAppConfig("sublimetext", "Sublime Text")
+	app.AddPath(oldSource, oldDir, config.PathTypeDirectory, false)
+	if err := manager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	symlinkManager := symlink.NewManager(tempDir, cfg.StorePath, cfg.BackupPath, false, false)
+	if err := symlinkManager.SyncApp(cfg.Apps["sublimetext"]); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	upgradesMigrate, upgradesYes = true, true
+	defer func() { upgradesMigrate, upgradesYes = false, false }()
+	if err := runUpgrades(upgradesCmd, nil); err != nil {
+		t.Fatalf("runUpgrades failed: %v", err)
+	}
+
+	cfg, err = config.NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	path := cfg.Apps["sublimetext"].Paths[0]
+	if path.Source != newSource || path.Destination != newDir {
+		t.Errorf("Expected the path to move to %s, got %+v", newDir, path)
+	}
+	if _, err := os.Lstat(oldSource); !os.IsNotExist(err) {
+		t.Errorf("Expected the old symlink to be removed, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(newSource, "Preferences.sublime-settings")); string(data) != "old settings" {
+		t.Errorf("Expected the new location to show the migrated settings, got %q", data)
+	}
+	archived, _ := filepath.Glob(filepath.Join(cfg.BackupPath, symlink.MovedArchiveDir, "*", newDir, "Preferences.sublime-settings"))
+	if len(archived) != 1 {
+		t.Errorf("Expected the fresh defaults to be archived, got %v", archived)
+	}
+}
+
+func TestBuildDoctorReport(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	gitconfig := filepath.Join(tempDir, ".gitconfig")
+	if err := os.WriteFile(gitconfig, []byte("[user]"), 0644); err != nil {
+		t.Fatalf("Failed to write gitconfig: %v", err)
+	}
+	app := config.NewAppConfig("git", "Git")
+	app.AddPath(gitconfig, ".gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	report, err := buildDoctorReport(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("buildDoctorReport failed: %v", err)
+	}
+	if !report.Healthy || len(report.ConfigErrors) != 0 || len(report.Permissions.Issues) != 0 {
+		t.Errorf("Expected a healthy setup, got %+v", report)
+	}
+
+	if err := os.WriteFile(manager.ConfigPath(), []byte("settings:\n  symlink_mode: banana\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	report, err = buildDoctorReport(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("buildDoctorReport failed: %v", err)
+	}
+	if report.Healthy || len(report.ConfigErrors) == 0 {
+		t.Errorf("Expected an invalid configuration to be reported, got %+v", report)
+	}
+}
+
+func TestCheckSyncPermissions(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	app := config.NewAppConfig("git", "Git")
+	app.AddPath(filepath.Join(tempDir, ".gitconfig"), ".gitconfig", config.PathTypeFile, false)
+	apps := map[string]*config.AppConfig{"git": app}
+
+	allowed, blocked := checkSyncPermissions(apps)
+	if len(allowed) != 1 || len(blocked) != 0 {
+		t.Errorf("Expected accessible apps to be synced, got %v allowed and %v blocked", allowed, blocked)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	result, err := validateConfigFile(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("validateConfigFile failed: %v", err)
+	}
+	if !result.Valid || len(result.Errors) != 0 {
+		t.Errorf("Expected a freshly initialized configuration to be valid, got %v", result.Errors)
+	}
+
+	store := filepath.Join(tempDir, ".configsync", "store")
+	content := `version: "1.0"
+store_path: ` + store + `
+backup_path: ` + filepath.Join(store, "backups") + `
+settings:
+  symlink_mode: banana
+  symlnk_mode: soft
+apps:
+  vscode:
+    name: vscode
+    paths:
+      - source: ~/Library/Application Support/Code/User/settings.json
+        destination: Code/settings.json
+        type: file
+  cursor:
+    name: cursor
+    paths:
+      - source: ~/Library/Application Support/Cursor/User
+        destination: Code
+        type: folder
+`
+	path := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	result, err = validateConfigFile(path)
+	if err != nil {
+		t.Fatalf("validateConfigFile failed: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("Expected the configuration to be invalid")
+	}
+
+	problems := strings.Join(result.Errors, "\n")
+	for _, want := range []string{
+		`unknown field "symlnk_mode" in settings (did you mean "symlink_mode"?)`,
+		`settings.symlink_mode: "banana" is not a valid mode`,
+		`apps.cursor.paths[0].type: "folder" is not a valid type`,
+		"nested destination Code/settings.json is used by both vscode.paths[0] and cursor.paths[0]",
+		"backup_path must not be inside the store",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q, got:\n%s", want, problems)
+		}
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "backup_path") {
+		t.Errorf("Expected a warning about the missing backup directory, got %v", result.Warnings)
+	}
+}
+
+func TestValidateConfigFileAppsDir(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	app := config.NewAppConfig("git", "Git")
+	app.AddPath("~/.gitconfig", ".gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	if err := runConfigSplit(nil, nil); err != nil {
+		t.Fatalf("config split failed: %v", err)
+	}
+
+	broken := "paths:\n  - source: ~/.gitconfig.local\n    destination: .gitconfig\n    type: file\n"
+	if err := os.WriteFile(filepath.Join(manager.AppsDir(), "git-local.yaml"), []byte(broken), 0644); err != nil {
+		t.Fatalf("Failed to write app file: %v", err)
+	}
+	result, err := validateConfigFile(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("validateConfigFile failed: %v", err)
+	}
+	if result.Valid || !strings.Contains(strings.Join(result.Errors, "\n"), "destination .gitconfig is used by both") {
+		t.Errorf("Expected the collision between application files to be reported, got %v", result.Errors)
+	}
+}
+
+func TestCheckSyncCollisions(t *testing.T) {
+	foo := config.NewAppConfig("foo", "Foo")
+	foo.AddPath("~/Library/Application Support/Foo", "Library/Application Support/Foo", config.PathTypeDirectory, false)
+	fooBeta := config.NewAppConfig("foo-beta", "Foo Beta")
+	fooBeta.AddPath("~/Library/Application Support/Foo Beta", "Library/Application Support/Foo", config.PathTypeDirectory, false)
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", ".gitconfig", config.PathTypeFile, false)
+	cfg := &config.Config{Apps: map[string]*config.AppConfig{"foo": foo, "foo-beta": fooBeta, "git": git}}
+
+	err := checkSyncCollisions(cfg, cfg.Apps)
+	if err == nil || !strings.Contains(err.Error(), "foo-beta.paths[0]") || !strings.Contains(err.Error(), "--rename-destination") {
+		t.Errorf("Expected an error listing the colliding apps, got %v", err)
+	}
+
+	if err := checkSyncCollisions(cfg, map[string]*config.AppConfig{"git": git}); err != nil {
+		t.Errorf("Expected syncing only git to be allowed, got %v", err)
+	}
+
+	fooBeta.Enabled = false
+	if err := checkSyncCollisions(cfg, map[string]*config.AppConfig{"foo-beta": fooBeta}); err != nil {
+		t.Errorf("Expected a disabled app not to block sync, got %v", err)
+	}
+}
+
+func TestApplyDestinationRenames(t *testing.T) {
+	defer func() { addRenames = nil }()
+
+	newApp := func() *config.AppConfig {
+		app := config.NewAppConfig("foo-beta", "Foo Beta")
+		app.AddPath("~/Library/Application Support/Foo Beta", "Library/Application Support/Foo", config.PathTypeDirectory, false)
+		return app
+	}
+
+	addRenames = []string{"Library/Application Support/Foo=Library/Application Support/Foo Beta"}
+	app := newApp()
+	if err := applyDestinationRenames(app); err != nil {
+		t.Fatalf("applyDestinationRenames failed: %v", err)
+	}
+	if app.Paths[0].Destination != "Library/Application Support/Foo Beta" {
+		t.Errorf("Destination = %q, want it renamed", app.Paths[0].Destination)
+	}
+
+	for _, rename := range []string{"no-separator", "Library=/etc", "Library=../outside", "Missing=Other"} {
+		addRenames = []string{rename}
+		if err := applyDestinationRenames(newApp()); err == nil {
+			t.Errorf("Expected --rename-destination %q to be rejected", rename)
+		}
+	}
+}
+
+func TestSelectDeployApps(t *testing.T) {
+	bundle := &config.DeploymentBundle{
+		Apps: map[string]*config.AppConfig{
+			"git":    config.NewAppConfig("git", "Git"),
+			"iterm2": config.NewAppConfig("iterm2", "iTerm2"),
+			"vscode": config.NewAppConfig("vscode", "Visual Studio Code"),
+		},
+	}
+
+	originalApps, originalSkip := deployApps, deploySkip
+	defer func() { deployApps, deploySkip = originalApps, originalSkip }()
+
+	deployApps, deploySkip = []string{"git", "vscode"}, []string{"vscode"}
+	selected, err := selectDeployApps(bundle, &config.Config{})
+	if err != nil {
+		t.Fatalf("selectDeployApps failed: %v", err)
+	}
+	if len(selected.Apps) != 1 || selected.Apps["git"] == nil {
+		t.Errorf("Expected only git to be selected, got %v", selected.Apps)
+	}
+
+	deployApps, deploySkip = nil, []string{"firefox"}
+	if _, err := selectDeployApps(bundle, &config.Config{}); err == nil {
+		t.Error("Expected error when skipping an app that is not in the bundle")
+	}
+
+	// The local hosts section wins over the bundle's
+	host := config.CurrentHost
+	config.CurrentHost = "laptop.local"
+	defer func() { config.CurrentHost = host }()
+	bundle.Hosts = map[string]*config.HostOverride{"laptop": {Exclude: []string{"git"}}}
+	deployApps, deploySkip = nil, nil
+	selected, err = selectDeployApps(bundle, &config.Config{})
+	if err != nil {
+		t.Fatalf("selectDeployApps failed: %v", err)
+	}
+	if len(selected.Apps) != 2 || selected.Apps["git"] != nil {
+		t.Errorf("Expected the bundle's hosts section to leave out git, got %v", selected.Apps)
+	}
+	local := &config.Config{Hosts: map[string]*config.HostOverride{"Laptop": {Include: []string{"git"}}}}
+	selected, err = selectDeployApps(bundle, local)
+	if err != nil {
+		t.Fatalf("selectDeployApps failed: %v", err)
+	}
+	if len(selected.Apps) != 1 || selected.Apps["git"] == nil {
+		t.Errorf("Expected the local hosts section to select only git, got %v", selected.Apps)
+	}
+}
+
+func TestImportPackages(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	repoDir := filepath.Join(tempDir, "dotfiles")
+	for rel, content := range map[string]string{
+		"git/.gitconfig": "[user]",
+		"zsh/.zshrc":     "export A=1",
+		"zsh2/.zshrc":    "export A=2",
+		"vim/dot-vimrc":  "set nu",
+		"vim/README.md":  "docs",
+	} {
+		path := filepath.Join(repoDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+	if err := manager.AddApp(config.NewAppConfig("git", "Git")); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	result, err := migrate.ReadStow(repoDir)
+	if err != nil {
+		t.Fatalf("ReadStow failed: %v", err)
+	}
+
+	imported, skipped := importPackages(manager, cfg, result.Packages)
+	if strings.Join(imported, ",") != "vim,zsh" {
+		t.Errorf("Expected vim and zsh to be imported, got %v", imported)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("Expected the existing git app and the colliding zsh2 package to be skipped, got %v", skipped)
+	}
+
+	if _, err := os.Stat(filepath.Join(cfg.StorePath, ".vimrc")); err != nil {
+		t.Errorf("Expected .vimrc in the store: %v", err)
+	}
+	vim := cfg.Apps["vim"]
+	if vim == nil || len(vim.Paths) != 1 || vim.Paths[0].Destination != ".vimrc" {
+		t.Errorf("Expected the vim app to manage .vimrc, got %+v", vim)
+	}
+}
+
+func TestCollectStatusCache(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	storeDir := filepath.Join(tempDir, "store")
+	storeFile := filepath.Join(storeDir, "settings.json")
+	sourceFile := filepath.Join(tempDir, "settings.json")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	if err := os.Symlink(storeFile, sourceFile); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	cfg := config.NewDefaultConfig(storeDir, filepath.Join(tempDir, "backup"), filepath.Join(tempDir, "logs"))
+	app := config.NewAppConfig("editor", "Editor")
+	app.AddPath(sourceFile, "settings.json", config.PathTypeFile, false)
+	cfg.Apps["editor"] = app
+
+	cache := statuscache.Load(tempDir)
+	if status := collectStatus(cfg, "", false, cache).Apps[0].Paths[0].Status; status != statusSynced {
+		t.Fatalf("Expected the path to be %s, got %s", statusSynced, status)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// The cached status is used while the files are unchanged
+	cache = statuscache.Load(tempDir)
+	cache.Entries[statuscache.Key("editor", "settings.json")].Status = "cached"
+	if status := collectStatus(cfg, "", false, cache).Apps[0].Paths[0].Status; status != "cached" {
+		t.Errorf("Expected the cached status, got %s", status)
+	}
+
+	// Replacing the symlink with a file invalidates it
+	if err := os.Remove(sourceFile); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.WriteFile(sourceFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if status := collectStatus(cfg, "", false, cache).Apps[0].Paths[0].Status; status != statusNotSynced {
+		t.Errorf("Expected the changed path to be rechecked as %s, got %s", statusNotSynced, status)
+	}
+}
+
+func TestPrintShortStatusExitCodes(t *testing.T) {
+	synced := appStatus{Name: "editor", Enabled: true, Synced: 1, Paths: []pathStatus{{Status: statusSynced}}}
+	drifted := appStatus{Name: "shell", Enabled: true, Paths: []pathStatus{{Status: statusNotSynced}}}
+	failed := appStatus{Name: "mail", Enabled: true, Paths: []pathStatus{{Status: statusNoAccess}}}
+	disabled := appStatus{Name: "old", Paths: []pathStatus{{Status: statusNotSynced}}}
+
+	for _, tc := range []struct {
+		name string
+		apps []appStatus
+		code int
+	}{
+		{"clean", []appStatus{synced, disabled}, 0},
+		{"drift", []appStatus{synced, drifted}, statusExitDrift},
+		{"errors", []appStatus{drifted, failed}, statusExitError},
+	} {
+		err := printShortStatus(&statusReport{Apps: tc.apps})
+		if tc.code == 0 {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tc.name, err)
+			}
+			continue
+		}
+		if code := ExitCode(err); err == nil || code != tc.code {
+			t.Errorf("%s: expected exit code %d, got %d (%v)", tc.name, tc.code, code, err)
+		}
+	}
+}
+
+func TestEditRejectsInvalidChanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore_global"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// The editor replaces one word of the file with another
+	edit := func(from, to string) {
+		script := filepath.Join(tempDir, "editor.sh")
+		content := fmt.Sprintf("#!/bin/sh\nsed -i.orig 's|%s|%s|' \"$1\"\n", from, to)
+		if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+			t.Fatalf("Failed to write editor: %v", err)
+		}
+		t.Setenv("VISUAL", script)
+	}
+
+	for _, tc := range []struct{ from, to string }{
+		{"display_name", "dispaly_name"}, // Unknown field
+		{"~/.gitconfig", "~/.gitconfgi"}, // Source that does not exist anywhere
+		{"type: file", "type: folder"},   // Invalid value
+	} {
+		edit(tc.from, tc.to)
+		if err := runEdit(editCmd, []string{"git"}); err == nil {
+			t.Errorf("Expected replacing %q with %q to be rejected", tc.from, tc.to)
+		}
+	}
+	if app, _ := config.NewManager(tempDir).GetApp("git"); app.DisplayName != "Git" || app.Paths[0].Source != "~/.gitconfig" {
+		t.Errorf("Expected the rejected edits to leave the configuration alone, got %+v", app)
+	}
+
+	edit("~/.gitconfig", "~/.gitignore_global")
+	if err := runEdit(editCmd, []string{"git"}); err != nil {
+		t.Fatalf("runEdit failed: %v", err)
+	}
+	if app, _ := config.NewManager(tempDir).GetApp("git"); app.Paths[0].Source != "~/.gitignore_global" {
+		t.Errorf("Expected the edit to be saved, got %+v", app.Paths)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	defer func() {
+		provisionBundle, provisionReportURL, provisionSHA256, provisionNonInteractive, nonInteractive = "", "", "", false, false
+	}()
+
+	// Export a bundle from another machine's configuration
+	otherHome := filepath.Join(tempDir, "other")
+	otherManager := config.NewManager(otherHome)
+	if err := otherManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	otherConfig, err := otherManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(otherConfig.StorePath, ".gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", ".gitconfig", config.PathTypeFile, false)
+	if err := otherManager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	bundlePath := filepath.Join(tempDir, "team.tar.gz")
+	if err := deploy.NewManager(otherHome, otherConfig.StorePath, otherConfig.BackupPath, false).ExportBundle(bundlePath, nil, otherManager); err != nil {
+		t.Fatalf("Failed to export bundle: %v", err)
+	}
+	digest, err := deploy.HashBundle(bundlePath)
+	if err != nil {
+		t.Fatalf("Failed to hash bundle: %v", err)
+	}
+
+	var reports []provisionReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/team.tar.gz":
+			http.ServeFile(w, r, bundlePath)
+		case "/report":
+			var report provisionReport
+			if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+				t.Errorf("Failed to decode report: %v", err)
+			}
+			reports = append(reports, report)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	provisionReportURL = server.URL + "/report"
+	provisionNonInteractive = true
+	provisionSHA256 = digest
+
+	provisionBundle = server.URL + "/missing.tar.gz"
+	if err := runProvision(nil, nil); ExitCode(err) != provisionExitDownload {
+		t.Errorf("Expected exit code %d for a failed download, got %v", provisionExitDownload, err)
+	}
+	if len(reports) != 1 || reports[0].Status != provisionFailed || reports[0].FailedStage != provisionStageDownload {
+		t.Errorf("Expected a failure report for the download stage, got %+v", reports)
+	}
+	if !newConfigManager().ConfigExists() {
+		t.Error("Expected provisioning to initialize ConfigSync")
+	}
+
+	provisionBundle = server.URL + "/team.tar.gz"
+	if err := runProvision(nil, nil); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+	if len(reports) != 2 || reports[1].Status != provisionSucceeded || strings.Join(reports[1].Apps, ",") != "git" || reports[1].BundleHash == "" {
+		t.Errorf("Expected a success report naming the deployed app, got %+v", reports[1:])
+	}
+	if target, err := os.Readlink(filepath.Join(tempDir, ".gitconfig")); err != nil || !strings.HasSuffix(target, ".gitconfig") {
+		t.Errorf("Expected ~/.gitconfig to be synced, got %s, %v", target, err)
+	}
+
+	provisionReportURL = server.URL + "/unknown"
+	if err := runProvision(nil, nil); ExitCode(err) != provisionExitReport {
+		t.Errorf("Expected exit code %d when the report cannot be posted, got %v", provisionExitReport, err)
+	}
+}
+
+func TestImportRemoteBundle(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	defer func() { importSHA256 = "" }()
+
+	manager := newConfigManager()
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.StorePath, ".gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", ".gitconfig", config.PathTypeFile, false)
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	bundlePath := filepath.Join(tempDir, "eng-baseline.tar.gz")
+	if err := deploy.NewManager(tempDir, cfg.StorePath, cfg.BackupPath, false).ExportBundle(bundlePath, nil, manager); err != nil {
+		t.Fatalf("Failed to export bundle: %v", err)
+	}
+	digest, err := deploy.HashBundle(bundlePath)
+	if err != nil {
+		t.Fatalf("Failed to hash bundle: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, bundlePath)
+	}))
+	defer server.Close()
+	bundleURL := server.URL + "/bundles/eng-baseline.tar.gz"
+
+	importSHA256 = strings.Repeat("0", 64)
+	if err := runImport(nil, []string{bundleURL}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a bundle with another digest to be rejected, got %v", err)
+	}
+	if err := runImport(nil, []string{bundlePath}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a local bundle with another digest to be rejected, got %v", err)
+	}
+
+	importSHA256 = digest
+	if err := runImport(nil, []string{bundleURL}); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	record, err := deploy.LoadImportRecord(filepath.Join(configDir, "import"))
+	if err != nil || record == nil || record.Source != bundleURL {
+		t.Errorf("Expected the import to record the bundle URL, got %+v, %v", record, err)
+	}
+}
+
+func TestRestoreSelectedPaths(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	vscode := config.NewAppConfig("vscode", "VS Code")
+	vscode.AddPath("~/Code/settings.json", "Code/settings.json", config.PathTypeFile, false)
+	vscode.AddPath("~/Code/keybindings.json", "Code/keybindings.json", config.PathTypeFile, false)
+	if err := manager.AddApp(vscode); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	backupManager := backup.NewManager(cfg.BackupPath, homeDir, false)
+	for _, path := range vscode.Paths {
+		source := filepath.Join(tempDir, strings.TrimPrefix(path.Source, "~/"))
+		if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(source, []byte("backed up"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", source, err)
+		}
+		if err := backupManager.BackupPath("vscode", &path); err != nil {
+			t.Fatalf("BackupPath failed: %v", err)
+		}
+		if err := os.WriteFile(source, []byte("changed"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", source, err)
+		}
+	}
+
+	restorePaths = []string{"keybindings.json"}
+	defer func() { restorePaths = nil }()
+	if err := runRestore(restoreCmd, []string{"vscode"}); err != nil {
+		t.Fatalf("runRestore failed: %v", err)
+	}
+	for name, want := range map[string]string{"keybindings.json": "backed up", "settings.json": "changed"} {
+		if content, _ := os.ReadFile(filepath.Join(tempDir, "Code", name)); string(content) != want {
+			t.Errorf("Expected %s to hold %q, got %q", name, want, string(content))
+		}
+	}
+}
+
+func TestParseBackupChoice(t *testing.T) {
+	tests := []struct {
+		answer  string
+		index   int
+		skip    bool
+		wantErr bool
+	}{
+		{answer: "", index: -1},
+		{answer: " 2 ", index: 1},
+		{answer: "S", skip: true},
+		{answer: "0", wantErr: true},
+		{answer: "4", wantErr: true},
+		{answer: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		index, skip, err := parseBackupChoice(tt.answer, 3)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBackupChoice(%q) error = %v, wantErr %t", tt.answer, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (index != tt.index || skip != tt.skip) {
+			t.Errorf("parseBackupChoice(%q) = %d, %t; want %d, %t", tt.answer, index, skip, tt.index, tt.skip)
+		}
+	}
+}
+
+func TestApplyWizardChoices(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	choices := &wizardChoices{
+		StorePath: filepath.Join(tempDir, "Dropbox", "configsync"),
+		Retention: 30,
+		Apps:      []*config.AppConfig{git},
+	}
+	cfg, err := applyWizardChoices(config.NewManager(tempDir), choices)
+	if err != nil {
+		t.Fatalf("applyWizardChoices failed: %v", err)
+	}
+
+	loaded, err := config.NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if loaded.StorePath != choices.StorePath || cfg.StorePath != choices.StorePath {
+		t.Errorf("Expected the store at %s, got %s", choices.StorePath, loaded.StorePath)
+	}
+	if loaded.Settings.BackupRetention != 30 {
+		t.Errorf("Expected backups to be kept 30 days, got %d", loaded.Settings.BackupRetention)
+	}
+	if _, exists := loaded.Apps["git"]; !exists {
+		t.Error("Expected the chosen application to be added")
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		answer  string
+		want    []int
+		wantErr bool
+	}{
+		{answer: "", want: nil},
+		{answer: "all", want: []int{0, 1, 2, 3}},
+		{answer: "2", want: []int{1}},
+		{answer: "1, 3-4, 3", want: []int{0, 2, 3}},
+		{answer: "5", wantErr: true},
+		{answer: "3-2", wantErr: true},
+		{answer: "vim", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.answer, 4)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %t", tt.answer, err, tt.wantErr)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	manager := config.NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath("~/.gitconfig", "git/.gitconfig", config.PathTypeFile, false)
+	source := filepath.Join(tempDir, ".gitconfig")
+	if err := os.WriteFile(source, []byte("[user]"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", source, err)
+	}
+	if err := symlink.NewManager(tempDir, cfg.StorePath, cfg.BackupPath, false, false).SyncApp(git); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if err := manager.AddApp(git); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	dryRun = true
+	err = runUninstall(uninstallCmd, nil)
+	dryRun = false
+	if err != nil {
+		t.Fatalf("runUninstall --dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(configDir); err != nil {
+		t.Fatalf("Expected a dry run to keep %s, got %v", configDir, err)
+	}
+
+	uninstallYes = true
+	defer func() { uninstallYes = false }()
+	if err := runUninstall(uninstallCmd, nil); err != nil {
+		t.Fatalf("runUninstall failed: %v", err)
+	}
+	info, err := os.Lstat(source)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Expected %s to be a regular file again, got %v, %v", source, info, err)
+	}
+	if content, _ := os.ReadFile(source); string(content) != "[user]" {
+		t.Errorf("Expected the configuration to be copied back, got %q", string(content))
+	}
+	if _, err := os.Stat(configDir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be deleted, got %v", configDir, err)
+	}
+}
+
+func TestUninstallKeepsForeignFiles(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dotfiles := filepath.Join(tempDir, "dotfiles")
+	configFile := filepath.Join(dotfiles, "configsync.yaml")
+	originalOptions := configOptions
+	configOptions = []config.Option{config.WithConfigFile(configFile)}
+	defer func() { configOptions = originalOptions }()
+	if err := newConfigManager().Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	unrelated := filepath.Join(dotfiles, "vimrc")
+	if err := os.WriteFile(unrelated, []byte("set number"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", unrelated, err)
+	}
+
+	uninstallYes = true
+	defer func() { uninstallYes = false }()
+	if err := runUninstall(uninstallCmd, nil); err == nil || !strings.Contains(err.Error(), "vimrc") {
+		t.Fatalf("Expected uninstall to refuse to delete vimrc, got %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Fatalf("Expected the unrelated file to be kept, got %v", err)
+	}
+
+	if err := os.Remove(unrelated); err != nil {
+		t.Fatalf("Failed to remove %s: %v", unrelated, err)
+	}
+	if err := runUninstall(uninstallCmd, nil); err != nil {
+		t.Fatalf("runUninstall failed: %v", err)
+	}
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the configuration file to be deleted, got %v", err)
+	}
+
+	configOptions = []config.Option{config.WithConfigFile(filepath.Join(tempDir, "configsync.yaml"))}
+	if err := newConfigManager().Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := runUninstall(uninstallCmd, nil); err == nil || !strings.Contains(err.Error(), "home directory") {
+		t.Errorf("Expected uninstall to refuse to run in the home directory, got %v", err)
+	}
+}
+
+func TestSelectAppNames(t *testing.T) {
+	cfg := &config.Config{
+		Apps: map[string]*config.AppConfig{
+			"vscode": config.NewAppConfig("vscode", "VS Code"),
+			"nvim":   config.NewAppConfig("nvim", "Neovim"),
+			"git":    config.NewAppConfig("git", "Git"),
+		},
+		Groups: map[string][]string{"editors": {"vscode", "nvim"}},
+	}
+
+	tests := []struct {
+		args     []string
+		except   []string
+		expected []string
+	}{
+		{nil, nil, nil},
+		{[]string{"@editors"}, nil, []string{"vscode", "nvim"}},
+		{nil, []string{"@editors"}, []string{"git"}},
+		{[]string{"@editors", "git"}, []string{"nvim"}, []string{"git", "vscode"}},
+	}
+	for _, tt := range tests {
+		names, err := selectAppNames(cfg, tt.args, tt.except)
+		if err != nil {
+			t.Errorf("selectAppNames(%v, %v) failed: %v", tt.args, tt.except, err)
+			continue
+		}
+		if !slices.Equal(names, tt.expected) {
+			t.Errorf("selectAppNames(%v, %v) = %v, expected %v", tt.args, tt.except, names, tt.expected)
+		}
+	}
+
+	if _, err := selectAppNames(cfg, []string{"@editors"}, []string{"@editors"}); err == nil {
+		t.Error("Expected an error when --except leaves nothing")
+	}
+	if _, err := selectAppNames(cfg, nil, []string{"xcode"}); err == nil {
+		t.Error("Expected an error for an excepted application that is not configured")
+	}
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the ConfigSync configuration file",
+	Long: `Inspect the ConfigSync configuration file (~/.configsync/config.yaml), and
+choose how applications are laid out in it.
+
+Examples:
+  configsync config validate                 # Check the configuration for problems
+  configsync config validate ./config.yaml   # Check a configuration file before using it
+  configsync config split                    # Keep each application in its own file`,
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check the configuration file for problems",
+	Long: `Check the configuration file against the schema and report every problem
+found, rather than stopping at the first one:
+
+  - Unknown fields, such as misspelled setting names
+  - Invalid values, such as an unknown symlink_mode or path type
+  - Application paths that collide: two paths sharing a store destination or a
+    source, or a destination inside another path's directory
+  - Store and backup paths that are missing, not directories, not writable, or
+    inside each other
+
+The application files in the apps.d directory next to the configuration file
+are checked along with it. Without a file, the ConfigSync configuration is checked. The command exits with
+an error when any problem is found; warnings alone do not fail it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigValidate,
+}
+
+// configSplitCmd represents the config split command
+var configSplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Move each application into its own file in apps.d",
+	Long: `Move the applications out of config.yaml into one file each, named
+<app>.yaml, in the apps.d directory next to it. Settings, hosts, and paths stay in
+config.yaml.
+
+With many applications, the files are easier to review and diff in git, and
+adding or changing an application only rewrites its own file. While apps.d
+exists, the applications in it are loaded along with config.yaml, and files
+can be added or removed by hand; an application may not be defined in both.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigSplit,
+}
+
+// configJoinCmd represents the config join command
+var configJoinCmd = &cobra.Command{
+	Use:   "join",
+	Short: "Move the applications in apps.d back into config.yaml",
+	Long: `Move the applications in the apps.d directory back under apps in config.yaml,
+and remove apps.d, undoing 'configsync config split'.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigJoin,
+}
+
+// configValidation is the structured result of the config validate command
+type configValidation struct {
+	ConfigPath string   `json:"config_path" yaml:"config_path"`
+	Errors     []string `json:"errors" yaml:"errors"`
+	Warnings   []string `json:"warnings" yaml:"warnings"`
+	Valid      bool     `json:"valid" yaml:"valid"`
+}
+
+func runConfigValidate(_ *cobra.Command, args []string) error {
+	configPath := newConfigManager().ConfigPath()
+	if len(args) > 0 {
+		configPath = args[0]
+	} else if !newConfigManager().ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	result, err := validateConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if err := printStructured(result); err != nil {
+			return err
+		}
+	} else {
+		printConfigValidation(result)
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("configuration has %d problem(s)", len(result.Errors))
+	}
+	return nil
+}
+
+// validateConfigFile lints a configuration file, its application paths, and its store and backup paths
+func validateConfigFile(configPath string) (*configValidation, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	result := &configValidation{ConfigPath: configPath, Errors: []string{}, Warnings: []string{}}
+
+	cfg, problems, err := config.CheckConfig(data)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("not valid YAML: %v", err))
+		return result, nil
+	}
+	result.Errors = append(result.Errors, problems...)
+
+	appsDir := filepath.Join(filepath.Dir(configPath), config.DefaultAppsDir)
+	if info, err := os.Stat(appsDir); err == nil && info.IsDir() {
+		apps, appProblems, err := config.CheckAppsDir(appsDir)
+		if err != nil {
+			return nil, err
+		}
+		result.Errors = append(result.Errors, appProblems...)
+		if cfg.Apps == nil {
+			cfg.Apps = make(map[string]*config.AppConfig)
+		}
+		for appName, app := range apps {
+			if _, ok := cfg.Apps[appName]; ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("apps.%s: also defined in %s", appName, filepath.Join(config.DefaultAppsDir, appName+".yaml")))
+				continue
+			}
+			cfg.Apps[appName] = app
+		}
+	}
+
+	for _, collision := range cfg.PathCollisions() {
+		result.Errors = append(result.Errors, collision.String())
+	}
+
+	storeProblems, storeWarnings := checkConfigDir("store_path", cfg.StorePath, true)
+	backupProblems, backupWarnings := checkConfigDir("backup_path", cfg.BackupPath, false)
+	result.Errors = append(append(result.Errors, storeProblems...), backupProblems...)
+	result.Warnings = append(append(result.Warnings, storeWarnings...), backupWarnings...)
+
+	if cfg.StorePath != "" && cfg.BackupPath != "" {
+		store, backups := filepath.Clean(cfg.StorePath), filepath.Clean(cfg.BackupPath)
+		switch {
+		case store == backups:
+			result.Errors = append(result.Errors, "store_path and backup_path must be different directories")
+		case strings.HasPrefix(backups, store+string(filepath.Separator)):
+			result.Errors = append(result.Errors, "backup_path must not be inside the store, or backups would be synced as configuration")
+		case strings.HasPrefix(store, backups+string(filepath.Separator)):
+			result.Errors = append(result.Errors, "store_path must not be inside the backup directory, or backup cleanup could delete it")
+		}
+	}
+
+	if cfg.Settings == nil {
+		result.Warnings = append(result.Warnings, "settings: missing; defaults are used")
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+func runConfigSplit(_ *cobra.Command, _ []string) error {
+	return changeAppsLayout(true)
+}
+
+func runConfigJoin(_ *cobra.Command, _ []string) error {
+	return changeAppsLayout(false)
+}
+
+// changeAppsLayout moves the applications from config.yaml into apps.d, or back, while no other
+// command changes the configuration
+func changeAppsLayout(split bool) error {
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	operation, change, destination := "join", manager.JoinApps, manager.ConfigPath()
+	if split {
+		operation, change, destination = "split", manager.SplitApps, manager.AppsDir()
+	}
+	if split == manager.SplitLayout() {
+		return fmt.Errorf("applications are already kept in %s", destination)
+	}
+
+	release, err := lockApps(manager, operation, configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would move %d application(s) into %s\n", len(cfg.Apps), destination)
+		return nil
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	printer.Success("%s", messages.Localize(messages.AppsMoved, messages.Data{"Count": len(cfg.Apps), "Path": destination}))
+	return nil
+}
+
+// checkConfigDir verifies that a store or backup directory from the configuration can be used.
+// A missing store is an error because synced symlinks point into it; a missing backup
+// directory is only a warning because it is created when the first backup is made.
+func checkConfigDir(field, dir string, required bool) (problems, warnings []string) {
+	if dir == "" {
+		return []string{field + ": is required"}, nil
+	}
+	if !filepath.IsAbs(dir) {
+		return []string{fmt.Sprintf("%s: %q must be an absolute path", field, dir)}, nil
+	}
+
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		message := fmt.Sprintf("%s: %s does not exist", field, dir)
+		if required {
+			return []string{message}, nil
+		}
+		return nil, []string{message + " (it will be created when needed)"}
+	case err != nil:
+		return []string{fmt.Sprintf("%s: %v", field, err)}, nil
+	case !info.IsDir():
+		return []string{fmt.Sprintf("%s: %s is not a directory", field, dir)}, nil
+	}
+
+	probe, err := os.CreateTemp(dir, ".configsync-validate-*")
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s is not writable: %v", field, dir, err)}, nil
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return nil, nil
+}
+
+// printConfigValidation displays the result of validating a configuration file
+func printConfigValidation(result *configValidation) {
+	fmt.Printf("Validating %s\n", result.ConfigPath)
+
+	for _, problem := range result.Errors {
+		printer.Failure("%s", problem)
+	}
+	for _, warning := range result.Warnings {
+		printer.Warning("%s", warning)
+	}
+
+	if result.Valid {
+		printer.Success("%s", messages.Localize(messages.ConfigValid, nil))
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSplitCmd)
+	configCmd.AddCommand(configJoinCmd)
+}
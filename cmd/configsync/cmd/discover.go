@@ -5,8 +5,12 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
 	"github.com/dotbrains/configsync/pkg/apps"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +19,7 @@ var (
 	discoverAutoAdd bool
 	discoverList    bool
 	discoverFilter  string
+	discoverRefresh bool
 )
 
 // discoverCmd represents the discover command
@@ -29,6 +34,17 @@ detects their configuration files and directories. It uses multiple detection me
 3. Spotlight Search: Uses mdfind to locate .app bundles
 4. Directory Scanning: Scans common app installation locations
 5. Smart Pattern Detection: Automatically detects config paths using common patterns
+6. Command-Line Tools: Finds tools on PATH and installed with Homebrew, such as
+   tmux, nvim, starship, and fish, and proposes their ~/.config/<tool> directory
+   or ~/.<tool>rc file when they are not known applications
+
+The scan of installed applications is cached for a day in ~/.configsync, and
+scanned again as soon as applications or tools are installed or removed, i.e.
+when /Applications, ~/Applications, a directory on PATH, or ~/.config changes.
+Use --refresh to scan again regardless.
+
+On Linux, methods 2-4 are replaced by desktop entries in the XDG data
+directories, and known applications are configured with their XDG paths.
 
 Examples:
   # List all discovered applications
@@ -40,22 +56,59 @@ Examples:
   # Filter results to specific apps
   configsync discover --filter="chrome,slack,vscode"
 
+  # Scan again instead of using the cached scan
+  configsync discover --refresh
+
   # Discover and show details in dry-run mode
   configsync discover --dry-run --verbose`,
 	RunE: runDiscover,
 }
 
+// discoveredApp is the structured result for one discovered application
+type discoveredApp struct {
+	Name        string           `json:"name" yaml:"name"`
+	DisplayName string           `json:"display_name" yaml:"display_name"`
+	BundleID    string           `json:"bundle_id,omitempty" yaml:"bundle_id,omitempty"`
+	Status      string           `json:"status" yaml:"status"`
+	Paths       []discoveredPath `json:"paths" yaml:"paths"`
+}
+
+// discoveredPath is a configuration path found for a discovered application
+type discoveredPath struct {
+	Source string `json:"source" yaml:"source"`
+	Type   string `json:"type" yaml:"type"`
+}
+
+// autoAddReport is the structured result of discover --auto-add
+type autoAddReport struct {
+	Added   []string `json:"added" yaml:"added"`
+	Skipped []string `json:"skipped" yaml:"skipped"`
+	DryRun  bool     `json:"dry_run" yaml:"dry_run"`
+}
+
 func init() {
 	discoverCmd.Flags().BoolVar(&discoverAutoAdd, "auto-add", false, "automatically add discovered apps to configuration")
 	discoverCmd.Flags().BoolVar(&discoverList, "list", false, "list all discovered applications")
 	discoverCmd.Flags().StringVar(&discoverFilter, "filter", "", "comma-separated list of app names to filter results")
+	discoverCmd.Flags().BoolVar(&discoverRefresh, "refresh", false, "scan installed applications again instead of using the cached scan")
 }
 
 func runDiscover(_ *cobra.Command, _ []string) error {
 	// Initialize detector
-	detector := apps.NewAppDetector(homeDir)
+	detector, err := newAppDetector()
+	if err != nil {
+		return err
+	}
+
+	showText := !structuredOutput()
+
+	if discoverRefresh {
+		if err := detector.InvalidateScanCache(); err != nil {
+			return err
+		}
+	}
 
-	if verbose {
+	if verbose && showText {
 		fmt.Printf("Scanning for installed applications...\n")
 	}
 
@@ -65,8 +118,12 @@ func runDiscover(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to scan installed apps: %v", err)
 	}
 
-	if verbose {
-		fmt.Printf("Found %d installed applications\n\n", len(installedApps))
+	if verbose && showText {
+		fmt.Printf("Found %d installed applications", len(installedApps))
+		if age := time.Since(detector.LastScan()); age > time.Minute {
+			fmt.Printf(" (cached scan from %s ago; use --refresh to scan again)", age.Round(time.Minute))
+		}
+		fmt.Printf("\n\n")
 	}
 
 	// Auto-detect configurations
@@ -74,6 +131,9 @@ func runDiscover(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to auto-detect app configurations: %v", err)
 	}
+	for _, pluginErr := range detector.PluginErrors() {
+		printer.Warning("%v", pluginErr)
+	}
 
 	// Filter results if requested
 	var filteredConfigs []*config.AppConfig
@@ -91,18 +151,62 @@ func runDiscover(_ *cobra.Command, _ []string) error {
 		detectedConfigs = filteredConfigs
 	}
 
-	if discoverList {
-		return printDiscoveredApps(detectedConfigs, installedApps)
-	}
-
 	if discoverAutoAdd {
 		return autoAddDiscoveredApps(detectedConfigs)
 	}
 
+	if !showText {
+		return printStructured(buildDiscoveredApps(detectedConfigs, installedApps))
+	}
+
+	if discoverList {
+		return printDiscoveredApps(detectedConfigs, installedApps)
+	}
+
 	// Default behavior: show summary and ask for confirmation
 	return showDiscoveryResults(detectedConfigs)
 }
 
+// buildDiscoveredApps converts detected configurations into structured results
+func buildDiscoveredApps(detectedConfigs []*config.AppConfig, installedApps []apps.InstalledApp) []discoveredApp {
+	installedMap := make(map[string]apps.InstalledApp)
+	for _, app := range installedApps {
+		installedMap[app.Name] = app
+	}
+
+	results := []discoveredApp{}
+	for _, appConfig := range detectedConfigs {
+		result := discoveredApp{
+			Name:        appConfig.Name,
+			DisplayName: appConfig.DisplayName,
+			BundleID:    appConfig.BundleID,
+			Status:      discoveryStatus(installedMap, appConfig.Name),
+			Paths:       []discoveredPath{},
+		}
+		for _, path := range appConfig.Paths {
+			result.Paths = append(result.Paths, discoveredPath{Source: path.Source, Type: string(path.Type)})
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// discoveryStatus describes how an application was found
+func discoveryStatus(installedMap map[string]apps.InstalledApp, name string) string {
+	installedApp, exists := installedMap[name]
+	if !exists {
+		return "Unknown"
+	}
+	if installedApp.CLI {
+		return "CLI tool"
+	}
+	if installedApp.BundleID != "" {
+		return "Installed"
+	}
+	return "Detected"
+}
+
 func printDiscoveredApps(detectedConfigs []*config.AppConfig, installedApps []apps.InstalledApp) error {
 	if len(detectedConfigs) == 0 {
 		fmt.Println("No applications with configuration files were discovered.")
@@ -124,14 +228,7 @@ func printDiscoveredApps(detectedConfigs []*config.AppConfig, installedApps []ap
 	}
 
 	for _, appConfig := range detectedConfigs {
-		status := "Unknown"
-		if installedApp, exists := installedMap[appConfig.Name]; exists {
-			if installedApp.BundleID != "" {
-				status = "Installed"
-			} else {
-				status = "Detected"
-			}
-		}
+		status := discoveryStatus(installedMap, appConfig.Name)
 
 		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
 			appConfig.Name,
@@ -164,7 +261,7 @@ func showDiscoveryResults(detectedConfigs []*config.AppConfig) error {
 		return nil
 	}
 
-	fmt.Printf("🔍 Discovered %d applications with configuration files:\n\n", len(detectedConfigs))
+	fmt.Printf("Discovered %d applications with configuration files:\n\n", len(detectedConfigs))
 
 	for i, appConfig := range detectedConfigs {
 		fmt.Printf("%d. %s (%s)\n", i+1, appConfig.DisplayName, appConfig.Name)
@@ -191,63 +288,94 @@ func showDiscoveryResults(detectedConfigs []*config.AppConfig) error {
 }
 
 func autoAddDiscoveredApps(detectedConfigs []*config.AppConfig) error {
+	showText := !structuredOutput()
+	report := &autoAddReport{Added: []string{}, Skipped: []string{}, DryRun: dryRun}
+
 	if len(detectedConfigs) == 0 {
+		if !showText {
+			return printStructured(report)
+		}
 		fmt.Println("No applications were discovered for auto-adding.")
 		return nil
 	}
 
 	// Load existing configuration
-	configManager := config.NewManager(homeDir)
+	configManager := newConfigManager()
 	cfg, err := configManager.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %v", err)
 	}
+	if err := refuseLockedStore(cfg, "discover --auto-add"); err != nil {
+		return err
+	}
 
-	added := 0
-	skipped := 0
-
-	fmt.Printf("🚀 Auto-adding %d discovered applications...\n\n", len(detectedConfigs))
+	if showText {
+		fmt.Printf("Auto-adding %d discovered applications...\n\n", len(detectedConfigs))
+	}
 
 	for _, appConfig := range detectedConfigs {
 		// Check if app already exists in configuration
 		if _, exists := cfg.Apps[appConfig.Name]; exists {
-			if verbose {
-				fmt.Printf("⏭️  Skipping %s (already configured)\n", appConfig.DisplayName)
+			if verbose && showText {
+				printer.Info("Skipping %s (already configured)", appConfig.DisplayName)
 			}
-			skipped++
+			report.Skipped = append(report.Skipped, appConfig.Name)
 			continue
 		}
 
 		if dryRun {
-			fmt.Printf("🔍 Would add: %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
-			added++
+			if showText {
+				printer.Info("Would add: %s (%d paths)", appConfig.DisplayName, len(appConfig.Paths))
+			}
+			report.Added = append(report.Added, appConfig.Name)
 			continue
 		}
 
 		// Add the application to configuration
 		cfg.Apps[appConfig.Name] = appConfig
-		fmt.Printf("✅ Added: %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
-		added++
+		if showText {
+			printer.Success("%s", messages.Localize(messages.AppDiscovered, messages.Data{"App": appConfig.DisplayName, "Count": len(appConfig.Paths)}))
+		}
+		report.Added = append(report.Added, appConfig.Name)
 	}
 
+	added := len(report.Added)
+	skipped := len(report.Skipped)
+
 	if !dryRun && added > 0 {
+		addedApps := make(map[string]*config.AppConfig, added)
+		for _, appName := range report.Added {
+			addedApps[appName] = cfg.Apps[appName]
+		}
+		undo := captureUndo(configManager, history.Add, addedApps)
+
 		// Save the updated configuration
 		if err := configManager.Save(cfg); err != nil {
-			return fmt.Errorf("failed to save configuration: %v", err)
+			return messages.Wrap(messages.ConfigSaveFailed, nil, err)
+		}
+		for _, appName := range report.Added {
+			eventEmitter.Emit(events.AppAdded, appName, map[string]interface{}{"source": "discover"})
+			recordHistory(addHistoryEntry(cfg.Apps[appName], "discover", undo))
 		}
+	}
+
+	if !showText {
+		return printStructured(report)
+	}
 
-		fmt.Printf("\n🎉 Successfully added %d applications to your configuration!\n", added)
+	if !dryRun && added > 0 {
+		printer.Success("\n%s", messages.Localize(messages.AppsDiscovered, messages.Data{"Count": added}))
 		if skipped > 0 {
-			fmt.Printf("⏭️  Skipped %d applications (already configured)\n", skipped)
+			printer.Info("Skipped %d applications (already configured)", skipped)
 		}
 
 		fmt.Println("\nNext steps:")
 		fmt.Println("• Run 'configsync sync' to create symlinks for the new applications")
 		fmt.Println("• Run 'configsync status' to check the current sync status")
 	} else if dryRun {
-		fmt.Printf("\n🔍 Dry run complete. Would have added %d applications.\n", added)
+		fmt.Printf("\nDry run complete. Would have added %d applications.\n", added)
 		if skipped > 0 {
-			fmt.Printf("⏭️  Would have skipped %d applications (already configured)\n", skipped)
+			printer.Info("Would have skipped %d applications (already configured)", skipped)
 		}
 	}
 
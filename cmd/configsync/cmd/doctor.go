@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/permissions"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var doctorFixPermissions bool
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that ConfigSync can manage the configured applications",
+	Long: `Check the ConfigSync setup for problems that would make operations fail:
+
+  - The configuration file is missing or invalid
+  - Full Disk Access has not been granted, which macOS requires for reading
+    protected locations such as ~/Library/Safari and ~/Library/Mail
+  - Managed paths that cannot be read
+  - Store copies, and copies restored from them, that belong to another user
+    (such as root after running configsync under sudo), that their owner
+    cannot read, or whose mode differs from the one they had before sync
+
+Each problem is listed with the steps needed to fix it. The command exits with
+an error when any problem is found.
+
+With --fix-permissions, ownership and mode problems are repaired: entries are
+given back to the user who owned the path before it was moved into the store
+(the owner of the home directory for paths synced by older versions), made
+readable by their owner, and store copies get their recorded mode back. Giving
+files back from root needs root, e.g. sudo configsync --home ~ doctor --fix-permissions.
+
+Examples:
+  configsync doctor
+  configsync doctor --json
+  configsync doctor --fix-permissions`,
+	RunE: runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFixPermissions, "fix-permissions", false, "repair the owner and mode of store copies and files restored from them")
+}
+
+// doctorReport is the structured result of the doctor command
+type doctorReport struct {
+	Permissions      *permissions.Report      `json:"permissions" yaml:"permissions"`
+	ConfigPath       string                   `json:"config_path" yaml:"config_path"`
+	ConfigErrors     []string                 `json:"config_errors" yaml:"config_errors"`
+	StorePermissions []*store.PermissionIssue `json:"store_permissions" yaml:"store_permissions"`
+	Healthy          bool                     `json:"healthy" yaml:"healthy"`
+}
+
+// unfixedPermissions returns the store permission problems that remain
+func (r *doctorReport) unfixedPermissions() []*store.PermissionIssue {
+	var unfixed []*store.PermissionIssue
+	for _, issue := range r.StorePermissions {
+		if !issue.Fixed {
+			unfixed = append(unfixed, issue)
+		}
+	}
+	return unfixed
+}
+
+func runDoctor(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	report, err := buildDoctorReport(manager.ConfigPath())
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if err := printStructured(report); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(report)
+	}
+
+	if !report.Healthy {
+		return fmt.Errorf("doctor found %d problem(s)", len(report.ConfigErrors)+len(report.Permissions.Issues)+len(report.unfixedPermissions()))
+	}
+	return nil
+}
+
+// buildDoctorReport validates the configuration and checks access to every managed path
+func buildDoctorReport(configPath string) (*doctorReport, error) {
+	validation, err := validateConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := permissions.NewChecker(homeDir)
+	report := &doctorReport{
+		ConfigPath:       configPath,
+		ConfigErrors:     validation.Errors,
+		Permissions:      &permissions.Report{FullDiskAccess: checker.FullDiskAccess()},
+		StorePermissions: []*store.PermissionIssue{},
+	}
+
+	// A configuration that fails to load has no paths to check; its problems are reported above
+	if cfg, err := newConfigManager().Load(); err == nil {
+		report.Permissions = checker.CheckApps(enabledApps(cfg.Apps))
+		issues, err := store.CheckPermissions(cfg, homeDir, enabledApps(cfg.Apps))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check store permissions: %w", err)
+		}
+		if doctorFixPermissions && !dryRun {
+			store.FixPermissions(issues)
+		}
+		if issues != nil {
+			report.StorePermissions = issues
+		}
+	}
+
+	report.Healthy = len(report.ConfigErrors) == 0 && len(report.Permissions.Issues) == 0 && len(report.unfixedPermissions()) == 0
+	return report, nil
+}
+
+// enabledApps returns the enabled applications of a configuration
+func enabledApps(apps map[string]*config.AppConfig) map[string]*config.AppConfig {
+	enabled := make(map[string]*config.AppConfig)
+	for appName, appConfig := range apps {
+		if appConfig.IsEnabled() {
+			enabled[appName] = appConfig
+		}
+	}
+	return enabled
+}
+
+// printDoctorReport displays the result of the doctor checks
+func printDoctorReport(report *doctorReport) {
+	if len(report.ConfigErrors) == 0 {
+		printer.Success("%s", messages.Localize(messages.ConfigValidAt, messages.Data{"Path": report.ConfigPath}))
+	} else {
+		printer.Failure("%s", messages.Localize(messages.ConfigProblems, messages.Data{"Count": len(report.ConfigErrors), "Path": report.ConfigPath}))
+		for _, problem := range report.ConfigErrors {
+			fmt.Printf("  - %s\n", problem)
+		}
+		fmt.Println("  Run 'configsync config validate' for details")
+	}
+
+	switch report.Permissions.FullDiskAccess {
+	case permissions.AccessGranted:
+		printer.Success("%s", messages.Localize(messages.FullDiskAccessGranted, nil))
+	case permissions.AccessDenied:
+		printer.Warning("%s", messages.Localize(messages.FullDiskAccessMissing, nil))
+	case permissions.AccessUnknown:
+		printer.Warning("%s", messages.Localize(messages.FullDiskAccessUnknown, nil))
+	}
+
+	if len(report.Permissions.Issues) > 0 {
+		printPermissionIssues(report.Permissions)
+	} else if len(report.ConfigErrors) == 0 {
+		printer.Success("%s", messages.Localize(messages.PathsAccessible, nil))
+	}
+
+	printStorePermissions(report)
+
+	if report.Healthy {
+		fmt.Println("\nNo problems found.")
+	}
+}
+
+// printStorePermissions lists the ownership and mode problems of store copies and what was repaired
+func printStorePermissions(report *doctorReport) {
+	if len(report.StorePermissions) == 0 {
+		if len(report.ConfigErrors) == 0 {
+			printer.Success("%s", messages.Localize(messages.PermissionsOk, nil))
+		}
+		return
+	}
+
+	fmt.Println("\nOwnership and Mode Problems:")
+	fmt.Println("============================")
+	for _, issue := range report.StorePermissions {
+		switch {
+		case issue.Fixed:
+			printer.Success("%s", messages.Localize(messages.PermissionFixed, messages.Data{"App": issue.App, "Path": issue.Path, "Problem": issue.Problem}))
+		case issue.FixError != "":
+			printer.Failure("%s", messages.Localize(messages.PermissionFixFailed, messages.Data{"App": issue.App, "Path": issue.Path, "Problem": issue.Problem, "Error": issue.FixError}))
+		case dryRun && doctorFixPermissions:
+			fmt.Printf("[DRY RUN] Would fix %s: %s: %s\n", issue.App, issue.Path, issue.Problem)
+		default:
+			printer.Failure("%s", messages.Localize(messages.PermissionProblem, messages.Data{"App": issue.App, "Path": issue.Path, "Problem": issue.Problem}))
+		}
+	}
+	if len(report.unfixedPermissions()) > 0 && !doctorFixPermissions {
+		fmt.Println("\nRun 'configsync doctor --fix-permissions' to repair them.")
+	}
+}
+
+// printPermissionIssues lists the managed paths configsync cannot read and how to fix access to them
+func printPermissionIssues(report *permissions.Report) {
+	fmt.Println("\nPermission Problems:")
+	fmt.Println("====================")
+	for _, issue := range report.Issues {
+		printer.Failure("%s", messages.Localize(messages.PathInaccessible, messages.Data{"App": issue.App, "Path": issue.Path, "Reason": issue.Reason}))
+	}
+
+	fmt.Println()
+	if report.NeedsFullDiskAccess() {
+		fmt.Println(permissions.Remediation)
+	} else {
+		fmt.Println("Check the ownership and permissions of these paths (e.g. with 'ls -l').")
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var duThreshold string
+
+// duCmd represents the du command
+var duCmd = &cobra.Command{
+	Use:   "du [app...]",
+	Short: "Show how much space managed applications take up",
+	Long: `Show how much space each managed application takes up in the store and in
+its backups, largest first.
+
+Paths whose store copy is larger than the size_warning setting (100 MB by
+default) are listed with a warning: whole profile directories, such as a
+browser profile with its caches, are usually better synced as the few files
+that hold the settings, or with ignore patterns for the caches.
+
+Examples:
+  configsync du
+  configsync du chrome vscode --verbose
+  configsync du --threshold 500MB
+  configsync du --json`,
+	RunE: runDu,
+}
+
+func runDu(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	threshold := cfg.Settings.SizeWarningThreshold()
+	if duThreshold != "" {
+		threshold, err = fsutil.ParseSize(duThreshold)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(args) > 0 {
+		selected := make(map[string]*config.AppConfig, len(args))
+		for _, appName := range args {
+			appConfig, exists := cfg.Apps[appName]
+			if !exists {
+				return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+			}
+			selected[appName] = appConfig
+		}
+		cfg.Apps = selected
+	}
+
+	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose)
+	usages, err := store.MeasureUsage(cfg, threshold, backupManager.AppSize)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printStructured(usages)
+	}
+
+	printUsage(usages, threshold)
+	return nil
+}
+
+// printUsage displays the space each application takes up, followed by warnings about large paths
+func printUsage(usages []*store.AppUsage, threshold int64) {
+	if len(usages) == 0 {
+		fmt.Println(messages.Localize(messages.NoAppsConfigured, nil))
+		return
+	}
+
+	var storeTotal, backupTotal int64
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSTORE\tBACKUPS\tTOTAL")
+	for _, usage := range usages {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", usage.Name, fsutil.FormatSize(usage.StoreSize), fsutil.FormatSize(usage.BackupSize), fsutil.FormatSize(usage.Total))
+		if verbose {
+			for _, path := range usage.Paths {
+				fmt.Fprintf(writer, "  %s\t%s\n", path.Destination, fsutil.FormatSize(path.Size))
+			}
+		}
+		storeTotal += usage.StoreSize
+		backupTotal += usage.BackupSize
+	}
+	fmt.Fprintf(writer, "Total\t%s\t%s\t%s\n", fsutil.FormatSize(storeTotal), fsutil.FormatSize(backupTotal), fsutil.FormatSize(storeTotal+backupTotal))
+	writer.Flush()
+
+	var large []string
+	for _, usage := range usages {
+		for _, path := range usage.LargePaths() {
+			large = append(large, fmt.Sprintf("%s: %s (%s)", usage.Name, path.Source, fsutil.FormatSize(path.Size)))
+		}
+	}
+	if len(large) == 0 {
+		return
+	}
+
+	printer.Warning("\n%s", messages.Localize(messages.LargePaths, messages.Data{"Count": len(large), "Size": fsutil.FormatSize(threshold)}))
+	for _, line := range large {
+		fmt.Printf("  - %s\n", line)
+	}
+	fmt.Println("Consider syncing only the files that hold the settings, or add ignore patterns for caches to the app.")
+}
+
+func init() {
+	duCmd.Flags().StringVar(&duThreshold, "threshold", "", "warn about paths larger than this, e.g. 500MB (default: size_warning setting or 100 MB)")
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// editCmd represents the edit command
+var editCmd = &cobra.Command{
+	Use:   "edit <app>",
+	Short: "Edit an application's configuration in your editor",
+	Long: `Open the configuration of one application in $VISUAL or $EDITOR, and check
+the result when the editor exits before saving it.
+
+The edit is rejected, leaving the configuration unchanged, when it has unknown
+fields, invalid values, or paths that collide with another application's, or
+when a new or changed path exists neither at its source nor in the store,
+which usually means a typo. In a terminal, you are offered to fix the problems
+in the editor again.
+
+Examples:
+  configsync edit vscode
+  EDITOR="code --wait" configsync edit zsh`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEdit,
+}
+
+// editHeader is placed above the application's configuration in the file being edited
+const editHeader = `# Configuration of %s. Save and close the editor to apply it; it is checked first,
+# and nothing is changed if it has problems. Lines starting with # are ignored.
+`
+
+func runEdit(_ *cobra.Command, args []string) error {
+	appName := args[0]
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "edit"); err != nil {
+		return err
+	}
+	original, ok := cfg.Apps[appName]
+	if !ok {
+		return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+	}
+
+	release, err := lockApps(manager, "edit", []string{appName})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	data, err := yaml.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", appName, err)
+	}
+	content := append([]byte(fmt.Sprintf(editHeader, appName)), data...)
+
+	for {
+		edited, err := editInEditor(appName, content)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, content) {
+			fmt.Println("No changes made")
+			return nil
+		}
+
+		app, problems, err := checkEditedApp(cfg, appName, original, edited)
+		if err != nil {
+			problems = []string{fmt.Sprintf("not valid YAML: %v", err)}
+		}
+		if len(problems) == 0 {
+			return saveEditedApp(manager, cfg, appName, app)
+		}
+
+		for _, problem := range problems {
+			printer.Failure("%s", problem)
+		}
+		if !isInteractive() || !promptYesNo("Reopen the editor to fix them?") {
+			return fmt.Errorf("edit rejected with %d problem(s); the configuration was not changed", len(problems))
+		}
+		content = edited
+	}
+}
+
+// editInEditor writes content to a temporary file, opens it in the user's editor, and returns
+// the file as the editor left it
+func editInEditor(appName string, content []byte) ([]byte, error) {
+	file, err := os.CreateTemp("", "configsync-"+appName+"-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the file to edit: %w", err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the file to edit: %w", err)
+	}
+
+	editor := strings.Fields(editorCommand())
+	command := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+
+	return os.ReadFile(file.Name())
+}
+
+// editorCommand returns the user's editor, from $VISUAL or $EDITOR
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// checkEditedApp checks an edited application configuration against the schema and the other
+// applications, and rejects new or changed paths that exist neither at their source nor in the
+// store
+func checkEditedApp(cfg *config.Config, appName string, original *config.AppConfig, edited []byte) (*config.AppConfig, []string, error) {
+	app, problems, err := cfg.CheckAppConfig(appName, edited)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known := make(map[string]bool, len(original.Paths))
+	for _, path := range original.Paths {
+		known[path.Source+"\x00"+path.Destination] = true
+	}
+	host := cfg.Host(config.CurrentHost)
+	for i, path := range app.Paths {
+		if known[path.Source+"\x00"+path.Destination] || path.Source == "" || path.Type == config.PathTypeGlob {
+			continue
+		}
+		source := host.SubstitutePath(path.Source, homeDir)
+		if !fsutil.PathExists(source) && !fsutil.PathExists(filepath.Join(cfg.StorePath, path.Destination)) {
+			problems = append(problems, fmt.Sprintf("apps.%s.paths[%d].source: %s does not exist, and neither does its store copy", appName, i, path.Source))
+		}
+	}
+	return app, problems, nil
+}
+
+// saveEditedApp replaces an application's configuration with the edited one
+func saveEditedApp(manager *config.Manager, cfg *config.Config, appName string, app *config.AppConfig) error {
+	app.Name = appName
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would save the edited configuration of %s\n", appName)
+		return nil
+	}
+
+	cfg.Apps[appName] = app
+	if err := manager.Save(cfg); err != nil {
+		return messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+	printer.Success("%s", messages.Localize(messages.AppConfigSaved, messages.Data{"App": appName}))
+	fmt.Println("Run 'configsync sync' to apply changed paths")
+	return nil
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/spf13/cobra"
+)
+
+var (
+	enableAll     bool
+	disableAll    bool
+	disableUnsync bool
+)
+
+// enableCmd represents the enable command
+var enableCmd = &cobra.Command{
+	Use:   "enable [app1] [app2] ...",
+	Short: "Enable application(s) so they are synced again",
+	Long: `Enable one or more disabled applications so that 'configsync sync'
+includes them again. Run sync afterwards to recreate their symlinks.
+
+Examples:
+  configsync enable vscode
+  configsync enable vscode git
+  configsync enable --all`,
+	RunE: runEnable,
+}
+
+// disableCmd represents the disable command
+var disableCmd = &cobra.Command{
+	Use:   "disable [app1] [app2] ...",
+	Short: "Disable application(s) without removing them",
+	Long: `Disable one or more applications so that 'configsync sync' skips them,
+while keeping their configuration and store contents.
+
+With --unsync, the symlinks of the disabled applications are also removed and
+their configuration files are copied back from the store, as 'configsync remove'
+does, so the applications use local files until they are enabled again.
+
+Examples:
+  configsync disable vscode
+  configsync disable vscode --unsync
+  configsync disable --all`,
+	RunE: runDisable,
+}
+
+func runEnable(_ *cobra.Command, args []string) error {
+	return setAppsEnabled(args, enableAll, true)
+}
+
+func runDisable(_ *cobra.Command, args []string) error {
+	return setAppsEnabled(args, disableAll, false)
+}
+
+// setAppsEnabled enables or disables the named applications, or all of them
+func setAppsEnabled(args []string, all, enabled bool) error {
+	verb := "enable"
+	if !enabled {
+		verb = "disable"
+	}
+
+	if len(args) == 0 && !all {
+		return fmt.Errorf("specify applications to %s or use --all flag", verb)
+	}
+	if len(args) > 0 && all {
+		return fmt.Errorf("cannot combine application names with --all")
+	}
+
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	appNames := args
+	if all {
+		appNames = make([]string, 0, len(cfg.Apps))
+		for appName := range cfg.Apps {
+			appNames = append(appNames, appName)
+		}
+		sort.Strings(appNames)
+	}
+
+	release, err := lockApps(manager, verb, configuredApps(cfg, appNames))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var symlinkManager *symlink.Manager
+	if !enabled && disableUnsync {
+		symlinkManager = symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+		symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+		symlinkManager.SetPlugins(openPlugin)
+		symlinkManager.SetConflictStrategy(cfg.Settings.ConflictStrategy)
+	}
+
+	var changed, unchanged, failed []string
+	for _, appName := range appNames {
+		appConfig, exists := cfg.Apps[appName]
+		if !exists {
+			printer.Failure("%s", messages.Localize(messages.AppNotConfigured, messages.Data{"App": appName}))
+			failed = append(failed, appName)
+			continue
+		}
+
+		var undo string
+		if appConfig.IsEnabled() != enabled {
+			undo = captureAppUndo(manager, verb, appName, appConfig)
+		}
+
+		// Unsync even when the app is already disabled, so --unsync can clean up an app disabled by hand
+		if symlinkManager != nil {
+			if err := symlinkManager.UnsyncApp(appConfig); err != nil {
+				printer.Failure("%s", messages.Localize(messages.AppUnsyncFailed, messages.Data{"App": appConfig.DisplayName, "Error": err}))
+				failed = append(failed, appConfig.DisplayName)
+				continue
+			}
+		}
+
+		if appConfig.IsEnabled() == enabled {
+			unchanged = append(unchanged, appConfig.DisplayName)
+			continue
+		}
+
+		if !dryRun {
+			err := manager.SetAppEnabled(appName, enabled)
+			entry := appHistoryEntry(verb, appName, appConfig, err)
+			entry.Undo = undo
+			recordHistory(entry)
+			if err != nil {
+				failedID := messages.AppEnableFailed
+				if !enabled {
+					failedID = messages.AppDisableFailed
+				}
+				printer.Failure("%s", messages.Localize(failedID, messages.Data{"App": appConfig.DisplayName, "Error": err}))
+				failed = append(failed, appConfig.DisplayName)
+				continue
+			}
+		}
+		changed = append(changed, appConfig.DisplayName)
+	}
+
+	showEnableSummary(verb, changed, unchanged)
+
+	if len(failed) > 0 && len(changed)+len(unchanged) == 0 {
+		return fmt.Errorf("failed to %s any applications", verb)
+	}
+	return nil
+}
+
+// showEnableSummary displays the applications that were enabled or disabled
+func showEnableSummary(verb string, changed, unchanged []string) {
+	if len(changed) > 0 {
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would %s %d application(s):\n", verb, len(changed))
+		} else {
+			changedID := messages.AppsEnabled
+			if verb == "disable" {
+				changedID = messages.AppsDisabled
+			}
+			printer.Success("%s", messages.Localize(changedID, messages.Data{"Count": len(changed)}))
+		}
+		for _, name := range changed {
+			fmt.Printf("  - %s\n", name)
+		}
+	}
+
+	if len(unchanged) > 0 {
+		fmt.Printf("Already %sd: %d application(s)\n", verb, len(unchanged))
+		for _, name := range unchanged {
+			fmt.Printf("  - %s\n", name)
+		}
+	}
+
+	if verb == "enable" && len(changed) > 0 && !dryRun {
+		fmt.Println("\nRun 'configsync sync' to sync the enabled applications.")
+	}
+}
+
+func init() {
+	enableCmd.Flags().BoolVar(&enableAll, "all", false, "enable all configured applications")
+	disableCmd.Flags().BoolVar(&disableAll, "all", false, "disable all configured applications")
+	disableCmd.Flags().BoolVar(&disableUnsync, "unsync", false, "also remove the symlinks of the disabled applications and restore their files")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// defaultRepoDir is where export --layout repo writes without --output
+const defaultRepoDir = "dotfiles"
+
+// bundleOnlyExportFlags describe a bundle, so they do not apply to export --layout repo
+var bundleOnlyExportFlags = []string{
+	"format", "compression", "level", "parent", "sign", "with-brewfile", "description", "tag", "min-version",
+}
+
+// exportRepoResult is the structured result of export --layout repo
+type exportRepoResult struct {
+	Path    string   `json:"path" yaml:"path"`
+	Stow    bool     `json:"stow" yaml:"stow"`
+	Apps    []string `json:"apps" yaml:"apps"`
+	Paths   int      `json:"paths" yaml:"paths"`
+	Skipped []string `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+}
+
+// runExportRepo writes the store as a dotfiles repository for export --layout repo
+func runExportRepo(cmd *cobra.Command, manager *config.Manager, cfg *config.Config) error {
+	for _, name := range bundleOnlyExportFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s applies to bundles, not to --layout %s", name, deploy.LayoutRepo)
+		}
+	}
+
+	repoDir := exportOutput
+	if repoDir == "" {
+		repoDir = defaultRepoDir
+	}
+	repoDir = expandHome(repoDir)
+	if !filepath.IsAbs(repoDir) {
+		cwd, _ := os.Getwd()
+		repoDir = filepath.Join(cwd, repoDir)
+	}
+
+	apps, err := selectAppNames(cfg, exportApps, exportExcept)
+	if err != nil {
+		return err
+	}
+
+	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetContext(runContext)
+	deployManager.SetProgress(progressEmitter)
+	deployManager.SetDryRun(dryRun)
+	deployManager.SetIncludeCaches(exportIncludeCaches)
+	result, err := deployManager.ExportRepo(repoDir, apps, manager, exportStow)
+	if err != nil {
+		return fmt.Errorf("failed to export dotfiles repository: %w", err)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if structuredOutput() {
+		apps := result.Apps
+		if apps == nil {
+			apps = []string{}
+		}
+		return printStructured(&exportRepoResult{
+			Path:    repoDir,
+			Stow:    exportStow,
+			Apps:    apps,
+			Paths:   result.Paths,
+			Skipped: result.Skipped,
+		})
+	}
+
+	printer.Success("\n%s", messages.Localize(messages.RepoExported, messages.Data{"Path": repoDir}))
+	fmt.Printf("  %d path(s) of %d application(s); see %s for where each belongs\n", result.Paths, len(result.Apps), deploy.RepoReadme)
+	if len(result.Skipped) > 0 {
+		fmt.Printf("  %d path(s) left out:\n", len(result.Skipped))
+		for _, skipped := range result.Skipped {
+			fmt.Printf("    %s\n", skipped)
+		}
+	}
+	fmt.Println("\nTo publish it:")
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+		fmt.Printf("  cd %s && git init && git add -A && git commit -m \"Dotfiles\"\n", repoDir)
+	} else {
+		fmt.Printf("  cd %s && git add -A && git commit -m \"Update dotfiles\"\n", repoDir)
+	}
+	if exportStow {
+		fmt.Println("\nTo link an application's files into place on another machine:")
+		fmt.Printf("  stow --dir %s --target ~ <app>\n", repoDir)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var gcYes bool
+
+// gcReport lists the unreferenced store files found, and whether they were deleted
+type gcReport struct {
+	Orphans []store.Orphan `json:"orphans" yaml:"orphans"`
+	Size    int64          `json:"size" yaml:"size"`
+	Removed bool           `json:"removed" yaml:"removed"`
+}
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete store files that no application references",
+	Long: `Find the files and directories in the store that no configured application
+references, such as the store copies of applications removed without
+--purge-store, and delete them.
+
+Disabled applications still reference their store copies. The store's own
+metadata, the directories created by 'configsync init', and cloud conflicted
+copies of referenced files are never deleted; resolve conflicts with
+'configsync store conflicts' instead.
+
+Examples:
+  configsync gc --dry-run  # List unreferenced files without deleting them
+  configsync gc
+  configsync gc --yes --json`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func runGC(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "gc"); err != nil {
+		return err
+	}
+
+	orphans, err := store.FindOrphans(cfg)
+	if err != nil {
+		return err
+	}
+	report := &gcReport{Orphans: orphans, Size: orphansSize(orphans)}
+	if report.Orphans == nil {
+		report.Orphans = []store.Orphan{}
+	}
+
+	if len(orphans) == 0 {
+		if structuredOutput() {
+			return printStructured(report)
+		}
+		printer.Success("%s", messages.Localize(messages.StoreAllReferenced, nil))
+		return nil
+	}
+
+	if !structuredOutput() {
+		if err := printOrphans(orphans); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	if dryRun {
+		if structuredOutput() {
+			return printStructured(report)
+		}
+		fmt.Printf("[DRY RUN] Would delete %d unreferenced item(s) (%s) from the store\n", len(orphans), fsutil.FormatSize(report.Size))
+		return nil
+	}
+
+	if !gcYes {
+		question := fmt.Sprintf("Delete %d unreferenced item(s) (%s) from the store?", len(orphans), fsutil.FormatSize(report.Size))
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("gc", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "delete"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; nothing was deleted")
+			return nil
+		}
+	}
+
+	removed, err := store.RemoveOrphans(manager, orphans)
+	report.Orphans, report.Size, report.Removed = removed, orphansSize(removed), true
+	if report.Orphans == nil {
+		report.Orphans = []store.Orphan{}
+	}
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printStructured(report)
+	}
+	printer.Success("%s", messages.Localize(messages.UnreferencedDeleted, messages.Data{"Count": len(removed), "Size": fsutil.FormatSize(report.Size)}))
+	if skipped := len(orphans) - len(removed); skipped > 0 {
+		fmt.Printf("  %d item(s) were claimed by an application in the meantime and kept\n", skipped)
+	}
+	return nil
+}
+
+// printOrphans lists unreferenced store files as a table
+func printOrphans(orphans []store.Orphan) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PATH\tSIZE")
+	for _, orphan := range orphans {
+		path := orphan.Path
+		if orphan.Dir {
+			path += string(os.PathSeparator)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", path, fsutil.FormatSize(orphan.Size))
+	}
+	return w.Flush()
+}
+
+// orphansSize adds up the space unreferenced store files take up
+func orphansSize(orphans []store.Orphan) int64 {
+	var total int64
+	for _, orphan := range orphans {
+		total += orphan.Size
+	}
+	return total
+}
+
+func init() {
+	gcCmd.Flags().BoolVarP(&gcYes, "yes", "y", false, "delete without asking for confirmation")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// exceptApps removes the applications named by --except, and those in the @groups it names, from apps
+func exceptApps(cfg *config.Config, apps map[string]*config.AppConfig, except []string) error {
+	names, err := cfg.ExpandGroups(except)
+	if err != nil {
+		return err
+	}
+	for _, appName := range names {
+		if _, exists := cfg.Apps[appName]; !exists {
+			return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+		}
+		delete(apps, appName)
+	}
+	return nil
+}
+
+// selectAppNames returns the applications named in args, with @groups replaced by their
+// applications, leaving out those named by except. With neither args nor except it returns nil,
+// which commands take as every application.
+func selectAppNames(cfg *config.Config, args, except []string) ([]string, error) {
+	names, err := cfg.ExpandGroups(args)
+	if err != nil || len(except) == 0 {
+		return names, err
+	}
+
+	selected := make(map[string]*config.AppConfig)
+	if len(names) == 0 {
+		maps.Copy(selected, cfg.Apps)
+	}
+	for _, appName := range names {
+		selected[appName] = cfg.Apps[appName]
+	}
+	if err := exceptApps(cfg, selected, except); err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("--except leaves no applications to work on")
+	}
+	return appNamesOf(selected), nil
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince     string
+	historyUntil     string
+	historyOn        string
+	historyOperation string
+	historyPath      string
+	historyLimit     int
+)
+
+// historyOperations are the operations recorded in the history, in the order they are listed in help
+var historyOperations = []string{
+	history.Add, history.Sync, history.Restore, history.Deploy, history.Remove,
+	history.Enable, history.Disable, history.StoreMove, history.StoreLockdown, history.StoreUnlock,
+	history.PeerSync, history.Upgrade, history.Undo,
+}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [app]",
+	Short: "Show the operations that changed managed configurations",
+	Long: `Show the history of operations that changed managed configurations, newest
+last, optionally for a single application.
+
+Every add, sync, restore, deploy, remove, enable, disable, store move, peer
+sync, and undo is recorded with its time, the user and host that ran it, and the
+paths it affected, including failed attempts. The history is kept in
+~/.configsync/history.jsonl and is only ever appended to; dry runs are not
+recorded.
+
+Times for --since, --until, and --on can be dates (2024-05-14 or
+"2024-05-14 09:30"), ages (90m, 36h, 7d, 2w), today, yesterday, or a weekday,
+which means the most recent such day before today.
+
+Examples:
+  configsync history                      # The last 50 operations
+  configsync history iterm2 --on tuesday  # What changed iTerm2 last Tuesday?
+  configsync history --since 7d --operation restore
+  configsync history --path .gitconfig --limit 0
+  configsync history vscode --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHistory,
+}
+
+func runHistory(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	filter, err := historyFilter(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := history.Open(manager.GetConfigDir()).Read(filter)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		return printStructured(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No matching operations recorded.")
+		return nil
+	}
+	return printHistory(entries)
+}
+
+// historyFilter builds the filter selected by the arguments and flags
+func historyFilter(cfg *config.Config, args []string) (history.Filter, error) {
+	filter := history.Filter{Operation: historyOperation, Path: historyPath, Limit: historyLimit}
+	if len(args) == 1 {
+		filter.App = resolveHistoryApp(cfg, args[0])
+	}
+
+	if filter.Operation != "" && !slices.Contains(historyOperations, filter.Operation) {
+		return filter, fmt.Errorf("invalid operation %q (expected one of: %s)", filter.Operation, strings.Join(historyOperations, ", "))
+	}
+	if historyOn != "" && (historySince != "" || historyUntil != "") {
+		return filter, fmt.Errorf("--on cannot be combined with --since or --until")
+	}
+
+	now := time.Now()
+	var err error
+	if historyOn != "" {
+		if filter.Since, filter.Until, err = history.ParseDay(historyOn, now); err != nil {
+			return filter, err
+		}
+	}
+	if historySince != "" {
+		if filter.Since, err = history.ParseTime(historySince, now); err != nil {
+			return filter, err
+		}
+	}
+	if historyUntil != "" {
+		if filter.Until, err = history.ParseTime(historyUntil, now); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// resolveHistoryApp returns the configured name of an application given by name or display name.
+// Names that match no configured application are kept, so removed applications can be looked up.
+func resolveHistoryApp(cfg *config.Config, name string) string {
+	if _, exists := cfg.Apps[name]; exists {
+		return name
+	}
+	for appName, appConfig := range cfg.Apps {
+		if strings.EqualFold(appConfig.DisplayName, name) {
+			return appName
+		}
+	}
+	return name
+}
+
+// printHistory lists the entries as a table
+func printHistory(entries []history.Entry) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tOPERATION\tAPP\tUSER\tPATHS\tDETAILS")
+	for _, entry := range entries {
+		app := entry.App
+		if app == "" {
+			app = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s@%s\t%s\t%s\n",
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Operation, app,
+			entry.User, entry.Host, historyPaths(entry.Paths), historyDetails(entry))
+	}
+	return w.Flush()
+}
+
+// historyPaths summarizes the affected paths; --verbose lists all of them
+func historyPaths(paths []string) string {
+	switch {
+	case len(paths) == 0:
+		return "-"
+	case len(paths) == 1 || verbose:
+		return strings.Join(paths, ", ")
+	default:
+		return fmt.Sprintf("%s (+%d more)", paths[0], len(paths)-1)
+	}
+}
+
+// historyDetails formats the details of an entry, and its error when the operation failed
+func historyDetails(entry history.Entry) string {
+	keys := make([]string, 0, len(entry.Details))
+	for key := range entry.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		parts = append(parts, key+"="+entry.Details[key])
+	}
+	if entry.Error != "" {
+		parts = append(parts, "failed: "+entry.Error)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordHistory appends entries to the history, warning when they cannot be recorded
+func recordHistory(entries ...history.Entry) {
+	if err := historyJournal.Record(entries...); err != nil {
+		printer.Warning("%v", err)
+	}
+}
+
+// appHistoryEntry describes an operation on an application and its configured paths
+func appHistoryEntry(operation, appName string, appConfig *config.AppConfig, err error) history.Entry {
+	entry := history.Entry{Operation: operation, App: appName}
+	if appConfig != nil {
+		for _, path := range appConfig.Paths {
+			entry.Paths = append(entry.Paths, path.Source)
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// addHistoryEntry describes an application added to the configuration, what added it, and the
+// undo point saved before it was added
+func addHistoryEntry(appConfig *config.AppConfig, source, undo string) history.Entry {
+	entry := appHistoryEntry(history.Add, appConfig.Name, appConfig, nil)
+	entry.Details = map[string]string{"source": source}
+	entry.Undo = undo
+	return entry
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only show operations at or after this time")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "only show operations before this time")
+	historyCmd.Flags().StringVar(&historyOn, "on", "", "only show operations on this day")
+	historyCmd.Flags().StringVar(&historyOperation, "operation", "", "only show this operation ("+strings.Join(historyOperations, ", ")+")")
+	historyCmd.Flags().StringVar(&historyPath, "path", "", "only show operations affecting paths containing this text")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 50, "show at most this many of the most recent operations (0 for all)")
+}
@@ -2,11 +2,19 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
-	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
 	"github.com/spf13/cobra"
 )
 
+var (
+	initStorePath   string
+	initInteractive bool
+)
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -19,7 +27,17 @@ This command creates:
 - Central storage directory (store/)
 - Backup directory (backups/)
 - Log directory (logs/)
-- Initial configuration file (config.yaml)`,
+- Initial configuration file (config.yaml)
+
+Use --store-path to keep the store somewhere else, such as a folder synced by
+iCloud Drive or Dropbox so your configurations follow you between Macs:
+
+  configsync init --store-path "~/Library/Mobile Documents/com~apple~CloudDocs/configsync"
+
+Use --interactive for a guided setup: it asks where to keep the store (locally,
+in iCloud Drive, in a git repository, or in another folder) and how long to keep
+backups, lets you pick applications from those discovered on this machine, and
+offers to sync them right away.`,
 	RunE: runInit,
 }
 
@@ -29,19 +47,38 @@ func runInit(_ *cobra.Command, _ []string) error {
 	}
 
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if already initialized
 	if manager.ConfigExists() {
 		return fmt.Errorf("ConfigSync is already initialized in %s", configDir)
 	}
 
+	if initStorePath != "" {
+		storePath, err := resolveStorePath(initStorePath)
+		if err != nil {
+			return err
+		}
+		manager.SetStorePath(storePath)
+		initStorePath = storePath
+	}
+
+	if initInteractive {
+		return runInitWizard(manager)
+	}
+
 	// Initialize the configuration
 	if err := manager.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize ConfigSync: %w", err)
 	}
 
-	fmt.Printf("✓ ConfigSync initialized successfully in %s\n", configDir)
+	printer.Success("%s", messages.Localize(messages.Initialized, messages.Data{"Path": configDir}))
+	if initStorePath != "" {
+		fmt.Printf("  Store: %s\n", initStorePath)
+		if provider := store.CloudProvider(initStorePath); provider != "" {
+			fmt.Printf("  The store is synced by %s; conflicted copies are reported by 'configsync status'\n", provider)
+		}
+	}
 	fmt.Println()
 	fmt.Println("Next steps:")
 	fmt.Println("  1. Add applications: configsync add <app>")
@@ -54,6 +91,19 @@ func runInit(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// resolveStorePath expands ~ and makes a store path given on the command line absolute
+func resolveStorePath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve store path: %w", err)
+	}
+	return absPath, nil
+}
+
 func init() {
-	// No additional flags needed for init command
+	initCmd.Flags().StringVar(&initStorePath, "store-path", "", "location of the central store (e.g. a folder synced by iCloud Drive or Dropbox)")
+	initCmd.Flags().BoolVar(&initInteractive, "interactive", false, "set up step by step: store location, backup retention, applications, and a first sync")
 }
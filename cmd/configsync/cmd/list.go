@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// Sort keys accepted by list --sort
+const (
+	listSortName       = "name"
+	listSortPaths      = "paths"
+	listSortLastSynced = "last-synced"
+)
+
+var (
+	listEnabledOnly bool
+	listSortBy      string
+	listFilter      string
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List managed applications",
+	Long: `List the applications managed by ConfigSync with their number of paths,
+whether they are enabled, and when they were last synced.
+
+Examples:
+  configsync list
+  configsync list --enabled-only
+  configsync list --sort last-synced
+  configsync list --filter code
+  configsync list --output json`,
+	Args: cobra.NoArgs,
+	RunE: runList,
+}
+
+// listedApp is one row of the list command
+type listedApp struct {
+	LastSynced  *time.Time `json:"last_synced,omitempty" yaml:"last_synced,omitempty"`
+	Name        string     `json:"name" yaml:"name"`
+	DisplayName string     `json:"display_name" yaml:"display_name"`
+	Version     string     `json:"version,omitempty" yaml:"version,omitempty"`
+	Bundle      string     `json:"deployed_bundle,omitempty" yaml:"deployed_bundle,omitempty"` // Hash of the bundle the app was deployed from
+	Paths       int        `json:"paths" yaml:"paths"`
+	Enabled     bool       `json:"enabled" yaml:"enabled"`
+}
+
+func runList(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	listed, err := buildAppList(cfg.Apps, listFilter, listSortBy, listEnabledOnly)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printStructured(listed)
+	}
+
+	printAppList(listed)
+	return nil
+}
+
+// buildAppList selects and orders the applications shown by the list command
+func buildAppList(apps map[string]*config.AppConfig, filter, sortBy string, enabledOnly bool) ([]listedApp, error) {
+	filter = strings.ToLower(filter)
+	listed := []listedApp{}
+
+	for name, appConfig := range apps {
+		if enabledOnly && !appConfig.Enabled {
+			continue
+		}
+		if filter != "" &&
+			!strings.Contains(strings.ToLower(name), filter) &&
+			!strings.Contains(strings.ToLower(appConfig.DisplayName), filter) {
+			continue
+		}
+
+		app := listedApp{
+			Name:        name,
+			DisplayName: appConfig.DisplayName,
+			Version:     appConfig.Metadata[config.MetadataAppVersion],
+			Bundle:      appConfig.Metadata[config.MetadataDeployedBundle],
+			Paths:       len(appConfig.Paths),
+			Enabled:     appConfig.Enabled,
+		}
+		if !appConfig.LastSynced.IsZero() {
+			lastSynced := appConfig.LastSynced
+			app.LastSynced = &lastSynced
+		}
+		listed = append(listed, app)
+	}
+
+	var less func(a, b listedApp) bool
+	switch sortBy {
+	case "", listSortName:
+		less = func(a, b listedApp) bool { return a.Name < b.Name }
+	case listSortPaths:
+		less = func(a, b listedApp) bool {
+			if a.Paths != b.Paths {
+				return a.Paths > b.Paths
+			}
+			return a.Name < b.Name
+		}
+	case listSortLastSynced:
+		// Most recently synced first; never-synced applications last
+		less = func(a, b listedApp) bool {
+			switch {
+			case a.LastSynced == nil || b.LastSynced == nil:
+				if (a.LastSynced == nil) != (b.LastSynced == nil) {
+					return b.LastSynced == nil
+				}
+			case !a.LastSynced.Equal(*b.LastSynced):
+				return a.LastSynced.After(*b.LastSynced)
+			}
+			return a.Name < b.Name
+		}
+	default:
+		return nil, fmt.Errorf("invalid sort key %q (expected name, paths, or last-synced)", sortBy)
+	}
+
+	sort.Slice(listed, func(i, j int) bool { return less(listed[i], listed[j]) })
+	return listed, nil
+}
+
+// printAppList displays the listed applications as a table
+func printAppList(listed []listedApp) {
+	if len(listed) == 0 {
+		fmt.Println("No applications match. Use 'configsync add <app>' to add applications.")
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tDISPLAY NAME\tVERSION\tPATHS\tENABLED\tLAST SYNCED\tDEPLOYED FROM")
+	for _, app := range listed {
+		lastSynced := "never"
+		if app.LastSynced != nil {
+			lastSynced = app.LastSynced.Format("2006-01-02 15:04")
+		}
+		enabled := "no"
+		if app.Enabled {
+			enabled = "yes"
+		}
+		version := app.Version
+		if version == "" {
+			version = "-"
+		}
+		bundle := "-"
+		if app.Bundle != "" {
+			bundle = deploy.ShortHash(app.Bundle)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", app.Name, app.DisplayName, version, app.Paths, enabled, lastSynced, bundle)
+	}
+	writer.Flush()
+}
+
+func init() {
+	listCmd.Flags().BoolVar(&listEnabledOnly, "enabled-only", false, "only list enabled applications")
+	listCmd.Flags().StringVar(&listSortBy, "sort", listSortName, "sort by name, paths, or last-synced")
+	listCmd.Flags().StringVar(&listFilter, "filter", "", "only list applications whose name contains this text")
+}
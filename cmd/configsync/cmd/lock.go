@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/lock"
+)
+
+// lockApps locks applications for an operation, first waiting for other configsync processes
+// working on them, such as a scheduled sync, to finish. Dry runs change nothing and take no locks.
+func lockApps(manager *config.Manager, operation string, apps []string) (func(), error) {
+	if dryRun || len(apps) == 0 {
+		return func() {}, nil
+	}
+
+	release, err := lock.NewManager(manager.GetConfigDir()).Acquire(operation, apps)
+	var held *lock.HeldError
+	if errors.As(err, &held) {
+		return nil, fmt.Errorf("%w; try again once it has finished", err)
+	}
+	return release, err
+}
+
+// appNamesOf returns the names of the applications in name order
+func appNamesOf(apps map[string]*config.AppConfig) []string {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredApps returns the named applications that are configured, or all of them when none are
+// named, in name order
+func configuredApps(cfg *config.Config, names []string) []string {
+	var apps []string
+	for appName := range cfg.Apps {
+		if len(names) == 0 || slices.Contains(names, appName) {
+			apps = append(apps, appName)
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFromStow    string
+	migrateFromChezmoi string
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Import an existing dotfiles repository",
+	Long: `Import the files of a dotfiles repository managed by another tool, creating
+an application for each group of files and copying them into the store.
+
+With --from-stow, every top-level directory of a GNU Stow repository becomes an
+application named after the package; names starting with dot- are read as
+starting with a dot, as 'stow --dotfiles' does.
+
+With --from-chezmoi, a chezmoi source directory (by default
+~/.local/share/chezmoi; see 'chezmoi source-path') is read, translating names such as
+private_dot_ssh and dot_gitconfig to their targets. Each top-level target
+becomes an application. Templates, scripts, and encrypted files are listed and
+skipped, since they only take shape when chezmoi applies them.
+
+Files in shared directories such as ~/.config and ~/Library/Application Support
+are grouped by tool. Applications that are already configured, or whose paths
+collide with another application's, are skipped. Run 'configsync sync'
+afterwards to replace the files in your home directory with symlinks to the
+store.
+
+Examples:
+  configsync migrate --from-stow ~/dotfiles
+  configsync migrate --from-chezmoi ~/.local/share/chezmoi
+  configsync migrate --from-chezmoi ~/.local/share/chezmoi --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func runMigrate(_ *cobra.Command, _ []string) error {
+	if (migrateFromStow == "") == (migrateFromChezmoi == "") {
+		return fmt.Errorf("specify exactly one of --from-stow or --from-chezmoi")
+	}
+
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "migrate"); err != nil {
+		return err
+	}
+
+	var result *migrate.Result
+	if migrateFromChezmoi != "" {
+		fmt.Printf("Reading chezmoi source directory %s...\n", migrateFromChezmoi)
+		result, err = migrate.ReadChezmoi(expandHome(migrateFromChezmoi))
+	} else {
+		fmt.Printf("Reading stow directory %s...\n", migrateFromStow)
+		result, err = migrate.ReadStow(expandHome(migrateFromStow))
+	}
+	if err != nil {
+		return err
+	}
+
+	imported, skipped := importPackages(manager, cfg, result.Packages)
+	showMigrateSummary(imported, skipped, result.Skipped)
+
+	if len(imported) == 0 && len(result.Packages) > 0 {
+		return fmt.Errorf("failed to import any applications")
+	}
+	return nil
+}
+
+// importPackages adds an application for each package and copies its files into the store,
+// returning the names of the imported packages and a description of each skipped one
+func importPackages(manager *config.Manager, cfg *config.Config, packages []migrate.Package) ([]string, []string) {
+	var imported, skipped []string
+	for i := range packages {
+		pkg := &packages[i]
+		appConfig := pkg.AppConfig(homeDir)
+
+		if _, exists := cfg.Apps[pkg.Name]; exists {
+			skipped = append(skipped, fmt.Sprintf("%s: already configured", pkg.Name))
+			continue
+		}
+
+		targets := make([]string, len(pkg.Entries))
+		for j, entry := range pkg.Entries {
+			targets[j] = entry.Target
+		}
+
+		if dryRun {
+			if err := manager.CheckCollisions(appConfig); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s: %v", pkg.Name, err))
+				continue
+			}
+			fmt.Printf("[DRY RUN] Would import %s: %s\n", pkg.Name, strings.Join(targets, ", "))
+			imported = append(imported, pkg.Name)
+			continue
+		}
+
+		// Register the app first so a collision is caught before anything is copied into the store
+		undo := captureAppUndo(manager, history.Add, pkg.Name, appConfig)
+		if err := manager.AddApp(appConfig); err != nil {
+			var collision *config.CollisionError
+			if !errors.As(err, &collision) {
+				err = fmt.Errorf("failed to add application: %w", err)
+			}
+			skipped = append(skipped, fmt.Sprintf("%s: %v", pkg.Name, err))
+			continue
+		}
+
+		if err := pkg.Import(cfg.StorePath); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", pkg.Name, err))
+			if err := manager.RemoveApp(pkg.Name); err != nil {
+				printer.Warning("%s", messages.Localize(messages.ImportCleanupFailed, messages.Data{"App": pkg.Name, "Error": err}))
+			}
+			continue
+		}
+
+		printer.Success("%s", messages.Localize(messages.PackageImported, messages.Data{"App": pkg.Name, "Paths": strings.Join(targets, ", ")}))
+		eventEmitter.Emit(events.AppAdded, pkg.Name, map[string]interface{}{"source": "migrate"})
+		recordHistory(addHistoryEntry(appConfig, "migrate", undo))
+		imported = append(imported, pkg.Name)
+	}
+	return imported, skipped
+}
+
+// showMigrateSummary displays the imported applications and everything that was left out
+func showMigrateSummary(imported, skipped, skippedEntries []string) {
+	if len(skipped) > 0 {
+		printer.Failure("\n%s", messages.Localize(messages.AppsSkipped, messages.Data{"Count": len(skipped)}))
+		for _, reason := range skipped {
+			fmt.Printf("  - %s\n", reason)
+		}
+	}
+
+	if len(skippedEntries) > 0 {
+		fmt.Printf("\nSkipped %d repository entr(ies) that cannot be imported:\n", len(skippedEntries))
+		for _, reason := range skippedEntries {
+			fmt.Printf("  - %s\n", reason)
+		}
+	}
+
+	if len(imported) > 0 && !dryRun {
+		printer.Success("\n%s", messages.Localize(messages.AppsImported, messages.Data{"Count": len(imported)}))
+	}
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateFromStow, "from-stow", "", "import a GNU Stow repository")
+	migrateCmd.Flags().StringVar(&migrateFromChezmoi, "from-chezmoi", "", "import a chezmoi source directory")
+}
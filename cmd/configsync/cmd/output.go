@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dotbrains/configsync/internal/output"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var (
+	outputFormat string
+	outputAsJSON bool
+	quiet        bool
+	colorMode    = output.ColorAuto
+	noColor      bool
+
+	// printer prints successes, failures, and warnings as selected by --quiet and --color
+	printer = output.New(os.Stdout, os.Stderr, false, false)
+)
+
+// resolveOutputFormat validates --output and applies the --json shorthand
+func resolveOutputFormat() error {
+	if outputAsJSON {
+		outputFormat = outputJSON
+	}
+
+	switch outputFormat {
+	case "", outputTable:
+		outputFormat = outputTable
+	case outputJSON, outputYAML:
+	default:
+		return fmt.Errorf("invalid output format %q (expected json, yaml, or table)", outputFormat)
+	}
+
+	return nil
+}
+
+// setupOutput applies --quiet, --color, and --no-color. In quiet mode everything but failures,
+// warnings, prompts, and structured results is discarded.
+func setupOutput() error {
+	if noColor {
+		colorMode = output.ColorNever
+	}
+	if err := output.CheckColorMode(colorMode); err != nil {
+		return err
+	}
+	printer = output.New(os.Stdout, os.Stderr, quiet, output.ColorEnabled(colorMode, os.Stdout))
+
+	if quiet && !structuredOutput() && !progressJSON {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		os.Stdout = devNull
+	}
+	return nil
+}
+
+// structuredOutput reports whether results should be printed as JSON or YAML instead of text
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// printStructured writes a result to stdout in the selected structured format
+func printStructured(result interface{}) error {
+	switch outputFormat {
+	case outputYAML:
+		data, err := yaml.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	default:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		return nil
+	}
+}
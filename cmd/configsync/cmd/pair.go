@@ -0,0 +1,391 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/peer"
+	"github.com/spf13/cobra"
+)
+
+// discoverTimeout is how long pair --discover listens for peers
+const discoverTimeout = 3 * time.Second
+
+var (
+	pairListen   string
+	pairCode     string
+	pairName     string
+	pairForget   string
+	pairDiscover bool
+	pairList     bool
+)
+
+// pairCmd represents the pair command
+var pairCmd = &cobra.Command{
+	Use:   "pair [host]",
+	Short: "Pair with another Mac to sync stores directly over the local network",
+	Long: `Pair two Macs so 'configsync sync --peer' can exchange their stores directly,
+without a cloud service or bundles.
+
+Without a host, this Mac waits for peers: it advertises itself with Bonjour,
+shows a one-time pairing code, and answers pairing and sync requests until it
+is interrupted. On the other Mac, run 'configsync pair <host>' with this Mac's
+name or address and enter the code. Afterwards either Mac can sync with the
+other while it is waiting for peers.
+
+The Macs identify each other by certificates exchanged while pairing, and all
+traffic is encrypted. A pairing code works once, and pairing is disabled after
+five wrong codes.
+
+Examples:
+  configsync pair                       # Wait for peers and show a pairing code
+  configsync pair --discover            # List Macs waiting for peers on the network
+  configsync pair studio.local          # Pair with a waiting Mac
+  configsync pair studio.local --code 123456
+  configsync pair --list                # List paired Macs
+  configsync pair --forget studio       # Forget a paired Mac`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPair,
+}
+
+func runPair(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "pair"); err != nil {
+		return err
+	}
+
+	peerDir := filepath.Join(manager.GetConfigDir(), peer.DirName)
+	registry, err := peer.LoadRegistry(peerDir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case pairList:
+		return printPeers(registry)
+	case pairForget != "":
+		if !registry.Remove(pairForget) {
+			return fmt.Errorf("%s is not paired", pairForget)
+		}
+		if err := registry.Save(); err != nil {
+			return err
+		}
+		printer.Success("%s", messages.Localize(messages.PeerForgotten, messages.Data{"Name": pairForget}))
+		return nil
+	case pairDiscover:
+		return discoverPeers(registry)
+	}
+
+	identity, err := peer.LoadOrCreateIdentity(peerDir)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return pairWith(identity, registry, args[0])
+	}
+	if dryRun {
+		return fmt.Errorf("waiting for peers does not support --dry-run")
+	}
+	return waitForPeers(manager, cfg, identity, registry)
+}
+
+// pairWith pairs with a Mac waiting for peers
+func pairWith(identity *peer.Identity, registry *peer.Registry, host string) error {
+	code := pairCode
+	if code == "" {
+		code = promptLine("Pairing code shown on the other Mac: ")
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if code == "" {
+		return fmt.Errorf("a pairing code is required; pass --code when not running in a terminal")
+	}
+
+	paired, err := peer.Pair(identity, host, peerName(), code)
+	if err != nil {
+		return fmt.Errorf("failed to pair with %s: %w", host, err)
+	}
+	registry.Add(*paired)
+	if err := registry.Save(); err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.PeerPaired, messages.Data{"Name": paired.Name, "Address": paired.Address}))
+	fmt.Printf("Run 'configsync sync --peer %s' to sync the stores\n", paired.Name)
+	return nil
+}
+
+// waitForPeers advertises this Mac and answers pairing and sync requests until interrupted
+func waitForPeers(manager *config.Manager, cfg *config.Config, identity *peer.Identity, registry *peer.Registry) error {
+	code, err := newPairingCode()
+	if err != nil {
+		return err
+	}
+
+	name := peerName()
+	server := peer.NewServer(identity, registry, name, cfg.StorePath, cfg.BackupPath)
+	server.SetPairingCode(code)
+	server.SetLocker(func() (func(), error) {
+		return lockApps(manager, "peer sync", configuredApps(cfg, nil))
+	})
+	server.SetReceived(func(path, sender string) {
+		if err := recordPeerChecksums(cfg.StorePath, []string{path}); err != nil {
+			printer.Warning("%s", messages.Localize(messages.ChecksumsRecordFailed, messages.Data{"Error": err}))
+		}
+		recordHistory(history.Entry{Operation: history.PeerSync, Paths: []string{path}, Details: map[string]string{"peer": sender}})
+	})
+
+	listener, err := net.Listen("tcp", pairListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", pairListen, err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if stopAdvertising, err := peer.Advertise(name, port); err != nil {
+		printer.Warning("%v", err)
+	} else {
+		defer stopAdvertising()
+	}
+
+	host, _ := os.Hostname()
+	fmt.Printf("Waiting for peers as %s on port %d (press Ctrl-C to stop)\n", name, port)
+	fmt.Printf("\nPairing code: %s %s\n", code[:3], code[3:])
+	fmt.Printf("On the other Mac, run: configsync pair %s\n\n", net.JoinHostPort(host, fmt.Sprint(port)))
+
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	err = serveUntilInterrupted(httpServer, func() error {
+		return httpServer.Serve(tls.NewListener(listener, server.TLSConfig()))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serve peers: %w", err)
+	}
+	return nil
+}
+
+// runPeerSync exchanges the store with a paired Mac
+func runPeerSync(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("--peer syncs the whole store; application names cannot be given")
+	}
+
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "sync --peer"); err != nil {
+		return err
+	}
+
+	peerDir := filepath.Join(manager.GetConfigDir(), peer.DirName)
+	registry, err := peer.LoadRegistry(peerDir)
+	if err != nil {
+		return err
+	}
+	identity, err := peer.LoadOrCreateIdentity(peerDir)
+	if err != nil {
+		return err
+	}
+
+	address := syncPeer
+	if paired := registry.Find(syncPeer); paired != nil && paired.Address != "" {
+		address = paired.Address
+	} else if len(registry.Peers) == 0 {
+		return fmt.Errorf("no Macs are paired; run 'configsync pair' on the other Mac and 'configsync pair <host>' here first")
+	}
+
+	release, err := lockApps(manager, "peer sync", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	client := peer.Dial(identity, registry, address)
+	result, err := peer.Sync(client, cfg.StorePath, cfg.BackupPath, dryRun)
+	if result != nil && !dryRun && len(result.Received) > 0 {
+		if err := recordPeerChecksums(cfg.StorePath, result.Received); err != nil {
+			printer.Warning("%s", messages.Localize(messages.ChecksumsRecordFailed, messages.Data{"Error": err}))
+		}
+	}
+	recordPeerSync(result, err)
+	if err != nil {
+		return fmt.Errorf("failed to sync with %s: %w", syncPeer, err)
+	}
+
+	if paired := registry.Trusted(client.Fingerprint()); paired != nil && !dryRun {
+		paired.LastSync = time.Now()
+		if err := registry.Save(); err != nil {
+			printer.Warning("%v", err)
+		}
+	}
+
+	if structuredOutput() {
+		return printStructured(result)
+	}
+	printPeerSyncResult(result)
+	return nil
+}
+
+// recordPeerSync adds the files received from a peer to the history; files sent are recorded by the peer
+func recordPeerSync(result *peer.Result, err error) {
+	entry := history.Entry{Operation: history.PeerSync, Details: map[string]string{"peer": syncPeer}}
+	if result != nil {
+		entry.Paths = result.Received
+		entry.Details["sent"] = strconv.Itoa(len(result.Sent))
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if len(entry.Paths) > 0 || entry.Error != "" {
+		recordHistory(entry)
+	}
+}
+
+// printPeerSyncResult lists the files exchanged with a peer
+func printPeerSyncResult(result *peer.Result) {
+	if len(result.Received)+len(result.Sent) == 0 {
+		printer.Success("%s", messages.Localize(messages.StoresMatch, nil))
+		return
+	}
+
+	received, sent := "✓ Received", "✓ Sent"
+	if dryRun {
+		received, sent = "[DRY RUN] Would receive", "[DRY RUN] Would send"
+	}
+	for _, path := range result.Received {
+		fmt.Printf("%s %s\n", received, path)
+	}
+	for _, path := range result.Sent {
+		fmt.Printf("%s %s\n", sent, path)
+	}
+	fmt.Printf("\n%d file(s) received, %d sent\n", len(result.Received), len(result.Sent))
+}
+
+// printPeers lists the paired Macs
+func printPeers(registry *peer.Registry) error {
+	if structuredOutput() {
+		peers := registry.Peers
+		if peers == nil {
+			peers = []peer.Peer{}
+		}
+		return printStructured(peers)
+	}
+	if len(registry.Peers) == 0 {
+		fmt.Println("No Macs are paired. Run 'configsync pair' to pair with one.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tADDRESS\tPAIRED\tLAST SYNC")
+	for _, paired := range registry.Peers {
+		address, lastSync := paired.Address, "never"
+		if address == "" {
+			address = "-"
+		}
+		if !paired.LastSync.IsZero() {
+			lastSync = paired.LastSync.Format("2006-01-02 15:04")
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", paired.Name, address, paired.PairedAt.Format("2006-01-02"), lastSync)
+	}
+	return w.Flush()
+}
+
+// discoverPeers lists the Macs waiting for peers on the network
+func discoverPeers(registry *peer.Registry) error {
+	services, err := peer.Browse(discoverTimeout)
+	if err != nil {
+		return err
+	}
+	if structuredOutput() {
+		if services == nil {
+			services = []peer.Service{}
+		}
+		return printStructured(services)
+	}
+	if len(services) == 0 {
+		fmt.Println("No Macs are waiting for peers. Run 'configsync pair' on the other Mac first.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tADDRESS\tPAIRED")
+	for _, service := range services {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%t\n", service.Name, service.Address, registry.Find(service.Name) != nil)
+	}
+	return w.Flush()
+}
+
+// peerName is the name this Mac gives itself when pairing
+func peerName() string {
+	if pairName != "" {
+		return pairName
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return "configsync"
+	}
+	return strings.TrimSuffix(host, ".local")
+}
+
+// newPairingCode returns a random six-digit code
+func newPairingCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pairing code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// recordPeerChecksums records the content of store files received from a peer, so
+// 'configsync status --verify' does not report them as changed outside of configsync
+func recordPeerChecksums(storePath string, paths []string) error {
+	checksums, err := manifest.LoadChecksums(storePath)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := checksums.Record(path); err != nil {
+			return err
+		}
+	}
+	return checksums.Save()
+}
+
+func init() {
+	pairCmd.Flags().StringVar(&pairListen, "listen", fmt.Sprintf(":%d", peer.DefaultPort), "address to listen on while waiting for peers")
+	pairCmd.Flags().StringVar(&pairCode, "code", "", "pairing code shown on the other Mac")
+	pairCmd.Flags().StringVar(&pairName, "name", "", "name this Mac gives itself (default: its host name)")
+	pairCmd.Flags().BoolVar(&pairDiscover, "discover", false, "list the Macs waiting for peers on the network")
+	pairCmd.Flags().BoolVar(&pairList, "list", false, "list the paired Macs")
+	pairCmd.Flags().StringVar(&pairForget, "forget", "", "forget a paired Mac")
+	pairCmd.MarkFlagsMutuallyExclusive("discover", "list", "forget")
+}
@@ -1,25 +1,68 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/dotbrains/configsync/internal/backup"
 	"github.com/dotbrains/configsync/internal/config"
 	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/events"
 	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/merge"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/tarball"
 	"github.com/spf13/cobra"
 )
 
 var (
-	backupKeepDays int
-	backupValidate bool
-	restoreAll     bool
-	exportOutput   string
-	exportApps     []string
-	importForce    bool
-	deployForce    bool
+	backupKeepDays      int
+	backupValidate      bool
+	backupList          bool
+	backupIncludeCaches bool
+	backupCompression   string
+	backupExcept        []string
+	restoreAll          bool
+	restoreVersion      string
+	restoreForce        bool
+	restorePaths        []string
+	restoreInteractive  bool
+	exportOutput        string
+	exportFormat        string
+	exportCompression   string
+	exportLevel         int
+	exportApps          []string
+	exportExcept        []string
+	exportParent        string
+	exportSignKey       string
+	exportBrewfile      bool
+	exportIncludeCaches bool
+	exportDescription   string
+	exportTags          []string
+	exportMinVersion    string
+	exportLayout        string
+	exportStow          bool
+	importForce         bool
+	importVerify        string
+	importLayer         string
+	importSHA256        string
+	importProxy         string
+	deployForce         bool
+	deployApps          []string
+	deploySkip          []string
+	deployInteractive   bool
+	deployPreferLocal   bool
+	deployPreferBundle  bool
+	deployInstall       bool
+	deployKeepImport    bool
+	deployLayers        bool
+	deployTrustHooks    bool
 )
 
 // backupCmd represents the backup command
@@ -28,38 +71,73 @@ var backupCmd = &cobra.Command{
 	Short: "Create and manage backups of configurations",
 	Long: `Create backups of original configurations before symlinking.
 
-If no app names are provided, all managed applications will be backed up.
+If no app names are provided, all managed applications will be backed up. A
+name starting with @ stands for the applications of a group, and --except
+leaves applications or groups out.
 
 Examples:
   configsync backup              # Backup all apps
   configsync backup vscode       # Backup only VS Code
+  configsync backup @editors     # Backup the apps in the editors group
   configsync backup --validate   # Validate existing backups
-  configsync backup --cleanup --keep-days 30  # Clean old backups`,
+  configsync backup --list       # Show the version history of each path
+  configsync backup --include-caches  # Also back up cache and log directories
+  configsync backup --compression zstd  # Keep each path as a compressed archive
+  configsync backup --cleanup --keep-days 30  # Clean old backups
+
+Every backup is kept as a separate timestamped version. Use
+'configsync restore --version <timestamp>' to restore an earlier one.
+
+With --compression or the backup_compression setting, each backed up path is
+written as a single gzip or zstd tar archive instead of a copy. Compressed and
+plain backups are restored and validated alike.`,
 	RunE: runBackup,
 }
 
 func runBackup(cmd *cobra.Command, args []string) error {
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if ConfigSync is initialized
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	// Load configuration
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if args, err = selectAppNames(cfg, args, backupExcept); err != nil {
+		return err
+	}
+
+	compression := cfg.Settings.BackupCompression
+	if cmd.Flags().Changed("compression") {
+		if err := tarball.CheckLevel(backupCompression, 0); err != nil {
+			return err
+		}
+		compression = backupCompression
 	}
 
 	// Create backup manager
-	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose)
+	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose).WithContext(runContext).WithCompression(compression)
+
+	if backupList {
+		return listBackupVersions(backupManager, args, cfg)
+	}
 
 	if backupValidate {
 		return validateBackups(backupManager, args, cfg)
 	}
 
+	release, err := lockApps(manager, "backup", configuredApps(cfg, args))
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	if cmd.Flags().Changed("keep-days") {
 		return cleanupBackups(backupManager, args, cfg)
 	}
@@ -74,12 +152,13 @@ func createBackups(backupManager *backup.Manager, args []string, cfg *config.Con
 	}
 
 	if len(appsToBackup) == 0 {
-		fmt.Println("No applications configured. Use 'configsync add <app>' to add applications.")
+		fmt.Println(messages.Localize(messages.NoAppsConfigured, nil))
 		return nil
 	}
 
 	successful, failed := performBackups(backupManager, appsToBackup)
 	showBackupResults(successful, failed)
+	pruneBackups(backupManager, cfg, appsToBackup)
 
 	return nil
 }
@@ -95,7 +174,7 @@ func selectAppsToBackup(cfg *config.Config, args []string) (map[string]*config.A
 		if app, exists := cfg.Apps[appName]; exists {
 			appsToBackup[appName] = app
 		} else {
-			return nil, fmt.Errorf("application %s is not configured", appName)
+			return nil, messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
 		}
 	}
 	return appsToBackup, nil
@@ -105,30 +184,39 @@ func selectAppsToBackup(cfg *config.Config, args []string) (map[string]*config.A
 func performBackups(backupManager *backup.Manager, appsToBackup map[string]*config.AppConfig) ([]string, []string) {
 	var successful, failed []string
 
+	progressEmitter.Start("backup", len(appsToBackup))
 	for appName, appConfig := range appsToBackup {
 		if verbose {
 			fmt.Printf("\n=== %s ===\n", appConfig.DisplayName)
 		}
 
 		pathErrors := 0
+		var lastErr error
 		for _, path := range appConfig.Paths {
-			if err := backupManager.BackupPath(appName, &path); err != nil {
+			ignored, err := appConfig.IgnoreMatcher(expandHome(path.Source), backupIncludeCaches)
+			if err == nil {
+				err = backupManager.BackupPathIgnoring(appName, &path, ignored)
+			}
+			if err != nil {
 				if verbose {
-					fmt.Printf("  ✗ Failed to backup %s: %v\n", path.Source, err)
+					printer.Failure("  %s", messages.Localize(messages.PathBackupFailed, messages.Data{"Path": path.Source, "Error": err}))
 				}
 				pathErrors++
+				lastErr = err
 			}
 		}
+		progressEmitter.App("backup", appName, len(successful)+len(failed)+1, len(appsToBackup), lastErr)
 
 		if pathErrors == 0 {
 			successful = append(successful, appConfig.DisplayName)
 			if verbose {
-				fmt.Printf("✓ Backed up %s\n", appConfig.DisplayName)
+				printer.Success("%s", messages.Localize(messages.AppBackedUp, messages.Data{"App": appConfig.DisplayName}))
 			}
 		} else {
 			failed = append(failed, appConfig.DisplayName)
 		}
 	}
+	progressEmitter.Finish("backup", len(successful), len(failed))
 
 	return successful, failed
 }
@@ -137,71 +225,172 @@ func performBackups(backupManager *backup.Manager, appsToBackup map[string]*conf
 func showBackupResults(successful, failed []string) {
 	fmt.Println()
 	if len(successful) > 0 {
-		fmt.Printf("✓ Successfully backed up %d application(s):\n", len(successful))
+		printer.Success("%s", messages.Localize(messages.AppsBackedUp, messages.Data{"Count": len(successful)}))
 		for _, name := range successful {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
 	if len(failed) > 0 {
-		fmt.Printf("\n✗ Failed to backup %d application(s):\n", len(failed))
+		printer.Failure("\n%s", messages.Localize(messages.AppsBackupFailed, messages.Data{"Count": len(failed)}))
 		for _, name := range failed {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 }
 
+// backupValidationReport is the structured result of backup --validate
+type backupValidationReport struct {
+	Backups []backupValidation `json:"backups" yaml:"backups"`
+	Valid   int                `json:"valid" yaml:"valid"`
+	Invalid int                `json:"invalid" yaml:"invalid"`
+	Total   int                `json:"total" yaml:"total"`
+}
+
+// backupValidation is the validation result of one backup
+type backupValidation struct {
+	App          string `json:"app" yaml:"app"`
+	OriginalPath string `json:"original_path" yaml:"original_path"`
+	Version      string `json:"version,omitempty" yaml:"version,omitempty"`
+	Error        string `json:"error,omitempty" yaml:"error,omitempty"`
+	Valid        bool   `json:"valid" yaml:"valid"`
+}
+
 func validateBackups(backupManager *backup.Manager, args []string, cfg *config.Config) error {
+	showText := !structuredOutput()
+	report := &backupValidationReport{Backups: []backupValidation{}}
+
 	if len(args) == 0 {
 		// Validate all apps
 		for appName := range cfg.Apps {
 			args = append(args, appName)
 		}
+		sort.Strings(args)
 	}
 
 	if len(args) == 0 {
+		if !showText {
+			return printStructured(report)
+		}
 		fmt.Println("No applications to validate.")
 		return nil
 	}
 
-	var totalBackups int
-	var validBackups int
-	var invalidBackups int
-
 	for _, appName := range args {
 		backups, err := backupManager.ListBackups(appName)
 		if err != nil {
+			if !showText {
+				return fmt.Errorf("failed to list backups for %s: %w", appName, err)
+			}
 			fmt.Printf("Error listing backups for %s: %v\n", appName, err)
 			continue
 		}
 
 		if len(backups) == 0 {
-			if verbose {
+			if verbose && showText {
 				fmt.Printf("%s: No backups found\n", appName)
 			}
 			continue
 		}
 
 		for _, backup := range backups {
-			totalBackups++
+			result := backupValidation{
+				App:          appName,
+				OriginalPath: backup.OriginalPath,
+				Version:      backup.Version,
+				Valid:        true,
+			}
+
 			if err := backupManager.ValidateBackup(backup); err != nil {
-				fmt.Printf("✗ %s: %s - %v\n", appName, filepath.Base(backup.OriginalPath), err)
-				invalidBackups++
+				result.Valid = false
+				result.Error = err.Error()
+				report.Invalid++
+				if showText {
+					printer.Failure("%s", messages.Localize(messages.BackupInvalid, messages.Data{"App": appName, "Path": filepath.Base(backup.OriginalPath), "Error": err}))
+				}
 			} else {
-				if verbose {
-					fmt.Printf("✓ %s: %s\n", appName, filepath.Base(backup.OriginalPath))
+				report.Valid++
+				if verbose && showText {
+					printer.Success("%s", messages.Localize(messages.BackupValid, messages.Data{"App": appName, "Path": filepath.Base(backup.OriginalPath)}))
 				}
-				validBackups++
 			}
+
+			report.Total++
+			report.Backups = append(report.Backups, result)
 		}
 	}
 
+	if !showText {
+		return printStructured(report)
+	}
+
 	fmt.Printf("\nBackup validation complete: %d valid, %d invalid (total: %d)\n",
-		validBackups, invalidBackups, totalBackups)
+		report.Valid, report.Invalid, report.Total)
+
+	return nil
+}
+
+// listBackupVersions shows the backup version history of each path, newest first
+func listBackupVersions(backupManager *backup.Manager, args []string, cfg *config.Config) error {
+	appNames := args
+	if len(appNames) == 0 {
+		for appName := range cfg.Apps {
+			appNames = append(appNames, appName)
+		}
+		sort.Strings(appNames)
+	}
+
+	found := false
+	for _, appName := range appNames {
+		appConfig, exists := cfg.Apps[appName]
+		if !exists {
+			return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+		}
+
+		headerShown := false
+		for _, path := range appConfig.Paths {
+			versions, err := backupManager.ListVersions(appName, &path)
+			if err != nil {
+				return fmt.Errorf("failed to list backups for %s: %w", appName, err)
+			}
+			if len(versions) == 0 {
+				continue
+			}
+
+			if !headerShown {
+				fmt.Printf("\n%s (%s):\n", appConfig.DisplayName, appName)
+				headerShown = true
+			}
+			found = true
+
+			fmt.Printf("  %s\n", path.Source)
+			notes := versionNotes(versions)
+			for i, version := range versions {
+				fmt.Printf("    %s  %s%s\n", version.Version, fsutil.FormatSize(version.Size), notes[i])
+			}
+		}
+	}
+
+	if !found {
+		fmt.Println("No backups found.")
+	}
 
 	return nil
 }
 
+// pruneBackups removes the backups of applications older than the backup_retention_days
+// setting, when it is set
+func pruneBackups(backupManager *backup.Manager, cfg *config.Config, apps map[string]*config.AppConfig) {
+	if cfg.Settings.BackupRetention <= 0 || dryRun {
+		return
+	}
+	for appName := range apps {
+		if err := backupManager.CleanupBackups(appName, cfg.Settings.BackupRetention); err != nil {
+			printer.Warning("%s", messages.Localize(messages.BackupPruneFailed, messages.Data{"App": appName, "Error": err}))
+		}
+	}
+}
+
 func cleanupBackups(backupManager *backup.Manager, args []string, cfg *config.Config) error {
 	if len(args) == 0 {
 		// Cleanup all apps
@@ -233,16 +422,51 @@ var restoreCmd = &cobra.Command{
 Examples:
   configsync restore vscode      # Restore VS Code from backup
   configsync restore git ssh     # Restore multiple apps
-  configsync restore --all       # Restore all backed up configurations`,
+  configsync restore --all       # Restore all backed up configurations
+  configsync restore vscode --version 20240115-093000.000  # Restore a specific version
+  configsync restore vscode --version 20240115             # Newest backup from that day
+  configsync restore vscode --path keybindings.json        # Restore only the keybindings
+  configsync restore vscode --interactive                  # Pick the backup of each path
+
+Without --version, the most recent backup of each path is restored. Use
+'configsync backup --list' to see the available versions.
+
+--path restores only the paths whose source or store destination contains the
+given text, and may be repeated. --interactive lists the backups of each path,
+with when they were taken and their size, and asks which one to restore.
+
+Each backup is validated before anything is overwritten, and one whose size,
+checksum, or archive does not match is refused unless --force is given. The
+current state of each path is backed up first, marked as taken before a restore,
+so a restore can be undone with --version; such backups are never picked as the
+latest.`,
 	RunE: runRestore,
 }
 
 func runRestore(_ *cobra.Command, args []string) error {
 	// Initialize and load configuration
-	_, cfg, backupManager, err := initializeRestoreComponents()
+	manager, cfg, backupManager, err := initializeRestoreComponents()
+	if err != nil {
+		return err
+	}
+	if err := refuseLockedStore(cfg, "restore"); err != nil {
+		return err
+	}
+
+	if restoreInteractive {
+		if restoreVersion != "" {
+			return fmt.Errorf("--interactive cannot be combined with --version")
+		}
+		if !isInteractive() {
+			return fmt.Errorf("--interactive requires a terminal")
+		}
+	}
+
+	release, err := lockApps(manager, "restore", configuredApps(cfg, args))
 	if err != nil {
 		return err
 	}
+	defer release()
 
 	// Determine applications to restore
 	appsToRestore, err := determineAppsToRestore(args, cfg, backupManager)
@@ -257,6 +481,11 @@ func runRestore(_ *cobra.Command, args []string) error {
 
 	// Restore applications and show results
 	successful, failed := restoreApplications(appsToRestore, cfg, backupManager)
+	restored := make(map[string]*config.AppConfig, len(appsToRestore))
+	for _, appName := range appsToRestore {
+		restored[appName] = cfg.Apps[appName]
+	}
+	flushPreferences(cfg, restored)
 	showRestoreResults(successful, failed)
 
 	return nil
@@ -271,32 +500,140 @@ var exportCmd = &cobra.Command{
 Examples:
   configsync export                           # Export all apps to default location
   configsync export --output my-config.tar.gz # Export to specific file
-  configsync export --apps vscode,git        # Export specific apps only`,
+  configsync export --format zip             # Export a zip archive
+  configsync export --format dir --output ~/src/dotfiles-bundle  # Write the bundle into a directory
+  configsync export --apps vscode,git        # Export specific apps only
+  configsync export --apps @editors --except nvim  # Export a group but one app
+  configsync export --parent baseline.tar.gz # Record changes relative to another bundle
+  configsync export --json                   # Describe the exported bundle as JSON
+  configsync export --result-format yaml     # ... or as YAML
+  configsync export --dry-run                # Show which files would be bundled
+  configsync export --sign ~/.configsync/keys/bundle.key  # Sign the bundle
+  configsync export --with-brewfile          # Also record the Homebrew packages of the apps
+  configsync export --include-caches         # Keep cache and log directories in the bundle
+  configsync export --description "Engineering baseline" --tag eng --min-version 1.4.0
+  configsync export --layout repo --output ~/dotfiles  # Write a dotfiles repository to publish
+  configsync export --layout repo --stow --output ~/dotfiles  # ... that GNU Stow can link into place
+
+Each bundle records its lineage (parent bundle hash, machine, and configsync
+version) and the apps and paths changed since its parent. The parent is the
+last bundle exported or imported on this machine unless --parent is given.
+Use 'configsync bundle log' to view the history.
+
+Bundles also record a description, tags, the oldest configsync version that
+may import them (--min-version), their number of apps, and the size of their
+files. Use 'configsync bundle inspect' to view them without extracting the bundle.
+
+Bundles are gzip-compressed tar archives unless --format is given: zip archives
+open on any computer without extra tools, and dir writes the bundle's files
+directly into a directory, such as a git-managed folder. Hidden entries like .git
+in that directory are kept when the bundle is replaced. Without --format, an
+--output ending in .zip produces a zip archive.
+
+Archives are written straight from the store without a temporary copy, so very
+large stores export with little extra disk space. --compression selects none,
+gzip, or zstd (tar only), and --level trades speed for size. Without
+--compression, an --output ending in .tar is left uncompressed and one ending in
+.zst uses zstd. The number of files and the compression ratio are reported
+after the export.
+
+With --with-brewfile, the installed Homebrew casks and formulae that provide
+the bundled apps are recorded in the bundle and written to a Brewfile, so
+'configsync deploy --install-missing' can install the apps on another Mac.
+
+With --layout repo, the store is written as a conventional dotfiles repository
+instead of a bundle (default: ./dotfiles): a folder per application holding its
+files, and a README listing where each belongs, ready to commit and publish on
+GitHub. With --stow, each folder mirrors the home directory so GNU Stow can link
+it into place; paths outside the home directory and settings exported by a
+tool, such as macOS defaults, are then listed in the README but left out.
+Secrets are never written. Hidden entries like .git are kept when a previous
+export is replaced. The bundle-only flags, such as --format and --sign, do not
+apply to repositories.`,
 	RunE: runExport,
 }
 
-func runExport(_ *cobra.Command, _ []string) error {
+// checkExportOutput refuses a result format given as the bundle path: on export, --output names
+// the bundle, so results are selected with --result-format or --json instead
+func checkExportOutput(path string) error {
+	switch path {
+	case outputTable, outputJSON, outputYAML:
+		return fmt.Errorf("--output is the bundle path for export; use --result-format %s to select the result format", path)
+	}
+	return nil
+}
+
+func runExport(cmd *cobra.Command, _ []string) error {
+	if err := checkExportOutput(exportOutput); err != nil {
+		return err
+	}
+
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if ConfigSync is initialized
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	// Load configuration
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	switch exportLayout {
+	case deploy.LayoutBundle:
+		if exportStow {
+			return fmt.Errorf("--stow needs --layout %s", deploy.LayoutRepo)
+		}
+	case deploy.LayoutRepo:
+		return runExportRepo(cmd, manager, cfg)
+	default:
+		return fmt.Errorf("unsupported export layout: %s (use %s or %s)", exportLayout, deploy.LayoutBundle, deploy.LayoutRepo)
 	}
 
 	// Create deploy manager
 	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetContext(runContext)
+	deployManager.SetProgress(progressEmitter)
+	deployManager.SetVersion(version)
+	deployManager.SetDryRun(dryRun)
+	deployManager.SetBrewfile(exportBrewfile)
+	deployManager.SetIncludeCaches(exportIncludeCaches)
+	deployManager.SetFormat(exportFormat)
+	deployManager.SetCompression(exportCompression, exportLevel)
+	deployManager.SetDescription(exportDescription, exportTags)
+	if err := deployManager.SetMinVersion(exportMinVersion); err != nil {
+		return err
+	}
+	if exportParent != "" {
+		deployManager.SetParentBundle(exportParent)
+	}
+	if exportSignKey != "" {
+		signingKey, err := deploy.LoadPrivateKey(exportSignKey)
+		if err != nil {
+			return err
+		}
+		deployManager.SetSigningKey(signingKey)
+	}
 
-	// Determine output file
+	// Determine output file and format
+	if exportFormat != "" {
+		if err := deploy.CheckFormat(exportFormat); err != nil {
+			return err
+		}
+	}
 	outputFile := exportOutput
 	if outputFile == "" {
-		outputFile = "configsync-bundle.tar.gz"
+		outputFile = deploy.DefaultBundlePath(exportFormat, exportCompression)
+	}
+	format := exportFormat
+	if format == "" {
+		format = deploy.FormatFromPath(outputFile)
+	}
+	if err := deploy.CheckCompression(format, exportCompression, exportLevel); err != nil {
+		return err
 	}
 
 	// Convert output to absolute path
@@ -306,11 +643,28 @@ func runExport(_ *cobra.Command, _ []string) error {
 	}
 
 	// Create bundle
-	if err := deployManager.ExportBundle(outputFile, exportApps, manager); err != nil {
+	apps, err := selectAppNames(cfg, exportApps, exportExcept)
+	if err != nil {
+		return err
+	}
+	if err := deployManager.ExportBundle(outputFile, apps, manager); err != nil {
 		return fmt.Errorf("failed to export bundle: %w", err)
 	}
 
-	fmt.Printf("\n✓ Configuration bundle exported to: %s\n", outputFile)
+	if dryRun {
+		return nil
+	}
+
+	stats := deployManager.ExportStats()
+	if structuredOutput() {
+		return printExportResult(outputFile, stats)
+	}
+
+	printer.Success("\n%s", messages.Localize(messages.BundleExported, messages.Data{"Path": outputFile}))
+	if stats.Compression != "" {
+		fmt.Printf("  %d files, %s compressed with %s to %s (%.0f%%)\n", stats.Files,
+			fsutil.FormatSize(stats.Size), stats.Compression, fsutil.FormatSize(stats.ArchiveSize), stats.Ratio()*100)
+	}
 	fmt.Println("\nTo import on another Mac:")
 	fmt.Printf("  configsync import %s\n", filepath.Base(outputFile))
 	fmt.Printf("  configsync deploy\n")
@@ -318,6 +672,65 @@ func runExport(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// exportResult is the structured result of the export command
+type exportResult struct {
+	BundlePath string   `json:"bundle_path" yaml:"bundle_path"`
+	Hash       string   `json:"hash" yaml:"hash"`
+	ParentHash string   `json:"parent_hash,omitempty" yaml:"parent_hash,omitempty"`
+	Apps       []string `json:"apps" yaml:"apps"`
+	Size       int64    `json:"size" yaml:"size"`
+
+	// Compression stats, left out for dir bundles
+	Compression string  `json:"compression,omitempty" yaml:"compression,omitempty"`
+	Files       int     `json:"files,omitempty" yaml:"files,omitempty"`
+	ContentSize int64   `json:"content_size,omitempty" yaml:"content_size,omitempty"`
+	Ratio       float64 `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+}
+
+// printExportResult describes an exported bundle in the selected structured format
+func printExportResult(bundlePath string, stats deploy.ExportStats) error {
+	result, err := buildExportResult(bundlePath, stats)
+	if err != nil {
+		return err
+	}
+	return printStructured(result)
+}
+
+// buildExportResult describes an exported bundle and how well it compressed
+func buildExportResult(bundlePath string, stats deploy.ExportStats) (*exportResult, error) {
+	bundle, hash, err := deploy.ReadBundleArchive(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := fsutil.Size(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &exportResult{
+		BundlePath: bundlePath,
+		Hash:       hash,
+		Apps:       []string{},
+		Size:       size,
+	}
+	for appName := range bundle.Apps {
+		result.Apps = append(result.Apps, appName)
+	}
+	sort.Strings(result.Apps)
+	if bundle.Provenance != nil {
+		result.ParentHash = bundle.Provenance.ParentHash
+	}
+	if stats.Compression != "" {
+		result.Compression = stats.Compression
+		result.Files = stats.Files
+		result.ContentSize = stats.Size
+		result.Ratio = stats.Ratio()
+	}
+
+	return result, nil
+}
+
 // importCmd represents the import command
 var importCmd = &cobra.Command{
 	Use:   "import <bundle.tar.gz>",
@@ -327,55 +740,181 @@ var importCmd = &cobra.Command{
 This extracts and validates the bundle but doesn't deploy it yet.
 Use 'configsync deploy' to apply the imported configurations.
 
+The bundle may be a tar.gz or zip archive, or a directory written by
+'configsync export --format dir'; the format is detected automatically.
+
 Examples:
   configsync import my-bundle.tar.gz
-  configsync import --force bundle.tar.gz   # Force import even with conflicts`,
+  configsync import my-bundle.zip
+  configsync import ~/src/dotfiles-bundle    # Import a directory bundle
+  configsync import --force bundle.tar.gz   # Force import even with conflicts
+  configsync import --dry-run bundle.tar.gz # Validate the bundle without importing it
+  configsync import --verify bundle.key.pub bundle.tar.gz  # Require a valid signature
+  configsync import --layer baseline team.tar.gz   # Import the team's baseline layer
+  configsync import --layer overlay personal.tar.gz  # Import your personal overlay layer
+  configsync import https://example.com/bundles/eng-baseline.tar.gz --sha256 <digest>
+
+A bundle given as an HTTP or HTTPS URL is downloaded first, through the proxy in
+HTTPS_PROXY or --proxy if set. An interrupted download is kept in
+~/.configsync/downloads and resumed by the next import of the same URL. With
+--sha256, the bundle is rejected unless its SHA-256 digest matches, which pins
+the exact archive that was reviewed; local archives can be pinned the same way.
+Plain HTTP URLs are only downloaded with --sha256.
+
+With --layer, the bundle is imported as the baseline or overlay layer of a
+layered deployment instead, replacing the layer imported before. Layers are kept
+after they are deployed with 'configsync deploy --layers', so a new baseline can
+be deployed later without losing the overlay.
+
+Every bundle records the SHA256 hash of each file it contains, and import rejects
+bundles whose files were corrupted or changed after export.`,
 	RunE: runImport,
 	Args: cobra.ExactArgs(1),
 }
 
 func runImport(_ *cobra.Command, args []string) error {
-	bundlePath := args[0]
+	bundleSource := args[0]
+	bundlePath := bundleSource
 
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if ConfigSync is initialized
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	// Load configuration
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
 	// Create deploy manager
 	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetContext(runContext)
+	deployManager.SetProgress(progressEmitter)
+	deployManager.SetVersion(version)
+	deployManager.SetDryRun(dryRun)
+	if importVerify != "" {
+		verifyKey, err := deploy.LoadPublicKey(importVerify)
+		if err != nil {
+			return err
+		}
+		deployManager.SetVerifyKey(verifyKey)
+	}
+
+	if deploy.IsRemoteBundle(bundleSource) {
+		downloaded, cleanup, err := downloadImportBundle(bundleSource)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		bundlePath = downloaded
+	} else if importSHA256 != "" {
+		if err := deploy.VerifyChecksum(bundlePath, importSHA256); err != nil {
+			return err
+		}
+	}
 
 	// Create import directory
 	importDir := filepath.Join(configDir, "import")
-	if rmErr := os.RemoveAll(importDir); rmErr != nil && !os.IsNotExist(rmErr) {
-		return fmt.Errorf("failed to clean import directory: %w", rmErr)
+	if importLayer != "" {
+		if err := deploy.CheckLayer(importLayer); err != nil {
+			return err
+		}
+		importDir = deploy.LayerDir(configDir, importLayer)
+	}
+	if !dryRun {
+		if rmErr := os.RemoveAll(importDir); rmErr != nil && !os.IsNotExist(rmErr) {
+			return fmt.Errorf("failed to clean import directory: %w", rmErr)
+		}
 	}
 
 	// Import bundle
 	bundle, err := deployManager.ImportBundle(bundlePath, importDir)
 	if err != nil {
+		// Never leave a rejected bundle behind for 'configsync deploy' to pick up
+		if !dryRun {
+			_ = os.RemoveAll(importDir)
+		}
 		return fmt.Errorf("failed to import bundle: %w", err)
 	}
 
-	fmt.Printf("\n✓ Bundle imported successfully\n")
+	if dryRun {
+		showImportPlan(bundle, importDir)
+		return nil
+	}
+
+	// The imported bundle becomes the parent of bundles exported from this machine
+	if err := deployManager.RecordParentBundle(manager.GetConfigDir(), bundle, bundlePath); err != nil {
+		printer.Warning("%s", messages.Localize(messages.BundleLineageFailed, messages.Data{"Error": err}))
+	}
+	// Without the hash, deploy identifies the bundle by its metadata instead
+	bundleHash, _ := deploy.HashBundle(bundlePath)
+	if err := deployManager.RecordImport(importDir, bundleSource, bundleHash, bundle); err != nil {
+		printer.Warning("%v", err)
+	}
+
+	printer.Success("\n%s", messages.Localize(messages.BundleImported, nil))
 	fmt.Printf("  Created: %s by %s\n", bundle.CreatedAt.Format("2006-01-02 15:04"), bundle.CreatedBy)
 	fmt.Printf("  Platform: %s\n", bundle.Metadata["platform"])
 	fmt.Printf("  Applications: %d\n", len(bundle.Apps))
 
+	if importLayer != "" {
+		fmt.Printf("  Layer: %s\n", importLayer)
+		fmt.Println("\nNext step: Run 'configsync deploy --layers' to apply the layered configurations")
+		return nil
+	}
 	fmt.Println("\nNext step: Run 'configsync deploy' to apply these configurations")
 
 	return nil
 }
 
+// downloadImportBundle downloads a bundle given to import as a URL, verifying it against
+// --sha256 when given. Interrupted downloads are resumed by the next import of the same URL.
+func downloadImportBundle(bundleURL string) (string, func(), error) {
+	client, err := deploy.NewHTTPClient(importProxy)
+	if err != nil {
+		return "", nil, err
+	}
+	fmt.Printf("Downloading %s\n", bundleURL)
+	bundlePath, cleanup, err := deploy.DownloadBundle(runContext, client, bundleURL, deploy.DownloadOptions{
+		SHA256:     importSHA256,
+		PartialDir: filepath.Join(configDir, deploy.DownloadsDir),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if importSHA256 != "" {
+		printer.Success("%s", messages.Localize(messages.ChecksumVerified, nil))
+	} else {
+		printer.Warning("%s", messages.Localize(messages.BundleNotPinned, nil))
+	}
+	return bundlePath, cleanup, nil
+}
+
+// showImportPlan describes a validated bundle that a dry-run import would have imported
+func showImportPlan(bundle *config.DeploymentBundle, importDir string) {
+	fmt.Printf("[DRY RUN] Bundle is valid; would replace %s\n", importDir)
+	fmt.Printf("  Created: %s by %s\n", bundle.CreatedAt.Format("2006-01-02 15:04"), bundle.CreatedBy)
+	fmt.Printf("  Applications: %d\n", len(bundle.Apps))
+
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	for _, appName := range appNames {
+		appConfig := bundle.Apps[appName]
+		if version := appConfig.Metadata[config.MetadataAppVersion]; version != "" {
+			fmt.Printf("    - %s %s (%d paths)\n", appConfig.DisplayName, version, len(appConfig.Paths))
+		} else {
+			fmt.Printf("    - %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
+		}
+	}
+}
+
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
 	Use:   "deploy",
@@ -385,72 +924,264 @@ var deployCmd = &cobra.Command{
 This command applies the configurations that were imported with 'configsync import'.
 Use --force to override any conflicts with existing configurations.
 
+When a file was changed both locally and in the bundle since the last deploy,
+the changes are merged: JSON, YAML, and plist files key by key, other text
+files line by line. Settings changed differently on both sides fail the
+application's deployment unless --prefer-local or --prefer-bundle decides
+which side wins; --force alone prefers the bundle.
+
+Deploy is safe to re-run: applications already deployed from the imported bundle
+whose files are unchanged are skipped, and applications that failed are retried.
+Once every application in the bundle has been deployed, the imported copy is
+removed unless --keep-import is given; 'configsync clean' removes it otherwise.
+
+With --install-missing, the Homebrew packages recorded by 'export --with-brewfile'
+for the selected apps are installed with brew before their configurations are
+deployed. Packages that are already installed are left alone.
+
+The post_sync commands of bundle apps run in a shell after every sync, so deploy
+shows the ones that differ from this machine's and keeps the local commands
+instead. Review them and deploy again with --trust-hooks to adopt them.
+
+With --layers, the bundles imported with 'import --layer' are deployed instead:
+the team's baseline with a personal overlay on top. Applications in both take
+their settings from the overlay and keep the paths only the baseline has, and
+files in both are merged key by key (line by line for text files), with the
+overlay winning where they differ. The layers each application came from are
+recorded and shown by 'configsync bundle layers'. Importing a new baseline and
+deploying again keeps the overlay's customizations and local changes.
+
 Examples:
   configsync deploy              # Deploy imported configurations
-  configsync deploy --force      # Force deploy even with conflicts`,
+  configsync deploy --force      # Force deploy even with conflicts
+  configsync deploy --prefer-local     # Merge, keeping local values where both sides changed
+  configsync deploy --prefer-bundle    # Merge, taking bundle values where both sides changed
+  configsync deploy --dry-run    # Show files to copy, configs to add or overwrite, and conflicts
+  configsync deploy --apps vscode,git  # Deploy only some apps from the bundle
+  configsync deploy --skip iterm2      # Deploy everything except some apps
+  configsync deploy --interactive      # Choose apps from the bundle contents
+  configsync deploy --install-missing  # Install the apps with Homebrew first
+  configsync deploy --layers           # Deploy the baseline with the overlay on top
+  configsync deploy --trust-hooks      # Adopt the bundle's post-sync commands`,
 	RunE: runDeploy,
 }
 
 func runDeploy(_ *cobra.Command, _ []string) error {
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if ConfigSync is initialized
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	// Load configuration
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
-	// Check if import directory exists
-	importDir := filepath.Join(configDir, "import")
-	if !fsutil.PathExists(importDir) {
-		return fmt.Errorf("no imported bundle found. Run 'configsync import <bundle>' first")
-	}
-
-	// Load bundle metadata
-	bundleFile := filepath.Join(importDir, "bundle.yaml")
-	if !fsutil.PathExists(bundleFile) {
-		return fmt.Errorf("invalid import directory. Run 'configsync import <bundle>' first")
+	if err := refuseLockedStore(cfg, "deploy"); err != nil {
+		return err
 	}
 
 	// Load bundle metadata directly from imported bundle
 	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetContext(runContext)
+	deployManager.SetProgress(progressEmitter)
+	deployManager.SetDryRun(dryRun)
+	deployManager.SetMergePolicy(deployMergePolicy())
+	deployManager.SetAutoBackup(cfg.Settings.AutoBackup)
+	deployManager.SetInstallMissing(deployInstall)
+	deployManager.SetTrustHooks(deployTrustHooks)
+	deployManager.SetHistory(historyJournal)
+	deployManager.SetUndo(func(apps map[string]*config.AppConfig) string {
+		return captureUndo(manager, history.Deploy, apps)
+	})
+
+	var (
+		bundle     *config.DeploymentBundle
+		importDir  string
+		provenance *deploy.LayerProvenance
+	)
+	if deployLayers {
+		bundle, importDir, provenance, err = deployManager.ComposeLayers(configDir)
+	} else {
+		bundle, importDir, err = loadImportedBundle(deployManager)
+	}
+	if err != nil {
+		return err
+	}
 
-	// Load the bundle metadata from the already imported bundle
-	bundle, err := deployManager.LoadBundleMetadata(bundleFile)
+	bundle, err = selectDeployApps(bundle, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to load imported bundle: %w", err)
+		return err
+	}
+	if len(bundle.Apps) == 0 {
+		fmt.Println("No applications selected for deployment")
+		return nil
+	}
+
+	bundleApps := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		bundleApps = append(bundleApps, appName)
+	}
+	release, err := lockApps(manager, "deploy", bundleApps)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	// Deploy bundle
 	if err := deployManager.DeployBundle(bundle, importDir, manager, deployForce); err != nil {
 		return fmt.Errorf("deployment failed: %w", err)
 	}
+	flushPreferences(cfg, bundle.Apps)
+
+	// Layers are kept so a new baseline can be deployed with the same overlay
+	if deployLayers {
+		if !dryRun {
+			if err := deploy.RecordLayerProvenance(configDir, provenance, bundle.Apps); err != nil {
+				printer.Warning("%v", err)
+			}
+		}
+		showLayerProvenance(provenance, bundle)
+		return nil
+	}
+
+	// The imported bundle is no longer needed once all of it is deployed
+	if !dryRun && !deployKeepImport {
+		removed, err := deployManager.RemoveDeployedImport(importDir)
+		if err != nil {
+			printer.Warning("%v", err)
+		} else if removed {
+			fmt.Println("\nEvery application in the bundle is deployed; removed the imported copy")
+		}
+	}
 
 	return nil
 }
 
+// loadImportedBundle loads the bundle waiting in the import directory, and returns it with the directory
+func loadImportedBundle(deployManager *deploy.Manager) (*config.DeploymentBundle, string, error) {
+	importDir := filepath.Join(configDir, "import")
+	if !fsutil.PathExists(importDir) {
+		return nil, "", fmt.Errorf("no imported bundle found. Run 'configsync import <bundle>' first")
+	}
+
+	bundleFile := filepath.Join(importDir, "bundle.yaml")
+	if !fsutil.PathExists(bundleFile) {
+		return nil, "", fmt.Errorf("invalid import directory. Run 'configsync import <bundle>' first")
+	}
+
+	bundle, err := deployManager.LoadBundleMetadata(bundleFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load imported bundle: %w", err)
+	}
+	return bundle, importDir, nil
+}
+
+// showLayerProvenance lists the layers each deployed application came from
+func showLayerProvenance(provenance *deploy.LayerProvenance, bundle *config.DeploymentBundle) {
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	fmt.Println("\nLayers:")
+	for _, appName := range appNames {
+		appLayers := provenance.Apps[appName]
+		if appLayers == nil {
+			continue
+		}
+		line := fmt.Sprintf("  %s: %s", appName, strings.Join(appLayers.Layers, " + "))
+		if len(appLayers.Overridden) > 0 {
+			line += fmt.Sprintf(" (%d file(s) overridden)", len(appLayers.Overridden))
+		}
+		fmt.Println(line)
+	}
+}
+
+// deployMergePolicy returns the merge policy chosen with --prefer-local or --prefer-bundle
+func deployMergePolicy() merge.Policy {
+	switch {
+	case deployPreferLocal:
+		return merge.PolicyPreferLocal
+	case deployPreferBundle:
+		return merge.PolicyPreferIncoming
+	default:
+		return merge.PolicyReport
+	}
+}
+
+// selectDeployApps limits a bundle to the applications chosen with --apps, --skip, and --interactive,
+// leaving out those the hosts section keeps off this host
+func selectDeployApps(bundle *config.DeploymentBundle, cfg *config.Config) (*config.DeploymentBundle, error) {
+	skip := append([]string{}, deploySkip...)
+	if skipped := deploy.SkippedOnHost(bundle, deploy.HostOverride(bundle, cfg, config.CurrentHost)); len(skipped) > 0 {
+		fmt.Printf("Skipping %s: not used on host %s\n", strings.Join(skipped, ", "), config.CurrentHost)
+		skip = append(skip, skipped...)
+	}
+
+	selected, err := deploy.SelectApps(bundle, deployApps, skip)
+	if err != nil {
+		return nil, err
+	}
+	if !deployInteractive {
+		return selected, nil
+	}
+	if !progressEmitter.Enabled() && !isInteractive() {
+		return nil, fmt.Errorf("--interactive requires a terminal")
+	}
+
+	appNames := make([]string, 0, len(selected.Apps))
+	for appName := range selected.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	fmt.Printf("Bundle created %s by %s contains:\n", bundle.CreatedAt.Format("2006-01-02 15:04"), bundle.CreatedBy)
+	for _, appName := range appNames {
+		appConfig := selected.Apps[appName]
+		fmt.Printf("  %s (%s): %d paths\n", appConfig.DisplayName, appName, len(appConfig.Paths))
+	}
+	fmt.Println()
+
+	var declined []string
+	for _, appName := range appNames {
+		appConfig := selected.Apps[appName]
+		question := fmt.Sprintf("Deploy %s (%d paths)?", appConfig.DisplayName, len(appConfig.Paths))
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("deploy-"+appName, question)
+		} else {
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			declined = append(declined, appName)
+		}
+	}
+
+	return deploy.SelectApps(selected, nil, declined)
+}
+
 // Helper functions for runRestore
 
 // initializeRestoreComponents sets up configuration manager, config, and backup manager
 func initializeRestoreComponents() (*config.Manager, *config.Config, *backup.Manager, error) {
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	if !manager.ConfigExists() {
-		return nil, nil, nil, fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return nil, nil, nil, messages.Error(messages.NotInitialized, nil)
 	}
 
 	cfg, err := manager.Load()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+		return nil, nil, nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
-	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose)
+	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose).WithContext(runContext).WithCompression(cfg.Settings.BackupCompression).WithForce(restoreForce)
 	return manager, cfg, backupManager, nil
 }
 
@@ -459,12 +1190,8 @@ func determineAppsToRestore(args []string, cfg *config.Config, backupManager *ba
 	var appsToRestore []string
 
 	if restoreAll {
-		for appName := range cfg.Apps {
-			backups, err := backupManager.ListBackups(appName)
-			if err != nil {
-				continue
-			}
-			if len(backups) > 0 {
+		for appName, appConfig := range cfg.Apps {
+			if hasBackups(backupManager, appName, appConfig) {
 				appsToRestore = append(appsToRestore, appName)
 			}
 		}
@@ -477,6 +1204,23 @@ func determineAppsToRestore(args []string, cfg *config.Config, backupManager *ba
 	return appsToRestore, nil
 }
 
+// hasBackups reports whether any path of an application selected by --path has a backup to restore
+func hasBackups(backupManager *backup.Manager, appName string, appConfig *config.AppConfig) bool {
+	if backups, err := backupManager.ListBackups(appName); err == nil && len(backups) > 0 && len(restorePaths) == 0 {
+		return true
+	}
+	for _, path := range appConfig.Paths {
+		if !restoreSelects(path) {
+			continue
+		}
+		versions, err := backupManager.ListVersions(appName, &path)
+		if err == nil && len(versions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // restoreApplications performs the actual restoration for all applications
 func restoreApplications(appsToRestore []string, cfg *config.Config, backupManager *backup.Manager) ([]string, []string) {
 	var successful []string
@@ -487,12 +1231,13 @@ func restoreApplications(appsToRestore []string, cfg *config.Config, backupManag
 		if !exists {
 			failed = append(failed, appName)
 			if verbose {
-				fmt.Printf("Application %s is not configured\n", appName)
+				printer.Failure("%s", messages.Localize(messages.AppNotConfigured, messages.Data{"App": appName}))
 			}
 			continue
 		}
 
 		if restoreApplication(appConfig, appName, backupManager) {
+			eventEmitter.Emit(events.RestorePerformed, appName, map[string]interface{}{"source": "backup", "version": restoreVersion})
 			successful = append(successful, appConfig.DisplayName)
 		} else {
 			failed = append(failed, appConfig.DisplayName)
@@ -509,18 +1254,56 @@ func restoreApplication(appConfig *config.AppConfig, appName string, backupManag
 	}
 
 	pathErrors := 0
+	entry := history.Entry{
+		Operation: history.Restore,
+		App:       appName,
+		Undo:      captureAppUndo(newConfigManager(), history.Restore, appName, appConfig),
+	}
+	if restoreVersion != "" {
+		entry.Details = map[string]string{"version": restoreVersion}
+	}
+	selected := 0
 	for _, path := range appConfig.Paths {
-		if err := backupManager.RestorePath(appName, &path); err != nil {
-			if verbose {
-				fmt.Printf("  ✗ Failed to restore %s: %v\n", path.Source, err)
+		if !restoreSelects(path) {
+			continue
+		}
+		selected++
+
+		version := restoreVersion
+		if restoreInteractive {
+			picked, skip, err := pickBackupVersion(backupManager, appName, path)
+			if err != nil {
+				printer.Failure("  %s", messages.Localize(messages.BackupListFailed, messages.Data{"Path": path.Source, "Error": err}))
+				entry.Error = err.Error()
+				pathErrors++
+				continue
+			}
+			if skip {
+				continue
+			}
+			version = picked
+		}
+		if err := backupManager.RestorePathVersion(appName, &path, version); err != nil {
+			if verbose || errors.Is(err, backup.ErrInvalidBackup) {
+				printer.Failure("  %s", messages.Localize(messages.PathRestoreFailed, messages.Data{"Path": path.Source, "Error": err}))
 			}
+			entry.Error = err.Error()
 			pathErrors++
+			continue
 		}
+		entry.Paths = append(entry.Paths, path.Source)
+	}
+	if selected == 0 {
+		printer.Failure("  %s", messages.Localize(messages.RestorePathUnmatched, messages.Data{"App": appConfig.DisplayName, "Paths": strings.Join(restorePaths, ", ")}))
+		return false
+	}
+	if len(entry.Paths) > 0 || entry.Error != "" {
+		recordHistory(entry)
 	}
 
 	if pathErrors == 0 {
 		if verbose {
-			fmt.Printf("✓ Restored %s\n", appConfig.DisplayName)
+			printer.Success("%s", messages.Localize(messages.Restored, messages.Data{"Name": appConfig.DisplayName}))
 		}
 		return true
 	}
@@ -528,18 +1311,102 @@ func restoreApplication(appConfig *config.AppConfig, appName string, backupManag
 	return false
 }
 
+// restoreSelects reports whether --path selects a path for restoring: when its source or store
+// destination contains one of the given texts, or always when none were given
+func restoreSelects(path config.Path) bool {
+	if len(restorePaths) == 0 {
+		return true
+	}
+	for _, text := range restorePaths {
+		if strings.Contains(path.Source, text) || strings.Contains(path.Destination, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickBackupVersion lists the backups of a path and asks which one to restore, returning its
+// version, or skip when the path is to be left alone. Paths without backups are skipped.
+func pickBackupVersion(backupManager *backup.Manager, appName string, path config.Path) (string, bool, error) {
+	versions, err := backupManager.ListVersions(appName, &path)
+	if err != nil {
+		return "", false, err
+	}
+	if len(versions) == 0 {
+		fmt.Printf("\n%s: no backups\n", path.Source)
+		return "", true, nil
+	}
+
+	fmt.Printf("\n%s\n", path.Source)
+	notes := versionNotes(versions)
+	for i, version := range versions {
+		fmt.Printf("  %d) %s  %s%s\n", i+1, version.CreatedAt.Local().Format("2006-01-02 15:04:05"), fsutil.FormatSize(version.Size), notes[i])
+	}
+
+	for {
+		answer := promptLine(fmt.Sprintf("Backup to restore (1-%d, Enter for the latest, s to skip): ", len(versions)))
+		index, skip, err := parseBackupChoice(answer, len(versions))
+		if err != nil {
+			printer.Failure("  %v", err)
+			continue
+		}
+		switch {
+		case skip:
+			return "", true, nil
+		case index < 0:
+			return "", false, nil
+		default:
+			return versions[index].Version, false, nil
+		}
+	}
+}
+
+// parseBackupChoice reads an answer to the backup picker: the index of a listed backup, -1 for
+// the latest backup when the answer is empty, or skip for s
+func parseBackupChoice(answer string, count int) (int, bool, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch answer {
+	case "":
+		return -1, false, nil
+	case "s", "skip":
+		return 0, true, nil
+	}
+	choice, err := strconv.Atoi(answer)
+	if err != nil || choice < 1 || choice > count {
+		return 0, false, fmt.Errorf("enter a number from 1 to %d, or s to skip", count)
+	}
+	return choice - 1, false, nil
+}
+
+// versionNotes marks which of a path's backups, newest first, is the latest and which were
+// taken before a restore
+func versionNotes(versions []*config.BackupInfo) []string {
+	notes := make([]string, len(versions))
+	latestShown := false
+	for i, version := range versions {
+		switch {
+		case version.Reason == backup.ReasonPreRestore:
+			notes[i] = "  (taken before a restore)"
+		case !latestShown:
+			notes[i] = "  (latest)"
+			latestShown = true
+		}
+	}
+	return notes
+}
+
 // showRestoreResults displays the restoration results
 func showRestoreResults(successful, failed []string) {
 	fmt.Println()
 	if len(successful) > 0 {
-		fmt.Printf("✓ Successfully restored %d application(s):\n", len(successful))
+		printer.Success("%s", messages.Localize(messages.AppsRestored, messages.Data{"Count": len(successful)}))
 		for _, name := range successful {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
 	if len(failed) > 0 {
-		fmt.Printf("\n✗ Failed to restore %d application(s):\n", len(failed))
+		printer.Failure("\n%s", messages.Localize(messages.AppsRestoreFailed, messages.Data{"Count": len(failed)}))
 		for _, name := range failed {
 			fmt.Printf("  - %s\n", name)
 		}
@@ -548,19 +1415,55 @@ func showRestoreResults(successful, failed []string) {
 
 func init() {
 	// Backup command flags
+	backupCmd.Flags().StringSliceVar(&backupExcept, "except", nil, "applications or @groups to leave out (repeatable)")
 	backupCmd.Flags().IntVar(&backupKeepDays, "keep-days", 30, "cleanup backups older than N days")
 	backupCmd.Flags().BoolVar(&backupValidate, "validate", false, "validate existing backups")
+	backupCmd.Flags().BoolVar(&backupList, "list", false, "list backup versions for each path")
+	backupCmd.Flags().BoolVar(&backupIncludeCaches, "include-caches", false, "back up cache and log directories instead of leaving them out")
+	backupCmd.Flags().StringVar(&backupCompression, "compression", "", "compress each backed up path into an archive: none, gzip, or zstd (default from the backup_compression setting)")
 
 	// Restore command flags
 	restoreCmd.Flags().BoolVar(&restoreAll, "all", false, "restore all backed up applications")
+	restoreCmd.Flags().StringVar(&restoreVersion, "version", "", "backup version (timestamp or prefix) to restore (default: latest)")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore backups that fail validation")
+	restoreCmd.Flags().StringSliceVar(&restorePaths, "path", nil, "only restore paths whose source or destination contains this text (repeatable)")
+	restoreCmd.Flags().BoolVar(&restoreInteractive, "interactive", false, "choose the backup to restore for each path")
 
 	// Export command flags
-	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file for bundle (default: configsync-bundle.tar.gz)")
-	exportCmd.Flags().StringSliceVar(&exportApps, "apps", []string{}, "comma-separated list of apps to export (default: all)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file or directory for bundle (default: configsync-bundle.tar.gz, or dotfiles with --layout repo)")
+	exportCmd.Flags().StringVar(&outputFormat, "result-format", outputTable, "output format for results: table, json, or yaml (--output is the bundle path)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "bundle format: tar.gz, zip, or dir (default: from the --output extension, else tar.gz)")
+	exportCmd.Flags().StringVar(&exportCompression, "compression", "", "archive compression: none, gzip, or zstd (default: from the --output extension, else gzip)")
+	exportCmd.Flags().IntVar(&exportLevel, "level", 0, "compression level: 1-9 for gzip, 1-22 for zstd (default: the algorithm's default)")
+	exportCmd.Flags().StringSliceVar(&exportApps, "apps", []string{}, "comma-separated list of apps or @groups to export (default: all)")
+	exportCmd.Flags().StringSliceVar(&exportExcept, "except", nil, "applications or @groups to leave out of the bundle (repeatable)")
+	exportCmd.Flags().StringVar(&exportParent, "parent", "", "bundle to record as this bundle's parent (default: last exported or imported bundle)")
+	exportCmd.Flags().StringVar(&exportSignKey, "sign", "", "sign the bundle with this Ed25519 private key (see 'configsync bundle keygen')")
+	exportCmd.Flags().BoolVar(&exportBrewfile, "with-brewfile", false, "record the Homebrew casks and formulae that install the bundled apps")
+	exportCmd.Flags().StringVar(&exportDescription, "description", "", "describe the bundle for the people importing it")
+	exportCmd.Flags().StringSliceVar(&exportTags, "tag", []string{}, "tag the bundle, e.g. team or role (repeatable)")
+	exportCmd.Flags().StringVar(&exportMinVersion, "min-version", "", "oldest configsync version that may import the bundle")
+	exportCmd.Flags().BoolVar(&exportIncludeCaches, "include-caches", false, "include cache and log directories in the bundle instead of leaving them out")
+	exportCmd.Flags().StringVar(&exportLayout, "layout", deploy.LayoutBundle, "what to write: bundle, for import and deploy, or repo, a dotfiles repository to browse and publish")
+	exportCmd.Flags().BoolVar(&exportStow, "stow", false, "with --layout repo, mirror the home directory in each application's folder for GNU Stow")
 
 	// Import command flags
 	importCmd.Flags().BoolVar(&importForce, "force", false, "force import even with conflicts")
+	importCmd.Flags().StringVar(&importVerify, "verify", "", "require a valid signature from this Ed25519 public key")
+	importCmd.Flags().StringVar(&importSHA256, "sha256", "", "require the bundle archive to have this SHA-256 digest")
+	importCmd.Flags().StringVar(&importProxy, "proxy", "", "download bundle URLs through this proxy instead of HTTPS_PROXY")
+	importCmd.Flags().StringVar(&importLayer, "layer", "", "import the bundle as a layer of a layered deployment: baseline or overlay")
 
 	// Deploy command flags
 	deployCmd.Flags().BoolVar(&deployForce, "force", false, "force deploy even with conflicts")
+	deployCmd.Flags().StringSliceVar(&deployApps, "apps", []string{}, "comma-separated list of apps to deploy from the bundle (default: all)")
+	deployCmd.Flags().StringSliceVar(&deploySkip, "skip", []string{}, "comma-separated list of apps in the bundle not to deploy")
+	deployCmd.Flags().BoolVar(&deployInteractive, "interactive", false, "list the bundle contents and choose which apps to deploy")
+	deployCmd.Flags().BoolVar(&deployPreferLocal, "prefer-local", false, "keep local values for settings changed both locally and in the bundle")
+	deployCmd.Flags().BoolVar(&deployPreferBundle, "prefer-bundle", false, "take bundle values for settings changed both locally and in the bundle")
+	deployCmd.Flags().BoolVar(&deployInstall, "install-missing", false, "install the bundled apps' Homebrew packages that are missing before deploying")
+	deployCmd.Flags().BoolVar(&deployKeepImport, "keep-import", false, "keep the imported bundle after all of it is deployed")
+	deployCmd.Flags().BoolVar(&deployLayers, "layers", false, "deploy the baseline and overlay layers imported with 'import --layer'")
+	deployCmd.Flags().BoolVar(&deployTrustHooks, "trust-hooks", false, "adopt the post-sync commands of bundle apps, which sync runs after linking")
+	deployCmd.MarkFlagsMutuallyExclusive("prefer-local", "prefer-bundle")
 }
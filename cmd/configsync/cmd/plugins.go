@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+// pluginsCmd represents the plugins command
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manage plugins that detect and sync applications",
+	Long: `Manage plugins: executables in ~/.configsync/plugins that extend ConfigSync
+without patching it.
+
+A plugin reads one JSON request on its standard input and writes one JSON
+response to its standard output. Detector plugins describe where niche
+applications keep their configuration, for 'discover' and 'add'. Sync plugins
+export settings kept outside plain files, such as in a database, into the store
+and import them back, for paths that name them with plugin:.
+
+Examples:
+  configsync plugins list`,
+}
+
+// pluginsListCmd represents the plugins list command
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the installed plugins",
+	Long: `List the plugins in ~/.configsync/plugins with what each can do, asking each
+to describe itself.`,
+	Args: cobra.NoArgs,
+	RunE: runPluginsList,
+}
+
+// pluginEntry is one plugin listed by plugins list
+type pluginEntry struct {
+	Name         string   `json:"name" yaml:"name"`
+	Path         string   `json:"path" yaml:"path"`
+	Description  string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Capabilities []string `json:"capabilities" yaml:"capabilities"`
+	Error        string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func init() {
+	pluginsCmd.AddCommand(pluginsListCmd)
+}
+
+// pluginDir returns the directory holding the user's plugins
+func pluginDir() string {
+	return filepath.Join(configDir, plugins.DirName)
+}
+
+// findPlugins returns the installed plugins
+func findPlugins() ([]*plugins.Plugin, error) {
+	return plugins.Find(pluginDir(), homeDir)
+}
+
+// openPlugin opens an installed plugin by name, for the paths synced with it
+func openPlugin(name string) (*plugins.Plugin, error) {
+	return plugins.Open(pluginDir(), name, homeDir)
+}
+
+func runPluginsList(_ *cobra.Command, _ []string) error {
+	found, err := findPlugins()
+	if err != nil {
+		return err
+	}
+
+	entries := []pluginEntry{}
+	for _, plugin := range found {
+		entry := pluginEntry{Name: plugin.Name, Path: plugin.Path, Capabilities: []string{}}
+		if description, err := plugin.Describe(); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Description = description.Description
+			entry.Capabilities = append(entry.Capabilities, description.Capabilities...)
+		}
+		entries = append(entries, entry)
+	}
+
+	if structuredOutput() {
+		return printStructured(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No plugins installed; put plugin executables in %s\n", pluginDir())
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tCAPABILITIES\tDESCRIPTION")
+	for _, entry := range entries {
+		description := entry.Description
+		if entry.Error != "" {
+			description = "error: " + entry.Error
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", entry.Name, strings.Join(entry.Capabilities, ", "), description)
+	}
+	writer.Flush()
+
+	fmt.Printf("\nTotal: %d plugins\n", len(entries))
+	return nil
+}
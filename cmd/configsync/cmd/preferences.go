@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/defaults"
+)
+
+// flushPreferences makes cfprefsd drop its cached preferences of the plists of apps, which sync,
+// restore, deploy, and remove replace on disk behind its back, as the preferences_flush setting
+// says. Without it, applications keep reading the old values until cfprefsd is restarted.
+func flushPreferences(cfg *config.Config, apps map[string]*config.AppConfig) {
+	mode := cfg.Settings.PreferencesFlushMode()
+	if runtime.GOOS != "darwin" || dryRun || mode == config.PreferencesFlushOff {
+		return
+	}
+
+	host := cfg.Host(config.CurrentHost)
+	var paths []string
+	for _, appConfig := range apps {
+		for _, path := range appConfig.Paths {
+			if path.Type != config.PathTypeDefaults && path.AppliesTo(config.CurrentPlatform) {
+				paths = append(paths, host.SubstitutePath(path.Source, homeDir))
+			}
+		}
+	}
+	domains := defaults.ChangedDomains(homeDir, paths)
+	if len(domains) == 0 {
+		return
+	}
+
+	defaultsManager := defaults.NewManager(verbose)
+	if mode == config.PreferencesFlushRestart {
+		if err := defaultsManager.RestartPreferencesDaemon(); err != nil {
+			printer.Warning("%v", err)
+		}
+		return
+	}
+	defaultsManager.FlushDomains(domains)
+}
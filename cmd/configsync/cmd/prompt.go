@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// stdinReader is shared by all prompts so input buffered by one prompt is not lost to the next
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// serving is set while the API server runs, whose operations must never wait for terminal input
+var serving bool
+
+// nonInteractive is set by 'provision --non-interactive', whose prompts take their default answer
+var nonInteractive bool
+
+// isInteractive reports whether stdin is attached to a terminal that prompts may read from
+func isInteractive() bool {
+	return !serving && !nonInteractive && isTerminal(os.Stdin)
+}
+
+// isTerminal reports whether a file is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptYesNo asks a yes/no question on stdin and returns true only for an explicit yes
+func promptYesNo(question string) bool {
+	if !isInteractive() {
+		return false
+	}
+
+	printer.Prompt("%s [y/N]: ", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// promptLine asks for a line of input on stdin and returns it trimmed, or an empty string without a terminal
+func promptLine(question string) string {
+	if !isInteractive() {
+		return ""
+	}
+
+	printer.Prompt("%s", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil && answer == "" {
+		return ""
+	}
+
+	return strings.TrimSpace(answer)
+}
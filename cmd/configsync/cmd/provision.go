@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+var (
+	provisionBundle         string
+	provisionReportURL      string
+	provisionStorePath      string
+	provisionVerify         string
+	provisionSHA256         string
+	provisionNonInteractive bool
+	provisionForce          bool
+	provisionTrustHooks     bool
+)
+
+// Exit codes of 'provision', which first-boot scripts can act on without parsing its output
+const (
+	provisionExitOK       = 0
+	provisionExitError    = 1 // Invalid flags
+	provisionExitConfig   = 2 // ConfigSync could not be initialized or its configuration loaded
+	provisionExitDeploy   = 6 // The bundle could not be deployed, or applications failed to sync
+	provisionExitDownload = 7 // The bundle could not be downloaded
+	provisionExitBundle   = 8 // The bundle is missing, invalid, or failed verification
+	provisionExitReport   = 9 // Provisioning succeeded but the report could not be posted
+)
+
+// Stages of provisioning, named in the report when one fails
+const (
+	provisionStageInit     = "init"
+	provisionStageDownload = "download"
+	provisionStageImport   = "import"
+	provisionStageDeploy   = "deploy"
+	provisionStageSync     = "sync"
+)
+
+// Results of provisioning
+const (
+	provisionSucceeded = "succeeded"
+	provisionFailed    = "failed"
+)
+
+// provisionReportTimeout bounds how long posting the report may take
+const provisionReportTimeout = 30 * time.Second
+
+// provisionReport is the machine-readable result of provisioning, posted to --report
+type provisionReport struct {
+	StartedAt   time.Time `json:"started_at" yaml:"started_at"`
+	FinishedAt  time.Time `json:"finished_at" yaml:"finished_at"`
+	Host        string    `json:"host" yaml:"host"`
+	Version     string    `json:"version" yaml:"version"`
+	Bundle      string    `json:"bundle" yaml:"bundle"`
+	BundleHash  string    `json:"bundle_sha256,omitempty" yaml:"bundle_sha256,omitempty"`
+	Status      string    `json:"status" yaml:"status"`
+	FailedStage string    `json:"failed_stage,omitempty" yaml:"failed_stage,omitempty"`
+	Error       string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Apps        []string  `json:"apps" yaml:"apps"`                                   // Applications deployed from the bundle
+	SyncFailed  []string  `json:"sync_failed,omitempty" yaml:"sync_failed,omitempty"` // Applications that failed to sync
+	ExitCode    int       `json:"exit_code" yaml:"exit_code"`
+	DryRun      bool      `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+}
+
+// provisionCmd represents the provision command
+var provisionCmd = &cobra.Command{
+	Use:   "provision --bundle <url-or-path>",
+	Short: "Set up a new Mac from a bundle without user interaction",
+	Long: `Provision a Mac from a configuration bundle in one step, for first-boot scripts run
+by Jamf or another MDM.
+
+Provisioning initializes ConfigSync if needed, downloads the bundle when given
+an HTTPS URL, or an HTTP URL pinned with --sha256, imports and deploys it, and
+syncs every configured application. With --non-interactive, nothing ever waits for input: questions
+take their default answer, as when no terminal is attached.
+
+The post-sync commands of bundle applications are shown but not adopted unless
+--trust-hooks is given, since the sync that follows would run them.
+
+With --report, the result is posted as JSON to an HTTP or HTTPS endpoint, both
+when provisioning succeeds and when it fails. The report names the host, the
+bundle and its SHA256 hash, the deployed applications, those that failed to
+sync, and the stage that failed with its error. --json prints the same report.
+
+The exit code tells the outcome apart without parsing output:
+
+  0  Provisioned
+  1  Invalid flags
+  2  ConfigSync could not be initialized or its configuration loaded
+  6  The bundle could not be deployed, or applications failed to sync
+  7  The bundle could not be downloaded
+  8  The bundle is missing, invalid, or failed verification
+  9  Provisioned, but the report could not be posted
+
+Examples:
+  configsync provision --bundle https://mdm.example.com/team.tar.gz --non-interactive
+  configsync provision --bundle https://mdm.example.com/team.tar.gz --non-interactive \
+    --report https://mdm.example.com/configsync/report
+  configsync provision --bundle /Volumes/Setup/team.tar.gz --verify team.key.pub --non-interactive
+  configsync provision --bundle https://mdm.example.com/team.tar.gz --dry-run  # Only check the bundle`,
+	RunE: runProvision,
+	Args: cobra.NoArgs,
+}
+
+func runProvision(_ *cobra.Command, _ []string) error {
+	if provisionReportURL != "" && !deploy.IsRemoteBundle(provisionReportURL) {
+		return &ExitError{Code: provisionExitError, Err: fmt.Errorf("--report must be an HTTP or HTTPS URL")}
+	}
+	if provisionNonInteractive {
+		nonInteractive = true
+	}
+
+	report := &provisionReport{
+		StartedAt: time.Now(),
+		Host:      config.CurrentHost,
+		Version:   version,
+		Bundle:    provisionBundle,
+		Apps:      []string{},
+		DryRun:    dryRun,
+	}
+	stage, code, err := provision(report)
+	report.FinishedAt = time.Now()
+	report.ExitCode = code
+	report.Status = provisionSucceeded
+	if err != nil {
+		report.Status = provisionFailed
+		report.FailedStage = stage
+		report.Error = err.Error()
+	}
+
+	reportErr := postProvisionReport(provisionReportURL, report)
+	if reportErr != nil {
+		printer.Warning("%v", reportErr)
+	}
+
+	if structuredOutput() {
+		if printErr := printStructured(report); printErr != nil && err == nil {
+			return printErr
+		}
+	} else if err == nil {
+		printer.Success("\n%s", messages.Localize(messages.Provisioned, messages.Data{"Host": report.Host, "Bundle": report.Bundle}))
+	}
+
+	switch {
+	case err != nil:
+		return &ExitError{Code: code, Err: err}
+	case reportErr != nil:
+		return &ExitError{Code: provisionExitReport}
+	}
+	return nil
+}
+
+// provision initializes ConfigSync, then imports, deploys, and syncs the bundle, recording the
+// results in the report. It returns the stage that failed and the exit code for the result.
+func provision(report *provisionReport) (string, int, error) {
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		if err := initializeForProvision(manager); err != nil {
+			return provisionStageInit, provisionExitConfig, err
+		}
+	}
+
+	bundlePath := provisionBundle
+	if deploy.IsRemoteBundle(bundlePath) {
+		fmt.Printf("Downloading %s\n", bundlePath)
+		// The download is bounded by --timeout rather than a fixed limit, since bundles may be large
+		downloaded, cleanup, err := deploy.DownloadBundle(runContext, &http.Client{}, bundlePath, deploy.DownloadOptions{
+			SHA256:     provisionSHA256,
+			PartialDir: filepath.Join(configDir, deploy.DownloadsDir),
+		})
+		if err != nil {
+			return provisionStageDownload, provisionExitDownload, err
+		}
+		defer cleanup()
+		bundlePath = downloaded
+	} else if provisionSHA256 != "" {
+		if err := deploy.VerifyChecksum(bundlePath, provisionSHA256); err != nil {
+			return provisionStageImport, provisionExitBundle, err
+		}
+	}
+	if hash, err := deploy.HashBundle(bundlePath); err == nil {
+		report.BundleHash = hash
+	}
+
+	if dryRun && !manager.ConfigExists() {
+		return provisionImport(manager, config.NewDefaultConfig("", "", ""), bundlePath, report)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return provisionStageInit, provisionExitConfig, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	if err := refuseLockedStore(cfg, "provision"); err != nil {
+		return provisionStageInit, provisionExitConfig, err
+	}
+	return provisionImport(manager, cfg, bundlePath, report)
+}
+
+// initializeForProvision initializes ConfigSync, keeping the store at --store-path if given
+func initializeForProvision(manager *config.Manager) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would initialize ConfigSync in %s\n", configDir)
+		return nil
+	}
+	if provisionStorePath != "" {
+		storePath, err := resolveStorePath(provisionStorePath)
+		if err != nil {
+			return err
+		}
+		manager.SetStorePath(storePath)
+	}
+	if err := manager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize ConfigSync: %w", err)
+	}
+	fmt.Printf("Initialized ConfigSync in %s\n", configDir)
+	return nil
+}
+
+// provisionImport imports the bundle like 'configsync import', then deploys and syncs it. A dry
+// run stops once the bundle is validated.
+func provisionImport(manager *config.Manager, cfg *config.Config, bundlePath string, report *provisionReport) (string, int, error) {
+	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetContext(runContext)
+	deployManager.SetProgress(progressEmitter)
+	deployManager.SetVersion(version)
+	deployManager.SetAutoBackup(cfg.Settings.AutoBackup)
+	deployManager.SetDryRun(dryRun)
+	if provisionVerify != "" {
+		verifyKey, err := deploy.LoadPublicKey(provisionVerify)
+		if err != nil {
+			return provisionStageImport, provisionExitBundle, err
+		}
+		deployManager.SetVerifyKey(verifyKey)
+	}
+
+	importDir := filepath.Join(configDir, "import")
+	if !dryRun {
+		if err := os.RemoveAll(importDir); err != nil && !os.IsNotExist(err) {
+			return provisionStageImport, provisionExitBundle, fmt.Errorf("failed to clean import directory: %w", err)
+		}
+	}
+	bundle, err := deployManager.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		if !dryRun {
+			_ = os.RemoveAll(importDir)
+		}
+		return provisionStageImport, provisionExitBundle, fmt.Errorf("failed to import bundle: %w", err)
+	}
+	if dryRun {
+		showImportPlan(bundle, importDir)
+		return "", provisionExitOK, nil
+	}
+	if err := deployManager.RecordParentBundle(manager.GetConfigDir(), bundle, bundlePath); err != nil {
+		printer.Warning("%s", messages.Localize(messages.BundleLineageFailed, messages.Data{"Error": err}))
+	}
+	// Without the hash, deploy identifies the bundle by its metadata instead
+	bundleHash, _ := deploy.HashBundle(bundlePath)
+	if err := deployManager.RecordImport(importDir, provisionBundle, bundleHash, bundle); err != nil {
+		printer.Warning("%v", err)
+	}
+
+	if stage, code, err := provisionDeploy(manager, cfg, deployManager, bundle, importDir, report); err != nil {
+		return stage, code, err
+	}
+
+	failed, err := syncConfiguredApps(nil)
+	report.SyncFailed = append(report.SyncFailed, failed...)
+	if err != nil {
+		return provisionStageSync, provisionExitDeploy, err
+	}
+	if len(failed) > 0 {
+		return provisionStageSync, provisionExitDeploy, fmt.Errorf("failed to sync %s", strings.Join(failed, ", "))
+	}
+	return "", provisionExitOK, nil
+}
+
+// provisionDeploy deploys an imported bundle like 'configsync deploy' and removes the imported copy
+func provisionDeploy(manager *config.Manager, cfg *config.Config, deployManager *deploy.Manager, bundle *config.DeploymentBundle, importDir string, report *provisionReport) (string, int, error) {
+	deployManager.SetMergePolicy(deployMergePolicy())
+	deployManager.SetTrustHooks(provisionTrustHooks)
+	deployManager.SetHistory(historyJournal)
+	deployManager.SetUndo(func(apps map[string]*config.AppConfig) string {
+		return captureUndo(manager, history.Deploy, apps)
+	})
+
+	bundle, err := selectDeployApps(bundle, cfg)
+	if err != nil {
+		return provisionStageDeploy, provisionExitDeploy, err
+	}
+	for appName := range bundle.Apps {
+		report.Apps = append(report.Apps, appName)
+	}
+	sort.Strings(report.Apps)
+	if len(bundle.Apps) == 0 {
+		return "", provisionExitOK, nil
+	}
+
+	release, err := lockApps(manager, "deploy", report.Apps)
+	if err != nil {
+		return provisionStageDeploy, provisionExitDeploy, err
+	}
+	defer release()
+
+	if err := deployManager.DeployBundle(bundle, importDir, manager, provisionForce); err != nil {
+		return provisionStageDeploy, provisionExitDeploy, fmt.Errorf("deployment failed: %w", err)
+	}
+	if _, err := deployManager.RemoveDeployedImport(importDir); err != nil {
+		printer.Warning("%v", err)
+	}
+	return "", provisionExitOK, nil
+}
+
+// postProvisionReport posts the report as JSON to endpoint, unless endpoint is empty
+func postProvisionReport(endpoint string, report *provisionReport) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode provisioning report: %w", err)
+	}
+	client := &http.Client{Timeout: provisionReportTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post provisioning report: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post provisioning report: %s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+func init() {
+	provisionCmd.Flags().StringVar(&provisionBundle, "bundle", "", "HTTP or HTTPS URL, or path, of the bundle to provision from")
+	provisionCmd.Flags().StringVar(&provisionReportURL, "report", "", "HTTP or HTTPS endpoint to post the JSON result report to")
+	provisionCmd.Flags().BoolVar(&provisionNonInteractive, "non-interactive", false, "never prompt; questions take their default answer")
+	provisionCmd.Flags().StringVar(&provisionStorePath, "store-path", "", "location of the central store when ConfigSync is not initialized yet")
+	provisionCmd.Flags().StringVar(&provisionVerify, "verify", "", "require a valid signature from this Ed25519 public key")
+	provisionCmd.Flags().StringVar(&provisionSHA256, "sha256", "", "require the bundle archive to have this SHA-256 digest")
+	provisionCmd.Flags().BoolVar(&provisionForce, "force", false, "deploy even with conflicts, letting the bundle win")
+	provisionCmd.Flags().BoolVar(&provisionTrustHooks, "trust-hooks", false, "adopt the post-sync commands of bundle applications, which sync runs")
+	_ = provisionCmd.MarkFlagRequired("bundle")
+}
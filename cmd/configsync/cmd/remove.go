@@ -2,12 +2,24 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/cas"
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
 	"github.com/dotbrains/configsync/internal/symlink"
 	"github.com/spf13/cobra"
 )
 
+var (
+	removePurgeStore   bool
+	removePurgeBackups bool
+)
+
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
 	Use:   "remove [app1] [app2] ...",
@@ -15,9 +27,15 @@ var removeCmd = &cobra.Command{
 	Long: `Remove one or more applications from ConfigSync management.
 This will also remove any symlinks and restore original files.
 
+The store copies and backups of removed applications are kept unless
+--purge-store or --purge-backups is given. Purged store copies can be brought
+back with 'configsync undo'; purged backups cannot. Use 'configsync gc' to find
+store files left behind by applications removed earlier.
+
 Examples:
   configsync remove vscode
-  configsync remove "Google Chrome" Firefox`,
+  configsync remove "Google Chrome" Firefox
+  configsync remove vscode --purge-store --purge-backups`,
 	RunE: runRemove,
 }
 
@@ -26,19 +44,38 @@ func runRemove(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("at least one application name is required")
 	}
 
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
+	if err := refuseLockedStore(cfg, "remove"); err != nil {
+		return err
+	}
+
+	release, err := lockApps(manager, "remove", configuredApps(cfg, args))
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	symlinkManager.SetPlugins(openPlugin)
+	symlinkManager.SetConflictStrategy(cfg.Settings.ConflictStrategy)
+	// Removing an application drops it from the configuration, so collect its paths first
+	removed := make(map[string]*config.AppConfig)
+	for _, appName := range configuredApps(cfg, args) {
+		removed[appName] = cfg.Apps[appName]
+	}
 	successful, failed := removeApplications(manager, symlinkManager, cfg, args)
+	flushPreferences(cfg, removed)
 
 	showRemoveSummary(successful, failed)
 
@@ -61,13 +98,23 @@ func removeApplications(manager *config.Manager, symlinkManager *symlink.Manager
 		appConfig, exists := cfg.Apps[appName]
 		if !exists {
 			if verbose {
-				fmt.Printf("  ✗ Application %s is not configured\n", appName)
+				printer.Failure("  %s", messages.Localize(messages.AppNotConfigured, messages.Data{"App": appName}))
 			}
 			failed = append(failed, appName)
 			continue
 		}
 
-		if err := removeApplication(manager, symlinkManager, appName, appConfig); err != nil {
+		undo := captureAppUndo(manager, history.Remove, appName, appConfig)
+		err := removeApplication(manager, symlinkManager, appName, appConfig)
+		entry := appHistoryEntry(history.Remove, appName, appConfig, err)
+		entry.Undo = undo
+		if err == nil {
+			if purged := purgeRemovedApp(cfg, appName, appConfig); purged != "" {
+				entry.Details = map[string]string{"purged": purged}
+			}
+		}
+		recordHistory(entry)
+		if err != nil {
 			failed = append(failed, appConfig.DisplayName)
 		} else {
 			successful = append(successful, appConfig.DisplayName)
@@ -85,7 +132,7 @@ func removeApplication(manager *config.Manager, symlinkManager *symlink.Manager,
 
 	if err := symlinkManager.UnsyncApp(appConfig); err != nil {
 		if verbose {
-			fmt.Printf("  ✗ Failed to unsync %s: %v\n", appConfig.DisplayName, err)
+			printer.Failure("  %s", messages.Localize(messages.AppUnsyncFailed, messages.Data{"App": appConfig.DisplayName, "Error": err}))
 		}
 		return err
 	}
@@ -93,37 +140,82 @@ func removeApplication(manager *config.Manager, symlinkManager *symlink.Manager,
 	if !dryRun {
 		if err := manager.RemoveApp(appName); err != nil {
 			if verbose {
-				fmt.Printf("  ✗ Failed to remove %s from config: %v\n", appConfig.DisplayName, err)
+				printer.Failure("  %s", messages.Localize(messages.AppRemoveFailed, messages.Data{"App": appConfig.DisplayName, "Error": err}))
 			}
 			return err
 		}
 	}
 
 	if verbose {
-		fmt.Printf("  ✓ Successfully removed %s\n", appConfig.DisplayName)
+		printer.Success("  %s", messages.Localize(messages.AppRemoved, messages.Data{"App": appConfig.DisplayName}))
 	}
 	return nil
 }
 
+// purgeRemovedApp deletes the store copies and backups of a removed application as requested with
+// --purge-store and --purge-backups, and returns what was purged for the history
+func purgeRemovedApp(cfg *config.Config, appName string, appConfig *config.AppConfig) string {
+	var purged []string
+
+	if removePurgeStore {
+		if dryRun {
+			for _, path := range appConfig.Paths {
+				fmt.Printf("[DRY RUN] Would delete %s of %s from the store\n", path.Destination, appConfig.DisplayName)
+			}
+		} else {
+			destinations, err := store.PurgeDestinations(cfg, appName, appConfig)
+			if err != nil {
+				printer.Warning("%v", err)
+			}
+			// Its blobs stay until 'store dedupe --prune' finds nothing links to them
+			if err := cas.New(cfg.StorePath).RemoveManifest(appName); err != nil {
+				printer.Warning("%s", messages.Localize(messages.BlobManifestRemoveFailed, messages.Data{"App": appConfig.DisplayName, "Error": err}))
+			}
+			if len(destinations) > 0 {
+				fmt.Printf("  Deleted %d store path(s) of %s\n", len(destinations), appConfig.DisplayName)
+				purged = append(purged, "store")
+			}
+		}
+	}
+
+	if removePurgeBackups {
+		backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose)
+		if dryRun {
+			size, _ := backupManager.AppSize(appName)
+			fmt.Printf("[DRY RUN] Would delete the backups of %s (%s)\n", appConfig.DisplayName, fsutil.FormatSize(size))
+		} else {
+			freed, err := backupManager.PurgeApp(appName)
+			if err != nil {
+				printer.Warning("%v", err)
+			} else if freed > 0 {
+				fmt.Printf("  Deleted the backups of %s (%s)\n", appConfig.DisplayName, fsutil.FormatSize(freed))
+				purged = append(purged, "backups")
+			}
+		}
+	}
+
+	return strings.Join(purged, ",")
+}
+
 // showRemoveSummary displays the removal results summary
 func showRemoveSummary(successful, failed []string) {
 	if len(successful) > 0 {
-		verb := "removed"
+		id := messages.AppsRemoved
 		if dryRun {
-			verb = "would be removed"
+			id = messages.AppsWouldBeRemoved
 		}
-		fmt.Printf("✓ Successfully %s %d application(s):\n", verb, len(successful))
+		printer.Success("%s", messages.Localize(id, messages.Data{"Count": len(successful)}))
 		for _, name := range successful {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
 	if len(failed) > 0 {
-		verb := "failed to remove"
+		id := messages.AppsRemoveFailed
 		if dryRun {
-			verb = "would fail to remove"
+			id = messages.AppsWouldFailToRemove
 		}
-		fmt.Printf("\n✗ %s %d application(s):\n", verb, len(failed))
+		printer.Failure("\n%s", messages.Localize(id, messages.Data{"Count": len(failed)}))
 		for _, name := range failed {
 			fmt.Printf("  - %s\n", name)
 		}
@@ -137,3 +229,8 @@ func showRemoveSummary(successful, failed []string) {
 func init() {
 	// No additional flags needed for remove command
 }
+
+func init() {
+	removeCmd.Flags().BoolVar(&removePurgeStore, "purge-store", false, "also delete the store copies of the applications")
+	removeCmd.Flags().BoolVar(&removePurgeBackups, "purge-backups", false, "also delete all backups of the applications")
+}
@@ -2,21 +2,59 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/output"
+	"github.com/dotbrains/configsync/internal/progress"
 	"github.com/spf13/cobra"
 )
 
 var (
-	homeDir   string
-	configDir string
-	verbose   bool
-	dryRun    bool
-	version   = "1.0.0" // Default version, overridden at build time
+	homeDir      string
+	configDir    string
+	verbose      bool
+	dryRun       bool
+	progressJSON bool
+	timeout      time.Duration
+	version      = "1.0.0" // Default version, overridden at build time
+
+	// progressEmitter reports progress as JSON lines when --progress-json is set, and is nil otherwise
+	progressEmitter *progress.Emitter
+
+	// eventEmitter delivers events to the configured webhook or socket, and is nil when none is configured
+	eventEmitter *events.Emitter
+
+	// historyJournal records the operations that change managed configurations, and is nil during dry runs
+	historyJournal *history.Journal
+
+	// runContext is canceled on Ctrl-C or SIGTERM and when --timeout expires, stopping long-running
+	// syncs, backups, exports, imports, and deploys
+	runContext = context.Background()
+
+	// cancelTimeout releases the --timeout timer once the command returns
+	cancelTimeout context.CancelFunc = func() {}
+
+	// configOptions relocates the configuration and store as selected by CONFIGSYNC_* environment variables
+	configOptions []config.Option
 )
 
+// localesDir is the directory in the configuration directory with translated message catalogs
+const localesDir = "locales"
+
+// eventFlushTimeout bounds how long configsync waits on exit for events to be delivered
+const eventFlushTimeout = 15 * time.Second
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "configsync",
@@ -31,25 +69,87 @@ It helps you:
 - Create backups before making changes
 - Support version control integration`,
 	Version: version,
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			cancelTimeout = cancel
+			cmd.SetContext(ctx)
+		}
+		if cmd.Context() != nil {
+			runContext = cmd.Context()
+		}
+		if err := resolveOutputFormat(); err != nil {
+			return err
+		}
+		return setupOutput()
+	},
+}
+
+// ExitError ends configsync with a particular exit code. Err is printed unless it is nil, so a
+// command can signal a result such as drift without printing anything more.
+type ExitError struct {
+	Err  error
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code for an error returned by Execute: the code of an ExitError, and
+// 1 for any other error
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	if err != nil && err.Error() != "" {
+		progressEmitter.Error(err)
+	}
+	if flushErr := eventEmitter.Close(eventFlushTimeout); flushErr != nil {
+		printer.Warning("%v", flushErr)
+	}
+	return err
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&homeDir, "home", "", "home directory (default is $HOME)")
+	rootCmd.PersistentFlags().StringVar(&homeDir, "home", "", "home directory (default is $HOME; see also CONFIGSYNC_HOME, CONFIGSYNC_CONFIG, CONFIGSYNC_STORE)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show what would be done without actually doing it")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputTable, "output format for results: table, json, or yaml")
+	rootCmd.PersistentFlags().BoolVar(&outputAsJSON, "json", false, "print results as JSON (shorthand for --output json)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "abort sync, backup, restore, export, import, and deploy after this long (e.g. 10m)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print failures, warnings, prompts, and structured results")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", output.ColorAuto, "color output: auto, always, or never (auto honors NO_COLOR)")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable color output (same as --color never)")
+	rootCmd.PersistentFlags().BoolVar(&progressJSON, "progress-json", false, "emit line-delimited JSON progress events on stdout and read prompt answers from stdin")
 
 	// Add subcommands
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(enableCmd)
+	rootCmd.AddCommand(disableCmd)
 	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(discoverCmd)
@@ -58,6 +158,32 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(provisionCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(storeCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(catalogCmd)
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.AddCommand(systemCmd)
+	rootCmd.AddCommand(tuiCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(checkpointCmd)
+	rootCmd.AddCommand(duCmd)
+	rootCmd.AddCommand(verifyLinksCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(pairCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(upgradesCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(uninstallCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -72,6 +198,48 @@ func initConfig() {
 		homeDir = home
 	}
 
-	// Set config directory
-	configDir = filepath.Join(homeDir, ".configsync")
+	// Set config directory, which XDG_CONFIG_HOME, CONFIGSYNC_HOME, or CONFIGSYNC_CONFIG may move out of
+	// the home directory. Every command resolves it the same way through newConfigManager.
+	options, err := config.EnvOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	configOptions = options
+	configDir = newConfigManager().GetConfigDir()
+	messages.Use(messages.NewCatalog(messages.Language(), filepath.Join(configDir, localesDir)))
+
+	// Reserve stdout for progress events; human-readable output moves to stderr
+	if progressJSON && progressEmitter == nil {
+		progressEmitter = progress.NewEmitter(os.Stdout, os.Stdin)
+		os.Stdout = os.Stderr
+	}
+
+	if eventEmitter == nil && !dryRun {
+		eventEmitter = newEventEmitter()
+	}
+
+	if historyJournal == nil && !dryRun && newConfigManager().ConfigExists() {
+		historyJournal = history.Open(configDir)
+	}
+}
+
+// newConfigManager creates a configuration manager for the home directory and environment
+func newConfigManager() *config.Manager {
+	return config.NewManager(homeDir, configOptions...)
+}
+
+// newEventEmitter creates an emitter for the event sinks in the configuration, if there are any
+func newEventEmitter() *events.Emitter {
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return nil
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return nil
+	}
+
+	sinks := cfg.Settings.EventSettings()
+	return events.NewEmitter(sinks.Webhook, expandHome(sinks.Socket))
 }
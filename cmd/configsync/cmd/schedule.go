@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/scheduler"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleInterval time.Duration
+	scheduleAtLogin  bool
+)
+
+// scheduleCmd represents the schedule command
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage scheduled automatic syncs via launchd",
+	Long: `Manage a launchd agent that runs 'configsync sync' automatically,
+either periodically, at login, or both.
+
+Examples:
+  configsync schedule install --interval 1h    # Sync every hour
+  configsync schedule install --at-login       # Sync at login only
+  configsync schedule status                   # Show the current schedule
+  configsync schedule remove                   # Remove the schedule`,
+}
+
+// scheduleInstallCmd represents the schedule install command
+var scheduleInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install or update the scheduled sync",
+	Long: `Generate a launchd agent in ~/Library/LaunchAgents and load it with launchctl.
+
+An existing schedule is replaced. Output from scheduled runs is written to the
+ConfigSync log directory.`,
+	RunE: runScheduleInstall,
+}
+
+// scheduleStatusCmd represents the schedule status command
+var scheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the scheduled sync status",
+	Long:  `Show whether the scheduled sync is installed and loaded, and how often it runs.`,
+	RunE:  runScheduleStatus,
+}
+
+// scheduleRemoveCmd represents the schedule remove command
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove the scheduled sync",
+	Long:  `Unload the launchd agent and delete its plist from ~/Library/LaunchAgents.`,
+	RunE:  runScheduleRemove,
+}
+
+func runScheduleInstall(cmd *cobra.Command, _ []string) error {
+	schedulerManager, err := newSchedulerManager()
+	if err != nil {
+		return err
+	}
+
+	schedule := scheduler.Schedule{AtLogin: scheduleAtLogin}
+	// Use the interval unless only --at-login was requested
+	if cmd.Flags().Changed("interval") || !scheduleAtLogin {
+		schedule.Interval = scheduleInterval
+	}
+
+	if dryRun {
+		data, err := schedulerManager.GeneratePlist(schedule)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[DRY RUN] Would write %s:\n\n%s", schedulerManager.PlistPath(), data)
+		return nil
+	}
+
+	if err := schedulerManager.Install(schedule); err != nil {
+		return fmt.Errorf("failed to install schedule: %w", err)
+	}
+
+	printer.Success("%s", messages.Localize(messages.ScheduleInstalled, messages.Data{"Schedule": describeSchedule(schedule)}))
+	fmt.Printf("  Agent: %s\n", schedulerManager.PlistPath())
+	fmt.Printf("  Log: %s\n", schedulerManager.LogPath())
+
+	return nil
+}
+
+func runScheduleStatus(_ *cobra.Command, _ []string) error {
+	schedulerManager, err := newSchedulerManager()
+	if err != nil {
+		return err
+	}
+
+	status, err := schedulerManager.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.Installed {
+		fmt.Println("No scheduled sync installed. Use 'configsync schedule install' to create one.")
+		return nil
+	}
+
+	fmt.Println("Scheduled Sync Status")
+	fmt.Println("=====================")
+	fmt.Printf("Schedule: %s\n", describeSchedule(status.Schedule))
+	fmt.Printf("Loaded: %t\n", status.Loaded)
+	fmt.Printf("Agent: %s\n", status.PlistPath)
+	fmt.Printf("Log: %s\n", status.LogPath)
+
+	return nil
+}
+
+func runScheduleRemove(_ *cobra.Command, _ []string) error {
+	schedulerManager, err := newSchedulerManager()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would unload and remove %s\n", schedulerManager.PlistPath())
+		return nil
+	}
+
+	if err := schedulerManager.Remove(); err != nil {
+		return fmt.Errorf("failed to remove schedule: %w", err)
+	}
+
+	printer.Success("%s", messages.Localize(messages.ScheduleRemoved, nil))
+	return nil
+}
+
+// newSchedulerManager creates a scheduler manager for the current configuration. Scheduled syncs
+// run from launchd, so Windows has none; Task Scheduler can run configsync sync instead.
+func newSchedulerManager() (*scheduler.Manager, error) {
+	if config.CurrentPlatform == config.PlatformWindows {
+		return nil, fmt.Errorf("scheduled syncs are not available on Windows; use Task Scheduler to run 'configsync sync'")
+	}
+
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return nil, messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	binaryPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine configsync binary path: %w", err)
+	}
+
+	schedulerManager := scheduler.NewManager(homeDir, binaryPath, cfg.LogPath, verbose)
+	schedulerManager.SetEnvironment(config.EnvOverrides())
+	return schedulerManager, nil
+}
+
+// describeSchedule returns a human-readable description of a schedule
+func describeSchedule(schedule scheduler.Schedule) string {
+	switch {
+	case schedule.Interval > 0 && schedule.AtLogin:
+		return fmt.Sprintf("every %s and at login", schedule.Interval)
+	case schedule.Interval > 0:
+		return fmt.Sprintf("every %s", schedule.Interval)
+	case schedule.AtLogin:
+		return "at login"
+	default:
+		return "never"
+	}
+}
+
+func init() {
+	scheduleInstallCmd.Flags().DurationVar(&scheduleInterval, "interval", scheduler.DefaultInterval, "interval between syncs (e.g. 30m, 1h)")
+	scheduleInstallCmd.Flags().BoolVar(&scheduleAtLogin, "at-login", false, "also sync when you log in")
+
+	scheduleCmd.AddCommand(scheduleInstallCmd)
+	scheduleCmd.AddCommand(scheduleStatusCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/api"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+// defaultListenAddress is where the API listens unless --listen is given
+const defaultListenAddress = "127.0.0.1:8080"
+
+var serveListen string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve status, list, sync, and export over a local HTTP API",
+	Long: `Serve the core operations over a small HTTP API on a loopback address, so a
+menubar app or scripts can drive ConfigSync without running the CLI.
+
+Every request must carry the token stored in ~/.configsync/api-token, which is
+created on first use, as a bearer token:
+
+  curl -H "Authorization: Bearer $(cat ~/.configsync/api-token)" \
+    http://127.0.0.1:8080/v1/status
+
+Endpoints:
+  GET  /v1/health   check that the server is running
+  GET  /v1/status   sync status, as 'configsync status --json'
+  GET  /v1/apps     managed applications, as 'configsync list --json'
+                    (query: filter, sort, enabled_only)
+  POST /v1/sync     sync {"apps": [...]}, or every application without a body
+  POST /v1/export   export {"output": "...", "format": "...", "apps": [...]}
+
+Operations run one at a time and never prompt: anything that would ask for
+confirmation is declined.
+
+Examples:
+  configsync serve
+  configsync serve --listen 127.0.0.1:9090`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func runServe(_ *cobra.Command, _ []string) error {
+	if err := api.CheckLoopback(serveListen); err != nil {
+		return err
+	}
+	if progressEmitter.Enabled() || dryRun {
+		return fmt.Errorf("serve does not support --progress-json or --dry-run")
+	}
+
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	token, err := api.LoadOrCreateToken(manager.GetConfigDir())
+	if err != nil {
+		return err
+	}
+
+	serving = true
+	server := &http.Server{
+		Addr:              serveListen,
+		Handler:           api.NewServer(token, apiBackend{}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serving the ConfigSync API on http://%s\n", serveListen)
+	fmt.Printf("Token: %s\n", filepath.Join(manager.GetConfigDir(), api.TokenFile))
+	if err := serveUntilInterrupted(server, server.ListenAndServe); err != nil {
+		return fmt.Errorf("failed to serve API: %w", err)
+	}
+	return nil
+}
+
+// serveUntilInterrupted runs an HTTP server until it fails or configsync is interrupted, in which
+// case requests in progress are given time to finish
+func serveUntilInterrupted(server *http.Server, serve func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// apiBackend performs API requests with the same code as the corresponding commands
+type apiBackend struct{}
+
+// serveSyncResult is the result of a sync request
+type serveSyncResult struct {
+	Failed []string `json:"failed"`
+}
+
+// loadConfig loads the configuration afresh for every request, so changes made with the CLI
+// while the server runs are picked up
+func (apiBackend) loadConfig() (*config.Manager, *config.Config, error) {
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return nil, nil, messages.Error(messages.NotInitialized, nil)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return nil, nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	return manager, cfg, nil
+}
+
+func (b apiBackend) Status() (interface{}, error) {
+	manager, cfg, err := b.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return buildStatusReport(cfg, filepath.Join(manager.GetConfigDir(), "config.yaml")), nil
+}
+
+func (b apiBackend) List(query url.Values) (interface{}, error) {
+	_, cfg, err := b.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	enabledOnly := false
+	if value := query.Get("enabled_only"); value != "" {
+		if enabledOnly, err = strconv.ParseBool(value); err != nil {
+			return nil, &api.RequestError{Err: fmt.Errorf("invalid enabled_only value %q", value)}
+		}
+	}
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = listSortName
+	}
+
+	listed, err := buildAppList(cfg.Apps, query.Get("filter"), sortBy, enabledOnly)
+	if err != nil {
+		return nil, &api.RequestError{Err: err}
+	}
+	return listed, nil
+}
+
+func (b apiBackend) Sync(req api.SyncRequest) (interface{}, error) {
+	_, cfg, err := b.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, appName := range req.Apps {
+		if _, exists := cfg.Apps[appName]; !exists {
+			return nil, &api.RequestError{Err: messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})}
+		}
+	}
+
+	failed, err := syncConfiguredApps(req.Apps)
+	if err != nil {
+		return nil, err
+	}
+	return serveSyncResult{Failed: append([]string{}, failed...)}, nil
+}
+
+func (b apiBackend) Export(req api.ExportRequest) (interface{}, error) {
+	manager, cfg, err := b.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if req.Format != "" {
+		if err := deploy.CheckFormat(req.Format); err != nil {
+			return nil, &api.RequestError{Err: err}
+		}
+	}
+
+	outputFile := req.Output
+	if outputFile == "" {
+		outputFile = deploy.DefaultBundlePath(req.Format, "")
+	}
+	if !filepath.IsAbs(outputFile) {
+		cwd, _ := os.Getwd()
+		outputFile = filepath.Join(cwd, outputFile)
+	}
+
+	deployManager := deploy.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, verbose)
+	deployManager.SetVersion(version)
+	deployManager.SetFormat(req.Format)
+	if err := deployManager.ExportBundle(outputFile, req.Apps, manager); err != nil {
+		return nil, fmt.Errorf("failed to export bundle: %w", err)
+	}
+	return buildExportResult(outputFile, deployManager.ExportStats())
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", defaultListenAddress, "loopback address and port to listen on")
+}
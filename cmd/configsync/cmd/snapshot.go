@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotLabel string
+	snapshotYes   bool
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot the whole store and roll back to earlier snapshots",
+	Long: `Capture the whole store and configuration in a timestamped local snapshot,
+and roll the system back to any snapshot later.
+
+Snapshots are kept in ~/.configsync/snapshots. Files that have not changed since
+the previous snapshot are hard links to its copy, so each snapshot only takes up
+space for what changed.
+
+Examples:
+  configsync snapshot create --label "before upgrading zsh"
+  configsync snapshot list
+  configsync snapshot restore 20240102-150405`,
+}
+
+// snapshotCreateCmd represents the snapshot create command
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Snapshot the store and configuration",
+	Long: `Snapshot the store and configuration. Use --label to describe the snapshot.
+
+Examples:
+  configsync snapshot create
+  configsync snapshot create --label "before trying a new editor config"`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotCreate,
+}
+
+// snapshotListCmd represents the snapshot list command
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots, newest first",
+	Long: `List the snapshots of the store, newest first, with their labels, the number
+of applications and files they contain, and how many files are shared with the
+previous snapshot.
+
+Examples:
+  configsync snapshot list
+  configsync snapshot list --json`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotList,
+}
+
+// snapshotRestoreCmd represents the snapshot restore command
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <snapshot-id>",
+	Short: "Roll the store and configuration back to a snapshot",
+	Long: `Roll the store and configuration back to a snapshot, given by its ID or a
+unique prefix of it.
+
+The current state is snapshotted first, so a restore can itself be undone.
+The restored store replaces the current one in a single rename. Afterwards,
+symlinks are made to match the restored configuration: synced paths whose
+symlinks are missing are linked into the store again, and symlinks of paths the
+snapshot does not manage are replaced with the files they pointed to. Paths
+synced in copy mode get the restored copy from the store. Sources that exist
+and are not symlinks are left alone and listed.
+
+Examples:
+  configsync snapshot restore 20240102-150405
+  configsync snapshot restore 20240102 --yes
+  configsync snapshot restore 20240102-150405 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotRestore,
+}
+
+func runSnapshotCreate(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	snapshotter := store.NewSnapshotter(homeDir, manager.GetConfigDir(), verbose)
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would snapshot the store %s and the configuration of %d application(s) into %s\n", cfg.StorePath, len(cfg.Apps), snapshotter.Dir())
+		return nil
+	}
+
+	snapshot, err := snapshotter.Create(manager, snapshotLabel)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	if structuredOutput() {
+		return printStructured(snapshot)
+	}
+
+	printer.Success("%s", messages.Localize(messages.SnapshotCreated, messages.Data{"Name": snapshot.ID}))
+	fmt.Printf("  Applications: %d\n", len(snapshot.Apps))
+	fmt.Printf("  Files: %d (%s), %d shared with the previous snapshot\n", snapshot.Files, fsutil.FormatSize(snapshot.Size), snapshot.Linked)
+	fmt.Printf("\nRoll back to it with: configsync snapshot restore %s\n", snapshot.ID)
+	return nil
+}
+
+func runSnapshotList(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	snapshots, err := store.NewSnapshotter(homeDir, manager.GetConfigDir(), verbose).List()
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if snapshots == nil {
+			snapshots = []*store.Snapshot{}
+		}
+		return printStructured(snapshots)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots yet. Create one with 'configsync snapshot create'.")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-17s %6s %7s %10s  %s\n", "ID", "CREATED", "APPS", "FILES", "SIZE", "LABEL")
+	for _, snapshot := range snapshots {
+		fmt.Printf("%-20s %-17s %6d %7d %10s  %s\n",
+			snapshot.ID,
+			snapshot.CreatedAt.Format("2006-01-02 15:04"),
+			len(snapshot.Apps),
+			snapshot.Files,
+			fsutil.FormatSize(snapshot.Size),
+			snapshot.Label)
+	}
+	return nil
+}
+
+func runSnapshotRestore(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	snapshotter := store.NewSnapshotter(homeDir, manager.GetConfigDir(), verbose)
+	snapshot, err := snapshotter.Find(args[0])
+	if err != nil {
+		return err
+	}
+
+	description := snapshot.ID
+	if snapshot.Label != "" {
+		description = fmt.Sprintf("%s (%s)", snapshot.ID, snapshot.Label)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would snapshot the current state, then restore snapshot %s from %s\n", description, snapshot.CreatedAt.Format("2006-01-02 15:04"))
+		fmt.Printf("[DRY RUN] Would restore %d file(s) and the configuration of %d application(s): %s\n", snapshot.Files, len(snapshot.Apps), strings.Join(snapshot.Apps, ", "))
+		return nil
+	}
+
+	if !snapshotYes {
+		question := fmt.Sprintf("Replace the store and configuration with snapshot %s?", description)
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("snapshot-restore", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "restore"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; nothing was restored")
+			return nil
+		}
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "snapshot restore"); err != nil {
+		return err
+	}
+	release, err := lockApps(manager, "snapshot restore", append(configuredApps(cfg, nil), snapshot.Apps...))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	result, err := snapshotter.Restore(manager, snapshot.ID)
+	recordSnapshotRestore(manager, snapshot, result, err)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	eventEmitter.Emit(events.RestorePerformed, "", map[string]interface{}{"source": "snapshot", "snapshot": snapshot.ID, "apps": snapshot.Apps})
+	printer.Success("%s", messages.Localize(messages.SnapshotRestored, messages.Data{"Name": description}))
+	fmt.Printf("  Symlinks linked: %d\n", result.Relinked)
+	if result.Copied > 0 {
+		fmt.Printf("  Copy-mode paths restored: %d\n", result.Copied)
+	}
+	if result.Unlinked > 0 {
+		fmt.Printf("  Symlinks replaced with files (not managed in the snapshot): %d\n", result.Unlinked)
+	}
+	if len(result.Skipped) > 0 {
+		printer.Warning("\n%s", messages.Localize(messages.SnapshotPathsSkipped, messages.Data{"Count": len(result.Skipped)}))
+		for _, source := range result.Skipped {
+			fmt.Printf("  - %s\n", source)
+		}
+	}
+	fmt.Printf("\nThe previous state was saved as snapshot %s; restore it to undo.\n", result.Safety.ID)
+	return nil
+}
+
+// recordSnapshotRestore adds the applications restored from a snapshot to the history, with the
+// paths they have after the restore
+func recordSnapshotRestore(manager *config.Manager, snapshot *store.Snapshot, result *store.RestoreResult, err error) {
+	var apps map[string]*config.AppConfig
+	if cfg, loadErr := manager.Load(); loadErr == nil {
+		apps = cfg.Apps
+	}
+
+	entries := make([]history.Entry, 0, len(snapshot.Apps))
+	for _, appName := range snapshot.Apps {
+		entry := appHistoryEntry(history.Restore, appName, apps[appName], err)
+		entry.Details = map[string]string{"snapshot": snapshot.ID}
+		if result != nil && result.Safety != nil {
+			entry.Undo = undoSnapshotPrefix + result.Safety.ID
+		}
+		entries = append(entries, entry)
+	}
+	recordHistory(entries...)
+}
+
+func init() {
+	snapshotCreateCmd.Flags().StringVar(&snapshotLabel, "label", "", "description of the snapshot")
+	snapshotRestoreCmd.Flags().BoolVarP(&snapshotYes, "yes", "y", false, "restore without asking for confirmation")
+
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+}
@@ -1,21 +1,55 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
 	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/permissions"
+	"github.com/dotbrains/configsync/internal/secrets"
+	"github.com/dotbrains/configsync/internal/statuscache"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/dotbrains/configsync/internal/symlink"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusVerify  bool
+	statusFormat  string
+	statusNoCache bool
+	statusShort   bool
+)
+
 const (
 	// Status constants for path sync states
 	statusSynced    = "synced"
 	statusNotSynced = "not_synced"
+	// statusOtherPlatform marks paths that are only used on another platform
+	statusOtherPlatform = "other_platform"
+	// statusInCloud marks paths whose store copy has been evicted by iCloud Drive
+	statusInCloud = "in_cloud"
+	// statusReplacedSymlink marks synced paths whose symlink an application replaced with a regular file
+	statusReplacedSymlink = "replaced_symlink"
+	// statusNoAccess marks paths configsync is not allowed to read, usually for lack of Full Disk Access
+	statusNoAccess = "no_access"
+	// statusPartiallyLinked marks synced paths with links that do not point to the store copy
+	statusPartiallyLinked = "partially_linked"
+	// statusOtherHost marks paths of applications the hosts section excludes from this host
+	statusOtherHost = "other_host"
+)
+
+// Exit codes of 'status --short'
+const (
+	statusExitDrift = 1
+	statusExitError = 2
 )
 
 // statusCmd represents the status command
@@ -23,82 +57,591 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show status of all managed configurations",
 	Long: `Show the current status of all managed application configurations,
-including sync status, last sync time, and any issues.`,
+including sync status, last sync time, and any issues.
+
+With --verify, every store file is re-read and compared with the checksum
+recorded when it was last synced, reporting files modified outside of
+configsync or corrupted, files missing from the store, and untracked files.
+A store locked down with 'configsync store lockdown' is always verified.
+
+The status of each path is cached, together with the modification times of
+the files it depends on, so only paths that changed since the last sync or
+status check are rechecked. Use --no-cache to recheck every path.
+
+With --short, only applications that need attention are printed, one line
+each, and nothing when everything is synced. The exit code tells scripts, shell
+prompts, and CI checks the result: 0 when every path is synced, 1 when paths
+have drifted, and 2 when paths could not be checked or the status could not be
+worked out at all.
+
+With --format xbar, the status is printed as an xbar or SwiftBar plugin: a
+one-line summary for the menubar and a menu with the status of every application
+and actions to sync it. The cloud conflict scan is skipped to keep it fast.
+
+Examples:
+  configsync status            # Show sync status
+  configsync status --no-cache # Recheck every path instead of using the cache
+  configsync status --short    # One line per application that needs attention
+  configsync status --verify   # Also verify store contents against recorded checksums
+  configsync status --format xbar  # Print a menubar plugin for xbar or SwiftBar`,
 	RunE: runStatus,
 }
 
-func runStatus(_ *cobra.Command, _ []string) error {
+// statusReport is the structured result of the status command
+type statusReport struct {
+	LastSync      *time.Time             `json:"last_sync,omitempty" yaml:"last_sync,omitempty"`
+	Verify        *manifest.VerifyReport `json:"verify,omitempty" yaml:"verify,omitempty"`
+	Permissions   *permissions.Report    `json:"permissions" yaml:"permissions"`
+	ConfigPath    string                 `json:"config_path" yaml:"config_path"`
+	StorePath     string                 `json:"store_path" yaml:"store_path"`
+	BackupPath    string                 `json:"backup_path" yaml:"backup_path"`
+	CloudProvider string                 `json:"cloud_provider,omitempty" yaml:"cloud_provider,omitempty"`
+	LockedDown    bool                   `json:"locked_down,omitempty" yaml:"locked_down,omitempty"`
+	Apps          []appStatus            `json:"apps" yaml:"apps"`
+	Conflicts     []store.CloudConflict  `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+}
+
+// appStatus is the sync status of one application
+type appStatus struct {
+	LastSynced  *time.Time   `json:"last_synced,omitempty" yaml:"last_synced,omitempty"`
+	Name        string       `json:"name" yaml:"name"`
+	DisplayName string       `json:"display_name" yaml:"display_name"`
+	Version     string       `json:"version,omitempty" yaml:"version,omitempty"`
+	Host        string       `json:"host,omitempty" yaml:"host,omitempty"` // Set when the application is not used on this host
+	AppPath     string       `json:"app_path,omitempty" yaml:"app_path,omitempty"`
+	Icon        string       `json:"icon,omitempty" yaml:"icon,omitempty"`
+	Deployed    *deployedApp `json:"deployed,omitempty" yaml:"deployed,omitempty"` // Set when the configuration came from a bundle
+	Paths       []pathStatus `json:"paths" yaml:"paths"`
+	Synced      int          `json:"synced" yaml:"synced"`
+	Enabled     bool         `json:"enabled" yaml:"enabled"`
+}
+
+// deployedApp describes the bundle an application was deployed from
+type deployedApp struct {
+	deploy.Deployment `yaml:",inline"`
+	Diverged          bool `json:"diverged" yaml:"diverged"` // Whether the store files changed since the deploy
+}
+
+// pathStatus is the sync status of one configuration path
+type pathStatus struct {
+	Source      string       `json:"source" yaml:"source"`
+	Destination string       `json:"destination" yaml:"destination"`
+	Type        string       `json:"type" yaml:"type"`
+	Status      string       `json:"status" yaml:"status"`
+	Links       []linkStatus `json:"links,omitempty" yaml:"links,omitempty"`
+}
+
+// linkStatus is the status of one of a path's links
+type linkStatus struct {
+	Path   string `json:"path" yaml:"path"`
+	Status string `json:"status" yaml:"status"`
+}
+
+func runStatus(cmd *cobra.Command, _ []string) error {
+	if !statusShort {
+		return checkStatus()
+	}
+
+	// Report failures through the exit code, printing the error once
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	if statusFormat != "" || structuredOutput() {
+		return &ExitError{Code: statusExitError, Err: fmt.Errorf("--short cannot be combined with --format or structured output")}
+	}
+	if err := checkStatus(); err != nil {
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			return err
+		}
+		return &ExitError{Code: statusExitError, Err: err}
+	}
+	return nil
+}
+
+// checkStatus prints the status in the selected format
+func checkStatus() error {
+	switch statusFormat {
+	case "", statusFormatXbar:
+	default:
+		return fmt.Errorf("invalid status format %q (expected xbar)", statusFormat)
+	}
+
 	// Create configuration manager
-	manager := config.NewManager(homeDir)
+	manager := newConfigManager()
 
 	// Check if ConfigSync is initialized
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return messages.Error(messages.NotInitialized, nil)
 	}
 
 	// Load configuration
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	configPath := filepath.Join(manager.GetConfigDir(), "config.yaml")
+	var cache *statuscache.Cache
+	if !statusNoCache {
+		cache = statuscache.Load(manager.GetConfigDir())
+		defer saveStatusCache(cache)
+	}
+	if statusFormat == statusFormatXbar {
+		return printXbarStatus(collectStatus(cfg, configPath, false, cache))
+	}
+
+	report := collectStatus(cfg, configPath, !statusShort, cache)
+	// A locked-down store must not change at all, so it is always verified
+	if statusVerify || report.LockedDown {
+		checksums, err := manifest.LoadChecksums(cfg.StorePath)
+		if err != nil {
+			return err
+		}
+		if report.Verify, err = checksums.Verify(); err != nil {
+			return err
+		}
+	}
+
+	if statusShort {
+		return printShortStatus(report)
+	}
+
+	if structuredOutput() {
+		if err := printStructured(report); err != nil {
+			return err
+		}
+	} else {
+		printStatusReport(report)
+	}
+
+	if report.Verify != nil && !report.Verify.Clean() {
+		return fmt.Errorf("store verification found differences")
+	}
+	return nil
+}
+
+// buildStatusReport collects the sync status of every configured application
+func buildStatusReport(cfg *config.Config, configPath string) *statusReport {
+	return collectStatus(cfg, configPath, true, nil)
+}
+
+// collectStatus collects the sync status of every configured application. Scanning the store for
+// cloud conflicts, and for changes since deployed applications were deployed, walks every synced
+// directory, so quick checks such as menubar refreshes skip it.
+// Path statuses are taken from the cache where the files they depend on are unchanged, and
+// stored in it otherwise; cache may be nil to check every path.
+func collectStatus(cfg *config.Config, configPath string, scanConflicts bool, cache *statuscache.Cache) *statusReport {
+	report := &statusReport{
+		ConfigPath: configPath,
+		StorePath:  cfg.StorePath,
+		BackupPath: cfg.BackupPath,
+		LockedDown: cfg.Settings.StoreReadOnly(),
+		Apps:       []appStatus{},
+	}
+	report.CloudProvider = store.CloudProvider(cfg.StorePath)
+	checker := permissions.NewChecker(homeDir)
+	report.Permissions = &permissions.Report{FullDiskAccess: checker.FullDiskAccess()}
+	if !cfg.LastSync.IsZero() {
+		lastSync := cfg.LastSync
+		report.LastSync = &lastSync
+	}
+
+	appNames := make([]string, 0, len(cfg.Apps))
+	for appName := range cfg.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	host := cfg.Host(config.CurrentHost)
+
+	for _, appName := range appNames {
+		appConfig := cfg.Apps[appName]
+		app := appStatus{
+			Name:        appName,
+			DisplayName: appConfig.DisplayName,
+			Version:     appConfig.Metadata[config.MetadataAppVersion],
+			AppPath:     appConfig.Metadata[config.MetadataAppPath],
+			Icon:        appConfig.Metadata[config.MetadataAppIcon],
+			Enabled:     appConfig.Enabled,
+			Paths:       []pathStatus{},
+		}
+		if !appConfig.LastSynced.IsZero() {
+			lastSynced := appConfig.LastSynced
+			app.LastSynced = &lastSynced
+		}
+		if deployment := deploy.DeploymentOf(appConfig); deployment != nil {
+			app.Deployed = &deployedApp{Deployment: *deployment}
+			if scanConflicts {
+				diverged, err := deployment.Diverged(cfg.StorePath, appConfig)
+				if err != nil && verbose {
+					printer.Warning("%s", messages.Localize(messages.DeploymentCompareFailed, messages.Data{"App": appName, "Error": err}))
+				}
+				app.Deployed.Diverged = diverged
+			}
+		}
+
+		if !host.UsesApp(appName) {
+			app.Host = config.CurrentHost
+			for _, path := range appConfig.Paths {
+				app.Paths = append(app.Paths, pathStatus{
+					Source:      path.Source,
+					Destination: path.Destination,
+					Type:        string(path.Type),
+					Status:      statusOtherHost,
+				})
+			}
+			report.Apps = append(report.Apps, app)
+			continue
+		}
+
+		// Check sync status for each path
+		for _, path := range appConfig.Paths {
+			sourcePath := host.SubstitutePath(path.Source, homeDir)
+			storePath := filepath.Join(cfg.StorePath, path.Destination)
+
+			if scanConflicts && report.CloudProvider != "" {
+				conflicts, err := store.FindConflicts(cfg.StorePath, storePath)
+				if err != nil && verbose {
+					printer.Warning("%s", messages.Localize(messages.ConflictCheckFailed, messages.Data{"Path": storePath, "Error": err}))
+				}
+				report.Conflicts = append(report.Conflicts, conflicts...)
+			}
+
+			key := statuscache.Key(appName, path.Destination)
+			fingerprint := statusFingerprint(&path, sourcePath, storePath, report)
+			if cache != nil {
+				if entry, ok := cache.Lookup(key, fingerprint); ok {
+					app.Paths = append(app.Paths, cachedPathStatus(&path, entry))
+					if entry.Status == statusSynced {
+						app.Synced++
+					}
+					continue
+				}
+			}
+
+			status := getPathStatus(sourcePath, storePath)
+			if status == statusNotSynced && symlink.IsReplaced(sourcePath, storePath, &path) {
+				status = statusReplacedSymlink
+			}
+			if path.IsExported() {
+				status = getExportedStatus(storePath)
+			}
+			if path.IsCopyMode() {
+				status = getCopyStatus(sourcePath, storePath)
+			}
+			if path.IsSecret() {
+				status = getSecretStatus(&path, sourcePath, storePath)
+			}
+			if status != statusSynced && report.CloudProvider != "" && store.IsEvicted(storePath) {
+				status = statusInCloud
+			}
+			if !path.AppliesTo(config.CurrentPlatform) {
+				status = statusOtherPlatform
+			} else if path.Type != config.PathTypeDefaults {
+				if issue := checker.CheckPath(appName, path.Source); issue != nil {
+					report.Permissions.Issues = append(report.Permissions.Issues, *issue)
+					status = statusNoAccess
+				}
+			}
+			links := linkStatuses(&path, storePath, host)
+			if status == statusSynced {
+				for _, link := range links {
+					if link.Status != statusSynced {
+						status = statusPartiallyLinked
+					}
+				}
+			}
+			if status == statusSynced {
+				app.Synced++
+			}
+
+			app.Paths = append(app.Paths, pathStatus{
+				Source:      path.Source,
+				Destination: path.Destination,
+				Type:        string(path.Type),
+				Status:      status,
+				Links:       links,
+			})
+
+			if cache != nil {
+				// Access problems are not reflected in modification times, so they are checked every time
+				if status == statusNoAccess {
+					cache.Forget(key)
+				} else {
+					cache.Store(key, statusCacheEntry(&path, fingerprint, status, links, sourcePath, storePath, host))
+				}
+			}
+		}
+
+		report.Apps = append(report.Apps, app)
+	}
+
+	return report
+}
+
+// statusFingerprint identifies everything besides the files themselves that a path's status
+// depends on, so a cached status is only used for the same configuration
+func statusFingerprint(path *config.Path, sourcePath, storePath string, report *statusReport) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%q|%q|%s|%s", sourcePath, storePath, path.Type, path.Mode, path.Secret, path.Synced, path.Links,
+		path.Platforms, report.CloudProvider, report.Permissions.FullDiskAccess)
+}
+
+// statusCacheEntry records a path's status with the state of its source, store copy, and links
+func statusCacheEntry(path *config.Path, fingerprint, status string, links []linkStatus, sourcePath, storePath string, host *config.HostOverride) *statuscache.Entry {
+	entry := &statuscache.Entry{
+		Fingerprint: fingerprint,
+		Status:      status,
+		Stamps:      []statuscache.Stamp{statuscache.StampOf(sourcePath), statuscache.StampOf(storePath)},
+	}
+	for _, link := range links {
+		entry.Links = append(entry.Links, statuscache.Link{Path: link.Path, Status: link.Status})
+		entry.Stamps = append(entry.Stamps, statuscache.StampOf(host.SubstitutePath(link.Path, homeDir)))
+	}
+	return entry
+}
+
+// cachedPathStatus returns the status of a path from its cache entry
+func cachedPathStatus(path *config.Path, entry *statuscache.Entry) pathStatus {
+	status := pathStatus{
+		Source:      path.Source,
+		Destination: path.Destination,
+		Type:        string(path.Type),
+		Status:      entry.Status,
+	}
+	for _, link := range entry.Links {
+		status.Links = append(status.Links, linkStatus{Path: link.Path, Status: link.Status})
+	}
+	return status
+}
+
+// saveStatusCache writes the status cache, warning if it cannot be written
+func saveStatusCache(cache *statuscache.Cache) {
+	if err := cache.Save(); err != nil && verbose {
+		printer.Warning("%v", err)
+	}
+}
+
+// refreshStatusCache works out the status of every path again and stores it in the cache, so
+// the next 'configsync status' only has to check what changed after this sync
+func refreshStatusCache(manager *config.Manager) {
+	cfg, err := manager.Load()
+	if err != nil {
+		return
 	}
+	cache := statuscache.Load(manager.GetConfigDir())
+	cache.Rebuild = true
+	collectStatus(cfg, manager.ConfigPath(), false, cache)
+	saveStatusCache(cache)
+}
 
+// printStatusReport displays a status report as text
+func printStatusReport(report *statusReport) {
 	// Show general information
 	fmt.Println("ConfigSync Status")
 	fmt.Println("=================")
-	fmt.Printf("Configuration: %s\n", filepath.Join(manager.GetConfigDir(), "config.yaml"))
-	fmt.Printf("Store Path: %s\n", cfg.StorePath)
-	fmt.Printf("Backup Path: %s\n", cfg.BackupPath)
+	fmt.Printf("Configuration: %s\n", report.ConfigPath)
+	if report.CloudProvider != "" {
+		fmt.Printf("Store Path: %s (synced by %s)\n", report.StorePath, report.CloudProvider)
+	} else {
+		fmt.Printf("Store Path: %s\n", report.StorePath)
+	}
+	if report.LockedDown {
+		fmt.Println("Store: locked down (read-only; local changes are reported as drift)")
+	}
+	fmt.Printf("Backup Path: %s\n", report.BackupPath)
 
-	if !cfg.LastSync.IsZero() {
-		fmt.Printf("Last Sync: %s\n", cfg.LastSync.Format(time.RFC3339))
+	if report.LastSync != nil {
+		fmt.Printf("Last Sync: %s\n", report.LastSync.Format(time.RFC3339))
 	} else {
 		fmt.Printf("Last Sync: Never\n")
 	}
 
-	fmt.Printf("Total Apps: %d\n", len(cfg.Apps))
+	fmt.Printf("Total Apps: %d\n", len(report.Apps))
 
-	if len(cfg.Apps) == 0 {
-		fmt.Println("\nNo applications configured. Use 'configsync add <app>' to add applications.")
-		return nil
+	if len(report.Apps) == 0 {
+		fmt.Println("\n" + messages.Localize(messages.NoAppsConfigured, nil))
+		return
 	}
 
 	fmt.Println("\nApplication Status:")
 	fmt.Println("===================")
 
-	// Check status of each application
-	for appName, appConfig := range cfg.Apps {
-		fmt.Printf("\n%s (%s)\n", appConfig.DisplayName, appName)
-		fmt.Printf("  Enabled: %t\n", appConfig.Enabled)
-		fmt.Printf("  Paths: %d\n", len(appConfig.Paths))
+	replaced, unlinked := 0, 0
+	for _, app := range report.Apps {
+		fmt.Printf("\n%s (%s)\n", app.DisplayName, app.Name)
+		fmt.Printf("  Enabled: %t\n", app.Enabled)
+		if app.Host != "" {
+			fmt.Printf("  Not used on host %s\n", app.Host)
+			continue
+		}
+		if app.Version != "" && app.AppPath != "" {
+			fmt.Printf("  Version: %s (%s)\n", app.Version, app.AppPath)
+		} else if app.Version != "" {
+			fmt.Printf("  Version: %s\n", app.Version)
+		}
+		fmt.Printf("  Paths: %d\n", len(app.Paths))
 
-		if !appConfig.LastSynced.IsZero() {
-			fmt.Printf("  Last Synced: %s\n", appConfig.LastSynced.Format(time.RFC3339))
+		if app.LastSynced != nil {
+			fmt.Printf("  Last Synced: %s\n", app.LastSynced.Format(time.RFC3339))
 		} else {
 			fmt.Printf("  Last Synced: Never\n")
 		}
+		if app.Deployed != nil {
+			diverged := ""
+			if app.Deployed.Diverged {
+				diverged = " (changed since)"
+			}
+			fmt.Printf("  Deployed: bundle %s at %s%s\n", deploy.ShortHash(app.Deployed.Bundle), app.Deployed.DeployedAt.Format(time.RFC3339), diverged)
+		}
 
-		// Check sync status for each path
-		syncedCount := 0
-		for _, path := range appConfig.Paths {
-			sourcePath := expandPath(path.Source, homeDir)
-			storePath := filepath.Join(cfg.StorePath, path.Destination)
+		if verbose {
+			for _, path := range app.Paths {
+				fmt.Printf("    %s -> %s (%s)\n", path.Source, path.Destination, path.Status)
+				for _, link := range path.Links {
+					fmt.Printf("      also %s (%s)\n", link.Path, link.Status)
+				}
+			}
+		}
 
-			status := getPathStatus(sourcePath, storePath)
-			if status == statusSynced {
-				syncedCount++
+		fmt.Printf("  Sync Status: %d/%d paths synced\n", app.Synced, len(app.Paths))
+
+		for _, path := range app.Paths {
+			if path.Status == statusReplacedSymlink {
+				printer.Failure("  %s", messages.Localize(messages.SymlinkReplaced, messages.Data{"Path": path.Source}))
+				replaced++
+			}
+			for _, link := range path.Links {
+				switch {
+				case link.Status == statusReplacedSymlink:
+					printer.Failure("  %s", messages.Localize(messages.SymlinkReplaced, messages.Data{"Path": link.Path}))
+					replaced++
+				case path.Status == statusPartiallyLinked && link.Status != statusSynced:
+					printer.Failure("  %s", messages.Localize(messages.LinkMisdirected, messages.Data{"Path": link.Path, "Status": link.Status}))
+					unlinked++
+				}
 			}
+		}
+	}
+
+	if replaced > 0 {
+		fmt.Printf("\n%d path(s) had their symlink replaced with a regular file, so the store copy is out of date.\n", replaced)
+		if report.LockedDown {
+			fmt.Println("The store is locked down; remove them and run 'configsync sync' to link the store copies again")
+		} else {
+			fmt.Println("Run 'configsync sync --heal' to move the new files into the store and relink them")
+		}
+	}
+	if unlinked > 0 {
+		fmt.Printf("\n%d link(s) do not point to the store copy. Run 'configsync sync' to link them\n", unlinked)
+	}
+
+	if len(report.Conflicts) > 0 {
+		printCloudConflicts(report.StorePath, report.Conflicts)
+	}
+
+	if len(report.Permissions.Issues) > 0 {
+		printPermissionIssues(report.Permissions)
+	}
+
+	if report.Verify != nil {
+		printVerifyReport(report.Verify)
+	}
+}
 
-			if verbose {
-				fmt.Printf("    %s -> %s (%s)\n", path.Source, path.Destination, status)
+// printShortStatus prints one line for each enabled application with paths that are not synced,
+// and returns an ExitError with the code for drift or errors if there are any
+func printShortStatus(report *statusReport) error {
+	code := 0
+	for _, app := range report.Apps {
+		if !app.Enabled || app.Host != "" {
+			continue
+		}
+
+		drifted, failed := 0, 0
+		for _, path := range app.Paths {
+			switch shortStatusOf(path) {
+			case statusExitDrift:
+				drifted++
+			case statusExitError:
+				failed++
 			}
 		}
 
-		fmt.Printf("  Sync Status: %d/%d paths synced\n", syncedCount, len(appConfig.Paths))
+		switch {
+		case failed > 0:
+			fmt.Printf("E %s: %d path(s) could not be checked, %d/%d synced\n", app.Name, failed, app.Synced, len(app.Paths))
+			code = statusExitError
+		case drifted > 0:
+			fmt.Printf("D %s: %d/%d paths synced\n", app.Name, app.Synced, len(app.Paths))
+			code = max(code, statusExitDrift)
+		}
+	}
+
+	if report.Verify != nil && !report.Verify.Clean() {
+		fmt.Printf("D store: %d file(s) differ from the recorded checksums\n", len(report.Verify.Modified)+len(report.Verify.Missing)+len(report.Verify.Untracked))
+		code = max(code, statusExitDrift)
 	}
 
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
 	return nil
 }
 
+// shortStatusOf returns the exit code a path's status contributes to 'status --short'. Paths used
+// on another platform or host, or evicted to the cloud, need no attention.
+func shortStatusOf(path pathStatus) int {
+	switch path.Status {
+	case statusSynced, statusOtherPlatform, statusOtherHost, statusInCloud:
+		return 0
+	case statusNoAccess, "error":
+		return statusExitError
+	default:
+		return statusExitDrift
+	}
+}
+
+// printCloudConflicts displays the conflicted copies a cloud service created in the store
+func printCloudConflicts(storePath string, conflicts []store.CloudConflict) {
+	fmt.Println("\nCloud Sync Conflicts:")
+	fmt.Println("=====================")
+	for _, conflict := range conflicts {
+		rel, _ := filepath.Rel(storePath, conflict.Path)
+		original, _ := filepath.Rel(storePath, conflict.Original)
+		printer.Failure("%s", messages.Localize(messages.ConflictedCopy, messages.Data{"Path": rel, "Provider": conflict.Provider, "Original": original}))
+	}
+	fmt.Println("\nRun 'configsync store conflicts --resolve <strategy>' to resolve them")
+}
+
+// printVerifyReport displays the result of verifying the store against its recorded checksums
+func printVerifyReport(verify *manifest.VerifyReport) {
+	fmt.Println("\nStore Verification:")
+	fmt.Println("===================")
+
+	if verify.Clean() {
+		printer.Success("%s", messages.Localize(messages.ChecksumsVerified, messages.Data{"Count": verify.Verified}))
+		return
+	}
+
+	fmt.Printf("%d file(s) verified\n", verify.Verified)
+	for _, section := range []struct {
+		title string
+		files []string
+	}{
+		{"Modified since last sync", verify.Modified},
+		{"Missing from store", verify.Missing},
+		{"Untracked (not recorded by sync)", verify.Untracked},
+	} {
+		if len(section.files) == 0 {
+			continue
+		}
+		printer.Failure("%s", messages.Localize(messages.VerifySection, messages.Data{"Title": section.title, "Count": len(section.files)}))
+		for _, file := range section.files {
+			fmt.Printf("  - %s\n", file)
+		}
+	}
+	fmt.Println("\nRun 'configsync sync' to record intended changes")
+}
+
 func getPathStatus(sourcePath, storePath string) string {
 	// Check if source exists
 	sourceExists := fsutil.PathExists(sourcePath)
@@ -142,11 +685,57 @@ func getPathStatus(sourcePath, storePath string) string {
 	return statusNotSynced
 }
 
-func expandPath(path, _ string) string {
-	if strings.HasPrefix(path, "~/") {
-		return path
+// linkStatuses reports whether each of a path's links is a symlink to its store copy. Links are
+// only managed on the platforms the path is used on, and defaults and plugin paths have none.
+func linkStatuses(path *config.Path, storePath string, host *config.HostOverride) []linkStatus {
+	if len(path.Links) == 0 || !path.AppliesTo(config.CurrentPlatform) || path.IsExported() {
+		return nil
+	}
+
+	links := make([]linkStatus, 0, len(path.Links))
+	for _, link := range path.Links {
+		linkPath := host.SubstitutePath(link, homeDir)
+		status := getPathStatus(linkPath, storePath)
+		if status == statusNotSynced && symlink.IsReplaced(linkPath, storePath, path) {
+			status = statusReplacedSymlink
+		}
+		links = append(links, linkStatus{Path: link, Status: status})
+	}
+	return links
+}
+
+// getExportedStatus reports a defaults or plugin path as synced once it has been exported to the store
+func getExportedStatus(storePath string) string {
+	if fsutil.PathExists(storePath) {
+		return statusSynced
 	}
-	return path
+	return statusNotSynced
+}
+
+// getCopyStatus reports a copy-mode path as synced once both it and its store copy exist
+func getCopyStatus(sourcePath, storePath string) string {
+	sourceExists, storeExists := fsutil.PathExists(sourcePath), fsutil.PathExists(storePath)
+	switch {
+	case sourceExists && storeExists:
+		return statusSynced
+	case !sourceExists && !storeExists:
+		return "missing"
+	}
+	return statusNotSynced
+}
+
+// getSecretStatus reports a secret path as synced once its file exists and the store holds a
+// reference to its backend. The backend itself is not asked, so checking needs no unlocking.
+func getSecretStatus(path *config.Path, sourcePath, storePath string) string {
+	sourceExists := fsutil.PathExists(sourcePath) && !isSymlink(sourcePath)
+	data, err := os.ReadFile(storePath)
+	if os.IsNotExist(err) && !sourceExists {
+		return "missing"
+	}
+	if ref, err := secrets.ParseReference(data); err == nil && ref.Backend == path.Secret && sourceExists {
+		return statusSynced
+	}
+	return statusNotSynced
 }
 
 func isSymlink(path string) bool {
@@ -158,5 +747,8 @@ func isSymlink(path string) bool {
 }
 
 func init() {
-	// No additional flags needed for status command
+	statusCmd.Flags().BoolVar(&statusVerify, "verify", false, "verify store files against the checksums recorded at sync time")
+	statusCmd.Flags().BoolVar(&statusShort, "short", false, "print one line per application that needs attention and exit 1 on drift, 2 on errors")
+	statusCmd.Flags().BoolVar(&statusNoCache, "no-cache", false, "recheck every path instead of using the status cache")
+	statusCmd.Flags().StringVar(&statusFormat, "format", "", "print the status for a menubar plugin: xbar (also works with SwiftBar)")
 }
@@ -0,0 +1,554 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/cas"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/spf13/cobra"
+)
+
+var (
+	storeMoveRemoveOld     bool
+	storeConflictsStrategy string
+	storeDedupePrune       bool
+)
+
+// storeDedupeReport describes the files linked to content-addressed blobs, and the blobs pruned
+type storeDedupeReport struct {
+	*cas.Result
+	Pruned *cas.PruneResult `json:"pruned,omitempty" yaml:"pruned,omitempty"`
+}
+
+// storeCmd represents the store command
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage the central configuration store",
+	Long: `Manage the central configuration store that synced applications link into.
+
+Examples:
+  configsync store move /Volumes/Data/configsync/store   # Move the store to another disk
+  configsync store conflicts                             # List conflicted copies made by iCloud Drive or Dropbox
+  configsync store dedupe --prune                        # Share identical files between apps
+  configsync store lockdown                              # Make the store read-only and report local changes as drift`,
+}
+
+// storeMoveCmd represents the store move command
+var storeMoveCmd = &cobra.Command{
+	Use:   "move <new-path>",
+	Short: "Move the store to a new location without interrupting apps",
+	Long: `Move the store to a new location, such as a different volume, while
+applications keep running.
+
+The store is first copied to the new location while apps continue to use the
+old one. The store is then locked, any changes made in the meantime are copied,
+and every application symlink is atomically swapped to the new location and
+verified. If anything fails, the symlinks and configuration are rolled back.
+
+The old store is kept unless --remove-old is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStoreMove,
+}
+
+func runStoreMove(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "store move"); err != nil {
+		return err
+	}
+
+	newStore, err := resolveStorePath(args[0])
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would move store: %s -> %s\n", cfg.StorePath, newStore)
+		fmt.Printf("[DRY RUN] Would relink synced paths of %d applications\n", len(cfg.Apps))
+		return nil
+	}
+
+	release, err := lockApps(manager, "store move", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	fmt.Printf("Moving store: %s -> %s\n", cfg.StorePath, newStore)
+
+	result, err := store.NewRelocator(homeDir, newStore, verbose).Relocate(manager)
+	entry := history.Entry{Operation: history.StoreMove, Paths: []string{cfg.StorePath, newStore}}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	recordHistory(entry)
+	if err != nil {
+		return fmt.Errorf("failed to move store: %w", err)
+	}
+
+	printer.Success("%s", messages.Localize(messages.StoreMoved, messages.Data{"Path": result.NewStore}))
+	fmt.Printf("  Files copied: %d\n", result.Copied+result.Skipped)
+	fmt.Printf("  Symlinks relinked: %d\n", result.Relinked)
+
+	if storeMoveRemoveOld {
+		if err := os.RemoveAll(result.OldStore); err != nil {
+			return fmt.Errorf("failed to remove old store: %w", err)
+		}
+		fmt.Printf("  Removed old store: %s\n", result.OldStore)
+	} else {
+		fmt.Printf("  Old store kept at %s (remove it once you have checked your apps)\n", result.OldStore)
+	}
+
+	return nil
+}
+
+// storeConflictsCmd represents the store conflicts command
+var storeConflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "List or resolve conflicted copies made by a cloud sync service",
+	Long: `List the conflicted copies that iCloud Drive, Dropbox, or Syncthing created
+in the store when the same file was changed on two machines, such as
+"settings (Alice's conflicted copy 2024-01-02).json" or "settings 2.json".
+
+With --resolve, each conflict is settled with a strategy:
+  keep-original   Keep the file with the original name
+  keep-copy       Replace the original with the conflicted copy
+  keep-newest     Keep whichever file was modified last
+
+The file that loses is moved to the conflicts directory in the backup path
+rather than deleted. Set conflict_strategy in the settings to one of these
+strategies to resolve conflicts automatically during sync.
+
+Examples:
+  configsync store conflicts                         # List conflicted copies
+  configsync store conflicts --resolve keep-newest   # Keep the newest version of each file`,
+	Args: cobra.NoArgs,
+	RunE: runStoreConflicts,
+}
+
+func runStoreConflicts(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	conflicts, err := store.FindConflicts(cfg.StorePath, cfg.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to find conflicts: %w", err)
+	}
+
+	if structuredOutput() && storeConflictsStrategy == "" {
+		if conflicts == nil {
+			conflicts = []store.CloudConflict{}
+		}
+		return printStructured(conflicts)
+	}
+
+	if len(conflicts) == 0 {
+		printer.Success("%s", messages.Localize(messages.NoConflictedCopies, nil))
+		return nil
+	}
+
+	if storeConflictsStrategy == "" {
+		printCloudConflicts(cfg.StorePath, conflicts)
+		return nil
+	}
+
+	if err := store.ValidateConflictStrategy(storeConflictsStrategy); err != nil {
+		return err
+	}
+
+	archiveDir := filepath.Join(cfg.BackupPath, symlink.ConflictArchiveDir)
+	resolved := 0
+	for _, conflict := range conflicts {
+		rel, _ := filepath.Rel(cfg.StorePath, conflict.Path)
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would resolve %s (%s)\n", rel, storeConflictsStrategy)
+			continue
+		}
+
+		kept, err := store.ResolveConflict(conflict, storeConflictsStrategy, archiveDir)
+		if err != nil {
+			printer.Failure("%s: %v", rel, err)
+			continue
+		}
+		resolved++
+		if kept == conflict.Path {
+			printer.Success("%s", messages.Localize(messages.ConflictKeptCopy, messages.Data{"Path": rel}))
+		} else {
+			printer.Success("%s", messages.Localize(messages.ConflictKeptOriginal, messages.Data{"Path": rel}))
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+	fmt.Printf("\nResolved %d of %d conflict(s); replaced files were moved to %s\n", resolved, len(conflicts), archiveDir)
+	if resolved < len(conflicts) {
+		return fmt.Errorf("failed to resolve %d conflict(s)", len(conflicts)-resolved)
+	}
+	return nil
+}
+
+// storeDedupeCmd represents the store dedupe command
+var storeDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Share identical files between apps",
+	Long: `Turn the store into a content-addressed store: every file of every
+application's store copy becomes a hard link to a blob named by the SHA-256
+hash of its content, so identical files take up space once. Blobs and a
+manifest of each application's files are kept in .configsync-objects at the
+root of the store.
+
+Set store_mode to content-addressed in the settings to deduplicate the synced
+applications after every sync. Files with different permissions than an
+identical blob keep their own copy. Backups and snapshots are never linked to
+blobs, so they keep the content they were taken with; those linked by earlier
+versions get their own copy back.
+
+Linked files share their content, so an application that rewrites a file in
+place, rather than replacing it, changes every copy linked to it. Such files
+are reported and filed under their new hash.
+
+With --prune, blobs no file links to anymore are deleted.
+
+Examples:
+  configsync store dedupe
+  configsync store dedupe --prune --json`,
+	Args: cobra.NoArgs,
+	RunE: runStoreDedupe,
+}
+
+func runStoreDedupe(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "store dedupe"); err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would link identical files of %d applications to blobs in %s\n",
+			len(cfg.Apps), cas.New(cfg.StorePath).Dir())
+		return nil
+	}
+
+	release, err := lockApps(manager, "store dedupe", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	result, err := dedupeStore(manager, cfg, cfg.Apps, true)
+	if err != nil {
+		return err
+	}
+	report := &storeDedupeReport{Result: result}
+	if storeDedupePrune {
+		objects := cas.New(cfg.StorePath)
+		objects.SetContext(runContext)
+		if report.Pruned, err = objects.Prune(); err != nil {
+			return fmt.Errorf("failed to prune blobs: %w", err)
+		}
+	}
+
+	if structuredOutput() {
+		return printStructured(report)
+	}
+
+	printer.Success("%s", messages.Localize(messages.StoreDeduplicated, messages.Data{"Count": result.Files, "Blobs": result.Blobs}))
+	fmt.Printf("  Copies linked: %d, freeing %s\n", result.Linked, fsutil.FormatSize(result.Saved))
+	if result.Skipped > 0 {
+		fmt.Printf("  Kept as separate copies: %d (on another volume or with other permissions)\n", result.Skipped)
+	}
+	if result.Unshared > 0 {
+		fmt.Printf("  Backup and snapshot files given their own copy: %d\n", result.Unshared)
+	}
+	if report.Pruned != nil {
+		fmt.Printf("  Unused blobs deleted: %d, freeing %s\n", report.Pruned.Blobs, fsutil.FormatSize(report.Pruned.Size))
+	}
+	printRekeyed(result)
+	return nil
+}
+
+// dedupeStore links the store files of apps to content-addressed blobs. When all is set, backups
+// and snapshots linked to blobs by earlier versions get their own copies back, so editing a
+// store file in place cannot change them.
+func dedupeStore(manager *config.Manager, cfg *config.Config, apps map[string]*config.AppConfig, all bool) (*cas.Result, error) {
+	objects := cas.New(cfg.StorePath)
+	objects.SetContext(runContext)
+	result := &cas.Result{}
+
+	for _, appName := range appNamesOf(apps) {
+		if err := objects.AddApp(apps[appName], result); err != nil {
+			return result, fmt.Errorf("failed to deduplicate %s: %w", appName, err)
+		}
+	}
+	if !all {
+		return result, nil
+	}
+
+	snapshots := store.NewSnapshotter(homeDir, manager.GetConfigDir(), verbose).Dir()
+	for _, dir := range []string{cfg.BackupPath, snapshots} {
+		if err := objects.Unshare(dir, result); err != nil {
+			return result, fmt.Errorf("failed to deduplicate %s: %w", dir, err)
+		}
+	}
+	return result, nil
+}
+
+// printRekeyed warns about store files that were edited in place while linked to a blob
+func printRekeyed(result *cas.Result) {
+	for _, path := range result.Rekeyed {
+		printer.Warning("%s", messages.Localize(messages.BlobEditedInPlace, messages.Data{"Path": path}))
+	}
+}
+
+// storeCheckoutCmd represents the store checkout command
+var storeCheckoutCmd = &cobra.Command{
+	Use:   "checkout [app...]",
+	Short: "Rebuild missing store files from content-addressed blobs",
+	Long: `Rebuild the files and symlinks missing from the store copies of applications
+from the manifests recorded by 'configsync store dedupe', linking each file to
+its blob. Existing files are left alone. Without arguments, every application
+with a manifest is checked out.
+
+Examples:
+  configsync store checkout
+  configsync store checkout vscode`,
+	RunE: runStoreCheckout,
+}
+
+func runStoreCheckout(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "store checkout"); err != nil {
+		return err
+	}
+
+	appNames := args
+	if len(appNames) == 0 {
+		appNames = appNamesOf(cfg.Apps)
+	}
+	objects := cas.New(cfg.StorePath)
+	objects.SetContext(runContext)
+
+	release, err := lockApps(manager, "store checkout", configuredApps(cfg, appNames))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var failed []string
+	for _, appName := range appNames {
+		if m, err := objects.LoadManifest(appName); err == nil && m == nil && len(args) == 0 {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would rebuild missing store files of %s\n", appName)
+			continue
+		}
+
+		rebuilt, err := objects.Checkout(appName)
+		if err != nil {
+			printer.Failure("%s: %v", appName, err)
+			failed = append(failed, appName)
+			continue
+		}
+		printer.Success("%s", messages.Localize(messages.AppFilesRebuilt, messages.Data{"App": appName, "Count": rebuilt}))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to check out %d application(s)", len(failed))
+	}
+	return nil
+}
+
+// storeLockdownCmd represents the store lockdown command
+var storeLockdownCmd = &cobra.Command{
+	Use:   "lockdown",
+	Short: "Make the store read-only and report local changes as drift",
+	Long: `Lock down the store for machines provisioned from a team baseline. The
+checksums of every store file are recorded, every file and directory in the
+store is made read-only, and on macOS flagged immutable, and read_only_store is
+set in the settings.
+
+While the store is locked down:
+  - sync links or copies the store copies into place, but reports paths changed
+    locally as drift instead of moving or copying them into the store; defaults
+    domains, plugin settings, secrets, and git sources are left alone
+  - status always verifies the store against the recorded checksums, so changes
+    made to it anyway are reported
+  - commands that would change the store, such as add, remove, restore, deploy,
+    and gc, refuse to run
+
+Files hard-linked to others, such as the blobs of a content-addressed store,
+are made read-only but not immutable, so their other links can still be deleted. Run 'configsync store
+unlock' to make the store writable again, such as to update the baseline.
+
+Examples:
+  configsync store lockdown
+  configsync status --short   # Exits with 1 when paths or the store drifted`,
+	Args: cobra.NoArgs,
+	RunE: runStoreLockdown,
+}
+
+func runStoreLockdown(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would record the checksums of %d applications' store files\n", len(cfg.Apps))
+		fmt.Printf("[DRY RUN] Would make the store read-only: %s\n", cfg.StorePath)
+		return nil
+	}
+
+	release, err := lockApps(manager, "store lockdown", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !cfg.Settings.StoreReadOnly() {
+		if err := recordStoreChecksums(cfg.StorePath, cfg.Apps); err != nil {
+			return fmt.Errorf("failed to record store checksums: %w", err)
+		}
+	}
+	changed, err := store.Lockdown(cfg.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to lock down the store: %w", err)
+	}
+	cfg.Settings.ReadOnlyStore = true
+	if err := manager.Save(cfg); err != nil {
+		return messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+	recordHistory(history.Entry{Operation: history.StoreLockdown, Paths: []string{cfg.StorePath}})
+
+	printer.Success("%s", messages.Localize(messages.StoreLockedDown, messages.Data{"Path": cfg.StorePath}))
+	fmt.Printf("  Entries made read-only: %d\n", changed)
+	fmt.Println("  Local changes are now reported as drift; run 'configsync store unlock' to change the store")
+	return nil
+}
+
+// storeUnlockCmd represents the store unlock command
+var storeUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Make a locked-down store writable again",
+	Long: `Undo 'configsync store lockdown': clear the immutable flag of every file and
+directory in the store, give each back the mode it had before lockdown, so
+files that were read-only, such as private keys, stay read-only, and clear
+read_only_store in the settings. The next sync moves local changes into the
+store again.
+
+Examples:
+  configsync store unlock`,
+	Args: cobra.NoArgs,
+	RunE: runStoreUnlock,
+}
+
+func runStoreUnlock(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would make the store writable: %s\n", cfg.StorePath)
+		return nil
+	}
+
+	release, err := lockApps(manager, "store unlock", configuredApps(cfg, nil))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	changed, err := store.Unlock(cfg.StorePath)
+	if err != nil {
+		return fmt.Errorf("failed to unlock the store: %w", err)
+	}
+	cfg.Settings.ReadOnlyStore = false
+	if err := manager.Save(cfg); err != nil {
+		return messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+	recordHistory(history.Entry{Operation: history.StoreUnlock, Paths: []string{cfg.StorePath}})
+
+	printer.Success("%s", messages.Localize(messages.StoreUnlocked, messages.Data{"Path": cfg.StorePath}))
+	fmt.Printf("  Entries whose mode was restored: %d\n", changed)
+	return nil
+}
+
+// refuseLockedStore returns an error when the store is locked down, for commands that would
+// change it; action names the command in the message
+func refuseLockedStore(cfg *config.Config, action string) error {
+	if cfg.Settings.StoreReadOnly() {
+		return messages.Error(messages.StoreReadOnly, messages.Data{"Action": action})
+	}
+	return nil
+}
+
+func init() {
+	storeDedupeCmd.Flags().BoolVar(&storeDedupePrune, "prune", false, "delete blobs no file links to anymore")
+	storeMoveCmd.Flags().BoolVar(&storeMoveRemoveOld, "remove-old", false, "delete the old store after a successful move")
+	storeConflictsCmd.Flags().StringVar(&storeConflictsStrategy, "resolve", "", "resolve conflicts with a strategy: keep-original, keep-copy, or keep-newest")
+
+	storeCmd.AddCommand(storeMoveCmd)
+	storeCmd.AddCommand(storeConflictsCmd)
+	storeCmd.AddCommand(storeDedupeCmd)
+	storeCmd.AddCommand(storeCheckoutCmd)
+	storeCmd.AddCommand(storeLockdownCmd)
+	storeCmd.AddCommand(storeUnlockCmd)
+}
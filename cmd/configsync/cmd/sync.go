@@ -2,12 +2,37 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/dotbrains/configsync/internal/backup"
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/notify"
+	"github.com/dotbrains/configsync/internal/permissions"
+	"github.com/dotbrains/configsync/internal/store"
 	"github.com/dotbrains/configsync/internal/symlink"
 	"github.com/spf13/cobra"
 )
 
+var (
+	syncAllowLarge    bool
+	syncExcept        []string
+	syncHeal          bool
+	syncIncludeCaches bool
+	syncNotify        bool
+	syncPeer          string
+	syncWorkers       int
+)
+
 // syncCmd represents the sync command
 var syncCmd = &cobra.Command{
 	Use:   "sync [app1] [app2] ...",
@@ -15,71 +40,244 @@ var syncCmd = &cobra.Command{
 	Long: `Sync application configurations by creating symlinks from their
 original locations to the central store.
 
-If no app names are provided, all managed applications will be synced.
+If no app names are provided, all managed applications will be synced. A name
+starting with @ stands for the applications of a group in the groups section of
+the configuration, and --except leaves applications or groups out.
 
 Examples:
   configsync sync              # Sync all apps
   configsync sync vscode       # Sync only VS Code
-  configsync sync Terminal iTerm2  # Sync multiple specific apps`,
+  configsync sync Terminal iTerm2  # Sync multiple specific apps
+  configsync sync @editors         # Sync the apps in the editors group
+  configsync sync --except xcode   # Sync every app but Xcode
+  configsync sync --allow-large    # Sync directories above the size limit without asking
+  configsync sync --workers 8      # Sync up to 8 apps concurrently
+  configsync sync --heal           # Re-absorb files apps wrote over their symlinks
+  configsync sync --include-caches # Keep cache and log directories when moving into the store
+  configsync sync --peer studio    # Exchange the store with a paired Mac
+
+Directories larger than the configured size limit (1 GB by default) are only
+moved into the store after confirmation. Common cache and log directories
+(Cache, Caches, CachedData, GPUCache, Crashpad, logs, ...) and entries matching
+an app's ignore rules are left out when a directory is moved into the store.
+
+Some applications save their settings by writing a new file and renaming it
+over the old one, replacing the symlink and leaving the store copy out of date
+('configsync status' reports these as replaced_symlink). Sync refuses to touch
+such paths unless --heal is given, in which case the new file is moved into the
+store, the old store copy is archived in the backup directory, and the path is
+relinked.
+
+With --peer, the store is exchanged with a Mac paired with 'configsync pair'
+that is waiting for peers, instead of syncing symlinks. Each file that differs
+is copied from the Mac where it was changed last; replaced versions are
+archived in the backup directory. Files deleted on one Mac are not deleted on
+the other.`,
 	RunE: runSync,
 }
 
 func runSync(_ *cobra.Command, args []string) error {
-	manager := config.NewManager(homeDir)
+	if syncPeer != "" {
+		return runPeerSync(args)
+	}
+	failed, err := syncConfiguredApps(args)
+	if syncNotify && !dryRun && (err != nil || len(failed) > 0) {
+		notifySyncFailure(failed, err)
+	}
+	return err
+}
+
+// syncConfiguredApps syncs the named applications, or all of them, and returns the display names
+// of those that failed to sync
+func syncConfiguredApps(args []string) ([]string, error) {
+	manager := newConfigManager()
 
 	if !manager.ConfigExists() {
-		return fmt.Errorf("ConfigSync is not initialized. Run 'configsync init' first")
+		return nil, messages.Error(messages.NotInitialized, nil)
+	}
+
+	if store.IsLocked(manager.GetConfigDir()) {
+		return nil, messages.Error(messages.StoreBusy, nil)
 	}
 
 	cfg, err := manager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
 	appsToSync, err := selectAppsToSync(cfg, args)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := exceptApps(cfg, appsToSync, syncExcept); err != nil {
+		return nil, err
 	}
 
+	if len(appsToSync) == 0 && len(syncExcept) > 0 {
+		fmt.Println("No applications are left to sync after --except.")
+		return nil, nil
+	}
+	if len(appsToSync) == 0 && len(cfg.Apps) > 0 {
+		fmt.Printf("No applications are used on host %s. See the hosts section of the configuration.\n", config.CurrentHost)
+		return nil, nil
+	}
 	if len(appsToSync) == 0 {
-		fmt.Println("No applications configured. Use 'configsync add <app>' to add applications.")
-		return nil
+		fmt.Println(messages.Localize(messages.NoAppsConfigured, nil))
+		return nil, nil
 	}
 
+	if err := checkSyncCollisions(cfg, appsToSync); err != nil {
+		return nil, err
+	}
+
+	release, err := lockApps(manager, "sync", appNamesOf(appsToSync))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	appsToSync, blocked := checkSyncPermissions(appsToSync)
+	eventEmitter.Emit(events.SyncStarted, "", map[string]interface{}{"apps": appNamesOf(appsToSync)})
+
 	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
-	successful, failed := syncApplications(symlinkManager, appsToSync)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	symlinkManager.SetPlugins(openPlugin)
+	symlinkManager.SetDirectorySizeLimit(cfg.Settings.DirectorySizeLimit(), confirmLargeDirectory)
+	symlinkManager.SetConflictStrategy(cfg.Settings.ConflictStrategy)
+	symlinkManager.SetBackupCompression(cfg.Settings.BackupCompression)
+	symlinkManager.SetAutoBackup(cfg.Settings.AutoBackup)
+	symlinkManager.SetHeal(syncHeal)
+	symlinkManager.SetReadOnly(cfg.Settings.StoreReadOnly())
+	symlinkManager.SetIncludeCaches(syncIncludeCaches)
+	symlinkManager.SetEvents(eventEmitter)
+	symlinkManager.SetContext(runContext)
+	symlinkManager.SetGitSources(newGitSources(manager))
+	undo := captureUndo(manager, history.Sync, appsToSync)
+	deployed := deployedBaselines(cfg.StorePath, appsToSync)
+	successful, failed := syncApplications(symlinkManager, appsToSync, resolveSyncWorkers(cfg.Settings), undo)
+	failed = append(failed, blocked...)
+	if len(successful) > 0 {
+		flushPreferences(cfg, appsToSync)
+	}
+	warnDivergedDeployments(cfg.StorePath, appsToSync, deployed)
 
 	if !dryRun && len(successful) > 0 {
+		refreshAppMetadata(appsToSync)
 		if err := manager.UpdateLastSync(); err != nil {
-			fmt.Printf("Warning: failed to update last sync time: %v\n", err)
+			printer.Warning("%s", messages.Localize(messages.LastSyncUpdateFailed, messages.Data{"Error": err}))
+		}
+	}
+
+	// A locked-down store keeps the checksums recorded by 'configsync store lockdown', so changes
+	// made to it anyway are still reported by 'configsync status'
+	if !dryRun && !cfg.Settings.StoreReadOnly() {
+		if err := recordStoreChecksums(cfg.StorePath, appsToSync); err != nil {
+			printer.Warning("%s", messages.Localize(messages.ChecksumsRecordFailed, messages.Data{"Error": err}))
+		}
+	}
+
+	if !dryRun && cfg.Settings.ContentAddressed() && !cfg.Settings.StoreReadOnly() {
+		result, err := dedupeStore(manager, cfg, enabledApps(appsToSync), false)
+		if err != nil {
+			printer.Warning("%s", messages.Localize(messages.StoreDeduplicateFailed, messages.Data{"Error": err}))
+		} else {
+			printRekeyed(result)
+			if verbose && result.Linked > 0 {
+				fmt.Printf("Linked %d identical store file(s), freeing %s\n", result.Linked, fsutil.FormatSize(result.Saved))
+			}
 		}
 	}
 
+	if !dryRun {
+		refreshStatusCache(manager)
+	}
+
 	showSyncSummary(successful, failed)
+	showSyncBackups(cfg.BackupPath, symlinkManager.CreatedBackups())
+	pruneBackups(backup.NewManager(cfg.BackupPath, homeDir, verbose).WithContext(runContext), cfg, appsToSync)
+	eventEmitter.Emit(events.SyncCompleted, "", map[string]interface{}{
+		"succeeded": append([]string{}, successful...),
+		"failed":    append([]string{}, failed...),
+	})
 
 	if len(failed) > 0 && len(successful) == 0 {
-		return fmt.Errorf("failed to sync any applications")
+		return failed, fmt.Errorf("failed to sync any applications")
+	}
+
+	return failed, nil
+}
+
+// checkSyncPermissions leaves out the enabled applications with paths configsync is not allowed
+// to read, which would otherwise fail partway through being moved into the store. It returns the
+// applications to sync and the display names of those left out.
+func checkSyncPermissions(appsToSync map[string]*config.AppConfig) (map[string]*config.AppConfig, []string) {
+	report := permissions.NewChecker(homeDir).CheckApps(enabledApps(appsToSync))
+	if len(report.Issues) == 0 {
+		return appsToSync, nil
+	}
+
+	printPermissionIssues(report)
+
+	blockedApps := make(map[string]bool)
+	for _, issue := range report.Issues {
+		blockedApps[issue.App] = true
 	}
 
-	return nil
+	allowed := make(map[string]*config.AppConfig)
+	var blocked []string
+	for appName, appConfig := range appsToSync {
+		if blockedApps[appName] {
+			blocked = append(blocked, appConfig.DisplayName)
+			continue
+		}
+		allowed[appName] = appConfig
+	}
+	sort.Strings(blocked)
+	fmt.Printf("Skipping %d application(s) until access is granted.\n\n", len(blocked))
+	return allowed, blocked
 }
 
-// selectAppsToSync determines which applications to sync based on arguments
+// selectAppsToSync determines which applications to sync based on arguments, leaving out those
+// the hosts section excludes from this host. Applications of an @group that are not used on this
+// host are skipped, while naming such an application is an error.
 func selectAppsToSync(cfg *config.Config, args []string) (map[string]*config.AppConfig, error) {
 	if len(args) == 0 {
+		appsToSync := cfg.HostApps(config.CurrentHost)
 		if verbose {
-			fmt.Printf("Syncing all %d configured applications...\n", len(cfg.Apps))
+			fmt.Printf("Syncing all %d configured applications...\n", len(appsToSync))
+			if skipped := len(cfg.Apps) - len(appsToSync); skipped > 0 {
+				fmt.Printf("Skipping %d application(s) not used on host %s\n", skipped, config.CurrentHost)
+			}
 		}
-		return cfg.Apps, nil
+		return appsToSync, nil
 	}
 
+	host := cfg.Host(config.CurrentHost)
 	appsToSync := make(map[string]*config.AppConfig)
 	for _, appName := range args {
-		if app, exists := cfg.Apps[appName]; exists {
-			appsToSync[appName] = app
-		} else {
+		if config.IsGroupName(appName) {
+			members, err := cfg.GroupApps(appName)
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range members {
+				if host.UsesApp(member) {
+					appsToSync[member] = cfg.Apps[member]
+				} else if verbose {
+					fmt.Printf("Skipping %s of %s, which is not used on host %s\n", member, appName, config.CurrentHost)
+				}
+			}
+			continue
+		}
+
+		app, exists := cfg.Apps[appName]
+		if !exists {
 			return nil, fmt.Errorf("application %s is not configured. Use 'configsync add %s' first", appName, appName)
 		}
+		if !host.UsesApp(appName) {
+			return nil, fmt.Errorf("application %s is not used on host %s; see the hosts section of the configuration", appName, config.CurrentHost)
+		}
+		appsToSync[appName] = app
 	}
 	if verbose {
 		fmt.Printf("Syncing %d specified applications...\n", len(appsToSync))
@@ -87,31 +285,181 @@ func selectAppsToSync(cfg *config.Config, args []string) (map[string]*config.App
 	return appsToSync, nil
 }
 
-// syncApplications syncs all provided applications and returns successful and failed lists
-func syncApplications(symlinkManager *symlink.Manager, apps map[string]*config.AppConfig) ([]string, []string) {
+// checkSyncCollisions refuses to sync when the paths of an application being synced collide
+// with another application's, since one would silently overwrite the other in the store
+func checkSyncCollisions(cfg *config.Config, appsToSync map[string]*config.AppConfig) error {
+	var lines []string
+	for _, collision := range cfg.PathCollisions() {
+		for _, appName := range collision.Apps {
+			if app, selected := appsToSync[appName]; selected && app.IsEnabled() {
+				lines = append(lines, collision.String())
+				break
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	return fmt.Errorf("applications claim the same paths, so nothing was synced:\n  - %s\n"+
+		"Give one of them another store destination with 'configsync add <app> --rename-destination <old>=<new>', "+
+		"or edit its destination in the configuration", strings.Join(lines, "\n  - "))
+}
+
+// syncApplications syncs all provided applications concurrently and returns successful and failed lists.
+// The history entries of the applications reference the undo point saved before the sync.
+func syncApplications(symlinkManager *symlink.Manager, apps map[string]*config.AppConfig, workers int, undo string) ([]string, []string) {
 	var successful, failed []string
 
-	for _, appConfig := range apps {
+	showProgress := !verbose && !dryRun && !progressEmitter.Enabled() && isTerminal(os.Stdout)
+
+	progressEmitter.Start("sync", len(apps))
+	results := symlinkManager.SyncApps(apps, workers, func(done, total int, result symlink.AppResult) {
+		progressEmitter.App("sync", result.Name, done, total, result.Err)
+
+		if showProgress {
+			// Clear the progress bar before printing anything else
+			fmt.Print("\r\033[K")
+		}
+
 		if verbose || dryRun {
-			fmt.Printf("\n=== %s ===\n", appConfig.DisplayName)
+			fmt.Printf("\n=== %s ===\n", result.App.DisplayName)
 		}
+		fmt.Print(result.Output)
 
-		if err := symlinkManager.SyncApp(appConfig); err != nil {
+		if result.Err != nil {
 			if verbose {
-				fmt.Printf("✗ Failed to sync %s: %v\n", appConfig.DisplayName, err)
+				printer.Failure("%s", messages.Localize(messages.AppSyncFailed, messages.Data{"App": result.App.DisplayName, "Error": result.Err}))
 			}
-			failed = append(failed, appConfig.DisplayName)
+		} else if verbose || dryRun {
+			printer.Success("%s", messages.Localize(messages.AppSynced, messages.Data{"App": result.App.DisplayName, "Duration": result.Duration.Round(time.Millisecond)}))
+		}
+
+		if showProgress {
+			printSyncProgress(done, total, result.App.DisplayName)
+		}
+	})
+
+	if showProgress {
+		fmt.Print("\r\033[K")
+	}
+
+	var entries []history.Entry
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.App.DisplayName)
 		} else {
-			if verbose || dryRun {
-				fmt.Printf("✓ Successfully synced %s\n", appConfig.DisplayName)
-			}
-			successful = append(successful, appConfig.DisplayName)
+			successful = append(successful, result.App.DisplayName)
+		}
+		if result.App.IsEnabled() {
+			entry := appHistoryEntry(history.Sync, result.Name, result.App, result.Err)
+			entry.Undo = undo
+			entries = append(entries, entry)
 		}
 	}
+	recordHistory(entries...)
+	progressEmitter.Finish("sync", len(successful), len(failed))
 
 	return successful, failed
 }
 
+// refreshAppMetadata records the installed version, bundle path, and icon of the synced
+// applications, which UpdateLastSync saves with the configuration
+func refreshAppMetadata(apps map[string]*config.AppConfig) {
+	detector, err := newAppDetector()
+	if err != nil {
+		printer.Warning("%s", messages.Localize(messages.AppMetadataRefreshFailed, messages.Data{"Error": err}))
+		return
+	}
+	for _, appConfig := range apps {
+		detector.RefreshMetadata(appConfig)
+	}
+}
+
+// deployedBaselines returns the deployments of the applications whose store files still match
+// the bundle they were deployed from, so a sync that changes them can be pointed out
+func deployedBaselines(storePath string, apps map[string]*config.AppConfig) map[string]*deploy.Deployment {
+	baselines := make(map[string]*deploy.Deployment)
+	if dryRun {
+		return baselines
+	}
+	for appName, appConfig := range apps {
+		deployment := deploy.DeploymentOf(appConfig)
+		if deployment == nil {
+			continue
+		}
+		if diverged, err := deployment.Diverged(storePath, appConfig); err == nil && !diverged {
+			baselines[appName] = deployment
+		}
+	}
+	return baselines
+}
+
+// warnDivergedDeployments warns about the applications whose store files matched the bundle
+// they were deployed from before the sync, but no longer do
+func warnDivergedDeployments(storePath string, apps map[string]*config.AppConfig, baselines map[string]*deploy.Deployment) {
+	appNames := make([]string, 0, len(baselines))
+	for appName := range baselines {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		deployment := baselines[appName]
+		if diverged, err := deployment.Diverged(storePath, apps[appName]); err != nil || !diverged {
+			continue
+		}
+		printer.Warning("%s", messages.Localize(messages.AppDiverged, messages.Data{
+			"App":    apps[appName].DisplayName,
+			"Bundle": deploy.ShortHash(deployment.Bundle),
+			"Date":   deployment.DeployedAt.Format("2006-01-02"),
+		}))
+	}
+}
+
+// recordStoreChecksums records the content of the synced applications' store files,
+// so 'configsync status --verify' can later detect changes made outside of configsync
+func recordStoreChecksums(storePath string, apps map[string]*config.AppConfig) error {
+	checksums, err := manifest.LoadChecksums(storePath)
+	if err != nil {
+		return err
+	}
+
+	for _, appConfig := range apps {
+		if !appConfig.IsEnabled() {
+			continue
+		}
+		for _, path := range appConfig.Paths {
+			if err := checksums.Record(path.Destination); err != nil {
+				return err
+			}
+		}
+	}
+
+	return checksums.Save()
+}
+
+// printSyncProgress renders a single-line progress bar
+func printSyncProgress(done, total int, current string) {
+	const width = 30
+	filled := width
+	if total > 0 {
+		filled = done * width / total
+	}
+	fmt.Printf("\rSyncing [%s%s] %d/%d %s", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), done, total, current)
+}
+
+// resolveSyncWorkers determines the number of concurrent sync workers
+func resolveSyncWorkers(settings *config.Settings) int {
+	if syncWorkers > 0 {
+		return syncWorkers
+	}
+	if settings != nil && settings.SyncWorkers > 0 {
+		return settings.SyncWorkers
+	}
+	return runtime.NumCPU()
+}
+
 // showSyncSummary displays the sync results summary
 func showSyncSummary(successful, failed []string) {
 	fmt.Println()
@@ -122,22 +470,22 @@ func showSyncSummary(successful, failed []string) {
 	}
 
 	if len(successful) > 0 {
-		verb := "synced"
+		id := messages.AppsSynced
 		if dryRun {
-			verb = "would be synced"
+			id = messages.AppsWouldBeSynced
 		}
-		fmt.Printf("✓ %d application(s) %s:\n", len(successful), verb)
+		printer.Success("%s", messages.Localize(id, messages.Data{"Count": len(successful)}))
 		for _, name := range successful {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
 	if len(failed) > 0 {
-		verb := "failed to sync"
+		id := messages.AppsSyncFailed
 		if dryRun {
-			verb = "would fail to sync"
+			id = messages.AppsWouldFailToSync
 		}
-		fmt.Printf("\n✗ %d application(s) %s:\n", len(failed), verb)
+		printer.Failure("\n%s", messages.Localize(id, messages.Data{"Count": len(failed)}))
 		for _, name := range failed {
 			fmt.Printf("  - %s\n", name)
 		}
@@ -148,6 +496,57 @@ func showSyncSummary(successful, failed []string) {
 	}
 }
 
+// showSyncBackups reports the backups taken before paths were moved into the store or replaced,
+// listing them in verbose mode
+func showSyncBackups(backupPath string, backups []*config.BackupInfo) {
+	if len(backups) == 0 {
+		return
+	}
+	fmt.Printf("\nBacked up %d path(s) to %s before changing them\n", len(backups), backupPath)
+	if verbose {
+		for _, info := range backups {
+			fmt.Printf("  - %s: %s\n", info.AppName, info.OriginalPath)
+		}
+	}
+}
+
+// notifySyncFailure reports a failed sync with the configured desktop notifications and webhook
+func notifySyncFailure(failed []string, syncErr error) {
+	var settings config.Notifications
+	if cfg, err := newConfigManager().Load(); err == nil {
+		settings = cfg.Settings.NotificationSettings()
+	}
+
+	n := notify.Notification{Event: "sync_failed", Title: "ConfigSync sync failed", Failed: failed}
+	if len(failed) > 0 {
+		n.Message = fmt.Sprintf("%d application(s) failed to sync: %s", len(failed), strings.Join(failed, ", "))
+	} else {
+		n.Message = syncErr.Error()
+	}
+
+	if err := notify.NewManager(settings.Desktop, settings.Webhook).Send(n); err != nil {
+		printer.Warning("%v", err)
+	}
+}
+
+// confirmLargeDirectory decides whether a directory above the size limit may be moved into the store
+func confirmLargeDirectory(path string, size int64) bool {
+	if syncAllowLarge {
+		return true
+	}
+	question := fmt.Sprintf("%s is %s. Move it into the store anyway?", path, fsutil.FormatSize(size))
+	if progressEmitter.Enabled() {
+		return progressEmitter.Confirm("allow-large", question)
+	}
+	return promptYesNo(question)
+}
+
 func init() {
-	// No additional flags needed - uses global dry-run and verbose flags
+	syncCmd.Flags().IntVarP(&syncWorkers, "workers", "j", 0, "number of apps to sync concurrently (default: sync_workers setting or CPU count)")
+	syncCmd.Flags().StringSliceVar(&syncExcept, "except", nil, "applications or @groups to leave out (repeatable)")
+	syncCmd.Flags().BoolVar(&syncAllowLarge, "allow-large", false, "sync directories larger than the size limit without confirmation")
+	syncCmd.Flags().BoolVar(&syncHeal, "heal", false, "move files that apps wrote in place of their symlinks into the store and relink them")
+	syncCmd.Flags().BoolVar(&syncNotify, "notify", false, "report failures with a desktop notification and the configured webhook, for unattended runs")
+	syncCmd.Flags().StringVar(&syncPeer, "peer", "", "exchange the store with a paired Mac, by name or address")
+	syncCmd.Flags().BoolVar(&syncIncludeCaches, "include-caches", false, "move cache and log directories into the store instead of leaving them out")
 }
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/defaults"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/spf13/cobra"
+)
+
+var (
+	systemGroups []string
+	systemYes    bool
+)
+
+// systemCmd represents the system command
+var systemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "Capture and apply macOS system settings",
+	Long: `Capture a curated set of macOS system settings (Dock, Finder, keyboard,
+and trackpad) with 'defaults read' and keep them as declarative YAML in the
+store at System/settings.yaml, then apply them on another Mac.
+
+The settings file can be edited by hand; 'configsync system list' shows the
+settings that can be used.
+
+Examples:
+  configsync system capture                 # Capture all groups
+  configsync system capture --groups dock   # Capture only the Dock settings
+  configsync system diff                    # Preview what apply would change
+  configsync system apply                   # Apply the stored settings after confirmation`,
+}
+
+// systemCaptureCmd represents the system capture command
+var systemCaptureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "Capture the current system settings into the store",
+	Long: `Read the curated system settings of this Mac and write them to the settings
+file in the store. Groups that are not captured are kept as they are in the file.
+Settings that were never changed from the macOS default are left out.`,
+	Args: cobra.NoArgs,
+	RunE: runSystemCapture,
+}
+
+// systemDiffCmd represents the system diff command
+var systemDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how the stored settings differ from this system",
+	Long:  `Compare the settings file in the store with this Mac and list each setting that 'configsync system apply' would change.`,
+	Args:  cobra.NoArgs,
+	RunE:  runSystemDiff,
+}
+
+// systemApplyCmd represents the system apply command
+var systemApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply the stored settings to this system",
+	Long: `Show the settings that differ from the settings file, then write them with
+'defaults write' after confirmation. Dock and Finder are restarted so that
+their changes take effect; keyboard and trackpad changes may need a new login.`,
+	Args: cobra.NoArgs,
+	RunE: runSystemApply,
+}
+
+// systemListCmd represents the system list command
+var systemListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the system settings that can be captured",
+	Long:  `List the curated system settings by group, with their type and what they control.`,
+	Args:  cobra.NoArgs,
+	RunE:  runSystemList,
+}
+
+func runSystemCapture(_ *cobra.Command, _ []string) error {
+	settingsPath, err := systemSettingsPath()
+	if err != nil {
+		return err
+	}
+	if cfg, err := newConfigManager().Load(); err == nil {
+		if err := refuseLockedStore(cfg, "system capture"); err != nil {
+			return err
+		}
+	}
+
+	settings, err := defaults.NewManager(verbose).CaptureSystemSettings(systemGroups)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, values := range settings {
+		count += len(values)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would capture %d system setting(s) to %s\n", count, settingsPath)
+		printSystemSettings(settings)
+		return nil
+	}
+
+	if err := defaults.SaveSystemSettings(settingsPath, settings); err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.SystemSettingsCaptured, messages.Data{"Count": count, "Path": settingsPath}))
+	if verbose {
+		printSystemSettings(settings)
+	}
+	return nil
+}
+
+func runSystemDiff(_ *cobra.Command, _ []string) error {
+	changes, err := loadSystemChanges()
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printStructured(systemChangeResults(changes))
+	}
+
+	printSystemChanges(changes)
+	return nil
+}
+
+func runSystemApply(_ *cobra.Command, _ []string) error {
+	changes, err := loadSystemChanges()
+	if err != nil {
+		return err
+	}
+
+	printSystemChanges(changes)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would apply %d system setting(s)\n", len(changes))
+		return nil
+	}
+
+	if !systemYes {
+		question := fmt.Sprintf("Apply %d system setting(s)?", len(changes))
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("system-apply", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "apply"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; no settings were changed")
+			return nil
+		}
+	}
+
+	if err := defaults.NewManager(verbose).ApplySystemSettings(changes); err != nil {
+		return err
+	}
+
+	printer.Success("%s", messages.Localize(messages.SystemSettingsApplied, messages.Data{"Count": len(changes)}))
+	fmt.Println("  Some keyboard and trackpad settings take effect after logging out and back in")
+	return nil
+}
+
+func runSystemList(_ *cobra.Command, _ []string) error {
+	if structuredOutput() {
+		return printStructured(defaults.CuratedSettings)
+	}
+
+	for _, group := range defaults.SystemGroups() {
+		fmt.Printf("%s:\n", group)
+		for _, setting := range defaults.CuratedSettings {
+			if setting.Group == group {
+				fmt.Printf("  %-38s %-7s %s\n", setting.Key, setting.Type, setting.Description)
+			}
+		}
+	}
+	return nil
+}
+
+// systemSettingsPath returns the path of the settings file in the store, checking that
+// ConfigSync is initialized and that this is a Mac
+func systemSettingsPath() (string, error) {
+	if config.CurrentPlatform != config.PlatformDarwin {
+		return "", fmt.Errorf("system settings are only available on macOS")
+	}
+
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return "", messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return "", messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	return filepath.Join(cfg.StorePath, defaults.SystemSettingsFile), nil
+}
+
+// loadSystemChanges compares the settings file in the store with this system
+func loadSystemChanges() ([]defaults.SettingChange, error) {
+	settingsPath, err := systemSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no system settings in the store. Run 'configsync system capture' first")
+	}
+
+	settings, err := defaults.LoadSystemSettings(settingsPath)
+	if err != nil {
+		return nil, err
+	}
+	return defaults.NewManager(verbose).DiffSystemSettings(settings)
+}
+
+// printSystemSettings lists captured settings by group
+func printSystemSettings(settings defaults.SystemSettings) {
+	for _, group := range defaults.SystemGroups() {
+		values := settings[group]
+		if len(values) == 0 {
+			continue
+		}
+		fmt.Printf("  %s:\n", group)
+		for _, setting := range defaults.CuratedSettings {
+			if value, ok := values[setting.Key]; ok && setting.Group == group {
+				fmt.Printf("    %s: %s\n", setting.Key, defaults.FormatValue(value))
+			}
+		}
+	}
+}
+
+// printSystemChanges shows the differences between the settings file and this system
+func printSystemChanges(changes []defaults.SettingChange) {
+	if len(changes) == 0 {
+		printer.Success("%s", messages.Localize(messages.SystemSettingsMatch, nil))
+		return
+	}
+
+	fmt.Printf("%d system setting(s) differ from the store:\n", len(changes))
+	for _, change := range changes {
+		setting := change.Setting
+		fmt.Printf("  %s.%s: %s -> %s\n", setting.Group, setting.Key, defaults.FormatValue(change.Current), defaults.FormatValue(change.Desired))
+	}
+}
+
+// systemChangeResult is the structured form of a system setting change
+type systemChangeResult struct {
+	Current any    `json:"current" yaml:"current"`
+	Desired any    `json:"desired" yaml:"desired"`
+	Group   string `json:"group" yaml:"group"`
+	Key     string `json:"key" yaml:"key"`
+	Domain  string `json:"domain" yaml:"domain"`
+}
+
+// systemChangeResults converts changes to their structured form
+func systemChangeResults(changes []defaults.SettingChange) []systemChangeResult {
+	results := []systemChangeResult{}
+	for _, change := range changes {
+		results = append(results, systemChangeResult{
+			Current: change.Current,
+			Desired: change.Desired,
+			Group:   change.Setting.Group,
+			Key:     change.Setting.Key,
+			Domain:  change.Setting.Domain,
+		})
+	}
+	return results
+}
+
+func init() {
+	systemCaptureCmd.Flags().StringSliceVar(&systemGroups, "groups", []string{}, "comma-separated list of groups to capture: dock, finder, keyboard, trackpad (default: all)")
+	systemApplyCmd.Flags().BoolVarP(&systemYes, "yes", "y", false, "apply without asking for confirmation")
+
+	systemCmd.AddCommand(systemCaptureCmd)
+	systemCmd.AddCommand(systemDiffCmd)
+	systemCmd.AddCommand(systemApplyCmd)
+	systemCmd.AddCommand(systemListCmd)
+}
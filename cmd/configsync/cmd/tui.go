@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse and manage applications interactively",
+	Long: `Open an interactive terminal interface listing the managed applications
+with their sync state and any cloud sync conflicts.
+
+Keys:
+  ↑/↓ or j/k   Move between applications
+  enter        Show the paths of an application and their sync state
+  space        Enable or disable an application
+  s            Sync the application
+  r            Restore the application from its latest backup
+  b            Browse the backups of the application and restore one
+  esc          Go back
+  q            Quit`,
+	Args: cobra.NoArgs,
+	RunE: runTUI,
+}
+
+func runTUI(_ *cobra.Command, _ []string) error {
+	if !isInteractive() || !isTerminal(os.Stdout) {
+		return fmt.Errorf("the tui command needs a terminal; use 'configsync status' in scripts")
+	}
+
+	manager := newConfigManager()
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	model, err := tui.NewModel(&tuiHandler{manager: manager})
+	if err != nil {
+		return err
+	}
+
+	return tui.Run(tui.NewTerminal(os.Stdin, stdinReader, os.Stdout), model)
+}
+
+// tuiHandler connects the interactive interface to the configuration and the sync and restore commands
+type tuiHandler struct {
+	manager *config.Manager
+}
+
+// Load builds the interface state from the status report
+func (h *tuiHandler) Load() (*tui.State, error) {
+	cfg, err := h.manager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	report := buildStatusReport(cfg, h.manager.ConfigPath())
+	state := &tui.State{
+		StorePath:     report.StorePath,
+		CloudProvider: report.CloudProvider,
+	}
+
+	for _, appStatus := range report.Apps {
+		app := tui.App{
+			Name:        appStatus.Name,
+			DisplayName: appStatus.DisplayName,
+			Synced:      appStatus.Synced,
+			Enabled:     appStatus.Enabled,
+		}
+		for _, path := range appStatus.Paths {
+			app.Paths = append(app.Paths, tui.Path{Source: path.Source, Destination: path.Destination, Status: path.Status})
+
+			// Conflicts are reported for the whole store; attribute them to the app owning the path
+			storePath := filepath.Join(report.StorePath, path.Destination)
+			for _, conflict := range report.Conflicts {
+				if conflict.Original == storePath || strings.HasPrefix(conflict.Original, storePath+string(filepath.Separator)) {
+					rel, _ := filepath.Rel(report.StorePath, conflict.Path)
+					app.Conflicts = append(app.Conflicts, rel)
+				}
+			}
+		}
+		state.Apps = append(state.Apps, app)
+	}
+
+	return state, nil
+}
+
+// SetEnabled enables or disables an application in the configuration
+func (h *tuiHandler) SetEnabled(appName string, enabled bool) error {
+	if dryRun {
+		return fmt.Errorf("[DRY RUN] would set %s enabled to %t", appName, enabled)
+	}
+	cfg, err := h.manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	operation := history.Disable
+	if enabled {
+		operation = history.Enable
+	}
+	undo := captureAppUndo(h.manager, operation, appName, cfg.Apps[appName])
+	err = h.manager.SetAppEnabled(appName, enabled)
+	entry := appHistoryEntry(operation, appName, cfg.Apps[appName], err)
+	entry.Undo = undo
+	recordHistory(entry)
+	return err
+}
+
+// Backups lists the backups of an application, newest first
+func (h *tuiHandler) Backups(appName string) ([]tui.Backup, error) {
+	cfg, err := h.manager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	infos, err := backup.NewManager(cfg.BackupPath, homeDir, verbose).ListBackups(appName)
+	if err != nil {
+		return nil, err
+	}
+	backup.SortNewestFirst(infos)
+
+	var backups []tui.Backup
+	for _, info := range infos {
+		backups = append(backups, tui.Backup{
+			CreatedAt: info.CreatedAt,
+			Source:    info.OriginalPath,
+			Version:   info.Version,
+			Size:      info.Size,
+		})
+	}
+	return backups, nil
+}
+
+// Sync syncs an application as 'configsync sync <app>' does
+func (h *tuiHandler) Sync(appName string) error {
+	return runSync(nil, []string{appName})
+}
+
+// Restore restores an application from its latest backups, or a single path from the given backup
+func (h *tuiHandler) Restore(appName string, selected *tui.Backup) error {
+	_, cfg, backupManager, err := initializeRestoreComponents()
+	if err != nil {
+		return err
+	}
+	if err := refuseLockedStore(cfg, "restore"); err != nil {
+		return err
+	}
+
+	appConfig, exists := cfg.Apps[appName]
+	if !exists {
+		return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+	}
+
+	if selected == nil {
+		successful, failed := restoreApplications([]string{appName}, cfg, backupManager)
+		showRestoreResults(successful, failed)
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to restore %s", appConfig.DisplayName)
+		}
+		return nil
+	}
+
+	for _, path := range appConfig.Paths {
+		if expandHome(path.Source) != selected.Source {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would restore %s from version %s\n", selected.Source, selected.Version)
+			return nil
+		}
+		undo := captureAppUndo(h.manager, history.Restore, appName, &config.AppConfig{Name: appName, Paths: []config.Path{path}})
+		err := backupManager.RestorePathVersion(appName, &path, selected.Version)
+		entry := appHistoryEntry(history.Restore, appName, nil, err)
+		entry.Paths = []string{path.Source}
+		entry.Details = map[string]string{"version": selected.Version}
+		entry.Undo = undo
+		recordHistory(entry)
+		if err != nil {
+			return err
+		}
+		printer.Success("%s", messages.Localize(messages.Restored, messages.Data{"Name": selected.Source}))
+		return nil
+	}
+
+	return fmt.Errorf("%s is no longer configured for %s", selected.Source, appConfig.DisplayName)
+}
+
+// expandHome expands a leading ~/ to the home directory, as backups record their original paths
+func expandHome(path string) string {
+	return fsops.ExpandHome(path, homeDir)
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// undoSnapshotPrefix marks undo references that name the snapshot saved before a snapshot restore
+const undoSnapshotPrefix = "snapshot:"
+
+var undoYes bool
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the most recent operation that changed managed configurations",
+	Long: `Revert the most recent operation in the history, such as an accidental remove
+or an unwanted deploy, restoring the symlinks, store content, and configuration
+entries of the applications it changed.
+
+Before every add, sync, restore, deploy, remove, enable, and disable, the state
+of the affected applications is saved in ~/.configsync/undo; the last 20 of
+these undo points are kept. Running undo again reverts the operation before
+that. Files the undo replaces are moved to the backup directory rather than
+deleted.
+
+Store moves and peer syncs cannot be undone; move the store back with
+'configsync store move', or restore a snapshot instead.
+
+Examples:
+  configsync undo --dry-run  # Show what would be reverted
+  configsync undo
+  configsync undo --yes`,
+	Args: cobra.NoArgs,
+	RunE: runUndo,
+}
+
+func runUndo(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	if err := refuseLockedStore(cfg, "undo"); err != nil {
+		return err
+	}
+
+	entries, err := history.Open(manager.GetConfigDir()).Read(history.Filter{})
+	if err != nil {
+		return err
+	}
+	operation, err := lastUndoableOperation(entries)
+	if err != nil {
+		return err
+	}
+	description := describeOperation(operation)
+
+	if !dryRun && !undoYes {
+		question := fmt.Sprintf("Undo %s?", description)
+		var accepted bool
+		if progressEmitter.Enabled() {
+			accepted = progressEmitter.Confirm("undo", question)
+		} else {
+			if !isInteractive() {
+				return messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "undo"})
+			}
+			accepted = promptYesNo(question)
+		}
+		if !accepted {
+			fmt.Println("Cancelled; nothing was undone")
+			return nil
+		}
+	}
+
+	apps := make([]string, 0, len(operation))
+	for _, entry := range operation {
+		if entry.App != "" {
+			apps = append(apps, entry.App)
+		}
+	}
+	release, err := lockApps(manager, "undo", apps)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ref := operation[0].Undo
+	if id, ok := strings.CutPrefix(ref, undoSnapshotPrefix); ok {
+		return undoSnapshotRestore(manager, operation, id, description)
+	}
+
+	result, err := store.NewUndoer(homeDir, manager.GetConfigDir(), verbose).Revert(manager, ref, dryRun)
+	if err != nil {
+		recordUndo(operation, err)
+		return fmt.Errorf("failed to undo %s: %w", description, err)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would undo %s\n", description)
+		for _, change := range result.Changes {
+			fmt.Printf("[DRY RUN] %s would be %s\n", change.Path, change.Action)
+		}
+		if len(result.Changes) == 0 {
+			fmt.Println("[DRY RUN] Nothing has changed since the operation")
+		}
+		return nil
+	}
+
+	if len(result.Failed) > 0 {
+		err = fmt.Errorf("failed to undo changes to %s", strings.Join(result.Failed, ", "))
+	}
+	recordUndo(operation, err)
+	finishUndo(manager, result.Point.Apps)
+
+	printer.Success("%s", messages.Localize(messages.Undone, messages.Data{"Operation": description}))
+	for _, change := range result.Changes {
+		fmt.Printf("  - %s %s\n", change.Path, change.Action)
+	}
+	if result.ArchiveDir != "" {
+		fmt.Printf("\nReplaced files were moved to %s\n", result.ArchiveDir)
+	}
+	return err
+}
+
+// undoSnapshotRestore undoes a snapshot restore by restoring the snapshot saved before it
+func undoSnapshotRestore(manager *config.Manager, operation []history.Entry, id, description string) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would undo %s by restoring snapshot %s, saved before it\n", description, id)
+		return nil
+	}
+
+	result, err := store.NewSnapshotter(homeDir, manager.GetConfigDir(), verbose).Restore(manager, id)
+	recordUndo(operation, err)
+	if err != nil {
+		return fmt.Errorf("failed to undo %s: %w", description, err)
+	}
+
+	finishUndo(manager, result.Snapshot.Apps)
+	printer.Success("%s", messages.Localize(messages.UndoneFromSnapshot, messages.Data{"Operation": description, "Name": id}))
+	return nil
+}
+
+// lastUndoableOperation returns the history entries of the most recent operation that has not been
+// undone; an operation that changed several applications has an entry for each of them
+func lastUndoableOperation(entries []history.Entry) ([]history.Entry, error) {
+	undone := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Operation == history.Undo {
+			if entry.Error == "" {
+				undone[entry.Details["undone"]] = true
+			}
+			continue
+		}
+		if entry.Undo != "" && undone[entry.Undo] {
+			continue
+		}
+		if entry.Undo == "" {
+			return nil, fmt.Errorf("the most recent operation, %s, cannot be undone", describeOperation([]history.Entry{entry}))
+		}
+
+		var operation []history.Entry
+		for _, other := range entries[:i+1] {
+			if other.Undo == entry.Undo {
+				operation = append(operation, other)
+			}
+		}
+		return operation, nil
+	}
+	return nil, fmt.Errorf("there are no operations to undo")
+}
+
+// describeOperation names an operation, the applications it changed, and when it ran
+func describeOperation(operation []history.Entry) string {
+	var apps []string
+	for _, entry := range operation {
+		if entry.App != "" {
+			apps = append(apps, entry.App)
+		}
+	}
+
+	last := operation[len(operation)-1]
+	description := last.Operation
+	switch {
+	case len(apps) == 1:
+		description += " of " + apps[0]
+	case len(apps) > 1:
+		description += fmt.Sprintf(" of %d applications", len(apps))
+	}
+	return description + " at " + last.Time.Local().Format("2006-01-02 15:04:05")
+}
+
+// recordUndo adds an undo to the history, with an entry for each application of the undone operation
+func recordUndo(operation []history.Entry, err error) {
+	entries := make([]history.Entry, 0, len(operation))
+	for _, undone := range operation {
+		entry := history.Entry{
+			Operation: history.Undo,
+			App:       undone.App,
+			Paths:     undone.Paths,
+			Details:   map[string]string{"operation": undone.Operation, "undone": undone.Undo},
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entries = append(entries, entry)
+	}
+	recordHistory(entries...)
+}
+
+// finishUndo records the reverted store content, so the next sync does not mistake it for changes
+// made elsewhere, and reports the undo to event listeners
+func finishUndo(manager *config.Manager, appNames []string) {
+	cfg, err := manager.Load()
+	if err != nil {
+		printer.Warning("%v", messages.Wrap(messages.ConfigLoadFailed, nil, err))
+		return
+	}
+
+	apps := make(map[string]*config.AppConfig)
+	for _, appName := range appNames {
+		if appConfig, exists := cfg.Apps[appName]; exists {
+			apps[appName] = appConfig
+		}
+	}
+	if err := recordStoreChecksums(cfg.StorePath, apps); err != nil {
+		printer.Warning("%s", messages.Localize(messages.ChecksumsRecordFailed, messages.Data{"Error": err}))
+	}
+	eventEmitter.Emit(events.RestorePerformed, "", map[string]interface{}{"source": "undo", "apps": appNames})
+}
+
+// captureUndo saves the state of applications before an operation changes them and returns the
+// undo point to record with the operation in the history. Nothing is captured when the history
+// is not recorded, as in dry runs, and a failure to capture only warns.
+func captureUndo(manager *config.Manager, operation string, apps map[string]*config.AppConfig) string {
+	if historyJournal == nil || len(apps) == 0 {
+		return ""
+	}
+
+	point, err := store.NewUndoer(homeDir, manager.GetConfigDir(), verbose).Capture(manager, operation, apps)
+	if err != nil {
+		printer.Warning("%s", messages.Localize(messages.UndoPointFailed, messages.Data{"Operation": operation, "Error": err}))
+		return ""
+	}
+	return point.ID
+}
+
+// captureAppUndo saves the state of a single application before an operation changes it
+func captureAppUndo(manager *config.Manager, operation, appName string, appConfig *config.AppConfig) string {
+	return captureUndo(manager, operation, map[string]*config.AppConfig{appName: appConfig})
+}
+
+func init() {
+	undoCmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "undo without asking for confirmation")
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/api"
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/deploy"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/lock"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/peer"
+	"github.com/dotbrains/configsync/internal/plugins"
+	"github.com/dotbrains/configsync/internal/statuscache"
+	"github.com/dotbrains/configsync/internal/store"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/dotbrains/configsync/pkg/apps"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uninstallFromBackups bool
+	uninstallYes         bool
+)
+
+// uninstallCmd represents the uninstall command
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop using ConfigSync and put every configuration back in place",
+	Long: `Stop using ConfigSync: unsync every application, copying its configuration
+back from the store to where the application expects it, remove the scheduled
+sync, and delete the ConfigSync directory (~/.configsync) with its store,
+backups, and history.
+
+With --from-backups, each path is restored from its latest backup instead, as
+it was before ConfigSync managed it; paths without a backup get the store copy.
+
+A store or backup directory kept outside the ConfigSync directory, such as a
+store in iCloud Drive shared with other Macs, is left in place. If any
+application fails to unsync, nothing is deleted. Only the files ConfigSync
+creates are deleted: when CONFIGSYNC_CONFIG places the configuration in a
+directory that holds other files, or in the home directory, uninstall refuses
+to run.
+
+Use --dry-run to preview every step. Uninstall asks for confirmation unless
+--yes is given.
+
+Examples:
+  configsync uninstall --dry-run
+  configsync uninstall
+  configsync uninstall --from-backups --yes`,
+	Args: cobra.NoArgs,
+	RunE: runUninstall,
+}
+
+func init() {
+	uninstallCmd.Flags().BoolVar(&uninstallFromBackups, "from-backups", false, "restore each path from its latest backup instead of the store")
+	uninstallCmd.Flags().BoolVar(&uninstallYes, "yes", false, "uninstall without asking for confirmation")
+}
+
+func runUninstall(_ *cobra.Command, _ []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "uninstall"); err != nil {
+		return err
+	}
+
+	if _, err := ownedEntries(manager, cfg); err != nil {
+		return err
+	}
+
+	appNames := appNamesOf(cfg.Apps)
+	kept := keptOutsideConfigDir(manager.GetConfigDir(), cfg)
+	scheduled := false
+	schedulerManager, schedulerErr := newSchedulerManager()
+	if schedulerErr == nil {
+		if status, err := schedulerManager.Status(); err == nil && status.Installed {
+			scheduled = true
+		}
+	}
+
+	if dryRun {
+		fmt.Println("[DRY RUN] Uninstalling ConfigSync would:")
+	} else {
+		showUninstallPlan(manager.GetConfigDir(), cfg, appNames, scheduled, kept)
+		if !uninstallYes {
+			if !isInteractive() {
+				return fmt.Errorf("uninstall asks for confirmation; use --yes to uninstall without a terminal")
+			}
+			if !promptYesNo("\nUninstall ConfigSync?") {
+				fmt.Println("Cancelled")
+				return nil
+			}
+		}
+	}
+
+	release, err := lockApps(manager, "uninstall", appNames)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Put every configuration back first, so nothing is deleted while it still lives in the store
+	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	symlinkManager.SetPlugins(openPlugin)
+	symlinkManager.SetContext(runContext)
+	backupManager := backup.NewManager(cfg.BackupPath, homeDir, verbose).WithContext(runContext)
+	var failed []string
+	for _, appName := range appNames {
+		if err := uninstallApp(symlinkManager, backupManager, appName, cfg.Apps[appName]); err != nil {
+			printer.Failure("%s: %v", cfg.Apps[appName].DisplayName, err)
+			failed = append(failed, appName)
+		}
+	}
+	flushPreferences(cfg, cfg.Apps)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to unsync %s; nothing was deleted, so fix the problem and run uninstall again", strings.Join(failed, ", "))
+	}
+
+	if scheduled {
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would unload and remove %s\n", schedulerManager.PlistPath())
+		} else if err := schedulerManager.Remove(); err != nil {
+			printer.Warning("%s", messages.Localize(messages.ScheduleRemoveFailed, messages.Data{"Error": err}))
+		}
+	}
+
+	if dryRun {
+		size, _ := fsutil.Size(manager.GetConfigDir())
+		fmt.Printf("[DRY RUN] Would delete %s (%s)\n", manager.GetConfigDir(), fsutil.FormatSize(size))
+		for _, dir := range kept {
+			fmt.Printf("[DRY RUN] Would keep %s\n", dir)
+		}
+		return nil
+	}
+
+	// Listed again, since unsyncing adds entries such as locks
+	entries, err := ownedEntries(manager, cfg)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(entry); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", entry, err)
+		}
+	}
+	// Only an empty directory is removed, in case files appeared in it while uninstalling
+	_ = os.Remove(manager.GetConfigDir())
+
+	printer.Success("%s", messages.Localize(messages.Uninstalled, messages.Data{"Count": len(appNames)}))
+	for _, dir := range kept {
+		fmt.Printf("  Kept %s\n", dir)
+	}
+	fmt.Println("\nRemove the configsync binary with the tool you installed it with, e.g. brew uninstall configsync")
+	return nil
+}
+
+// uninstallApp puts an application's configuration back in place: the store copy, or with
+// --from-backups the latest backup of each path that has one
+func uninstallApp(symlinkManager *symlink.Manager, backupManager *backup.Manager, appName string, appConfig *config.AppConfig) error {
+	if err := symlinkManager.UnsyncApp(appConfig); err != nil {
+		return err
+	}
+	if !uninstallFromBackups {
+		return nil
+	}
+
+	for _, path := range appConfig.Paths {
+		if !path.AppliesTo(config.CurrentPlatform) || path.IsExported() || path.IsSecret() {
+			continue
+		}
+		versions, err := backupManager.ListVersions(appName, &path)
+		if err != nil {
+			return err
+		}
+		if !hasRestorableVersion(versions) {
+			if verbose {
+				fmt.Printf("  No backup of %s; keeping the store copy\n", path.Source)
+			}
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would restore %s from its latest backup\n", path.Source)
+			continue
+		}
+		if err := backupManager.RestorePath(appName, &path); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path.Source, err)
+		}
+	}
+	return nil
+}
+
+// hasRestorableVersion reports whether any backup version would be restored as the latest
+func hasRestorableVersion(versions []*config.BackupInfo) bool {
+	for _, version := range versions {
+		if version.Reason != backup.ReasonPreRestore {
+			return true
+		}
+	}
+	return false
+}
+
+// showUninstallPlan lists what uninstall is about to do
+func showUninstallPlan(configDir string, cfg *config.Config, appNames []string, scheduled bool, kept []string) {
+	fmt.Println("Uninstalling ConfigSync will:")
+	source := "the store"
+	if uninstallFromBackups {
+		source = "their latest backups, or the store when they have none"
+	}
+	fmt.Printf("  - Put the configuration of %d application(s) back in place from %s\n", len(appNames), source)
+	if verbose {
+		for _, appName := range appNames {
+			fmt.Printf("      %s\n", cfg.Apps[appName].DisplayName)
+		}
+	}
+	if scheduled {
+		fmt.Println("  - Remove the scheduled sync")
+	}
+	fmt.Printf("  - Delete %s, including its store, backups, and history\n", configDir)
+	for _, dir := range kept {
+		fmt.Printf("  - Keep %s, which is outside it\n", dir)
+	}
+}
+
+// configDirEntries are the files and directories ConfigSync keeps in its directory, besides the
+// configuration file and a store or backup directory placed there under another name
+var configDirEntries = []string{
+	config.DefaultAppsDir, config.DefaultStoreDir, config.DefaultBackupDir, config.DefaultLogDir,
+	lock.DirName, history.FileName, statuscache.FileName, store.LockFileName,
+	store.UndoDir, store.SnapshotDir, store.CheckpointDir,
+	deploy.LayersDir, deploy.ParentRecordFile, deploy.DownloadsDir, deploy.MergeBaseDir, "import", "keys",
+	gitsource.DefaultDir, api.TokenFile, peer.DirName, plugins.DirName,
+	apps.CatalogDirName, apps.ScanCacheFileName, localesDir,
+}
+
+// ownedEntries returns the entries of the configuration directory that uninstall deletes. Since
+// CONFIGSYNC_CONFIG can place the configuration file in any directory, it refuses to go on when
+// that directory is the home directory, contains it, or holds files ConfigSync did not create,
+// rather than deleting files the user keeps there.
+func ownedEntries(manager *config.Manager, cfg *config.Config) ([]string, error) {
+	configDir := filepath.Clean(manager.GetConfigDir())
+	if rel, err := filepath.Rel(configDir, filepath.Clean(homeDir)); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("refusing to uninstall from %s, which holds the home directory; delete ConfigSync's files there yourself", configDir)
+	}
+
+	owned := make(map[string]bool)
+	for _, name := range configDirEntries {
+		owned[name] = true
+	}
+	for _, path := range []string{manager.ConfigPath(), cfg.StorePath, cfg.BackupPath} {
+		if filepath.Dir(filepath.Clean(path)) == configDir {
+			owned[filepath.Base(path)] = true
+		}
+	}
+
+	dirEntries, err := os.ReadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+	var entries, unknown []string
+	for _, entry := range dirEntries {
+		if !owned[entry.Name()] {
+			unknown = append(unknown, entry.Name())
+			continue
+		}
+		entries = append(entries, filepath.Join(configDir, entry.Name()))
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("refusing to uninstall: %s holds files ConfigSync did not create (%s); move them elsewhere or delete them, then run uninstall again",
+			configDir, strings.Join(unknown, ", "))
+	}
+	return entries, nil
+}
+
+// keptOutsideConfigDir returns the store and backup directories that are not inside the
+// configuration directory, which uninstall leaves in place
+func keptOutsideConfigDir(configDir string, cfg *config.Config) []string {
+	var kept []string
+	for _, dir := range []string{cfg.StorePath, cfg.BackupPath} {
+		rel, err := filepath.Rel(configDir, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			kept = append(kept, dir)
+		}
+	}
+	return kept
+}
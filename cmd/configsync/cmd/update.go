@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/spf13/cobra"
+)
+
+var updateForce bool
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use:   "update [app...]",
+	Short: "Pull upstream changes into paths checked out from git repositories",
+	Long: `Pull the upstream changes of the git repositories that paths are checked out
+from, and bring their store copies up to date. Since the paths are symlinked to
+their store copies, the applications see the changes right away.
+
+A path is checked out from a repository when it has a repo, such as
+github.com/me/dotfiles//nvim for the nvim directory of a dotfiles repository,
+and optionally a ref naming the branch or tag to check out. 'configsync sync'
+makes the first checkout.
+
+Changes belong in the repository rather than the store: a store copy changed
+since it was checked out is not updated, unless --force discards the changes.
+
+Examples:
+  configsync update
+  configsync update neovim
+  configsync update --dry-run
+  configsync update neovim --force`,
+	RunE: runUpdate,
+}
+
+// updateReport is the structured result of the update command
+type updateReport struct {
+	Paths  []updatedPath `json:"paths" yaml:"paths"`
+	Failed []string      `json:"failed,omitempty" yaml:"failed,omitempty"`
+}
+
+// updatedPath describes the update of one path checked out from a repository
+type updatedPath struct {
+	App      string `json:"app" yaml:"app"`
+	Path     string `json:"path" yaml:"path"`
+	Repo     string `json:"repo" yaml:"repo"`
+	Revision string `json:"revision" yaml:"revision"`
+	Pulled   int    `json:"pulled" yaml:"pulled"`
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "discard changes made to store copies since they were checked out")
+}
+
+// newGitSources returns the manager of the checkouts paths are synced from
+func newGitSources(manager *config.Manager) *gitsource.Manager {
+	return gitsource.NewManager(filepath.Join(manager.GetConfigDir(), gitsource.DefaultDir), gitsource.RunCommand)
+}
+
+func runUpdate(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "update"); err != nil {
+		return err
+	}
+
+	for _, appName := range args {
+		if _, exists := cfg.Apps[appName]; !exists {
+			return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+		}
+	}
+
+	release, err := lockApps(manager, "update", configuredApps(cfg, args))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	symlinkManager.SetPlugins(openPlugin)
+	symlinkManager.SetContext(runContext)
+	symlinkManager.SetGitSources(newGitSources(manager))
+
+	report := &updateReport{Paths: []updatedPath{}}
+	for _, appName := range configuredApps(cfg, args) {
+		appConfig := cfg.Apps[appName]
+		for i := range appConfig.Paths {
+			path := &appConfig.Paths[i]
+			if path.Repo == "" {
+				continue
+			}
+			update, err := symlinkManager.UpdateGitSource(path, updateForce)
+			if err != nil {
+				if !structuredOutput() {
+					printer.Failure("%s: %v", appName, err)
+				}
+				report.Failed = append(report.Failed, appName)
+				continue
+			}
+			report.Paths = append(report.Paths, updatedPath{
+				App:      appName,
+				Path:     path.Source,
+				Repo:     update.Repo,
+				Revision: update.Revision,
+				Pulled:   update.Pulled,
+			})
+		}
+	}
+
+	if structuredOutput() {
+		if err := printStructured(report); err != nil {
+			return err
+		}
+	} else {
+		printUpdateReport(report)
+	}
+
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("failed to update %d path(s)", len(report.Failed))
+	}
+	return nil
+}
+
+// printUpdateReport lists the paths brought up to date with their repositories
+func printUpdateReport(report *updateReport) {
+	if len(report.Paths) == 0 && len(report.Failed) == 0 {
+		fmt.Println("No paths are checked out from git repositories")
+		return
+	}
+
+	for _, path := range report.Paths {
+		switch {
+		case path.Pulled == 0:
+			printer.Success("%s", messages.Localize(messages.GitSourceCurrent, messages.Data{"App": path.App, "Path": path.Path, "Revision": path.Revision}))
+		case dryRun:
+			fmt.Printf("[DRY RUN] %s: would pull %d commit(s) of %s into %s\n", path.App, path.Pulled, path.Repo, path.Path)
+		default:
+			printer.Success("%s", messages.Localize(messages.GitSourcePulled, messages.Data{"App": path.App, "Count": path.Pulled, "Repo": path.Repo, "Path": path.Path, "Revision": path.Revision}))
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/dotbrains/configsync/pkg/apps"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradesMigrate bool
+	upgradesYes     bool
+)
+
+// upgradesCmd represents the upgrades command
+var upgradesCmd = &cobra.Command{
+	Use:   "upgrades [app...]",
+	Short: "Find applications whose upgrade moved their configuration",
+	Long: `Compare the installed version of each managed application with the version
+recorded when it was added, and re-detect its known paths to find those that
+moved, such as Sublime Text 4 keeping its packages in "Sublime Text" rather
+than "Sublime Text 3". A synced path left at the old location goes stale: the
+upgraded application no longer reads it.
+
+With --migrate, each moved path is reconfigured to its new location: the store
+copy is moved to the new store destination, whatever the upgraded application
+created at the new location is archived in the backup directory, and the new
+location is linked to the store copy. The recorded versions are updated too.
+
+Installed versions are read from application bundles, so they are only
+compared on macOS; moved paths are found on every platform.
+
+Examples:
+  configsync upgrades
+  configsync upgrades sublimetext
+  configsync upgrades --migrate
+  configsync upgrades --migrate --yes --json`,
+	RunE: runUpgrades,
+}
+
+// appUpgrade is an application that was upgraded or whose paths moved
+type appUpgrade struct {
+	App       string          `json:"app" yaml:"app"`
+	Recorded  string          `json:"recorded_version,omitempty" yaml:"recorded_version,omitempty"`
+	Installed string          `json:"installed_version,omitempty" yaml:"installed_version,omitempty"`
+	Moves     []apps.PathMove `json:"moves" yaml:"moves"`
+	Migrated  bool            `json:"migrated" yaml:"migrated"`
+}
+
+// upgradesReport is the structured result of the upgrades command
+type upgradesReport struct {
+	Apps []*appUpgrade `json:"apps" yaml:"apps"`
+}
+
+func runUpgrades(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if err := refuseLockedStore(cfg, "upgrades"); err != nil {
+		return err
+	}
+
+	for _, appName := range args {
+		if _, exists := cfg.Apps[appName]; !exists {
+			return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+		}
+	}
+
+	detector, err := newAppDetector()
+	if err != nil {
+		return err
+	}
+
+	report := &upgradesReport{Apps: []*appUpgrade{}}
+	for _, appName := range configuredApps(cfg, args) {
+		appConfig := cfg.Apps[appName]
+		upgrade := &appUpgrade{
+			App:       appName,
+			Recorded:  appConfig.Metadata[config.MetadataAppVersion],
+			Installed: detector.InstalledVersion(appConfig.BundleID),
+			Moves:     detector.DetectMoves(appConfig),
+		}
+		if len(upgrade.Moves) > 0 || upgrade.upgraded() {
+			report.Apps = append(report.Apps, upgrade)
+		}
+	}
+
+	if upgradesMigrate && len(report.Apps) > 0 {
+		if err := migrateUpgrades(manager, cfg, report); err != nil {
+			return err
+		}
+	}
+
+	if structuredOutput() {
+		return printStructured(report)
+	}
+	if !upgradesMigrate {
+		printUpgradesReport(report)
+	}
+	return nil
+}
+
+// upgraded reports whether the installed version differs from the one recorded when the
+// application was added
+func (u *appUpgrade) upgraded() bool {
+	return u.Recorded != "" && u.Installed != "" && u.Recorded != u.Installed
+}
+
+// printUpgradesReport lists the upgraded applications and their moved paths
+func printUpgradesReport(report *upgradesReport) {
+	if len(report.Apps) == 0 {
+		printer.Success("%s", messages.Localize(messages.NoUpgradeMoves, nil))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tVERSION\tPATH\tMOVED TO")
+	moved := 0
+	for _, upgrade := range report.Apps {
+		version := "-"
+		if upgrade.upgraded() {
+			version = upgrade.Recorded + " -> " + upgrade.Installed
+		}
+		if len(upgrade.Moves) == 0 {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t-\tunchanged\n", upgrade.App, version)
+			continue
+		}
+		for _, move := range upgrade.Moves {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", upgrade.App, version, move.From, move.To)
+			moved++
+		}
+	}
+	_ = w.Flush()
+
+	if moved > 0 {
+		fmt.Printf("\n%d path(s) moved; run 'configsync upgrades --migrate' to move them to their new locations\n", moved)
+	} else {
+		fmt.Println("\nRun 'configsync upgrades --migrate' to record the installed versions")
+	}
+}
+
+// migrateUpgrades moves the paths of the upgraded applications to their new locations, once
+// confirmed, and records the installed versions
+func migrateUpgrades(manager *config.Manager, cfg *config.Config, report *upgradesReport) error {
+	// Keep structured output parseable
+	printf := func(format string, a ...interface{}) {
+		if !structuredOutput() {
+			fmt.Printf(format, a...)
+		}
+	}
+
+	var names []string
+	migrating := make(map[string]*config.AppConfig)
+	for _, upgrade := range report.Apps {
+		names = append(names, upgrade.App)
+		migrating[upgrade.App] = cfg.Apps[upgrade.App]
+		for _, move := range upgrade.Moves {
+			printf("%s: %s -> %s\n", upgrade.App, move.From, move.To)
+		}
+	}
+
+	accepted, err := confirmUpgrades(fmt.Sprintf("Migrate %d upgraded application(s)?", len(report.Apps)))
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		printf("Cancelled\n")
+		return nil
+	}
+
+	release, err := lockApps(manager, "upgrades", names)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	undo := ""
+	if !dryRun {
+		undo = captureUndo(manager, history.Upgrade, migrating)
+	}
+	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	symlinkManager.SetPlugins(openPlugin)
+	migrated := make(map[string]*config.AppConfig)
+	for _, upgrade := range report.Apps {
+		appConfig := cfg.Apps[upgrade.App]
+		var migrateErr error
+		for _, move := range upgrade.Moves {
+			if migrateErr = symlinkManager.MovePath(appConfig, move.Index, move.To, move.ToDestination); migrateErr != nil {
+				printf("✗ Failed to move %s: %v\n", move.From, migrateErr)
+				break
+			}
+		}
+		if migrateErr == nil && upgrade.Installed != "" && !dryRun {
+			appConfig.Metadata[config.MetadataAppVersion] = upgrade.Installed
+		}
+
+		if !dryRun {
+			entry := appHistoryEntry(history.Upgrade, upgrade.App, appConfig, migrateErr)
+			entry.Undo = undo
+			recordHistory(entry)
+		}
+		if migrateErr == nil {
+			upgrade.Migrated = true
+			migrated[upgrade.App] = appConfig
+			if !dryRun {
+				printf("✓ Migrated %s\n", appConfig.DisplayName)
+			}
+		}
+	}
+
+	if dryRun {
+		printf("\n[DRY RUN] Would migrate %d application(s)\n", len(migrated))
+		return nil
+	}
+	if err := manager.Save(cfg); err != nil {
+		return messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+	if err := recordStoreChecksums(cfg.StorePath, migrated); err != nil {
+		printf("Warning: failed to record store checksums: %v\n", err)
+	}
+	if len(migrated) < len(report.Apps) {
+		return fmt.Errorf("failed to migrate %d application(s)", len(report.Apps)-len(migrated))
+	}
+	return nil
+}
+
+// confirmUpgrades asks whether to migrate, unless --yes or --dry-run was given
+func confirmUpgrades(question string) (bool, error) {
+	if upgradesYes || dryRun {
+		return true, nil
+	}
+	if progressEmitter.Enabled() {
+		return progressEmitter.Confirm("upgrades-migrate", question), nil
+	}
+	if !isInteractive() {
+		return false, messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "migrate"})
+	}
+	return promptYesNo(question), nil
+}
+
+func init() {
+	upgradesCmd.Flags().BoolVar(&upgradesMigrate, "migrate", false, "move the moved paths to their new locations and record the installed versions")
+	upgradesCmd.Flags().BoolVarP(&upgradesYes, "yes", "y", false, "migrate without asking for confirmation")
+}
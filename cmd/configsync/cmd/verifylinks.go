@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/symlink"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyLinksRepair bool
+	verifyLinksYes    bool
+)
+
+// verifyLinksCmd represents the verify-links command
+var verifyLinksCmd = &cobra.Command{
+	Use:   "verify-links [app...]",
+	Short: "Check managed symlinks and repair broken or wrong ones",
+	Long: `Check every symlinked path of the managed applications and classify it:
+
+  correct       a symlink to its store copy
+  broken        a symlink whose target does not exist
+  wrong_target  a symlink to something other than its store copy
+  replaced      a file an application wrote in place of its symlink
+  missing       the path does not exist, although its store copy does
+  not_synced    the path has not been moved into the store yet
+
+With --repair, the problems are fixed in batches, each confirmed separately:
+broken, wrong, and missing links are relinked to their store copy, and replaced
+paths are moved into the store with the previous store copy archived in the
+backup directory, as 'configsync sync --heal' does.
+
+Examples:
+  configsync verify-links
+  configsync verify-links vscode
+  configsync verify-links --repair
+  configsync verify-links --repair --yes
+  configsync verify-links --json`,
+	RunE: runVerifyLinks,
+}
+
+// verifyLinksReport is the structured result of the verify-links command
+type verifyLinksReport struct {
+	Problems []symlink.LinkCheck `json:"problems" yaml:"problems"`
+	Checked  int                 `json:"checked" yaml:"checked"`
+	Repaired int                 `json:"repaired" yaml:"repaired"`
+}
+
+func runVerifyLinks(_ *cobra.Command, args []string) error {
+	manager := newConfigManager()
+
+	if !manager.ConfigExists() {
+		return messages.Error(messages.NotInitialized, nil)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	if verifyLinksRepair {
+		if err := refuseLockedStore(cfg, "verify-links --repair"); err != nil {
+			return err
+		}
+	}
+
+	for _, appName := range args {
+		if _, exists := cfg.Apps[appName]; !exists {
+			return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+		}
+	}
+
+	symlinkManager := symlink.NewManager(homeDir, cfg.StorePath, cfg.BackupPath, dryRun, verbose)
+	symlinkManager.SetHost(cfg.Host(config.CurrentHost))
+	report := &verifyLinksReport{Problems: []symlink.LinkCheck{}}
+	for _, appName := range configuredApps(cfg, args) {
+		for _, check := range symlinkManager.VerifyLinks(cfg.Apps[appName]) {
+			report.Checked++
+			if check.State != symlink.LinkCorrect {
+				report.Problems = append(report.Problems, check)
+			}
+		}
+	}
+
+	if verifyLinksRepair && len(report.Problems) > 0 {
+		if report.Repaired, err = repairLinks(manager, cfg, symlinkManager, report.Problems); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case structuredOutput():
+		if err := printStructured(report); err != nil {
+			return err
+		}
+	case verifyLinksRepair && len(report.Problems) > 0 && dryRun:
+		fmt.Printf("\n[DRY RUN] Would repair %d of %d link problem(s)\n", report.Repaired, len(report.Problems))
+	case verifyLinksRepair && len(report.Problems) > 0:
+		fmt.Printf("\n%d of %d link problem(s) repaired\n", report.Repaired, len(report.Problems))
+	default:
+		printVerifyLinksReport(report)
+	}
+
+	if remaining := len(report.Problems) - report.Repaired; remaining > 0 && !dryRun {
+		return fmt.Errorf("%d link problem(s) remain", remaining)
+	}
+	return nil
+}
+
+// printVerifyLinksReport lists the paths whose links need attention
+func printVerifyLinksReport(report *verifyLinksReport) {
+	if len(report.Problems) == 0 {
+		printer.Success("%s", messages.Localize(messages.LinksCorrect, messages.Data{"Count": report.Checked}))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tPATH\tSTATE\tREPAIR")
+	repairable := 0
+	for _, check := range report.Problems {
+		repair := string(check.Repair)
+		if check.Repair == symlink.RepairNone {
+			repair = "-"
+		} else {
+			repairable++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", check.App, check.Source, check.State, repair)
+	}
+	_ = w.Flush()
+
+	for _, check := range report.Problems {
+		if check.Hint != "" {
+			fmt.Printf("\n%s: %s", check.Source, check.Hint)
+		}
+	}
+
+	fmt.Printf("\n%d of %d managed link(s) need attention", len(report.Problems), report.Checked)
+	if repairable > 0 {
+		fmt.Printf("; run 'configsync verify-links --repair' to fix %d of them", repairable)
+	}
+	fmt.Println()
+}
+
+// repairLinks applies the repairs in batches by action, confirming each batch, and returns how
+// many paths were repaired
+func repairLinks(manager *config.Manager, cfg *config.Config, symlinkManager *symlink.Manager, problems []symlink.LinkCheck) (int, error) {
+	batches := []struct {
+		action   symlink.RepairAction
+		question string
+	}{
+		{symlink.RepairRelink, "Relink %d path(s) to their store copy?"},
+		{symlink.RepairHeal, "Move %d replaced path(s) into the store, archiving the previous store copies?"},
+	}
+
+	var apps []string
+	for _, check := range problems {
+		if check.Repair != symlink.RepairNone {
+			apps = append(apps, check.App)
+		}
+	}
+	release, err := lockApps(manager, "verify-links", apps)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// Keep structured output parseable
+	printf := func(format string, a ...interface{}) {
+		if !structuredOutput() {
+			fmt.Printf(format, a...)
+		}
+	}
+
+	repaired := 0
+	repairedApps := make(map[string]*config.AppConfig)
+	for _, batch := range batches {
+		var checks []symlink.LinkCheck
+		for _, check := range problems {
+			if check.Repair == batch.action {
+				checks = append(checks, check)
+			}
+		}
+		if len(checks) == 0 {
+			continue
+		}
+
+		printf("%s:\n", batch.action)
+		for _, check := range checks {
+			printf("  - %s (%s)\n", check.Source, check.State)
+		}
+
+		accepted, err := confirmRepair(string(batch.action), fmt.Sprintf(batch.question, len(checks)))
+		if err != nil {
+			return repaired, err
+		}
+		if !accepted {
+			printf("Skipped\n")
+			continue
+		}
+
+		for _, check := range checks {
+			appConfig := cfg.Apps[check.App]
+			if err := symlinkManager.RepairLink(appConfig, check); err != nil {
+				printf("✗ Failed to repair %s: %v\n", check.Source, err)
+				continue
+			}
+			repaired++
+			repairedApps[check.App] = appConfig
+			if !dryRun {
+				printf("✓ Repaired %s\n", check.Source)
+			}
+		}
+	}
+
+	for _, check := range problems {
+		if check.Repair == symlink.RepairNone {
+			printf("Cannot repair %s (%s): %s\n", check.Source, check.State, check.Hint)
+		}
+	}
+
+	if !dryRun && len(repairedApps) > 0 {
+		if err := recordStoreChecksums(cfg.StorePath, repairedApps); err != nil {
+			printf("Warning: failed to record store checksums: %v\n", err)
+		}
+	}
+	return repaired, nil
+}
+
+// confirmRepair asks whether to apply a batch of repairs, unless --yes or --dry-run was given
+func confirmRepair(id, question string) (bool, error) {
+	if verifyLinksYes || dryRun {
+		return true, nil
+	}
+	if progressEmitter.Enabled() {
+		return progressEmitter.Confirm("verify-links-"+id, question), nil
+	}
+	if !isInteractive() {
+		return false, messages.Error(messages.ConfirmationRequired, messages.Data{"Action": "repair"})
+	}
+	return promptYesNo(question), nil
+}
+
+func init() {
+	verifyLinksCmd.Flags().BoolVar(&verifyLinksRepair, "repair", false, "repair the broken, wrong, missing, and replaced links")
+	verifyLinksCmd.Flags().BoolVarP(&verifyLinksYes, "yes", "y", false, "repair without asking for confirmation")
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// iCloudStorePath is where the wizard offers to keep the store in iCloud Drive, relative to the home directory
+const iCloudStorePath = "Library/Mobile Documents/com~apple~CloudDocs/configsync"
+
+// wizardChoices are the answers given to the setup wizard of 'init --interactive'
+type wizardChoices struct {
+	StorePath string // Empty for the default store in the configuration directory
+	Git       bool   // Make the store a git repository
+	Retention int    // Days backups are kept; 0 keeps them all
+	Apps      []*config.AppConfig
+}
+
+// storeOption is a store location offered by the setup wizard
+type storeOption struct {
+	label string
+	path  string // Empty for the default store, or for one the user types in when ask is set
+	git   bool
+	ask   bool
+}
+
+// runInitWizard guides a new user through choosing a store location, backup retention, and the
+// applications to manage, initializes ConfigSync with them, and offers a first sync
+func runInitWizard(manager *config.Manager) error {
+	if !isInteractive() {
+		return fmt.Errorf("--interactive requires a terminal")
+	}
+
+	fmt.Println("Welcome to ConfigSync! A few questions set it up; Enter picks the default.")
+	choices, err := askWizardChoices(manager)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("\n[DRY RUN] Would initialize ConfigSync in %s and add %d application(s)\n", configDir, len(choices.Apps))
+		return nil
+	}
+
+	cfg, err := applyWizardChoices(manager, choices)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	printer.Success("%s", messages.Localize(messages.Initialized, messages.Data{"Path": configDir}))
+	fmt.Printf("  Store: %s\n", cfg.StorePath)
+	fmt.Printf("  Applications: %d\n", len(choices.Apps))
+	if len(choices.Apps) == 0 {
+		fmt.Println("\nAdd applications later with: configsync add <app>")
+		return nil
+	}
+
+	if !promptYesNo("\nSync the selected applications now?") {
+		fmt.Println("\nSync them when you are ready with: configsync sync")
+		return nil
+	}
+	names := make([]string, 0, len(choices.Apps))
+	for _, appConfig := range choices.Apps {
+		names = append(names, appConfig.Name)
+	}
+	_, err = syncConfiguredApps(names)
+	return err
+}
+
+// askWizardChoices asks where to keep the store, how long to keep backups, and which of the
+// discovered applications to manage
+func askWizardChoices(manager *config.Manager) (*wizardChoices, error) {
+	choices := &wizardChoices{}
+
+	if initStorePath != "" {
+		choices.StorePath = initStorePath
+	} else {
+		option, err := askStoreLocation(manager)
+		if err != nil {
+			return nil, err
+		}
+		choices.StorePath, choices.Git = option.path, option.git
+	}
+
+	for {
+		answer := promptLine("\nKeep backups for how many days? (Enter to keep them all): ")
+		if answer == "" {
+			break
+		}
+		days, err := strconv.Atoi(answer)
+		if err == nil && days >= 0 {
+			choices.Retention = days
+			break
+		}
+		printer.Failure("  %s", messages.Localize(messages.WizardEnterDays, nil))
+	}
+
+	detector, err := newAppDetector()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("\nLooking for applications with configurations to manage...")
+	detected, err := detector.AutoDetectApps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to auto-detect app configurations: %v", err)
+	}
+	for _, pluginErr := range detector.PluginErrors() {
+		printer.Warning("%v", pluginErr)
+	}
+	if len(detected) == 0 {
+		fmt.Println("No applications were found.")
+		return choices, nil
+	}
+	sort.Slice(detected, func(i, j int) bool { return detected[i].DisplayName < detected[j].DisplayName })
+
+	for i, appConfig := range detected {
+		fmt.Printf("  %2d) %s (%d paths)\n", i+1, appConfig.DisplayName, len(appConfig.Paths))
+	}
+	for {
+		answer := promptLine("Applications to manage (e.g. 1,3-5 or all; Enter for none): ")
+		selected, err := parseSelection(answer, len(detected))
+		if err != nil {
+			printer.Failure("  %v", err)
+			continue
+		}
+		for _, index := range selected {
+			choices.Apps = append(choices.Apps, detected[index])
+		}
+		return choices, nil
+	}
+}
+
+// askStoreLocation asks where to keep the store
+func askStoreLocation(manager *config.Manager) (storeOption, error) {
+	defaultStore := filepath.Join(manager.GetConfigDir(), config.DefaultStoreDir)
+	options := []storeOption{{label: "Local, in " + defaultStore}}
+	if runtime.GOOS == "darwin" {
+		options = append(options, storeOption{
+			label: "iCloud Drive, so it follows you between Macs",
+			path:  filepath.Join(homeDir, iCloudStorePath),
+		})
+	}
+	options = append(options,
+		storeOption{label: "A git repository in " + defaultStore + ", to push to a remote", git: true},
+		storeOption{label: "Another folder, such as one synced by Dropbox", ask: true},
+	)
+
+	fmt.Println("\nWhere should the store, which holds your configurations, be kept?")
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option.label)
+	}
+	for {
+		answer := promptLine(fmt.Sprintf("Store location (1-%d, Enter for 1): ", len(options)))
+		if answer == "" {
+			return options[0], nil
+		}
+		choice, err := strconv.Atoi(answer)
+		if err != nil || choice < 1 || choice > len(options) {
+			printer.Failure("  %s", messages.Localize(messages.WizardEnterNumber, messages.Data{"Max": len(options)}))
+			continue
+		}
+		option := options[choice-1]
+		if !option.ask {
+			return option, nil
+		}
+		folder := promptLine("Folder for the store: ")
+		if folder == "" {
+			continue
+		}
+		path, err := resolveStorePath(folder)
+		if err != nil {
+			return storeOption{}, err
+		}
+		option.path = path
+		return option, nil
+	}
+}
+
+// applyWizardChoices initializes ConfigSync with the answers given to the setup wizard
+func applyWizardChoices(manager *config.Manager, choices *wizardChoices) (*config.Config, error) {
+	if choices.StorePath != "" {
+		manager.SetStorePath(choices.StorePath)
+	}
+	if err := manager.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize ConfigSync: %w", err)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	cfg.Settings.BackupRetention = choices.Retention
+	for _, appConfig := range choices.Apps {
+		cfg.Apps[appConfig.Name] = appConfig
+	}
+	if err := manager.Save(cfg); err != nil {
+		return nil, messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+
+	if choices.Git {
+		if _, err := gitsource.RunCommand(cfg.StorePath, "git", "init"); err != nil {
+			printer.Warning("%s", messages.Localize(messages.StoreGitInitFailed, messages.Data{"Error": err}))
+		}
+	}
+	return cfg, nil
+}
+
+// parseSelection reads a selection from a numbered list of count items, such as "1,3-5" or
+// "all", and returns the indexes selected in order. An empty answer selects nothing.
+func parseSelection(answer string, count int) ([]int, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" {
+		return nil, nil
+	}
+	if answer == "all" {
+		selected := make([]int, count)
+		for i := range selected {
+			selected[i] = i
+		}
+		return selected, nil
+	}
+
+	var selected []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err != nil || first < 1 || last > count || first > last {
+			return nil, fmt.Errorf("%q is not a number or range from 1 to %d", part, count)
+		}
+		for n := first; n <= last; n++ {
+			if !seen[n] {
+				seen[n] = true
+				selected = append(selected, n-1)
+			}
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// statusFormatXbar prints the status as an xbar or SwiftBar plugin
+const statusFormatXbar = "xbar"
+
+// printXbarStatus prints a status report as xbar plugin output
+func printXbarStatus(report *statusReport) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the configsync executable: %w", err)
+	}
+	writeXbarStatus(os.Stdout, report, executable, time.Now())
+	return nil
+}
+
+// writeXbarStatus writes the menubar line, followed by a menu with the status of every
+// application and actions that run configsync in the background and refresh the plugin
+func writeXbarStatus(w io.Writer, report *statusReport, executable string, now time.Time) {
+	issues := 0
+	for _, app := range report.Apps {
+		if app.Enabled && app.Synced < xbarExpectedPaths(app) {
+			issues++
+		}
+	}
+	issues += len(report.Permissions.Issues)
+
+	if issues > 0 {
+		_, _ = fmt.Fprintf(w, "⚠ %d | color=orange\n", issues)
+	} else {
+		_, _ = fmt.Fprintln(w, "✓ ConfigSync")
+	}
+	_, _ = fmt.Fprintln(w, "---")
+
+	lastSync := "never"
+	if report.LastSync != nil {
+		lastSync = formatAgo(now.Sub(*report.LastSync))
+	}
+	_, _ = fmt.Fprintf(w, "%d app(s), last sync %s\n", len(report.Apps), lastSync)
+	if len(report.Permissions.Issues) > 0 {
+		_, _ = fmt.Fprintf(w, "%d path(s) need Full Disk Access | color=red\n", len(report.Permissions.Issues))
+	}
+	_, _ = fmt.Fprintln(w, "---")
+
+	for _, app := range report.Apps {
+		if app.Host != "" {
+			continue
+		}
+		expected := xbarExpectedPaths(app)
+		switch {
+		case !app.Enabled:
+			_, _ = fmt.Fprintf(w, "%s (disabled) | color=gray\n", xbarText(app.DisplayName))
+		case app.Synced < expected:
+			_, _ = fmt.Fprintf(w, "✗ %s (%d/%d) | color=red\n", xbarText(app.DisplayName), app.Synced, expected)
+		default:
+			_, _ = fmt.Fprintf(w, "✓ %s\n", xbarText(app.DisplayName))
+		}
+
+		for _, path := range app.Paths {
+			if path.Status == statusOtherPlatform {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "--%s: %s | font=Menlo size=11\n", xbarText(path.Source), path.Status)
+		}
+		if app.Enabled {
+			_, _ = fmt.Fprintf(w, "--Sync %s | %s\n", xbarText(app.DisplayName), xbarAction(executable, "sync", app.Name))
+		}
+	}
+
+	if len(report.Apps) > 0 {
+		_, _ = fmt.Fprintln(w, "---")
+		_, _ = fmt.Fprintf(w, "Sync all | %s\n", xbarAction(executable, "sync"))
+	}
+	_, _ = fmt.Fprintln(w, "Refresh | refresh=true")
+}
+
+// xbarExpectedPaths counts the paths of an application that are used on this platform and host
+func xbarExpectedPaths(app appStatus) int {
+	expected := 0
+	for _, path := range app.Paths {
+		if path.Status != statusOtherPlatform && path.Status != statusOtherHost {
+			expected++
+		}
+	}
+	return expected
+}
+
+// xbarAction returns the parameters that make a menu item run configsync with the arguments
+func xbarAction(executable string, args ...string) string {
+	params := []string{"bash=" + xbarParam(executable)}
+	if homeDir != "" {
+		args = append(args, "--home", homeDir)
+	}
+	for i, arg := range args {
+		params = append(params, fmt.Sprintf("param%d=%s", i+1, xbarParam(arg)))
+	}
+	return strings.Join(append(params, "terminal=false", "refresh=true"), " ")
+}
+
+// xbarParam quotes a parameter value containing spaces
+func xbarParam(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
+
+// xbarText keeps text from being read as the separator between an item and its parameters
+func xbarText(text string) string {
+	return strings.ReplaceAll(text, "|", "¦")
+}
+
+// formatAgo describes a duration in the past in its largest unit
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
@@ -1,4 +1,9 @@
+// The go:debug directive below makes Windows junctions report as symlinks, as before Go 1.23, so
+// directories linked with a junction where symlinks need Developer Mode are recognized as synced.
+
 // Package main provides the entry point for the ConfigSync CLI application.
+//
+//go:debug winsymlink=0
 package main
 
 import (
@@ -10,7 +15,9 @@ import (
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if message := err.Error(); message != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", message)
+		}
+		os.Exit(cmd.ExitCode(err))
 	}
 }
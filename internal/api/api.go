@@ -0,0 +1,212 @@
+// Package api serves the core configsync operations over a small authenticated HTTP API on a
+// loopback address, so companion apps and scripts can drive configsync without running the CLI.
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TokenFile is the name of the file in the configuration directory holding the API token
+const TokenFile = "api-token"
+
+// maxRequestSize bounds the size of request bodies
+const maxRequestSize = 1 << 20
+
+// Backend performs the operations exposed by the API
+type Backend interface {
+	// Status returns the sync status of the managed applications
+	Status() (interface{}, error)
+	// List returns the managed applications, filtered and sorted by the query parameters
+	List(query url.Values) (interface{}, error)
+	// Sync syncs the named applications, or all of them
+	Sync(req SyncRequest) (interface{}, error)
+	// Export writes a configuration bundle
+	Export(req ExportRequest) (interface{}, error)
+}
+
+// SyncRequest is the body of a sync request
+type SyncRequest struct {
+	Apps []string `json:"apps"`
+}
+
+// ExportRequest is the body of an export request
+type ExportRequest struct {
+	Output string   `json:"output"`
+	Format string   `json:"format"`
+	Apps   []string `json:"apps"`
+}
+
+// RequestError is returned by a backend when a request cannot be carried out as given, such as
+// for an unknown application, and is reported to the client as a bad request
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server routes authenticated requests to the backend, one operation at a time
+type Server struct {
+	backend Backend
+	mux     *http.ServeMux
+	token   string
+	mu      sync.Mutex
+}
+
+// NewServer creates a server that accepts requests carrying the token
+func NewServer(token string, backend Backend) *Server {
+	s := &Server{backend: backend, token: token, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /v1/status", s.handleStatus)
+	s.mux.HandleFunc("GET /v1/apps", s.handleList)
+	s.mux.HandleFunc("POST /v1/sync", s.handleSync)
+	s.mux.HandleFunc("POST /v1/export", s.handleExport)
+	return s
+}
+
+// ServeHTTP checks the request's token before handling it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="configsync"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid token"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether the request carries the server's bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	s.run(w, s.backend.Status)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.run(w, func() (interface{}, error) {
+		return s.backend.List(r.URL.Query())
+	})
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	var req SyncRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	s.run(w, func() (interface{}, error) {
+		return s.backend.Sync(req)
+	})
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	var req ExportRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	s.run(w, func() (interface{}, error) {
+		return s.backend.Export(req)
+	})
+}
+
+// run performs an operation, waiting for any other operation to finish first, and writes its result
+func (s *Server) run(w http.ResponseWriter, operation func() (interface{}, error)) {
+	s.mu.Lock()
+	result, err := operation()
+	s.mu.Unlock()
+
+	var requestErr *RequestError
+	switch {
+	case errors.As(err, &requestErr):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// decodeRequest reads a JSON request body, which may be empty, and reports whether it was valid
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return false
+	}
+	return true
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// CheckLoopback rejects listen addresses that are reachable from other machines
+func CheckLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listen address %q is not a loopback address; the API only listens on 127.0.0.1, ::1, or localhost", addr)
+}
+
+// LoadOrCreateToken reads the API token from the configuration directory, creating a random one
+// readable only by the user when there is none
+func LoadOrCreateToken(configDir string) (string, error) {
+	path := filepath.Join(configDir, TokenFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read API token: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write API token: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBackend records the requests it receives
+type fakeBackend struct {
+	query     url.Values
+	err       error
+	exportReq ExportRequest
+	syncReq   SyncRequest
+}
+
+func (b *fakeBackend) Status() (interface{}, error) {
+	return map[string]string{"store_path": "/store"}, b.err
+}
+
+func (b *fakeBackend) List(query url.Values) (interface{}, error) {
+	b.query = query
+	return []string{"vscode"}, b.err
+}
+
+func (b *fakeBackend) Sync(req SyncRequest) (interface{}, error) {
+	b.syncReq = req
+	return map[string][]string{"failed": {}}, b.err
+}
+
+func (b *fakeBackend) Export(req ExportRequest) (interface{}, error) {
+	b.exportReq = req
+	return map[string]string{"bundle_path": req.Output}, b.err
+}
+
+// do sends a request to the server with the given token
+func do(t *testing.T, s *Server, method, target, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthentication(t *testing.T) {
+	s := NewServer("secret", &fakeBackend{})
+
+	for _, token := range []string{"", "wrong"} {
+		if w := do(t, s, http.MethodGet, "/v1/status", token, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected 401, got %d", token, w.Code)
+		}
+	}
+	if w := do(t, s, http.MethodGet, "/v1/health", "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the token, got %d", w.Code)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	backend := &fakeBackend{}
+	s := NewServer("secret", backend)
+
+	w := do(t, s, http.MethodGet, "/v1/status", "secret", "")
+	var status map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil || status["store_path"] != "/store" {
+		t.Errorf("Unexpected status response: %d %v %v", w.Code, status, err)
+	}
+
+	do(t, s, http.MethodGet, "/v1/apps?filter=code&sort=paths", "secret", "")
+	if backend.query.Get("filter") != "code" || backend.query.Get("sort") != "paths" {
+		t.Errorf("Expected the query to reach the backend, got %v", backend.query)
+	}
+
+	if w := do(t, s, http.MethodPost, "/v1/sync", "secret", `{"apps":["vscode"]}`); w.Code != http.StatusOK {
+		t.Errorf("Expected sync to succeed, got %d", w.Code)
+	}
+	if len(backend.syncReq.Apps) != 1 || backend.syncReq.Apps[0] != "vscode" {
+		t.Errorf("Unexpected sync request: %+v", backend.syncReq)
+	}
+
+	if w := do(t, s, http.MethodPost, "/v1/sync", "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected sync without a body to succeed, got %d", w.Code)
+	}
+
+	do(t, s, http.MethodPost, "/v1/export", "secret", `{"output":"/tmp/b.zip","format":"zip"}`)
+	if backend.exportReq.Output != "/tmp/b.zip" || backend.exportReq.Format != "zip" {
+		t.Errorf("Unexpected export request: %+v", backend.exportReq)
+	}
+
+	if w := do(t, s, http.MethodGet, "/v1/sync", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET /v1/sync, got %d", w.Code)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	backend := &fakeBackend{}
+	s := NewServer("secret", backend)
+
+	if w := do(t, s, http.MethodPost, "/v1/sync", "secret", `{"unknown":1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid body, got %d", w.Code)
+	}
+
+	backend.err = &RequestError{Err: errors.New("application foo is not configured")}
+	w := do(t, s, http.MethodPost, "/v1/sync", "secret", `{"apps":["foo"]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "foo is not configured") {
+		t.Errorf("Expected 400 with the error, got %d %s", w.Code, w.Body.String())
+	}
+
+	backend.err = errors.New("disk full")
+	if w := do(t, s, http.MethodGet, "/v1/status", "secret", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:8080", "[::1]:8080", "localhost:8080"} {
+		if err := CheckLoopback(addr); err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "192.168.1.2:8080", "127.0.0.1"} {
+		if err := CheckLoopback(addr); err == nil {
+			t.Errorf("Expected %s to be rejected", addr)
+		}
+	}
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	dir := t.TempDir()
+
+	token, err := LoadOrCreateToken(dir)
+	if err != nil || len(token) != 64 {
+		t.Fatalf("Expected a new token, got %q %v", token, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, TokenFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the token file to be private, got %v %v", info, err)
+	}
+
+	again, err := LoadOrCreateToken(dir)
+	if err != nil || again != token {
+		t.Errorf("Expected the same token on the second call, got %q %v", again, err)
+	}
+}
@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/tarball"
+)
+
+// archiveIndexName is the file at the root of a backup archive that describes its backups
+const archiveIndexName = "backups.yaml"
+
+// ArchiveIndex describes the backups in an archive written by Export. The BackupPath of each
+// backup is the slash-separated archive entry holding it, the same as its path in the backup
+// directory.
+type ArchiveIndex struct {
+	CreatedAt time.Time            `yaml:"created_at"`
+	Host      string               `yaml:"host,omitempty"`
+	HomeDir   string               `yaml:"home_dir"` // Home directory the backups' original paths are in
+	Backups   []*config.BackupInfo `yaml:"backups"`
+}
+
+// Apps returns the names of the applications with backups, sorted
+func (m *Manager) Apps() ([]string, error) {
+	infoDir := filepath.Join(m.backupDir, "info")
+	if !m.pathExists(infoDir) {
+		return nil, nil
+	}
+	entries, err := m.fs.ReadDir(infoDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup info directory: %w", err)
+	}
+
+	var apps []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			apps = append(apps, entry.Name())
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// Export writes the backups of applications, or of every application when none are given, with
+// their metadata to a tar archive at dst, so they can be imported on another machine. The archive
+// is compressed according to the extension of dst, and with gzip when it has none. Backups whose
+// files are missing are left out.
+func (m *Manager) Export(dst string, apps []string) (*ArchiveIndex, error) {
+	if len(apps) == 0 {
+		var err error
+		if apps, err = m.Apps(); err != nil {
+			return nil, err
+		}
+	}
+
+	index := &ArchiveIndex{CreatedAt: time.Now(), Host: config.CurrentHost, HomeDir: m.homeDir}
+	var sources []string
+	for _, appName := range apps {
+		backups, err := m.ListBackups(appName)
+		if err != nil {
+			return nil, err
+		}
+		SortNewestFirst(backups)
+		for _, backup := range backups {
+			name, ok := m.archiveEntryName(backup)
+			if !ok {
+				if m.verbose {
+					fmt.Fprintf(m.out, "Warning: leaving out backup %s of %s: its files are missing\n", backup.Version, backup.OriginalPath)
+				}
+				continue
+			}
+			exported := *backup
+			exported.BackupPath = name
+			index.Backups = append(index.Backups, &exported)
+			sources = append(sources, backup.BackupPath)
+		}
+	}
+
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	compression, ok := tarball.CompressionOf(dst)
+	if !ok {
+		compression = tarball.Gzip
+	}
+	if err := m.writeBackupArchive(dst, compression, data, index.Backups, sources); err != nil {
+		_ = m.fs.Remove(dst)
+		return nil, err
+	}
+	return index, nil
+}
+
+// archiveEntryName returns the archive entry a backup is exported as: its path in the backup
+// directory, with forward slashes. Backups whose files are not in the backup directory are not
+// exported.
+func (m *Manager) archiveEntryName(backup *config.BackupInfo) (string, bool) {
+	if !m.pathExists(backup.BackupPath) {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.backupDir, backup.BackupPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// writeBackupArchive writes an archive holding an index followed by the files of its backups
+func (m *Manager) writeBackupArchive(dst, compression string, index []byte, backups []*config.BackupInfo, sources []string) error {
+	file, err := m.fs.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	writer, err := tarball.NewWriter(m.ctx, file, compression, 0)
+	if err != nil {
+		return err
+	}
+	if err := writer.AddFile(archiveIndexName, index); err != nil {
+		return err
+	}
+	for i, backup := range backups {
+		if err := writer.AddTree(m.fs, sources[i], backup.BackupPath, nil); err != nil {
+			return fmt.Errorf("failed to add backup %s: %w", sources[i], err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// ReadArchiveIndex returns the index of an archive written by Export without unpacking its backups
+func (m *Manager) ReadArchiveIndex(src string) (*ArchiveIndex, error) {
+	file, err := m.fs.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	tarReader, release, err := tarball.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is not a backup archive: it has no %s", src, archiveIndexName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != archiveIndexName {
+			continue
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, err
+		}
+		return parseArchiveIndex(data)
+	}
+}
+
+// Import adds the backups in an archive written by Export to the backup directory. Every backup
+// is validated against the size and checksum recorded when it was taken before any is added,
+// and the locations they were taken at are moved from the exporting machine's home directory to
+// this one's. Backups already present, with the same application, location, and version, are
+// skipped. It returns the backups imported and the number skipped.
+func (m *Manager) Import(src string) ([]*config.BackupInfo, int, error) {
+	if err := m.fs.MkdirAll(m.backupDir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	tempDir, err := fsys.MkdirTemp(m.fs, m.backupDir, ".import-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = m.fs.RemoveAll(tempDir) }()
+
+	file, err := m.fs.Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = file.Close() }()
+	if err := tarball.Extract(m.ctx, m.fs, file, tempDir); err != nil {
+		return nil, 0, fmt.Errorf("failed to unpack %s: %w", src, err)
+	}
+
+	data, err := m.fs.ReadFile(filepath.Join(tempDir, archiveIndexName))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s is not a backup archive: it has no %s", src, archiveIndexName)
+	}
+	index, err := parseArchiveIndex(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var imported, unpacked []*config.BackupInfo
+	skipped := 0
+	for _, exported := range index.Backups {
+		from, err := tarball.EntryPath(tempDir, exported.BackupPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		to, err := tarball.EntryPath(m.backupDir, exported.BackupPath)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		backup := *exported
+		backup.OriginalPath = rehome(exported.OriginalPath, index.HomeDir, m.homeDir)
+		backup.BackupPath = to
+		if m.pathExists(m.infoPathFor(&backup)) || m.pathExists(to) {
+			skipped++
+			continue
+		}
+
+		inArchive := backup
+		inArchive.BackupPath = from
+		if err := m.ValidateBackup(&inArchive); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s of %s: %v", ErrInvalidBackup, backup.Version, backup.OriginalPath, err)
+		}
+		imported = append(imported, &backup)
+		unpacked = append(unpacked, &inArchive)
+	}
+
+	for i, backup := range imported {
+		if err := m.fs.MkdirAll(filepath.Dir(backup.BackupPath), 0755); err != nil {
+			return nil, 0, fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		if err := m.fs.Rename(unpacked[i].BackupPath, backup.BackupPath); err != nil {
+			return nil, 0, fmt.Errorf("failed to import backup %s: %w", backup.BackupPath, err)
+		}
+		if err := m.saveBackupInfo(backup); err != nil {
+			return nil, 0, fmt.Errorf("failed to save backup info: %w", err)
+		}
+	}
+	return imported, skipped, nil
+}
+
+// parseArchiveIndex reads the index of a backup archive
+func parseArchiveIndex(data []byte) (*ArchiveIndex, error) {
+	var index ArchiveIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to read backup archive index: %w", err)
+	}
+	return &index, nil
+}
+
+// rehome moves a path in one home directory to the same place in another, leaving paths outside
+// it as they are
+func rehome(path, fromHome, toHome string) string {
+	if fromHome == "" || fromHome == toHome {
+		return path
+	}
+	if path == fromHome {
+		return toHome
+	}
+	if rel, ok := strings.CutPrefix(path, fromHome+string(filepath.Separator)); ok {
+		return filepath.Join(toHome, rel)
+	}
+	return path
+}
@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	oldHome, newHome := t.TempDir(), t.TempDir()
+	exporter := NewManager(filepath.Join(oldHome, "backups"), oldHome, false)
+
+	configPath := &config.Path{Source: "~/test.conf", Destination: "test.conf", Type: config.PathTypeFile}
+	if err := os.WriteFile(filepath.Join(oldHome, "test.conf"), []byte("old machine"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := exporter.BackupPath("testapp", configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	if err := exporter.WithCompression("zstd").BackupPath("otherapp", configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backups.tar.zst")
+	index, err := exporter.Export(archive, []string{"testapp"})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(index.Backups) != 1 {
+		t.Fatalf("Expected only the requested app's backup to be exported, got %d", len(index.Backups))
+	}
+	if read, err := exporter.ReadArchiveIndex(archive); err != nil || len(read.Backups) != 1 {
+		t.Fatalf("ReadArchiveIndex() = %+v, %v", read, err)
+	}
+
+	importer := NewManager(filepath.Join(newHome, "backups"), newHome, false)
+	imported, skipped, err := importer.Import(archive)
+	if err != nil || len(imported) != 1 || skipped != 0 {
+		t.Fatalf("Import() = %d imported, %d skipped, %v", len(imported), skipped, err)
+	}
+	if want := filepath.Join(newHome, "test.conf"); imported[0].OriginalPath != want {
+		t.Errorf("Expected the original path to move to the new home, got %s", imported[0].OriginalPath)
+	}
+
+	if err := importer.RestorePath("testapp", configPath); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(newHome, "test.conf")); string(content) != "old machine" {
+		t.Errorf("Expected the imported backup to be restored, got %q", string(content))
+	}
+
+	if _, skipped, err := importer.Import(archive); err != nil || skipped != 1 {
+		t.Errorf("Expected importing again to skip the backup, got %d skipped, %v", skipped, err)
+	}
+}
+
+func TestImportRejectsDamagedBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	configPath := &config.Path{Source: filepath.Join(tempDir, "test.conf"), Destination: "test.conf", Type: config.PathTypeFile}
+	if err := os.WriteFile(configPath.Source, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := manager.BackupPath("testapp", configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	versions, _ := manager.ListVersions("testapp", configPath)
+	if err := os.WriteFile(versions[0].BackupPath, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to damage backup: %v", err)
+	}
+
+	archive := filepath.Join(tempDir, "backups.tar.gz")
+	if _, err := manager.Export(archive, nil); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	importer := NewManager(filepath.Join(tempDir, "imported"), tempDir, false)
+	if _, _, err := importer.Import(archive); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+	if backups, _ := importer.ListBackups("testapp"); len(backups) != 0 {
+		t.Errorf("Expected nothing to be imported, got %d backups", len(backups))
+	}
+}
@@ -2,117 +2,269 @@
 package backup
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/ignore"
+	"github.com/dotbrains/configsync/internal/tarball"
 )
 
+// VersionFormat is the timestamp layout used for backup version identifiers
+const VersionFormat = "20060102-150405.000"
+
+// ReasonPreRestore marks the safety backup of a live path taken before a restore overwrites it.
+// Such backups are skipped when restoring the latest version, so restoring twice does not undo
+// the first restore, but can be restored by their version.
+const ReasonPreRestore = "pre-restore"
+
+// ReasonPreDeploy marks the backup of a store copy taken before a deploy overwrites it
+const ReasonPreDeploy = "pre-deploy"
+
+// legacySyncApp is the application name older versions kept the backups taken by sync under
+const legacySyncApp = "temp"
+
+// ErrInvalidBackup is returned when a backup fails validation and restoring it is not forced
+var ErrInvalidBackup = errors.New("backup failed validation")
+
 // Manager handles backup operations for configurations
 type Manager struct {
+	ctx       context.Context
+	fs        fsys.FS
+	out       io.Writer
 	backupDir string
 	homeDir   string
 	verbose   bool
+	// compression of new backups, which are written as one tar archive per path unless it is
+	// empty
+	compression string
+	force       bool          // Restore backups that fail validation
+	created     *createdPaths // Shared by the manager's copies
+}
+
+// createdPaths collects the backups taken by a manager and its copies, which may run concurrently
+type createdPaths struct {
+	mu      sync.Mutex
+	backups []*config.BackupInfo
 }
 
 // NewManager creates a new backup manager
 func NewManager(backupDir, homeDir string, verbose bool) *Manager {
 	return &Manager{
+		ctx:       context.Background(),
+		fs:        fsys.OS,
+		out:       os.Stdout,
 		backupDir: backupDir,
 		homeDir:   homeDir,
 		verbose:   verbose,
+		created:   &createdPaths{},
 	}
 }
 
-// BackupPath creates a backup of a single configuration path
+// Created returns the backups the manager and its copies took, in the order they were taken
+func (m *Manager) Created() []*config.BackupInfo {
+	m.created.mu.Lock()
+	defer m.created.mu.Unlock()
+	return append([]*config.BackupInfo(nil), m.created.backups...)
+}
+
+// WithOutput returns a copy of the manager that writes progress messages to w
+func (m *Manager) WithOutput(w io.Writer) *Manager {
+	clone := *m
+	clone.out = w
+	return &clone
+}
+
+// WithContext returns a copy of the manager whose backups and restores stop with the context's
+// error once ctx is canceled or times out
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	clone := *m
+	clone.ctx = ctx
+	return &clone
+}
+
+// WithFS returns a copy of the manager that keeps backups and restores files through files
+// instead of the operating system
+func (m *Manager) WithFS(files fsys.FS) *Manager {
+	clone := *m
+	clone.fs = files
+	return &clone
+}
+
+// WithCompression returns a copy of the manager that writes each new backup as a tar archive
+// compressed with gzip or zstd, trading the time to compress for space. An empty compression or
+// none copies paths as they are. Backups of either kind are restored and validated alike.
+func (m *Manager) WithCompression(compression string) *Manager {
+	clone := *m
+	clone.compression = compression
+	if compression == tarball.None {
+		clone.compression = ""
+	}
+	return &clone
+}
+
+// WithForce returns a copy of the manager that restores backups failing validation instead of
+// refusing them
+func (m *Manager) WithForce(force bool) *Manager {
+	clone := *m
+	clone.force = force
+	return &clone
+}
+
+// BackupPath creates a new timestamped version of the backup of a single configuration path.
+// Earlier versions are kept so any of them can be restored later.
 func (m *Manager) BackupPath(appName string, configPath *config.Path) error {
+	return m.BackupPathIgnoring(appName, configPath, nil)
+}
+
+// BackupPathIgnoring backs up a configuration path like BackupPath, leaving out the entries of a
+// directory that match the ignore rules
+func (m *Manager) BackupPathIgnoring(appName string, configPath *config.Path, ignored *ignore.Matcher) error {
+	sourcePath := m.expandPath(configPath.Source)
+	return m.backupPath(appName, configPath, sourcePath, ignored, "")
+}
+
+// BackupStoreCopy backs up the store copy of a path, which its source links to, before an
+// operation overwrites it. The backup is restored to the path's source like any other.
+func (m *Manager) BackupStoreCopy(appName string, configPath *config.Path, storePath, reason string) error {
+	return m.backupPath(appName, configPath, storePath, nil, reason)
+}
+
+// backupPath backs up the content of a configuration path found at from, usually its source,
+// recording why configsync took the backup when it was not requested
+func (m *Manager) backupPath(appName string, configPath *config.Path, from string, ignored *ignore.Matcher, reason string) error {
 	sourcePath := m.expandPath(configPath.Source)
 
 	// Check if source exists
-	if !m.pathExists(sourcePath) {
+	if !m.pathExists(from) {
 		if m.verbose {
-			fmt.Printf("    No backup needed - path does not exist: %s\n", sourcePath)
+			fmt.Fprintf(m.out, "    No backup needed - path does not exist: %s\n", from)
 		}
 		return nil
 	}
 
 	// Check if it's already a symlink (don't backup symlinks)
-	if m.isSymlink(sourcePath) {
+	if m.isSymlink(from) {
 		if m.verbose {
-			fmt.Printf("    No backup needed - path is already a symlink: %s\n", sourcePath)
+			fmt.Fprintf(m.out, "    No backup needed - path is already a symlink: %s\n", from)
 		}
 		return nil
 	}
 
 	// Create backup info
+	createdAt := m.nextVersionTime(appName, sourcePath)
 	backupInfo := &config.BackupInfo{
 		AppName:      appName,
+		Source:       configPath.Source,
 		OriginalPath: sourcePath,
-		CreatedAt:    time.Now(),
+		Destination:  configPath.Destination,
+		Version:      createdAt.Format(VersionFormat),
+		CreatedAt:    createdAt,
+		Reason:       reason,
 	}
 
-	// Calculate checksum
-	if configPath.Type == config.PathTypeFile {
-		checksum, err := m.calculateChecksum(sourcePath)
+	// Calculate checksum; an archive is checksummed once written
+	if configPath.Type == config.PathTypeFile && m.compression == "" {
+		checksum, err := m.calculateChecksum(from)
 		if err != nil {
 			return fmt.Errorf("failed to calculate checksum: %w", err)
 		}
 		backupInfo.Checksum = checksum
 	}
 
-	// Get file/directory size
-	size, err := m.calculateSize(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to calculate size: %w", err)
-	}
-	backupInfo.Size = size
-
 	// Create backup path
-	backupPath := m.getBackupPath(appName, configPath.Destination)
+	backupPath := m.getVersionPath(appName, configPath.Destination, backupInfo.Version)
+	if m.compression != "" {
+		backupPath += tarball.Extension(m.compression)
+		backupInfo.Compression = m.compression
+	}
 	backupInfo.BackupPath = backupPath
 
 	if m.verbose {
-		fmt.Printf("    Creating backup: %s -> %s\n", sourcePath, backupPath)
+		fmt.Fprintf(m.out, "    Creating backup: %s -> %s\n", from, backupPath)
 	}
 
 	// Create backup directory
 	backupDir := filepath.Dir(backupPath)
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(backupDir, 0755); err != nil {
 		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
-	// Copy file/directory to backup location
-	if err := m.copyPath(sourcePath, backupPath); err != nil {
+	// Copy file/directory to backup location, leaving no partial version behind
+	copyBackup := m.copyPathIgnoring
+	if m.compression != "" {
+		copyBackup = m.writeArchive
+	}
+	if err := copyBackup(from, backupPath, ignored); err != nil {
+		_ = m.fs.RemoveAll(backupPath)
 		return fmt.Errorf("failed to create backup: %w", err)
 	}
 
+	if m.compression != "" {
+		checksum, err := m.calculateChecksum(backupPath)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+		backupInfo.Checksum = checksum
+	}
+
+	// Get file/directory size
+	size, err := m.calculateSize(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate size: %w", err)
+	}
+	backupInfo.Size = size
+
 	// Save backup metadata
 	if err := m.saveBackupInfo(backupInfo); err != nil {
 		return fmt.Errorf("failed to save backup info: %w", err)
 	}
+	m.created.mu.Lock()
+	m.created.backups = append(m.created.backups, backupInfo)
+	m.created.mu.Unlock()
 
 	if m.verbose {
-		fmt.Printf("    Backup created successfully (%d bytes)\n", backupInfo.Size)
+		fmt.Fprintf(m.out, "    Backup created successfully (%d bytes)\n", backupInfo.Size)
 	}
 
 	return nil
 }
 
-// RestorePath restores a configuration path from backup
+// RestorePath restores a configuration path from its most recent backup
 func (m *Manager) RestorePath(appName string, configPath *config.Path) error {
+	return m.RestorePathVersion(appName, configPath, "")
+}
+
+// RestorePathVersion restores a configuration path from a specific backup version.
+// The version may be a prefix such as a date, in which case the newest matching version is used.
+// An empty version restores the most recent backup that was not taken before a restore.
+//
+// The backup is validated first and refused with ErrInvalidBackup if it is damaged, unless the
+// manager was created WithForce. The live path is then backed up with ReasonPreRestore before
+// it is overwritten.
+func (m *Manager) RestorePathVersion(appName string, configPath *config.Path, version string) error {
 	sourcePath := m.expandPath(configPath.Source)
-	backupPath := m.getBackupPath(appName, configPath.Destination)
+	backupPath, backupInfo, err := m.resolveBackup(appName, configPath, version)
+	if err != nil {
+		return err
+	}
 
 	if m.verbose {
-		fmt.Printf("    Restoring: %s <- %s\n", sourcePath, backupPath)
+		fmt.Fprintf(m.out, "    Restoring: %s <- %s\n", sourcePath, backupPath)
 	}
 
 	// Check if backup exists
@@ -120,29 +272,86 @@ func (m *Manager) RestorePath(appName string, configPath *config.Path) error {
 		return fmt.Errorf("backup does not exist: %s", backupPath)
 	}
 
+	// Leave the live files alone when the restore was canceled before it started
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+
+	// Backups from before versioning may have no metadata to validate against
+	if backupInfo != nil {
+		if err := m.ValidateBackup(backupInfo); err != nil {
+			if !m.force {
+				return fmt.Errorf("%w: %v (use --force to restore it anyway)", ErrInvalidBackup, err)
+			}
+			fmt.Fprintf(m.out, "    Warning: restoring %s despite failed validation: %v\n", sourcePath, err)
+		}
+	}
+
+	// Keep the current state, unless it is a symlink whose target stays in place
+	if err := m.backupPath(appName, configPath, m.expandPath(configPath.Source), nil, ReasonPreRestore); err != nil {
+		return fmt.Errorf("failed to back up the current state: %w", err)
+	}
+
+	// Unpack an archive next to the live path first, so a damaged one leaves it alone
+	restoreFrom := backupPath
+	if _, ok := tarball.CompressionOf(backupPath); ok {
+		unpacked, cleanup, err := m.extractArchive(backupPath, sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to unpack backup: %w", err)
+		}
+		defer cleanup()
+		restoreFrom = unpacked
+	}
+
 	// Remove existing file/symlink if it exists
 	if m.pathExists(sourcePath) {
 		if m.verbose {
-			fmt.Printf("    Removing existing: %s\n", sourcePath)
+			fmt.Fprintf(m.out, "    Removing existing: %s\n", sourcePath)
 		}
-		if err := os.RemoveAll(sourcePath); err != nil {
+		if err := m.fs.RemoveAll(sourcePath); err != nil {
 			return fmt.Errorf("failed to remove existing path: %w", err)
 		}
 	}
 
 	// Create source directory if needed
 	sourceDir := filepath.Dir(sourcePath)
-	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(sourceDir, 0755); err != nil {
 		return fmt.Errorf("failed to create source directory: %w", err)
 	}
 
-	// Copy backup back to original location
-	if err := m.copyPath(backupPath, sourcePath); err != nil {
+	// Copy backup back to original location, or move an unpacked archive there
+	if restoreFrom != backupPath {
+		if err := m.fs.Rename(restoreFrom, sourcePath); err != nil {
+			return fmt.Errorf("failed to restore from backup: %w", err)
+		}
+		restoreFrom = sourcePath
+	} else if err := m.copyPath(backupPath, sourcePath); err != nil {
 		return fmt.Errorf("failed to restore from backup: %w", err)
 	}
+	// Backups belong to root when taken under sudo; the restored copy belongs to the user
+	if err := fsops.GiveToOwnerOf(m.fs, sourcePath, m.homeDir); err != nil {
+		return fmt.Errorf("failed to give %s back to its owner: %w", sourcePath, err)
+	}
+
+	// Links that are symlinks, normally to the store copy, get the restored version too
+	for _, link := range configPath.Links {
+		linkPath := m.expandPath(link)
+		if !m.isSymlink(linkPath) {
+			continue
+		}
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Restoring link: %s <- %s\n", linkPath, backupPath)
+		}
+		if err := m.fs.Remove(linkPath); err != nil {
+			return fmt.Errorf("failed to remove link %s: %w", linkPath, err)
+		}
+		if err := m.copyPath(restoreFrom, linkPath); err != nil {
+			return fmt.Errorf("failed to restore %s from backup: %w", linkPath, err)
+		}
+	}
 
 	if m.verbose {
-		fmt.Printf("    Restored successfully\n")
+		fmt.Fprintf(m.out, "    Restored successfully\n")
 	}
 
 	return nil
@@ -156,7 +365,7 @@ func (m *Manager) ListBackups(appName string) ([]*config.BackupInfo, error) {
 		return []*config.BackupInfo{}, nil
 	}
 
-	entries, err := os.ReadDir(backupInfoDir)
+	entries, err := m.fs.ReadDir(backupInfoDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read backup info directory: %w", err)
 	}
@@ -171,7 +380,7 @@ func (m *Manager) ListBackups(appName string) ([]*config.BackupInfo, error) {
 		backupInfo, err := m.loadBackupInfo(infoPath)
 		if err != nil {
 			if m.verbose {
-				fmt.Printf("Warning: failed to load backup info %s: %v\n", infoPath, err)
+				fmt.Fprintf(m.out, "Warning: failed to load backup info %s: %v\n", infoPath, err)
 			}
 			continue
 		}
@@ -182,6 +391,113 @@ func (m *Manager) ListBackups(appName string) ([]*config.BackupInfo, error) {
 	return backups, nil
 }
 
+// ListVersions returns all backup versions of a configuration path, newest first. A backup
+// belongs to the path when it was taken of the same configured source, at the same location, or
+// of the same store destination, so backups are still found after a path's source moves, e.g. to
+// a new version of the application. Backups older versions kept under "temp" are included when
+// they were taken at the path's location.
+func (m *Manager) ListVersions(appName string, configPath *config.Path) ([]*config.BackupInfo, error) {
+	backups, err := m.ListBackups(appName)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := m.ListBackups(legacySyncApp)
+	if err != nil {
+		return nil, err
+	}
+
+	sourcePath := m.expandPath(configPath.Source)
+	var versions []*config.BackupInfo
+	for _, backup := range backups {
+		if backup.Version != "" && belongsTo(backup, configPath, sourcePath) {
+			versions = append(versions, backup)
+		}
+	}
+	if appName != legacySyncApp {
+		for _, backup := range legacy {
+			if backup.Version != "" && backup.OriginalPath == sourcePath {
+				versions = append(versions, backup)
+			}
+		}
+	}
+
+	SortNewestFirst(versions)
+	return versions, nil
+}
+
+// belongsTo reports whether a backup was taken of a configuration path, whose source expands to sourcePath
+func belongsTo(backup *config.BackupInfo, configPath *config.Path, sourcePath string) bool {
+	switch {
+	case backup.Source != "" && backup.Source == configPath.Source:
+		return true
+	case backup.OriginalPath == sourcePath:
+		return true
+	default:
+		return backup.Destination != "" && backup.Destination == configPath.Destination
+	}
+}
+
+// RelinkPath moves the backups of a configuration path over to the path it became, such as
+// when a migration gives it a new source and destination, so restoring the new path finds them.
+// Backups older versions kept under "temp" are moved to the application. The backed up files
+// stay where they are. It returns the number of backups relinked.
+func (m *Manager) RelinkPath(appName string, from, to *config.Path) (int, error) {
+	versions, err := m.ListVersions(appName, from)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, backup := range versions {
+		oldInfoPath := m.infoPathFor(backup)
+		backup.AppName, backup.Source, backup.Destination = appName, to.Source, to.Destination
+		if err := m.saveBackupInfo(backup); err != nil {
+			return 0, fmt.Errorf("failed to save backup info: %w", err)
+		}
+		if newInfoPath := m.infoPathFor(backup); newInfoPath != oldInfoPath {
+			if err := m.fs.Remove(oldInfoPath); err != nil {
+				return 0, fmt.Errorf("failed to remove backup info: %w", err)
+			}
+		}
+	}
+	return len(versions), nil
+}
+
+// AppSize returns the space all backups of an application take up, including every version
+// and their metadata
+func (m *Manager) AppSize(appName string) (int64, error) {
+	var total int64
+	for _, dir := range []string{"files", "versions", "info"} {
+		size, err := m.calculateSize(filepath.Join(m.backupDir, dir, appName))
+		if err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to measure %s backups of %s: %w", dir, appName, err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// PurgeApp deletes every backup of an application, including all versions and their metadata,
+// and returns the space freed
+func (m *Manager) PurgeApp(appName string) (int64, error) {
+	size, err := m.AppSize(appName)
+	if err != nil {
+		return 0, err
+	}
+	for _, dir := range []string{"files", "versions", "info"} {
+		if err := m.fs.RemoveAll(filepath.Join(m.backupDir, dir, appName)); err != nil {
+			return 0, fmt.Errorf("failed to remove %s backups of %s: %w", dir, appName, err)
+		}
+	}
+	return size, nil
+}
+
+// SortNewestFirst orders backups by creation time, most recent first
+func SortNewestFirst(backups []*config.BackupInfo) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+}
+
 // CleanupBackups removes old backups for an application
 func (m *Manager) CleanupBackups(appName string, keepDays int) error {
 	backups, err := m.ListBackups(appName)
@@ -195,22 +511,22 @@ func (m *Manager) CleanupBackups(appName string, keepDays int) error {
 	for _, backup := range backups {
 		if backup.CreatedAt.Before(cutoff) {
 			if m.verbose {
-				fmt.Printf("Removing old backup: %s (created %s)\n",
+				fmt.Fprintf(m.out, "Removing old backup: %s (created %s)\n",
 					backup.BackupPath, backup.CreatedAt.Format(time.RFC3339))
 			}
 
 			// Remove backup file/directory
-			if err := os.RemoveAll(backup.BackupPath); err != nil {
+			if err := m.fs.RemoveAll(backup.BackupPath); err != nil {
 				if m.verbose {
-					fmt.Printf("Warning: failed to remove backup file %s: %v\n", backup.BackupPath, err)
+					fmt.Fprintf(m.out, "Warning: failed to remove backup file %s: %v\n", backup.BackupPath, err)
 				}
 			}
 
 			// Remove backup info file
-			infoPath := m.getBackupInfoPath(appName, backup.OriginalPath)
-			if err := os.Remove(infoPath); err != nil {
+			infoPath := m.infoPathFor(backup)
+			if err := m.fs.Remove(infoPath); err != nil {
 				if m.verbose {
-					fmt.Printf("Warning: failed to remove backup info %s: %v\n", infoPath, err)
+					fmt.Fprintf(m.out, "Warning: failed to remove backup info %s: %v\n", infoPath, err)
 				}
 			}
 
@@ -219,7 +535,7 @@ func (m *Manager) CleanupBackups(appName string, keepDays int) error {
 	}
 
 	if m.verbose && removed > 0 {
-		fmt.Printf("Cleaned up %d old backup(s) for %s\n", removed, appName)
+		fmt.Fprintf(m.out, "Cleaned up %d old backup(s) for %s\n", removed, appName)
 	}
 
 	return nil
@@ -254,29 +570,33 @@ func (m *Manager) ValidateBackup(backupInfo *config.BackupInfo) error {
 		}
 	}
 
+	// Unpack archives to make sure they still decompress
+	if backupInfo.Compression != "" {
+		file, err := m.fs.Open(backupInfo.BackupPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		if err := tarball.Verify(m.ctx, file); err != nil {
+			return fmt.Errorf("backup archive is damaged: %w", err)
+		}
+	}
+
 	return nil
 }
 
 // Helper methods
 
 func (m *Manager) expandPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		return filepath.Join(m.homeDir, path[2:])
-	}
-	return path
+	return fsops.ExpandHome(path, m.homeDir)
 }
 
 func (m *Manager) pathExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+	return fsys.Exists(m.fs, path)
 }
 
 func (m *Manager) isSymlink(path string) bool {
-	info, err := os.Lstat(path)
-	if err != nil {
-		return false
-	}
-	return info.Mode()&os.ModeSymlink != 0
+	return fsys.IsSymlink(m.fs, path)
 }
 
 func (m *Manager) getBackupPath(appName, destination string) string {
@@ -295,64 +615,139 @@ func (m *Manager) getBackupInfoPath(appName, originalPath string) string {
 	return filepath.Join(m.backupDir, "info", appName, safeName+".yaml")
 }
 
-func (m *Manager) copyPath(src, dst string) error {
-	srcInfo, err := os.Stat(src)
+// getVersionPath returns where a specific backup version of a destination is stored
+func (m *Manager) getVersionPath(appName, destination, version string) string {
+	safeName := strings.ReplaceAll(destination, "/", "_")
+	safeName = strings.ReplaceAll(safeName, " ", "_")
+
+	return filepath.Join(m.backupDir, "versions", appName, safeName, version)
+}
+
+// infoPathFor returns the metadata file of a backup, which depends on whether it is versioned
+func (m *Manager) infoPathFor(backup *config.BackupInfo) string {
+	infoPath := m.getBackupInfoPath(backup.AppName, backup.OriginalPath)
+	if backup.Version == "" {
+		return infoPath
+	}
+	return strings.TrimSuffix(infoPath, ".yaml") + "@" + backup.Version + ".yaml"
+}
+
+// nextVersionTime returns a creation time whose version identifier is not yet used for the path
+func (m *Manager) nextVersionTime(appName, originalPath string) time.Time {
+	createdAt := time.Now()
+	for {
+		candidate := &config.BackupInfo{AppName: appName, OriginalPath: originalPath, Version: createdAt.Format(VersionFormat)}
+		if !m.pathExists(m.infoPathFor(candidate)) {
+			return createdAt
+		}
+		createdAt = createdAt.Add(time.Millisecond)
+	}
+}
+
+// resolveBackup finds the backup to restore for a path and its metadata, falling back to a
+// pre-versioning backup, whose metadata is nil when it has none
+func (m *Manager) resolveBackup(appName string, configPath *config.Path, version string) (string, *config.BackupInfo, error) {
+	versions, err := m.ListVersions(appName, configPath)
 	if err != nil {
-		return err
+		return "", nil, err
+	}
+
+	for _, backup := range versions {
+		if version == "" && backup.Reason == ReasonPreRestore {
+			continue
+		}
+		if strings.HasPrefix(backup.Version, version) {
+			return backup.BackupPath, backup, nil
+		}
 	}
 
-	if srcInfo.IsDir() {
-		return m.copyDir(src, dst)
+	if version != "" {
+		return "", nil, fmt.Errorf("backup version %s not found for %s", version, configPath.Source)
 	}
-	return m.copyFile(src, dst)
+
+	backupPath := m.getBackupPath(appName, configPath.Destination)
+	backupInfo, err := m.loadBackupInfo(m.getBackupInfoPath(appName, m.expandPath(configPath.Source)))
+	if err != nil || backupInfo.BackupPath != backupPath {
+		return backupPath, nil, nil
+	}
+	return backupPath, backupInfo, nil
+}
+
+func (m *Manager) copyPath(src, dst string) error {
+	return m.copyPathIgnoring(src, dst, nil)
 }
 
-func (m *Manager) copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
+// copyPathIgnoring copies a file or directory, leaving out directory entries that match the ignore rules
+func (m *Manager) copyPathIgnoring(src, dst string, ignored *ignore.Matcher) error {
+	return fsops.CopyPath(m.ctx, m.fs, src, dst, func(rel string, info os.FileInfo) bool {
+		return ignored.Match(rel, info.IsDir())
+	})
+}
+
+// archiveEntry is the name a backed up path is stored under in a backup archive
+const archiveEntry = "backup"
+
+// writeArchive writes a file or directory to a compressed tar archive at dst, leaving out
+// directory entries that match the ignore rules
+func (m *Manager) writeArchive(src, dst string, ignored *ignore.Matcher) error {
+	file, err := m.fs.Create(dst)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = srcFile.Close() }()
+	defer func() { _ = file.Close() }()
 
-	dstFile, err := os.Create(dst)
+	writer, err := tarball.NewWriter(m.ctx, file, m.compression, 0)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = dstFile.Close() }()
-
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	err = writer.AddTree(m.fs, src, archiveEntry, func(rel string, info os.FileInfo) bool {
+		return ignored.Match(rel, info.IsDir())
+	})
+	if err != nil {
 		return err
 	}
-
-	// Copy permissions
-	srcInfo, statErr := srcFile.Stat()
-	if statErr != nil {
-		return statErr
+	if err := writer.Close(); err != nil {
+		return err
 	}
-	return os.Chmod(dst, srcInfo.Mode())
+	return file.Sync()
 }
 
-func (m *Manager) copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// extractArchive unpacks a backup archive into a temporary directory beside sourcePath, so it
+// can be renamed into place. It returns the unpacked path and a function removing what is left
+// of the temporary directory.
+func (m *Manager) extractArchive(archivePath, sourcePath string) (string, func(), error) {
+	parent := filepath.Dir(sourcePath)
+	if err := m.fs.MkdirAll(parent, 0755); err != nil {
+		return "", nil, err
+	}
+	tempDir, err := fsys.MkdirTemp(m.fs, parent, ".configsync-restore-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = m.fs.RemoveAll(tempDir) }
 
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		dstPath := filepath.Join(dst, relPath)
+	file, err := m.fs.Open(archivePath)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer func() { _ = file.Close() }()
 
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-		return m.copyFile(path, dstPath)
-	})
+	if err := tarball.Extract(m.ctx, m.fs, file, tempDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	unpacked := filepath.Join(tempDir, archiveEntry)
+	if _, err := m.fs.Lstat(unpacked); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("archive holds no backup: %w", err)
+	}
+	return unpacked, cleanup, nil
 }
 
 func (m *Manager) calculateChecksum(path string) (string, error) {
-	file, err := os.Open(path)
+	file, err := m.fs.Open(path)
 	if err != nil {
 		return "", err
 	}
@@ -367,7 +762,7 @@ func (m *Manager) calculateChecksum(path string) (string, error) {
 }
 
 func (m *Manager) calculateSize(path string) (int64, error) {
-	info, err := os.Stat(path)
+	info, err := m.fs.Stat(path)
 	if err != nil {
 		return 0, err
 	}
@@ -377,7 +772,7 @@ func (m *Manager) calculateSize(path string) (int64, error) {
 	}
 
 	var size int64
-	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+	err = fsys.Walk(m.fs, path, func(_ string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -391,11 +786,11 @@ func (m *Manager) calculateSize(path string) (int64, error) {
 }
 
 func (m *Manager) saveBackupInfo(info *config.BackupInfo) error {
-	infoPath := m.getBackupInfoPath(info.AppName, info.OriginalPath)
+	infoPath := m.infoPathFor(info)
 
 	// Create info directory
 	infoDir := filepath.Dir(infoPath)
-	if err := os.MkdirAll(infoDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(infoDir, 0755); err != nil {
 		return err
 	}
 
@@ -404,11 +799,11 @@ func (m *Manager) saveBackupInfo(info *config.BackupInfo) error {
 		return err
 	}
 
-	return os.WriteFile(infoPath, data, 0644)
+	return m.fs.WriteFile(infoPath, data, 0644)
 }
 
 func (m *Manager) loadBackupInfo(infoPath string) (*config.BackupInfo, error) {
-	data, err := os.ReadFile(infoPath)
+	data, err := m.fs.ReadFile(infoPath)
 	if err != nil {
 		return nil, err
 	}
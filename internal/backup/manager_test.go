@@ -1,6 +1,9 @@
 package backup
 
 import (
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,6 +12,7 @@ import (
 
 	"github.com/dotbrains/configsync/internal/config"
 	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/fsys"
 )
 
 func TestNewManager(t *testing.T) {
@@ -61,7 +65,11 @@ func TestBackupPath(t *testing.T) {
 	}
 
 	// Verify backup was created
-	backupPath := manager.getBackupPath(constants.TestAppName, "test.conf")
+	versions, err := manager.ListVersions(constants.TestAppName, configPath)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("Expected 1 backup version, got %d (%v)", len(versions), err)
+	}
+	backupPath := versions[0].BackupPath
 	if !manager.pathExists(backupPath) {
 		t.Errorf("Backup file not created: %s", backupPath)
 	}
@@ -136,7 +144,11 @@ func TestBackupPathDirectory(t *testing.T) {
 	}
 
 	// Verify backup directory was created
-	backupPath := manager.getBackupPath(constants.TestAppName, "testdir")
+	versions, err := manager.ListVersions(constants.TestAppName, configPath)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("Expected 1 backup version, got %d (%v)", len(versions), err)
+	}
+	backupPath := versions[0].BackupPath
 	if !manager.pathExists(backupPath) {
 		t.Errorf("Backup directory not created: %s", backupPath)
 	}
@@ -162,6 +174,35 @@ func TestBackupPathDirectory(t *testing.T) {
 	}
 }
 
+func TestBackupPathCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false).WithContext(ctx)
+
+	testDir := filepath.Join(tempDir, "testdir")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	configPath := &config.Path{Source: testDir, Destination: "testdir", Type: config.PathTypeDirectory}
+	if err := manager.BackupPath(constants.TestAppName, configPath); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	versions, _ := manager.ListVersions(constants.TestAppName, configPath)
+	if len(versions) != 0 {
+		t.Errorf("Expected no backup versions after cancellation, got %d", len(versions))
+	}
+	entries, _ := os.ReadDir(filepath.Join(tempDir, "backups", constants.TestAppName))
+	for _, entry := range entries {
+		t.Errorf("Expected partial backup to be removed, found %s", entry.Name())
+	}
+}
+
 func TestBackupPathNonExistent(t *testing.T) {
 	tempDir := t.TempDir()
 	backupDir := filepath.Join(tempDir, "backups")
@@ -292,6 +333,84 @@ func TestRestorePath(t *testing.T) {
 	}
 }
 
+func TestBackupAndRestoreInMemory(t *testing.T) {
+	files := fsys.NewMem()
+	homeDir := filepath.Join(string(filepath.Separator), "Users", "test")
+	manager := NewManager(filepath.Join(homeDir, ".configsync", "backups"), homeDir, false).WithFS(files)
+
+	settingsDir := filepath.Join(homeDir, "Library", "Application Support", "App")
+	if err := files.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatalf("Failed to create settings directory: %v", err)
+	}
+	settingsFile := filepath.Join(settingsDir, "settings.json")
+	if err := files.WriteFile(settingsFile, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create settings file: %v", err)
+	}
+
+	configPath := &config.Path{Source: settingsDir, Destination: "App", Type: config.PathTypeDirectory}
+	if err := manager.BackupPath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	versions, err := manager.ListVersions(constants.TestAppName, configPath)
+	if err != nil || len(versions) != 1 || versions[0].Size != int64(len("original")) {
+		t.Fatalf("Expected one backup version of the directory, got %v (%v)", versions, err)
+	}
+
+	if err := files.WriteFile(settingsFile, []byte("modified"), 0644); err != nil {
+		t.Fatalf("Failed to modify settings file: %v", err)
+	}
+	if err := manager.RestorePath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if data, err := files.ReadFile(settingsFile); err != nil || string(data) != "original" {
+		t.Errorf("Expected restored settings, got %q, %v", data, err)
+	}
+}
+
+func TestRestorePathReplacesLinks(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	originalFile := filepath.Join(tempDir, "original.conf")
+	if err := os.WriteFile(originalFile, []byte("original content"), 0644); err != nil {
+		t.Fatalf("Failed to create original file: %v", err)
+	}
+	configPath := &config.Path{
+		Source:      originalFile,
+		Destination: "original.conf",
+		Type:        config.PathTypeFile,
+		Links:       []string{"~/legacy.conf"},
+	}
+	if err := manager.BackupPath("testapp", configPath); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	// Both locations link to the store copy, as after syncing
+	storeFile := filepath.Join(tempDir, "store", "original.conf")
+	if err := os.MkdirAll(filepath.Dir(storeFile), 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.Rename(originalFile, storeFile); err != nil {
+		t.Fatalf("Failed to move original file: %v", err)
+	}
+	legacyFile := filepath.Join(tempDir, "legacy.conf")
+	for _, location := range []string{originalFile, legacyFile} {
+		if err := os.Symlink(storeFile, location); err != nil {
+			t.Fatalf("Failed to link %s: %v", location, err)
+		}
+	}
+
+	if err := manager.RestorePath("testapp", configPath); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if info, err := os.Lstat(legacyFile); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Expected the link to be replaced by a regular file, got %v %v", info, err)
+	}
+	if data, _ := os.ReadFile(legacyFile); string(data) != "original content" {
+		t.Errorf("Expected the link to hold the restored content, got %q", data)
+	}
+}
+
 func TestRestorePathNonExistentBackup(t *testing.T) {
 	tempDir := t.TempDir()
 	backupDir := filepath.Join(tempDir, "backups")
@@ -385,6 +504,74 @@ func TestListBackups(t *testing.T) {
 	}
 }
 
+func TestAppSize(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	size, err := manager.AppSize("testapp")
+	if err != nil {
+		t.Fatalf("AppSize failed: %v", err)
+	}
+	if size != 0 {
+		t.Errorf("Expected no space used without backups, got %d", size)
+	}
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(testFile, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	configPath := &config.Path{Source: testFile, Destination: "test.conf", Type: config.PathTypeFile}
+	for i := 0; i < 2; i++ {
+		if err := manager.BackupPath("testapp", configPath); err != nil {
+			t.Fatalf("BackupPath failed: %v", err)
+		}
+	}
+
+	size, err = manager.AppSize("testapp")
+	if err != nil {
+		t.Fatalf("AppSize failed: %v", err)
+	}
+	// Both versions of the file plus their metadata
+	if size <= 20 {
+		t.Errorf("Expected both versions and their metadata to be counted, got %d bytes", size)
+	}
+
+	other, err := manager.AppSize("otherapp")
+	if err != nil || other != 0 {
+		t.Errorf("Expected other apps to be unaffected, got %d (%v)", other, err)
+	}
+}
+
+func TestPurgeApp(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(testFile, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	configPath := &config.Path{Source: testFile, Destination: "test.conf", Type: config.PathTypeFile}
+	for _, appName := range []string{"testapp", "otherapp"} {
+		if err := manager.BackupPath(appName, configPath); err != nil {
+			t.Fatalf("BackupPath failed: %v", err)
+		}
+	}
+
+	freed, err := manager.PurgeApp("testapp")
+	if err != nil {
+		t.Fatalf("PurgeApp failed: %v", err)
+	}
+	if freed <= 10 {
+		t.Errorf("Expected the purged backups to be measured, got %d bytes", freed)
+	}
+	if backups, _ := manager.ListBackups("testapp"); len(backups) != 0 {
+		t.Errorf("Expected no backups left, got %d", len(backups))
+	}
+	if backups, _ := manager.ListBackups("otherapp"); len(backups) != 1 {
+		t.Errorf("Expected other apps' backups to be kept, got %d", len(backups))
+	}
+}
+
 func TestCleanupBackups(t *testing.T) {
 	tempDir := t.TempDir()
 	backupDir := filepath.Join(tempDir, "backups")
@@ -705,3 +892,331 @@ func BenchmarkCalculateChecksum(b *testing.B) {
 		}
 	}
 }
+
+func TestBackupPathKeepsVersions(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	configPath := &config.Path{
+		Source:      testFile,
+		Destination: "test.conf",
+		Type:        config.PathTypeFile,
+	}
+
+	for _, content := range []string{"first", "second"} {
+		if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := manager.BackupPath("testapp", configPath); err != nil {
+			t.Fatalf("BackupPath failed: %v", err)
+		}
+	}
+
+	versions, err := manager.ListVersions("testapp", configPath)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].Version == versions[1].Version {
+		t.Error("Expected distinct version identifiers")
+	}
+
+	// Restore the older version explicitly
+	if err := manager.RestorePathVersion("testapp", configPath, versions[1].Version); err != nil {
+		t.Fatalf("RestorePathVersion failed: %v", err)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "first" {
+		t.Errorf("Expected older version to be restored, got %q", string(content))
+	}
+
+	// Restoring without a version uses the newest backup
+	if err := manager.RestorePath("testapp", configPath); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "second" {
+		t.Errorf("Expected newest version to be restored, got %q", string(content))
+	}
+
+	if err := manager.RestorePathVersion("testapp", configPath, "19700101"); err == nil {
+		t.Error("Expected error for unknown version")
+	}
+}
+
+func TestRestorePathFromUnversionedBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	configPath := &config.Path{
+		Source:      filepath.Join(tempDir, "legacy.conf"),
+		Destination: "legacy.conf",
+		Type:        config.PathTypeFile,
+	}
+
+	legacyBackup := manager.getBackupPath("testapp", configPath.Destination)
+	if err := os.MkdirAll(filepath.Dir(legacyBackup), 0755); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+	if err := os.WriteFile(legacyBackup, []byte("legacy"), 0644); err != nil {
+		t.Fatalf("Failed to write legacy backup: %v", err)
+	}
+
+	if err := manager.RestorePath("testapp", configPath); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if content, _ := os.ReadFile(configPath.Source); string(content) != "legacy" {
+		t.Errorf("Expected legacy backup to be restored, got %q", string(content))
+	}
+}
+
+func TestCompressedBackupRoundTrip(t *testing.T) {
+	for _, compression := range []string{"gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			tempDir := t.TempDir()
+			manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false).WithCompression(compression)
+
+			testDir := filepath.Join(tempDir, "testdir")
+			if err := os.MkdirAll(filepath.Join(testDir, "nested"), 0755); err != nil {
+				t.Fatalf("Failed to create test directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(testDir, "nested", "file.txt"), []byte("content"), 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			if err := os.Symlink("nested/file.txt", filepath.Join(testDir, "link")); err != nil {
+				t.Fatalf("Failed to create symlink: %v", err)
+			}
+			configPath := &config.Path{Source: testDir, Destination: "testdir", Type: config.PathTypeDirectory}
+
+			if err := manager.BackupPath(constants.TestAppName, configPath); err != nil {
+				t.Fatalf("BackupPath failed: %v", err)
+			}
+			versions, err := manager.ListVersions(constants.TestAppName, configPath)
+			if err != nil || len(versions) != 1 {
+				t.Fatalf("Expected 1 backup version, got %d (%v)", len(versions), err)
+			}
+			backup := versions[0]
+			if backup.Compression != compression || backup.Checksum == "" {
+				t.Errorf("Expected a checksummed %s archive, got %+v", compression, backup)
+			}
+			if info, err := os.Stat(backup.BackupPath); err != nil || !info.Mode().IsRegular() {
+				t.Fatalf("Expected the backup to be a single archive, got %v", err)
+			}
+			if err := manager.ValidateBackup(backup); err != nil {
+				t.Errorf("ValidateBackup failed: %v", err)
+			}
+
+			if err := os.WriteFile(filepath.Join(testDir, "nested", "file.txt"), []byte("changed"), 0644); err != nil {
+				t.Fatalf("Failed to change test file: %v", err)
+			}
+			if err := manager.RestorePath(constants.TestAppName, configPath); err != nil {
+				t.Fatalf("RestorePath failed: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(testDir, "link"))
+			if err != nil || string(data) != "content" {
+				t.Errorf("Expected the directory to be restored through its symlink, got %q, %v", data, err)
+			}
+			if info, err := os.Stat(filepath.Join(testDir, "nested", "file.txt")); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("Expected the file permissions to be restored, got %v", err)
+			}
+			entries, _ := os.ReadDir(tempDir)
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".configsync-restore-") {
+					t.Errorf("Expected the temporary directory to be removed, found %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBackupDamagedArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false).WithCompression("gzip")
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(testFile, []byte(strings.Repeat("test content ", 100)), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	configPath := &config.Path{Source: testFile, Destination: "test.conf", Type: config.PathTypeFile}
+	if err := manager.BackupPath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	versions, _ := manager.ListVersions(constants.TestAppName, configPath)
+	backup := versions[0]
+
+	// Truncate the archive and record its new size and checksum, so only decompressing it can
+	// tell it is damaged
+	data, err := os.ReadFile(backup.BackupPath)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if err := os.WriteFile(backup.BackupPath, data[:len(data)/2], 0644); err != nil {
+		t.Fatalf("Failed to truncate archive: %v", err)
+	}
+	backup.Size = int64(len(data) / 2)
+	backup.Checksum, _ = manager.calculateChecksum(backup.BackupPath)
+
+	err = manager.ValidateBackup(backup)
+	if err == nil || !strings.Contains(err.Error(), "damaged") {
+		t.Errorf("Expected a damaged archive error, got %v", err)
+	}
+
+	if err := manager.RestorePath(constants.TestAppName, configPath); err == nil {
+		t.Error("Expected restoring a damaged archive to fail")
+	}
+	if data, err := os.ReadFile(testFile); err != nil || !strings.HasPrefix(string(data), "test content") {
+		t.Errorf("Expected the live file to be left alone, got %v", err)
+	}
+}
+
+func TestRestorePathRefusesInvalidBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(testFile, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	configPath := &config.Path{Source: testFile, Destination: "test.conf", Type: config.PathTypeFile}
+	if err := manager.BackupPath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	versions, _ := manager.ListVersions(constants.TestAppName, configPath)
+	if err := os.WriteFile(versions[0].BackupPath, []byte("corrupt!"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt backup: %v", err)
+	}
+	if err := os.WriteFile(testFile, []byte("live"), 0644); err != nil {
+		t.Fatalf("Failed to change test file: %v", err)
+	}
+
+	err := manager.RestorePath(constants.TestAppName, configPath)
+	if !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("Expected ErrInvalidBackup, got %v", err)
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "live" {
+		t.Errorf("Expected the live file to be left alone, got %q", data)
+	}
+
+	if err := manager.WithOutput(io.Discard).WithForce(true).RestorePath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("Forced RestorePath failed: %v", err)
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "corrupt!" {
+		t.Errorf("Expected the forced restore to write the backup, got %q", data)
+	}
+}
+
+func TestRestorePathKeepsSafetyBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	testFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(testFile, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	configPath := &config.Path{Source: testFile, Destination: "test.conf", Type: config.PathTypeFile}
+	if err := manager.BackupPath(constants.TestAppName, configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	if err := os.WriteFile(testFile, []byte("edited"), 0644); err != nil {
+		t.Fatalf("Failed to change test file: %v", err)
+	}
+
+	// Restoring twice keeps restoring the requested backup, not the safety backup
+	for i := 0; i < 2; i++ {
+		if err := manager.RestorePath(constants.TestAppName, configPath); err != nil {
+			t.Fatalf("RestorePath failed: %v", err)
+		}
+		if data, _ := os.ReadFile(testFile); string(data) != "original" {
+			t.Errorf("Expected the original content to be restored, got %q", data)
+		}
+	}
+
+	versions, _ := manager.ListVersions(constants.TestAppName, configPath)
+	if len(versions) != 3 || versions[0].Reason != ReasonPreRestore || versions[2].Reason != "" {
+		t.Fatalf("Expected two safety backups after the original, got %+v", versions)
+	}
+
+	// The first safety backup holds the edited state and can be restored by version
+	if err := manager.RestorePathVersion(constants.TestAppName, configPath, versions[1].Version); err != nil {
+		t.Fatalf("RestorePathVersion failed: %v", err)
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "edited" {
+		t.Errorf("Expected the safety backup to be restored, got %q", data)
+	}
+}
+
+func TestRestorePathAfterRename(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	oldPath := &config.Path{Source: filepath.Join(tempDir, "IDE2024.1", "options"), Destination: "IDE/options", Type: config.PathTypeFile}
+	if err := os.MkdirAll(filepath.Dir(oldPath.Source), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(oldPath.Source, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := manager.BackupPath("testapp", oldPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+
+	// A new source keeping the store destination still finds the backup
+	followed := &config.Path{Source: filepath.Join(tempDir, "IDE2024.2", "options"), Destination: "IDE/options", Type: config.PathTypeFile}
+	if err := manager.RestorePath("testapp", followed); err != nil {
+		t.Fatalf("RestorePath failed: %v", err)
+	}
+	if content, _ := os.ReadFile(followed.Source); string(content) != "original" {
+		t.Errorf("Expected the backup to be restored to the new source, got %q", string(content))
+	}
+
+	// A path moved to a new source and destination finds it once relinked
+	moved := &config.Path{Source: filepath.Join(tempDir, "IDE", "options"), Destination: "IDE 2/options", Type: config.PathTypeFile}
+	if versions, _ := manager.ListVersions("testapp", moved); len(versions) != 0 {
+		t.Fatalf("Expected no backups of the moved path before relinking, got %d", len(versions))
+	}
+	if relinked, err := manager.RelinkPath("testapp", oldPath, moved); err != nil || relinked != 1 {
+		t.Fatalf("RelinkPath() = %d, %v; want 1 backup relinked", relinked, err)
+	}
+	versions, err := manager.ListVersions("testapp", moved)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("Expected the relinked backup, got %d, %v", len(versions), err)
+	}
+	if versions[0].Source != moved.Source || versions[0].OriginalPath != oldPath.Source {
+		t.Errorf("Expected the backup to keep where it was taken and link to the moved path, got %+v", versions[0])
+	}
+}
+
+func TestListVersionsFindsLegacySyncBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(filepath.Join(tempDir, "backups"), tempDir, false)
+
+	configPath := &config.Path{Source: filepath.Join(tempDir, "test.conf"), Destination: "test.conf", Type: config.PathTypeFile}
+	if err := os.WriteFile(configPath.Source, []byte("synced"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	// Older versions kept the backups taken by sync under "temp"
+	if err := manager.BackupPath(legacySyncApp, configPath); err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+
+	versions, err := manager.ListVersions("testapp", configPath)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("Expected the legacy backup, got %d, %v", len(versions), err)
+	}
+	other := &config.Path{Source: filepath.Join(tempDir, "other.conf"), Destination: "test.conf", Type: config.PathTypeFile}
+	if versions, _ := manager.ListVersions("testapp", other); len(versions) != 0 {
+		t.Errorf("Expected legacy backups to match by location only, got %d", len(versions))
+	}
+
+	if relinked, err := manager.RelinkPath("testapp", configPath, configPath); err != nil || relinked != 1 {
+		t.Fatalf("RelinkPath() = %d, %v; want 1 backup relinked", relinked, err)
+	}
+	if backups, _ := manager.ListBackups(legacySyncApp); len(backups) != 0 {
+		t.Errorf("Expected the legacy backup to move to the application, %d left", len(backups))
+	}
+	if backups, _ := manager.ListBackups("testapp"); len(backups) != 1 || backups[0].AppName != "testapp" {
+		t.Errorf("Expected the backup under the application, got %+v", backups)
+	}
+}
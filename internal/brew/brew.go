@@ -0,0 +1,276 @@
+// Package brew provides functionality for recording and installing the Homebrew packages of managed applications.
+package brew
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// BrewfileName is the name of the Brewfile written into exported bundles
+const BrewfileName = "Brewfile"
+
+// brewLocations are checked for the brew command when it is not on PATH, as happens in launchd jobs
+var brewLocations = []string{"/opt/homebrew/bin/brew", "/usr/local/bin/brew", "/home/linuxbrew/.linuxbrew/bin/brew"}
+
+// Manager queries and installs Homebrew packages
+type Manager struct {
+	runCommand func(name string, args ...string) ([]byte, error)
+	brewPath   string
+	verbose    bool
+}
+
+// Inventory lists the Homebrew packages installed on this system
+type Inventory struct {
+	casks    []cask
+	formulae []formula
+}
+
+type cask struct {
+	Token     string            `json:"token"`
+	FullToken string            `json:"full_token"`
+	Names     []string          `json:"name"`
+	Artifacts []json.RawMessage `json:"artifacts"`
+}
+
+type formula struct {
+	Name     string   `json:"name"`
+	FullName string   `json:"full_name"`
+	Aliases  []string `json:"aliases"`
+}
+
+// NewManager creates a new Homebrew manager
+func NewManager(verbose bool) *Manager {
+	return &Manager{
+		verbose: verbose,
+		runCommand: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).CombinedOutput()
+		},
+	}
+}
+
+// Available reports whether Homebrew is installed
+func (m *Manager) Available() bool {
+	return m.brew() != ""
+}
+
+// Installed returns the casks and formulae that are currently installed
+func (m *Manager) Installed() (*Inventory, error) {
+	brewPath := m.brew()
+	if brewPath == "" {
+		return nil, fmt.Errorf("homebrew is not installed (see https://brew.sh)")
+	}
+
+	output, err := m.runCommand(brewPath, "info", "--json=v2", "--installed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed Homebrew packages: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	var info struct {
+		Casks    []cask    `json:"casks"`
+		Formulae []formula `json:"formulae"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse Homebrew package list: %w", err)
+	}
+	return &Inventory{casks: info.Casks, formulae: info.Formulae}, nil
+}
+
+// Formulae returns the names of the installed formulae, i.e. command-line tools
+func (m *Manager) Formulae() ([]string, error) {
+	brewPath := m.brew()
+	if brewPath == "" {
+		return nil, fmt.Errorf("homebrew is not installed (see https://brew.sh)")
+	}
+
+	output, err := m.runCommand(brewPath, "list", "--formula", "-1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed Homebrew formulae: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// Install installs a package with brew install
+func (m *Manager) Install(pkg config.BrewPackage) error {
+	brewPath := m.brew()
+	if brewPath == "" {
+		return fmt.Errorf("homebrew is not installed (see https://brew.sh)")
+	}
+
+	args := []string{"install"}
+	if pkg.Type == config.BrewCask {
+		args = append(args, "--cask")
+	}
+	args = append(args, pkg.Name)
+
+	if m.verbose {
+		fmt.Printf("  Running: brew %s\n", strings.Join(args, " "))
+	}
+	if output, err := m.runCommand(brewPath, args...); err != nil {
+		return fmt.Errorf("brew install %s failed: %w: %s", pkg.Name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Packages returns the installed package that provides each application, sorted by application
+func (inv *Inventory) Packages(apps map[string]*config.AppConfig) []config.BrewPackage {
+	var packages []config.BrewPackage
+	for appName, appConfig := range apps {
+		if pkg, ok := inv.Match(appConfig); ok {
+			pkg.App = appName
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].App < packages[j].App })
+	return packages
+}
+
+// Match finds the installed cask or formula that provides an application. Casks match on their
+// token, names, or the .app bundle they install; formulae match on their name or aliases.
+func (inv *Inventory) Match(appConfig *config.AppConfig) (config.BrewPackage, bool) {
+	keys := map[string]bool{normalize(appConfig.Name): true, normalize(appConfig.DisplayName): true}
+	delete(keys, "")
+
+	for _, c := range inv.casks {
+		candidates := append([]string{c.Token}, c.Names...)
+		candidates = append(candidates, c.apps()...)
+		if matchesAny(keys, candidates) {
+			return config.BrewPackage{Name: qualifiedName(c.Token, c.FullToken), Type: config.BrewCask}, true
+		}
+	}
+
+	for _, f := range inv.formulae {
+		if matchesAny(keys, append([]string{f.Name}, f.Aliases...)) {
+			return config.BrewPackage{Name: qualifiedName(f.Name, f.FullName), Type: config.BrewFormula}, true
+		}
+	}
+
+	return config.BrewPackage{}, false
+}
+
+// Has reports whether a package is installed
+func (inv *Inventory) Has(pkg config.BrewPackage) bool {
+	if pkg.Type == config.BrewCask {
+		for _, c := range inv.casks {
+			if pkg.Name == c.Token || pkg.Name == c.FullToken {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range inv.formulae {
+		if pkg.Name == f.Name || pkg.Name == f.FullName {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBrewfile writes packages in the Brewfile format understood by brew bundle
+func WriteBrewfile(path string, packages []config.BrewPackage) error {
+	if err := os.WriteFile(path, Brewfile(packages), 0644); err != nil {
+		return fmt.Errorf("failed to write Brewfile: %w", err)
+	}
+	return nil
+}
+
+// Brewfile returns packages in the Brewfile format understood by brew bundle
+func Brewfile(packages []config.BrewPackage) []byte {
+	var b strings.Builder
+	b.WriteString("# Generated by configsync; install with: brew bundle --file Brewfile\n")
+	taps := make(map[string]bool)
+	for _, pkg := range packages {
+		keyword := "brew"
+		if pkg.Type == config.BrewCask {
+			keyword = "cask"
+		}
+		if tap, ok := tapOf(pkg.Name); ok && !taps[tap] {
+			taps[tap] = true
+			fmt.Fprintf(&b, "tap %q\n", tap)
+		}
+		fmt.Fprintf(&b, "%s %q # %s\n", keyword, pkg.Name, pkg.App)
+	}
+	return []byte(b.String())
+}
+
+// Helper functions
+
+// brew returns the path of the brew command, or "" when Homebrew is not installed
+func (m *Manager) brew() string {
+	if m.brewPath != "" {
+		return m.brewPath
+	}
+	if path, err := exec.LookPath("brew"); err == nil {
+		m.brewPath = path
+		return path
+	}
+	for _, path := range brewLocations {
+		if _, err := os.Stat(path); err == nil {
+			m.brewPath = path
+			return path
+		}
+	}
+	return ""
+}
+
+// apps returns the names of the .app bundles a cask installs, without the extension
+func (c cask) apps() []string {
+	var apps []string
+	for _, raw := range c.Artifacts {
+		var artifact map[string][]any
+		if json.Unmarshal(raw, &artifact) != nil {
+			continue
+		}
+		for _, value := range artifact["app"] {
+			if name, ok := value.(string); ok {
+				apps = append(apps, strings.TrimSuffix(name, ".app"))
+			}
+		}
+	}
+	return apps
+}
+
+// qualifiedName returns the tap-qualified name for packages outside the core taps
+func qualifiedName(name, fullName string) string {
+	if strings.Contains(fullName, "/") {
+		return fullName
+	}
+	return name
+}
+
+// tapOf returns the tap of a tap-qualified package name such as "user/repo/name"
+func tapOf(name string) (string, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		return "", false
+	}
+	return parts[0] + "/" + parts[1], true
+}
+
+// matchesAny reports whether any candidate normalizes to one of the keys
+func matchesAny(keys map[string]bool, candidates []string) bool {
+	for _, candidate := range candidates {
+		if keys[normalize(candidate)] {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases a name and drops everything but letters and digits
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package brew
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+const installedJSON = `{
+  "formulae": [
+    {"name": "neovim", "full_name": "neovim", "aliases": ["nvim"]},
+    {"name": "gh", "full_name": "gh", "aliases": []},
+    {"name": "sketchybar", "full_name": "felixkratz/formulae/sketchybar", "aliases": []}
+  ],
+  "casks": [
+    {"token": "visual-studio-code", "full_token": "visual-studio-code", "name": ["Microsoft Visual Studio Code", "VS Code"],
+     "artifacts": [{"app": ["Visual Studio Code.app"]}, {"binary": ["code"]}, {"zap": [{"trash": ["~/.vscode"]}]}]},
+    {"token": "iterm2", "full_token": "iterm2", "name": ["iTerm2"], "artifacts": [{"app": ["iTerm.app"]}]}
+  ]
+}`
+
+// newTestManager creates a manager that runs a fake brew, recording the commands it is given
+func newTestManager(commands *[]string) *Manager {
+	manager := NewManager(false)
+	manager.brewPath = "/usr/local/bin/brew"
+	manager.runCommand = func(name string, args ...string) ([]byte, error) {
+		*commands = append(*commands, strings.Join(args, " "))
+		if args[0] == "info" {
+			return []byte(installedJSON), nil
+		}
+		if args[0] == "list" {
+			return []byte("gh\nneovim\nsketchybar\n"), nil
+		}
+		if args[len(args)-1] == "broken" {
+			return []byte("Error: No available formula"), fmt.Errorf("exit status 1")
+		}
+		return nil, nil
+	}
+	return manager
+}
+
+func TestInventoryPackages(t *testing.T) {
+	var commands []string
+	inventory, err := newTestManager(&commands).Installed()
+	if err != nil {
+		t.Fatalf("Installed failed: %v", err)
+	}
+
+	apps := map[string]*config.AppConfig{
+		"vscode":     config.NewAppConfig("vscode", "Visual Studio Code"),
+		"iterm2":     config.NewAppConfig("iterm2", "iTerm2"),
+		"neovim":     config.NewAppConfig("neovim", "Neovim"),
+		"sketchybar": config.NewAppConfig("sketchybar", "SketchyBar"),
+		"zsh":        config.NewAppConfig("zsh", "Zsh"),
+	}
+
+	packages := inventory.Packages(apps)
+	expected := []config.BrewPackage{
+		{App: "iterm2", Name: "iterm2", Type: config.BrewCask},
+		{App: "neovim", Name: "neovim", Type: config.BrewFormula},
+		{App: "sketchybar", Name: "felixkratz/formulae/sketchybar", Type: config.BrewFormula},
+		{App: "vscode", Name: "visual-studio-code", Type: config.BrewCask},
+	}
+	if len(packages) != len(expected) {
+		t.Fatalf("Expected %d packages, got %+v", len(expected), packages)
+	}
+	for i := range expected {
+		if packages[i] != expected[i] {
+			t.Errorf("Package %d: expected %+v, got %+v", i, expected[i], packages[i])
+		}
+	}
+
+	if !inventory.Has(config.BrewPackage{Name: "gh", Type: config.BrewFormula}) {
+		t.Error("Expected gh to be installed")
+	}
+	if inventory.Has(config.BrewPackage{Name: "gh", Type: config.BrewCask}) {
+		t.Error("Expected gh not to be installed as a cask")
+	}
+}
+
+func TestFormulae(t *testing.T) {
+	var commands []string
+	formulae, err := newTestManager(&commands).Formulae()
+	if err != nil {
+		t.Fatalf("Formulae failed: %v", err)
+	}
+	if strings.Join(formulae, ",") != "gh,neovim,sketchybar" {
+		t.Errorf("Unexpected formulae %v", formulae)
+	}
+	if len(commands) != 1 || commands[0] != "list --formula -1" {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	var commands []string
+	manager := newTestManager(&commands)
+
+	if err := manager.Install(config.BrewPackage{Name: "visual-studio-code", Type: config.BrewCask}); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if err := manager.Install(config.BrewPackage{Name: "neovim", Type: config.BrewFormula}); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if err := manager.Install(config.BrewPackage{Name: "broken", Type: config.BrewFormula}); err == nil {
+		t.Error("Expected failed install to return an error")
+	}
+
+	expected := []string{"install --cask visual-studio-code", "install neovim", "install broken"}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands %v, got %v", expected, commands)
+	}
+}
+
+func TestWriteBrewfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), BrewfileName)
+	packages := []config.BrewPackage{
+		{App: "sketchybar", Name: "felixkratz/formulae/sketchybar", Type: config.BrewFormula},
+		{App: "vscode", Name: "visual-studio-code", Type: config.BrewCask},
+	}
+
+	if err := WriteBrewfile(path, packages); err != nil {
+		t.Fatalf("WriteBrewfile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read Brewfile: %v", err)
+	}
+	for _, line := range []string{
+		`tap "felixkratz/formulae"`,
+		`brew "felixkratz/formulae/sketchybar" # sketchybar`,
+		`cask "visual-studio-code" # vscode`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expected Brewfile to contain %q, got:\n%s", line, data)
+		}
+	}
+}
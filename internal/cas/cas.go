@@ -0,0 +1,459 @@
+// Package cas implements the content-addressed store mode. Every regular file in an
+// application's store tree is a hard link to a blob named by the SHA-256 hash of its content,
+// so identical files across apps take up space once. A manifest per app records the hash of
+// each of its files, from which the tree can be rebuilt with hard links. Backups and snapshots
+// keep their own copies, since a file edited in place through the store changes its blob.
+package cas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+const (
+	blobsDir     = "sha256"
+	manifestsDir = "manifests"
+	linkSuffix   = ".configsync-link" // Temporary name of a link being swapped in for a file
+)
+
+// Manifest records the files of an application's store tree by their path relative to the store
+type Manifest struct {
+	UpdatedAt time.Time         `json:"updated_at" yaml:"updated_at"`
+	App       string            `json:"app" yaml:"app"`
+	Files     map[string]Entry  `json:"files" yaml:"files"`
+	Symlinks  map[string]string `json:"symlinks,omitempty" yaml:"symlinks,omitempty"` // Targets of symlinks inside the tree
+}
+
+// Entry describes one file of a manifest
+type Entry struct {
+	Hash string      `json:"hash" yaml:"hash"`
+	Size int64       `json:"size" yaml:"size"`
+	Mode os.FileMode `json:"mode" yaml:"mode"`
+}
+
+// Result summarizes the files added to the store
+type Result struct {
+	Files    int      `json:"files" yaml:"files"`                         // Regular files examined
+	Blobs    int      `json:"blobs" yaml:"blobs"`                         // New blobs added
+	Linked   int      `json:"linked" yaml:"linked"`                       // Copies replaced with a link to an identical blob
+	Skipped  int      `json:"skipped" yaml:"skipped"`                     // Files left as they are, being on another volume or differing in mode
+	Saved    int64    `json:"saved" yaml:"saved"`                         // Bytes freed by linking copies
+	Unshared int      `json:"unshared" yaml:"unshared"`                   // Files outside the store given back their own copy of a blob
+	Rekeyed  []string `json:"rekeyed,omitempty" yaml:"rekeyed,omitempty"` // Files edited in place, whose blob changed with them
+}
+
+// PruneResult summarizes the blobs removed by Prune
+type PruneResult struct {
+	Blobs int   `json:"blobs" yaml:"blobs"`
+	Size  int64 `json:"size" yaml:"size"`
+}
+
+// Store keeps the blobs and manifests of a configuration store
+type Store struct {
+	ctx      context.Context
+	fs       fsys.FS
+	storeDir string
+	dir      string
+}
+
+// New returns the content-addressed store kept inside a configuration store, which puts the
+// blobs on the same volume as the files linked to them
+func New(storeDir string) *Store {
+	return &Store{
+		ctx:      context.Background(),
+		fs:       fsys.OS,
+		storeDir: storeDir,
+		dir:      filepath.Join(storeDir, manifest.ObjectsDirName),
+	}
+}
+
+// SetContext makes adding, checking out, and pruning stop with the context's error once ctx is
+// canceled
+func (s *Store) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// SetFS makes the store read and link files through files instead of the operating system's
+// file system
+func (s *Store) SetFS(files fsys.FS) {
+	s.fs = files
+}
+
+// Dir returns the directory holding the blobs and manifests
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// AddApp links every file of an application's store tree to the blob of its content, adding
+// blobs for new content, and records the tree in the application's manifest. A file whose blob
+// changed because it was edited in place is filed under its new hash.
+func (s *Store) AddApp(appConfig *config.AppConfig, result *Result) error {
+	previous, err := s.LoadManifest(appConfig.Name)
+	if err != nil {
+		return err
+	}
+
+	current := &Manifest{
+		UpdatedAt: time.Now(),
+		App:       appConfig.Name,
+		Files:     make(map[string]Entry),
+		Symlinks:  make(map[string]string),
+	}
+	for _, path := range appConfig.Paths {
+		root := filepath.Join(s.storeDir, path.Destination)
+		if _, err := s.fs.Lstat(root); os.IsNotExist(err) {
+			continue
+		}
+		if err := s.addTree(root, previous, current, result); err != nil {
+			return fmt.Errorf("failed to add %s: %w", path.Destination, err)
+		}
+	}
+
+	return s.saveManifest(current)
+}
+
+// Unshare gives every file below a directory outside the store, such as a backup or snapshot,
+// its own copy where it is hard-linked to a blob, as earlier versions left them, so a file
+// edited in place through the store no longer changes it. Run it after AddApp, which files
+// blobs edited in place under their new hash.
+func (s *Store) Unshare(root string, result *Result) error {
+	if _, err := s.fs.Lstat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return fsys.Walk(s.fs, root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if links, ok := fsops.LinkCount(info); ok && links < 2 {
+			return nil
+		}
+
+		hash, err := s.hashFile(path)
+		if err != nil {
+			return err
+		}
+		blob := s.blobPath(hash)
+		blobInfo, err := s.fs.Stat(blob)
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		case !os.SameFile(blobInfo, info):
+			return nil
+		}
+		if err := fsops.CopyFile(s.ctx, s.fs, blob, path); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", path, err)
+		}
+		result.Unshared++
+		return nil
+	})
+}
+
+// addTree adds the files below root, recording them in current when it is not nil
+func (s *Store) addTree(root string, previous, current *Manifest, result *Result) error {
+	return fsys.Walk(s.fs, root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == manifest.ObjectsDirName {
+			return filepath.SkipDir
+		}
+
+		key := s.key(path)
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			if current != nil {
+				target, err := s.fs.Readlink(path)
+				if err != nil {
+					return err
+				}
+				current.Symlinks[key] = target
+			}
+		case info.Mode().IsRegular() && !strings.HasSuffix(path, linkSuffix):
+			var prev *Entry
+			if entry, ok := previous.entry(key); ok {
+				prev = &entry
+			}
+			entry, err := s.addFile(path, key, info, prev, result)
+			if err != nil {
+				return err
+			}
+			if current != nil {
+				current.Files[key] = entry
+			}
+		}
+		return nil
+	})
+}
+
+// addFile links one file to the blob of its content. prev is its entry in the previous manifest.
+func (s *Store) addFile(path, key string, info fs.FileInfo, prev *Entry, result *Result) (Entry, error) {
+	hash, err := s.hashFile(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Hash: hash, Size: info.Size(), Mode: info.Mode().Perm()}
+	result.Files++
+
+	// A file edited in place through its symlink changed the blob it is linked to, whose name
+	// no longer matches its content
+	if prev != nil && prev.Hash != hash {
+		if oldInfo, err := s.fs.Stat(s.blobPath(prev.Hash)); err == nil && os.SameFile(oldInfo, info) {
+			if err := s.fs.Remove(s.blobPath(prev.Hash)); err != nil {
+				return Entry{}, err
+			}
+			result.Rekeyed = append(result.Rekeyed, key)
+		}
+	}
+
+	blob := s.blobPath(hash)
+	blobInfo, err := s.fs.Stat(blob)
+	switch {
+	case os.IsNotExist(err):
+		if err := s.fs.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return Entry{}, err
+		}
+		if err := s.fs.Link(path, blob); err != nil {
+			if errors.Is(err, syscall.EXDEV) {
+				result.Skipped++
+				return entry, nil
+			}
+			return Entry{}, err
+		}
+		result.Blobs++
+	case err != nil:
+		return Entry{}, err
+	case os.SameFile(blobInfo, info):
+		// Already linked
+	case blobInfo.Mode().Perm() != info.Mode().Perm():
+		// Linked files share their permissions, so the copy keeps its own
+		result.Skipped++
+	default:
+		linked, err := s.replaceWithLink(blob, path)
+		if err != nil {
+			return Entry{}, err
+		}
+		if !linked {
+			result.Skipped++
+			return entry, nil
+		}
+		result.Linked++
+		result.Saved += info.Size()
+	}
+	return entry, nil
+}
+
+// replaceWithLink atomically replaces the file at path with a hard link to blob. It returns
+// false when they are on different volumes.
+func (s *Store) replaceWithLink(blob, path string) (bool, error) {
+	temp := path + linkSuffix
+	_ = s.fs.Remove(temp)
+	if err := s.fs.Link(blob, temp); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := s.fs.Rename(temp, path); err != nil {
+		_ = s.fs.Remove(temp)
+		return false, err
+	}
+	return true, nil
+}
+
+// Checkout rebuilds the missing files and symlinks of an application's store tree from its
+// manifest, linking each file to its blob. Files that exist are left alone, even when they
+// differ from the manifest. It returns the number of entries rebuilt.
+func (s *Store) Checkout(appName string) (int, error) {
+	m, err := s.LoadManifest(appName)
+	if err != nil {
+		return 0, err
+	}
+	if m == nil {
+		return 0, fmt.Errorf("no manifest recorded for %s; run 'configsync store dedupe' first", appName)
+	}
+
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rebuilt := 0
+	var missing []string
+	for _, key := range keys {
+		if err := s.ctx.Err(); err != nil {
+			return rebuilt, err
+		}
+		path := filepath.Join(s.storeDir, filepath.FromSlash(key))
+		if _, err := s.fs.Lstat(path); err == nil {
+			continue
+		}
+
+		blob := s.blobPath(m.Files[key].Hash)
+		if !fsys.Exists(s.fs, blob) {
+			missing = append(missing, key)
+			continue
+		}
+		if err := s.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return rebuilt, err
+		}
+		if err := s.fs.Link(blob, path); err != nil {
+			return rebuilt, err
+		}
+		rebuilt++
+	}
+
+	for key, target := range m.Symlinks {
+		path := filepath.Join(s.storeDir, filepath.FromSlash(key))
+		if _, err := s.fs.Lstat(path); err == nil {
+			continue
+		}
+		if err := s.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return rebuilt, err
+		}
+		if err := s.fs.Symlink(target, path); err != nil {
+			return rebuilt, err
+		}
+		rebuilt++
+	}
+
+	if len(missing) > 0 {
+		return rebuilt, fmt.Errorf("blobs missing for %d file(s) of %s: %s", len(missing), appName, strings.Join(missing, ", "))
+	}
+	return rebuilt, nil
+}
+
+// Prune removes the blobs no file links to anymore. Link counts are only known on macOS and
+// Linux, so elsewhere nothing is removed.
+func (s *Store) Prune() (*PruneResult, error) {
+	result := &PruneResult{}
+	root := filepath.Join(s.dir, blobsDir)
+	if _, err := s.fs.Stat(root); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	err := fsys.Walk(s.fs, root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if links, ok := fsops.LinkCount(info); !ok || links > 1 {
+			return nil
+		}
+		if err := s.fs.Remove(path); err != nil {
+			return err
+		}
+		result.Blobs++
+		result.Size += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// LoadManifest reads an application's manifest, returning nil when none has been recorded
+func (s *Store) LoadManifest(appName string) (*Manifest, error) {
+	data, err := s.fs.ReadFile(s.manifestPath(appName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of %s: %w", appName, err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s: %w", appName, err)
+	}
+	return &m, nil
+}
+
+// RemoveManifest deletes an application's manifest, leaving its blobs for Prune
+func (s *Store) RemoveManifest(appName string) error {
+	err := s.fs.Remove(s.manifestPath(appName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) saveManifest(m *Manifest) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest of %s: %w", m.App, err)
+	}
+	if err := s.fs.MkdirAll(filepath.Join(s.dir, manifestsDir), 0755); err != nil {
+		return err
+	}
+	return s.fs.WriteFile(s.manifestPath(m.App), data, 0644)
+}
+
+func (s *Store) manifestPath(appName string) string {
+	return filepath.Join(s.dir, manifestsDir, appName+".yaml")
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.dir, blobsDir, hash[:2], hash[2:])
+}
+
+// key returns the manifest key of a path: slash-separated and relative to the store
+func (s *Store) key(path string) string {
+	rel, err := filepath.Rel(s.storeDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (s *Store) hashFile(path string) (string, error) {
+	file, err := s.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := fsutil.CopyContext(s.ctx, hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// entry returns the entry of a file in a manifest that may be nil
+func (m *Manifest) entry(key string) (Entry, bool) {
+	if m == nil {
+		return Entry{}, false
+	}
+	entry, ok := m.Files[key]
+	return entry, ok
+}
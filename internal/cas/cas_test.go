@@ -0,0 +1,218 @@
+package cas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+)
+
+// setupStore creates a store with two apps that share an identical file
+func setupStore(t *testing.T) (string, *config.AppConfig, *config.AppConfig) {
+	t.Helper()
+	storeDir := filepath.Join(t.TempDir(), "store")
+	writeFile(t, filepath.Join(storeDir, "one", "theme.json"), "shared theme")
+	writeFile(t, filepath.Join(storeDir, "one", "settings.json"), "one")
+	writeFile(t, filepath.Join(storeDir, "two", "theme.json"), "shared theme")
+	if err := os.Symlink("theme.json", filepath.Join(storeDir, "two", "current.json")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	one := config.NewAppConfig("one", "One")
+	one.AddPath("~/.one", "one", config.PathTypeDirectory, false)
+	two := config.NewAppConfig("two", "Two")
+	two.AddPath("~/.two", "two", config.PathTypeDirectory, false)
+	return storeDir, one, two
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	infoA, err := os.Stat(a)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+func TestAddAppDeduplicatesAcrossApps(t *testing.T) {
+	storeDir, one, two := setupStore(t)
+	objects := New(storeDir)
+
+	result := &Result{}
+	for _, app := range []*config.AppConfig{one, two} {
+		if err := objects.AddApp(app, result); err != nil {
+			t.Fatalf("AddApp failed: %v", err)
+		}
+	}
+
+	if result.Files != 3 || result.Blobs != 2 || result.Linked != 1 || result.Saved != int64(len("shared theme")) {
+		t.Errorf("Expected 3 files in 2 blobs with 1 copy linked, got %+v", result)
+	}
+	if !sameFile(t, filepath.Join(storeDir, "one", "theme.json"), filepath.Join(storeDir, "two", "theme.json")) {
+		t.Error("Expected the identical files to be linked")
+	}
+
+	manifest, err := objects.LoadManifest("two")
+	if err != nil || manifest == nil {
+		t.Fatalf("Expected a manifest, got %v, %v", manifest, err)
+	}
+	if entry := manifest.Files["two/theme.json"]; entry.Size != int64(len("shared theme")) || entry.Hash == "" {
+		t.Errorf("Expected the file to be recorded, got %+v", entry)
+	}
+	if target := manifest.Symlinks["two/current.json"]; target != "theme.json" {
+		t.Errorf("Expected the symlink to be recorded, got %q", target)
+	}
+
+	// Adding again changes nothing
+	again := &Result{}
+	if err := objects.AddApp(one, again); err != nil {
+		t.Fatalf("AddApp failed: %v", err)
+	}
+	if again.Blobs != 0 || again.Linked != 0 {
+		t.Errorf("Expected nothing new, got %+v", again)
+	}
+}
+
+func TestAddAppKeepsFilesWithOtherPermissions(t *testing.T) {
+	storeDir, one, two := setupStore(t)
+	if err := os.Chmod(filepath.Join(storeDir, "two", "theme.json"), 0600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	objects := New(storeDir)
+
+	result := &Result{}
+	for _, app := range []*config.AppConfig{one, two} {
+		if err := objects.AddApp(app, result); err != nil {
+			t.Fatalf("AddApp failed: %v", err)
+		}
+	}
+	if result.Linked != 0 || result.Skipped != 1 {
+		t.Errorf("Expected the file with other permissions to be skipped, got %+v", result)
+	}
+}
+
+func TestAddAppRekeysFilesEditedInPlace(t *testing.T) {
+	storeDir, one, _ := setupStore(t)
+	objects := New(storeDir)
+	if err := objects.AddApp(one, &Result{}); err != nil {
+		t.Fatalf("AddApp failed: %v", err)
+	}
+
+	path := filepath.Join(storeDir, "one", "settings.json")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	_, _ = file.WriteString("edited")
+	_ = file.Close()
+
+	result := &Result{}
+	if err := objects.AddApp(one, result); err != nil {
+		t.Fatalf("AddApp failed: %v", err)
+	}
+	if len(result.Rekeyed) != 1 || result.Rekeyed[0] != "one/settings.json" {
+		t.Errorf("Expected the edited file to be reported, got %v", result.Rekeyed)
+	}
+
+	manifest, _ := objects.LoadManifest("one")
+	blob := objects.blobPath(manifest.Files["one/settings.json"].Hash)
+	if data, err := os.ReadFile(blob); err != nil || string(data) != "edited" {
+		t.Errorf("Expected the blob to be filed under its new hash, got %q, %v", data, err)
+	}
+}
+
+func TestCheckoutRebuildsMissingFiles(t *testing.T) {
+	storeDir, _, two := setupStore(t)
+	objects := New(storeDir)
+	if err := objects.AddApp(two, &Result{}); err != nil {
+		t.Fatalf("AddApp failed: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(storeDir, "two")); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+
+	rebuilt, err := objects.Checkout("two")
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if rebuilt != 2 {
+		t.Errorf("Expected the file and symlink to be rebuilt, got %d", rebuilt)
+	}
+	if data, err := os.ReadFile(filepath.Join(storeDir, "two", "current.json")); err != nil || string(data) != "shared theme" {
+		t.Errorf("Expected the tree to be rebuilt, got %q, %v", data, err)
+	}
+
+	if _, err := objects.Checkout("unknown"); err == nil {
+		t.Error("Expected an error for an app without a manifest")
+	}
+}
+
+func TestUnshareAndPrune(t *testing.T) {
+	storeDir, one, _ := setupStore(t)
+	objects := New(storeDir)
+	if err := objects.AddApp(one, &Result{}); err != nil {
+		t.Fatalf("AddApp failed: %v", err)
+	}
+
+	// Earlier versions linked backups to the blobs of the store files
+	backupDir := filepath.Join(filepath.Dir(storeDir), "backups")
+	backupFile := filepath.Join(backupDir, "one", "settings.json")
+	storeFile := filepath.Join(storeDir, "one", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(backupFile), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.Link(storeFile, backupFile); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	writeFile(t, filepath.Join(backupDir, "one", "other.json"), "other")
+
+	result := &Result{}
+	if err := objects.Unshare(backupDir, result); err != nil {
+		t.Fatalf("Unshare failed: %v", err)
+	}
+	if result.Unshared != 1 || sameFile(t, backupFile, storeFile) {
+		t.Errorf("Expected the backup to get its own copy, got %+v", result)
+	}
+
+	// Editing the store file in place leaves the backup alone
+	if err := os.WriteFile(storeFile, []byte("edited"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, err := os.ReadFile(backupFile); err != nil || string(data) != "one" {
+		t.Errorf("Expected the backup to keep its content, got %q, %v", data, err)
+	}
+
+	info, _ := os.Stat(filepath.Join(storeDir, "one", "theme.json"))
+	if _, ok := fsops.LinkCount(info); !ok {
+		t.Skip("Link counts are not available on this platform")
+	}
+	if err := os.RemoveAll(filepath.Join(storeDir, "one")); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	pruned, err := objects.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if pruned.Blobs != 2 || pruned.Size != int64(len("shared theme")+len("edited")) {
+		t.Errorf("Expected the blobs only the removed store files linked to be pruned, got %+v", pruned)
+	}
+	if _, err := os.Stat(backupFile); err != nil {
+		t.Errorf("Expected the backup to survive pruning: %v", err)
+	}
+}
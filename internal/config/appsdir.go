@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// appFileExt is the extension of the per-application files in the apps directory
+const appFileExt = ".yaml"
+
+// AppsDir returns the directory of per-application configuration files, next to the
+// configuration file
+func (m *Manager) AppsDir() string {
+	return filepath.Join(filepath.Dir(m.configPath), DefaultAppsDir)
+}
+
+// SplitLayout reports whether applications are kept in one file each in the apps directory
+// rather than under apps in the configuration file
+func (m *Manager) SplitLayout() bool {
+	info, err := m.fs.Stat(m.AppsDir())
+	return err == nil && info.IsDir()
+}
+
+// SplitApps moves the applications out of the configuration file into one file each in the
+// apps directory. The application files are written before they are removed from the
+// configuration file.
+func (m *Manager) SplitApps() error {
+	if m.SplitLayout() {
+		return fmt.Errorf("applications are already kept in %s", m.AppsDir())
+	}
+	config, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := m.fs.MkdirAll(m.AppsDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", m.AppsDir(), err)
+	}
+	return m.saveConfig(config)
+}
+
+// JoinApps moves the applications in the apps directory back under apps in the configuration
+// file and removes the apps directory
+func (m *Manager) JoinApps() error {
+	if !m.SplitLayout() {
+		return fmt.Errorf("applications are already kept in %s", m.configPath)
+	}
+	config, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := m.writeConfigFile(config, config.Apps); err != nil {
+		return err
+	}
+	if err := m.fs.RemoveAll(m.AppsDir()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", m.AppsDir(), err)
+	}
+	m.config = config
+	return nil
+}
+
+// ConfigData returns the configuration as a single configuration file, with the applications
+// of the apps directory merged in, e.g. to save a copy of it
+func (m *Manager) ConfigData() ([]byte, error) {
+	data, err := m.fs.ReadFile(m.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if !m.SplitLayout() {
+		return data, nil
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
+	}
+	if err := m.loadAppsDir(config); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(config)
+}
+
+// loadAppsDir adds the applications in the apps directory to a configuration. Each file is
+// named after its application; an application may not also be defined in the configuration file.
+func (m *Manager) loadAppsDir(config *Config) error {
+	dir := m.AppsDir()
+	entries, err := m.fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	if config.Apps == nil {
+		config.Apps = make(map[string]*AppConfig)
+	}
+
+	for _, entry := range entries {
+		appName, ok := appFileName(entry)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if _, exists := config.Apps[appName]; exists {
+			return fmt.Errorf("application %s is defined both in %s and in %s", appName, m.configPath, path)
+		}
+
+		data, err := m.fs.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		app, err := parseAppConfig(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if app.Name == "" {
+			app.Name = appName
+		}
+		config.Apps[appName] = app
+	}
+
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid application in %s: %w", dir, err)
+	}
+	return nil
+}
+
+// saveAppsDir writes each application to its file in the apps directory, skipping files whose
+// content is unchanged
+func (m *Manager) saveAppsDir(apps map[string]*AppConfig) error {
+	dir := m.AppsDir()
+	for appName, app := range apps {
+		data, err := yaml.Marshal(app)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", appName, err)
+		}
+		path := filepath.Join(dir, appName+appFileExt)
+		if current, err := m.fs.ReadFile(path); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+		if err := m.fs.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// removeStaleAppFiles removes the files in the apps directory of applications no longer configured
+func (m *Manager) removeStaleAppFiles(apps map[string]*AppConfig) error {
+	dir := m.AppsDir()
+	entries, err := m.fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		appName, ok := appFileName(entry)
+		if !ok {
+			continue
+		}
+		if _, configured := apps[appName]; configured {
+			continue
+		}
+		if err := m.fs.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// appFileName returns the application an entry of the apps directory configures. Directories,
+// hidden files such as editor backups, and files of other types are not application files.
+func appFileName(entry os.DirEntry) (string, bool) {
+	name := entry.Name()
+	if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, appFileExt) {
+		return "", false
+	}
+	appName := strings.TrimSuffix(name, appFileExt)
+	return appName, appName != ""
+}
+
+// parseAppConfig decodes the configuration of one application, rejecting unknown fields
+func parseAppConfig(data []byte) (*AppConfig, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var app AppConfig
+	if err := decoder.Decode(&app); err != nil && !errors.Is(err, io.EOF) {
+		return nil, describeDecodeError(err)
+	}
+	return &app, nil
+}
+
+// CheckAppsDir reports every schema problem in the application files of an apps directory, each
+// prefixed with the file it was found in, and returns the applications decoded as far as possible.
+// Collisions between applications are not checked. The error is only set when the directory
+// cannot be read.
+func CheckAppsDir(dir string) (map[string]*AppConfig, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	apps := make(map[string]*AppConfig)
+	var problems []string
+	for _, entry := range entries {
+		appName, ok := appFileName(entry)
+		if !ok {
+			continue
+		}
+		label := filepath.Join(DefaultAppsDir, entry.Name())
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+			continue
+		}
+		app, appProblems, err := checkApp(appName, data)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: not valid YAML: %v", label, err))
+			continue
+		}
+		for _, problem := range appProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", label, problem))
+		}
+		if app.Name == "" {
+			app.Name = appName
+		}
+		apps[appName] = app
+	}
+	return apps, problems, nil
+}
@@ -2,6 +2,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -119,6 +121,32 @@ func TestAppConfigAddPath(t *testing.T) {
 	}
 }
 
+func TestAppConfigRenameDestination(t *testing.T) {
+	app := NewAppConfig("cursor", "Cursor")
+	app.AddPath("~/Library/Application Support/Cursor/User", "Library/Application Support/Code/User", PathTypeDirectory, false)
+	app.AddPath("~/Library/Application Support/Cursor/keybindings.json", "Library/Application Support/Code/keybindings.json", PathTypeFile, false)
+	app.AddPath("~/.cursorrc", "Library/Application Support/CodeX", PathTypeFile, false)
+
+	if renamed := app.RenameDestination("Library/Application Support/Code/", "Library/Application Support/Cursor"); renamed != 2 {
+		t.Errorf("RenameDestination() renamed %d paths, want 2", renamed)
+	}
+
+	want := []string{
+		"Library/Application Support/Cursor/User",
+		"Library/Application Support/Cursor/keybindings.json",
+		"Library/Application Support/CodeX", // Shares a prefix but is not inside the renamed directory
+	}
+	for i, path := range app.Paths {
+		if path.Destination != want[i] {
+			t.Errorf("Paths[%d].Destination = %q, want %q", i, path.Destination, want[i])
+		}
+	}
+
+	if renamed := app.RenameDestination("Missing", "Other"); renamed != 0 {
+		t.Errorf("RenameDestination() of an unknown destination renamed %d paths", renamed)
+	}
+}
+
 func TestConfigPathMarkSynced(t *testing.T) {
 	path := &Path{
 		Source:      "/test/source",
@@ -171,3 +199,129 @@ func TestAppConfigIsEnabled(t *testing.T) {
 		t.Error("Expected disabled app to return false")
 	}
 }
+
+func TestPreferencesDomain(t *testing.T) {
+	app := NewAppConfig(constants.TestAppName, "Test App")
+	app.BundleID = constants.TestBundleID
+
+	if domain := app.PreferencesDomain(); domain != constants.TestBundleID {
+		t.Errorf("Expected domain to fall back to bundle ID, got %s", domain)
+	}
+
+	app.DefaultsDomain = "com.test.custom"
+	if domain := app.PreferencesDomain(); domain != "com.test.custom" {
+		t.Errorf("Expected configured domain, got %s", domain)
+	}
+
+	app.AddDefaultsPath()
+	if len(app.Paths) != 1 {
+		t.Fatalf("Expected 1 path, got %d", len(app.Paths))
+	}
+
+	path := app.Paths[0]
+	if path.Type != PathTypeDefaults {
+		t.Errorf("Expected defaults path type, got %s", path.Type)
+	}
+	if path.Destination != "Defaults/com.test.custom.plist" {
+		t.Errorf("Expected store destination Defaults/com.test.custom.plist, got %s", path.Destination)
+	}
+}
+
+func TestDirectorySizeLimit(t *testing.T) {
+	var nilSettings *Settings
+	if limit := nilSettings.DirectorySizeLimit(); limit != DefaultMaxDirectorySize {
+		t.Errorf("Expected default limit for nil settings, got %d", limit)
+	}
+
+	settings := &Settings{}
+	if limit := settings.DirectorySizeLimit(); limit != DefaultMaxDirectorySize {
+		t.Errorf("Expected default limit for unset value, got %d", limit)
+	}
+
+	settings.MaxDirectorySize = 42
+	if limit := settings.DirectorySizeLimit(); limit != 42 {
+		t.Errorf("Expected configured limit 42, got %d", limit)
+	}
+}
+
+func TestSizeWarningThreshold(t *testing.T) {
+	var nilSettings *Settings
+	if threshold := nilSettings.SizeWarningThreshold(); threshold != DefaultSizeWarning {
+		t.Errorf("Expected default threshold for nil settings, got %d", threshold)
+	}
+
+	settings := &Settings{SizeWarning: 42}
+	if threshold := settings.SizeWarningThreshold(); threshold != 42 {
+		t.Errorf("Expected configured threshold 42, got %d", threshold)
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".configsyncignore"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+	app := &AppConfig{Name: "chrome", Ignore: []string{"Sessions/", "!logs/"}}
+
+	ignored, err := app.IgnoreMatcher(root, false)
+	if err != nil {
+		t.Fatalf("IgnoreMatcher failed: %v", err)
+	}
+	for _, tt := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"Default/Cache", true, true},    // Common cache directory
+		{"Default/Sessions", true, true}, // App pattern
+		{"state.tmp", false, true},       // .configsyncignore pattern
+		{"logs", true, false},            // Re-included by the app
+		{"Default/Preferences", false, false},
+	} {
+		if got := ignored.Match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q) = %v, expected %v", tt.path, got, tt.ignored)
+		}
+	}
+
+	withCaches, err := app.IgnoreMatcher(root, true)
+	if err != nil {
+		t.Fatalf("IgnoreMatcher failed: %v", err)
+	}
+	if withCaches.Match("Default/Cache", true) {
+		t.Error("Expected caches to be kept when included")
+	}
+	if !withCaches.Match("Default/Sessions", true) {
+		t.Error("Expected the app's patterns to apply when caches are included")
+	}
+}
+
+func TestPathIsExcluded(t *testing.T) {
+	path := Path{Exclude: []string{"cache", "*.log", "plugin/compiled.lua"}}
+
+	tests := []struct {
+		relPath  string
+		expected bool
+	}{
+		{"cache", true},
+		{"cache/entry", true},
+		{"nested/cache/entry", true},
+		{"debug.log", true},
+		{"logs/debug.log", true},
+		{"plugin/compiled.lua", true},
+		{"plugin/other.lua", false},
+		{"settings.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.relPath, func(t *testing.T) {
+			if got := path.IsExcluded(tt.relPath); got != tt.expected {
+				t.Errorf("IsExcluded(%s) = %t, expected %t", tt.relPath, got, tt.expected)
+			}
+		})
+	}
+
+	empty := Path{}
+	if empty.IsExcluded("anything") {
+		t.Error("Expected no exclusions for path without patterns")
+	}
+}
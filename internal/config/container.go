@@ -0,0 +1,88 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Modes a path can be synced with
+const (
+	// PathModeSymlink replaces the path with a symlink into the store. It is the default.
+	PathModeSymlink = "symlink"
+	// PathModeCopy keeps the path a regular file and copies changes between it and the store,
+	// for sandboxed apps that cannot follow a symlink out of their container
+	PathModeCopy = "copy"
+)
+
+// IsCopyMode reports whether a path is synced by copying rather than symlinking
+func (p *Path) IsCopyMode() bool {
+	return p.Mode == PathModeCopy
+}
+
+// IsSecret reports whether a path's content is kept in a secret backend instead of the store
+func (p *Path) IsSecret() bool {
+	return p.Secret != ""
+}
+
+// IsExported reports whether a path's store copy is exported from its source and imported back by
+// a tool, as defaults domains and plugin paths are, rather than linked to it
+func (p *Path) IsExported() bool {
+	return p.Type == PathTypeDefaults || p.Plugin != ""
+}
+
+// ContainerPath returns where a sandboxed app keeps a path from ~/Library, e.g.
+// ~/Library/Preferences/com.app.plist becomes
+// ~/Library/Containers/com.app/Data/Library/Preferences/com.app.plist. Paths may start with ~/ or
+// the home directory and keep their form. It returns "" for paths outside ~/Library or in a container.
+func ContainerPath(homeDir, bundleID, path string) string {
+	prefix, rel := splitHome(homeDir, path)
+	if bundleID == "" || prefix == "" || !strings.HasPrefix(rel, "Library/") || IsContainerPath(homeDir, path) {
+		return ""
+	}
+	return joinHome(prefix, "Library/Containers/"+bundleID+"/Data/"+rel)
+}
+
+// UncontainedPath returns the ~/Library location of a path inside an app's sandbox container,
+// along with the app's bundle ID. It returns empty strings for paths that are not in a container.
+func UncontainedPath(homeDir, path string) (string, string) {
+	prefix, rel := splitHome(homeDir, path)
+	if prefix == "" || !strings.HasPrefix(rel, "Library/Containers/") {
+		return "", ""
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(rel, "Library/Containers/"), "/", 3)
+	if len(parts) < 3 || parts[1] != "Data" || !strings.HasPrefix(parts[2], "Library/") {
+		return "", ""
+	}
+	return joinHome(prefix, parts[2]), parts[0]
+}
+
+// IsContainerPath reports whether a path lies inside an app's sandbox container or a group
+// container, where the sandbox keeps the app from following symlinks into the store
+func IsContainerPath(homeDir, path string) bool {
+	prefix, rel := splitHome(homeDir, path)
+	return prefix != "" && (strings.HasPrefix(rel, "Library/Containers/") || strings.HasPrefix(rel, "Library/Group Containers/"))
+}
+
+// splitHome splits a path into its home directory prefix (~ or homeDir) and the slash-separated
+// remainder. The prefix is empty for paths outside the home directory.
+func splitHome(homeDir, path string) (string, string) {
+	if strings.HasPrefix(path, "~/") {
+		return "~", filepath.ToSlash(path[2:])
+	}
+	if homeDir == "" {
+		return "", ""
+	}
+	rel, err := filepath.Rel(homeDir, path)
+	if err != nil || !filepath.IsAbs(path) || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", ""
+	}
+	return homeDir, filepath.ToSlash(rel)
+}
+
+func joinHome(prefix, rel string) string {
+	if prefix == "~" {
+		return "~/" + rel
+	}
+	return filepath.Join(prefix, filepath.FromSlash(rel))
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestContainerPath(t *testing.T) {
+	home := filepath.Join("/Users", "test")
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"tilde path", "~/Library/Preferences/com.app.plist", "~/Library/Containers/com.app/Data/Library/Preferences/com.app.plist"},
+		{"absolute path", filepath.Join(home, "Library", "Application Support", "App"), filepath.Join(home, "Library", "Containers", "com.app", "Data", "Library", "Application Support", "App")},
+		{"outside Library", "~/.apprc", ""},
+		{"outside home", "/etc/app.conf", ""},
+		{"already in a container", "~/Library/Containers/com.app/Data/Library/Preferences/com.app.plist", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ContainerPath(home, "com.app", tt.path); got != tt.expected {
+			t.Errorf("%s: ContainerPath(%q) = %q, want %q", tt.name, tt.path, got, tt.expected)
+		}
+	}
+
+	if got := ContainerPath(home, "", "~/Library/Preferences/com.app.plist"); got != "" {
+		t.Errorf("Expected no container path without a bundle ID, got %q", got)
+	}
+}
+
+func TestUncontainedPath(t *testing.T) {
+	home := filepath.Join("/Users", "test")
+
+	path, bundleID := UncontainedPath(home, filepath.Join(home, "Library", "Containers", "com.app", "Data", "Library", "Preferences", "com.app.plist"))
+	if path != filepath.Join(home, "Library", "Preferences", "com.app.plist") || bundleID != "com.app" {
+		t.Errorf("Unexpected uncontained path %q (%q)", path, bundleID)
+	}
+
+	if path, _ := UncontainedPath(home, "~/Library/Containers/com.app/Data/Library/Preferences/com.app.plist"); path != "~/Library/Preferences/com.app.plist" {
+		t.Errorf("Expected tilde paths to keep their form, got %q", path)
+	}
+
+	for _, notContained := range []string{"~/Library/Preferences/com.app.plist", "~/Library/Containers/com.app", "~/Library/Containers/com.app/Data/Documents/file"} {
+		if path, bundleID := UncontainedPath(home, notContained); path != "" || bundleID != "" {
+			t.Errorf("Expected no uncontained path for %q, got %q (%q)", notContained, path, bundleID)
+		}
+	}
+}
+
+func TestIsContainerPath(t *testing.T) {
+	home := filepath.Join("/Users", "test")
+
+	for _, path := range []string{"~/Library/Containers/com.app/Data/Library/Preferences/com.app.plist", filepath.Join(home, "Library", "Group Containers", "group.com.app")} {
+		if !IsContainerPath(home, path) {
+			t.Errorf("Expected %q to be a container path", path)
+		}
+	}
+	for _, path := range []string{"~/Library/Preferences/com.app.plist", "/Library/Containers/com.app"} {
+		if IsContainerPath(home, path) {
+			t.Errorf("Expected %q not to be a container path", path)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// GroupPrefix marks a command argument naming a group of applications instead of an application,
+// as in "configsync sync @editors"
+const GroupPrefix = "@"
+
+// IsGroupName reports whether a command argument names a group of applications
+func IsGroupName(arg string) bool {
+	return strings.HasPrefix(arg, GroupPrefix)
+}
+
+// GroupApps returns the applications in a group, named with or without the @ prefix. It fails when
+// the group is not defined or names an application that is not configured.
+func (c *Config) GroupApps(group string) ([]string, error) {
+	group = strings.TrimPrefix(group, GroupPrefix)
+	members, ok := c.Groups[group]
+	if !ok {
+		return nil, fmt.Errorf("group %s is not defined; see the groups section of the configuration", group)
+	}
+	for _, appName := range members {
+		if _, exists := c.Apps[appName]; !exists {
+			return nil, fmt.Errorf("group %s names application %s, which is not configured", group, appName)
+		}
+	}
+	return members, nil
+}
+
+// ExpandGroups replaces each @group among names with the applications in the group, keeping the
+// first of any application named more than once
+func (c *Config) ExpandGroups(names []string) ([]string, error) {
+	var expanded []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		members := []string{name}
+		if IsGroupName(name) {
+			var err error
+			if members, err = c.GroupApps(name); err != nil {
+				return nil, err
+			}
+		}
+		for _, appName := range members {
+			if !seen[appName] {
+				seen[appName] = true
+				expanded = append(expanded, appName)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// removeFromGroups drops an application that is no longer configured from every group
+func (c *Config) removeFromGroups(appName string) {
+	for group, members := range c.Groups {
+		kept := members[:0]
+		for _, member := range members {
+			if member != appName {
+				kept = append(kept, member)
+			}
+		}
+		c.Groups[group] = kept
+	}
+}
+
+// validateGroups checks that group names and members can be used as command arguments
+func (c *Config) validateGroups(problems *ValidationError) {
+	for _, group := range slices.Sorted(maps.Keys(c.Groups)) {
+		field := "groups." + group
+		if group == "" || IsGroupName(group) || strings.ContainsAny(group, ", ") {
+			problems.add(field, "%q is not a valid group name; use letters, digits, - and _", group)
+		}
+		for i, appName := range c.Groups[group] {
+			if appName == "" || IsGroupName(appName) {
+				problems.add(fmt.Sprintf("%s[%d]", field, i), "%q must name an application; groups cannot contain groups", appName)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExpandGroups(t *testing.T) {
+	cfg := &Config{
+		Apps: map[string]*AppConfig{
+			"vscode": NewAppConfig("vscode", "VS Code"),
+			"nvim":   NewAppConfig("nvim", "Neovim"),
+			"git":    NewAppConfig("git", "Git"),
+		},
+		Groups: map[string][]string{
+			"editors": {"vscode", "nvim"},
+			"stale":   {"sublimetext"},
+		},
+	}
+
+	expanded, err := cfg.ExpandGroups([]string{"nvim", "@editors", "git"})
+	if err != nil {
+		t.Fatalf("ExpandGroups failed: %v", err)
+	}
+	if expected := []string{"nvim", "vscode", "git"}; !slices.Equal(expanded, expected) {
+		t.Errorf("ExpandGroups() = %v, expected %v", expanded, expected)
+	}
+
+	if _, err := cfg.ExpandGroups([]string{"@missing"}); err == nil {
+		t.Error("Expected an error for an undefined group")
+	}
+	if _, err := cfg.ExpandGroups([]string{"@stale"}); err == nil {
+		t.Error("Expected an error for a group naming an application that is not configured")
+	}
+
+	cfg.removeFromGroups("nvim")
+	if members, _ := cfg.GroupApps("editors"); !slices.Equal(members, []string{"vscode"}) {
+		t.Errorf("Expected nvim to be dropped from the group, got %v", members)
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	cfg := &Config{Groups: map[string][]string{
+		"editors": {"vscode"},
+		"@tools":  {"git"},
+		"nested":  {"@editors"},
+	}}
+
+	var invalid *ValidationError
+	if !errors.As(cfg.Validate(), &invalid) || len(invalid.Problems) != 2 {
+		t.Fatalf("Expected the group name and the nested group to be reported, got %v", cfg.Validate())
+	}
+}
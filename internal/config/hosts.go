@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+)
+
+// HostEnvVar names the environment variable that overrides the hostname hosts are matched by
+const HostEnvVar = "CONFIGSYNC_HOST"
+
+// CurrentHost is the name of the host configsync is running on, matched against the hosts section.
+// It is a variable so tests can simulate other hosts.
+var CurrentHost = currentHost()
+
+// HostOverride adjusts a configuration shared by several machines for one of them
+type HostOverride struct {
+	Include []string           `yaml:"include,omitempty"` // Only these applications are used on the host
+	Exclude []string           `yaml:"exclude,omitempty"` // Applications not used on the host
+	Paths   []PathSubstitution `yaml:"paths,omitempty"`   // Source locations that differ on the host
+}
+
+// PathSubstitution rewrites the start of source paths on one host, e.g. ~/Work/ to ~/Projects/
+type PathSubstitution struct {
+	From string `yaml:"from"` // Path prefix in the shared configuration; ~/ stands for the home directory
+	To   string `yaml:"to"`   // Replacement prefix on the host
+}
+
+// Host returns the overrides for a host, matching the hosts section by full or short hostname
+// regardless of case, or nil when the host has none
+func (c *Config) Host(host string) *HostOverride {
+	return FindHost(c.Hosts, host)
+}
+
+// FindHost returns the overrides for a host from a hosts section, or nil when the host has none
+func FindHost(hosts map[string]*HostOverride, host string) *HostOverride {
+	if host == "" {
+		return nil
+	}
+	short, _, _ := strings.Cut(host, ".")
+	for name, override := range hosts {
+		if strings.EqualFold(name, host) || strings.EqualFold(name, short) {
+			return override
+		}
+	}
+	return nil
+}
+
+// HostApps returns the configured applications used on a host
+func (c *Config) HostApps(host string) map[string]*AppConfig {
+	override := c.Host(host)
+	apps := make(map[string]*AppConfig, len(c.Apps))
+	for appName, appConfig := range c.Apps {
+		if override.UsesApp(appName) {
+			apps[appName] = appConfig
+		}
+	}
+	return apps
+}
+
+// UsesApp reports whether an application is used on the host. Without overrides every
+// application is.
+func (h *HostOverride) UsesApp(appName string) bool {
+	if h == nil {
+		return true
+	}
+	if len(h.Include) > 0 && !slices.Contains(h.Include, appName) {
+		return false
+	}
+	return !slices.Contains(h.Exclude, appName)
+}
+
+// SubstitutePath expands a source path and applies the first substitution whose prefix it starts with
+func (h *HostOverride) SubstitutePath(path, homeDir string) string {
+	path = expandHome(path, homeDir)
+	if h == nil {
+		return path
+	}
+	for _, substitution := range h.Paths {
+		from := strings.TrimSuffix(expandHome(substitution.From, homeDir), "/")
+		if path == from || strings.HasPrefix(path, from+"/") {
+			return expandHome(substitution.To, homeDir) + strings.TrimPrefix(path, from)
+		}
+	}
+	return path
+}
+
+// validate checks that a host's overrides can be applied
+func (h *HostOverride) validate(problems *ValidationError, field string) {
+	if len(h.Include) > 0 && len(h.Exclude) > 0 {
+		problems.add(field, "include and exclude cannot both be set")
+	}
+	for i, substitution := range h.Paths {
+		for name, path := range map[string]string{"from": substitution.From, "to": substitution.To} {
+			if !filepath.IsAbs(path) && !strings.HasPrefix(path, "~/") {
+				problems.add(fmt.Sprintf("%s.paths[%d].%s", field, i, name), "%q must be absolute or start with ~/", path)
+			}
+		}
+	}
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(path, homeDir string) string {
+	return fsops.ExpandHome(path, homeDir)
+}
+
+// currentHost returns CONFIGSYNC_HOST, or else the hostname of the machine
+func currentHost() string {
+	if host := os.Getenv(HostEnvVar); host != "" {
+		return host
+	}
+	host, _ := os.Hostname()
+	return host
+}
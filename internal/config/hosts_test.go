@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	cfg := &Config{Hosts: map[string]*HostOverride{
+		"Laptop":  {Exclude: []string{"iterm2"}},
+		"desktop": {Include: []string{"git", "vscode"}},
+	}}
+
+	for host, expected := range map[string]*HostOverride{
+		"laptop":            cfg.Hosts["Laptop"],
+		"LAPTOP.local":      cfg.Hosts["Laptop"],
+		"desktop.home.arpa": cfg.Hosts["desktop"],
+		"server":            nil,
+		"":                  nil,
+	} {
+		if got := cfg.Host(host); got != expected {
+			t.Errorf("Host(%q) = %v, expected %v", host, got, expected)
+		}
+	}
+
+	cfg.Apps = map[string]*AppConfig{"git": NewAppConfig("git", "Git"), "iterm2": NewAppConfig("iterm2", "iTerm2"), "zsh": NewAppConfig("zsh", "Zsh")}
+	if apps := cfg.HostApps("laptop"); len(apps) != 2 || apps["iterm2"] != nil {
+		t.Errorf("Expected iterm2 to be left out on the laptop, got %v", apps)
+	}
+	if apps := cfg.HostApps("desktop"); len(apps) != 1 || apps["git"] == nil {
+		t.Errorf("Expected only git on the desktop, got %v", apps)
+	}
+	if apps := cfg.HostApps("server"); len(apps) != 3 {
+		t.Errorf("Expected every application on a host without overrides, got %v", apps)
+	}
+}
+
+func TestSubstitutePath(t *testing.T) {
+	host := &HostOverride{Paths: []PathSubstitution{
+		{From: "~/Work/", To: "~/Projects/"},
+		{From: "/opt/tools", To: "/usr/local/tools"},
+	}}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"~/Work/notes/.editorconfig", "/home/alice/Projects/notes/.editorconfig"},
+		{"/home/alice/Work", "/home/alice/Projects"},
+		{"~/Workshop/.config", "/home/alice/Workshop/.config"},
+		{"/opt/tools/config.yaml", "/usr/local/tools/config.yaml"},
+		{"~/.gitconfig", "/home/alice/.gitconfig"},
+	}
+	for _, tt := range tests {
+		if got := host.SubstitutePath(tt.path, "/home/alice"); got != tt.expected {
+			t.Errorf("SubstitutePath(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+
+	var none *HostOverride
+	if got := none.SubstitutePath("~/Work", "/home/alice"); got != "/home/alice/Work" {
+		t.Errorf("Expected a host without overrides to only expand the path, got %q", got)
+	}
+}
+
+func TestValidateHosts(t *testing.T) {
+	cfg := &Config{Hosts: map[string]*HostOverride{
+		"laptop": {
+			Include: []string{"git"},
+			Exclude: []string{"iterm2"},
+			Paths:   []PathSubstitution{{From: "Work", To: "~/Projects"}},
+		},
+	}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected invalid host overrides to fail validation")
+	}
+	for _, expected := range []string{"hosts.laptop: include and exclude", "hosts.laptop.paths[0].from"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in %v", expected, err)
+		}
+	}
+
+	if _, err := ParseConfig([]byte("hosts:\n  laptop:\n    exclud: [git]\n")); err == nil || !strings.Contains(err.Error(), `did you mean "exclude"`) {
+		t.Errorf("Expected a misspelled host field to be reported, got %v", err)
+	}
+}
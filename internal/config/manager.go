@@ -4,16 +4,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/messages"
 )
 
 const (
 	// DefaultConfigDir is the default directory name for ConfigSync configuration
 	DefaultConfigDir = ".configsync"
+	// DefaultXDGConfigDir is the directory name for ConfigSync configuration in the XDG
+	// configuration directory, ~/.config by default
+	DefaultXDGConfigDir = "configsync"
 	// DefaultConfigFile is the default filename for ConfigSync configuration
 	DefaultConfigFile = "config.yaml"
+	// DefaultAppsDir is the directory next to the configuration file that, when it exists, holds
+	// the configuration of each application in a file of its own, named <app>.yaml
+	DefaultAppsDir = "apps.d"
 	// DefaultStoreDir is the default directory name for ConfigSync store
 	DefaultStoreDir = "store"
 	// DefaultBackupDir is the default directory name for ConfigSync backups
@@ -22,38 +32,220 @@ const (
 	DefaultLogDir = "logs"
 )
 
+// Environment variables that relocate ConfigSync's files without the --home flag
+const (
+	// EnvHome names the directory that replaces ~/.configsync
+	EnvHome = "CONFIGSYNC_HOME"
+	// EnvConfig names the configuration file that replaces ~/.configsync/config.yaml
+	EnvConfig = "CONFIGSYNC_CONFIG"
+	// EnvStore names the store directory used instead of the one in the configuration file
+	EnvStore = "CONFIGSYNC_STORE"
+	// EnvDirName is the name used instead of .configsync in the home directory, and of configsync
+	// in the XDG configuration directory
+	EnvDirName = "CONFIGSYNC_DIR_NAME"
+	// EnvXDGConfigHome is the XDG base directory for configuration, ~/.config when it is unset
+	EnvXDGConfigHome = "XDG_CONFIG_HOME"
+)
+
 // Manager handles configuration file operations
 type Manager struct {
+	fs         fsys.FS
 	config     *Config
 	configDir  string
 	configPath string
+	storePath  string
+
+	// dirName and xdgConfigHome change where the configuration directory is looked for when it is
+	// not given explicitly
+	dirName       string
+	xdgConfigHome string
+
+	// storeOverride replaces the store path of the loaded configuration without being saved to it
+	storeOverride string
+	// savedStorePath is the store path in the configuration file while storeOverride is in effect
+	savedStorePath string
+}
+
+// Option customizes where a Manager keeps its files
+type Option func(*Manager)
+
+// WithConfigDir keeps the configuration, backups, and logs in dir instead of ~/.configsync
+func WithConfigDir(dir string) Option {
+	return func(m *Manager) {
+		m.configDir = dir
+		m.configPath = filepath.Join(dir, DefaultConfigFile)
+	}
+}
+
+// WithConfigFile reads and writes the configuration at path. Other files are kept next to it,
+// so it takes precedence over an earlier WithConfigDir.
+func WithConfigFile(path string) Option {
+	return func(m *Manager) {
+		m.configDir = filepath.Dir(path)
+		m.configPath = path
+	}
+}
+
+// WithConfigDirName looks for the configuration directory under name instead of .configsync in
+// the home directory and configsync in the XDG configuration directory
+func WithConfigDirName(name string) Option {
+	return func(m *Manager) {
+		m.dirName = name
+	}
+}
+
+// WithXDGConfigHome uses dir as the XDG configuration directory instead of ~/.config. Unlike
+// ~/.config, which is only used when ConfigSync's directory already exists in it, dir is used for
+// a new configuration when ~/.configsync does not exist.
+func WithXDGConfigHome(dir string) Option {
+	return func(m *Manager) {
+		m.xdgConfigHome = dir
+	}
 }
 
-// NewManager creates a new configuration manager
-func NewManager(homeDir string) *Manager {
-	configDir := filepath.Join(homeDir, DefaultConfigDir)
-	configPath := filepath.Join(configDir, DefaultConfigFile)
+// WithFS reads and writes the configuration through files instead of the operating system,
+// e.g. an in-memory file system in tests
+func WithFS(files fsys.FS) Option {
+	return func(m *Manager) {
+		m.fs = files
+	}
+}
 
-	return &Manager{
-		configDir:  configDir,
-		configPath: configPath,
+// WithStorePath uses path as the store, both when initializing and in place of the store path
+// of an existing configuration. The configuration file keeps its own store path.
+func WithStorePath(path string) Option {
+	return func(m *Manager) {
+		m.storePath = path
+		m.storeOverride = path
 	}
 }
 
+// ValidateDirName checks a name for the configuration directory given with CONFIGSYNC_DIR_NAME:
+// it, and the name without its leading dot used in the XDG configuration directory, must each
+// name a single directory, not the directory holding it or one further up
+func ValidateDirName(name string) error {
+	for _, candidate := range []string{name, strings.TrimPrefix(name, ".")} {
+		if candidate == "" || candidate == "." || candidate == ".." || strings.ContainsAny(candidate, `/\`) {
+			return fmt.Errorf("invalid %s %q: it must name a single directory, such as .configsync", EnvDirName, name)
+		}
+	}
+	return nil
+}
+
+// EnvOptions returns the options selected by the CONFIGSYNC_DIR_NAME, XDG_CONFIG_HOME,
+// CONFIGSYNC_HOME, CONFIGSYNC_CONFIG, and CONFIGSYNC_STORE environment variables, in that order
+func EnvOptions() ([]Option, error) {
+	var opts []Option
+	if name := os.Getenv(EnvDirName); name != "" {
+		if err := ValidateDirName(name); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithConfigDirName(name))
+	}
+	if dir := envPath(EnvXDGConfigHome); dir != "" {
+		opts = append(opts, WithXDGConfigHome(dir))
+	}
+	if dir := envPath(EnvHome); dir != "" {
+		opts = append(opts, WithConfigDir(dir))
+	}
+	if path := envPath(EnvConfig); path != "" {
+		opts = append(opts, WithConfigFile(path))
+	}
+	if path := envPath(EnvStore); path != "" {
+		opts = append(opts, WithStorePath(path))
+	}
+	return opts, nil
+}
+
+// EnvOverrides returns the variables of EnvOptions that are set, so processes started on
+// configsync's behalf, which may not inherit the environment, can be given the same locations
+func EnvOverrides() map[string]string {
+	overrides := make(map[string]string)
+	if name := os.Getenv(EnvDirName); name != "" {
+		overrides[EnvDirName] = name
+	}
+	for _, name := range []string{EnvXDGConfigHome, EnvHome, EnvConfig, EnvStore} {
+		if value := envPath(name); value != "" {
+			overrides[name] = value
+		}
+	}
+	return overrides
+}
+
+// envPath returns the absolute form of the path in an environment variable, or "" when it is unset
+func envPath(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(value); err == nil {
+		return abs
+	}
+	return value
+}
+
+// NewManager creates a new configuration manager for the configuration under homeDir,
+// relocated by any options. Without WithConfigDir or WithConfigFile, the configuration directory
+// is ~/.configsync or, when that does not exist, one in the XDG configuration directory.
+func NewManager(homeDir string, opts ...Option) *Manager {
+	m := &Manager{fs: fsys.OS}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.configDir == "" {
+		m.configDir = m.locateConfigDir(homeDir)
+		m.configPath = filepath.Join(m.configDir, DefaultConfigFile)
+	}
+	return m
+}
+
+// locateConfigDir returns the configuration directory under homeDir: ~/.configsync when it
+// exists, and otherwise configsync in the XDG configuration directory when that exists or was set
+// with WithXDGConfigHome. A new configuration goes in ~/.configsync otherwise.
+func (m *Manager) locateConfigDir(homeDir string) string {
+	legacyName, xdgName := DefaultConfigDir, DefaultXDGConfigDir
+	if m.dirName != "" && ValidateDirName(m.dirName) == nil {
+		legacyName, xdgName = m.dirName, strings.TrimPrefix(m.dirName, ".")
+	}
+
+	legacyDir := filepath.Join(homeDir, legacyName)
+	if fsys.Exists(m.fs, legacyDir) {
+		return legacyDir
+	}
+	xdgConfigHome := m.xdgConfigHome
+	if xdgConfigHome == "" {
+		xdgConfigHome = filepath.Join(homeDir, ".config")
+	}
+	xdgDir := filepath.Join(xdgConfigHome, xdgName)
+	if m.xdgConfigHome != "" || fsys.Exists(m.fs, xdgDir) {
+		return xdgDir
+	}
+	return legacyDir
+}
+
+// SetStorePath places the store at a custom location, such as a cloud-synced folder,
+// instead of inside the configuration directory when initializing
+func (m *Manager) SetStorePath(path string) {
+	m.storePath = path
+}
+
 // Initialize creates the configuration directory structure and initial config file
 func (m *Manager) Initialize() error {
 	// Create main config directory
-	if err := os.MkdirAll(m.configDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(m.configDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
 	// Create subdirectories
 	storeDir := filepath.Join(m.configDir, DefaultStoreDir)
+	if m.storePath != "" {
+		storeDir = m.storePath
+	}
 	backupDir := filepath.Join(m.configDir, DefaultBackupDir)
 	logDir := filepath.Join(m.configDir, DefaultLogDir)
 
 	for _, dir := range []string{storeDir, backupDir, logDir} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := m.fs.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -65,7 +257,7 @@ func (m *Manager) Initialize() error {
 	configHomeDir := filepath.Join(storeDir, ".config")
 
 	for _, dir := range []string{libraryDir, prefsDir, appSupportDir, configHomeDir} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := m.fs.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create store directory %s: %w", dir, err)
 		}
 	}
@@ -73,6 +265,12 @@ func (m *Manager) Initialize() error {
 	// Create initial config file if it doesn't exist
 	if !m.configExists() {
 		config := NewDefaultConfig(storeDir, backupDir, logDir)
+		if m.SplitLayout() {
+			// Keep applications already in the apps directory, e.g. one checked out from git
+			if err := m.loadAppsDir(config); err != nil {
+				return err
+			}
+		}
 		if err := m.saveConfig(config); err != nil {
 			return fmt.Errorf("failed to create initial config file: %w", err)
 		}
@@ -81,53 +279,146 @@ func (m *Manager) Initialize() error {
 	return nil
 }
 
-// Load loads the configuration from file
+// Load loads the configuration from file, merging in the applications of the apps directory
+// when it exists
 func (m *Manager) Load() (*Config, error) {
 	if !m.configExists() {
 		return nil, fmt.Errorf("configuration file not found: %s", m.configPath)
 	}
 
-	data, err := os.ReadFile(m.configPath)
+	data, err := m.fs.ReadFile(m.configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	migrated, version, err := MigrateConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
+	}
+	config, err := ParseConfig(migrated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
+	}
+	if m.SplitLayout() {
+		if err := m.loadAppsDir(config); err != nil {
+			return nil, err
+		}
+	}
+
+	if CompareVersions(version, CurrentVersion) < 0 {
+		if err := m.saveMigrated(config, data, version); err != nil {
+			return nil, err
+		}
 	}
 
-	m.config = &config
-	return &config, nil
+	if m.storeOverride != "" {
+		m.savedStorePath = config.StorePath
+		config.StorePath = m.storeOverride
+	}
+
+	m.config = config
+	return config, nil
 }
 
-// Save saves the configuration to file
+// Save saves the configuration to file in the current schema version. A configuration that
+// Load would reject is not written, so a bad change cannot keep ConfigSync from loading it.
 func (m *Manager) Save(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("refusing to save an invalid configuration: %w", err)
+	}
 	config.UpdatedAt = time.Now()
+	config.Version = CurrentVersion
 	return m.saveConfig(config)
 }
 
-// AddApp adds a new application configuration
+// saveMigrated writes a configuration migrated from an older schema version, after keeping the
+// file as it was next to it. An existing backup is kept, since it is the oldest original.
+func (m *Manager) saveMigrated(config *Config, original []byte, version string) error {
+	backupPath := migrationBackupPath(m.configPath, version)
+	if !fsys.Exists(m.fs, backupPath) {
+		if err := m.fs.WriteFile(backupPath, original, 0644); err != nil {
+			return fmt.Errorf("failed to back up the configuration before migrating it: %w", err)
+		}
+	}
+	if err := m.saveConfig(config); err != nil {
+		return fmt.Errorf("failed to save the configuration migrated from version %s: %w", version, err)
+	}
+	return nil
+}
+
+// AddApp adds a new application configuration, or replaces one with the same name. It returns
+// a *ValidationError when the application is invalid, and a *CollisionError when its paths
+// collide with another application's.
 func (m *Manager) AddApp(appConfig *AppConfig) error {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
+	if err := ValidateApp(appConfig); err != nil {
+		return err
+	}
+	if err := m.CheckCollisions(appConfig); err != nil {
+		return err
+	}
+
+	previous, existed := m.config.Apps[appConfig.Name]
 	m.config.Apps[appConfig.Name] = appConfig
-	return m.Save(m.config)
+	if err := m.Save(m.config); err != nil {
+		if existed {
+			m.config.Apps[appConfig.Name] = previous
+		} else {
+			delete(m.config.Apps, appConfig.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckCollisions returns a *CollisionError when an application's paths collide with those of
+// the other configured applications
+func (m *Manager) CheckCollisions(appConfig *AppConfig) error {
+	if m.config == nil {
+		if _, err := m.Load(); err != nil {
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+		}
+	}
+
+	if collisions := m.config.CollisionsWith(appConfig); len(collisions) > 0 {
+		return &CollisionError{App: appConfig.Name, Collisions: collisions}
+	}
+	return nil
 }
 
 // RemoveApp removes an application configuration
 func (m *Manager) RemoveApp(appName string) error {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
 	delete(m.config.Apps, appName)
+	m.config.removeFromGroups(appName)
+	m.config.removeDependency(appName)
+	return m.Save(m.config)
+}
+
+// SetAppEnabled enables or disables an application configuration
+func (m *Manager) SetAppEnabled(appName string, enabled bool) error {
+	if m.config == nil {
+		if _, err := m.Load(); err != nil {
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
+		}
+	}
+
+	app, exists := m.config.Apps[appName]
+	if !exists {
+		return messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
+	}
+
+	app.Enabled = enabled
 	return m.Save(m.config)
 }
 
@@ -135,13 +426,13 @@ func (m *Manager) RemoveApp(appName string) error {
 func (m *Manager) GetApp(appName string) (*AppConfig, error) {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return nil, fmt.Errorf("failed to load config: %w", err)
+			return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
 	app, exists := m.config.Apps[appName]
 	if !exists {
-		return nil, fmt.Errorf("application %s not found", appName)
+		return nil, messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
 	}
 
 	return app, nil
@@ -151,7 +442,7 @@ func (m *Manager) GetApp(appName string) (*AppConfig, error) {
 func (m *Manager) ListApps() (map[string]*AppConfig, error) {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return nil, fmt.Errorf("failed to load config: %w", err)
+			return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -162,7 +453,7 @@ func (m *Manager) ListApps() (map[string]*AppConfig, error) {
 func (m *Manager) UpdateLastSync() error {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -174,7 +465,7 @@ func (m *Manager) UpdateLastSync() error {
 func (m *Manager) GetStorePath() (string, error) {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return "", fmt.Errorf("failed to load config: %w", err)
+			return "", messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -185,7 +476,7 @@ func (m *Manager) GetStorePath() (string, error) {
 func (m *Manager) GetBackupPath() (string, error) {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return "", fmt.Errorf("failed to load config: %w", err)
+			return "", messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -196,7 +487,7 @@ func (m *Manager) GetBackupPath() (string, error) {
 func (m *Manager) GetSettings() (*Settings, error) {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return nil, fmt.Errorf("failed to load config: %w", err)
+			return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -207,7 +498,7 @@ func (m *Manager) GetSettings() (*Settings, error) {
 func (m *Manager) UpdateSettings(settings *Settings) error {
 	if m.config == nil {
 		if _, err := m.Load(); err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+			return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 		}
 	}
 
@@ -233,20 +524,49 @@ func (m *Manager) ConfigPath() string {
 // private methods
 
 func (m *Manager) configExists() bool {
-	_, err := os.Stat(m.configPath)
-	return err == nil
+	return fsys.Exists(m.fs, m.configPath)
 }
 
 func (m *Manager) saveConfig(config *Config) error {
-	data, err := yaml.Marshal(config)
+	if !m.SplitLayout() {
+		if err := m.writeConfigFile(config, config.Apps); err != nil {
+			return err
+		}
+		m.config = config
+		return nil
+	}
+
+	// Application files are written first and stale ones removed last, so an interrupted save
+	// never loses an application
+	if err := m.saveAppsDir(config.Apps); err != nil {
+		return err
+	}
+	if err := m.writeConfigFile(config, map[string]*AppConfig{}); err != nil {
+		return err
+	}
+	if err := m.removeStaleAppFiles(config.Apps); err != nil {
+		return err
+	}
+	m.config = config
+	return nil
+}
+
+// writeConfigFile writes the configuration file with the given applications under apps
+func (m *Manager) writeConfigFile(config *Config, apps map[string]*AppConfig) error {
+	toWrite := *config
+	toWrite.Apps = apps
+	// Keep an overridden store path out of the file
+	if m.storeOverride != "" && m.savedStorePath != "" && config.StorePath == m.storeOverride {
+		toWrite.StorePath = m.savedStorePath
+	}
+
+	data, err := yaml.Marshal(&toWrite)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(m.configPath, data, 0644); err != nil {
+	if err := m.fs.WriteFile(m.configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
-
-	m.config = config
 	return nil
 }
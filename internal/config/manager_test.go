@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/fsys"
 )
 
 func TestNewManager(t *testing.T) {
@@ -381,6 +383,191 @@ func TestManagerInitialize(t *testing.T) {
 	}
 }
 
+func TestManagerInitializeCustomStorePath(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "Library", "Mobile Documents", "com~apple~CloudDocs", "configsync")
+
+	manager := NewManager(tempDir)
+	manager.SetStorePath(storeDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(storeDir, "Library", "Preferences")); err != nil {
+		t.Errorf("Expected store structure in custom location: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".configsync", "store")); !os.IsNotExist(err) {
+		t.Error("Expected default store not to be created")
+	}
+
+	storePath, err := manager.GetStorePath()
+	if err != nil {
+		t.Fatalf("Failed to get store path: %v", err)
+	}
+	if storePath != storeDir {
+		t.Errorf("Expected store path %s, got %s", storeDir, storePath)
+	}
+}
+
+func TestManagerOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	configDir := filepath.Join(tempDir, "ci", "configsync")
+	storeDir := filepath.Join(tempDir, "mounted-store")
+
+	manager := NewManager(filepath.Join(tempDir, "home"), WithConfigDir(configDir), WithStorePath(storeDir))
+	if manager.ConfigPath() != filepath.Join(configDir, DefaultConfigFile) {
+		t.Errorf("Expected config path in %s, got %s", configDir, manager.ConfigPath())
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, DefaultBackupDir)); err != nil {
+		t.Errorf("Expected backups in relocated config directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "Library")); err != nil {
+		t.Errorf("Expected store structure in overridden store: %v", err)
+	}
+
+	// An override on an existing configuration is used but not written back
+	otherStore := filepath.Join(tempDir, "other-store")
+	overridden := NewManager(filepath.Join(tempDir, "home"), WithConfigDir(configDir), WithStorePath(otherStore))
+	cfg, err := overridden.Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if cfg.StorePath != otherStore {
+		t.Errorf("Expected overridden store path %s, got %s", otherStore, cfg.StorePath)
+	}
+	if err := overridden.UpdateLastSync(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reloaded, err := NewManager(filepath.Join(tempDir, "home"), WithConfigDir(configDir)).Load()
+	if err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if reloaded.StorePath != storeDir {
+		t.Errorf("Expected saved store path %s to be kept, got %s", storeDir, reloaded.StorePath)
+	}
+}
+
+func TestManagerInMemory(t *testing.T) {
+	files := fsys.NewMem()
+	homeDir := filepath.Join(string(filepath.Separator), "Users", "test")
+
+	manager := NewManager(homeDir, WithFS(files))
+	if manager.ConfigExists() {
+		t.Fatal("Expected no configuration in an empty file system")
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := manager.AddApp(NewAppConfig("testapp", "Test App")); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	reloaded := NewManager(homeDir, WithFS(files))
+	if _, err := reloaded.GetApp("testapp"); err != nil {
+		t.Errorf("Expected the app to be saved in memory: %v", err)
+	}
+	if !fsys.Exists(files, filepath.Join(homeDir, DefaultConfigDir, DefaultStoreDir, "Library", "Preferences")) {
+		t.Error("Expected the store structure in memory")
+	}
+	if _, err := os.Stat(manager.ConfigPath()); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written to disk")
+	}
+}
+
+func TestEnvOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "etc", "configsync.yaml")
+	t.Setenv(EnvHome, filepath.Join(tempDir, "configsync"))
+	t.Setenv(EnvConfig, configFile)
+	t.Setenv(EnvStore, "")
+	t.Setenv(EnvDirName, "")
+	t.Setenv(EnvXDGConfigHome, "")
+
+	opts, err := EnvOptions()
+	if err != nil {
+		t.Fatalf("EnvOptions failed: %v", err)
+	}
+	manager := NewManager("/test/home", opts...)
+	if manager.ConfigPath() != configFile {
+		t.Errorf("Expected CONFIGSYNC_CONFIG to take precedence, got %s", manager.ConfigPath())
+	}
+	if manager.GetConfigDir() != filepath.Dir(configFile) {
+		t.Errorf("Expected config directory next to the config file, got %s", manager.GetConfigDir())
+	}
+
+	overrides := EnvOverrides()
+	if len(overrides) != 2 || overrides[EnvConfig] != configFile {
+		t.Errorf("Expected the two set variables, got %v", overrides)
+	}
+}
+
+func TestValidateDirName(t *testing.T) {
+	for _, name := range []string{".configsync", "configsync", ".dotsync"} {
+		if err := ValidateDirName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{".", "..", "...", "a/b", "../x", `a\b`} {
+		if err := ValidateDirName(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+
+	t.Setenv(EnvDirName, "..")
+	if _, err := EnvOptions(); err == nil {
+		t.Error("Expected EnvOptions to reject CONFIGSYNC_DIR_NAME=..")
+	}
+	homeDir := t.TempDir()
+	if got := NewManager(homeDir, WithConfigDirName("..")).GetConfigDir(); got != filepath.Join(homeDir, DefaultConfigDir) {
+		t.Errorf("Expected an invalid name to be ignored, got %s", got)
+	}
+}
+
+func TestLocateConfigDir(t *testing.T) {
+	homeDir := t.TempDir()
+	legacyDir := filepath.Join(homeDir, DefaultConfigDir)
+	xdgDir := filepath.Join(homeDir, ".config", DefaultXDGConfigDir)
+	customXDG := filepath.Join(homeDir, "xdg")
+
+	if got := NewManager(homeDir).GetConfigDir(); got != legacyDir {
+		t.Errorf("Expected a new configuration in %s, got %s", legacyDir, got)
+	}
+	if got := NewManager(homeDir, WithXDGConfigHome(customXDG)).GetConfigDir(); got != filepath.Join(customXDG, DefaultXDGConfigDir) {
+		t.Errorf("Expected a new configuration in XDG_CONFIG_HOME, got %s", got)
+	}
+	if got := NewManager(homeDir, WithConfigDirName(".dotsync")).GetConfigDir(); got != filepath.Join(homeDir, ".dotsync") {
+		t.Errorf("Expected the custom directory name, got %s", got)
+	}
+
+	if err := os.MkdirAll(xdgDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", xdgDir, err)
+	}
+	if got := NewManager(homeDir).GetConfigDir(); got != xdgDir {
+		t.Errorf("Expected the existing directory in ~/.config, got %s", got)
+	}
+	if err := os.MkdirAll(filepath.Join(homeDir, ".config", "dotsync"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if got := NewManager(homeDir, WithConfigDirName(".dotsync")).GetConfigDir(); got != filepath.Join(homeDir, ".config", "dotsync") {
+		t.Errorf("Expected the custom directory name in ~/.config, got %s", got)
+	}
+
+	// An existing ~/.configsync keeps being used
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", legacyDir, err)
+	}
+	if got := NewManager(homeDir, WithXDGConfigHome(customXDG)).GetConfigDir(); got != legacyDir {
+		t.Errorf("Expected the existing %s, got %s", legacyDir, got)
+	}
+	if got := NewManager(homeDir, WithConfigDir(customXDG)).GetConfigDir(); got != customXDG {
+		t.Errorf("Expected WithConfigDir to take precedence, got %s", got)
+	}
+}
+
 func TestManagerAppOperations(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir)
@@ -442,6 +629,203 @@ func TestManagerAppOperations(t *testing.T) {
 	}
 }
 
+func TestManagerSetAppEnabled(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	if err := manager.AddApp(NewAppConfig("testapp", "Test App")); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	if err := manager.SetAppEnabled("testapp", false); err != nil {
+		t.Fatalf("Failed to disable app: %v", err)
+	}
+
+	// Reload from disk to check the change was saved
+	cfg, err := NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Apps["testapp"].IsEnabled() {
+		t.Error("Expected testapp to be disabled after reload")
+	}
+
+	if err := manager.SetAppEnabled("testapp", true); err != nil {
+		t.Fatalf("Failed to enable app: %v", err)
+	}
+	app, _ := manager.GetApp("testapp")
+	if !app.IsEnabled() {
+		t.Error("Expected testapp to be enabled")
+	}
+
+	if err := manager.SetAppEnabled("nonexistent", true); err == nil {
+		t.Error("Expected error when enabling non-existent app")
+	}
+}
+
+func TestManagerAddAppCollision(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	foo := NewAppConfig("foo", "Foo")
+	foo.AddPath("~/Library/Application Support/Foo", "Library/Application Support/Foo", PathTypeDirectory, false)
+	if err := manager.AddApp(foo); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	fooBeta := NewAppConfig("foo-beta", "Foo Beta")
+	fooBeta.AddPath("~/Library/Application Support/Foo Beta", "Library/Application Support/Foo", PathTypeDirectory, false)
+	err := manager.AddApp(fooBeta)
+	var collision *CollisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("Expected a *CollisionError, got %v", err)
+	}
+	if collision.App != "foo-beta" || !strings.Contains(err.Error(), "foo-beta.paths[0] and foo.paths[0]") {
+		t.Errorf("Expected the error to list the conflicting apps, got: %v", err)
+	}
+	if _, err := manager.GetApp("foo-beta"); err == nil {
+		t.Error("Expected the colliding app not to be added")
+	}
+
+	fooBeta.RenameDestination("Library/Application Support/Foo", "Library/Application Support/Foo Beta")
+	if err := manager.AddApp(fooBeta); err != nil {
+		t.Errorf("Expected the app to be added after renaming its destination, got %v", err)
+	}
+}
+
+func TestManagerAddAppInvalid(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	evil := NewAppConfig("evil", "Evil")
+	evil.AddPath("~/.evilrc", "../../../evil", PathTypeFile, false)
+	err := manager.AddApp(evil)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	if _, err := manager.GetApp("evil"); err == nil {
+		t.Error("Expected the invalid app not to be added")
+	}
+
+	// The configuration on disk still loads
+	if _, err := NewManager(tempDir).Load(); err != nil {
+		t.Errorf("Expected the configuration to load after rejecting the app, got %v", err)
+	}
+
+	// Save rejects it too
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	cfg.Apps["evil"] = evil
+	if err := manager.Save(cfg); err == nil {
+		t.Error("Expected Save to refuse an invalid configuration")
+	}
+	if _, err := NewManager(tempDir).Load(); err != nil {
+		t.Errorf("Expected the configuration to load after refusing to save it, got %v", err)
+	}
+}
+
+func TestManagerSplitLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		app := NewAppConfig(name, name)
+		app.AddPath("~/."+name+"rc", "."+name+"rc", PathTypeFile, false)
+		if err := manager.AddApp(app); err != nil {
+			t.Fatalf("Failed to add app: %v", err)
+		}
+	}
+
+	if err := manager.SplitApps(); err != nil {
+		t.Fatalf("SplitApps failed: %v", err)
+	}
+	if !manager.SplitLayout() {
+		t.Fatal("Expected the split layout after SplitApps")
+	}
+	data, err := os.ReadFile(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), ".foorc") {
+		t.Errorf("Expected the applications to be moved out of config.yaml, got:\n%s", data)
+	}
+
+	// Adding an application only writes its own file
+	barFile := filepath.Join(manager.AppsDir(), "bar.yaml")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(barFile, past, past); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	baz := NewAppConfig("baz", "Baz")
+	if err := manager.AddApp(baz); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	if info, err := os.Stat(barFile); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("Expected the file of an unchanged application not to be rewritten")
+	}
+	if err := manager.RemoveApp("foo"); err != nil {
+		t.Fatalf("Failed to remove app: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(manager.AppsDir(), "foo.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected the file of a removed application to be deleted, got %v", err)
+	}
+
+	// A fresh manager merges the application files, including ones added by hand
+	if err := os.WriteFile(filepath.Join(manager.AppsDir(), "qux.yaml"), []byte("display_name: Qux\nenabled: true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write app file: %v", err)
+	}
+	cfg, err := NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Apps) != 3 || cfg.Apps["bar"] == nil || cfg.Apps["qux"] == nil || cfg.Apps["qux"].Name != "qux" {
+		t.Errorf("Expected bar, baz, and qux to be loaded, got %v", cfg.Apps)
+	}
+	if data, err := NewManager(tempDir).ConfigData(); err != nil || !strings.Contains(string(data), ".barrc") {
+		t.Errorf("Expected ConfigData to merge the application files, got %v:\n%s", err, data)
+	}
+
+	// An application file with an unknown field is rejected naming the file
+	if err := os.WriteFile(filepath.Join(manager.AppsDir(), "qux.yaml"), []byte("enabeld: true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write app file: %v", err)
+	}
+	if _, err := NewManager(tempDir).Load(); err == nil || !strings.Contains(err.Error(), "qux.yaml") {
+		t.Errorf("Expected an error naming qux.yaml, got %v", err)
+	}
+	if err := os.Remove(filepath.Join(manager.AppsDir(), "qux.yaml")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	joined := NewManager(tempDir)
+	if err := joined.JoinApps(); err != nil {
+		t.Fatalf("JoinApps failed: %v", err)
+	}
+	if joined.SplitLayout() {
+		t.Error("Expected the apps directory to be removed by JoinApps")
+	}
+	cfg, err = NewManager(tempDir).Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Apps) != 2 || cfg.Apps["bar"] == nil || cfg.Apps["baz"] == nil {
+		t.Errorf("Expected bar and baz back in config.yaml, got %v", cfg.Apps)
+	}
+}
+
 func TestManagerPaths(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir)
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the configuration schema this build reads and writes
+const CurrentVersion = "1.0"
+
+// legacyVersion stands for configurations written before they carried a version
+const legacyVersion = "0"
+
+// migration upgrades a configuration from one schema version to the next. It works on the
+// generic form of config.yaml, since old layouts have fields the current schema rejects.
+type migration struct {
+	from, to string
+	migrate  func(doc map[string]interface{})
+}
+
+// migrations are applied in order to configurations older than CurrentVersion
+var migrations = []migration{
+	{from: legacyVersion, to: "1.0", migrate: migrateLegacy},
+}
+
+// NewerVersionError is returned for a configuration written by a newer configsync, whose schema
+// this build does not know
+type NewerVersionError struct {
+	Version string
+}
+
+// Error implements the error interface
+func (e *NewerVersionError) Error() string {
+	return fmt.Sprintf("configuration version %s is newer than this configsync supports (%s); upgrade configsync to use it", e.Version, CurrentVersion)
+}
+
+// MigrateConfig upgrades config.yaml data written for an older schema to CurrentVersion, and
+// returns it with the version it was written for. Data that is current is returned unchanged.
+// It returns a *NewerVersionError for data written for a newer schema.
+func MigrateConfig(data []byte) ([]byte, string, error) {
+	var header struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, "", err
+	}
+	version := header.Version
+	if version == "" {
+		version = legacyVersion
+	}
+
+	switch compare := CompareVersions(version, CurrentVersion); {
+	case compare == 0:
+		return data, version, nil
+	case compare > 0:
+		return nil, version, &NewerVersionError{Version: version}
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, version, err
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	for _, m := range migrations {
+		if CompareVersions(version, m.to) < 0 {
+			m.migrate(doc)
+			doc["version"] = m.to
+		}
+	}
+
+	migrated, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to encode migrated configuration: %w", err)
+	}
+	return migrated, version, nil
+}
+
+// migrationBackupPath returns where the configuration file is kept as it was before being
+// migrated from a version, e.g. config.yaml.v0.bak
+func migrationBackupPath(configPath, version string) string {
+	return filepath.Join(filepath.Dir(configPath), fmt.Sprintf("%s.v%s.bak", filepath.Base(configPath), version))
+}
+
+// migrateLegacy upgrades the unversioned layout, which kept applications under "applications"
+// with their display name in "name", and backup and logging settings at the top level
+func migrateLegacy(doc map[string]interface{}) {
+	// Configurations that merely lack a version already have the current application layout
+	apps, _ := doc["applications"].(map[string]interface{})
+	if _, ok := doc["applications"]; ok {
+		if _, exists := doc["apps"]; !exists {
+			doc["apps"] = doc["applications"]
+		}
+		delete(doc, "applications")
+	}
+	for appName, value := range apps {
+		app, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := app["name"].(string); ok && name != appName {
+			if _, exists := app["display_name"]; !exists {
+				app["display_name"] = name
+			}
+		}
+		app["name"] = appName
+		if lastSync, ok := app["last_sync"]; ok {
+			app["last_synced"] = lastSync
+			delete(app, "last_sync")
+		}
+	}
+
+	settings, _ := doc["settings"].(map[string]interface{})
+	setting := func(key string, value interface{}) {
+		if settings == nil {
+			settings = make(map[string]interface{})
+			doc["settings"] = settings
+		}
+		settings[key] = value
+	}
+	if backup, ok := doc["backup_enabled"]; ok {
+		setting("auto_backup", backup)
+		delete(doc, "backup_enabled")
+	}
+	if logging, ok := doc["logging"].(map[string]interface{}); ok {
+		if file, ok := logging["file"].(string); ok && doc["log_path"] == nil {
+			doc["log_path"] = filepath.Dir(file)
+		}
+		if logging["level"] == "debug" {
+			setting("verbose_logging", true)
+		}
+	}
+	delete(doc, "logging")
+}
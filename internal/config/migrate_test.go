@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManagerMigratesLegacyConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	if err := os.MkdirAll(manager.GetConfigDir(), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	legacy := `store_path: ` + filepath.Join(tempDir, "store") + `
+backup_enabled: true
+logging:
+  level: debug
+  file: ` + filepath.Join(tempDir, "logs", "configsync.log") + `
+applications:
+  vscode:
+    name: "Visual Studio Code"
+    enabled: true
+    paths:
+      - source: "~/Library/Application Support/Code/User/settings.json"
+        destination: "Library/Application Support/Code/User/settings.json"
+        type: file
+    last_sync: 2024-01-15T14:30:45Z
+`
+	if err := os.WriteFile(manager.ConfigPath(), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	vscode := cfg.Apps["vscode"]
+	if vscode == nil || vscode.Name != "vscode" || vscode.DisplayName != "Visual Studio Code" || vscode.LastSynced.IsZero() {
+		t.Errorf("Expected the legacy application to be migrated, got %+v", vscode)
+	}
+	if cfg.Settings == nil || !cfg.Settings.AutoBackup || !cfg.Settings.VerboseLogging || cfg.LogPath != filepath.Join(tempDir, "logs") {
+		t.Errorf("Expected the legacy settings to be migrated, got %+v and log path %s", cfg.Settings, cfg.LogPath)
+	}
+
+	backup, err := os.ReadFile(filepath.Join(manager.GetConfigDir(), "config.yaml.v0.bak"))
+	if err != nil || string(backup) != legacy {
+		t.Errorf("Expected the original file to be backed up, got %v", err)
+	}
+	data, err := os.ReadFile(manager.ConfigPath())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if !strings.Contains(string(data), "version: \""+CurrentVersion+"\"") || strings.Contains(string(data), "applications:") {
+		t.Errorf("Expected the migrated configuration to be saved, got:\n%s", data)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	current := []byte("version: \"" + CurrentVersion + "\"\napps: {}\n")
+	data, version, err := MigrateConfig(current)
+	if err != nil || version != CurrentVersion || string(data) != string(current) {
+		t.Errorf("Expected a current configuration to be left alone, got %q, %s, %v", data, version, err)
+	}
+
+	// A configuration without a version in the current layout keeps its applications as they are
+	data, _, err = MigrateConfig([]byte("apps:\n  vscode:\n    name: code\n"))
+	if err != nil || !strings.Contains(string(data), "name: code") {
+		t.Errorf("Expected the application to be unchanged, got %q, %v", data, err)
+	}
+
+	_, _, err = MigrateConfig([]byte("version: \"2.0\"\napps: {}\n"))
+	var newer *NewerVersionError
+	if !errors.As(err, &newer) || newer.Version != "2.0" {
+		t.Errorf("Expected a *NewerVersionError, got %v", err)
+	}
+	if _, err := ParseConfig([]byte("version: \"2.0\"\n")); !errors.As(err, &newer) {
+		t.Errorf("Expected ParseConfig to refuse a newer configuration, got %v", err)
+	}
+}
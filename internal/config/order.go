@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SyncOrder returns the names of apps in the order they are synced: every application after the
+// applications it depends on, and otherwise by descending priority and then by name. Dependencies
+// on applications that are not in apps are ignored. It fails when dependencies form a cycle.
+func SyncOrder(apps map[string]*AppConfig) ([]string, error) {
+	names := make([]string, 0, len(apps))
+	for name, app := range apps {
+		if app != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if apps[names[i]].Priority != apps[names[j]].Priority {
+			return apps[names[i]].Priority > apps[names[j]].Priority
+		}
+		return names[i] < names[j]
+	})
+
+	waiting := make(map[string]int, len(names))
+	for _, name := range names {
+		waiting[name] = len(dependenciesIn(apps, name))
+	}
+
+	order := make([]string, 0, len(names))
+	for len(order) < len(names) {
+		next := ""
+		for _, name := range names {
+			if waiting[name] == 0 {
+				next = name
+				break
+			}
+		}
+		if next == "" {
+			return nil, fmt.Errorf("applications depend on each other in a cycle: %s", strings.Join(dependencyCycle(apps, names, waiting), " -> "))
+		}
+		order = append(order, next)
+		waiting[next] = -1
+		for _, name := range names {
+			if slices.Contains(dependenciesIn(apps, name), next) {
+				waiting[name]--
+			}
+		}
+	}
+	return order, nil
+}
+
+// dependenciesIn returns the distinct applications in apps that an application depends on
+func dependenciesIn(apps map[string]*AppConfig, name string) []string {
+	var deps []string
+	for _, dep := range apps[name].DependsOn {
+		if app, ok := apps[dep]; ok && app != nil && !slices.Contains(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// dependencyCycle follows the dependencies of applications still waiting for one until it
+// reaches an application twice, and returns the cycle it went around
+func dependencyCycle(apps map[string]*AppConfig, names []string, waiting map[string]int) []string {
+	var path []string
+	for _, name := range names {
+		if waiting[name] > 0 {
+			path = append(path, name)
+			break
+		}
+	}
+	for {
+		current := path[len(path)-1]
+		for _, dep := range dependenciesIn(apps, current) {
+			if waiting[dep] <= 0 {
+				continue
+			}
+			if i := slices.Index(path, dep); i >= 0 {
+				return append(path[i:], dep)
+			}
+			path = append(path, dep)
+			break
+		}
+	}
+}
+
+// removeDependency drops an application that is no longer configured from the dependencies of
+// every application
+func (c *Config) removeDependency(appName string) {
+	for _, app := range c.Apps {
+		if app != nil {
+			app.DependsOn = slices.DeleteFunc(app.DependsOn, func(dep string) bool { return dep == appName })
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSyncOrder(t *testing.T) {
+	apps := map[string]*AppConfig{
+		"alacritty": NewAppConfig("alacritty", "Alacritty"),
+		"zsh":       NewAppConfig("zsh", "Zsh"),
+		"git":       NewAppConfig("git", "Git"),
+		"vscode":    NewAppConfig("vscode", "VS Code"),
+	}
+	apps["alacritty"].DependsOn = []string{"zsh", "missing"}
+	apps["git"].Priority = 10
+	apps["vscode"].Priority = -1
+	apps["zsh"].Priority = -5
+
+	order, err := SyncOrder(apps)
+	if err != nil {
+		t.Fatalf("SyncOrder failed: %v", err)
+	}
+	if expected := []string{"git", "vscode", "zsh", "alacritty"}; !slices.Equal(order, expected) {
+		t.Errorf("SyncOrder() = %v, expected %v", order, expected)
+	}
+
+	apps["zsh"].DependsOn = []string{"alacritty"}
+	_, err = SyncOrder(apps)
+	if err == nil || !strings.Contains(err.Error(), "alacritty -> zsh -> alacritty") {
+		t.Errorf("Expected the cycle to be reported, got %v", err)
+	}
+
+	var invalid *ValidationError
+	if !errors.As((&Config{Apps: apps}).Validate(), &invalid) {
+		t.Error("Expected a dependency cycle to make the configuration invalid")
+	}
+
+	cfg := &Config{Apps: apps}
+	cfg.removeDependency("zsh")
+	if !slices.Equal(apps["alacritty"].DependsOn, []string{"missing"}) {
+		t.Errorf("Expected zsh to be dropped from the dependencies, got %v", apps["alacritty"].DependsOn)
+	}
+}
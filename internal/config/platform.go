@@ -0,0 +1,125 @@
+package config
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Platforms configsync can run on, named like runtime.GOOS
+const (
+	PlatformDarwin  = "darwin"
+	PlatformLinux   = "linux"
+	PlatformWindows = "windows"
+)
+
+// Platforms lists the platforms configsync can run on
+var Platforms = []string{PlatformDarwin, PlatformLinux, PlatformWindows}
+
+// CurrentPlatform is the platform configsync is running on. It is a variable so tests can simulate others.
+var CurrentPlatform = runtime.GOOS
+
+// PathTranslation rewrites the start of a source path when a bundle created on one platform
+// is deployed on another, e.g. ~/Library/Application Support/Code/ on macOS to ~/.config/Code/ on Linux
+type PathTranslation struct {
+	FromPlatform string `yaml:"from_platform"`
+	ToPlatform   string `yaml:"to_platform"`
+	From         string `yaml:"from"` // Path prefix on the source platform; ~/ stands for the home directory
+	To           string `yaml:"to"`   // Replacement prefix on the target platform
+}
+
+// DefaultPathTranslations map the configuration locations of common cross-platform apps
+// between macOS, the XDG base directories used on Linux, and %APPDATA% and %LOCALAPPDATA% on
+// Windows. More specific prefixes come first.
+var DefaultPathTranslations = []PathTranslation{
+	{PlatformDarwin, PlatformLinux, "~/Library/Application Support/Code/", "~/.config/Code/"},
+	{PlatformDarwin, PlatformLinux, "~/Library/Application Support/Sublime Text/", "~/.config/sublime-text/"},
+	{PlatformDarwin, PlatformLinux, "~/Library/Application Support/Firefox/", "~/.mozilla/firefox/"},
+	{PlatformDarwin, PlatformLinux, "~/Library/Application Support/Google/Chrome/", "~/.config/google-chrome/"},
+	{PlatformDarwin, PlatformLinux, "~/Library/Application Support/", "~/.config/"},
+	{PlatformLinux, PlatformDarwin, "~/.config/Code/", "~/Library/Application Support/Code/"},
+	{PlatformLinux, PlatformDarwin, "~/.config/sublime-text/", "~/Library/Application Support/Sublime Text/"},
+	{PlatformLinux, PlatformDarwin, "~/.mozilla/firefox/", "~/Library/Application Support/Firefox/"},
+	{PlatformLinux, PlatformDarwin, "~/.config/google-chrome/", "~/Library/Application Support/Google/Chrome/"},
+	{PlatformDarwin, PlatformWindows, "~/Library/Application Support/Code/", "~/AppData/Roaming/Code/"},
+	{PlatformDarwin, PlatformWindows, "~/Library/Application Support/Sublime Text/", "~/AppData/Roaming/Sublime Text/"},
+	{PlatformDarwin, PlatformWindows, "~/Library/Application Support/Firefox/", "~/AppData/Roaming/Mozilla/Firefox/"},
+	{PlatformDarwin, PlatformWindows, "~/Library/Application Support/Google/Chrome/", "~/AppData/Local/Google/Chrome/User Data/"},
+	{PlatformDarwin, PlatformWindows, "~/Library/Application Support/", "~/AppData/Roaming/"},
+	{PlatformWindows, PlatformDarwin, "~/AppData/Roaming/Code/", "~/Library/Application Support/Code/"},
+	{PlatformWindows, PlatformDarwin, "~/AppData/Roaming/Sublime Text/", "~/Library/Application Support/Sublime Text/"},
+	{PlatformWindows, PlatformDarwin, "~/AppData/Roaming/Mozilla/Firefox/", "~/Library/Application Support/Firefox/"},
+	{PlatformWindows, PlatformDarwin, "~/AppData/Local/Google/Chrome/User Data/", "~/Library/Application Support/Google/Chrome/"},
+	{PlatformWindows, PlatformDarwin, "~/AppData/Roaming/", "~/Library/Application Support/"},
+	{PlatformLinux, PlatformWindows, "~/.config/Code/", "~/AppData/Roaming/Code/"},
+	{PlatformLinux, PlatformWindows, "~/.config/sublime-text/", "~/AppData/Roaming/Sublime Text/"},
+	{PlatformLinux, PlatformWindows, "~/.mozilla/firefox/", "~/AppData/Roaming/Mozilla/Firefox/"},
+	{PlatformLinux, PlatformWindows, "~/.config/google-chrome/", "~/AppData/Local/Google/Chrome/User Data/"},
+	{PlatformWindows, PlatformLinux, "~/AppData/Roaming/Code/", "~/.config/Code/"},
+	{PlatformWindows, PlatformLinux, "~/AppData/Roaming/Sublime Text/", "~/.config/sublime-text/"},
+	{PlatformWindows, PlatformLinux, "~/AppData/Roaming/Mozilla/Firefox/", "~/.mozilla/firefox/"},
+	{PlatformWindows, PlatformLinux, "~/AppData/Local/Google/Chrome/User Data/", "~/.config/google-chrome/"},
+	{PlatformWindows, PlatformLinux, "~/AppData/Roaming/", "~/.config/"},
+}
+
+// foreignHomePattern matches home directories in paths recorded on another machine, including
+// Windows profiles once their separators are forward slashes
+var foreignHomePattern = regexp.MustCompile(`^(/Users/[^/]+|/home/[^/]+|/root|[A-Za-z]:/Users/[^/]+)(/|$)`)
+
+// AppliesTo reports whether a path is used on a platform. Paths list their platforms explicitly;
+// without a list, defaults domains and paths inside ~/Library are macOS-only, paths inside
+// ~/AppData or %APPDATA% are Windows-only, and all others apply everywhere.
+func (p *Path) AppliesTo(platform string) bool {
+	if len(p.Platforms) > 0 {
+		for _, candidate := range p.Platforms {
+			if candidate == platform {
+				return true
+			}
+		}
+		return false
+	}
+
+	source := filepath.ToSlash(p.Source)
+	if p.Type == PathTypeDefaults || strings.Contains(source, "/Library/") {
+		return platform == PlatformDarwin
+	}
+	if strings.Contains(source, "/AppData/") || strings.HasPrefix(source, "%") {
+		return platform == PlatformWindows
+	}
+	return true
+}
+
+// TranslatePath maps a source path recorded on another machine to this one. The home directory
+// it was recorded under (fromHome, or any recognizable home directory when empty) is replaced with
+// homeDir, and when the platforms differ the first matching translation rewrites the path prefix.
+func TranslatePath(path, fromHome, homeDir, fromPlatform, toPlatform string, translations []PathTranslation) string {
+	if fromPlatform == PlatformWindows {
+		path, fromHome = strings.ReplaceAll(path, `\`, "/"), strings.ReplaceAll(fromHome, `\`, "/")
+	}
+	relative := path
+	switch {
+	case strings.HasPrefix(path, "~/"):
+	case fromHome != "" && (path == fromHome || strings.HasPrefix(path, strings.TrimSuffix(fromHome, "/")+"/")):
+		relative = "~" + strings.TrimPrefix(path, strings.TrimSuffix(fromHome, "/"))
+	case foreignHomePattern.MatchString(path):
+		relative = foreignHomePattern.ReplaceAllString(path, "~$2")
+	default:
+		return path
+	}
+
+	if fromPlatform != "" && fromPlatform != toPlatform {
+		for _, translation := range translations {
+			if translation.FromPlatform == fromPlatform && translation.ToPlatform == toPlatform &&
+				strings.HasPrefix(relative, translation.From) {
+				relative = translation.To + strings.TrimPrefix(relative, translation.From)
+				break
+			}
+		}
+	}
+
+	if relative == "~" {
+		return homeDir
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(relative, "~/"))
+}
@@ -0,0 +1,69 @@
+package config
+
+import "testing"
+
+func TestPathAppliesTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    Path
+		darwin  bool
+		linux   bool
+		windows bool
+	}{
+		{"dotfile", Path{Source: "/home/test/.gitconfig", Type: PathTypeFile}, true, true, true},
+		{"library", Path{Source: "/Users/test/Library/Preferences/com.test.plist", Type: PathTypeFile}, true, false, false},
+		{"defaults", Path{Source: "com.test.app", Type: PathTypeDefaults}, true, false, false},
+		{"explicit", Path{Source: "~/.config/Code/User", Type: PathTypeDirectory, Platforms: []string{PlatformLinux}}, false, true, false},
+		{"appdata", Path{Source: "~/AppData/Roaming/Code/User", Type: PathTypeDirectory}, false, false, true},
+		{"appdata variable", Path{Source: `%APPDATA%\Code\User`, Type: PathTypeDirectory}, false, false, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.path.AppliesTo(PlatformDarwin); got != tt.darwin {
+			t.Errorf("%s: AppliesTo(darwin) = %t, want %t", tt.name, got, tt.darwin)
+		}
+		if got := tt.path.AppliesTo(PlatformLinux); got != tt.linux {
+			t.Errorf("%s: AppliesTo(linux) = %t, want %t", tt.name, got, tt.linux)
+		}
+		if got := tt.path.AppliesTo(PlatformWindows); got != tt.windows {
+			t.Errorf("%s: AppliesTo(windows) = %t, want %t", tt.name, got, tt.windows)
+		}
+	}
+}
+
+func TestTranslatePath(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		fromHome     string
+		fromPlatform string
+		toPlatform   string
+		expected     string
+	}{
+		{"same platform home", "/Users/alice/.gitconfig", "/Users/alice", PlatformDarwin, PlatformDarwin, "/home/bob/.gitconfig"},
+		{"unknown home", "/Users/alice/.zshrc", "", PlatformDarwin, PlatformLinux, "/home/bob/.zshrc"},
+		{"tilde", "~/.ssh/config", "", PlatformDarwin, PlatformLinux, "/home/bob/.ssh/config"},
+		{"vscode to linux", "/Users/alice/Library/Application Support/Code/User/settings.json", "/Users/alice", PlatformDarwin, PlatformLinux, "/home/bob/.config/Code/User/settings.json"},
+		{"generic app support", "/Users/alice/Library/Application Support/Foo/config", "/Users/alice", PlatformDarwin, PlatformLinux, "/home/bob/.config/Foo/config"},
+		{"vscode to macos", "/home/alice/.config/Code/User/settings.json", "/home/alice", PlatformLinux, PlatformDarwin, "/home/bob/Library/Application Support/Code/User/settings.json"},
+		{"xdg stays on macos", "/home/alice/.config/nvim", "/home/alice", PlatformLinux, PlatformDarwin, "/home/bob/.config/nvim"},
+		{"outside home", "/etc/hosts", "/Users/alice", PlatformDarwin, PlatformLinux, "/etc/hosts"},
+		{"vscode to windows", "/Users/alice/Library/Application Support/Code/User/settings.json", "/Users/alice", PlatformDarwin, PlatformWindows, "/home/bob/AppData/Roaming/Code/User/settings.json"},
+		{"chrome to windows", "/home/alice/.config/google-chrome/Default", "/home/alice", PlatformLinux, PlatformWindows, "/home/bob/AppData/Local/Google/Chrome/User Data/Default"},
+		{"windows separators", `C:\Users\alice\AppData\Roaming\Code\User\settings.json`, `C:\Users\alice`, PlatformWindows, PlatformDarwin, "/home/bob/Library/Application Support/Code/User/settings.json"},
+		{"unknown windows home", `C:\Users\alice\AppData\Roaming\Foo\config`, "", PlatformWindows, PlatformLinux, "/home/bob/.config/Foo/config"},
+	}
+
+	for _, tt := range tests {
+		got := TranslatePath(tt.path, tt.fromHome, "/home/bob", tt.fromPlatform, tt.toPlatform, DefaultPathTranslations)
+		if got != tt.expected {
+			t.Errorf("%s: TranslatePath(%q) = %q, want %q", tt.name, tt.path, got, tt.expected)
+		}
+	}
+
+	custom := []PathTranslation{{PlatformDarwin, PlatformLinux, "~/Library/Application Support/Foo/", "~/.foo/"}}
+	got := TranslatePath("/Users/alice/Library/Application Support/Foo/config", "/Users/alice", "/home/bob", PlatformDarwin, PlatformLinux, append(custom, DefaultPathTranslations...))
+	if got != "/home/bob/.foo/config" {
+		t.Errorf("Expected custom translation to take precedence, got %q", got)
+	}
+}
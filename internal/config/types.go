@@ -1,44 +1,96 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/dotbrains/configsync/internal/ignore"
 )
 
 // Config represents the main configuration for ConfigSync
 type Config struct {
-	LastSync   time.Time             `yaml:"last_sync,omitempty"`
-	CreatedAt  time.Time             `yaml:"created_at"`
-	UpdatedAt  time.Time             `yaml:"updated_at"`
-	Apps       map[string]*AppConfig `yaml:"apps"`
-	Settings   *Settings             `yaml:"settings"`
-	Version    string                `yaml:"version"`
-	StorePath  string                `yaml:"store_path"`
-	BackupPath string                `yaml:"backup_path"`
-	LogPath    string                `yaml:"log_path"`
+	LastSync   time.Time                `yaml:"last_sync,omitempty"`
+	CreatedAt  time.Time                `yaml:"created_at"`
+	UpdatedAt  time.Time                `yaml:"updated_at"`
+	Apps       map[string]*AppConfig    `yaml:"apps"`
+	Hosts      map[string]*HostOverride `yaml:"hosts,omitempty"`  // Overrides for single machines sharing the configuration, keyed by hostname
+	Groups     map[string][]string      `yaml:"groups,omitempty"` // Named sets of applications, used as @group in place of application names
+	Settings   *Settings                `yaml:"settings"`
+	Version    string                   `yaml:"version"`
+	StorePath  string                   `yaml:"store_path"`
+	BackupPath string                   `yaml:"backup_path"`
+	LogPath    string                   `yaml:"log_path"`
 }
 
 // AppConfig represents configuration for a single application
 type AppConfig struct {
-	AddedAt      time.Time         `yaml:"added_at"`
-	LastSynced   time.Time         `yaml:"last_synced,omitempty"`
-	Metadata     map[string]string `yaml:"metadata,omitempty"`
-	Name         string            `yaml:"name"`
-	DisplayName  string            `yaml:"display_name"`
-	BundleID     string            `yaml:"bundle_id,omitempty"`
-	Paths        []Path            `yaml:"paths"`
-	Enabled      bool              `yaml:"enabled"`
-	BackupBefore bool              `yaml:"backup_before"`
+	AddedAt        time.Time         `yaml:"added_at"`
+	LastSynced     time.Time         `yaml:"last_synced,omitempty"`
+	Metadata       map[string]string `yaml:"metadata,omitempty"`
+	Name           string            `yaml:"name"`
+	DisplayName    string            `yaml:"display_name"`
+	BundleID       string            `yaml:"bundle_id,omitempty"`
+	DefaultsDomain string            `yaml:"defaults_domain,omitempty"`
+	Paths          []Path            `yaml:"paths"`
+	PostSync       []string          `yaml:"post_sync,omitempty"`
+	Ignore         []string          `yaml:"ignore,omitempty"`     // Gitignore-style patterns for entries of directory paths left out of the store, backups, and bundles
+	DependsOn      []string          `yaml:"depends_on,omitempty"` // Applications synced before this one, e.g. a shell before the terminal that sources it
+	Priority       int               `yaml:"priority,omitempty"`   // Applications with a higher priority are synced first, when dependencies allow
+	Enabled        bool              `yaml:"enabled"`
+	BackupBefore   bool              `yaml:"backup_before"`
+}
+
+// Application metadata keys describing the installed application, recorded when it is added and
+// refreshed on sync
+const (
+	// MetadataAppVersion holds the version of the installed application, to notice upgrades that
+	// move its configuration and bundles built against another version
+	MetadataAppVersion = "app_version"
+	// MetadataAppPath holds the path of the installed application bundle
+	MetadataAppPath = "app_path"
+	// MetadataAppIcon holds the path of the icon file of the installed application bundle
+	MetadataAppIcon = "app_icon"
+)
+
+// Application metadata keys describing the bundle the application's configuration was last
+// deployed from, recorded by deploy
+const (
+	// MetadataDeployedBundle holds the hash of the bundle
+	MetadataDeployedBundle = "deployed_bundle"
+	// MetadataDeployedAt holds when the configuration was deployed, in RFC 3339 format
+	MetadataDeployedAt = "deployed_at"
+	// MetadataDeployedChecksum holds the hash of the application's store files as deployed, to
+	// notice when they diverge from the bundle
+	MetadataDeployedChecksum = "deployed_checksum"
+)
+
+// InstallMetadata lists the application metadata keys that describe the installation on one
+// machine, rather than the configuration shared between machines
+var InstallMetadata = []string{
+	MetadataAppVersion, MetadataAppPath, MetadataAppIcon,
+	MetadataDeployedBundle, MetadataDeployedAt, MetadataDeployedChecksum,
 }
 
 // Path represents a configuration file or directory path within an application config
 type Path struct {
 	SyncedAt    time.Time `yaml:"synced_at,omitempty"`
-	Source      string    `yaml:"source"`      // Original path (e.g., ~/Library/Preferences/com.app.plist)
-	Destination string    `yaml:"destination"` // Path in central store
-	Type        PathType  `yaml:"type"`        // file, directory, or glob
-	Required    bool      `yaml:"required"`    // Whether this path must exist
-	BackedUp    bool      `yaml:"backed_up"`   // Whether original was backed up
-	Synced      bool      `yaml:"synced"`      // Whether currently synced
+	Source      string    `yaml:"source"`              // Original path (e.g., ~/Library/Preferences/com.app.plist)
+	Destination string    `yaml:"destination"`         // Path in central store
+	Type        PathType  `yaml:"type"`                // file, directory, or glob
+	Mode        string    `yaml:"mode,omitempty"`      // symlink (default) or copy; see PathModeCopy
+	Exclude     []string  `yaml:"exclude,omitempty"`   // Patterns skipped when copying a directory (e.g. caches)
+	Versions    string    `yaml:"versions,omitempty"`  // Glob of versioned locations; sync moves the source to the newest match
+	Links       []string  `yaml:"links,omitempty"`     // Other locations symlinked to the same store copy (e.g. a legacy path)
+	Platforms   []string  `yaml:"platforms,omitempty"` // Platforms the path is used on (e.g. darwin, linux); see AppliesTo
+	Secret      string    `yaml:"secret,omitempty"`    // keychain or 1password: the file's content is kept there, and the store holds a reference
+	Repo        string    `yaml:"repo,omitempty"`      // Git repository, with an optional //directory, the store copy is checked out from
+	Ref         string    `yaml:"ref,omitempty"`       // Branch or tag of Repo to check out; the default branch if empty
+	Plugin      string    `yaml:"plugin,omitempty"`    // Plugin that exports the source's settings into the store and imports them back, instead of a symlink
+	Required    bool      `yaml:"required"`            // Whether this path must exist
+	BackedUp    bool      `yaml:"backed_up"`           // Whether original was backed up
+	Synced      bool      `yaml:"synced"`              // Whether currently synced
 }
 
 // PathType represents the type of configuration path
@@ -51,16 +103,119 @@ const (
 	PathTypeDirectory PathType = "directory"
 	// PathTypeGlob represents a configuration glob pattern
 	PathTypeGlob PathType = "glob"
+	// PathTypeDefaults represents a user defaults domain captured with defaults export/import
+	PathTypeDefaults PathType = "defaults"
 )
 
 // Settings represents global settings for ConfigSync
 type Settings struct {
-	SymlinkMode      string   `yaml:"symlink_mode"`
-	ConflictStrategy string   `yaml:"conflict_strategy"`
-	ExcludePatterns  []string `yaml:"exclude_patterns"`
-	AutoBackup       bool     `yaml:"auto_backup"`
-	DryRun           bool     `yaml:"dry_run"`
-	VerboseLogging   bool     `yaml:"verbose_logging"`
+	SymlinkMode       string            `yaml:"symlink_mode"`
+	ConflictStrategy  string            `yaml:"conflict_strategy"` // How sync resolves conflicted copies in a cloud-synced store: ask, keep-original, keep-copy, or keep-newest
+	ExcludePatterns   []string          `yaml:"exclude_patterns"`
+	PathTranslations  []PathTranslation `yaml:"path_translations,omitempty"`     // Checked before DefaultPathTranslations when deploying bundles from another platform
+	Notifications     *Notifications    `yaml:"notifications,omitempty"`         // How failures of unattended syncs run with --notify are reported
+	Events            *EventSinks       `yaml:"events,omitempty"`                // Where structured events about operations are delivered
+	MaxDirectorySize  int64             `yaml:"max_directory_size,omitempty"`    // Bytes; larger directories need confirmation before syncing
+	SyncWorkers       int               `yaml:"sync_workers,omitempty"`          // Number of apps synced concurrently; 0 uses the CPU count
+	SizeWarning       int64             `yaml:"size_warning,omitempty"`          // Bytes; du warns about synced paths taking up more space in the store
+	StoreMode         string            `yaml:"store_mode,omitempty"`            // plain (default) or content-addressed; see StoreModeContentAddressed
+	BackupCompression string            `yaml:"backup_compression,omitempty"`    // none (default), gzip, or zstd; compressed backups are one archive per path
+	BackupRetention   int               `yaml:"backup_retention_days,omitempty"` // Days backups are kept after sync and backup; 0 keeps them all
+	PreferencesFlush  string            `yaml:"preferences_flush,omitempty"`     // How cfprefsd is made to drop cached preferences after plists change: domains (default), restart, or off
+	ReadOnlyStore     bool              `yaml:"read_only_store,omitempty"`       // Set by 'configsync store lockdown': the store is read-only, and local changes are reported as drift instead of synced into it
+	AutoBackup        bool              `yaml:"auto_backup"`
+	DryRun            bool              `yaml:"dry_run"`
+	VerboseLogging    bool              `yaml:"verbose_logging"`
+}
+
+// DefaultMaxDirectorySize is the directory size above which syncing requires confirmation
+const DefaultMaxDirectorySize int64 = 1 << 30
+
+// DirectorySizeLimit returns the configured directory size limit, falling back to the default
+func (s *Settings) DirectorySizeLimit() int64 {
+	if s == nil || s.MaxDirectorySize == 0 {
+		return DefaultMaxDirectorySize
+	}
+	return s.MaxDirectorySize
+}
+
+// DefaultSizeWarning is the store size of a synced path above which du warns about it
+const DefaultSizeWarning int64 = 100 << 20
+
+// SizeWarningThreshold returns the configured size warning threshold, falling back to the default
+func (s *Settings) SizeWarningThreshold() int64 {
+	if s == nil || s.SizeWarning == 0 {
+		return DefaultSizeWarning
+	}
+	return s.SizeWarning
+}
+
+// StoreReadOnly reports whether the store is locked down, as on machines provisioned from a team
+// baseline: commands that would change it refuse to, and sync reports local changes as drift
+func (s *Settings) StoreReadOnly() bool {
+	return s != nil && s.ReadOnlyStore
+}
+
+// Store modes
+const (
+	// StoreModePlain keeps every store file as an independent copy
+	StoreModePlain = "plain"
+	// StoreModeContentAddressed hard-links identical store, backup, and snapshot files to one blob
+	// keyed by its SHA-256 hash, recording each app's files in a manifest
+	StoreModeContentAddressed = "content-addressed"
+)
+
+// ContentAddressed reports whether the store deduplicates files as content-addressed blobs
+func (s *Settings) ContentAddressed() bool {
+	return s != nil && s.StoreMode == StoreModeContentAddressed
+}
+
+// Ways of making cfprefsd drop the cached preferences of plists that sync, restore, deploy, and
+// remove replaced on disk, which applications would otherwise keep reading
+const (
+	// PreferencesFlushDomains reads each changed domain with defaults read
+	PreferencesFlushDomains = "domains"
+	// PreferencesFlushRestart quits cfprefsd, dropping the cached preferences of every domain
+	PreferencesFlushRestart = "restart"
+	// PreferencesFlushOff leaves cfprefsd alone
+	PreferencesFlushOff = "off"
+)
+
+// PreferencesFlushMode returns how cfprefsd is made to drop cached preferences, falling back to
+// the default of reading each changed domain
+func (s *Settings) PreferencesFlushMode() string {
+	if s == nil || s.PreferencesFlush == "" {
+		return PreferencesFlushDomains
+	}
+	return s.PreferencesFlush
+}
+
+// Notifications configures how failures of unattended syncs, such as scheduled ones, are reported
+type Notifications struct {
+	Desktop string `yaml:"desktop,omitempty"` // auto (default), terminal-notifier, osascript, or off
+	Webhook string `yaml:"webhook,omitempty"` // URL that receives a JSON POST for each failure
+}
+
+// NotificationSettings returns the configured notification settings, or the defaults when unset
+func (s *Settings) NotificationSettings() Notifications {
+	if s == nil || s.Notifications == nil {
+		return Notifications{}
+	}
+	return *s.Notifications
+}
+
+// EventSinks configures where structured events about operations are delivered for other automation
+type EventSinks struct {
+	Webhook string `yaml:"webhook,omitempty"` // URL that receives each event as a JSON POST
+	Socket  string `yaml:"socket,omitempty"`  // Unix socket that receives each event as a line of JSON
+}
+
+// EventSettings returns the configured event sinks, or none when unset
+func (s *Settings) EventSettings() EventSinks {
+	if s == nil || s.Events == nil {
+		return EventSinks{}
+	}
+	return *s.Events
 }
 
 // SyncStatus represents the status of configuration synchronization
@@ -71,30 +226,104 @@ type SyncStatus struct {
 	Message     string    `yaml:"message,omitempty"`
 }
 
-// BackupInfo represents information about a backup
+// BackupInfo represents information about a backup. A backup is identified by its application,
+// the source of the path it was taken of, and its version, the time it was taken.
 type BackupInfo struct {
 	CreatedAt    time.Time `yaml:"created_at"`
 	AppName      string    `yaml:"app_name"`
+	Source       string    `yaml:"source,omitempty"` // The path's source as configured (e.g. ~/.vimrc); OriginalPath is where it was then
 	OriginalPath string    `yaml:"original_path"`
 	BackupPath   string    `yaml:"backup_path"`
+	Destination  string    `yaml:"destination,omitempty"`
+	Version      string    `yaml:"version,omitempty"`
 	Checksum     string    `yaml:"checksum,omitempty"`
 	Size         int64     `yaml:"size"`
+	Compression  string    `yaml:"compression,omitempty"` // Set when the backup is a compressed archive, whose Size and Checksum it is
+	Reason       string    `yaml:"reason,omitempty"`      // Why configsync took the backup on its own, e.g. before a restore
 }
 
 // DeploymentBundle represents a bundle of configurations for deployment
 type DeploymentBundle struct {
-	CreatedAt time.Time             `yaml:"created_at"`
-	Apps      map[string]*AppConfig `yaml:"apps"`
-	Metadata  map[string]string     `yaml:"metadata,omitempty"`
-	Version   string                `yaml:"version"`
-	CreatedBy string                `yaml:"created_by"`
+	CreatedAt  time.Time                `yaml:"created_at"`
+	Apps       map[string]*AppConfig    `yaml:"apps"`
+	Hosts      map[string]*HostOverride `yaml:"hosts,omitempty"` // The exporting configuration's host overrides
+	Metadata   map[string]string        `yaml:"metadata,omitempty"`
+	Provenance *BundleProvenance        `yaml:"provenance,omitempty"`
+	Integrity  *BundleIntegrity         `yaml:"integrity,omitempty"`
+	Version    string                   `yaml:"version"`
+	CreatedBy  string                   `yaml:"created_by"`
+	Packages   []BrewPackage            `yaml:"packages,omitempty"` // Homebrew packages providing the bundled apps
+	Info       *BundleInfo              `yaml:"info,omitempty"`
+}
+
+// BundleInfo describes a bundle for the people choosing whether to import it
+type BundleInfo struct {
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	MinVersion  string   `json:"min_version,omitempty" yaml:"min_version,omitempty"` // Oldest configsync version that may import the bundle
+	AppCount    int      `json:"app_count" yaml:"app_count"`
+	TotalSize   int64    `json:"total_size" yaml:"total_size"` // Size of the bundled files before compression
+}
+
+// Homebrew package types
+const (
+	BrewCask    = "cask"
+	BrewFormula = "formula"
+)
+
+// BrewPackage is the Homebrew cask or formula that installs a bundled application
+type BrewPackage struct {
+	App  string `yaml:"app"`
+	Name string `yaml:"name"` // Tap-qualified for packages outside the core taps
+	Type string `yaml:"type"` // BrewCask or BrewFormula
+}
+
+// BundleIntegrity lists the hash of every file in a bundle so that corruption and tampering can be detected
+type BundleIntegrity struct {
+	Files     map[string]string `yaml:"files"`     // Bundle-relative path -> hash
+	Algorithm string            `yaml:"algorithm"` // Hash algorithm, e.g. sha256
 }
 
+// BundleProvenance records where a bundle came from and what changed since its parent bundle
+type BundleProvenance struct {
+	Checksums   map[string]string `yaml:"checksums,omitempty"`
+	ParentHash  string            `yaml:"parent_hash,omitempty"`
+	Machine     string            `yaml:"machine"`
+	ToolVersion string            `yaml:"configsync_version"`
+	Changes     []BundleChange    `yaml:"changes,omitempty"`
+	History     []BundleLineage   `yaml:"history,omitempty"`
+}
+
+// BundleLineage summarizes an ancestor bundle in a provenance chain
+type BundleLineage struct {
+	CreatedAt   time.Time      `yaml:"created_at"`
+	Hash        string         `yaml:"hash"`
+	ParentHash  string         `yaml:"parent_hash,omitempty"`
+	CreatedBy   string         `yaml:"created_by"`
+	Machine     string         `yaml:"machine"`
+	ToolVersion string         `yaml:"configsync_version"`
+	Changes     []BundleChange `yaml:"changes,omitempty"`
+}
+
+// BundleChange describes an app or path that was added, removed, or modified between bundles
+type BundleChange struct {
+	Kind string `yaml:"kind"`
+	App  string `yaml:"app"`
+	Path string `yaml:"path,omitempty"`
+}
+
+// Bundle change kinds
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
 // NewDefaultConfig creates a new configuration with default settings
 func NewDefaultConfig(storePath, backupPath, logPath string) *Config {
 	now := time.Now()
 	return &Config{
-		Version:    "1.0",
+		Version:    CurrentVersion,
 		StorePath:  storePath,
 		BackupPath: backupPath,
 		LogPath:    logPath,
@@ -106,6 +335,7 @@ func NewDefaultConfig(storePath, backupPath, logPath string) *Config {
 			SymlinkMode:      "soft",
 			ExcludePatterns:  []string{".DS_Store", "*.tmp", "*.log"},
 			ConflictStrategy: "ask",
+			MaxDirectorySize: DefaultMaxDirectorySize,
 		},
 		CreatedAt: now,
 		UpdatedAt: now,
@@ -138,17 +368,99 @@ func (ac *AppConfig) AddPath(source, destination string, pathType PathType, requ
 	ac.Paths = append(ac.Paths, path)
 }
 
+// RenameDestination moves the store destinations at or below from to the same place below to,
+// returning the number of paths changed
+func (ac *AppConfig) RenameDestination(from, to string) int {
+	from, to = filepath.Clean(from), filepath.Clean(to)
+
+	renamed := 0
+	for i := range ac.Paths {
+		destination := filepath.Clean(ac.Paths[i].Destination)
+		switch {
+		case destination == from:
+			ac.Paths[i].Destination = to
+		case strings.HasPrefix(destination, from+string(filepath.Separator)):
+			ac.Paths[i].Destination = filepath.Join(to, strings.TrimPrefix(destination, from+string(filepath.Separator)))
+		default:
+			continue
+		}
+		renamed++
+	}
+	return renamed
+}
+
+// AddDefaultsPath adds a user defaults capture path for the app's defaults domain
+func (ac *AppConfig) AddDefaultsPath() {
+	domain := ac.PreferencesDomain()
+	ac.AddPath(domain, filepath.Join("Defaults", domain+".plist"), PathTypeDefaults, false)
+}
+
+// PreferencesDomain returns the user defaults domain for the app
+func (ac *AppConfig) PreferencesDomain() string {
+	if ac.DefaultsDomain != "" {
+		return ac.DefaultsDomain
+	}
+	return ac.BundleID
+}
+
 // IsEnabled checks if the app configuration is enabled
 func (ac *AppConfig) IsEnabled() bool {
 	return ac.Enabled
 }
 
+// IgnoreMatcher returns the ignore rules for the entries of a directory path whose copy is at
+// root: the common cache directories unless includeCaches is set, then the app's ignore patterns,
+// then those in root's .configsyncignore file
+func (ac *AppConfig) IgnoreMatcher(root string, includeCaches bool) (*ignore.Matcher, error) {
+	var patterns []string
+	if !includeCaches {
+		patterns = append(patterns, ignore.CachePatterns...)
+	}
+	patterns = append(patterns, ac.Ignore...)
+	if info, err := os.Stat(root); err == nil && info.IsDir() {
+		lines, err := ignore.ReadFile(filepath.Join(root, ignore.FileName))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, lines...)
+	}
+	return ignore.New(patterns)
+}
+
 // MarkSynced marks a path as synced
 func (cp *Path) MarkSynced() {
 	cp.Synced = true
 	cp.SyncedAt = time.Now()
 }
 
+// MarkUnsynced marks a path as no longer synced, once its symlink has been removed
+func (cp *Path) MarkUnsynced() {
+	cp.Synced = false
+}
+
+// IsExcluded reports whether a path relative to this path's root matches one of its exclude patterns.
+// Patterns are matched against both the full relative path and each of its components.
+func (cp *Path) IsExcluded(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, pattern := range cp.Exclude {
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+		for _, part := range strings.Split(relPath, "/") {
+			if matched, _ := filepath.Match(pattern, part); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LiveLocations returns the path's source followed by its links: every location on this system
+// that holds the store copy
+func (cp *Path) LiveLocations() []string {
+	return append([]string{cp.Source}, cp.Links...)
+}
+
 // MarkBackedUp marks a path as backed up
 func (cp *Path) MarkBackedUp() {
 	cp.BackedUp = true
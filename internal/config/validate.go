@@ -0,0 +1,674 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/ignore"
+)
+
+// SymlinkModeSoft links configuration paths into the store with symbolic links
+const SymlinkModeSoft = "soft"
+
+// validConflictStrategies mirrors the strategies defined by the store package
+var validConflictStrategies = []string{"ask", "keep-original", "keep-copy", "keep-newest"}
+
+// validBackupCompressions mirrors the compressions defined by the tarball package
+var validBackupCompressions = []string{"none", "gzip", "zstd"}
+
+// validPreferencesFlushModes lists the values of the preferences_flush setting
+var validPreferencesFlushModes = []string{PreferencesFlushDomains, PreferencesFlushRestart, PreferencesFlushOff}
+
+// validDesktopNotifiers mirrors the desktop notification backends defined by the notify package
+var validDesktopNotifiers = []string{"auto", "terminal-notifier", "osascript", "off"}
+
+// validSecretBackends mirrors the backends defined by the secrets package
+var validSecretBackends = []string{"keychain", "1password"}
+
+// validPathTypes lists the path types sync knows how to handle
+var validPathTypes = []PathType{PathTypeFile, PathTypeDirectory, PathTypeGlob, PathTypeDefaults}
+
+// unknownFieldPattern matches the errors yaml.v3 reports for fields not in the schema
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type config\.(\w+)$`)
+
+// schemaSections names the part of config.yaml each configuration type appears in
+var schemaSections = map[string]reflect.Type{
+	"Config":           reflect.TypeOf(Config{}),
+	"Settings":         reflect.TypeOf(Settings{}),
+	"AppConfig":        reflect.TypeOf(AppConfig{}),
+	"Path":             reflect.TypeOf(Path{}),
+	"PathTranslation":  reflect.TypeOf(PathTranslation{}),
+	"HostOverride":     reflect.TypeOf(HostOverride{}),
+	"PathSubstitution": reflect.TypeOf(PathSubstitution{}),
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string // Each problem names the field it concerns, e.g. "settings.symlink_mode: ..."
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d problems:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// add records a problem with a field
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Problems = append(e.Problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+// PathCollision is a store destination or source path claimed by more than one configuration path
+type PathCollision struct {
+	Kind   string   // "destination", "nested destination", or "source"
+	Path   string   // The path that is claimed twice
+	Owners []string // The claiming paths as "app.paths[i]"
+	Apps   []string // The applications owning them, in the same order
+}
+
+// ParseConfig decodes config.yaml strictly, rejecting fields that are not part of the schema
+// and values that fail Validate
+func ParseConfig(data []byte) (*Config, error) {
+	data, _, err := MigrateConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, describeDecodeError(err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// CheckConfig reports every schema problem in config.yaml, both unknown fields and invalid
+// values. Unlike ParseConfig it does not stop at unknown fields; the configuration is decoded
+// as far as possible and returned so further checks can run. The error is only set when the
+// file is not valid YAML.
+func CheckConfig(data []byte) (*Config, []string, error) {
+	var problems []string
+
+	migrated, _, err := MigrateConfig(data)
+	var newer *NewerVersionError
+	switch {
+	case errors.As(err, &newer):
+		problems = append(problems, "version: "+err.Error())
+	case err != nil:
+		return nil, nil, err
+	default:
+		data = migrated
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, err
+		}
+		problems = append(problems, describeDecodeError(err).(*ValidationError).Problems...)
+
+		// Decode again ignoring unknown fields; values that do not fit stay at their zero value
+		config = Config{}
+		_ = yaml.Unmarshal(data, &config)
+	}
+
+	var invalid *ValidationError
+	if errors.As(config.Validate(), &invalid) {
+		problems = append(problems, invalid.Problems...)
+	}
+	return &config, problems, nil
+}
+
+// CheckAppConfig reports every problem with the configuration of one application, decoded on
+// its own as it appears under apps in config.yaml: unknown fields, invalid values, and paths
+// that collide with those of the other applications. The application is returned even when
+// there are problems; the error is only set when the data is not valid YAML.
+func (c *Config) CheckAppConfig(appName string, data []byte) (*AppConfig, []string, error) {
+	app, problems, err := checkApp(appName, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	named := *app
+	named.Name = appName
+	for _, collision := range c.CollisionsWith(&named) {
+		problems = append(problems, collision.String())
+	}
+	return app, problems, nil
+}
+
+// checkApp reports the unknown fields and invalid values in the configuration of one application
+func checkApp(appName string, data []byte) (*AppConfig, []string, error) {
+	var problems []string
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var app AppConfig
+	if err := decoder.Decode(&app); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, err
+		}
+		problems = append(problems, describeDecodeError(err).(*ValidationError).Problems...)
+
+		app = AppConfig{}
+		_ = yaml.Unmarshal(data, &app)
+	}
+
+	// Validate the application alone, so problems elsewhere in the configuration are not reported
+	alone := &Config{Apps: map[string]*AppConfig{appName: &app}}
+	var invalid *ValidationError
+	if errors.As(alone.Validate(), &invalid) {
+		problems = append(problems, invalid.Problems...)
+	}
+	return &app, problems, nil
+}
+
+// ValidateApp checks one application's configuration as Validate does when the configuration
+// is loaded, so an application that would make config.yaml fail to load is never added to it
+func ValidateApp(appConfig *AppConfig) error {
+	alone := &Config{Apps: map[string]*AppConfig{appConfig.Name: appConfig}}
+	return alone.Validate()
+}
+
+// Validate checks that settings and application paths have values sync can use
+func (c *Config) Validate() error {
+	problems := &ValidationError{}
+
+	if c.Settings != nil {
+		c.Settings.validate(problems)
+	}
+
+	appNames := make([]string, 0, len(c.Apps))
+	for appName := range c.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		app := c.Apps[appName]
+		field := "apps." + appName
+		if app == nil {
+			problems.add(field, "application has no configuration")
+			continue
+		}
+		if app.Name != "" && app.Name != appName {
+			problems.add(field+".name", "%q does not match the application key %q", app.Name, appName)
+		}
+		for i := range app.Paths {
+			app.Paths[i].validate(problems, fmt.Sprintf("%s.paths[%d]", field, i))
+		}
+		for i, pattern := range app.Ignore {
+			if err := ignore.Check(pattern); err != nil {
+				problems.add(fmt.Sprintf("%s.ignore[%d]", field, i), "%q is not a valid pattern", pattern)
+			}
+		}
+	}
+
+	hostNames := make([]string, 0, len(c.Hosts))
+	for hostName := range c.Hosts {
+		hostNames = append(hostNames, hostName)
+	}
+	sort.Strings(hostNames)
+	for _, hostName := range hostNames {
+		if host := c.Hosts[hostName]; host != nil {
+			host.validate(problems, "hosts."+hostName)
+		}
+	}
+	c.validateGroups(problems)
+	if _, err := SyncOrder(c.Apps); err != nil {
+		problems.add("apps", "%v", err)
+	}
+
+	if len(problems.Problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// PathCollisions finds configuration paths that would overwrite each other: two paths
+// sharing a store destination or a source, or a destination inside another path's directory.
+// Paths used on different platforms never collide.
+func (c *Config) PathCollisions() []PathCollision {
+	owners := c.pathOwners("")
+
+	var collisions []PathCollision
+	for i := range owners {
+		for j := i + 1; j < len(owners); j++ {
+			collisions = append(collisions, owners[i].collisionsWith(owners[j])...)
+		}
+	}
+	return collisions
+}
+
+// CollisionsWith finds the paths of an application that would overwrite paths of the other
+// configured applications. A configured application with the same name is ignored, since
+// adding the application again replaces it.
+func (c *Config) CollisionsWith(appConfig *AppConfig) []PathCollision {
+	others := c.pathOwners(appConfig.Name)
+
+	var collisions []PathCollision
+	for i := range appConfig.Paths {
+		owner := pathOwner{&appConfig.Paths[i], appConfig.Name, fmt.Sprintf("%s.paths[%d]", appConfig.Name, i)}
+		for _, other := range others {
+			collisions = append(collisions, owner.collisionsWith(other)...)
+		}
+	}
+	return collisions
+}
+
+// pathOwner is a configuration path together with the application it belongs to
+type pathOwner struct {
+	path  *Path
+	app   string
+	label string // "app.paths[i]"
+}
+
+// pathOwners lists the paths of all applications except one, ordered by application name
+func (c *Config) pathOwners(except string) []pathOwner {
+	appNames := make([]string, 0, len(c.Apps))
+	for appName := range c.Apps {
+		if appName != except && c.Apps[appName] != nil {
+			appNames = append(appNames, appName)
+		}
+	}
+	sort.Strings(appNames)
+
+	var owners []pathOwner
+	for _, appName := range appNames {
+		for i := range c.Apps[appName].Paths {
+			owners = append(owners, pathOwner{&c.Apps[appName].Paths[i], appName, fmt.Sprintf("%s.paths[%d]", appName, i)})
+		}
+	}
+	return owners
+}
+
+// collisionsWith reports how two paths would overwrite each other
+func (a pathOwner) collisionsWith(b pathOwner) []PathCollision {
+	if !sharePlatform(a.path, b.path) {
+		return nil
+	}
+
+	var collisions []PathCollision
+	destA, destB := filepath.Clean(a.path.Destination), filepath.Clean(b.path.Destination)
+	switch {
+	case destA == destB:
+		collisions = append(collisions, PathCollision{"destination", destA, []string{a.label, b.label}, []string{a.app, b.app}})
+	case isWithin(destB, destA):
+		collisions = append(collisions, PathCollision{"nested destination", destB, []string{b.label, a.label}, []string{b.app, a.app}})
+	case isWithin(destA, destB):
+		collisions = append(collisions, PathCollision{"nested destination", destA, []string{a.label, b.label}, []string{a.app, b.app}})
+	}
+
+	for _, locationA := range a.path.LiveLocations() {
+		for _, locationB := range b.path.LiveLocations() {
+			if filepath.Clean(locationA) == filepath.Clean(locationB) {
+				collisions = append(collisions, PathCollision{"source", locationA, []string{a.label, b.label}, []string{a.app, b.app}})
+			}
+		}
+	}
+	return collisions
+}
+
+// String describes a collision, e.g. "destination Code/User is used by both vscode.paths[0] and cursor.paths[1]"
+func (pc PathCollision) String() string {
+	return fmt.Sprintf("%s %s is used by both %s", pc.Kind, pc.Path, strings.Join(pc.Owners, " and "))
+}
+
+// CollisionError reports that an application's paths collide with those of other applications
+type CollisionError struct {
+	App        string
+	Collisions []PathCollision
+}
+
+// Error implements the error interface
+func (e *CollisionError) Error() string {
+	lines := make([]string, len(e.Collisions))
+	for i, collision := range e.Collisions {
+		lines[i] = collision.String()
+	}
+	return fmt.Sprintf("paths collide with %s:\n  - %s", strings.Join(e.OtherApps(), ", "), strings.Join(lines, "\n  - "))
+}
+
+// OtherApps lists the applications the paths collide with
+func (e *CollisionError) OtherApps() []string {
+	var others []string
+	for _, collision := range e.Collisions {
+		for _, app := range collision.Apps {
+			if app != e.App && !contains(others, app) {
+				others = append(others, app)
+			}
+		}
+	}
+	sort.Strings(others)
+	return others
+}
+
+// validate checks the global settings
+func (s *Settings) validate(problems *ValidationError) {
+	if s.SymlinkMode != "" && s.SymlinkMode != SymlinkModeSoft {
+		problems.add("settings.symlink_mode", "%q is not a valid mode (use %s)", s.SymlinkMode, SymlinkModeSoft)
+	}
+	if s.ConflictStrategy != "" && !contains(validConflictStrategies, s.ConflictStrategy) {
+		problems.add("settings.conflict_strategy", "%q is not a valid strategy (use %s)", s.ConflictStrategy, strings.Join(validConflictStrategies, ", "))
+	}
+	if s.StoreMode != "" && s.StoreMode != StoreModePlain && s.StoreMode != StoreModeContentAddressed {
+		problems.add("settings.store_mode", "%q is not a valid mode (use %s or %s)", s.StoreMode, StoreModePlain, StoreModeContentAddressed)
+	}
+	if s.BackupCompression != "" && !contains(validBackupCompressions, s.BackupCompression) {
+		problems.add("settings.backup_compression", "%q is not a valid compression (use %s)", s.BackupCompression, strings.Join(validBackupCompressions, ", "))
+	}
+	if s.PreferencesFlush != "" && !contains(validPreferencesFlushModes, s.PreferencesFlush) {
+		problems.add("settings.preferences_flush", "%q is not a valid mode (use %s)", s.PreferencesFlush, strings.Join(validPreferencesFlushModes, ", "))
+	}
+	if s.BackupRetention < 0 {
+		problems.add("settings.backup_retention_days", "must not be negative, got %d (use 0 to keep every backup)", s.BackupRetention)
+	}
+	if s.MaxDirectorySize < 0 {
+		problems.add("settings.max_directory_size", "must not be negative, got %d", s.MaxDirectorySize)
+	}
+	if s.SizeWarning < 0 {
+		problems.add("settings.size_warning", "must not be negative, got %d", s.SizeWarning)
+	}
+	if s.SyncWorkers < 0 {
+		problems.add("settings.sync_workers", "must not be negative, got %d (use 0 for the CPU count)", s.SyncWorkers)
+	}
+	for i, pattern := range s.ExcludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			problems.add(fmt.Sprintf("settings.exclude_patterns[%d]", i), "%q is not a valid pattern", pattern)
+		}
+	}
+	if n := s.Notifications; n != nil {
+		if n.Desktop != "" && !contains(validDesktopNotifiers, n.Desktop) {
+			problems.add("settings.notifications.desktop", "%q is not a valid notifier (use %s)", n.Desktop, strings.Join(validDesktopNotifiers, ", "))
+		}
+		validateWebhook(problems, "settings.notifications.webhook", n.Webhook)
+	}
+	if e := s.Events; e != nil {
+		validateWebhook(problems, "settings.events.webhook", e.Webhook)
+		if e.Socket != "" && !filepath.IsAbs(e.Socket) && !strings.HasPrefix(e.Socket, "~/") {
+			problems.add("settings.events.socket", "%q must be an absolute path", e.Socket)
+		}
+	}
+	for i, translation := range s.PathTranslations {
+		field := fmt.Sprintf("settings.path_translations[%d]", i)
+		if !isKnownPlatform(translation.FromPlatform) {
+			problems.add(field+".from_platform", "%q is not a known platform (use %s)", translation.FromPlatform, strings.Join(Platforms, ", "))
+		}
+		if !isKnownPlatform(translation.ToPlatform) {
+			problems.add(field+".to_platform", "%q is not a known platform (use %s)", translation.ToPlatform, strings.Join(Platforms, ", "))
+		}
+		if translation.From == "" || translation.To == "" {
+			problems.add(field, "from and to are both required")
+		}
+	}
+}
+
+// validateWebhook checks that an optional webhook is an http or https URL
+func validateWebhook(problems *ValidationError, field, webhook string) {
+	if webhook == "" {
+		return
+	}
+	if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems.add(field, "%q is not an http or https URL", webhook)
+	}
+}
+
+// validate checks one application path
+func (p *Path) validate(problems *ValidationError, field string) {
+	if p.Source == "" {
+		problems.add(field+".source", "is required")
+	}
+
+	switch {
+	case p.Destination == "":
+		problems.add(field+".destination", "is required")
+	case filepath.IsAbs(p.Destination):
+		problems.add(field+".destination", "%q must be relative to the store", p.Destination)
+	case filepath.Clean(p.Destination) == "." || isOutside(p.Destination):
+		problems.add(field+".destination", "%q must be inside the store", p.Destination)
+	}
+
+	if p.Type != "" && !containsPathType(p.Type) {
+		names := make([]string, len(validPathTypes))
+		for i, pathType := range validPathTypes {
+			names[i] = string(pathType)
+		}
+		problems.add(field+".type", "%q is not a valid type (use %s)", p.Type, strings.Join(names, ", "))
+	}
+
+	if p.Mode != "" && p.Mode != PathModeSymlink && p.Mode != PathModeCopy {
+		problems.add(field+".mode", "%q is not a valid mode (use %s or %s)", p.Mode, PathModeSymlink, PathModeCopy)
+	}
+
+	if p.Secret != "" {
+		switch {
+		case !slices.Contains(validSecretBackends, p.Secret):
+			problems.add(field+".secret", "%q is not a valid secret backend (use %s)", p.Secret, strings.Join(validSecretBackends, " or "))
+		case p.Type != PathTypeFile:
+			problems.add(field+".secret", "only file paths can be kept as secrets")
+		case p.IsCopyMode() || len(p.Links) > 0 || p.Versions != "":
+			problems.add(field+".secret", "secret paths cannot have a copy mode, links, or versions")
+		}
+	}
+
+	if p.Repo != "" {
+		switch _, err := gitsource.Parse(p.Repo); {
+		case err != nil:
+			problems.add(field+".repo", "%v", err)
+		case p.Type != PathTypeDirectory:
+			problems.add(field+".repo", "only directory paths can be checked out from a repository")
+		case p.IsSecret() || p.Versions != "":
+			problems.add(field+".repo", "paths checked out from a repository cannot be secrets or have versions")
+		}
+	} else if p.Ref != "" {
+		problems.add(field+".ref", "a ref needs a repo to check out")
+	}
+
+	if p.Plugin != "" {
+		switch {
+		case p.Plugin != filepath.Base(p.Plugin) || strings.HasPrefix(p.Plugin, "."):
+			problems.add(field+".plugin", "%q must name a plugin in the plugins directory", p.Plugin)
+		case p.Type != PathTypeFile && p.Type != PathTypeDirectory:
+			problems.add(field+".plugin", "plugin paths must be a file or directory in the store")
+		case p.IsCopyMode() || p.IsSecret() || p.Repo != "" || len(p.Links) > 0 || p.Versions != "":
+			problems.add(field+".plugin", "plugin paths cannot have a copy mode, secret, repo, links, or versions")
+		}
+	}
+
+	for _, platform := range p.Platforms {
+		if !isKnownPlatform(platform) {
+			problems.add(field+".platforms", "%q is not a known platform (use %s)", platform, strings.Join(Platforms, ", "))
+		}
+	}
+
+	for i, pattern := range p.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			problems.add(fmt.Sprintf("%s.exclude[%d]", field, i), "%q is not a valid pattern", pattern)
+		}
+	}
+
+	if p.Versions != "" {
+		if _, err := filepath.Match(p.Versions, ""); err != nil {
+			problems.add(field+".versions", "%q is not a valid pattern", p.Versions)
+		} else if !filepath.IsAbs(p.Versions) && !strings.HasPrefix(p.Versions, "~/") {
+			problems.add(field+".versions", "%q must be an absolute path", p.Versions)
+		}
+		if p.Type == PathTypeDefaults {
+			problems.add(field+".versions", "defaults paths have no versioned location")
+		}
+	}
+
+	if len(p.Links) > 0 && p.Type == PathTypeDefaults {
+		problems.add(field+".links", "defaults paths have no file to link")
+	}
+	seen := map[string]bool{filepath.Clean(p.Source): true}
+	for i, link := range p.Links {
+		linkField := fmt.Sprintf("%s.links[%d]", field, i)
+		switch {
+		case link == "":
+			problems.add(linkField, "must not be empty")
+		case !filepath.IsAbs(link) && !strings.HasPrefix(link, "~/"):
+			problems.add(linkField, "%q must be an absolute path", link)
+		case seen[filepath.Clean(link)]:
+			problems.add(linkField, "%q is already a location of this path", link)
+		}
+		seen[filepath.Clean(link)] = true
+	}
+}
+
+// describeDecodeError rewrites yaml errors about unknown fields to name the config.yaml
+// section and suggest the field that was probably meant
+func describeDecodeError(err error) error {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+
+	problems := &ValidationError{}
+	for _, message := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(message)
+		if match == nil {
+			problems.Problems = append(problems.Problems, message)
+			continue
+		}
+
+		line, field, typeName := match[1], match[2], match[3]
+		problem := fmt.Sprintf("line %s: unknown field %q in %s", line, field, sectionName(typeName))
+		if suggestion := closestField(schemaSections[typeName], field); suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		problems.Problems = append(problems.Problems, problem)
+	}
+	return problems
+}
+
+// sectionName describes where fields of a configuration type appear in config.yaml
+func sectionName(typeName string) string {
+	switch typeName {
+	case "Config":
+		return "the top level"
+	case "Settings":
+		return "settings"
+	case "AppConfig":
+		return "an application"
+	case "Path":
+		return "an application path"
+	case "PathTranslation":
+		return "a path translation"
+	case "HostOverride":
+		return "a host"
+	case "PathSubstitution":
+		return "a host path substitution"
+	}
+	return typeName
+}
+
+// closestField returns the yaml field of a type nearest to a misspelled name, or "" when none is close
+func closestField(structType reflect.Type, name string) string {
+	if structType == nil {
+		return ""
+	}
+
+	best, bestDistance := "", 3 // Only suggest names within two edits
+	for i := 0; i < structType.NumField(); i++ {
+		tag := strings.Split(structType.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		if distance := editDistance(strings.ToLower(name), tag); distance < bestDistance {
+			best, bestDistance = tag, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// sharePlatform reports whether two paths are used on a common platform
+func sharePlatform(a, b *Path) bool {
+	for _, platform := range Platforms {
+		if a.AppliesTo(platform) && b.AppliesTo(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithin reports whether path lies inside dir
+func isWithin(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// isOutside reports whether a relative path climbs out of the directory it is relative to
+func isOutside(path string) bool {
+	clean := filepath.Clean(path)
+	return clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+func isKnownPlatform(platform string) bool {
+	return contains(Platforms, platform)
+}
+
+func containsPathType(pathType PathType) bool {
+	for _, valid := range validPathTypes {
+		if pathType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
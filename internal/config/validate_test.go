@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string // Expected in the error; empty when parsing should succeed
+	}{
+		{
+			name:    "valid",
+			content: "version: \"1.0\"\nstore_path: /store\nsettings:\n  symlink_mode: soft\n  conflict_strategy: keep-newest\n",
+		},
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "unknown top-level field",
+			content: "version: \"1.0\"\nstore_pth: /store\n",
+			want:    `line 2: unknown field "store_pth" in the top level (did you mean "store_path"?)`,
+		},
+		{
+			name:    "unknown field without suggestion",
+			content: "settings:\n  colour_scheme: dark\n",
+			want:    `unknown field "colour_scheme" in settings`,
+		},
+		{
+			name:    "invalid symlink mode",
+			content: "settings:\n  symlink_mode: banana\n",
+			want:    `settings.symlink_mode: "banana" is not a valid mode (use soft)`,
+		},
+		{
+			name:    "invalid conflict strategy",
+			content: "settings:\n  conflict_strategy: newest\n",
+			want:    `settings.conflict_strategy: "newest" is not a valid strategy`,
+		},
+		{
+			name:    "invalid store mode",
+			content: "settings:\n  store_mode: dedupe\n",
+			want:    `settings.store_mode: "dedupe" is not a valid mode`,
+		},
+		{
+			name:    "invalid backup compression",
+			content: "settings:\n  backup_compression: xz\n",
+			want:    `settings.backup_compression: "xz" is not a valid compression (use none, gzip, zstd)`,
+		},
+		{
+			name:    "negative backup retention",
+			content: "settings:\n  backup_retention_days: -1\n",
+			want:    "settings.backup_retention_days: must not be negative",
+		},
+		{
+			name:    "unknown preferences flush",
+			content: "settings:\n  preferences_flush: always\n",
+			want:    `settings.preferences_flush: "always" is not a valid mode (use domains, restart, off)`,
+		},
+		{
+			name:    "negative workers",
+			content: "settings:\n  sync_workers: -2\n",
+			want:    "settings.sync_workers: must not be negative",
+		},
+		{
+			name:    "invalid desktop notifier",
+			content: "settings:\n  notifications:\n    desktop: growl\n",
+			want:    `settings.notifications.desktop: "growl" is not a valid notifier`,
+		},
+		{
+			name:    "invalid webhook",
+			content: "settings:\n  notifications:\n    webhook: ftp://example.com\n",
+			want:    `settings.notifications.webhook: "ftp://example.com" is not an http or https URL`,
+		},
+		{
+			name:    "relative event socket",
+			content: "settings:\n  events:\n    socket: events.sock\n",
+			want:    `settings.events.socket: "events.sock" must be an absolute path`,
+		},
+		{
+			name:    "bad exclude pattern",
+			content: "settings:\n  exclude_patterns: [\"[unclosed\"]\n",
+			want:    `settings.exclude_patterns[0]: "[unclosed" is not a valid pattern`,
+		},
+		{
+			name:    "unknown platform in translation",
+			content: "settings:\n  path_translations:\n    - from_platform: freebsd\n      to_platform: linux\n      from: ~/a/\n      to: ~/b/\n",
+			want:    `settings.path_translations[0].from_platform: "freebsd" is not a known platform`,
+		},
+		{
+			name:    "app name mismatch",
+			content: "apps:\n  vscode:\n    name: code\n",
+			want:    `apps.vscode.name: "code" does not match the application key "vscode"`,
+		},
+		{
+			name:    "path problems",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.gitconfig\n        destination: ../outside\n        type: symlink\n        platforms: [freebsd]\n",
+			want:    "3 problems",
+		},
+		{
+			name:    "bad ignore pattern",
+			content: "apps:\n  chrome:\n    ignore: [\"Cache/\", \"[unclosed/\"]\n",
+			want:    `apps.chrome.ignore[1]: "[unclosed/" is not a valid pattern`,
+		},
+		{
+			name:    "unknown path mode",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.gitconfig\n        destination: .gitconfig\n        mode: hardlink\n",
+			want:    `apps.git.paths[0].mode: "hardlink" is not a valid mode (use symlink or copy)`,
+		},
+		{
+			name:    "secret directory",
+			content: "apps:\n  ssh:\n    paths:\n      - source: ~/.ssh\n        destination: .ssh\n        type: directory\n        secret: keychain\n",
+			want:    `apps.ssh.paths[0].secret: only file paths can be kept as secrets`,
+		},
+		{
+			name:    "unknown secret backend",
+			content: "apps:\n  npm:\n    paths:\n      - source: ~/.npmrc\n        destination: .npmrc\n        type: file\n        secret: vault\n",
+			want:    `apps.npm.paths[0].secret: "vault" is not a valid secret backend (use keychain or 1password)`,
+		},
+		{
+			name:    "repository file",
+			content: "apps:\n  vim:\n    paths:\n      - source: ~/.vimrc\n        destination: .vimrc\n        type: file\n        repo: github.com/me/dotfiles\n",
+			want:    `apps.vim.paths[0].repo: only directory paths can be checked out from a repository`,
+		},
+		{
+			name:    "ref without repository",
+			content: "apps:\n  nvim:\n    paths:\n      - source: ~/.config/nvim\n        destination: nvim\n        type: directory\n        ref: main\n",
+			want:    `apps.nvim.paths[0].ref: a ref needs a repo to check out`,
+		},
+		{
+			name:    "plugin with links",
+			content: "apps:\n  dbeaver:\n    paths:\n      - source: ~/.dbeaver/settings.db\n        destination: dbeaver/settings.json\n        type: file\n        plugin: sqlite-settings\n        links: [~/.dbeaver-old/settings.db]\n",
+			want:    `apps.dbeaver.paths[0].plugin: plugin paths cannot have a copy mode, secret, repo, links, or versions`,
+		},
+		{
+			name:    "plugin path",
+			content: "apps:\n  dbeaver:\n    paths:\n      - source: ~/.dbeaver/settings.db\n        destination: dbeaver/settings.json\n        type: file\n        plugin: ../bin/sqlite\n",
+			want:    `apps.dbeaver.paths[0].plugin: "../bin/sqlite" must name a plugin in the plugins directory`,
+		},
+		{
+			name:    "absolute destination",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.gitconfig\n        destination: /etc/gitconfig\n",
+			want:    `apps.git.paths[0].destination: "/etc/gitconfig" must be relative to the store`,
+		},
+		{
+			name:    "unknown path field",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.gitconfig\n        destination: .gitconfig\n        requried: true\n",
+			want:    `unknown field "requried" in an application path (did you mean "required"?)`,
+		},
+		{
+			name:    "links",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.config/git/config\n        destination: .gitconfig\n        links: [~/.gitconfig]\n",
+		},
+		{
+			name:    "relative link",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.config/git/config\n        destination: .gitconfig\n        links: [.gitconfig]\n",
+			want:    `apps.git.paths[0].links[0]: ".gitconfig" must be an absolute path`,
+		},
+		{
+			name:    "link to the source",
+			content: "apps:\n  git:\n    paths:\n      - source: ~/.gitconfig\n        destination: .gitconfig\n        links: [~/.gitconfig]\n",
+			want:    `apps.git.paths[0].links[0]: "~/.gitconfig" is already a location of this path`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.content))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ParseConfig() error = %v", err)
+				}
+				if config == nil {
+					t.Fatal("ParseConfig() returned nil config")
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("ParseConfig() succeeded, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseConfig() error = %q, want it to contain %q", err, tt.want)
+			}
+			var validation *ValidationError
+			if !errors.As(err, &validation) {
+				t.Errorf("ParseConfig() error should be a *ValidationError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestCheckConfigCollectsAllProblems(t *testing.T) {
+	content := "stor_path: /store\nsettings:\n  symlink_mode: hard\napps:\n  git:\n    paths:\n      - source: \"\"\n        destination: .gitconfig\n"
+
+	config, problems, err := CheckConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("CheckConfig() error = %v", err)
+	}
+	if config == nil || config.Settings == nil || config.Settings.SymlinkMode != "hard" {
+		t.Fatalf("CheckConfig() should decode the rest of the file despite unknown fields, got %+v", config)
+	}
+	if len(problems) != 3 {
+		t.Errorf("CheckConfig() problems = %v, want 3", problems)
+	}
+
+	if _, _, err := CheckConfig([]byte("apps: [unclosed")); err == nil {
+		t.Error("CheckConfig() should fail on invalid YAML")
+	}
+}
+
+func TestPathCollisions(t *testing.T) {
+	config := &Config{Apps: map[string]*AppConfig{}}
+
+	vscode := NewAppConfig("vscode", "VS Code")
+	vscode.AddPath("~/Library/Application Support/Code/User", "Code/User", PathTypeDirectory, false)
+	config.Apps["vscode"] = vscode
+
+	cursor := NewAppConfig("cursor", "Cursor")
+	cursor.AddPath("~/Library/Application Support/Cursor/User/settings.json", "Code/User/settings.json", PathTypeFile, false)
+	cursor.AddPath("~/.cursorrc", ".cursorrc", PathTypeFile, false)
+	config.Apps["cursor"] = cursor
+
+	git := NewAppConfig("git", "Git")
+	git.AddPath("~/.cursorrc", ".gitconfig", PathTypeFile, false)
+	git.AddPath("~/.config/git/config", ".cursorrc", PathTypeFile, false)
+	// The same destination on different platforms does not collide
+	git.AddPath("~/Library/Preferences/git.plist", "git/prefs", PathTypeFile, false)
+	git.AddPath("~/.config/git/prefs", "git/prefs", PathTypeFile, false)
+	git.Paths[3].Platforms = []string{PlatformLinux}
+	config.Apps["git"] = git
+
+	collisions := config.PathCollisions()
+	got := make([]string, len(collisions))
+	for i, collision := range collisions {
+		got[i] = collision.Kind + " " + collision.Path + " " + strings.Join(collision.Owners, ",")
+	}
+
+	want := []string{
+		"nested destination Code/User/settings.json cursor.paths[0],vscode.paths[0]",
+		"source ~/.cursorrc cursor.paths[1],git.paths[0]",
+		"destination .cursorrc cursor.paths[1],git.paths[1]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("PathCollisions() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPathCollisionsWithLinks(t *testing.T) {
+	config := &Config{Apps: map[string]*AppConfig{}}
+
+	nvim := NewAppConfig("nvim", "Neovim")
+	nvim.AddPath("~/.config/nvim/init.vim", "nvim/init.vim", PathTypeFile, false)
+	nvim.Paths[0].Links = []string{"~/.vimrc"}
+	config.Apps["nvim"] = nvim
+
+	vim := NewAppConfig("vim", "Vim")
+	vim.AddPath("~/.vimrc", ".vimrc", PathTypeFile, false)
+	config.Apps["vim"] = vim
+
+	collisions := config.PathCollisions()
+	if len(collisions) != 1 || collisions[0].Kind != "source" || collisions[0].Path != "~/.vimrc" {
+		t.Errorf("Expected a link to collide with another app's source, got %+v", collisions)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"symlink_mode", "symlink_mode", 0},
+		{"symlnk_mode", "symlink_mode", 1},
+		{"requried", "required", 2},
+		{"abc", "", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCollisionsWith(t *testing.T) {
+	config := &Config{Apps: map[string]*AppConfig{}}
+	vscode := NewAppConfig("vscode", "VS Code")
+	vscode.AddPath("~/Library/Application Support/Code/User", "Library/Application Support/Code/User", PathTypeDirectory, false)
+	config.Apps["vscode"] = vscode
+
+	// Adding the same application again replaces it rather than colliding with it
+	if collisions := config.CollisionsWith(vscode); len(collisions) != 0 {
+		t.Errorf("CollisionsWith() the app itself = %v, want none", collisions)
+	}
+
+	cursor := NewAppConfig("cursor", "Cursor")
+	cursor.AddPath("~/Library/Application Support/Cursor/User", "Library/Application Support/Code/User", PathTypeDirectory, false)
+	collisions := config.CollisionsWith(cursor)
+	if len(collisions) != 1 {
+		t.Fatalf("CollisionsWith() = %v, want one collision", collisions)
+	}
+
+	err := &CollisionError{App: "cursor", Collisions: collisions}
+	if others := err.OtherApps(); len(others) != 1 || others[0] != "vscode" {
+		t.Errorf("OtherApps() = %v, want [vscode]", others)
+	}
+	want := "paths collide with vscode:\n  - destination Library/Application Support/Code/User is used by both cursor.paths[0] and vscode.paths[0]"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestCheckAppConfig(t *testing.T) {
+	config := &Config{Apps: map[string]*AppConfig{}}
+	vscode := NewAppConfig("vscode", "VS Code")
+	vscode.AddPath("~/Library/Application Support/Code/User", "Library/Application Support/Code/User", PathTypeDirectory, false)
+	config.Apps["vscode"] = vscode
+	config.Apps["broken"] = &AppConfig{Name: "other"} // Problems of other applications are not reported
+
+	edited := `name: cursor
+display_name: Cursor
+enabeld: true
+paths:
+  - source: ~/Library/Application Support/Cursor/User
+    destination: Library/Application Support/Code/User
+    type: directory
+`
+	app, problems, err := config.CheckAppConfig("cursor", []byte(edited))
+	if err != nil {
+		t.Fatalf("CheckAppConfig() error = %v", err)
+	}
+	if app.DisplayName != "Cursor" {
+		t.Errorf("CheckAppConfig() app = %+v, want the decoded application", app)
+	}
+	if len(problems) != 2 || !strings.Contains(problems[0], `did you mean "enabled"?`) || !strings.Contains(problems[1], "is used by both") {
+		t.Errorf("CheckAppConfig() problems = %v, want the unknown field and the collision", problems)
+	}
+
+	if _, _, err := config.CheckAppConfig("cursor", []byte("paths: [")); err == nil {
+		t.Error("CheckAppConfig() expected an error for invalid YAML")
+	}
+}
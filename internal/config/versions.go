@@ -0,0 +1,68 @@
+package config
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionNumberPattern matches the numbers in a versioned directory name, e.g. 2024 and 1 in GoLand2024.1
+var versionNumberPattern = regexp.MustCompile(`\d+`)
+
+// NewestVersion returns the match of a glob of versioned locations with the highest version, e.g.
+// ~/Library/Application Support/JetBrains/GoLand2024.2 for .../JetBrains/GoLand*, or "" when
+// nothing matches. Patterns may start with ~/ or the home directory, and the match keeps that form.
+func NewestVersion(homeDir, pattern string) string {
+	prefix, rel := splitHome(homeDir, pattern)
+	glob := pattern
+	if prefix == "~" {
+		glob = filepath.Join(homeDir, filepath.FromSlash(rel))
+	}
+
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return ""
+	}
+	newest := ""
+	for _, match := range matches {
+		if newest == "" || CompareVersions(filepath.Base(match), filepath.Base(newest)) > 0 {
+			newest = match
+		}
+	}
+
+	if newest == "" || prefix != "~" {
+		return newest
+	}
+	newestRel, err := filepath.Rel(homeDir, newest)
+	if err != nil {
+		return newest
+	}
+	return joinHome(prefix, filepath.ToSlash(newestRel))
+}
+
+// CompareVersions orders versioned names by the numbers in them, so GoLand2024.10 sorts after
+// GoLand2024.9 and 2024.1.1 after 2024.1. Names with the same numbers are compared as strings.
+func CompareVersions(a, b string) int {
+	numbersA, numbersB := versionNumberPattern.FindAllString(a, -1), versionNumberPattern.FindAllString(b, -1)
+	for i := 0; i < len(numbersA) && i < len(numbersB); i++ {
+		x, errX := strconv.ParseUint(numbersA[i], 10, 64)
+		y, errY := strconv.ParseUint(numbersB[i], 10, 64)
+		if errX != nil || errY != nil {
+			break
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	switch {
+	case len(numbersA) < len(numbersB):
+		return -1
+	case len(numbersA) > len(numbersB):
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"GoLand2024.2", "GoLand2024.1", 1},
+		{"GoLand2024.10", "GoLand2024.9", 1},
+		{"GoLand2023.3", "GoLand2024.1", -1},
+		{"GoLand2024.1.1", "GoLand2024.1", 1},
+		{"GoLand2024.1", "GoLand2024.1", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestNewestVersion(t *testing.T) {
+	homeDir := t.TempDir()
+	jetBrainsDir := filepath.Join(homeDir, ".config", "JetBrains")
+	for _, name := range []string{"GoLand2023.3", "GoLand2024.10", "GoLand2024.9", "PyCharm2025.1"} {
+		if err := os.MkdirAll(filepath.Join(jetBrainsDir, name), 0755); err != nil {
+			t.Fatalf("Failed to create version directory: %v", err)
+		}
+	}
+
+	if newest := NewestVersion(homeDir, "~/.config/JetBrains/GoLand*"); newest != "~/.config/JetBrains/GoLand2024.10" {
+		t.Errorf("Expected the newest match in ~/ form, got %q", newest)
+	}
+	if newest := NewestVersion(homeDir, filepath.Join(jetBrainsDir, "GoLand*")); newest != filepath.Join(jetBrainsDir, "GoLand2024.10") {
+		t.Errorf("Expected the newest match as an absolute path, got %q", newest)
+	}
+	if newest := NewestVersion(homeDir, "~/.config/JetBrains/WebStorm*"); newest != "" {
+		t.Errorf("Expected no match, got %q", newest)
+	}
+}
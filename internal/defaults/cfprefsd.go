@@ -0,0 +1,109 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PreferencesDir is where macOS keeps user preferences, relative to the home directory
+const PreferencesDir = "Library/Preferences"
+
+// byHostSuffix matches the hardware UUID, or the older MAC address, that ends the name of a
+// per-host preferences file in Library/Preferences/ByHost
+var byHostSuffix = regexp.MustCompile(`\.([0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}|[0-9A-Fa-f]{12})$`)
+
+// Domain is a defaults domain whose preferences file changed on disk
+type Domain struct {
+	Name        string
+	CurrentHost bool // A per-host domain in ByHost, read with defaults -currentHost
+}
+
+// ChangedDomains returns the defaults domains held by the plist files among paths, or directly
+// in the directories among them, that are in the preferences directory of homeDir. Paths outside
+// it are ignored.
+func ChangedDomains(homeDir string, paths []string) []Domain {
+	prefsDir := filepath.Join(homeDir, filepath.FromSlash(PreferencesDir))
+	seen := make(map[Domain]bool)
+	var domains []Domain
+	add := func(path string) {
+		domain, ok := domainOf(prefsDir, path)
+		if ok && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	for _, path := range paths {
+		if path != prefsDir && !strings.HasPrefix(path, prefsDir+string(filepath.Separator)) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			add(path)
+			continue
+		}
+		// A directory of preferences, or the whole preferences directory with its ByHost files
+		for _, pattern := range []string{"*.plist", filepath.Join("ByHost", "*.plist")} {
+			matches, _ := filepath.Glob(filepath.Join(path, pattern))
+			for _, match := range matches {
+				add(match)
+			}
+		}
+	}
+
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].Name != domains[j].Name {
+			return domains[i].Name < domains[j].Name
+		}
+		return !domains[i].CurrentHost
+	})
+	return domains
+}
+
+// domainOf returns the domain held by a plist file in the preferences directory
+func domainOf(prefsDir, path string) (Domain, bool) {
+	name, ok := strings.CutSuffix(filepath.Base(path), ".plist")
+	if !ok || name == "" {
+		return Domain{}, false
+	}
+	switch filepath.Dir(path) {
+	case prefsDir:
+		return Domain{Name: name}, true
+	case filepath.Join(prefsDir, "ByHost"):
+		return Domain{Name: byHostSuffix.ReplaceAllString(name, ""), CurrentHost: true}, true
+	}
+	return Domain{}, false
+}
+
+// FlushDomains makes cfprefsd drop its cached values of domains whose preferences files were
+// replaced or linked behind its back, by reading each with defaults read, so applications see the
+// files on their next launch. Domains that no longer exist are skipped.
+func (m *Manager) FlushDomains(domains []Domain) {
+	for _, domain := range domains {
+		args := []string{"read", domain.Name}
+		if domain.CurrentHost {
+			args = []string{"-currentHost", "read", domain.Name}
+		}
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Refreshing cached preferences of %s\n", domain.Name)
+		}
+		_, _ = m.run("defaults", args...)
+	}
+}
+
+// RestartPreferencesDaemon quits the user's cfprefsd, dropping every cached domain; launchd starts
+// it again on the next preferences access. It is not an error when cfprefsd is not running.
+func (m *Manager) RestartPreferencesDaemon() error {
+	if m.verbose {
+		fmt.Fprintln(m.out, "  Restarting cfprefsd to drop cached preferences")
+	}
+	output, err := m.run("killall", "cfprefsd")
+	if err != nil && !strings.Contains(string(output), "No matching processes") {
+		return fmt.Errorf("failed to restart cfprefsd: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChangedDomains(t *testing.T) {
+	homeDir := t.TempDir()
+	prefsDir := filepath.Join(homeDir, "Library", "Preferences")
+	appDir := filepath.Join(homeDir, "Library", "Application Support", "Code")
+	for _, path := range []string{
+		filepath.Join(prefsDir, "com.googlecode.iterm2.plist"),
+		filepath.Join(prefsDir, "ByHost", "com.apple.screensaver.0A1B2C3D-1111-2222-3333-444455556666.plist"),
+		filepath.Join(prefsDir, "notes.txt"),
+		filepath.Join(appDir, "settings.json"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	domains := ChangedDomains(homeDir, []string{
+		filepath.Join(prefsDir, "com.googlecode.iterm2.plist"),
+		filepath.Join(prefsDir, "com.apple.Terminal.plist"), // Not linked yet, still a domain
+		appDir,
+	})
+	expected := []Domain{{Name: "com.apple.Terminal"}, {Name: "com.googlecode.iterm2"}}
+	if !slices.Equal(domains, expected) {
+		t.Errorf("ChangedDomains() = %v, expected %v", domains, expected)
+	}
+
+	// The whole preferences directory includes its per-host files
+	domains = ChangedDomains(homeDir, []string{prefsDir})
+	expected = []Domain{{Name: "com.apple.screensaver", CurrentHost: true}, {Name: "com.googlecode.iterm2"}}
+	if !slices.Equal(domains, expected) {
+		t.Errorf("ChangedDomains() = %v, expected %v", domains, expected)
+	}
+}
+
+func TestFlushPreferences(t *testing.T) {
+	var calls []string
+	manager := NewManagerWithRunner(func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		if name == "killall" {
+			return []byte("No matching processes belonging to you were found\n"), fmt.Errorf("exit status 1")
+		}
+		return nil, nil
+	}, false)
+
+	manager.FlushDomains([]Domain{{Name: "com.googlecode.iterm2"}, {Name: "com.apple.screensaver", CurrentHost: true}})
+	if err := manager.RestartPreferencesDaemon(); err != nil {
+		t.Errorf("Expected cfprefsd not running to be fine, got %v", err)
+	}
+
+	expected := []string{
+		"defaults read com.googlecode.iterm2",
+		"defaults -currentHost read com.apple.screensaver",
+		"killall cfprefsd",
+	}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected commands %v, got %v", expected, calls)
+	}
+}
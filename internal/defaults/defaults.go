@@ -0,0 +1,95 @@
+// Package defaults provides functionality for capturing and applying macOS user defaults domains.
+package defaults
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CommandRunner executes an external command and returns its combined output
+type CommandRunner func(name string, args ...string) ([]byte, error)
+
+// Manager handles export and import of user defaults domains via the defaults tool
+type Manager struct {
+	out     io.Writer
+	run     CommandRunner
+	verbose bool
+}
+
+// NewManager creates a new defaults manager that shells out to the defaults tool
+func NewManager(verbose bool) *Manager {
+	return NewManagerWithRunner(func(name string, args ...string) ([]byte, error) {
+		return exec.Command(name, args...).CombinedOutput()
+	}, verbose)
+}
+
+// NewManagerWithRunner creates a new defaults manager using a custom command runner
+func NewManagerWithRunner(run CommandRunner, verbose bool) *Manager {
+	return &Manager{
+		out:     os.Stdout,
+		run:     run,
+		verbose: verbose,
+	}
+}
+
+// WithOutput returns a copy of the manager that writes progress messages to w
+func (m *Manager) WithOutput(w io.Writer) *Manager {
+	clone := *m
+	clone.out = w
+	return &clone
+}
+
+// Export writes the contents of a defaults domain to a plist file
+func (m *Manager) Export(domain, path string) error {
+	if domain == "" {
+		return fmt.Errorf("defaults domain is required")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Exporting defaults: %s -> %s\n", domain, path)
+	}
+
+	if output, err := m.run("defaults", "export", domain, path); err != nil {
+		return fmt.Errorf("failed to export defaults domain %s: %w: %s", domain, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Import replaces the contents of a defaults domain with a plist file
+func (m *Manager) Import(domain, path string) error {
+	if domain == "" {
+		return fmt.Errorf("defaults domain is required")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("defaults file not found: %s", path)
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Importing defaults: %s <- %s\n", domain, path)
+	}
+
+	if output, err := m.run("defaults", "import", domain, path); err != nil {
+		return fmt.Errorf("failed to import defaults domain %s: %w: %s", domain, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// DomainExists reports whether the defaults domain has any stored preferences
+func (m *Manager) DomainExists(domain string) bool {
+	if domain == "" {
+		return false
+	}
+	_, err := m.run("defaults", "read", domain)
+	return err == nil
+}
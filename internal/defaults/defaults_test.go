@@ -0,0 +1,118 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+// fakeRunner records executed commands and writes exported files
+type fakeRunner struct {
+	domains map[string]string
+	calls   []string
+}
+
+func (f *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+
+	switch args[0] {
+	case "export":
+		content, ok := f.domains[args[1]]
+		if !ok {
+			return []byte("Domain does not exist"), fmt.Errorf("exit status 1")
+		}
+		return nil, os.WriteFile(args[2], []byte(content), 0644)
+	case "import":
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			return nil, err
+		}
+		f.domains[args[1]] = string(data)
+		return nil, nil
+	case "read":
+		if _, ok := f.domains[args[1]]; !ok {
+			return nil, fmt.Errorf("exit status 1")
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unexpected command: %v", args)
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{domains: map[string]string{constants.TestBundleID: constants.TestConfiguration}}
+}
+
+func TestExport(t *testing.T) {
+	runner := newFakeRunner()
+	manager := NewManagerWithRunner(runner.run, false)
+
+	target := filepath.Join(t.TempDir(), "Defaults", constants.TestBundleID+".plist")
+	if err := manager.Export(constants.TestBundleID, target); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Failed to read exported file: %v", err)
+	}
+	if string(data) != constants.TestConfiguration {
+		t.Errorf("Expected exported content %q, got %q", constants.TestConfiguration, string(data))
+	}
+}
+
+func TestExportMissingDomain(t *testing.T) {
+	manager := NewManagerWithRunner(newFakeRunner().run, false)
+
+	err := manager.Export("com.missing.app", filepath.Join(t.TempDir(), "missing.plist"))
+	if err == nil {
+		t.Fatal("Expected error exporting missing domain")
+	}
+	if !strings.Contains(err.Error(), "Domain does not exist") {
+		t.Errorf("Expected command output in error, got: %v", err)
+	}
+
+	if err := manager.Export("", "unused"); err == nil {
+		t.Error("Expected error for empty domain")
+	}
+}
+
+func TestImport(t *testing.T) {
+	runner := newFakeRunner()
+	manager := NewManagerWithRunner(runner.run, false)
+
+	source := filepath.Join(t.TempDir(), "prefs.plist")
+	if err := os.WriteFile(source, []byte(constants.TestHelloWorld), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	if err := manager.Import("com.new.app", source); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if runner.domains["com.new.app"] != constants.TestHelloWorld {
+		t.Errorf("Expected domain to be imported, got %q", runner.domains["com.new.app"])
+	}
+
+	if err := manager.Import("com.new.app", filepath.Join(t.TempDir(), "missing.plist")); err == nil {
+		t.Error("Expected error importing missing file")
+	}
+}
+
+func TestDomainExists(t *testing.T) {
+	manager := NewManagerWithRunner(newFakeRunner().run, false)
+
+	if !manager.DomainExists(constants.TestBundleID) {
+		t.Error("Expected existing domain to be reported")
+	}
+	if manager.DomainExists("com.missing.app") {
+		t.Error("Expected missing domain to be reported as absent")
+	}
+	if manager.DomainExists("") {
+		t.Error("Expected empty domain to be reported as absent")
+	}
+}
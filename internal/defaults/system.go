@@ -0,0 +1,343 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// SystemSettingsFile is the store-relative path of the captured system settings
+const SystemSettingsFile = "System/settings.yaml"
+
+// Value types of system settings, named after the defaults write flags
+const (
+	TypeBool   = "bool"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeString = "string"
+)
+
+// globalDomain is the domain of preferences shared by all applications
+const globalDomain = "NSGlobalDomain"
+
+// SystemSetting is one curated macOS system preference
+type SystemSetting struct {
+	Group       string `json:"group" yaml:"group"` // Section of the settings file, e.g. dock
+	Key         string `json:"key" yaml:"key"`     // defaults key, also used in the settings file
+	Domain      string `json:"domain" yaml:"domain"`
+	Type        string `json:"type" yaml:"type"`
+	Restart     string `json:"restart,omitempty" yaml:"restart,omitempty"` // Process to restart for the change to take effect
+	Description string `json:"description" yaml:"description"`
+}
+
+// SystemSettings are setting values by group and key, as stored in the settings file
+type SystemSettings map[string]map[string]any
+
+// SettingChange is a difference between a desired system setting and the current one
+type SettingChange struct {
+	Current any // nil when the setting is not set
+	Desired any
+	Setting SystemSetting
+}
+
+// CuratedSettings are the system preferences that can be captured and applied
+var CuratedSettings = []SystemSetting{
+	{"dock", "autohide", "com.apple.dock", TypeBool, "Dock", "Automatically hide and show the Dock"},
+	{"dock", "autohide-delay", "com.apple.dock", TypeFloat, "Dock", "Delay before the hidden Dock appears, in seconds"},
+	{"dock", "tilesize", "com.apple.dock", TypeInt, "Dock", "Icon size in pixels"},
+	{"dock", "magnification", "com.apple.dock", TypeBool, "Dock", "Magnify icons on hover"},
+	{"dock", "largesize", "com.apple.dock", TypeInt, "Dock", "Magnified icon size in pixels"},
+	{"dock", "orientation", "com.apple.dock", TypeString, "Dock", "Position on screen: left, bottom, or right"},
+	{"dock", "mineffect", "com.apple.dock", TypeString, "Dock", "Minimize effect: genie or scale"},
+	{"dock", "minimize-to-application", "com.apple.dock", TypeBool, "Dock", "Minimize windows into their application icon"},
+	{"dock", "show-recents", "com.apple.dock", TypeBool, "Dock", "Show recent applications"},
+	{"finder", "AppleShowAllFiles", "com.apple.finder", TypeBool, "Finder", "Show hidden files"},
+	{"finder", "AppleShowAllExtensions", globalDomain, TypeBool, "Finder", "Show all filename extensions"},
+	{"finder", "ShowPathbar", "com.apple.finder", TypeBool, "Finder", "Show the path bar"},
+	{"finder", "ShowStatusBar", "com.apple.finder", TypeBool, "Finder", "Show the status bar"},
+	{"finder", "_FXShowPosixPathInTitle", "com.apple.finder", TypeBool, "Finder", "Show the full path in the window title"},
+	{"finder", "FXPreferredViewStyle", "com.apple.finder", TypeString, "Finder", "Default view: icnv, Nlsv, clmv, or glyv"},
+	{"finder", "FXDefaultSearchScope", "com.apple.finder", TypeString, "Finder", "Search scope: SCev (this Mac) or SCcf (current folder)"},
+	{"finder", "FXEnableExtensionChangeWarning", "com.apple.finder", TypeBool, "Finder", "Warn before changing a file extension"},
+	{"keyboard", "KeyRepeat", globalDomain, TypeInt, "", "Key repeat rate (lower is faster)"},
+	{"keyboard", "InitialKeyRepeat", globalDomain, TypeInt, "", "Delay until key repeat (lower is shorter)"},
+	{"keyboard", "ApplePressAndHoldEnabled", globalDomain, TypeBool, "", "Show accents when holding a key instead of repeating it"},
+	{"keyboard", "NSAutomaticSpellingCorrectionEnabled", globalDomain, TypeBool, "", "Correct spelling automatically"},
+	{"keyboard", "com.apple.keyboard.fnState", globalDomain, TypeBool, "", "Use F1, F2, etc. keys as standard function keys"},
+	{"trackpad", "Clicking", "com.apple.AppleMultitouchTrackpad", TypeBool, "", "Tap to click"},
+	{"trackpad", "TrackpadThreeFingerDrag", "com.apple.AppleMultitouchTrackpad", TypeBool, "", "Drag with three fingers"},
+	{"trackpad", "com.apple.trackpad.scaling", globalDomain, TypeFloat, "", "Tracking speed"},
+	{"trackpad", "com.apple.swipescrolldirection", globalDomain, TypeBool, "", "Natural scrolling"},
+}
+
+// SystemGroups returns the names of the curated setting groups in order
+func SystemGroups() []string {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, setting := range CuratedSettings {
+		if !seen[setting.Group] {
+			seen[setting.Group] = true
+			groups = append(groups, setting.Group)
+		}
+	}
+	return groups
+}
+
+// CaptureSystemSettings reads the current values of the curated settings in the given groups
+// (all groups when none are given). Settings that were never changed from the macOS default
+// are left out.
+func (m *Manager) CaptureSystemSettings(groups []string) (SystemSettings, error) {
+	selected, err := selectGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(SystemSettings)
+	for _, setting := range CuratedSettings {
+		if !selected[setting.Group] {
+			continue
+		}
+		value, ok := m.readSetting(setting)
+		if !ok {
+			continue
+		}
+		if settings[setting.Group] == nil {
+			settings[setting.Group] = make(map[string]any)
+		}
+		settings[setting.Group][setting.Key] = value
+	}
+
+	return settings, nil
+}
+
+// DiffSystemSettings compares desired settings with the current system and returns the changes
+// needed to apply them
+func (m *Manager) DiffSystemSettings(desired SystemSettings) ([]SettingChange, error) {
+	var changes []SettingChange
+	for _, setting := range CuratedSettings {
+		raw, ok := desired[setting.Group][setting.Key]
+		if !ok {
+			continue
+		}
+		value, err := normalizeValue(setting, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		current, exists := m.readSetting(setting)
+		if exists && current == value {
+			continue
+		}
+		change := SettingChange{Setting: setting, Desired: value}
+		if exists {
+			change.Current = current
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// ApplySystemSettings writes changed settings with defaults write and restarts the processes
+// that only pick up their preferences on launch
+func (m *Manager) ApplySystemSettings(changes []SettingChange) error {
+	restart := make(map[string]bool)
+	for _, change := range changes {
+		setting := change.Setting
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Writing %s %s = %s\n", setting.Domain, setting.Key, FormatValue(change.Desired))
+		}
+		args := []string{"write", setting.Domain, setting.Key, "-" + setting.Type, FormatValue(change.Desired)}
+		if output, err := m.run("defaults", args...); err != nil {
+			return fmt.Errorf("failed to write %s %s: %w: %s", setting.Domain, setting.Key, err, strings.TrimSpace(string(output)))
+		}
+		if setting.Restart != "" {
+			restart[setting.Restart] = true
+		}
+	}
+
+	processes := make([]string, 0, len(restart))
+	for process := range restart {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	for _, process := range processes {
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Restarting %s\n", process)
+		}
+		// The process may not be running, in which case it reads the settings on launch
+		_, _ = m.run("killall", process)
+	}
+
+	return nil
+}
+
+// LoadSystemSettings reads a settings file, checking every setting against the curated list
+func LoadSystemSettings(path string) (SystemSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system settings: %w", err)
+	}
+
+	var settings SystemSettings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse system settings %s: %w", path, err)
+	}
+
+	for group, values := range settings {
+		for key, value := range values {
+			setting, ok := findSetting(group, key)
+			if !ok {
+				return nil, fmt.Errorf("unknown system setting %s.%s in %s (see 'configsync system list')", group, key, path)
+			}
+			if _, err := normalizeValue(setting, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return settings, nil
+}
+
+// SaveSystemSettings writes settings as YAML, merging them into an existing settings file so
+// groups that were not captured are kept
+func SaveSystemSettings(path string, settings SystemSettings) error {
+	merged := make(SystemSettings)
+	if existing, err := LoadSystemSettings(path); err == nil {
+		merged = existing
+	}
+	for group, values := range settings {
+		merged[group] = values
+	}
+
+	var b strings.Builder
+	b.WriteString("# macOS system settings captured by configsync\n")
+	b.WriteString("# Edit values and run 'configsync system apply'; see 'configsync system list' for the available settings\n")
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to encode system settings: %w", err)
+	}
+	b.Write(data)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write system settings: %w", err)
+	}
+	return nil
+}
+
+// FormatValue renders a setting value the way defaults write expects it
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "(not set)"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Helper functions
+
+// readSetting reads the current value of a setting, reporting false when it is not set
+func (m *Manager) readSetting(setting SystemSetting) (any, bool) {
+	output, err := m.run("defaults", "read", setting.Domain, setting.Key)
+	if err != nil {
+		return nil, false
+	}
+	value, err := parseValue(setting.Type, strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// parseValue converts defaults read output to a value of the setting's type
+func parseValue(valueType, text string) (any, error) {
+	switch valueType {
+	case TypeBool:
+		switch strings.ToLower(text) {
+		case "1", "true", "yes":
+			return true, nil
+		case "0", "false", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean %q", text)
+	case TypeInt:
+		return strconv.Atoi(text)
+	case TypeFloat:
+		return strconv.ParseFloat(text, 64)
+	default:
+		return text, nil
+	}
+}
+
+// normalizeValue checks a value loaded from YAML against a setting's type and converts it
+// to the type readSetting returns, so values can be compared
+func normalizeValue(setting SystemSetting, value any) (any, error) {
+	switch setting.Type {
+	case TypeBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case TypeInt:
+		if v, ok := value.(int); ok {
+			return v, nil
+		}
+	case TypeFloat:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		}
+	case TypeString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("system setting %s.%s must be a %s, got %v", setting.Group, setting.Key, setting.Type, value)
+}
+
+// findSetting looks up a curated setting by group and key
+func findSetting(group, key string) (SystemSetting, bool) {
+	for _, setting := range CuratedSettings {
+		if setting.Group == group && setting.Key == key {
+			return setting, true
+		}
+	}
+	return SystemSetting{}, false
+}
+
+// selectGroups returns the set of groups to work on, checking that each one exists
+func selectGroups(groups []string) (map[string]bool, error) {
+	known := SystemGroups()
+	selected := make(map[string]bool)
+	if len(groups) == 0 {
+		for _, group := range known {
+			selected[group] = true
+		}
+		return selected, nil
+	}
+
+	for _, group := range groups {
+		found := false
+		for _, name := range known {
+			if name == group {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown system settings group %q (available: %s)", group, strings.Join(known, ", "))
+		}
+		selected[group] = true
+	}
+	return selected, nil
+}
@@ -0,0 +1,142 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSystem holds defaults values by "domain key" and records the commands it is given
+type fakeSystem struct {
+	values map[string]string
+	calls  []string
+}
+
+func (f *fakeSystem) run(name string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	switch {
+	case name == "killall":
+		return nil, nil
+	case args[0] == "read":
+		value, ok := f.values[args[1]+" "+args[2]]
+		if !ok {
+			return []byte("does not exist"), fmt.Errorf("exit status 1")
+		}
+		return []byte(value + "\n"), nil
+	case args[0] == "write":
+		f.values[args[1]+" "+args[2]] = args[4]
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected command: %v", args)
+}
+
+func newFakeSystem() *fakeSystem {
+	return &fakeSystem{values: map[string]string{
+		"com.apple.dock autohide":               "1",
+		"com.apple.dock tilesize":               "48",
+		"com.apple.dock autohide-delay":         "0.2",
+		"com.apple.finder FXPreferredViewStyle": "Nlsv",
+		"NSGlobalDomain KeyRepeat":              "2",
+	}}
+}
+
+func TestCaptureSystemSettings(t *testing.T) {
+	manager := NewManagerWithRunner(newFakeSystem().run, false)
+
+	settings, err := manager.CaptureSystemSettings(nil)
+	if err != nil {
+		t.Fatalf("CaptureSystemSettings failed: %v", err)
+	}
+	if settings["dock"]["autohide"] != true || settings["dock"]["tilesize"] != 48 || settings["dock"]["autohide-delay"] != 0.2 {
+		t.Errorf("Unexpected Dock settings: %+v", settings["dock"])
+	}
+	if settings["finder"]["FXPreferredViewStyle"] != "Nlsv" || settings["keyboard"]["KeyRepeat"] != 2 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+	if _, ok := settings["trackpad"]; ok {
+		t.Error("Expected unset trackpad settings to be left out")
+	}
+
+	settings, err = manager.CaptureSystemSettings([]string{"keyboard"})
+	if err != nil || len(settings) != 1 {
+		t.Errorf("Expected only keyboard settings, got %+v (%v)", settings, err)
+	}
+	if _, err := manager.CaptureSystemSettings([]string{"sound"}); err == nil {
+		t.Error("Expected error for unknown group")
+	}
+}
+
+func TestSystemSettingsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SystemSettingsFile)
+	if err := SaveSystemSettings(path, SystemSettings{"dock": {"autohide": true, "tilesize": 48}}); err != nil {
+		t.Fatalf("SaveSystemSettings failed: %v", err)
+	}
+	// Capturing another group keeps the existing ones
+	if err := SaveSystemSettings(path, SystemSettings{"keyboard": {"KeyRepeat": 2}}); err != nil {
+		t.Fatalf("SaveSystemSettings failed: %v", err)
+	}
+
+	settings, err := LoadSystemSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSystemSettings failed: %v", err)
+	}
+	if settings["dock"]["tilesize"] != 48 || settings["keyboard"]["KeyRepeat"] != 2 {
+		t.Errorf("Unexpected settings after round trip: %+v", settings)
+	}
+
+	for name, content := range map[string]string{
+		"unknown": "dock:\n  bounce: true\n",
+		"type":    "dock:\n  tilesize: large\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write settings: %v", err)
+		}
+		if _, err := LoadSystemSettings(path); err == nil {
+			t.Errorf("%s: expected invalid settings to be rejected", name)
+		}
+	}
+}
+
+func TestDiffAndApplySystemSettings(t *testing.T) {
+	system := newFakeSystem()
+	manager := NewManagerWithRunner(system.run, false)
+
+	desired := SystemSettings{
+		"dock":     {"autohide": true, "tilesize": 64, "autohide-delay": 0},
+		"trackpad": {"Clicking": true},
+	}
+	changes, err := manager.DiffSystemSettings(desired)
+	if err != nil {
+		t.Fatalf("DiffSystemSettings failed: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %+v", changes)
+	}
+	if changes[1].Setting.Key != "tilesize" || changes[1].Current != 48 || changes[1].Desired != 64 {
+		t.Errorf("Unexpected tilesize change: %+v", changes[1])
+	}
+	if changes[2].Setting.Key != "Clicking" || changes[2].Current != nil {
+		t.Errorf("Expected unset trackpad setting to be added, got %+v", changes[2])
+	}
+
+	system.calls = nil
+	if err := manager.ApplySystemSettings(changes); err != nil {
+		t.Fatalf("ApplySystemSettings failed: %v", err)
+	}
+	expected := []string{
+		"defaults write com.apple.dock autohide-delay -float 0",
+		"defaults write com.apple.dock tilesize -int 64",
+		"defaults write com.apple.AppleMultitouchTrackpad Clicking -bool true",
+		"killall Dock",
+	}
+	if strings.Join(system.calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(system.calls, "\n"))
+	}
+
+	changes, err = manager.DiffSystemSettings(desired)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes after applying, got %+v (%v)", changes, err)
+	}
+}
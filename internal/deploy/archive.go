@@ -0,0 +1,356 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/tarball"
+)
+
+// Bundle formats
+const (
+	FormatTarGz = "tar.gz" // Tar archive, gzip-compressed by default (the default)
+	FormatZip   = "zip"    // Zip archive, which every platform can open without extra tools
+	FormatDir   = "dir"    // Plain directory, e.g. inside a git-managed folder
+)
+
+// BundleMetadataFile is the file at the root of a bundle holding its metadata
+const BundleMetadataFile = "bundle.yaml"
+
+var zipMagic = []byte("PK")
+
+// SetFormat sets the format of exported bundles. An empty format is chosen from the
+// extension of the bundle path.
+func (m *Manager) SetFormat(format string) {
+	m.format = format
+}
+
+// CheckFormat returns an error if a bundle format is not supported
+func CheckFormat(format string) error {
+	switch format {
+	case FormatTarGz, FormatZip, FormatDir:
+		return nil
+	default:
+		return fmt.Errorf("unsupported bundle format: %s (use %s, %s, or %s)", format, FormatTarGz, FormatZip, FormatDir)
+	}
+}
+
+// FormatFromPath returns the bundle format suggested by a path's extension
+func FormatFromPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return FormatZip
+	}
+	return FormatTarGz
+}
+
+// DefaultBundlePath returns the default bundle file name for a format and compression
+func DefaultBundlePath(format, compression string) string {
+	switch {
+	case format == FormatZip:
+		return "configsync-bundle.zip"
+	case format == FormatDir:
+		return "configsync-bundle"
+	case compression == CompressionNone:
+		return "configsync-bundle.tar"
+	case compression == CompressionZstd:
+		return "configsync-bundle.tar.zst"
+	default:
+		return "configsync-bundle.tar.gz"
+	}
+}
+
+// DetectFormat determines the format of an existing bundle from its magic bytes,
+// falling back to its extension
+func DetectFormat(bundlePath string) (string, error) {
+	return detectFormat(fsys.OS, bundlePath)
+}
+
+// detectFormat implements DetectFormat on a file system
+func detectFormat(files fsys.FS, bundlePath string) (string, error) {
+	info, err := files.Stat(bundlePath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return FormatDir, nil
+	}
+
+	file, err := files.Open(bundlePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(file, header)
+	switch {
+	case tarball.DetectCompression(header[:n]) != tarball.None:
+		return FormatTarGz, nil
+	case bytes.HasPrefix(header[:n], zipMagic):
+		return FormatZip, nil
+	default:
+		return FormatFromPath(bundlePath), nil
+	}
+}
+
+// HashBundle returns the hash identifying a bundle. Archives are hashed whole; a directory
+// bundle is identified by its metadata, which records the hash of every file it contains.
+func HashBundle(bundlePath string) (string, error) {
+	return hashBundle(fsys.OS, bundlePath)
+}
+
+// hashBundle implements HashBundle on a file system
+func hashBundle(files fsys.FS, bundlePath string) (string, error) {
+	format, err := detectFormat(files, bundlePath)
+	if err != nil {
+		return "", err
+	}
+	if format == FormatDir {
+		return manifest.HashFileFS(files, filepath.Join(bundlePath, BundleMetadataFile))
+	}
+	return manifest.HashFileFS(files, bundlePath)
+}
+
+// extractBundle unpacks a bundle of any format into a directory
+func (m *Manager) extractBundle(bundlePath, targetDir string) error {
+	format, err := detectFormat(m.fs, bundlePath)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatZip:
+		return m.extractZip(bundlePath, targetDir)
+	case FormatDir:
+		return m.copyBundleDir(bundlePath, targetDir)
+	default:
+		return m.extractTarGz(bundlePath, targetDir)
+	}
+}
+
+// readBundleMetadataFile reads the metadata of a bundle of any format without extracting it
+func readBundleMetadataFile(files fsys.FS, bundlePath string) ([]byte, error) {
+	format, err := detectFormat(files, bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatDir:
+		return files.ReadFile(filepath.Join(bundlePath, BundleMetadataFile))
+	case FormatZip:
+		reader, closer, err := openZip(files, bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = closer.Close() }()
+
+		for _, file := range reader.File {
+			if filepath.Clean(file.Name) != BundleMetadataFile {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer func() { _ = rc.Close() }()
+			return io.ReadAll(rc)
+		}
+	default:
+		file, err := files.Open(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+
+		tarReader, release, err := tarball.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if filepath.Clean(header.Name) == BundleMetadataFile {
+				return io.ReadAll(tarReader)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("bundle metadata not found in %s", bundlePath)
+}
+
+// openZip opens a zip archive on a file system, reading its entries as they are opened. The
+// returned closer closes the archive file.
+func openZip(files fsys.FS, path string) (*zip.Reader, io.Closer, error) {
+	file, err := files.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err == nil {
+		var reader *zip.Reader
+		if reader, err = zip.NewReader(file, info.Size()); err == nil {
+			return reader, file, nil
+		}
+	}
+	_ = file.Close()
+	return nil, nil, err
+}
+
+// extractZip unpacks a zip bundle, reading its entries from the file rather than loading it
+// into memory. Symlinks are created after every other entry, so no entry can be written
+// through one.
+func (m *Manager) extractZip(sourcePath, targetDir string) error {
+	reader, closer, err := openZip(m.fs, sourcePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = closer.Close() }()
+
+	symlinks := make(map[string]string)
+	for _, entry := range reader.File {
+		path, err := tarball.EntryPath(targetDir, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := entry.Mode()
+		switch {
+		case mode&os.ModeSymlink != 0:
+			target, err := readZipEntry(entry)
+			if err != nil {
+				return err
+			}
+			symlinks[path] = string(target)
+		case mode.IsDir():
+			if err := m.fs.MkdirAll(path, mode.Perm()|0700); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := m.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			src, err := entry.Open()
+			if err != nil {
+				return err
+			}
+
+			file, err := m.fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+			if err != nil {
+				_ = src.Close()
+				return err
+			}
+
+			_, err = fsutil.CopyContext(m.ctx, file, src)
+			_ = file.Close()
+			_ = src.Close()
+			if err != nil {
+				_ = m.fs.Remove(path)
+				return err
+			}
+			// Keep the exported modification time, which deploy reports for conflicting files
+			if err := m.fs.Chtimes(path, entry.Modified, entry.Modified); err != nil {
+				return err
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(symlinks))
+	for path := range symlinks {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if tarball.BelowSymlink(m.fs, targetDir, path) {
+			return fmt.Errorf("invalid path in archive: %s", path)
+		}
+		if err := m.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := m.fs.Symlink(symlinks[path], path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readZipEntry reads the content of a zip entry
+func readZipEntry(entry *zip.File) ([]byte, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	return io.ReadAll(rc)
+}
+
+// writeBundleDir replaces the bundle in a directory with the prepared contents. Hidden entries
+// such as .git are left alone so the directory can be kept under version control.
+func (m *Manager) writeBundleDir(sourceDir, targetDir string) error {
+	entries, err := m.fs.ReadDir(targetDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var existing []string
+	isBundle := false
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.Name() == BundleMetadataFile {
+			isBundle = true
+		}
+		existing = append(existing, entry.Name())
+	}
+	if len(existing) > 0 && !isBundle {
+		return fmt.Errorf("%s is not empty and does not contain a bundle; refusing to overwrite it", targetDir)
+	}
+
+	for _, name := range existing {
+		if err := m.fs.RemoveAll(filepath.Join(targetDir, name)); err != nil {
+			return fmt.Errorf("failed to remove previous bundle contents: %w", err)
+		}
+	}
+
+	if err := m.fs.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return m.copyDir(sourceDir, targetDir)
+}
+
+// copyBundleDir copies a directory bundle, leaving out hidden top-level entries such as .git
+func (m *Manager) copyBundleDir(sourceDir, targetDir string) error {
+	entries, err := m.fs.ReadDir(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := m.copyPath(filepath.Join(sourceDir, entry.Name()), filepath.Join(targetDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
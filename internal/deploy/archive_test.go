@@ -0,0 +1,353 @@
+package deploy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// setupExportManager creates a configuration with one app and a deploy manager for it
+func setupExportManager(t *testing.T) (*Manager, *config.Manager, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(filepath.Join(storeDir, "app"), 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "app", "settings.json"), []byte("{}"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("/test/app", "app", config.PathTypeDirectory, false)
+	if err := configManager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	return NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false), configManager, tempDir
+}
+
+func TestExportImportFormats(t *testing.T) {
+	tests := []struct {
+		format      string
+		compression string
+		output      string
+		want        string
+	}{
+		{format: "", output: "bundle.tar.gz", want: FormatTarGz},
+		{format: "", output: "bundle.tar", want: FormatTarGz},
+		{format: "", output: "bundle.tar.zst", want: FormatTarGz},
+		{format: "", compression: CompressionZstd, output: "bundle.tar.gz", want: FormatTarGz},
+		{format: "", output: "bundle.zip", want: FormatZip},
+		{format: FormatZip, output: "bundle.pkg", want: FormatZip},
+		{format: FormatZip, compression: CompressionNone, output: "stored.zip", want: FormatZip},
+		{format: FormatDir, output: "bundle", want: FormatDir},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want+"/"+tt.compression+"/"+tt.output, func(t *testing.T) {
+			manager, configManager, tempDir := setupExportManager(t)
+			manager.SetFormat(tt.format)
+			manager.SetCompression(tt.compression, 0)
+			if err := os.Symlink("settings.json", filepath.Join(tempDir, "store", "app", "current.json")); err != nil {
+				t.Fatalf("Failed to create symlink: %v", err)
+			}
+
+			bundlePath := filepath.Join(tempDir, tt.output)
+			if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+				t.Fatalf("ExportBundle failed: %v", err)
+			}
+
+			format, err := DetectFormat(bundlePath)
+			if err != nil {
+				t.Fatalf("DetectFormat failed: %v", err)
+			}
+			if format != tt.want {
+				t.Errorf("Expected format %s, got %s", tt.want, format)
+			}
+
+			bundle, hash, err := ReadBundleArchive(bundlePath)
+			if err != nil {
+				t.Fatalf("ReadBundleArchive failed: %v", err)
+			}
+			if _, exists := bundle.Apps["testapp"]; !exists || hash == "" {
+				t.Errorf("Expected bundle metadata and hash, got %+v and %q", bundle.Apps, hash)
+			}
+
+			importDir := filepath.Join(tempDir, "import")
+			if _, err := manager.ImportBundle(bundlePath, importDir); err != nil {
+				t.Fatalf("ImportBundle failed: %v", err)
+			}
+			info, err := os.Stat(filepath.Join(importDir, "files", "testapp", "app", "settings.json"))
+			if err != nil {
+				t.Fatalf("Expected the app file to be imported: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected the file mode to be kept, got %v", info.Mode().Perm())
+			}
+			if target, err := os.Readlink(filepath.Join(importDir, "files", "testapp", "app", "current.json")); err != nil || target != "settings.json" {
+				t.Errorf("Expected the symlink to be imported, got %q, %v", target, err)
+			}
+		})
+	}
+}
+
+func TestExportDirectoryBundleKeepsHiddenEntries(t *testing.T) {
+	manager, configManager, tempDir := setupExportManager(t)
+	manager.SetFormat(FormatDir)
+
+	bundleDir := filepath.Join(tempDir, "bundle")
+	if err := manager.ExportBundle(bundleDir, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	// A git checkout of the bundle, with a file left over from an older export
+	if err := os.MkdirAll(filepath.Join(bundleDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	stale := filepath.Join(bundleDir, "files", "testapp", "stale.conf")
+	if err := os.WriteFile(stale, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write stale file: %v", err)
+	}
+
+	if err := manager.ExportBundle(bundleDir, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, ".git")); err != nil {
+		t.Errorf("Expected .git to be kept: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Expected files from the previous bundle to be removed")
+	}
+
+	// The imported copy leaves .git out, so the integrity check passes
+	if _, err := manager.ImportBundle(bundleDir, filepath.Join(tempDir, "import")); err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "import", ".git")); !os.IsNotExist(err) {
+		t.Error("Expected .git not to be imported")
+	}
+}
+
+func TestExportDirectoryBundleRefusesUnrelatedDirectory(t *testing.T) {
+	manager, configManager, tempDir := setupExportManager(t)
+	manager.SetFormat(FormatDir)
+
+	targetDir := filepath.Join(tempDir, "documents")
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "notes.txt"), []byte("keep me"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := manager.ExportBundle(targetDir, nil, configManager); err == nil {
+		t.Fatal("Expected an error when exporting into an unrelated directory")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "notes.txt")); err != nil {
+		t.Errorf("Expected existing files to be left alone: %v", err)
+	}
+}
+
+func TestExportStats(t *testing.T) {
+	manager, configManager, tempDir := setupExportManager(t)
+	content := strings.Repeat("compressible settings\n", 4096)
+	if err := os.WriteFile(filepath.Join(tempDir, "store", "app", "large.conf"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	manager.SetCompression(CompressionZstd, 19)
+
+	bundlePath := filepath.Join(tempDir, "bundle.tar.zst")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	stats := manager.ExportStats()
+	if stats.Files != 2 || stats.Size != int64(len(content))+2 {
+		t.Errorf("Expected 2 files of %d bytes, got %d files of %d bytes", len(content)+2, stats.Files, stats.Size)
+	}
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if stats.ArchiveSize != info.Size() || stats.Compression != CompressionZstd {
+		t.Errorf("Expected a %d byte zstd archive, got %+v", info.Size(), stats)
+	}
+	if stats.Ratio() >= 0.5 {
+		t.Errorf("Expected repetitive content to compress well, got ratio %.2f", stats.Ratio())
+	}
+}
+
+func TestCheckCompression(t *testing.T) {
+	tests := []struct {
+		format      string
+		compression string
+		level       int
+		valid       bool
+	}{
+		{FormatTarGz, "", 0, true},
+		{FormatTarGz, CompressionGzip, 9, true},
+		{FormatTarGz, CompressionGzip, 10, false},
+		{FormatTarGz, CompressionZstd, 22, true},
+		{FormatTarGz, CompressionZstd, 23, false},
+		{FormatTarGz, CompressionNone, 0, true},
+		{FormatTarGz, CompressionNone, 3, false},
+		{FormatTarGz, "xz", 0, false},
+		{FormatZip, CompressionGzip, 1, true},
+		{FormatZip, CompressionZstd, 0, false},
+		{FormatDir, "", 0, true},
+		{FormatDir, CompressionGzip, 0, false},
+	}
+	for _, tt := range tests {
+		err := CheckCompression(tt.format, tt.compression, tt.level)
+		if (err == nil) != tt.valid {
+			t.Errorf("CheckCompression(%q, %q, %d) = %v, want valid %v", tt.format, tt.compression, tt.level, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range []string{FormatTarGz, FormatZip, FormatDir} {
+		if err := CheckFormat(format); err != nil {
+			t.Errorf("Expected %s to be supported: %v", format, err)
+		}
+	}
+	if err := CheckFormat("rar"); err == nil {
+		t.Error("Expected rar to be rejected")
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	longDir := filepath.Join(sourceDir, "Application Support", strings.Repeat("nested-directory-", 8))
+	if err := os.MkdirAll(longDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	settings := filepath.Join(longDir, "settings.json")
+	if err := os.WriteFile(settings, []byte("{}"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Link(settings, filepath.Join(sourceDir, "settings-link.json")); err != nil {
+		t.Fatalf("Failed to create hard link: %v", err)
+	}
+	if err := os.Symlink("Application Support", filepath.Join(sourceDir, "Support")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC)
+	for _, path := range []string{settings, longDir} {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+
+	for _, compression := range []string{CompressionGzip, CompressionZstd, CompressionNone} {
+		t.Run(compression, func(t *testing.T) {
+			manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
+			archivePath := filepath.Join(tempDir, "bundle-"+compression)
+			writeTestArchive(t, manager, sourceDir, archivePath, compression)
+
+			targetDir := filepath.Join(tempDir, "target-"+compression)
+			if err := manager.extractTarGz(archivePath, targetDir); err != nil {
+				t.Fatalf("extractTarGz failed: %v", err)
+			}
+			checkTarRoundTrip(t, sourceDir, settings, filepath.Join(targetDir, "source"), mtime)
+		})
+	}
+}
+
+// writeTestArchive writes a directory to a tar archive as the source entry
+func writeTestArchive(t *testing.T, manager *Manager, sourceDir, archivePath, compression string) {
+	t.Helper()
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	archive, err := newArchiveWriter(context.Background(), file, FormatTarGz, compression, 0)
+	if err != nil {
+		t.Fatalf("newArchiveWriter failed: %v", err)
+	}
+	if _, err := manager.addTree(archive, sourceDir, "source", nil); err != nil {
+		t.Fatalf("addTree failed: %v", err)
+	}
+	if err := archive.close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+}
+
+// checkTarRoundTrip checks the extracted copy of the directory written by TestTarRoundTrip
+func checkTarRoundTrip(t *testing.T, sourceDir, settings, targetDir string, mtime time.Time) {
+	t.Helper()
+
+	rel, _ := filepath.Rel(sourceDir, settings)
+	extracted := filepath.Join(targetDir, rel)
+	info, err := os.Stat(extracted)
+	if err != nil {
+		t.Fatalf("Expected the long path to be extracted: %v", err)
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mode 0600 and modification time %v, got %v and %v", mtime, info.Mode().Perm(), info.ModTime())
+	}
+	if info, err := os.Stat(filepath.Dir(extracted)); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected the directory modification time to be kept, got %v, %v", info, err)
+	}
+
+	if target, err := os.Readlink(filepath.Join(targetDir, "Support")); err != nil || target != "Application Support" {
+		t.Errorf("Expected the symlink to be kept, got %q, %v", target, err)
+	}
+	linkInfo, err := os.Stat(filepath.Join(targetDir, "settings-link.json"))
+	if err != nil {
+		t.Fatalf("Expected the hard link to be extracted: %v", err)
+	}
+	if !os.SameFile(info, linkInfo) {
+		t.Error("Expected the hard link to share the file it links to")
+	}
+}
+
+func TestExtractTarGzRejectsWritesThroughSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	archive := filepath.Join(tempDir, "evil.tar.gz")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	gzWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzWriter)
+	for _, header := range []*tar.Header{
+		{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "escape/planted", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0777},
+	} {
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+	}
+	_ = tarWriter.Close()
+	_ = gzWriter.Close()
+	_ = file.Close()
+
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
+	if err := manager.extractTarGz(archive, filepath.Join(tempDir, "target")); err == nil {
+		t.Error("Expected an entry below a symlink to be rejected")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "planted")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be created outside the target directory, got %v", err)
+	}
+}
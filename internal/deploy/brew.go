@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/brew"
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// SetBrewfile makes exports record the Homebrew casks and formulae that install the bundled apps
+func (m *Manager) SetBrewfile(enabled bool) {
+	m.withBrewfile = enabled
+}
+
+// SetInstallMissing makes deploy install the bundled apps' Homebrew packages that are missing
+// on this system before deploying their configurations
+func (m *Manager) SetInstallMissing(enabled bool) {
+	m.installMissing = enabled
+}
+
+// Helper methods
+
+// addBrewPackages records the installed packages that provide the bundled apps
+func (m *Manager) addBrewPackages(bundle *config.DeploymentBundle) error {
+	if !m.withBrewfile {
+		return nil
+	}
+
+	inventory, err := m.brew.Installed()
+	if err != nil {
+		return err
+	}
+	bundle.Packages = inventory.Packages(bundle.Apps)
+
+	if m.verbose {
+		fmt.Printf("Found Homebrew packages for %d of %d application(s)\n", len(bundle.Packages), len(bundle.Apps))
+	}
+	return nil
+}
+
+// writeBrewfile writes the bundle's packages as a Brewfile next to its metadata
+func (m *Manager) writeBrewfile(bundle *config.DeploymentBundle, bundleDir string) error {
+	if !m.withBrewfile {
+		return nil
+	}
+	return brew.WriteBrewfile(filepath.Join(bundleDir, brew.BrewfileName), bundle.Packages)
+}
+
+// installMissingPackages installs the packages of the bundle's apps that are not installed yet.
+// A package that fails to install is reported without stopping the deployment of configurations.
+func (m *Manager) installMissingPackages(bundle *config.DeploymentBundle) error {
+	if !m.installMissing {
+		return nil
+	}
+
+	var packages []config.BrewPackage
+	for _, pkg := range bundle.Packages {
+		if _, selected := bundle.Apps[pkg.App]; selected {
+			packages = append(packages, pkg)
+		}
+	}
+	if len(packages) == 0 {
+		fmt.Println("No Homebrew packages recorded in the bundle (export with --with-brewfile)")
+		return nil
+	}
+
+	inventory, err := m.brew.Installed()
+	if err != nil {
+		return fmt.Errorf("failed to install missing applications: %w", err)
+	}
+
+	for _, pkg := range packages {
+		if inventory.Has(pkg) {
+			if m.verbose {
+				fmt.Printf("  Already installed: %s %s\n", pkg.Type, pkg.Name)
+			}
+			continue
+		}
+
+		if m.dryRun {
+			fmt.Printf("[DRY RUN] Would install %s %s for %s\n", pkg.Type, pkg.Name, pkg.App)
+			continue
+		}
+
+		m.progress.Step("deploy", fmt.Sprintf("Installing %s %s", pkg.Type, pkg.Name))
+		fmt.Printf("Installing %s %s for %s...\n", pkg.Type, pkg.Name, pkg.App)
+		if err := m.brew.Install(pkg); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			continue
+		}
+		fmt.Printf("✓ Installed %s\n", pkg.Name)
+	}
+
+	return nil
+}
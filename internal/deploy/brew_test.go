@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/brew"
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// fakeBrew puts a brew command on PATH that reports neovim as installed and logs installs
+func fakeBrew(t *testing.T) string {
+	t.Helper()
+	binDir := t.TempDir()
+	logFile := filepath.Join(binDir, "installs.log")
+	script := `#!/bin/sh
+if [ "$1" = "info" ]; then
+  echo '{"formulae": [{"name": "neovim", "full_name": "neovim", "aliases": ["nvim"]}], "casks": []}'
+  exit 0
+fi
+echo "$@" >> "` + logFile + `"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "brew"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake brew: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logFile
+}
+
+func TestExportBundleWithBrewfile(t *testing.T) {
+	fakeBrew(t)
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for _, name := range []string{"neovim.lua", "testapp.lua"} {
+		if err := os.WriteFile(filepath.Join(storeDir, name), []byte("vim.opt.number = true"), 0644); err != nil {
+			t.Fatalf("Failed to write store file: %v", err)
+		}
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	for _, app := range []*config.AppConfig{config.NewAppConfig("neovim", "Neovim"), config.NewAppConfig("testapp", "Test App")} {
+		app.AddPath("/test/"+app.Name+".lua", app.Name+".lua", config.PathTypeFile, false)
+		if err := configManager.AddApp(app); err != nil {
+			t.Fatalf("Failed to add app: %v", err)
+		}
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false)
+	manager.SetBrewfile(true)
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	importDir := filepath.Join(tempDir, "import")
+	bundle, err := manager.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if len(bundle.Packages) != 1 || bundle.Packages[0] != (config.BrewPackage{App: "neovim", Name: "neovim", Type: config.BrewFormula}) {
+		t.Errorf("Expected neovim formula to be recorded, got %+v", bundle.Packages)
+	}
+	data, err := os.ReadFile(filepath.Join(importDir, brew.BrewfileName))
+	if err != nil || !strings.Contains(string(data), `brew "neovim" # neovim`) {
+		t.Errorf("Expected Brewfile in bundle, got %q (%v)", data, err)
+	}
+}
+
+func TestInstallMissingPackages(t *testing.T) {
+	logFile := fakeBrew(t)
+	manager, _, bundle, _, _ := setupImportedBundle(t)
+	bundle.Apps["neovim"] = config.NewAppConfig("neovim", "Neovim")
+	bundle.Apps["iterm2"] = config.NewAppConfig("iterm2", "iTerm2")
+	bundle.Packages = []config.BrewPackage{
+		{App: "iterm2", Name: "iterm2", Type: config.BrewCask},
+		{App: "neovim", Name: "neovim", Type: config.BrewFormula},
+		{App: "slack", Name: "slack", Type: config.BrewCask}, // Not selected for deployment
+	}
+
+	// Nothing is installed unless asked for
+	if err := manager.installMissingPackages(bundle); err != nil {
+		t.Fatalf("installMissingPackages failed: %v", err)
+	}
+	if _, err := os.Stat(logFile); !os.IsNotExist(err) {
+		t.Fatal("Expected no installs without --install-missing")
+	}
+
+	manager.SetInstallMissing(true)
+	manager.SetDryRun(true)
+	if err := manager.installMissingPackages(bundle); err != nil {
+		t.Fatalf("installMissingPackages failed: %v", err)
+	}
+	if _, err := os.Stat(logFile); !os.IsNotExist(err) {
+		t.Fatal("Expected no installs in dry-run mode")
+	}
+
+	manager.SetDryRun(false)
+	if err := manager.installMissingPackages(bundle); err != nil {
+		t.Fatalf("installMissingPackages failed: %v", err)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Expected brew to be run: %v", err)
+	}
+	if string(data) != "install --cask iterm2\n" {
+		t.Errorf("Expected only the missing selected cask to be installed, got %q", data)
+	}
+}
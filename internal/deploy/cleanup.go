@@ -0,0 +1,190 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// ImportRecordFile is the file in an import directory that records where the bundle came from
+const ImportRecordFile = ".import.yaml"
+
+// TempBundlePattern names the scratch directories bundles are built and checked in
+const TempBundlePattern = "configsync-bundle-*"
+
+// DefaultStaleAge is how long a scratch directory must have been left alone before it is
+// considered left behind by a failed or interrupted export, rather than in use
+const DefaultStaleAge = time.Hour
+
+// Kinds of artifacts
+const (
+	ArtifactImport = "import" // An imported bundle waiting in the import directory
+	ArtifactTemp   = "temp"   // A scratch directory left behind by an export or dry-run import
+)
+
+// ImportRecord describes the bundle in the import directory
+type ImportRecord struct {
+	ImportedAt time.Time `yaml:"imported_at"`
+	Source     string    `yaml:"source"`
+	Hash       string    `yaml:"hash,omitempty"` // Hash of the imported archive
+	Apps       []string  `yaml:"apps"`
+}
+
+// Artifact is an imported bundle or scratch directory that takes up space and can be deleted
+type Artifact struct {
+	ModTime time.Time `json:"mod_time" yaml:"mod_time"`
+	Kind    string    `json:"kind" yaml:"kind"`
+	Path    string    `json:"path" yaml:"path"`
+	Note    string    `json:"note,omitempty" yaml:"note,omitempty"`
+	Size    int64     `json:"size" yaml:"size"`
+}
+
+// RecordImport notes in the import directory where its bundle was imported from, and the hash
+// identifying it, which deploy records in the applications it deploys
+func (m *Manager) RecordImport(importDir, bundlePath, hash string, bundle *config.DeploymentBundle) error {
+	record := &ImportRecord{ImportedAt: time.Now(), Source: bundlePath, Hash: hash, Apps: []string{}}
+	if absolute, err := filepath.Abs(bundlePath); err == nil && !IsRemoteBundle(bundlePath) {
+		record.Source = absolute
+	}
+	for appName := range bundle.Apps {
+		record.Apps = append(record.Apps, appName)
+	}
+	sort.Strings(record.Apps)
+
+	data, err := yaml.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal import record: %w", err)
+	}
+	if err := m.fs.WriteFile(filepath.Join(importDir, ImportRecordFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save import record: %w", err)
+	}
+	return nil
+}
+
+// LoadImportRecord reads the record of the bundle in the import directory, or returns nil if the
+// bundle was imported before imports were recorded
+func LoadImportRecord(importDir string) (*ImportRecord, error) {
+	return loadImportRecord(fsys.OS, importDir)
+}
+
+// loadImportRecord implements LoadImportRecord on a file system
+func loadImportRecord(files fsys.FS, importDir string) (*ImportRecord, error) {
+	data, err := files.ReadFile(filepath.Join(importDir, ImportRecordFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import record: %w", err)
+	}
+
+	var record ImportRecord
+	if err := yaml.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse import record: %w", err)
+	}
+	return &record, nil
+}
+
+// RemoveDeployedImport deletes the import directory once every application of its bundle has
+// been deployed, and reports whether it did. Bundles only partly deployed, for example with
+// --apps, are kept so the rest can still be deployed.
+func (m *Manager) RemoveDeployedImport(importDir string) (bool, error) {
+	bundle, err := m.loadBundleMetadata(filepath.Join(importDir, "bundle.yaml"))
+	if err != nil {
+		return false, fmt.Errorf("failed to load imported bundle: %w", err)
+	}
+	state, err := loadDeployState(m.fs, importDir)
+	if err != nil {
+		return false, err
+	}
+	if state.DeployedCount(bundle) < len(bundle.Apps) {
+		return false, nil
+	}
+
+	if err := m.fs.RemoveAll(importDir); err != nil {
+		return false, fmt.Errorf("failed to remove import directory: %w", err)
+	}
+	return true, nil
+}
+
+// FindArtifacts lists the imported bundle waiting in the import directory and the scratch
+// directories in tempDir that have not been modified for staleAge
+func (m *Manager) FindArtifacts(importDir, tempDir string, staleAge time.Duration) ([]Artifact, error) {
+	var artifacts []Artifact
+
+	if info, err := m.fs.Stat(importDir); err == nil {
+		size, err := fsutil.SizeFS(m.fs, importDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure import directory: %w", err)
+		}
+		artifacts = append(artifacts, Artifact{
+			Kind:    ArtifactImport,
+			Path:    importDir,
+			Size:    size,
+			ModTime: info.ModTime(),
+			Note:    m.describeImport(importDir),
+		})
+	}
+
+	entries, err := m.fs.ReadDir(tempDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-staleAge)
+	for _, entry := range entries {
+		if matched, _ := filepath.Match(TempBundlePattern, entry.Name()); !matched {
+			continue
+		}
+		path := filepath.Join(tempDir, entry.Name())
+		info, err := m.fs.Stat(path)
+		if err != nil || !info.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		size, err := fsutil.SizeFS(m.fs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure %s: %w", path, err)
+		}
+		artifacts = append(artifacts, Artifact{
+			Kind:    ArtifactTemp,
+			Path:    path,
+			Size:    size,
+			ModTime: info.ModTime(),
+			Note:    "left behind by an interrupted export or import",
+		})
+	}
+
+	return artifacts, nil
+}
+
+// describeImport summarizes where the imported bundle came from and how much of it is deployed
+func (m *Manager) describeImport(importDir string) string {
+	bundle, err := m.loadBundleMetadata(filepath.Join(importDir, "bundle.yaml"))
+	if err != nil {
+		return "incomplete import"
+	}
+
+	note := "imported bundle"
+	if bundle.CreatedBy != "" {
+		note = fmt.Sprintf("bundle by %s", bundle.CreatedBy)
+	}
+	if record, err := loadImportRecord(m.fs, importDir); err == nil && record != nil {
+		note = fmt.Sprintf("%s, imported %s", filepath.Base(record.Source), record.ImportedAt.Format("2006-01-02 15:04"))
+	}
+
+	state, err := loadDeployState(m.fs, importDir)
+	if err != nil {
+		return note
+	}
+	deployed := state.DeployedCount(bundle)
+	if deployed == 0 {
+		return note + ", not deployed"
+	}
+	return fmt.Sprintf("%s, %d of %d app(s) deployed", note, deployed, len(bundle.Apps))
+}
@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestRecordImport(t *testing.T) {
+	manager, _, bundle, importDir, _ := setupImportedBundle(t)
+
+	if record, err := LoadImportRecord(importDir); err != nil || record != nil {
+		t.Fatalf("Expected no record before importing, got %+v %v", record, err)
+	}
+	if err := manager.RecordImport(importDir, "bundle.zip", "abc123", bundle); err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	record, err := LoadImportRecord(importDir)
+	if err != nil || record == nil {
+		t.Fatalf("LoadImportRecord failed: %+v %v", record, err)
+	}
+	if !filepath.IsAbs(record.Source) || filepath.Base(record.Source) != "bundle.zip" || record.Hash != "abc123" || len(record.Apps) != 1 || record.Apps[0] != "testapp" {
+		t.Errorf("Unexpected import record: %+v", record)
+	}
+}
+
+func TestRemoveDeployedImport(t *testing.T) {
+	manager, configManager, bundle, importDir, _ := setupImportedBundle(t)
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(importDir, "bundle.yaml"), data, 0644); err != nil {
+		t.Fatalf("Failed to write bundle metadata: %v", err)
+	}
+
+	// Nothing deployed yet, so the import is kept
+	if removed, err := manager.RemoveDeployedImport(importDir); err != nil || removed {
+		t.Fatalf("Expected an undeployed import to be kept, got %v %v", removed, err)
+	}
+
+	deployOnce(t, manager, configManager, bundle, importDir)
+	if removed, err := manager.RemoveDeployedImport(importDir); err != nil || !removed {
+		t.Fatalf("Expected a fully deployed import to be removed, got %v %v", removed, err)
+	}
+	if _, err := os.Stat(importDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the import directory to be gone, got %v", err)
+	}
+}
+
+func TestFindArtifacts(t *testing.T) {
+	manager, _, bundle, importDir, _ := setupImportedBundle(t)
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(importDir, "bundle.yaml"), data, 0644); err != nil {
+		t.Fatalf("Failed to write bundle metadata: %v", err)
+	}
+
+	tempDir := t.TempDir()
+	stale := filepath.Join(tempDir, "configsync-bundle-1")
+	fresh := filepath.Join(tempDir, "configsync-bundle-2")
+	unrelated := filepath.Join(tempDir, "other-1")
+	for _, dir := range []string{stale, fresh, unrelated} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * DefaultStaleAge)
+	for _, dir := range []string{stale, unrelated} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatalf("Failed to age directory: %v", err)
+		}
+	}
+
+	artifacts, err := manager.FindArtifacts(importDir, tempDir, DefaultStaleAge)
+	if err != nil {
+		t.Fatalf("FindArtifacts failed: %v", err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("Expected the import and the stale directory, got %+v", artifacts)
+	}
+	if artifacts[0].Kind != ArtifactImport || artifacts[0].Size == 0 || !strings.Contains(artifacts[0].Note, "not deployed") {
+		t.Errorf("Unexpected import artifact: %+v", artifacts[0])
+	}
+	if artifacts[1].Kind != ArtifactTemp || artifacts[1].Path != stale {
+		t.Errorf("Unexpected temp artifact: %+v", artifacts[1])
+	}
+
+	// Without an import directory only the scratch directories are listed
+	artifacts, err = manager.FindArtifacts(filepath.Join(tempDir, "missing"), tempDir, DefaultStaleAge)
+	if err != nil || len(artifacts) != 1 {
+		t.Errorf("Expected only the stale directory, got %+v %v", artifacts, err)
+	}
+}
@@ -0,0 +1,214 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// How a bundle application compares with the current system
+const (
+	DiffNew       = "new"       // The application is not configured on this system
+	DiffChanged   = "changed"   // Deploying would change the configuration or store files
+	DiffUnchanged = "unchanged" // The system already matches the bundle
+)
+
+// SystemDiff describes how a bundle differs from the current system
+type SystemDiff struct {
+	Apps      []AppDiff `json:"apps" yaml:"apps"`
+	LocalOnly []string  `json:"local_only,omitempty" yaml:"local_only,omitempty"` // Configured applications the bundle does not contain
+}
+
+// AppDiff describes how one bundle application differs from the current system
+type AppDiff struct {
+	Name         string   `json:"name" yaml:"name"`
+	DisplayName  string   `json:"display_name" yaml:"display_name"`
+	Status       string   `json:"status" yaml:"status"`
+	AddedPaths   []string `json:"added_paths,omitempty" yaml:"added_paths,omitempty"`     // Bundle paths the local configuration lacks
+	RemovedPaths []string `json:"removed_paths,omitempty" yaml:"removed_paths,omitempty"` // Local paths the bundle lacks
+	ChangedFiles []string `json:"changed_files,omitempty" yaml:"changed_files,omitempty"` // Store-relative files whose contents differ
+	NewFiles     []string `json:"new_files,omitempty" yaml:"new_files,omitempty"`         // Store-relative files missing from the store
+	Metadata     []string `json:"metadata,omitempty" yaml:"metadata,omitempty"`           // Differing settings, e.g. display_name: "A" -> "B"
+	Unchanged    int      `json:"unchanged_files" yaml:"unchanged_files"`
+}
+
+// HasChanges reports whether deploying the bundle would change anything on this system
+func (d *SystemDiff) HasChanges() bool {
+	for _, app := range d.Apps {
+		if app.Status != DiffUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffBundle unpacks a bundle into a scratch directory and compares it with the current system
+// without deploying or importing it
+func (m *Manager) DiffBundle(bundlePath string, configManager *config.Manager) (*SystemDiff, error) {
+	if !m.pathExists(bundlePath) {
+		return nil, fmt.Errorf("bundle file not found: %s", bundlePath)
+	}
+
+	scratchDir, cleanup, err := m.prepareBundleDirectory()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	bundle, err := m.unpackBundle(bundlePath, scratchDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CompareBundle(bundle, scratchDir, configManager)
+}
+
+// CompareBundle compares an unpacked bundle with the current configuration and store: which
+// applications are new, which paths were added or removed, which files have different contents,
+// and which settings differ. Source paths are translated to this system first, as deploy does.
+func (m *Manager) CompareBundle(bundle *config.DeploymentBundle, bundleDir string, configManager *config.Manager) (*SystemDiff, error) {
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current configuration: %w", err)
+	}
+
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	diff := &SystemDiff{}
+	translations := pathTranslations(configManager)
+	for _, appName := range appNames {
+		bundleApp := m.translateApp(bundle, bundle.Apps[appName], translations)
+		appDiff, err := m.compareApp(appName, bundleApp, cfg.Apps[appName], filepath.Join(bundleDir, "files", appName))
+		if err != nil {
+			return nil, err
+		}
+		diff.Apps = append(diff.Apps, appDiff)
+	}
+
+	for appName := range cfg.Apps {
+		if _, exists := bundle.Apps[appName]; !exists {
+			diff.LocalOnly = append(diff.LocalOnly, appName)
+		}
+	}
+	sort.Strings(diff.LocalOnly)
+
+	return diff, nil
+}
+
+// compareApp compares one bundle application with its local configuration, which is nil when
+// the application is not configured
+func (m *Manager) compareApp(appName string, bundleApp, localApp *config.AppConfig, bundleFilesDir string) (AppDiff, error) {
+	appDiff := AppDiff{Name: appName, DisplayName: bundleApp.DisplayName, Status: DiffUnchanged}
+
+	if localApp != nil {
+		appDiff.Metadata = compareAppSettings(localApp, bundleApp)
+
+		localPaths := make(map[string]*config.Path)
+		for i := range localApp.Paths {
+			localPaths[localApp.Paths[i].Destination] = &localApp.Paths[i]
+		}
+		for i := range bundleApp.Paths {
+			bundlePath := &bundleApp.Paths[i]
+			localPath, exists := localPaths[bundlePath.Destination]
+			if !exists {
+				appDiff.AddedPaths = append(appDiff.AddedPaths, bundlePath.Destination)
+				continue
+			}
+			delete(localPaths, bundlePath.Destination)
+			appDiff.Metadata = append(appDiff.Metadata, m.comparePathSettings(localPath, bundlePath)...)
+		}
+		for destination := range localPaths {
+			appDiff.RemovedPaths = append(appDiff.RemovedPaths, destination)
+		}
+		sort.Strings(appDiff.RemovedPaths)
+	}
+
+	files, err := hashBundleFiles(m.fs, bundleFilesDir)
+	if err != nil {
+		return appDiff, fmt.Errorf("failed to hash bundle files of %s: %w", appName, err)
+	}
+	relPaths := make([]string, 0, len(files))
+	for relPath := range files {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	for _, relPath := range relPaths {
+		storeHash, err := manifest.HashFileFS(m.fs, filepath.Join(m.storeDir, filepath.FromSlash(relPath)))
+		switch {
+		case os.IsNotExist(err):
+			appDiff.NewFiles = append(appDiff.NewFiles, relPath)
+		case err != nil:
+			return appDiff, fmt.Errorf("failed to hash store file %s: %w", relPath, err)
+		case storeHash != files[relPath]:
+			appDiff.ChangedFiles = append(appDiff.ChangedFiles, relPath)
+		default:
+			appDiff.Unchanged++
+		}
+	}
+
+	switch {
+	case localApp == nil:
+		appDiff.Status = DiffNew
+	case len(appDiff.AddedPaths)+len(appDiff.RemovedPaths)+len(appDiff.ChangedFiles)+len(appDiff.NewFiles)+len(appDiff.Metadata) > 0:
+		appDiff.Status = DiffChanged
+	}
+	return appDiff, nil
+}
+
+// compareAppSettings lists the application settings that differ between the local and bundle configurations
+func compareAppSettings(localApp, bundleApp *config.AppConfig) []string {
+	var diffs []string
+	diffs = appendSettingDiff(diffs, "display_name", localApp.DisplayName, bundleApp.DisplayName)
+	diffs = appendSettingDiff(diffs, "bundle_id", localApp.BundleID, bundleApp.BundleID)
+	diffs = appendSettingDiff(diffs, "defaults_domain", localApp.DefaultsDomain, bundleApp.DefaultsDomain)
+	diffs = appendSettingDiff(diffs, "post_sync", strings.Join(localApp.PostSync, "; "), strings.Join(bundleApp.PostSync, "; "))
+	return diffs
+}
+
+// comparePathSettings lists the settings of a path that differ between the local and bundle configurations
+func (m *Manager) comparePathSettings(localPath, bundlePath *config.Path) []string {
+	prefix := bundlePath.Destination + ": "
+	var diffs []string
+	diffs = appendSettingDiff(diffs, prefix+"source", m.expandHome(localPath.Source), m.expandHome(bundlePath.Source))
+	diffs = appendSettingDiff(diffs, prefix+"type", string(localPath.Type), string(bundlePath.Type))
+	diffs = appendSettingDiff(diffs, prefix+"mode", localPath.Mode, bundlePath.Mode)
+	diffs = appendSettingDiff(diffs, prefix+"required", fmt.Sprint(localPath.Required), fmt.Sprint(bundlePath.Required))
+	diffs = appendSettingDiff(diffs, prefix+"exclude", strings.Join(localPath.Exclude, ", "), strings.Join(bundlePath.Exclude, ", "))
+	diffs = appendSettingDiff(diffs, prefix+"links", m.joinLinks(localPath.Links), m.joinLinks(bundlePath.Links))
+	diffs = appendSettingDiff(diffs, prefix+"versions", m.expandHome(localPath.Versions), m.expandHome(bundlePath.Versions))
+	diffs = appendSettingDiff(diffs, prefix+"platforms", strings.Join(localPath.Platforms, ", "), strings.Join(bundlePath.Platforms, ", "))
+	return diffs
+}
+
+// appendSettingDiff records a setting whose local and bundle values differ
+func appendSettingDiff(diffs []string, name, local, bundle string) []string {
+	if local == bundle {
+		return diffs
+	}
+	return append(diffs, fmt.Sprintf("%s: %q -> %q", name, local, bundle))
+}
+
+// joinLinks lists a path's links with ~/ resolved, so they compare like sources do
+func (m *Manager) joinLinks(links []string) string {
+	expanded := make([]string, len(links))
+	for i, link := range links {
+		expanded[i] = m.expandHome(link)
+	}
+	return strings.Join(expanded, ", ")
+}
+
+// expandHome resolves a path starting with ~/ against the home directory
+func (m *Manager) expandHome(path string) string {
+	return fsops.ExpandHome(path, m.homeDir)
+}
@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestDiffBundle(t *testing.T) {
+	manager, configManager, tempDir := setupExportManager(t)
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	diff, err := manager.DiffBundle(bundlePath, configManager)
+	if err != nil {
+		t.Fatalf("DiffBundle failed: %v", err)
+	}
+	if diff.HasChanges() || len(diff.Apps) != 1 || diff.Apps[0].Unchanged != 1 {
+		t.Fatalf("Expected the system to match a bundle it just exported, got %+v", diff)
+	}
+
+	// Change the local system after the export
+	if err := os.WriteFile(filepath.Join(manager.storeDir, "app", "settings.json"), []byte(`{"theme": "dark"}`), 0600); err != nil {
+		t.Fatalf("Failed to change store file: %v", err)
+	}
+	app, err := configManager.GetApp("testapp")
+	if err != nil {
+		t.Fatalf("GetApp failed: %v", err)
+	}
+	app.DisplayName = "Renamed App"
+	app.AddPath("/test/extra.conf", "extra.conf", config.PathTypeFile, false)
+	other := config.NewAppConfig("other", "Other App")
+	if err := configManager.AddApp(other); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	diff, err = manager.DiffBundle(bundlePath, configManager)
+	if err != nil {
+		t.Fatalf("DiffBundle failed: %v", err)
+	}
+	appDiff := diff.Apps[0]
+	if appDiff.Status != DiffChanged {
+		t.Errorf("Expected testapp to be changed, got %s", appDiff.Status)
+	}
+	if strings.Join(appDiff.ChangedFiles, ",") != "app/settings.json" {
+		t.Errorf("Expected app/settings.json to differ, got %v", appDiff.ChangedFiles)
+	}
+	if strings.Join(appDiff.RemovedPaths, ",") != "extra.conf" {
+		t.Errorf("Expected extra.conf to be missing from the bundle, got %v", appDiff.RemovedPaths)
+	}
+	if len(appDiff.Metadata) != 1 || !strings.HasPrefix(appDiff.Metadata[0], "display_name") {
+		t.Errorf("Expected the display name to differ, got %v", appDiff.Metadata)
+	}
+	if strings.Join(diff.LocalOnly, ",") != "other" {
+		t.Errorf("Expected other to be listed as local only, got %v", diff.LocalOnly)
+	}
+}
+
+func TestDiffBundleNewApp(t *testing.T) {
+	manager, configManager, tempDir := setupExportManager(t)
+	bundlePath := filepath.Join(tempDir, "bundle.zip")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	if err := configManager.RemoveApp("testapp"); err != nil {
+		t.Fatalf("RemoveApp failed: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(manager.storeDir, "app")); err != nil {
+		t.Fatalf("Failed to remove store files: %v", err)
+	}
+
+	diff, err := manager.DiffBundle(bundlePath, configManager)
+	if err != nil {
+		t.Fatalf("DiffBundle failed: %v", err)
+	}
+	appDiff := diff.Apps[0]
+	if appDiff.Status != DiffNew || strings.Join(appDiff.NewFiles, ",") != "app/settings.json" {
+		t.Errorf("Expected a new app with a new file, got %+v", appDiff)
+	}
+}
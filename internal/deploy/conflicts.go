@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// Conflict represents a deployment conflict between a bundle application and the current system
+type Conflict struct {
+	AppName string
+	Message string
+	Path    string    // Store-relative file whose content conflicts, with forward slashes; empty for other conflicts
+	Local   FileState // The local copy of Path
+	Bundle  FileState // The bundle's copy of Path
+}
+
+// FileState is the size and modification time of one copy of a conflicting file
+type FileState struct {
+	ModTime time.Time
+	Path    string // Where the copy is: the store, or the source when the store has none yet
+	Size    int64
+}
+
+// String describes the copy for a conflict message
+func (s FileState) String() string {
+	return fmt.Sprintf("%s, modified %s", fsutil.FormatSize(s.Size), s.ModTime.Format("2006-01-02 15:04"))
+}
+
+// detectConflicts compares the content of the bundle's files with their local copies. A file
+// conflicts when its store copy was changed in ways deploy cannot merge with the bundle's, or,
+// when the store has no copy yet, when the file at its source differs from the bundle's and would
+// be replaced once synced. Store copies unchanged since the last deploy, and local changes the
+// bundle does not touch, are not conflicts. Bundles built against a newer version of an
+// application than the local one also conflict.
+func (m *Manager) detectConflicts(bundle *config.DeploymentBundle, bundleDir string, currentCfg *config.Config, translations []config.PathTranslation) ([]Conflict, error) {
+	storeManifest, err := manifest.LoadFS(m.fs, m.storeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Conflict
+	for appName, bundleApp := range bundle.Apps {
+		if currentApp, exists := currentCfg.Apps[appName]; exists {
+			// Settings written by a newer version of the application may not be understood locally
+			bundleVersion := bundleApp.Metadata[config.MetadataAppVersion]
+			localVersion := currentApp.Metadata[config.MetadataAppVersion]
+			if bundleVersion != "" && localVersion != "" && config.CompareVersions(bundleVersion, localVersion) > 0 {
+				conflicts = append(conflicts, Conflict{
+					AppName: appName,
+					Message: fmt.Sprintf("bundle built against %s %s, local has %s",
+						bundleApp.DisplayName, bundleVersion, localVersion),
+				})
+			}
+		}
+
+		appConflicts, err := m.fileConflicts(bundle, appName, bundleDir, storeManifest, translations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s with the local files: %w", appName, err)
+		}
+		conflicts = append(conflicts, appConflicts...)
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].AppName != conflicts[j].AppName {
+			return conflicts[i].AppName < conflicts[j].AppName
+		}
+		return conflicts[i].Path < conflicts[j].Path
+	})
+	return conflicts, nil
+}
+
+// fileConflicts returns the files of one bundle application whose content conflicts with their local copies
+func (m *Manager) fileConflicts(bundle *config.DeploymentBundle, appName, bundleDir string, storeManifest *manifest.Manifest, translations []config.PathTranslation) ([]Conflict, error) {
+	var conflicts []Conflict
+	bundleApp := bundle.Apps[appName]
+	for _, path := range bundleApp.Paths {
+		bundlePath := filepath.Join(bundleDir, "files", appName, path.Destination)
+		if !m.pathExists(bundlePath) {
+			continue
+		}
+		updates, err := m.planAppFiles(storeManifest, bundlePath, path.Destination)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, update := range updates {
+			localFile := update.storeFile
+			if update.action == fileCopy && !m.pathExists(update.storeFile) && !path.IsExported() {
+				// Nothing to merge with in the store; the source is replaced when the app is synced
+				rel, err := filepath.Rel(filepath.Join(m.storeDir, path.Destination), update.storeFile)
+				if err != nil {
+					return nil, err
+				}
+				source := config.TranslatePath(path.Source, bundle.Metadata[MetadataHomeDir], m.homeDir,
+					bundle.Metadata[MetadataPlatform], config.CurrentPlatform, translations)
+				localFile = filepath.Join(m.expandHome(source), rel)
+				differs, err := m.sourceDiffers(localFile, update.bundleFile)
+				if err != nil {
+					return nil, err
+				}
+				if !differs {
+					continue
+				}
+			} else if update.action != fileMerge || len(update.conflicts) == 0 {
+				continue
+			}
+
+			conflict, err := m.newFileConflict(appName, update.relPath, localFile, update.bundleFile)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// sourceDiffers reports whether a regular file exists at a source with other content than the
+// bundle's copy. Symlinks, which sync manages itself, never differ.
+func (m *Manager) sourceDiffers(sourceFile, bundleFile string) (bool, error) {
+	info, err := m.fs.Lstat(sourceFile)
+	if os.IsNotExist(err) || (err == nil && !info.Mode().IsRegular()) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	sourceHash, err := manifest.HashFileFS(m.fs, sourceFile)
+	if err != nil {
+		return false, err
+	}
+	bundleHash, err := manifest.HashFileFS(m.fs, bundleFile)
+	if err != nil {
+		return false, err
+	}
+	return sourceHash != bundleHash, nil
+}
+
+// newFileConflict describes a file whose local and bundle copies conflict
+func (m *Manager) newFileConflict(appName, relPath, localFile, bundleFile string) (Conflict, error) {
+	local, err := m.statFile(localFile)
+	if err != nil {
+		return Conflict{}, err
+	}
+	incoming, err := m.statFile(bundleFile)
+	if err != nil {
+		return Conflict{}, err
+	}
+	return Conflict{
+		AppName: appName,
+		Message: fmt.Sprintf("%s differs (local: %s; bundle: %s)", relPath, local, incoming),
+		Path:    relPath,
+		Local:   local,
+		Bundle:  incoming,
+	}, nil
+}
+
+// statFile returns the size and modification time of a file
+func (m *Manager) statFile(path string) (FileState, error) {
+	info, err := m.fs.Stat(path)
+	if err != nil {
+		return FileState{}, err
+	}
+	return FileState{ModTime: info.ModTime(), Path: path, Size: info.Size()}, nil
+}
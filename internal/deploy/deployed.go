@@ -0,0 +1,103 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// Deployment describes the bundle an application's configuration was last deployed from
+type Deployment struct {
+	DeployedAt time.Time `json:"deployed_at" yaml:"deployed_at"`
+	Bundle     string    `json:"bundle" yaml:"bundle"`                         // Hash of the bundle
+	Checksum   string    `json:"checksum,omitempty" yaml:"checksum,omitempty"` // Hash of the store files as deployed
+}
+
+// DeploymentOf returns the bundle an application was deployed from, or nil if it was never deployed
+func DeploymentOf(appConfig *config.AppConfig) *Deployment {
+	bundle := appConfig.Metadata[config.MetadataDeployedBundle]
+	if bundle == "" {
+		return nil
+	}
+	deployment := &Deployment{Bundle: bundle, Checksum: appConfig.Metadata[config.MetadataDeployedChecksum]}
+	deployment.DeployedAt, _ = time.Parse(time.RFC3339, appConfig.Metadata[config.MetadataDeployedAt])
+	return deployment
+}
+
+// Diverged reports whether an application's store files no longer match the ones deployed
+func (d *Deployment) Diverged(storeDir string, appConfig *config.AppConfig) (bool, error) {
+	if d.Checksum == "" {
+		return false, nil
+	}
+	checksum, err := StoreChecksum(storeDir, appConfig)
+	if err != nil {
+		return false, err
+	}
+	return checksum != d.Checksum, nil
+}
+
+// StoreChecksum hashes the store files of an application's paths. Secrets, which are kept
+// outside the store, and paths with nothing in the store are left out.
+func StoreChecksum(storeDir string, appConfig *config.AppConfig) (string, error) {
+	return storeChecksum(fsys.OS, storeDir, appConfig)
+}
+
+// storeChecksum implements StoreChecksum on a file system
+func storeChecksum(files fsys.FS, storeDir string, appConfig *config.AppConfig) (string, error) {
+	destinations := make([]string, 0, len(appConfig.Paths))
+	for _, path := range appConfig.Paths {
+		if !path.IsSecret() {
+			destinations = append(destinations, path.Destination)
+		}
+	}
+	sort.Strings(destinations)
+
+	hash := sha256.New()
+	for _, destination := range destinations {
+		storePath := filepath.Join(storeDir, destination)
+		if _, err := files.Lstat(storePath); os.IsNotExist(err) {
+			continue
+		}
+		pathHash, err := hashPath(files, storePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", storePath, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%s\n", filepath.ToSlash(destination), pathHash)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordDeployment notes in an application's metadata the bundle it was just deployed from,
+// and the checksum of its store files as deployed. Nothing is recorded for unidentified bundles.
+func (m *Manager) recordDeployment(appConfig *config.AppConfig, bundleHash string, deployedAt time.Time) error {
+	if bundleHash == "" {
+		return nil
+	}
+	checksum, err := storeChecksum(m.fs, m.storeDir, appConfig)
+	if err != nil {
+		return err
+	}
+	if appConfig.Metadata == nil {
+		appConfig.Metadata = make(map[string]string)
+	}
+	appConfig.Metadata[config.MetadataDeployedBundle] = bundleHash
+	appConfig.Metadata[config.MetadataDeployedAt] = deployedAt.UTC().Format(time.RFC3339)
+	appConfig.Metadata[config.MetadataDeployedChecksum] = checksum
+	return nil
+}
+
+// deployedBundleHash returns the hash identifying the bundle in a directory: the hash of the
+// archive it was imported from when recorded, or else the hash of its metadata
+func deployedBundleHash(files fsys.FS, bundleDir string) (string, error) {
+	if record, err := loadImportRecord(files, bundleDir); err == nil && record != nil && record.Hash != "" {
+		return record.Hash, nil
+	}
+	return hashBundle(files, bundleDir)
+}
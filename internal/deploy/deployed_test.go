@@ -0,0 +1,40 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestDeployRecordsDeployment(t *testing.T) {
+	manager, configManager, bundle, importDir, _ := setupImportedBundle(t)
+	bundle.Apps["testapp"].Metadata[config.MetadataDeployedBundle] = "exporting-machine"
+
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected the app to be deployed, got %+v", result)
+	}
+	appConfig, err := configManager.GetApp("testapp")
+	if err != nil {
+		t.Fatalf("GetApp failed: %v", err)
+	}
+	deployment := DeploymentOf(appConfig)
+	if deployment == nil || deployment.Bundle != "abc123" || deployment.DeployedAt.IsZero() || deployment.Checksum == "" {
+		t.Fatalf("Expected the deployment to be recorded, got %+v", deployment)
+	}
+
+	if diverged, err := deployment.Diverged(manager.storeDir, appConfig); err != nil || diverged {
+		t.Errorf("Expected the deployed store files to match, got %t, %v", diverged, err)
+	}
+	if err := os.WriteFile(filepath.Join(manager.storeDir, "test.conf"), []byte("edited locally"), 0644); err != nil {
+		t.Fatalf("Failed to modify store file: %v", err)
+	}
+	if diverged, err := deployment.Diverged(manager.storeDir, appConfig); err != nil || !diverged {
+		t.Errorf("Expected the changed store file to diverge, got %t, %v", diverged, err)
+	}
+
+	if DeploymentOf(config.NewAppConfig("other", "Other")) != nil {
+		t.Error("Expected no deployment for an app added locally")
+	}
+}
@@ -0,0 +1,221 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// IsRemoteBundle reports whether a bundle location is an HTTP or HTTPS URL rather than a path
+func IsRemoteBundle(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// DownloadsDir is the directory in the config directory that keeps interrupted downloads of
+// remote bundles, so downloading the same URL again resumes them
+const DownloadsDir = "downloads"
+
+// DownloadOptions controls how DownloadBundle downloads a bundle
+type DownloadOptions struct {
+	SHA256     string // Expected SHA-256 digest of the bundle in hex; required for HTTP URLs, not checked when empty
+	PartialDir string // Directory keeping interrupted downloads to resume; downloads start over when empty
+}
+
+// NewHTTPClient returns the client bundles are downloaded with. Requests go through proxy when
+// it is given, and otherwise through the proxy set in HTTPS_PROXY or HTTP_PROXY, if any.
+func NewHTTPClient(proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// DownloadBundle downloads a bundle from an HTTP or HTTPS URL and returns its path, with a
+// function that removes it. The file keeps its name from the URL, so bundles whose format is
+// only known from their extension are still recognized. An interrupted download is kept in the
+// options' PartialDir, and the next download of the URL resumes it where the server supports
+// range requests. A bundle not matching the options' digest is deleted. Plain HTTP can be
+// tampered with on the way, so HTTP URLs are only downloaded with a digest to verify.
+func DownloadBundle(ctx context.Context, client *http.Client, bundleURL string, opts DownloadOptions) (string, func(), error) {
+	parsed, err := url.Parse(bundleURL)
+	if err != nil || !IsRemoteBundle(bundleURL) {
+		return "", nil, fmt.Errorf("invalid bundle URL %q", bundleURL)
+	}
+	if parsed.Scheme == "http" && opts.SHA256 == "" {
+		return "", nil, fmt.Errorf("refusing to download %s over plain HTTP without a SHA-256 digest to verify it; use HTTPS or pass --sha256", bundleURL)
+	}
+	if opts.SHA256 != "" {
+		if err := CheckDigest(opts.SHA256); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Downloads kept for resuming have a directory per URL; others a scratch directory
+	var workDir string
+	if opts.PartialDir != "" {
+		sum := sha256.Sum256([]byte(bundleURL))
+		workDir = filepath.Join(opts.PartialDir, hex.EncodeToString(sum[:])[:16])
+		err = os.MkdirAll(workDir, 0755)
+	} else {
+		workDir, err = os.MkdirTemp("", TempBundlePattern)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(workDir) }
+
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		name = DefaultBundlePath("", "")
+	}
+	bundlePath := filepath.Join(workDir, name)
+	partialPath := bundlePath + ".part"
+	if err := fetchResuming(ctx, client, bundleURL, parsed.Host, partialPath); err != nil {
+		if opts.PartialDir == "" {
+			cleanup()
+		}
+		return "", nil, err
+	}
+
+	if opts.SHA256 != "" {
+		if err := VerifyChecksum(partialPath, opts.SHA256); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	if err := os.Rename(partialPath, bundlePath); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to save bundle: %w", err)
+	}
+	return bundlePath, cleanup, nil
+}
+
+// fetchResuming downloads a URL to a file, appending to what an earlier download left in it.
+// The ETag or Last-Modified date of the file is kept next to it and sent as If-Range, so a
+// file that changed since is downloaded again from the start instead of being spliced onto
+// the old bytes. A partial file without one is never resumed.
+func fetchResuming(ctx context.Context, client *http.Client, fileURL, host, partialPath string) error {
+	validatorPath := partialPath + ".validator"
+
+	// A partial file the server cannot resume is discarded, and the download starts over once
+	for attempt := 0; attempt < 2; attempt++ {
+		var offset int64
+		if info, err := os.Stat(partialPath); err == nil {
+			offset = info.Size()
+		}
+		validator, _ := os.ReadFile(validatorPath)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+		if err != nil {
+			return fmt.Errorf("invalid bundle URL %q: %w", fileURL, err)
+		}
+		if offset > 0 && len(validator) > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", string(validator))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to download bundle: %w", err)
+		}
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && req.Header.Get("Range") != "" &&
+			strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+			flags = os.O_WRONLY | os.O_APPEND
+		case resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			_ = resp.Body.Close()
+			if err := os.Remove(partialPath); err != nil {
+				return fmt.Errorf("failed to discard partial download: %w", err)
+			}
+			_ = os.Remove(validatorPath)
+			continue
+		case resp.StatusCode != http.StatusOK:
+			_ = resp.Body.Close()
+			return fmt.Errorf("failed to download bundle: %s returned %s", host, resp.Status)
+		default:
+			// The whole file, either for a new download or because it changed since the partial
+			// one, replaces the partial file; remember which version of it is being downloaded
+			if err := saveValidator(validatorPath, resp.Header); err != nil {
+				_ = resp.Body.Close()
+				return err
+			}
+		}
+
+		file, err := os.OpenFile(partialPath, flags, 0644)
+		if err != nil {
+			_ = resp.Body.Close()
+			return fmt.Errorf("failed to create %s: %w", partialPath, err)
+		}
+		_, err = io.Copy(file, resp.Body)
+		_ = resp.Body.Close()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to download bundle: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to download bundle: %s cannot resume the partial download", host)
+}
+
+// saveValidator keeps the validator of a file being downloaded for resuming it with If-Range:
+// its ETag, unless that is weak and so cannot be used for ranges, or else its Last-Modified date
+func saveValidator(validatorPath string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(validatorPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to discard download validator: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(validatorPath, []byte(validator), 0644); err != nil {
+		return fmt.Errorf("failed to save download validator: %w", err)
+	}
+	return nil
+}
+
+// CheckDigest returns an error unless a digest is a hex-encoded SHA-256 digest
+func CheckDigest(digest string) error {
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 digest %q (expected %d hex characters)", digest, 2*sha256.Size)
+	}
+	return nil
+}
+
+// VerifyChecksum returns an error unless the SHA-256 digest of a bundle archive is digest
+func VerifyChecksum(bundlePath, digest string) error {
+	if err := CheckDigest(digest); err != nil {
+		return err
+	}
+	if info, err := os.Stat(bundlePath); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory; only bundle archives have a checksum", bundlePath)
+	}
+	actual, err := manifest.HashFile(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash bundle: %w", err)
+	}
+	if !strings.EqualFold(actual, digest) {
+		return fmt.Errorf("bundle checksum mismatch: expected SHA-256 %s, got %s", strings.ToLower(digest), actual)
+	}
+	return nil
+}
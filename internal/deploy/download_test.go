@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadBundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bundles/team.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("bundle"))
+	}))
+	defer server.Close()
+
+	bundlePath, cleanup, err := DownloadBundle(context.Background(), server.Client(), server.URL+"/bundles/team.zip", DownloadOptions{})
+	if err != nil {
+		t.Fatalf("DownloadBundle failed: %v", err)
+	}
+	data, err := os.ReadFile(bundlePath)
+	if err != nil || string(data) != "bundle" || filepath.Base(bundlePath) != "team.zip" {
+		t.Errorf("Expected the bundle to be downloaded as team.zip, got %s: %q, %v", bundlePath, data, err)
+	}
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(bundlePath)); !os.IsNotExist(err) {
+		t.Errorf("Expected cleanup to remove the scratch directory, got %v", err)
+	}
+
+	if _, _, err := DownloadBundle(context.Background(), server.Client(), server.URL+"/missing.tar.gz", DownloadOptions{}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a failed download to report the status, got %v", err)
+	}
+	if _, _, err := DownloadBundle(context.Background(), server.Client(), "bundle.tar.gz", DownloadOptions{}); err == nil {
+		t.Error("Expected a path to be rejected")
+	}
+	plainURL := strings.Replace(server.URL, "https://", "http://", 1) + "/bundles/team.zip"
+	if _, _, err := DownloadBundle(context.Background(), server.Client(), plainURL, DownloadOptions{}); err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("Expected a plain HTTP URL without a digest to be rejected, got %v", err)
+	}
+}
+
+func TestDownloadBundleResumesAndVerifies(t *testing.T) {
+	content := []byte("a bundle large enough to be interrupted")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "team.tar.gz", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+	bundleURL := server.URL + "/team.tar.gz"
+
+	// An earlier download was interrupted after the first bytes
+	partialDir := t.TempDir()
+	urlSum := sha256.Sum256([]byte(bundleURL))
+	workDir := filepath.Join(partialDir, hex.EncodeToString(urlSum[:])[:16])
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create download directory: %v", err)
+	}
+	partialPath := filepath.Join(workDir, "team.tar.gz.part")
+	if err := os.WriteFile(partialPath, content[:10], 0644); err != nil {
+		t.Fatalf("Failed to write partial download: %v", err)
+	}
+	if err := os.WriteFile(partialPath+".validator", []byte(`"v2"`), 0644); err != nil {
+		t.Fatalf("Failed to write download validator: %v", err)
+	}
+
+	bundlePath, cleanup, err := DownloadBundle(context.Background(), server.Client(), bundleURL, DownloadOptions{SHA256: digest, PartialDir: partialDir})
+	if err != nil {
+		t.Fatalf("DownloadBundle failed: %v", err)
+	}
+	if data, _ := os.ReadFile(bundlePath); string(data) != string(content) {
+		t.Errorf("Expected the resumed download to be complete, got %q", data)
+	}
+	if len(ranges) != 1 || ranges[0] != `bytes=10- "v2"` {
+		t.Errorf("Expected the download to resume after the partial bytes, got ranges %q", ranges)
+	}
+	cleanup()
+
+	// A partial download of an older version of the bundle is not spliced onto the new one
+	ranges = nil
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create download directory: %v", err)
+	}
+	if err := os.WriteFile(partialPath, []byte("old bundle"), 0644); err != nil {
+		t.Fatalf("Failed to write partial download: %v", err)
+	}
+	if err := os.WriteFile(partialPath+".validator", []byte(`"v1"`), 0644); err != nil {
+		t.Fatalf("Failed to write download validator: %v", err)
+	}
+	bundlePath, cleanup, err = DownloadBundle(context.Background(), server.Client(), bundleURL, DownloadOptions{SHA256: digest, PartialDir: partialDir})
+	if err != nil {
+		t.Fatalf("DownloadBundle failed for a changed bundle: %v", err)
+	}
+	if data, _ := os.ReadFile(bundlePath); string(data) != string(content) {
+		t.Errorf("Expected the changed bundle to be downloaded from the start, got %q", data)
+	}
+	if len(ranges) != 1 || ranges[0] != `bytes=10- "v1"` {
+		t.Errorf("Expected the resume to be conditional on the old version, got ranges %q", ranges)
+	}
+	cleanup()
+
+	wrong := strings.Repeat("0", 64)
+	if _, _, err := DownloadBundle(context.Background(), server.Client(), bundleURL, DownloadOptions{SHA256: wrong, PartialDir: partialDir}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(workDir); !os.IsNotExist(err) {
+		t.Errorf("Expected a bundle failing verification to be deleted, got %v", err)
+	}
+	if _, _, err := DownloadBundle(context.Background(), server.Client(), bundleURL, DownloadOptions{SHA256: "abc"}); err == nil || !strings.Contains(err.Error(), "invalid SHA-256") {
+		t.Errorf("Expected a malformed digest to be rejected, got %v", err)
+	}
+}
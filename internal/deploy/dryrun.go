@@ -0,0 +1,220 @@
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/merge"
+)
+
+// DeployPlan describes what deploying a bundle would change, without changing anything
+type DeployPlan struct {
+	Conflicts []Conflict
+	Apps      []AppPlan
+}
+
+// AppPlan describes what deploying one application would change
+type AppPlan struct {
+	Name        string
+	DisplayName string
+	Error       string   // Why the deployment would fail, if it would
+	Copy        []string // Store-relative files that would be written
+	Merge       []string // Store-relative files that would be merged with local changes
+	Keep        []string // Store-relative files whose local changes would be kept
+	Conflicts   []string // Conflicting changes that would fail the deployment
+	Unchanged   int      // Files already identical in the store
+	Skipped     bool     // Already deployed from this bundle and unchanged
+	Overwrite   bool     // An existing app configuration would be replaced
+}
+
+// SetDryRun makes export, import, and deploy report what they would do without writing anything
+func (m *Manager) SetDryRun(dryRun bool) {
+	m.dryRun = dryRun
+}
+
+// PlanDeployment works out which files would be copied to the store, which app
+// configurations would be added or overwritten, and which conflicts exist
+func (m *Manager) PlanDeployment(bundle *config.DeploymentBundle, bundleDir string, configManager *config.Manager, state *DeployState) (*DeployPlan, error) {
+	currentCfg, err := configManager.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current configuration: %w", err)
+	}
+
+	storeManifest, err := manifest.LoadFS(m.fs, m.storeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := m.detectConflicts(bundle, bundleDir, currentCfg, pathTranslations(configManager))
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeployPlan{}
+	for _, conflict := range conflicts {
+		if m.mergePolicy != merge.PolicyReport {
+			break
+		}
+		if appState, exists := state.Apps[conflict.AppName]; exists && appState.Status == AppStateDeployed {
+			continue
+		}
+		plan.Conflicts = append(plan.Conflicts, conflict)
+	}
+
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		bundleAppConfig := bundle.Apps[appName]
+		_, exists := currentCfg.Apps[appName]
+		appPlan := AppPlan{
+			Name:        appName,
+			DisplayName: bundleAppConfig.DisplayName,
+			Overwrite:   exists,
+		}
+
+		bundleFilesDir := filepath.Join(bundleDir, "files", appName)
+		files, err := hashBundleFiles(m.fs, bundleFilesDir)
+		if err != nil {
+			appPlan.Error = err.Error()
+			plan.Apps = append(plan.Apps, appPlan)
+			continue
+		}
+
+		if exists && state.Unchanged(appName, files, storeManifest, m.storeDir) {
+			appPlan.Skipped = true
+			plan.Apps = append(plan.Apps, appPlan)
+			continue
+		}
+
+		var updates []*fileUpdate
+		for _, path := range bundleAppConfig.Paths {
+			bundlePath := filepath.Join(bundleFilesDir, path.Destination)
+			if !m.pathExists(bundlePath) {
+				if path.Required {
+					appPlan.Error = fmt.Sprintf("required file missing from bundle: %s", path.Destination)
+					break
+				}
+				continue
+			}
+
+			pathUpdates, err := m.planAppFiles(storeManifest, bundlePath, path.Destination)
+			if err != nil {
+				appPlan.Error = err.Error()
+				break
+			}
+			updates = append(updates, pathUpdates...)
+		}
+		sort.Slice(updates, func(i, j int) bool { return updates[i].relPath < updates[j].relPath })
+
+		for _, update := range updates {
+			switch update.action {
+			case fileUnchanged:
+				appPlan.Unchanged++
+			case fileCopy:
+				appPlan.Copy = append(appPlan.Copy, update.relPath)
+			case fileKeep:
+				appPlan.Keep = append(appPlan.Keep, update.relPath)
+			case fileMerge:
+				appPlan.Merge = append(appPlan.Merge, update.relPath)
+				for _, conflict := range update.conflicts {
+					appPlan.Conflicts = append(appPlan.Conflicts, fmt.Sprintf("%s (%s)", update.relPath, conflict))
+				}
+			}
+		}
+
+		plan.Apps = append(plan.Apps, appPlan)
+	}
+
+	return plan, nil
+}
+
+// Helper methods
+
+// showDeployPlan displays a deployment plan
+func (m *Manager) showDeployPlan(plan *DeployPlan, force bool) {
+	if len(plan.Conflicts) > 0 {
+		fmt.Println("Deployment conflicts detected:")
+		for _, conflict := range plan.Conflicts {
+			fmt.Printf("  - %s: %s\n", conflict.AppName, conflict.Message)
+		}
+		if force {
+			fmt.Println("[DRY RUN] Conflicts would be overridden by --force")
+		} else {
+			fmt.Println("[DRY RUN] Deployment would stop here; use --force to override conflicts")
+		}
+		fmt.Println()
+	}
+
+	for _, app := range plan.Apps {
+		switch {
+		case app.Skipped:
+			fmt.Printf("[DRY RUN] Would skip %s (already deployed and unchanged)\n", app.DisplayName)
+			continue
+		case app.Error != "":
+			fmt.Printf("[DRY RUN] Would fail to deploy %s: %s\n", app.DisplayName, app.Error)
+			continue
+		case len(app.Conflicts) > 0:
+			fmt.Printf("[DRY RUN] Would fail to deploy %s: local changes conflict with the bundle\n", app.DisplayName)
+			for _, conflict := range app.Conflicts {
+				fmt.Printf("    Conflict: %s\n", conflict)
+			}
+			continue
+		case app.Overwrite:
+			fmt.Printf("[DRY RUN] Would overwrite configuration for %s\n", app.DisplayName)
+		default:
+			fmt.Printf("[DRY RUN] Would add configuration for %s\n", app.DisplayName)
+		}
+
+		for _, relPath := range app.Copy {
+			fmt.Printf("    Would copy: %s\n", filepath.Join(m.storeDir, filepath.FromSlash(relPath)))
+		}
+		for _, relPath := range app.Merge {
+			fmt.Printf("    Would merge: %s\n", filepath.Join(m.storeDir, filepath.FromSlash(relPath)))
+		}
+		for _, relPath := range app.Keep {
+			fmt.Printf("    Would keep local changes: %s\n", filepath.Join(m.storeDir, filepath.FromSlash(relPath)))
+		}
+		if app.Unchanged > 0 {
+			fmt.Printf("    %d file(s) already up to date\n", app.Unchanged)
+		}
+	}
+}
+
+// showExportPlan displays which store paths an export would include
+func (m *Manager) showExportPlan(bundle *config.DeploymentBundle, bundlePath string) {
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		appConfig := bundle.Apps[appName]
+		if version := appConfig.Metadata[config.MetadataAppVersion]; version != "" {
+			fmt.Printf("[DRY RUN] Would include %s (version %s)\n", appConfig.DisplayName, version)
+		} else {
+			fmt.Printf("[DRY RUN] Would include %s\n", appConfig.DisplayName)
+		}
+		for _, path := range appConfig.Paths {
+			storePath := filepath.Join(m.storeDir, path.Destination)
+			if _, err := m.fs.Stat(storePath); err != nil {
+				fmt.Printf("    Would skip missing: %s\n", storePath)
+				continue
+			}
+			fmt.Printf("    Would copy: %s\n", storePath)
+		}
+	}
+
+	for _, pkg := range bundle.Packages {
+		fmt.Printf("[DRY RUN] Would record %s %s for %s in the Brewfile\n", pkg.Type, pkg.Name, pkg.App)
+	}
+
+	fmt.Printf("[DRY RUN] Would create bundle %s with %d application(s)\n", bundlePath, len(bundle.Apps))
+}
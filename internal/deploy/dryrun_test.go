@@ -0,0 +1,112 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestDeployDryRunChangesNothing(t *testing.T) {
+	manager, configManager, bundle, importDir, _ := setupImportedBundle(t)
+	manager.SetDryRun(true)
+
+	state, err := LoadDeployState(importDir)
+	if err != nil {
+		t.Fatalf("LoadDeployState failed: %v", err)
+	}
+
+	plan, err := manager.PlanDeployment(bundle, importDir, configManager, state)
+	if err != nil {
+		t.Fatalf("PlanDeployment failed: %v", err)
+	}
+	if len(plan.Apps) != 1 || plan.Apps[0].Overwrite || len(plan.Apps[0].Copy) != 1 || plan.Apps[0].Copy[0] != "test.conf" {
+		t.Fatalf("Expected plan to add the app and copy test.conf, got %+v", plan.Apps)
+	}
+
+	if err := manager.DeployBundle(bundle, importDir, configManager, false); err != nil {
+		t.Fatalf("Dry-run deploy failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(manager.storeDir, "test.conf")); !os.IsNotExist(err) {
+		t.Error("Dry-run deploy must not copy files to the store")
+	}
+	if _, err := os.Stat(filepath.Join(importDir, StateFile)); !os.IsNotExist(err) {
+		t.Error("Dry-run deploy must not record deployment state")
+	}
+	if _, err := configManager.GetApp("testapp"); err == nil {
+		t.Error("Dry-run deploy must not add app configuration")
+	}
+}
+
+func TestPlanDeploymentAfterDeploy(t *testing.T) {
+	manager, configManager, bundle, importDir, _ := setupImportedBundle(t)
+	deployOnce(t, manager, configManager, bundle, importDir)
+
+	state, err := LoadDeployState(importDir)
+	if err != nil {
+		t.Fatalf("LoadDeployState failed: %v", err)
+	}
+
+	plan, err := manager.PlanDeployment(bundle, importDir, configManager, state)
+	if err != nil {
+		t.Fatalf("PlanDeployment failed: %v", err)
+	}
+	if len(plan.Apps) != 1 || !plan.Apps[0].Skipped {
+		t.Errorf("Expected deployed app to be skipped, got %+v", plan.Apps)
+	}
+}
+
+func TestExportAndImportDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "test.conf"), []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("/test/source.conf", "test.conf", config.PathTypeFile, false)
+	if err := configManager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false)
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+
+	manager.SetDryRun(true)
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("Dry-run export failed: %v", err)
+	}
+	if _, err := os.Stat(bundlePath); !os.IsNotExist(err) {
+		t.Fatal("Dry-run export must not create the bundle")
+	}
+	if record, err := LoadParentRecord(configManager.GetConfigDir()); err != nil || record != nil {
+		t.Errorf("Dry-run export must not record a parent bundle, got %+v (%v)", record, err)
+	}
+
+	manager.SetDryRun(false)
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	importDir := filepath.Join(tempDir, "import")
+	manager.SetDryRun(true)
+	bundle, err := manager.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("Dry-run import failed: %v", err)
+	}
+	if len(bundle.Apps) != 1 {
+		t.Errorf("Expected dry-run import to return the bundle, got %+v", bundle.Apps)
+	}
+	if _, err := os.Stat(importDir); !os.IsNotExist(err) {
+		t.Error("Dry-run import must not create the import directory")
+	}
+}
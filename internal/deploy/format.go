@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// BundleFormatVersion is the bundle format written by this version of configsync.
+// The major version changes when older binaries can no longer read a bundle;
+// the minor version changes when fields are added that older binaries can ignore.
+//
+//	1.0  initial format
+//	1.1  provenance, integrity manifest, and optional signature
+//	1.2  host overrides
+//	1.3  description, tags, minimum configsync version, app count, and size
+const BundleFormatVersion = "1.3"
+
+// bundleMigration upgrades a bundle from one format version to the next
+type bundleMigration struct {
+	migrate     func(bundle *config.DeploymentBundle) error
+	from        string
+	to          string
+	description string
+}
+
+// bundleMigrations upgrade old bundles step by step to the current format, in order
+var bundleMigrations = []bundleMigration{
+	{
+		from:        "1.0",
+		to:          "1.1",
+		description: "fill in app names and metadata omitted by early 1.0 bundles",
+		migrate:     migrateBundle10To11,
+	},
+	{
+		from:        "1.1",
+		to:          "1.2",
+		description: "bundles without host overrides apply to every host",
+		migrate:     migrateBundle11To12,
+	},
+	{
+		from:        "1.2",
+		to:          "1.3",
+		description: "count the applications of bundles exported without bundle info",
+		migrate:     migrateBundle12To13,
+	},
+}
+
+// CheckBundleFormat reports whether a bundle of the given format version can be read by this binary
+func CheckBundleFormat(version string) error {
+	bundleVersion, err := parseFormatVersion(version)
+	if err != nil {
+		return err
+	}
+	current, _ := parseFormatVersion(BundleFormatVersion)
+
+	if bundleVersion[0] > current[0] {
+		return fmt.Errorf("bundle format %s is newer than the supported format %s; upgrade configsync to import this bundle", version, BundleFormatVersion)
+	}
+	if bundleVersion[0] < 1 {
+		return fmt.Errorf("bundle format %s is no longer supported", version)
+	}
+
+	return nil
+}
+
+// Helper methods
+
+// migrateBundle checks a loaded bundle's format version and upgrades it in memory to the current format.
+// The bundle files on disk are left untouched so their signature and integrity manifest still apply.
+func (m *Manager) migrateBundle(bundle *config.DeploymentBundle) error {
+	if err := CheckBundleFormat(bundle.Version); err != nil {
+		return err
+	}
+
+	if compareFormatVersions(bundle.Version, BundleFormatVersion) > 0 {
+		fmt.Printf("Warning: bundle format %s is newer than %s; fields added since will be ignored\n", bundle.Version, BundleFormatVersion)
+		return nil
+	}
+
+	for _, migration := range bundleMigrations {
+		if compareFormatVersions(bundle.Version, migration.to) >= 0 {
+			continue
+		}
+		if m.verbose {
+			fmt.Printf("Migrating bundle format %s to %s: %s\n", migration.from, migration.to, migration.description)
+		}
+		if err := migration.migrate(bundle); err != nil {
+			return fmt.Errorf("failed to migrate bundle from format %s to %s: %w", migration.from, migration.to, err)
+		}
+		bundle.Version = migration.to
+	}
+
+	return nil
+}
+
+// migrateBundle10To11 fills in fields that early 1.0 bundles could leave empty
+func migrateBundle10To11(bundle *config.DeploymentBundle) error {
+	if bundle.Metadata == nil {
+		bundle.Metadata = make(map[string]string)
+	}
+	if bundle.Apps == nil {
+		bundle.Apps = make(map[string]*config.AppConfig)
+	}
+
+	for appName, appConfig := range bundle.Apps {
+		if appConfig == nil {
+			return fmt.Errorf("application %s has no configuration", appName)
+		}
+		if appConfig.Name == "" {
+			appConfig.Name = appName
+		}
+		if appConfig.DisplayName == "" {
+			appConfig.DisplayName = appName
+		}
+	}
+
+	return nil
+}
+
+// migrateBundle11To12 leaves 1.1 bundles as they are: without a hosts section, every application is
+// deployed on every host
+func migrateBundle11To12(_ *config.DeploymentBundle) error {
+	return nil
+}
+
+// migrateBundle12To13 adds the bundle info older bundles lack. The size of their files is unknown.
+func migrateBundle12To13(bundle *config.DeploymentBundle) error {
+	if bundle.Info == nil {
+		bundle.Info = &config.BundleInfo{AppCount: len(bundle.Apps)}
+	}
+	return nil
+}
+
+// parseFormatVersion parses a "major.minor" or "major.minor.patch" bundle format version
+func parseFormatVersion(version string) ([3]int, error) {
+	var parsed [3]int
+
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if version == "" || len(parts) > 3 {
+		return parsed, fmt.Errorf("invalid bundle format version %q", version)
+	}
+
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return parsed, fmt.Errorf("invalid bundle format version %q", version)
+		}
+		parsed[i] = number
+	}
+
+	return parsed, nil
+}
+
+// compareFormatVersions returns -1, 0, or 1 when a is older than, the same as, or newer than b.
+// Both versions must already have been validated.
+func compareFormatVersions(a, b string) int {
+	versionA, _ := parseFormatVersion(a)
+	versionB, _ := parseFormatVersion(b)
+
+	for i := range versionA {
+		switch {
+		case versionA[i] < versionB[i]:
+			return -1
+		case versionA[i] > versionB[i]:
+			return 1
+		}
+	}
+	return 0
+}
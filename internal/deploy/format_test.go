@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestCheckBundleFormat(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"1.0", false},
+		{BundleFormatVersion, false},
+		{"1.9", false},
+		{"1.1.3", false},
+		{"2.0", true},
+		{"0.9", true},
+		{"", true},
+		{"one", true},
+		{"1.x", true},
+	}
+
+	for _, tt := range tests {
+		err := CheckBundleFormat(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckBundleFormat(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+		}
+	}
+
+	if err := CheckBundleFormat("2.0"); err == nil || !strings.Contains(err.Error(), "upgrade configsync") {
+		t.Errorf("Expected newer format error to suggest upgrading, got %v", err)
+	}
+}
+
+func TestLoadBundleMetadataMigratesOldFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	bundleFile := filepath.Join(tempDir, "bundle.yaml")
+	content := `version: "1.0"
+created_by: test
+apps:
+  git:
+    paths:
+      - source: ~/.gitconfig
+        destination: .gitconfig
+        type: file
+`
+	if err := os.WriteFile(bundleFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write bundle metadata: %v", err)
+	}
+
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
+	bundle, err := manager.LoadBundleMetadata(bundleFile)
+	if err != nil {
+		t.Fatalf("LoadBundleMetadata failed: %v", err)
+	}
+
+	if bundle.Version != BundleFormatVersion {
+		t.Errorf("Expected bundle to be migrated to %s, got %s", BundleFormatVersion, bundle.Version)
+	}
+	if bundle.Apps["git"].Name != "git" || bundle.Metadata == nil {
+		t.Errorf("Expected migration to fill in app name and metadata, got %+v", bundle)
+	}
+
+	// The file on disk is left as exported
+	data, err := os.ReadFile(bundleFile)
+	if err != nil {
+		t.Fatalf("Failed to read bundle metadata: %v", err)
+	}
+	if string(data) != content {
+		t.Error("Migration must not rewrite the bundle metadata file")
+	}
+}
+
+func TestLoadBundleMetadataRejectsNewerFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	bundleFile := filepath.Join(tempDir, "bundle.yaml")
+	if err := os.WriteFile(bundleFile, []byte("version: \"2.0\"\napps: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle metadata: %v", err)
+	}
+
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
+	if _, err := manager.LoadBundleMetadata(bundleFile); err == nil {
+		t.Error("Expected bundle with a newer major format to be rejected")
+	}
+}
+
+func TestMigrationsReachCurrentFormat(t *testing.T) {
+	version := bundleMigrations[0].from
+	for _, migration := range bundleMigrations {
+		if migration.from != version {
+			t.Fatalf("Migration from %s does not follow %s", migration.from, version)
+		}
+		version = migration.to
+	}
+	if version != BundleFormatVersion {
+		t.Errorf("Migrations end at %s, expected %s", version, BundleFormatVersion)
+	}
+
+	bundle := &config.DeploymentBundle{Version: "1.0"}
+	if err := migrateBundle10To11(bundle); err != nil || bundle.Apps == nil {
+		t.Errorf("Expected empty bundle to migrate, got %+v (%v)", bundle, err)
+	}
+}
@@ -0,0 +1,41 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// SetDescription sets the description and tags recorded in exported bundles
+func (m *Manager) SetDescription(description string, tags []string) {
+	m.info.Description = description
+	m.info.Tags = tags
+}
+
+// SetMinVersion sets the oldest configsync version that may import exported bundles
+func (m *Manager) SetMinVersion(version string) error {
+	if version != "" {
+		if _, err := parseFormatVersion(version); err != nil {
+			return fmt.Errorf("invalid minimum configsync version %q (expected e.g. 1.4.0)", version)
+		}
+	}
+	m.info.MinVersion = version
+	return nil
+}
+
+// CheckMinVersion returns an error when a bundle needs a newer configsync than toolVersion.
+// Development builds, whose version is not a release number, accept every bundle.
+func CheckMinVersion(bundle *config.DeploymentBundle, toolVersion string) error {
+	if bundle.Info == nil || bundle.Info.MinVersion == "" {
+		return nil
+	}
+	if _, err := parseFormatVersion(toolVersion); err != nil {
+		return nil
+	}
+	if compareFormatVersions(toolVersion, bundle.Info.MinVersion) < 0 {
+		return fmt.Errorf("bundle requires configsync %s or newer, but this is %s; upgrade configsync to import it",
+			strings.TrimPrefix(bundle.Info.MinVersion, "v"), strings.TrimPrefix(toolVersion, "v"))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleInfo(t *testing.T) {
+	for _, format := range []string{FormatTarGz, FormatDir} {
+		t.Run(format, func(t *testing.T) {
+			manager, configManager, tempDir := setupExportManager(t)
+			manager.SetFormat(format)
+			manager.SetDescription("Engineering baseline", []string{"eng", "macos"})
+			if err := manager.SetMinVersion("1.4.0"); err != nil {
+				t.Fatalf("SetMinVersion failed: %v", err)
+			}
+
+			bundlePath := filepath.Join(tempDir, "bundle-"+format)
+			if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+				t.Fatalf("ExportBundle failed: %v", err)
+			}
+			bundle, _, err := ReadBundleArchive(bundlePath)
+			if err != nil {
+				t.Fatalf("ReadBundleArchive failed: %v", err)
+			}
+			info := bundle.Info
+			if info == nil || info.Description != "Engineering baseline" || strings.Join(info.Tags, ",") != "eng,macos" ||
+				info.MinVersion != "1.4.0" || info.AppCount != 1 || info.TotalSize != int64(len("{}")) {
+				t.Fatalf("Expected the bundle info to be recorded, got %+v", info)
+			}
+
+			manager.SetVersion("1.3.2")
+			if _, err := manager.ImportBundle(bundlePath, filepath.Join(tempDir, "import-old")); err == nil || !strings.Contains(err.Error(), "requires configsync 1.4.0") {
+				t.Errorf("Expected an older configsync to refuse the bundle, got %v", err)
+			}
+			manager.SetVersion("1.4.0")
+			if _, err := manager.ImportBundle(bundlePath, filepath.Join(tempDir, "import")); err != nil {
+				t.Errorf("Expected the minimum version to import the bundle, got %v", err)
+			}
+		})
+	}
+
+	manager, _, _ := setupExportManager(t)
+	if err := manager.SetMinVersion("latest"); err == nil {
+		t.Error("Expected an invalid minimum version to be rejected")
+	}
+}
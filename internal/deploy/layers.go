@@ -0,0 +1,323 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/merge"
+)
+
+// LayersDir is the directory in the config directory that keeps the bundles imported as layers
+// of a layered deployment, each in a directory named after its layer
+const LayersDir = "layers"
+
+// Layers of a layered deployment
+const (
+	LayerBaseline = "baseline" // Provided by the team, and replaced when it publishes a new one
+	LayerOverlay  = "overlay"  // Personal customizations, which win over the baseline
+)
+
+// Layers lists the layers from lowest to highest precedence
+var Layers = []string{LayerBaseline, LayerOverlay}
+
+// composedLayerDir is the directory in the layers directory the layers are composed into. It
+// keeps its deploy state between compositions, so unchanged applications are not redeployed.
+const composedLayerDir = "composed"
+
+// LayerProvenanceFile is the file in the layers directory recording which layers each deployed
+// application came from
+const LayerProvenanceFile = "provenance.yaml"
+
+// LayerProvenance records the layers of the last layered deployment and where each deployed
+// application came from
+type LayerProvenance struct {
+	DeployedAt time.Time               `json:"deployed_at" yaml:"deployed_at"`
+	Layers     map[string]*LayerSource `json:"layers" yaml:"layers"`
+	Apps       map[string]*AppLayers   `json:"apps" yaml:"apps"`
+}
+
+// LayerSource identifies the bundle imported as a layer
+type LayerSource struct {
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	CreatedBy string    `json:"created_by" yaml:"created_by"`
+	Source    string    `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// AppLayers records where a deployed application came from
+type AppLayers struct {
+	Layers     []string `json:"layers" yaml:"layers"`                             // Layers providing the application, lowest precedence first
+	Overridden []string `json:"overridden,omitempty" yaml:"overridden,omitempty"` // Store files a higher layer replaced or merged over a lower one
+}
+
+// CheckLayer returns an error for an unknown layer name
+func CheckLayer(layer string) error {
+	for _, known := range Layers {
+		if layer == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown layer %q (use %s)", layer, strings.Join(Layers, " or "))
+}
+
+// LayerDir returns the directory a layer is imported into
+func LayerDir(configDir, layer string) string {
+	return filepath.Join(configDir, LayersDir, layer)
+}
+
+// ComposeLayers combines the imported layers into one bundle, written to a directory in the
+// layers directory, and returns it with that directory and the provenance of its applications.
+// An application in several layers takes its settings from the highest layer and keeps the
+// paths only lower layers have. Files in several layers are merged key by key for JSON, YAML,
+// and plist files, and line by line otherwise, with the higher layer winning where they differ.
+func (m *Manager) ComposeLayers(configDir string) (*config.DeploymentBundle, string, *LayerProvenance, error) {
+	targetDir := filepath.Join(configDir, LayersDir, composedLayerDir)
+	if err := m.resetComposedDir(targetDir); err != nil {
+		return nil, "", nil, err
+	}
+
+	composed := &config.DeploymentBundle{
+		Version:  BundleFormatVersion,
+		Apps:     make(map[string]*config.AppConfig),
+		Metadata: make(map[string]string),
+	}
+	provenance := &LayerProvenance{
+		Layers: make(map[string]*LayerSource),
+		Apps:   make(map[string]*AppLayers),
+	}
+	var creators []string
+
+	for _, layer := range Layers {
+		layerDir := LayerDir(configDir, layer)
+		metadataPath := filepath.Join(layerDir, BundleMetadataFile)
+		if !m.pathExists(metadataPath) {
+			continue
+		}
+		bundle, err := m.loadBundleMetadata(metadataPath)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to load %s layer: %w", layer, err)
+		}
+
+		source := &LayerSource{CreatedAt: bundle.CreatedAt, CreatedBy: bundle.CreatedBy}
+		if record, err := LoadImportRecord(layerDir); err == nil && record != nil {
+			source.Source = record.Source
+		}
+		provenance.Layers[layer] = source
+		creators = append(creators, bundle.CreatedBy)
+		composeBundleSettings(composed, bundle)
+
+		appNames := make([]string, 0, len(bundle.Apps))
+		for appName := range bundle.Apps {
+			appNames = append(appNames, appName)
+		}
+		sort.Strings(appNames)
+		for _, appName := range appNames {
+			overridden, err := m.composeApp(composed, appName, bundle.Apps[appName], layerDir, targetDir)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("failed to compose %s from the %s layer: %w", appName, layer, err)
+			}
+			appLayers := provenance.Apps[appName]
+			if appLayers == nil {
+				appLayers = &AppLayers{}
+				provenance.Apps[appName] = appLayers
+			}
+			appLayers.Layers = append(appLayers.Layers, layer)
+			appLayers.Overridden = append(appLayers.Overridden, overridden...)
+		}
+	}
+	if len(provenance.Layers) == 0 {
+		return nil, "", nil, fmt.Errorf("no layers imported. Run 'configsync import --layer %s <bundle>' first", LayerBaseline)
+	}
+
+	composed.CreatedBy = strings.Join(creators, " + ")
+	if err := m.saveBundleMetadata(composed, filepath.Join(targetDir, BundleMetadataFile)); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to save composed bundle: %w", err)
+	}
+	return composed, targetDir, provenance, nil
+}
+
+// resetComposedDir empties the directory layers are composed into, keeping its deploy state
+func (m *Manager) resetComposedDir(targetDir string) error {
+	state, err := m.fs.ReadFile(filepath.Join(targetDir, StateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read deploy state: %w", err)
+	}
+	if err := m.fs.RemoveAll(targetDir); err != nil {
+		return fmt.Errorf("failed to clean %s: %w", targetDir, err)
+	}
+	if err := m.fs.MkdirAll(filepath.Join(targetDir, "files"), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", targetDir, err)
+	}
+	if state != nil {
+		if err := m.fs.WriteFile(filepath.Join(targetDir, StateFile), state, 0644); err != nil {
+			return fmt.Errorf("failed to write deploy state: %w", err)
+		}
+	}
+	return nil
+}
+
+// composeBundleSettings applies the bundle-wide settings of a layer over those of lower layers
+func composeBundleSettings(composed, bundle *config.DeploymentBundle) {
+	if bundle.CreatedAt.After(composed.CreatedAt) {
+		composed.CreatedAt = bundle.CreatedAt
+	}
+	for key, value := range bundle.Metadata {
+		composed.Metadata[key] = value
+	}
+	for host, override := range bundle.Hosts {
+		if composed.Hosts == nil {
+			composed.Hosts = make(map[string]*config.HostOverride)
+		}
+		composed.Hosts[host] = override
+	}
+	for _, pkg := range bundle.Packages {
+		known := false
+		for _, existing := range composed.Packages {
+			known = known || existing.App == pkg.App
+		}
+		if !known {
+			composed.Packages = append(composed.Packages, pkg)
+		}
+	}
+}
+
+// composeApp adds an application of a layer to the composed bundle, and returns the store files
+// of lower layers it replaced or merged over
+func (m *Manager) composeApp(composed *config.DeploymentBundle, appName string, app *config.AppConfig, layerDir, targetDir string) ([]string, error) {
+	sourceDir := filepath.Join(layerDir, "files", appName)
+	filesDir := filepath.Join(targetDir, "files", appName)
+
+	lower, exists := composed.Apps[appName]
+	if !exists {
+		composed.Apps[appName] = app
+		if !m.pathExists(sourceDir) {
+			return nil, nil
+		}
+		return nil, m.copyDir(sourceDir, filesDir)
+	}
+
+	merged := *app
+	merged.Paths = append([]config.Path{}, app.Paths...)
+	for _, path := range lower.Paths {
+		if !hasDestination(app, path.Destination) {
+			merged.Paths = append(merged.Paths, path)
+		}
+	}
+	composed.Apps[appName] = &merged
+
+	if !m.pathExists(sourceDir) {
+		return nil, nil
+	}
+	var overridden []string
+	err := fsys.Walk(m.fs, sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		changed, err := m.composeFile(path, filepath.Join(filesDir, relPath), filepath.ToSlash(relPath))
+		if changed {
+			overridden = append(overridden, filepath.ToSlash(relPath))
+		}
+		return err
+	})
+	return overridden, err
+}
+
+// composeFile writes a file of a higher layer over the same file of lower layers, merging the
+// two when they differ, and reports whether the lower layers' file changed
+func (m *Manager) composeFile(higherFile, composedFile, relPath string) (bool, error) {
+	lower, err := m.fs.ReadFile(composedFile)
+	if os.IsNotExist(err) {
+		return false, m.copyFile(higherFile, composedFile)
+	}
+	if err != nil {
+		return false, err
+	}
+	higher, err := m.fs.ReadFile(higherFile)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(lower, higher) {
+		return false, nil
+	}
+
+	content := higher
+	// Files with no common ancestor merge every key both layers set, keeping the higher layer's value
+	if result, err := merge.Merge(relPath, nil, lower, higher, merge.PolicyPreferIncoming); err == nil {
+		content = result.Content
+	}
+	if err := m.fs.WriteFile(composedFile, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", composedFile, err)
+	}
+	return true, nil
+}
+
+// hasDestination reports whether an application has a path with a store destination
+func hasDestination(app *config.AppConfig, destination string) bool {
+	for _, path := range app.Paths {
+		if path.Destination == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadLayerProvenance reads the provenance of layered deployments, or returns nil when there
+// has been none
+func LoadLayerProvenance(configDir string) (*LayerProvenance, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, LayersDir, LayerProvenanceFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer provenance: %w", err)
+	}
+
+	var provenance LayerProvenance
+	if err := yaml.Unmarshal(data, &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse layer provenance: %w", err)
+	}
+	if provenance.Apps == nil {
+		provenance.Apps = make(map[string]*AppLayers)
+	}
+	return &provenance, nil
+}
+
+// RecordLayerProvenance records where the applications of a layered deployment came from.
+// Applications deployed from layers earlier keep their provenance.
+func RecordLayerProvenance(configDir string, composed *LayerProvenance, apps map[string]*config.AppConfig) error {
+	provenance, err := LoadLayerProvenance(configDir)
+	if err != nil {
+		return err
+	}
+	if provenance == nil {
+		provenance = &LayerProvenance{Apps: make(map[string]*AppLayers)}
+	}
+	provenance.DeployedAt = time.Now()
+	provenance.Layers = composed.Layers
+	for appName := range apps {
+		if appLayers, exists := composed.Apps[appName]; exists {
+			provenance.Apps[appName] = appLayers
+		}
+	}
+
+	data, err := yaml.Marshal(provenance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal layer provenance: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, LayersDir, LayerProvenanceFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save layer provenance: %w", err)
+	}
+	return nil
+}
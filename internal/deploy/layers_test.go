@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// writeLayer imports a bundle with one app as a layer, with the given store files
+func writeLayer(t *testing.T, m *Manager, configDir, layer string, app *config.AppConfig, files map[string]string) {
+	t.Helper()
+	layerDir := LayerDir(configDir, layer)
+	for relPath, content := range files {
+		path := filepath.Join(layerDir, "files", app.Name, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", relPath, err)
+		}
+	}
+	bundle := &config.DeploymentBundle{
+		Version:   BundleFormatVersion,
+		CreatedAt: time.Now(),
+		CreatedBy: layer + "-author",
+		Apps:      map[string]*config.AppConfig{app.Name: app},
+	}
+	if err := m.saveBundleMetadata(bundle, filepath.Join(layerDir, BundleMetadataFile)); err != nil {
+		t.Fatalf("Failed to save bundle: %v", err)
+	}
+}
+
+func TestComposeLayers(t *testing.T) {
+	tempDir := t.TempDir()
+	configDir := filepath.Join(tempDir, ".configsync")
+	m := NewManager(tempDir, filepath.Join(configDir, "store"), filepath.Join(configDir, "backups"), false)
+
+	if _, _, _, err := m.ComposeLayers(configDir); err == nil || !strings.Contains(err.Error(), "no layers") {
+		t.Errorf("Expected an error without layers, got %v", err)
+	}
+
+	baseline := config.NewAppConfig("vscode", "VS Code")
+	baseline.AddPath("~/Library/Application Support/Code/User/settings.json", "Code/settings.json", config.PathTypeFile, false)
+	baseline.AddPath("~/Library/Application Support/Code/User/keybindings.json", "Code/keybindings.json", config.PathTypeFile, false)
+	writeLayer(t, m, configDir, LayerBaseline, baseline, map[string]string{
+		"Code/settings.json":    `{"editor.tabSize": 4, "telemetry.enabled": false}`,
+		"Code/keybindings.json": `[]`,
+	})
+	overlay := config.NewAppConfig("vscode", "Visual Studio Code")
+	overlay.AddPath("~/Library/Application Support/Code/User/settings.json", "Code/settings.json", config.PathTypeFile, false)
+	writeLayer(t, m, configDir, LayerOverlay, overlay, map[string]string{
+		"Code/settings.json": `{"editor.tabSize": 2, "workbench.colorTheme": "Solarized"}`,
+	})
+
+	bundle, bundleDir, provenance, err := m.ComposeLayers(configDir)
+	if err != nil {
+		t.Fatalf("ComposeLayers failed: %v", err)
+	}
+	app := bundle.Apps["vscode"]
+	if app == nil || app.DisplayName != "Visual Studio Code" || len(app.Paths) != 2 {
+		t.Fatalf("Expected the overlay's settings with the baseline's extra path, got %+v", app)
+	}
+	if bundle.CreatedBy != "baseline-author + overlay-author" {
+		t.Errorf("Expected both layers' authors, got %q", bundle.CreatedBy)
+	}
+
+	data, err := os.ReadFile(filepath.Join(bundleDir, "files", "vscode", "Code", "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to read composed file: %v", err)
+	}
+	for _, want := range []string{`"editor.tabSize": 2`, `"telemetry.enabled": false`, `"workbench.colorTheme": "Solarized"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected the composed settings to contain %s, got %s", want, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, "files", "vscode", "Code", "keybindings.json")); err != nil {
+		t.Errorf("Expected the baseline-only file to be composed: %v", err)
+	}
+
+	appLayers := provenance.Apps["vscode"]
+	if appLayers == nil || strings.Join(appLayers.Layers, ",") != "baseline,overlay" || strings.Join(appLayers.Overridden, ",") != "Code/settings.json" {
+		t.Errorf("Expected provenance from both layers with settings.json overridden, got %+v", appLayers)
+	}
+
+	if err := RecordLayerProvenance(configDir, provenance, bundle.Apps); err != nil {
+		t.Fatalf("RecordLayerProvenance failed: %v", err)
+	}
+	recorded, err := LoadLayerProvenance(configDir)
+	if err != nil || recorded == nil || recorded.Apps["vscode"] == nil || recorded.Layers[LayerOverlay] == nil {
+		t.Errorf("Expected the provenance to be recorded, got %+v, %v", recorded, err)
+	}
+
+	if err := CheckLayer("personal"); err == nil {
+		t.Error("Expected an unknown layer to be rejected")
+	}
+}
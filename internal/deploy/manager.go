@@ -2,49 +2,144 @@
 package deploy
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"context"
+	"crypto/ed25519"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
+	"sort"
 	"time"
 
 	yaml "gopkg.in/yaml.v3"
 
+	"github.com/dotbrains/configsync/internal/backup"
+	"github.com/dotbrains/configsync/internal/brew"
 	"github.com/dotbrains/configsync/internal/config"
 	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/history"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/merge"
+	"github.com/dotbrains/configsync/internal/messages"
+	"github.com/dotbrains/configsync/internal/progress"
+	"github.com/dotbrains/configsync/internal/tarball"
 )
 
 // Manager handles deployment operations for configuration bundles
 type Manager struct {
-	homeDir   string
-	storeDir  string
-	backupDir string
-	verbose   bool
+	ctx            context.Context
+	fs             fsys.FS
+	progress       *progress.Emitter
+	brew           *brew.Manager
+	backups        *backup.Manager
+	history        *history.Journal
+	undo           func(apps map[string]*config.AppConfig) string
+	signingKey     ed25519.PrivateKey
+	verifyKey      ed25519.PublicKey
+	homeDir        string
+	storeDir       string
+	backupDir      string
+	toolVersion    string
+	info           config.BundleInfo
+	parentPath     string
+	format         string
+	compression    string
+	level          int
+	stats          ExportStats
+	mergePolicy    merge.Policy
+	verbose        bool
+	dryRun         bool
+	withBrewfile   bool
+	installMissing bool
+	includeCaches  bool
+	autoBackup     bool
+	trustHooks     bool
 }
 
 // NewManager creates a new deployment manager
 func NewManager(homeDir, storeDir, backupDir string, verbose bool) *Manager {
 	return &Manager{
-		homeDir:   homeDir,
-		storeDir:  storeDir,
-		backupDir: backupDir,
-		verbose:   verbose,
+		ctx:        context.Background(),
+		fs:         fsys.OS,
+		homeDir:    homeDir,
+		storeDir:   storeDir,
+		backupDir:  backupDir,
+		verbose:    verbose,
+		autoBackup: true,
+		brew:       brew.NewManager(verbose),
+		backups:    backup.NewManager(backupDir, homeDir, verbose),
 	}
 }
 
+// SetContext makes exports, imports, and deploys stop with the context's error once ctx is
+// canceled or times out, removing the partially written bundle or file
+func (m *Manager) SetContext(ctx context.Context) {
+	m.ctx = ctx
+	m.backups = m.backups.WithContext(ctx)
+}
+
+// SetFS makes the manager read and write bundles, the store, and the records it keeps about
+// imports and deployments through files instead of the operating system's file system
+func (m *Manager) SetFS(files fsys.FS) {
+	m.fs = files
+	m.backups = m.backups.WithFS(files)
+}
+
+// SetProgress reports export, import, and deploy progress to an emitter
+func (m *Manager) SetProgress(emitter *progress.Emitter) {
+	m.progress = emitter
+}
+
+// SetHistory records every deployed application in a history journal
+func (m *Manager) SetHistory(journal *history.Journal) {
+	m.history = journal
+}
+
+// SetUndo sets the function that saves the state of the applications about to be deployed, so the
+// deploy can be undone; it returns the undo point recorded with them in the history
+func (m *Manager) SetUndo(capture func(apps map[string]*config.AppConfig) string) {
+	m.undo = capture
+}
+
+// SetAutoBackup sets whether deploy backs up the store copies it overwrites. Applications can
+// also turn their backups off with BackupBefore.
+func (m *Manager) SetAutoBackup(enabled bool) {
+	m.autoBackup = enabled
+}
+
+// SetTrustHooks sets whether deploy adopts the post-sync commands of bundle apps. Sync runs
+// them in a shell, so by default they are shown and left out for the user to review.
+func (m *Manager) SetTrustHooks(trust bool) {
+	m.trustHooks = trust
+}
+
+// SetIncludeCaches sets whether exports include the common cache and log directories, which are
+// otherwise left out of bundles
+func (m *Manager) SetIncludeCaches(include bool) {
+	m.includeCaches = include
+}
+
 // ExportBundle creates a deployment bundle from current configuration
 func (m *Manager) ExportBundle(bundlePath string, apps []string, configManager *config.Manager) error {
 	if m.verbose {
 		fmt.Printf("Creating deployment bundle: %s\n", bundlePath)
 	}
 
+	format := m.format
+	if format == "" {
+		format = FormatFromPath(bundlePath)
+	}
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
 	// Load current configuration
 	cfg, err := configManager.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return messages.Wrap(messages.ConfigLoadFailed, nil, err)
 	}
 
 	// Create and populate bundle metadata
@@ -53,28 +148,46 @@ func (m *Manager) ExportBundle(bundlePath string, apps []string, configManager *
 		return err
 	}
 
-	// Prepare bundle contents in temporary directory
-	tempDir, cleanup, err := m.prepareBundleDirectory()
-	if err != nil {
+	// Record the Homebrew packages that install the bundled apps
+	if err := m.addBrewPackages(bundle); err != nil {
 		return err
 	}
-	defer cleanup()
 
-	// Save bundle metadata
-	bundleFile := filepath.Join(tempDir, "bundle.yaml")
-	if err := m.saveBundleMetadata(bundle, bundleFile); err != nil {
-		return fmt.Errorf("failed to save bundle metadata: %w", err)
+	if m.dryRun {
+		m.showExportPlan(bundle, bundlePath)
+		return nil
 	}
 
-	// Copy configuration files
-	if err := m.copyBundleFiles(bundle, tempDir); err != nil {
-		return err
-	}
+	m.progress.Start("export", len(bundle.Apps))
+
+	if format == FormatDir {
+		err = m.exportBundleDir(bundle, bundlePath, configManager)
+	} else {
+		compression := m.compression
+		if compression == "" && format == FormatTarGz {
+			compression = CompressionFromPath(bundlePath)
+		} else if compression == "" {
+			compression = CompressionGzip
+		}
+		if err = CheckCompression(format, compression, m.level); err != nil {
+			return err
+		}
 
-	// Create compressed bundle
-	if err := m.createTarGz(tempDir, bundlePath); err != nil {
+		// Stream the store into the archive, so large stores need no temporary copy
+		err = m.streamBundle(bundle, bundlePath, format, compression, configManager)
+		if err != nil {
+			_ = m.fs.Remove(bundlePath)
+		}
+	}
+	if err != nil {
 		return fmt.Errorf("failed to create bundle archive: %w", err)
 	}
+	m.progress.Finish("export", len(bundle.Apps), 0)
+
+	// This bundle becomes the parent of the next export
+	if err := m.RecordParentBundle(configManager.GetConfigDir(), bundle, bundlePath); err != nil {
+		fmt.Printf("Warning: failed to record bundle lineage: %v\n", err)
+	}
 
 	if m.verbose {
 		bundleSize, _ := m.getFileSize(bundlePath)
@@ -95,16 +208,52 @@ func (m *Manager) ImportBundle(bundlePath, targetDir string) (*config.Deployment
 		return nil, fmt.Errorf("bundle file not found: %s", bundlePath)
 	}
 
+	// A dry run extracts into a scratch directory so the target is left untouched
+	if m.dryRun {
+		scratchDir, cleanup, err := m.prepareBundleDirectory()
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		targetDir = scratchDir
+	}
+
 	// Create target directory
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(targetDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create target directory: %w", err)
 	}
 
+	m.progress.Start("import", 0)
+	bundle, err := m.unpackBundle(bundlePath, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	m.progress.Finish("import", len(bundle.Apps), 0)
+
+	if m.verbose {
+		fmt.Printf("Bundle imported successfully: %d applications\n", len(bundle.Apps))
+		for _, appConfig := range bundle.Apps {
+			fmt.Printf("  %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
+		}
+	}
+
+	return bundle, nil
+}
+
+// unpackBundle extracts a bundle into a directory, then verifies and validates its contents
+func (m *Manager) unpackBundle(bundlePath, targetDir string) (*config.DeploymentBundle, error) {
 	// Extract bundle
-	if err := m.extractTarGz(bundlePath, targetDir); err != nil {
+	m.progress.Step("import", "Extracting bundle")
+	if err := m.extractBundle(bundlePath, targetDir); err != nil {
 		return nil, fmt.Errorf("failed to extract bundle: %w", err)
 	}
 
+	// Check the signature before trusting the metadata
+	m.progress.Step("import", "Verifying bundle")
+	if err := m.verifySignature(targetDir); err != nil {
+		return nil, fmt.Errorf("bundle verification failed: %w", err)
+	}
+
 	// Load bundle metadata
 	bundleFile := filepath.Join(targetDir, "bundle.yaml")
 	bundle, err := m.loadBundleMetadata(bundleFile)
@@ -112,40 +261,86 @@ func (m *Manager) ImportBundle(bundlePath, targetDir string) (*config.Deployment
 		return nil, fmt.Errorf("failed to load bundle metadata: %w", err)
 	}
 
+	if err := m.verifyIntegrity(bundle, targetDir); err != nil {
+		return nil, fmt.Errorf("bundle verification failed: %w", err)
+	}
+	if err := CheckMinVersion(bundle, m.toolVersion); err != nil {
+		return nil, err
+	}
+
 	// Validate bundle contents
+	m.progress.Step("import", "Validating bundle")
 	if err := m.validateBundle(bundle, targetDir); err != nil {
 		return nil, fmt.Errorf("bundle validation failed: %w", err)
 	}
 
-	if m.verbose {
-		fmt.Printf("Bundle imported successfully: %d applications\n", len(bundle.Apps))
-		for _, appConfig := range bundle.Apps {
-			fmt.Printf("  %s (%d paths)\n", appConfig.DisplayName, len(appConfig.Paths))
-		}
-	}
-
 	return bundle, nil
 }
 
-// DeployBundle deploys an imported bundle to the current system
+// DeployBundle deploys an imported bundle to the current system.
+// It is safe to run repeatedly: applications already deployed from the bundle whose files
+// are unchanged are skipped, and applications that failed previously are retried.
 func (m *Manager) DeployBundle(bundle *config.DeploymentBundle, bundleDir string, configManager *config.Manager, force bool) error {
 	if m.verbose {
 		fmt.Printf("Deploying bundle to current system\n")
 	}
 
+	state, err := loadDeployState(m.fs, bundleDir)
+	if err != nil {
+		return err
+	}
+
+	// Refuse applications this machine's configuration would not load before anything is
+	// written, so a destination outside the store never reaches the file system
+	if err := validateBundleApps(bundle); err != nil {
+		return err
+	}
+
+	// Forcing a deployment without a merge policy lets the bundle win conflicting changes
+	if force && m.mergePolicy == merge.PolicyReport {
+		m.mergePolicy = merge.PolicyPreferIncoming
+	}
+
+	if m.dryRun {
+		plan, err := m.PlanDeployment(bundle, bundleDir, configManager, state)
+		if err != nil {
+			return err
+		}
+		m.showDeployPlan(plan, force)
+		return m.installMissingPackages(bundle)
+	}
+
 	// Load current configuration and check conflicts
-	if err := m.checkDeploymentConflicts(bundle, configManager, force); err != nil {
+	if err := m.checkDeploymentConflicts(bundle, bundleDir, configManager, state, force); err != nil {
 		return err
 	}
 
+	// Install missing apps before deploying their configurations
+	if err := m.installMissingPackages(bundle); err != nil {
+		return err
+	}
+
+	// Applications deployed from the bundle record its hash, when it can be identified
+	bundleHash, err := deployedBundleHash(m.fs, bundleDir)
+	if err != nil && m.verbose {
+		fmt.Printf("Warning: failed to hash bundle, so its applications will not record it: %v\n", err)
+	}
+
 	// Deploy all applications
-	deployed, failed := m.deployAllApplications(bundle, bundleDir, configManager)
+	m.progress.Start("deploy", len(bundle.Apps))
+	result := m.deployAllApplications(bundle, bundleDir, bundleHash, configManager, state)
+	m.progress.Finish("deploy", len(result.Deployed)+len(result.Unchanged), len(result.Failed))
 
 	// Show deployment summary
-	m.showDeploymentSummary(deployed, failed)
+	m.showDeploymentSummary(result)
+
+	// Applications not reached yet are deployed when the deploy is run again
+	if err := m.ctx.Err(); err != nil {
+		return fmt.Errorf("deploy interrupted: %w", err)
+	}
 
 	// Return error if no applications were deployed
-	if len(deployed) == 0 && len(failed) > 0 {
+	if len(result.Deployed) == 0 && len(result.Unchanged) == 0 && len(result.Failed) > 0 {
 		return fmt.Errorf("failed to deploy any applications")
 	}
 
@@ -154,16 +349,30 @@ func (m *Manager) DeployBundle(bundle *config.DeploymentBundle, bundleDir string
 
 // Helper methods and types
 
-// Conflict represents a deployment conflict between source and target files
-type Conflict struct {
-	AppName string
-	Message string
+// validateBundleApps checks every application in a bundle as loading config.yaml would
+func validateBundleApps(bundle *config.DeploymentBundle) error {
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		appConfig := bundle.Apps[appName]
+		if appConfig == nil {
+			return fmt.Errorf("bundle application %s has no configuration", appName)
+		}
+		if err := config.ValidateApp(appConfig); err != nil {
+			return fmt.Errorf("bundle application %s is invalid: %w", appName, err)
+		}
+	}
+	return nil
 }
 
 // createDeploymentBundle creates and populates the bundle metadata
 func (m *Manager) createDeploymentBundle(cfg *config.Config, apps []string) (*config.DeploymentBundle, error) {
 	bundle := &config.DeploymentBundle{
-		Version:   "1.0",
+		Version:   BundleFormatVersion,
 		CreatedAt: time.Now(),
 		CreatedBy: m.getUserInfo(),
 		Apps:      make(map[string]*config.AppConfig),
@@ -171,9 +380,12 @@ func (m *Manager) createDeploymentBundle(cfg *config.Config, apps []string) (*co
 	}
 
 	// Add system information to metadata
-	bundle.Metadata["platform"] = "darwin"
+	bundle.Metadata[MetadataPlatform] = config.CurrentPlatform
+	bundle.Metadata[MetadataHomeDir] = m.homeDir
 	bundle.Metadata["created_on"] = m.getSystemInfo()
 
+	bundle.Hosts = cfg.Hosts
+
 	// Select apps to include
 	if len(apps) == 0 {
 		bundle.Apps = cfg.Apps
@@ -188,27 +400,80 @@ func (m *Manager) createDeploymentBundle(cfg *config.Config, apps []string) (*co
 					fmt.Printf("Including application: %s\n", appConfig.DisplayName)
 				}
 			} else {
-				return nil, fmt.Errorf("application not found: %s", appName)
+				return nil, messages.Error(messages.AppNotConfigured, messages.Data{"App": appName})
 			}
 		}
 	}
 
 	if len(bundle.Apps) == 0 {
-		return nil, fmt.Errorf("no applications to export")
+		return nil, messages.Error(messages.NothingToExport, nil)
 	}
 
+	info := m.info
+	info.AppCount = len(bundle.Apps)
+	bundle.Info = &info
+
 	return bundle, nil
 }
 
+// exportBundleDir prepares the bundle contents in a temporary directory and writes them to a
+// directory bundle
+func (m *Manager) exportBundleDir(bundle *config.DeploymentBundle, bundlePath string, configManager *config.Manager) error {
+	tempDir, cleanup, err := m.prepareBundleDirectory()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Copy configuration files
+	if err := m.copyBundleFiles(bundle, tempDir); err != nil {
+		return err
+	}
+
+	if err := m.writeBrewfile(bundle, tempDir); err != nil {
+		return err
+	}
+
+	// Record lineage and changes since the parent bundle
+	checksums, err := bundleChecksums(m.fs, bundle, filepath.Join(tempDir, "files"))
+	if err != nil {
+		return fmt.Errorf("failed to checksum bundle contents: %w", err)
+	}
+	if err := m.addProvenance(bundle, checksums, configManager.GetConfigDir()); err != nil {
+		return err
+	}
+
+	// Record the hash of every file so imports can detect corruption and tampering
+	if err := m.addIntegrity(bundle, tempDir); err != nil {
+		return err
+	}
+	if bundle.Info.TotalSize, err = fsutil.SizeFS(m.fs, filepath.Join(tempDir, "files")); err != nil {
+		return fmt.Errorf("failed to measure bundle contents: %w", err)
+	}
+
+	// Save bundle metadata
+	bundleFile := filepath.Join(tempDir, "bundle.yaml")
+	if err := m.saveBundleMetadata(bundle, bundleFile); err != nil {
+		return fmt.Errorf("failed to save bundle metadata: %w", err)
+	}
+
+	if err := m.signBundle(tempDir); err != nil {
+		return err
+	}
+
+	m.progress.Step("export", "Writing bundle directory")
+	return m.writeBundleDir(tempDir, bundlePath)
+}
+
 // prepareBundleDirectory creates and returns a temporary directory with cleanup function
 func (m *Manager) prepareBundleDirectory() (string, func(), error) {
-	tempDir, err := os.MkdirTemp("", "configsync-bundle-*")
+	tempDir, err := fsys.MkdirTemp(m.fs, "", TempBundlePattern)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
 	cleanup := func() {
-		if err := os.RemoveAll(tempDir); err != nil {
+		if err := m.fs.RemoveAll(tempDir); err != nil {
 			fmt.Printf("Warning: failed to clean up temporary directory: %v\n", err)
 		}
 	}
@@ -219,12 +484,19 @@ func (m *Manager) prepareBundleDirectory() (string, func(), error) {
 // copyBundleFiles copies configuration files to the bundle directory
 func (m *Manager) copyBundleFiles(bundle *config.DeploymentBundle, tempDir string) error {
 	filesDir := filepath.Join(tempDir, "files")
-	if err := os.MkdirAll(filesDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(filesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create files directory: %w", err)
 	}
 
+	done := 0
 	for _, appConfig := range bundle.Apps {
-		if err := m.copyAppFiles(appConfig, filesDir); err != nil {
+		if err := m.ctx.Err(); err != nil {
+			return err
+		}
+		err := m.copyAppFiles(appConfig, filesDir)
+		done++
+		m.progress.App("export", appConfig.Name, done, len(bundle.Apps), err)
+		if err != nil {
 			return err
 		}
 	}
@@ -235,7 +507,7 @@ func (m *Manager) copyBundleFiles(bundle *config.DeploymentBundle, tempDir strin
 // copyAppFiles copies files for a specific application
 func (m *Manager) copyAppFiles(appConfig *config.AppConfig, filesDir string) error {
 	appFilesDir := filepath.Join(filesDir, appConfig.Name)
-	if err := os.MkdirAll(appFilesDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(appFilesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create app files directory: %w", err)
 	}
 
@@ -251,12 +523,12 @@ func (m *Manager) copyAppFiles(appConfig *config.AppConfig, filesDir string) err
 		// Create destination path in bundle
 		destPath := filepath.Join(appFilesDir, path.Destination)
 		destDir := filepath.Dir(destPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
+		if err := m.fs.MkdirAll(destDir, 0755); err != nil {
 			return fmt.Errorf("failed to create bundle path directory: %w", err)
 		}
 
-		// Copy file/directory
-		if err := m.copyPath(storePath, destPath); err != nil {
+		// Copy file/directory, leaving out excluded caches and state
+		if err := m.copyPathExcluding(storePath, destPath, appConfig, &path); err != nil {
 			return fmt.Errorf("failed to copy %s: %w", storePath, err)
 		}
 
@@ -269,121 +541,290 @@ func (m *Manager) copyAppFiles(appConfig *config.AppConfig, filesDir string) err
 }
 
 // checkDeploymentConflicts checks for conflicts and returns error if found
-func (m *Manager) checkDeploymentConflicts(bundle *config.DeploymentBundle, configManager *config.Manager, force bool) error {
+func (m *Manager) checkDeploymentConflicts(bundle *config.DeploymentBundle, bundleDir string, configManager *config.Manager, state *DeployState, force bool) error {
 	currentCfg, err := configManager.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load current configuration: %w", err)
 	}
 
-	if !force {
-		conflicts := m.detectConflicts(bundle, currentCfg)
+	// An explicit merge policy resolves conflicts file by file
+	if !force && m.mergePolicy == merge.PolicyReport {
+		detected, err := m.detectConflicts(bundle, bundleDir, currentCfg, pathTranslations(configManager))
+		if err != nil {
+			return err
+		}
+		var conflicts []Conflict
+		for _, conflict := range detected {
+			// Apps deployed from this bundle by an earlier run are not conflicts
+			if appState, exists := state.Apps[conflict.AppName]; exists && appState.Status == AppStateDeployed {
+				continue
+			}
+			conflicts = append(conflicts, conflict)
+		}
 		if len(conflicts) > 0 {
 			fmt.Println("Deployment conflicts detected:")
 			for _, conflict := range conflicts {
 				fmt.Printf("  - %s: %s\n", conflict.AppName, conflict.Message)
 			}
-			return fmt.Errorf("use --force to override conflicts")
+			return fmt.Errorf("use --force to override conflicts, or --prefer-local or --prefer-bundle to merge them")
 		}
 	}
 
 	return nil
 }
 
-// deployAllApplications deploys all applications in the bundle
-func (m *Manager) deployAllApplications(bundle *config.DeploymentBundle, bundleDir string, configManager *config.Manager) ([]string, []string) {
-	var deployed []string
-	var failed []string
+// deployAllApplications deploys all applications in the bundle, skipping those already deployed and unchanged
+func (m *Manager) deployAllApplications(bundle *config.DeploymentBundle, bundleDir, bundleHash string, configManager *config.Manager, state *DeployState) *DeployResult {
+	result := &DeployResult{}
+	deployedAt := time.Now()
 
+	storeManifest, manifestErr := manifest.LoadFS(m.fs, m.storeDir)
+	translations := pathTranslations(configManager)
+
+	appNames := make([]string, 0, len(bundle.Apps))
+	translatedApps := make(map[string]*config.AppConfig, len(bundle.Apps))
 	for appName, bundleAppConfig := range bundle.Apps {
+		appNames = append(appNames, appName)
+		translated := m.translateApp(bundle, bundleAppConfig, translations)
+		keepInstallMetadata(appName, translated, configManager)
+		translatedApps[appName] = translated
+	}
+	sort.Strings(appNames)
+
+	var undo string
+	if m.undo != nil {
+		undo = m.undo(translatedApps)
+	}
+
+	for i, appName := range appNames {
+		if m.ctx.Err() != nil {
+			break
+		}
+		bundleAppConfig := bundle.Apps[appName]
+
+		files, err := hashBundleFiles(m.fs, filepath.Join(bundleDir, "files", appName))
+		if err == nil && manifestErr == nil && state.Unchanged(appName, files, storeManifest, m.storeDir) {
+			if _, getErr := configManager.GetApp(appName); getErr == nil {
+				if m.verbose {
+					fmt.Printf("\n%s is already deployed and unchanged, skipping\n", bundleAppConfig.DisplayName)
+				}
+				m.progress.App("deploy", appName, i+1, len(appNames), nil)
+				result.Unchanged = append(result.Unchanged, bundleAppConfig.DisplayName)
+				continue
+			}
+		}
+
 		if m.verbose {
 			fmt.Printf("\nDeploying %s...\n", bundleAppConfig.DisplayName)
 		}
 
-		if err := m.deployApplication(bundleAppConfig, bundleDir, configManager, appName); err != nil {
+		retry := state.Failed(appName)
+		translated := translatedApps[appName]
+		if err == nil {
+			err = m.deployApplication(translated, bundleDir, bundleHash, deployedAt, configManager, appName)
+		}
+		m.progress.App("deploy", appName, i+1, len(appNames), err)
+		m.recordDeploy(bundle, appName, translated, undo, err)
+
+		state.Record(appName, files, err)
+		if saveErr := state.Save(); saveErr != nil && m.verbose {
+			fmt.Printf("  Warning: %v\n", saveErr)
+		}
+
+		switch {
+		case err != nil:
 			if m.verbose {
 				fmt.Printf("  ✗ Failed to deploy %s: %v\n", bundleAppConfig.DisplayName, err)
 			}
-			failed = append(failed, bundleAppConfig.DisplayName)
-		} else {
+			result.Failed = append(result.Failed, bundleAppConfig.DisplayName)
+		case retry:
+			if m.verbose {
+				fmt.Printf("  ✓ Deployed %s successfully after an earlier failure\n", bundleAppConfig.DisplayName)
+			}
+			result.Retried = append(result.Retried, bundleAppConfig.DisplayName)
+		default:
 			if m.verbose {
 				fmt.Printf("  ✓ Deployed %s successfully\n", bundleAppConfig.DisplayName)
 			}
-			deployed = append(deployed, bundleAppConfig.DisplayName)
+			result.Deployed = append(result.Deployed, bundleAppConfig.DisplayName)
 		}
 	}
 
-	return deployed, failed
+	return result
 }
 
-// deployApplication deploys a single application
-func (m *Manager) deployApplication(bundleAppConfig *config.AppConfig, bundleDir string, configManager *config.Manager, appName string) error {
+// recordDeploy adds a deployed application to the history, with the undo point saved before the deploy
+func (m *Manager) recordDeploy(bundle *config.DeploymentBundle, appName string, appConfig *config.AppConfig, undo string, err error) {
+	entry := history.Entry{Operation: history.Deploy, App: appName, Undo: undo}
+	if bundle.CreatedBy != "" {
+		entry.Details = map[string]string{"bundle_created_by": bundle.CreatedBy}
+	}
+	for _, path := range appConfig.Paths {
+		entry.Paths = append(entry.Paths, path.Source)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if recordErr := m.history.Record(entry); recordErr != nil {
+		fmt.Printf("  Warning: %v\n", recordErr)
+	}
+}
+
+// deployApplication deploys a single application, recording the bundle it came from
+func (m *Manager) deployApplication(bundleAppConfig *config.AppConfig, bundleDir, bundleHash string, deployedAt time.Time, configManager *config.Manager, appName string) error {
+	// Refuse before copying anything, so colliding files never overwrite another app's store files
+	if err := configManager.CheckCollisions(bundleAppConfig); err != nil {
+		return err
+	}
+
+	// Back up the store copies the deploy overwrites as the app's configuration says, or as
+	// the bundle says for an app not configured yet
+	backupBefore := m.autoBackup && bundleAppConfig.BackupBefore
+	local, err := configManager.GetApp(appName)
+	if err == nil {
+		backupBefore = m.autoBackup && local.BackupBefore
+	}
+
 	// Copy files from bundle to store
 	bundleFilesDir := filepath.Join(bundleDir, "files", appName)
 	if m.pathExists(bundleFilesDir) {
-		if err := m.deployAppFiles(bundleAppConfig, bundleFilesDir); err != nil {
+		if err := m.deployAppFiles(bundleAppConfig, bundleFilesDir, backupBefore); err != nil {
 			return fmt.Errorf("failed to deploy files: %w", err)
 		}
 	}
 
+	if err := m.recordDeployment(bundleAppConfig, bundleHash, deployedAt); err != nil {
+		return fmt.Errorf("failed to record deployment: %w", err)
+	}
+
 	// Add/update app configuration
-	if err := configManager.AddApp(bundleAppConfig); err != nil {
+	appConfig := machineAppConfig(bundleAppConfig, local)
+	m.reviewPostSync(appConfig, local)
+	if err := configManager.AddApp(appConfig); err != nil {
 		return fmt.Errorf("failed to add configuration: %w", err)
 	}
 
 	return nil
 }
 
+// machineAppConfig returns the configuration of a bundle app to save on this machine. Whether
+// its paths are synced and backed up describes the machine that exported the bundle, so they
+// keep the state of the app configured here, if any, and are otherwise not synced yet.
+func machineAppConfig(bundleAppConfig, local *config.AppConfig) *config.AppConfig {
+	appConfig := *bundleAppConfig
+	appConfig.LastSynced = time.Time{}
+	if local != nil {
+		appConfig.LastSynced = local.LastSynced
+	}
+
+	appConfig.Paths = make([]config.Path, len(bundleAppConfig.Paths))
+	for i, path := range bundleAppConfig.Paths {
+		path.Synced, path.SyncedAt, path.BackedUp = false, time.Time{}, false
+		if local != nil {
+			for _, localPath := range local.Paths {
+				if localPath.Source == path.Source && localPath.Destination == path.Destination {
+					path.Synced, path.SyncedAt, path.BackedUp = localPath.Synced, localPath.SyncedAt, localPath.BackedUp
+					break
+				}
+			}
+		}
+		appConfig.Paths[i] = path
+	}
+	return &appConfig
+}
+
+// reviewPostSync shows the post-sync commands a bundle app brings and, unless hooks are trusted,
+// keeps the commands configured on this machine instead, since sync would run whatever the
+// bundle's author wrote. Bundles that leave commands out are followed, as that runs nothing.
+func (m *Manager) reviewPostSync(appConfig, local *config.AppConfig) {
+	var localPostSync []string
+	if local != nil {
+		localPostSync = local.PostSync
+	}
+	if len(appConfig.PostSync) == 0 || slices.Equal(appConfig.PostSync, localPostSync) {
+		return
+	}
+
+	if m.trustHooks {
+		fmt.Printf("  %s: adopting post-sync commands from the bundle:\n", appConfig.Name)
+	} else {
+		fmt.Printf("  %s: not adopting post-sync commands from the bundle:\n", appConfig.Name)
+	}
+	for _, command := range appConfig.PostSync {
+		fmt.Printf("    %s\n", command)
+	}
+	if m.trustHooks {
+		return
+	}
+	fmt.Println("    Review them and deploy again with --trust-hooks to run them after syncs")
+	appConfig.PostSync = append([]string(nil), localPostSync...)
+}
+
 // showDeploymentSummary displays the deployment results
-func (m *Manager) showDeploymentSummary(deployed, failed []string) {
+func (m *Manager) showDeploymentSummary(result *DeployResult) {
 	fmt.Println()
-	if len(deployed) > 0 {
-		fmt.Printf("✓ Successfully deployed %d application(s):\n", len(deployed))
-		for _, name := range deployed {
+	if len(result.Deployed) > 0 {
+		fmt.Printf("✓ Successfully deployed %d application(s):\n", len(result.Deployed))
+		for _, name := range result.Deployed {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
-	if len(failed) > 0 {
-		fmt.Printf("\n✗ Failed to deploy %d application(s):\n", len(failed))
-		for _, name := range failed {
+	if len(result.Retried) > 0 {
+		fmt.Printf("✓ Deployed %d previously failed application(s):\n", len(result.Retried))
+		for _, name := range result.Retried {
 			fmt.Printf("  - %s\n", name)
 		}
 	}
 
-	if len(deployed) > 0 {
-		fmt.Println("\nNext step: Run 'configsync sync' to create symlinks")
+	if len(result.Unchanged) > 0 {
+		fmt.Printf("= %d application(s) already deployed and unchanged\n", len(result.Unchanged))
+		if m.verbose {
+			for _, name := range result.Unchanged {
+				fmt.Printf("  - %s\n", name)
+			}
+		}
 	}
-}
-
-func (m *Manager) detectConflicts(bundle *config.DeploymentBundle, currentCfg *config.Config) []Conflict {
-	var conflicts []Conflict
 
-	for appName, bundleApp := range bundle.Apps {
-		if currentApp, exists := currentCfg.Apps[appName]; exists {
-			// Check if versions differ significantly
-			if currentApp.LastSynced.After(bundle.CreatedAt) {
-				conflicts = append(conflicts, Conflict{
-					AppName: appName,
-					Message: fmt.Sprintf("local configuration is newer than bundle (local: %s, bundle: %s)",
-						currentApp.LastSynced.Format("2006-01-02"), bundle.CreatedAt.Format("2006-01-02")),
-				})
-			}
+	if len(result.Failed) > 0 {
+		fmt.Printf("\n✗ Failed to deploy %d application(s):\n", len(result.Failed))
+		for _, name := range result.Failed {
+			fmt.Printf("  - %s\n", name)
+		}
+		fmt.Println("Re-run 'configsync deploy' to retry only the failed applications")
+	}
 
-			// Check if paths have changed
-			if len(currentApp.Paths) != len(bundleApp.Paths) {
-				conflicts = append(conflicts, Conflict{
-					AppName: appName,
-					Message: fmt.Sprintf("path count differs (local: %d, bundle: %d)",
-						len(currentApp.Paths), len(bundleApp.Paths)),
-				})
+	if backups := m.backups.Created(); len(backups) > 0 {
+		fmt.Printf("\nBacked up %d path(s) to %s before overwriting their store copies\n", len(backups), m.backupDir)
+		if m.verbose {
+			for _, info := range backups {
+				fmt.Printf("  - %s: %s\n", info.AppName, info.OriginalPath)
 			}
 		}
 	}
 
-	return conflicts
+	if len(result.Deployed) > 0 || len(result.Retried) > 0 {
+		fmt.Println("\nNext step: Run 'configsync sync' to create symlinks")
+	}
 }
 
-func (m *Manager) deployAppFiles(appConfig *config.AppConfig, bundleFilesDir string) error {
+// deployAppFiles copies an application's bundle files to the store, first backing up the store
+// copies of the paths it overwrites when backupBefore is set
+func (m *Manager) deployAppFiles(appConfig *config.AppConfig, bundleFilesDir string, backupBefore bool) error {
+	// Destinations are joined onto the store, so they must stay inside it
+	if err := config.ValidateApp(appConfig); err != nil {
+		return err
+	}
+
+	// Use the store manifest so files that are already up to date are not copied again
+	storeManifest, err := manifest.LoadFS(m.fs, m.storeDir)
+	if err != nil {
+		return err
+	}
+
+	// Plan every file before writing any, so unresolved conflicts leave the store untouched
+	var updates []*fileUpdate
+	var overwritten []config.Path
 	for _, path := range appConfig.Paths {
 		bundlePath := filepath.Join(bundleFilesDir, path.Destination)
 		if !m.pathExists(bundlePath) {
@@ -393,28 +834,50 @@ func (m *Manager) deployAppFiles(appConfig *config.AppConfig, bundleFilesDir str
 			continue
 		}
 
-		// Copy to store
-		storePath := filepath.Join(m.storeDir, path.Destination)
-		storeDir := filepath.Dir(storePath)
-		if err := os.MkdirAll(storeDir, 0755); err != nil {
-			return fmt.Errorf("failed to create store directory: %w", err)
+		pathUpdates, err := m.planAppFiles(storeManifest, bundlePath, path.Destination)
+		if err != nil {
+			return fmt.Errorf("failed to compare %s with the store: %w", path.Destination, err)
 		}
+		updates = append(updates, pathUpdates...)
+		if m.overwritesStore(pathUpdates) {
+			overwritten = append(overwritten, path)
+		}
+	}
 
-		if err := m.copyPath(bundlePath, storePath); err != nil {
-			return fmt.Errorf("failed to copy to store: %w", err)
+	if err := conflictError(updates); err != nil {
+		return err
+	}
+
+	if backupBefore {
+		for i := range overwritten {
+			storePath := filepath.Join(m.storeDir, overwritten[i].Destination)
+			if err := m.backups.BackupStoreCopy(appConfig.Name, &overwritten[i], storePath, backup.ReasonPreDeploy); err != nil {
+				return fmt.Errorf("failed to back up %s: %w", storePath, err)
+			}
 		}
+	}
 
-		if m.verbose {
-			fmt.Printf("    Copied: %s\n", path.Destination)
+	for _, update := range updates {
+		if err := m.applyFileUpdate(storeManifest, update); err != nil {
+			return fmt.Errorf("failed to copy to store: %w", err)
 		}
 	}
 
-	return nil
+	return storeManifest.Save()
+}
+
+// overwritesStore reports whether deploying files replaces the content of any existing store copy
+func (m *Manager) overwritesStore(updates []*fileUpdate) bool {
+	for _, update := range updates {
+		if (update.action == fileCopy || update.action == fileMerge) && m.pathExists(update.storeFile) {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *Manager) pathExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+	return fsys.Exists(m.fs, path)
 }
 
 func (m *Manager) getUserInfo() string {
@@ -430,71 +893,41 @@ func (m *Manager) getSystemInfo() string {
 }
 
 func (m *Manager) getFileSize(path string) (int64, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return 0, err
-	}
-	return info.Size(), nil
+	return fsutil.SizeFS(m.fs, path)
 }
 
 func (m *Manager) copyPath(src, dst string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
-
-	if srcInfo.IsDir() {
-		return m.copyDir(src, dst)
-	}
-	return m.copyFile(src, dst)
+	return fsops.CopyPath(m.ctx, m.fs, src, dst, nil)
 }
 
-func (m *Manager) copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
+// copyPathExcluding copies a path like copyPath but skips entries matching the path's exclude
+// patterns or the app's ignore rules
+func (m *Manager) copyPathExcluding(src, dst string, appConfig *config.AppConfig, path *config.Path) error {
+	ignored, err := appConfig.IgnoreMatcher(src, m.includeCaches)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if closeErr := srcFile.Close(); closeErr != nil {
-			fmt.Printf("Warning: failed to close source file: %v\n", closeErr)
-		}
-	}()
-
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	if len(path.Exclude) == 0 && ignored.Empty() {
+		return m.copyPath(src, dst)
 	}
-	defer func() { _ = dstFile.Close() }()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return err
-	}
+	return fsops.CopyPath(m.ctx, m.fs, src, dst, func(relPath string, info os.FileInfo) bool {
+		if !path.IsExcluded(relPath) && !ignored.Match(relPath, info.IsDir()) {
+			return false
+		}
+		if m.verbose {
+			fmt.Printf("    Excluding: %s\n", relPath)
+		}
+		return true
+	})
+}
 
-	// Copy permissions
-	srcInfo, statErr := srcFile.Stat()
-	if statErr != nil {
-		return statErr
-	}
-	return os.Chmod(dst, srcInfo.Mode())
+func (m *Manager) copyFile(src, dst string) error {
+	return fsops.CopyFile(m.ctx, m.fs, src, dst)
 }
 
 func (m *Manager) copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		dstPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-		return m.copyFile(path, dstPath)
-	})
+	return fsops.CopyPath(m.ctx, m.fs, src, dst, nil)
 }
 
 func (m *Manager) saveBundleMetadata(bundle *config.DeploymentBundle, path string) error {
@@ -502,7 +935,7 @@ func (m *Manager) saveBundleMetadata(bundle *config.DeploymentBundle, path strin
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return m.fs.WriteFile(path, data, 0644)
 }
 
 // LoadBundleMetadata loads bundle metadata from a file path
@@ -511,7 +944,7 @@ func (m *Manager) LoadBundleMetadata(path string) (*config.DeploymentBundle, err
 }
 
 func (m *Manager) loadBundleMetadata(path string) (*config.DeploymentBundle, error) {
-	data, err := os.ReadFile(path)
+	data, err := m.fs.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -521,6 +954,10 @@ func (m *Manager) loadBundleMetadata(path string) (*config.DeploymentBundle, err
 		return nil, err
 	}
 
+	if err := m.migrateBundle(&bundle); err != nil {
+		return nil, err
+	}
+
 	return &bundle, nil
 }
 
@@ -553,113 +990,13 @@ func (m *Manager) validateBundle(bundle *config.DeploymentBundle, bundleDir stri
 	return nil
 }
 
-func (m *Manager) createTarGz(sourceDir, targetPath string) error {
-	file, err := os.Create(targetPath)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = file.Close() }()
-
-	gzWriter := gzip.NewWriter(file)
-	defer func() { _ = gzWriter.Close() }()
-
-	tarWriter := tar.NewWriter(gzWriter)
-	defer func() { _ = tarWriter.Close() }()
-
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-
-		// Skip the root directory itself
-		if relPath == "." {
-			return nil
-		}
-
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-
-		header.Name = relPath
-
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer func() { _ = file.Close() }()
-
-			_, err = io.Copy(tarWriter, file)
-			return err
-		}
-
-		return nil
-	})
-}
-
+// extractTarGz unpacks a tar bundle of any compression, streaming each file
 func (m *Manager) extractTarGz(sourcePath, targetDir string) error {
-	file, err := os.Open(sourcePath)
+	file, err := m.fs.Open(sourcePath)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = file.Close() }()
 
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = gzReader.Close() }()
-
-	tarReader := tar.NewReader(gzReader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		path := filepath.Join(targetDir, header.Name)
-
-		// Security check: ensure path is within target directory
-		if !strings.HasPrefix(path, filepath.Clean(targetDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid path in archive: %s", header.Name)
-		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(path, os.FileMode(header.Mode)); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-				return err
-			}
-
-			file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-
-			_, err = io.Copy(file, tarReader)
-			_ = file.Close()
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	return tarball.Extract(m.ctx, m.fs, file, targetDir)
 }
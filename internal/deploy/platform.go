@@ -0,0 +1,77 @@
+package deploy
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// Bundle metadata keys describing the system a bundle was exported on
+const (
+	MetadataPlatform = "platform"
+	MetadataHomeDir  = "home_dir"
+)
+
+// Helper methods
+
+// translateApp returns a copy of a bundle application whose source paths are mapped to this system:
+// the exporting user's home directory is replaced with ours, and when the bundle was exported on
+// another platform, known configuration locations are translated (e.g. macOS to XDG paths on Linux).
+// Store destinations are left unchanged so the bundle files are still found.
+func (m *Manager) translateApp(bundle *config.DeploymentBundle, appConfig *config.AppConfig, translations []config.PathTranslation) *config.AppConfig {
+	fromPlatform := bundle.Metadata[MetadataPlatform]
+	fromHome := bundle.Metadata[MetadataHomeDir]
+
+	translated := *appConfig
+	translated.Paths = make([]config.Path, len(appConfig.Paths))
+	for i, path := range appConfig.Paths {
+		if path.Type != config.PathTypeDefaults {
+			source := config.TranslatePath(path.Source, fromHome, m.homeDir, fromPlatform, config.CurrentPlatform, translations)
+			if source != path.Source && m.verbose {
+				fmt.Printf("    Translated: %s -> %s\n", path.Source, source)
+			}
+			path.Source = source
+
+			links := make([]string, len(path.Links))
+			for j, link := range path.Links {
+				links[j] = config.TranslatePath(link, fromHome, m.homeDir, fromPlatform, config.CurrentPlatform, translations)
+			}
+			if len(links) > 0 {
+				path.Links = links
+			}
+			if path.Versions != "" {
+				path.Versions = config.TranslatePath(path.Versions, fromHome, m.homeDir, fromPlatform, config.CurrentPlatform, translations)
+			}
+		}
+		translated.Paths[i] = path
+	}
+
+	return &translated
+}
+
+// keepInstallMetadata replaces the metadata describing the exporting machine's installation of an
+// application with the local installation's, which sync records once the application is configured
+func keepInstallMetadata(appName string, appConfig *config.AppConfig, configManager *config.Manager) {
+	metadata := maps.Clone(appConfig.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	local, err := configManager.GetApp(appName)
+	for _, key := range config.InstallMetadata {
+		delete(metadata, key)
+		if err == nil && local.Metadata[key] != "" {
+			metadata[key] = local.Metadata[key]
+		}
+	}
+	appConfig.Metadata = metadata
+}
+
+// pathTranslations returns the user's path translations followed by the defaults
+func pathTranslations(configManager *config.Manager) []config.PathTranslation {
+	var translations []config.PathTranslation
+	if settings, err := configManager.GetSettings(); err == nil && settings != nil {
+		translations = append(translations, settings.PathTranslations...)
+	}
+	return append(translations, config.DefaultPathTranslations...)
+}
@@ -0,0 +1,80 @@
+package deploy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestTranslateApp(t *testing.T) {
+	platform := config.CurrentPlatform
+	config.CurrentPlatform = config.PlatformLinux
+	defer func() { config.CurrentPlatform = platform }()
+
+	homeDir := t.TempDir()
+	manager := NewManager(homeDir, filepath.Join(homeDir, "store"), filepath.Join(homeDir, "backup"), false)
+
+	app := config.NewAppConfig("vscode", "Visual Studio Code")
+	app.AddPath("/Users/alice/Library/Application Support/Code/User/settings.json", "Library/Application Support/Code/User/settings.json", config.PathTypeFile, false)
+	app.AddPath("/Users/alice/.gitconfig", ".gitconfig", config.PathTypeFile, false)
+	bundle := &config.DeploymentBundle{
+		Apps:     map[string]*config.AppConfig{"vscode": app},
+		Metadata: map[string]string{MetadataPlatform: config.PlatformDarwin, MetadataHomeDir: "/Users/alice"},
+	}
+
+	translated := manager.translateApp(bundle, app, config.DefaultPathTranslations)
+
+	if got := translated.Paths[0].Source; got != filepath.Join(homeDir, ".config", "Code", "User", "settings.json") {
+		t.Errorf("Expected VS Code settings to move to the XDG location, got %s", got)
+	}
+	if got := translated.Paths[1].Source; got != filepath.Join(homeDir, ".gitconfig") {
+		t.Errorf("Expected dotfile to move to this home directory, got %s", got)
+	}
+	if translated.Paths[0].Destination != app.Paths[0].Destination {
+		t.Error("Expected store destination to be unchanged")
+	}
+	if app.Paths[0].Source != "/Users/alice/Library/Application Support/Code/User/settings.json" {
+		t.Error("Expected bundle application to be left unchanged")
+	}
+}
+
+func TestKeepInstallMetadata(t *testing.T) {
+	homeDir := t.TempDir()
+	configManager := config.NewManager(homeDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+	local := config.NewAppConfig("vscode", "Visual Studio Code")
+	local.Metadata[config.MetadataAppVersion] = "1.80.2"
+	if err := configManager.AddApp(local); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	bundleApp := config.NewAppConfig("vscode", "Visual Studio Code")
+	bundleApp.Metadata[config.MetadataAppVersion] = "1.92.0"
+	bundleApp.Metadata[config.MetadataAppPath] = "/Applications/Visual Studio Code.app"
+	bundleApp.Metadata["team"] = "editors"
+	translated := *bundleApp
+	keepInstallMetadata("vscode", &translated, configManager)
+
+	expected := map[string]string{config.MetadataAppVersion: "1.80.2", "team": "editors"}
+	if len(translated.Metadata) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, translated.Metadata)
+	}
+	for key, value := range expected {
+		if translated.Metadata[key] != value {
+			t.Errorf("Expected %s = %q, got %q", key, value, translated.Metadata[key])
+		}
+	}
+	if bundleApp.Metadata[config.MetadataAppVersion] != "1.92.0" {
+		t.Error("Expected bundle application to be left unchanged")
+	}
+
+	newApp := config.NewAppConfig("zed", "Zed")
+	newApp.Metadata[config.MetadataAppVersion] = "0.150"
+	keepInstallMetadata("zed", newApp, configManager)
+	if len(newApp.Metadata) != 0 {
+		t.Errorf("Expected the exporting machine's metadata to be dropped, got %v", newApp.Metadata)
+	}
+}
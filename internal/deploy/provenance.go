@@ -0,0 +1,321 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// ParentRecordFile is the file in the config directory that remembers the last bundle
+// exported or imported on this machine, which becomes the parent of the next export
+const ParentRecordFile = "bundle-parent.yaml"
+
+// MaxBundleHistory is the number of ancestor bundles kept in a bundle's provenance
+const MaxBundleHistory = 50
+
+// ParentRecord identifies a bundle and its archive hash
+type ParentRecord struct {
+	Bundle *config.DeploymentBundle `yaml:"bundle"`
+	Hash   string                   `yaml:"hash"`
+}
+
+// SetVersion sets the configsync version recorded in exported bundles
+func (m *Manager) SetVersion(version string) {
+	m.toolVersion = version
+}
+
+// SetParentBundle uses the given bundle archive as the parent of the next export
+// instead of the last bundle recorded on this machine
+func (m *Manager) SetParentBundle(bundlePath string) {
+	m.parentPath = bundlePath
+}
+
+// RecordParentBundle remembers a bundle as the parent for the next export
+func (m *Manager) RecordParentBundle(configDir string, bundle *config.DeploymentBundle, bundlePath string) error {
+	hash, err := hashBundle(m.fs, bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash bundle: %w", err)
+	}
+
+	data, err := yaml.Marshal(&ParentRecord{Bundle: bundle, Hash: hash})
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle record: %w", err)
+	}
+
+	if err := m.fs.WriteFile(filepath.Join(configDir, ParentRecordFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save bundle record: %w", err)
+	}
+
+	return nil
+}
+
+// LoadParentRecord loads the last bundle recorded on this machine, or nil if there is none
+func LoadParentRecord(configDir string) (*ParentRecord, error) {
+	return loadParentRecord(fsys.OS, configDir)
+}
+
+// loadParentRecord implements LoadParentRecord on a file system
+func loadParentRecord(files fsys.FS, configDir string) (*ParentRecord, error) {
+	data, err := files.ReadFile(filepath.Join(configDir, ParentRecordFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle record: %w", err)
+	}
+
+	var record ParentRecord
+	if err := yaml.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle record: %w", err)
+	}
+	if record.Bundle == nil {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+// ReadBundleArchive reads the metadata of a bundle archive or directory without extracting it,
+// returning the bundle along with the hash identifying it
+func ReadBundleArchive(bundlePath string) (*config.DeploymentBundle, string, error) {
+	return readBundleArchive(fsys.OS, bundlePath)
+}
+
+// readBundleArchive implements ReadBundleArchive on a file system
+func readBundleArchive(files fsys.FS, bundlePath string) (*config.DeploymentBundle, string, error) {
+	hash, err := hashBundle(files, bundlePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to hash bundle: %w", err)
+	}
+
+	data, err := readBundleMetadataFile(files, bundlePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	var bundle config.DeploymentBundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, "", fmt.Errorf("failed to parse bundle metadata: %w", err)
+	}
+	return &bundle, hash, nil
+}
+
+// DiffBundles returns the apps and paths added, removed, or modified in bundle compared to parent
+func DiffBundles(parent, bundle *config.DeploymentBundle) []config.BundleChange {
+	var changes []config.BundleChange
+
+	parentChecksums := map[string]string{}
+	if parent.Provenance != nil {
+		parentChecksums = parent.Provenance.Checksums
+	}
+	checksums := map[string]string{}
+	if bundle.Provenance != nil {
+		checksums = bundle.Provenance.Checksums
+	}
+
+	for appName, appConfig := range bundle.Apps {
+		parentApp, exists := parent.Apps[appName]
+		if !exists {
+			changes = append(changes, config.BundleChange{Kind: config.ChangeAdded, App: appName})
+			continue
+		}
+
+		parentPaths := make(map[string]bool)
+		for _, path := range parentApp.Paths {
+			parentPaths[path.Destination] = true
+		}
+
+		for _, path := range appConfig.Paths {
+			if !parentPaths[path.Destination] {
+				changes = append(changes, config.BundleChange{Kind: config.ChangeAdded, App: appName, Path: path.Destination})
+				continue
+			}
+			delete(parentPaths, path.Destination)
+
+			key := checksumKey(appName, path.Destination)
+			oldSum, hadSum := parentChecksums[key]
+			if hadSum && oldSum != checksums[key] {
+				changes = append(changes, config.BundleChange{Kind: config.ChangeModified, App: appName, Path: path.Destination})
+			}
+		}
+
+		for destination := range parentPaths {
+			changes = append(changes, config.BundleChange{Kind: config.ChangeRemoved, App: appName, Path: destination})
+		}
+	}
+
+	for appName := range parent.Apps {
+		if _, exists := bundle.Apps[appName]; !exists {
+			changes = append(changes, config.BundleChange{Kind: config.ChangeRemoved, App: appName})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].App != changes[j].App {
+			return changes[i].App < changes[j].App
+		}
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// Helper methods
+
+// addProvenance records checksums of the bundle contents and its lineage relative to the parent bundle
+func (m *Manager) addProvenance(bundle *config.DeploymentBundle, checksums map[string]string, configDir string) error {
+	bundle.Provenance = &config.BundleProvenance{
+		Checksums:   checksums,
+		Machine:     m.getSystemInfo(),
+		ToolVersion: m.toolVersion,
+	}
+
+	parent, err := m.loadParent(configDir)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return nil
+	}
+
+	if m.verbose {
+		fmt.Printf("Parent bundle: %s\n", ShortHash(parent.Hash))
+	}
+
+	bundle.Provenance.ParentHash = parent.Hash
+	bundle.Provenance.Changes = DiffBundles(parent.Bundle, bundle)
+	bundle.Provenance.History = lineageOf(parent)
+
+	return nil
+}
+
+// loadParent returns the explicitly set parent bundle or the last one recorded on this machine
+func (m *Manager) loadParent(configDir string) (*ParentRecord, error) {
+	if m.parentPath != "" {
+		bundle, hash, err := readBundleArchive(m.fs, m.parentPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read parent bundle: %w", err)
+		}
+		return &ParentRecord{Bundle: bundle, Hash: hash}, nil
+	}
+	return loadParentRecord(m.fs, configDir)
+}
+
+// lineageOf returns the history of a bundle that has the given parent, newest first
+func lineageOf(parent *ParentRecord) []config.BundleLineage {
+	entry := config.BundleLineage{
+		CreatedAt: parent.Bundle.CreatedAt,
+		Hash:      parent.Hash,
+		CreatedBy: parent.Bundle.CreatedBy,
+	}
+
+	var ancestors []config.BundleLineage
+	if provenance := parent.Bundle.Provenance; provenance != nil {
+		entry.ParentHash = provenance.ParentHash
+		entry.Machine = provenance.Machine
+		entry.ToolVersion = provenance.ToolVersion
+		entry.Changes = provenance.Changes
+		ancestors = provenance.History
+	} else {
+		entry.Machine = parent.Bundle.Metadata["created_on"]
+	}
+
+	history := append([]config.BundleLineage{entry}, ancestors...)
+	if len(history) > MaxBundleHistory {
+		history = history[:MaxBundleHistory]
+	}
+	return history
+}
+
+// bundleChecksums computes a content hash for every path copied into the bundle
+func bundleChecksums(files fsys.FS, bundle *config.DeploymentBundle, filesDir string) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	for appName, appConfig := range bundle.Apps {
+		for _, path := range appConfig.Paths {
+			bundlePath := filepath.Join(filesDir, appName, path.Destination)
+			if _, err := files.Stat(bundlePath); err != nil {
+				continue
+			}
+
+			hash, err := hashPath(files, bundlePath)
+			if err != nil {
+				return nil, err
+			}
+			checksums[checksumKey(appName, path.Destination)] = hash
+		}
+	}
+
+	return checksums, nil
+}
+
+// hashPath hashes a file, or the names and contents of all files and symlinks below a directory
+func hashPath(files fsys.FS, path string) (string, error) {
+	info, err := files.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return manifest.HashFileFS(files, path)
+	}
+
+	hash := sha256.New()
+	err = fsys.Walk(files, path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		fileHash, err := hashEntry(files, filePath, info)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(hash, "%s\x00%s\n", filepath.ToSlash(relPath), fileHash)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashEntry hashes a file's content, or a symlink's target
+func hashEntry(files fsys.FS, path string, info os.FileInfo) (string, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := files.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		return linkHash(target), nil
+	}
+	return manifest.HashFileFS(files, path)
+}
+
+func checksumKey(appName, destination string) string {
+	return appName + "/" + filepath.ToSlash(destination)
+}
+
+// ShortHash abbreviates a bundle hash for display
+func ShortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
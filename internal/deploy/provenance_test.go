@@ -0,0 +1,132 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+func TestDiffBundles(t *testing.T) {
+	parent := &config.DeploymentBundle{
+		Apps: map[string]*config.AppConfig{
+			"git":    {Paths: []config.Path{{Destination: ".gitconfig"}, {Destination: ".gitignore_global"}}},
+			"iterm2": {Paths: []config.Path{{Destination: "Library/Preferences/com.googlecode.iterm2.plist"}}},
+		},
+		Provenance: &config.BundleProvenance{
+			Checksums: map[string]string{
+				"git/.gitconfig":        "aaa",
+				"git/.gitignore_global": "bbb",
+			},
+		},
+	}
+	bundle := &config.DeploymentBundle{
+		Apps: map[string]*config.AppConfig{
+			"git":    {Paths: []config.Path{{Destination: ".gitconfig"}, {Destination: ".gitattributes"}}},
+			"vscode": {Paths: []config.Path{{Destination: "Library/Application Support/Code/User"}}},
+		},
+		Provenance: &config.BundleProvenance{
+			Checksums: map[string]string{
+				"git/.gitconfig":     "changed",
+				"git/.gitattributes": "ccc",
+			},
+		},
+	}
+
+	changes := DiffBundles(parent, bundle)
+
+	expected := []config.BundleChange{
+		{Kind: config.ChangeAdded, App: "git", Path: ".gitattributes"},
+		{Kind: config.ChangeModified, App: "git", Path: ".gitconfig"},
+		{Kind: config.ChangeRemoved, App: "git", Path: ".gitignore_global"},
+		{Kind: config.ChangeRemoved, App: "iterm2"},
+		{Kind: config.ChangeAdded, App: "vscode"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i, change := range changes {
+		if change != expected[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, expected[i], change)
+		}
+	}
+}
+
+func TestExportRecordsProvenanceChain(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+
+	configFile := filepath.Join(storeDir, "test.conf")
+	if err := os.WriteFile(configFile, []byte("version 1"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("/test/source.conf", "test.conf", config.PathTypeFile, false)
+	if err := configManager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false)
+	manager.SetVersion("1.2.3")
+
+	firstPath := filepath.Join(tempDir, "first.tar.gz")
+	if err := manager.ExportBundle(firstPath, nil, configManager); err != nil {
+		t.Fatalf("First export failed: %v", err)
+	}
+
+	first, firstHash, err := ReadBundleArchive(firstPath)
+	if err != nil {
+		t.Fatalf("Failed to read first bundle: %v", err)
+	}
+	if first.Provenance == nil || first.Provenance.ParentHash != "" {
+		t.Fatalf("Expected initial bundle without parent, got %+v", first.Provenance)
+	}
+	if first.Provenance.ToolVersion != "1.2.3" {
+		t.Errorf("Expected version 1.2.3, got %s", first.Provenance.ToolVersion)
+	}
+
+	expectedHash, err := manifest.HashFile(firstPath)
+	if err != nil {
+		t.Fatalf("Failed to hash bundle: %v", err)
+	}
+	if firstHash != expectedHash {
+		t.Errorf("Expected archive hash %s, got %s", expectedHash, firstHash)
+	}
+
+	// Modify the configuration and export again
+	if err := os.WriteFile(configFile, []byte("version 2"), 0644); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+
+	secondPath := filepath.Join(tempDir, "second.tar.gz")
+	if err := manager.ExportBundle(secondPath, nil, configManager); err != nil {
+		t.Fatalf("Second export failed: %v", err)
+	}
+
+	second, _, err := ReadBundleArchive(secondPath)
+	if err != nil {
+		t.Fatalf("Failed to read second bundle: %v", err)
+	}
+
+	provenance := second.Provenance
+	if provenance.ParentHash != firstHash {
+		t.Errorf("Expected parent hash %s, got %s", firstHash, provenance.ParentHash)
+	}
+	if len(provenance.Changes) != 1 || provenance.Changes[0].Kind != config.ChangeModified {
+		t.Errorf("Expected one modified change, got %+v", provenance.Changes)
+	}
+	if len(provenance.History) != 1 || provenance.History[0].Hash != firstHash {
+		t.Errorf("Expected history to contain the first bundle, got %+v", provenance.History)
+	}
+}
@@ -0,0 +1,261 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// Export layouts
+const (
+	LayoutBundle = "bundle" // A bundle for import and deploy (the default)
+	LayoutRepo   = "repo"   // A dotfiles repository for people to browse and publish
+)
+
+// RepoReadme is the manifest at the top of a dotfiles repository export
+const RepoReadme = "README.md"
+
+// repoMarker starts the manifest of a dotfiles repository export, so exporting again recognizes
+// the directory and may replace it
+const repoMarker = "<!-- Written by configsync export --layout repo; regenerated on every export -->"
+
+// RepoExport describes a dotfiles repository written by ExportRepo
+type RepoExport struct {
+	Apps    []string // Applications with at least one path in the repository
+	Paths   int      // Paths written
+	Skipped []string // Paths left out, with the reason
+}
+
+// repoEntry is one path of an application in the repository
+type repoEntry struct {
+	file     string // Relative to the repository, or empty when the path is left out
+	location string // Where the application keeps it, relative to ~ when inside the home directory
+	note     string // Why the path was left out
+}
+
+// ExportRepo writes the store copies of apps, or of every configured application when apps is
+// empty, into repoDir as a conventional dotfiles repository: a folder per application and a
+// README listing where each file belongs. With stow, each folder mirrors the home directory, so
+// GNU Stow can link it into place; paths that are not files in the home directory are left out.
+// Hidden entries in repoDir, such as .git, are kept when a previous export is replaced.
+func (m *Manager) ExportRepo(repoDir string, apps []string, configManager *config.Manager, stow bool) (*RepoExport, error) {
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	bundle, err := m.createDeploymentBundle(cfg, apps)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkRepoDir(repoDir); err != nil {
+		return nil, err
+	}
+
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	tempDir, cleanup, err := m.prepareBundleDirectory()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	result := &RepoExport{}
+	entries := make(map[string][]repoEntry, len(appNames))
+	m.progress.Start("export", len(appNames))
+	for i, appName := range appNames {
+		if err := m.ctx.Err(); err != nil {
+			return nil, err
+		}
+		appEntries, err := m.copyRepoApp(bundle.Apps[appName], tempDir, stow)
+		m.progress.App("export", appName, i+1, len(appNames), err)
+		if err != nil {
+			return nil, err
+		}
+		entries[appName] = appEntries
+		written := false
+		for _, entry := range appEntries {
+			if entry.file == "" {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %s", entry.location, entry.note))
+				continue
+			}
+			written = true
+			result.Paths++
+		}
+		if written {
+			result.Apps = append(result.Apps, appName)
+		}
+	}
+
+	if m.dryRun {
+		fmt.Printf("[DRY RUN] Would write a dotfiles repository with %d application(s) to %s\n", len(result.Apps), repoDir)
+		return result, nil
+	}
+
+	readme := m.repoReadme(bundle.Apps, appNames, entries, stow)
+	if err := m.fs.WriteFile(filepath.Join(tempDir, RepoReadme), readme, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", RepoReadme, err)
+	}
+
+	m.progress.Step("export", "Writing dotfiles repository")
+	if err := m.replaceRepoDir(tempDir, repoDir); err != nil {
+		return nil, fmt.Errorf("failed to write dotfiles repository: %w", err)
+	}
+	m.progress.Finish("export", len(appNames), 0)
+	return result, nil
+}
+
+// copyRepoApp copies the store copies of an application's paths into its folder of the repository
+func (m *Manager) copyRepoApp(appConfig *config.AppConfig, repoDir string, stow bool) ([]repoEntry, error) {
+	var entries []repoEntry
+	for _, path := range appConfig.Paths {
+		entry := repoEntry{location: m.homeRelative(path.Source)}
+		storePath := filepath.Join(m.storeDir, path.Destination)
+		rel := path.Destination
+		switch {
+		case path.IsSecret():
+			entry.note = "kept in " + path.Secret
+		case !m.pathExists(storePath):
+			entry.note = "not in the store"
+		case stow && path.IsExported():
+			entry.note = "exported by a tool rather than a file in the home directory"
+		case stow && !strings.HasPrefix(entry.location, "~/"):
+			entry.note = "outside the home directory"
+		case stow:
+			rel = strings.TrimPrefix(entry.location, "~/")
+		}
+		if entry.note != "" {
+			if m.verbose {
+				fmt.Printf("  Leaving out %s: %s\n", entry.location, entry.note)
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry.file = filepath.ToSlash(filepath.Join(appConfig.Name, rel))
+		if m.dryRun {
+			fmt.Printf("[DRY RUN] Would write %s\n", entry.file)
+		} else {
+			target := filepath.Join(repoDir, appConfig.Name, rel)
+			if err := m.fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+			}
+			if err := m.copyPathExcluding(storePath, target, appConfig, &path); err != nil {
+				return nil, fmt.Errorf("failed to copy %s: %w", storePath, err)
+			}
+			if m.verbose {
+				fmt.Printf("  Added: %s\n", entry.file)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// homeRelative returns a path inside the home directory starting with ~/, and others as they are
+func (m *Manager) homeRelative(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return path
+	}
+	rel, err := filepath.Rel(m.homeDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return "~/" + filepath.ToSlash(rel)
+}
+
+// repoReadme writes the manifest of a dotfiles repository: each application's files and where
+// they belong
+func (m *Manager) repoReadme(apps map[string]*config.AppConfig, appNames []string, entries map[string][]repoEntry, stow bool) []byte {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, repoMarker)
+	fmt.Fprintln(&b, "# Dotfiles")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Configuration of %d application(s), exported with [configsync](https://github.com/dotbrains/configsync) on %s.\n",
+		len(appNames), time.Now().Format("2006-01-02"))
+	fmt.Fprintln(&b)
+	if stow {
+		fmt.Fprintln(&b, "Each folder mirrors the home directory, so [GNU Stow](https://www.gnu.org/software/stow/) can link an")
+		fmt.Fprintln(&b, "application's files into place:")
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "```sh")
+		fmt.Fprintf(&b, "stow --target ~ %s\n", appNames[0])
+		fmt.Fprintln(&b, "```")
+	} else {
+		fmt.Fprintln(&b, "Each application has a folder; the tables below say where each of its files belongs.")
+	}
+
+	for _, appName := range appNames {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "## %s\n\n", apps[appName].DisplayName)
+		fmt.Fprintln(&b, "| File | Location |")
+		fmt.Fprintln(&b, "|------|----------|")
+		for _, entry := range entries[appName] {
+			file := fmt.Sprintf("[%s](<%s>)", markdownEscape(entry.file), entry.file)
+			if entry.file == "" {
+				file = "Not exported: " + entry.note
+			}
+			fmt.Fprintf(&b, "| %s | `%s` |\n", file, entry.location)
+		}
+	}
+	return b.Bytes()
+}
+
+// markdownEscape escapes the characters that would end a table cell or link text
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// checkRepoDir refuses to write into a directory that is not empty and does not hold a
+// previous dotfiles repository export, ignoring hidden entries such as .git
+func (m *Manager) checkRepoDir(repoDir string) error {
+	entries, err := m.fs.ReadDir(repoDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		readme, err := m.fs.ReadFile(filepath.Join(repoDir, RepoReadme))
+		if err == nil && bytes.HasPrefix(readme, []byte(repoMarker)) {
+			return nil
+		}
+		return fmt.Errorf("%s is not empty and does not contain a dotfiles repository export; refusing to overwrite it", repoDir)
+	}
+	return nil
+}
+
+// replaceRepoDir replaces the visible entries of a previous export in repoDir with those of
+// sourceDir, keeping hidden entries such as .git
+func (m *Manager) replaceRepoDir(sourceDir, repoDir string) error {
+	entries, err := m.fs.ReadDir(repoDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := m.fs.RemoveAll(filepath.Join(repoDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove previous export: %w", err)
+		}
+	}
+	if err := m.fs.MkdirAll(repoDir, 0755); err != nil {
+		return err
+	}
+	return m.copyBundleDir(sourceDir, repoDir)
+}
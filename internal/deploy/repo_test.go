@@ -0,0 +1,148 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// setupRepoExport creates a configuration with a file in the home directory, one outside it, and
+// a defaults domain, all with store copies
+func setupRepoExport(t *testing.T) (*Manager, *config.Manager, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	for rel, content := range map[string]string{
+		".gitconfig":    "[user]\n",
+		"etc/gitconfig": "[core]\n",
+		"Library/Preferences/com.apple.dock.plist": "dock",
+	} {
+		path := filepath.Join(storeDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create store dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write store copy: %v", err)
+		}
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath(filepath.Join(tempDir, ".gitconfig"), ".gitconfig", config.PathTypeFile, true)
+	git.AddPath("/etc/gitconfig", "etc/gitconfig", config.PathTypeFile, false)
+	dock := config.NewAppConfig("dock", "Dock")
+	dock.AddPath("~/Library/Preferences/com.apple.dock.plist", "Library/Preferences/com.apple.dock.plist", config.PathTypeDefaults, true)
+	for _, app := range []*config.AppConfig{git, dock} {
+		if err := configManager.AddApp(app); err != nil {
+			t.Fatalf("Failed to add app: %v", err)
+		}
+	}
+
+	return NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false), configManager, tempDir
+}
+
+func TestExportRepo(t *testing.T) {
+	tests := []struct {
+		name        string
+		stow        bool
+		wantFiles   []string
+		wantMissing []string
+		wantSkipped int
+	}{
+		{
+			name:      "folders",
+			wantFiles: []string{"git/.gitconfig", "git/etc/gitconfig", "dock/Library/Preferences/com.apple.dock.plist"},
+		},
+		{
+			name:        "stow",
+			stow:        true,
+			wantFiles:   []string{"git/.gitconfig"},
+			wantMissing: []string{"git/etc", "dock"},
+			wantSkipped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, configManager, tempDir := setupRepoExport(t)
+			repoDir := filepath.Join(tempDir, "dotfiles")
+
+			result, err := manager.ExportRepo(repoDir, nil, configManager, tt.stow)
+			if err != nil {
+				t.Fatalf("ExportRepo failed: %v", err)
+			}
+			for _, rel := range tt.wantFiles {
+				if _, err := os.Stat(filepath.Join(repoDir, filepath.FromSlash(rel))); err != nil {
+					t.Errorf("Expected %s in the repository: %v", rel, err)
+				}
+			}
+			for _, rel := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(repoDir, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be left out", rel)
+				}
+			}
+			if result.Paths != len(tt.wantFiles) || len(result.Skipped) != tt.wantSkipped {
+				t.Errorf("Expected %d paths and %d skipped, got %d and %v", len(tt.wantFiles), tt.wantSkipped, result.Paths, result.Skipped)
+			}
+
+			readme, err := os.ReadFile(filepath.Join(repoDir, RepoReadme))
+			if err != nil {
+				t.Fatalf("Expected a README: %v", err)
+			}
+			for _, want := range []string{"## Git", "[git/.gitconfig](<git/.gitconfig>) | `~/.gitconfig`", "`/etc/gitconfig`"} {
+				if !strings.Contains(string(readme), want) {
+					t.Errorf("Expected the README to contain %q:\n%s", want, readme)
+				}
+			}
+			if tt.stow && !strings.Contains(string(readme), "Not exported: outside the home directory") {
+				t.Errorf("Expected the README to list paths left out:\n%s", readme)
+			}
+		})
+	}
+}
+
+func TestExportRepoReplacesPreviousExport(t *testing.T) {
+	manager, configManager, tempDir := setupRepoExport(t)
+	repoDir := filepath.Join(tempDir, "dotfiles")
+	if _, err := manager.ExportRepo(repoDir, nil, configManager, false); err != nil {
+		t.Fatalf("ExportRepo failed: %v", err)
+	}
+
+	// A git checkout of the repository, with an application removed since
+	if err := os.MkdirAll(filepath.Join(repoDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	if _, err := manager.ExportRepo(repoDir, []string{"git"}, configManager, false); err != nil {
+		t.Fatalf("ExportRepo failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+		t.Errorf("Expected .git to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "dock")); !os.IsNotExist(err) {
+		t.Error("Expected folders from the previous export to be removed")
+	}
+}
+
+func TestExportRepoRefusesUnrelatedDirectory(t *testing.T) {
+	manager, configManager, tempDir := setupRepoExport(t)
+	repoDir := filepath.Join(tempDir, "documents")
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, RepoReadme), []byte("# My notes\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := manager.ExportRepo(repoDir, nil, configManager, false); err == nil {
+		t.Fatal("Expected an error when exporting into an unrelated directory")
+	}
+	if content, _ := os.ReadFile(filepath.Join(repoDir, RepoReadme)); string(content) != "# My notes\n" {
+		t.Errorf("Expected existing files to be left alone, got %q", content)
+	}
+}
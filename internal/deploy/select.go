@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// SelectApps returns a copy of a bundle limited to the named applications, minus the skipped ones.
+// An empty include list selects every application. Names that are not in the bundle are an error,
+// so a typo does not silently deploy less than intended.
+func SelectApps(bundle *config.DeploymentBundle, include, skip []string) (*config.DeploymentBundle, error) {
+	var unknown []string
+	for _, appName := range append(append([]string{}, include...), skip...) {
+		if _, exists := bundle.Apps[appName]; !exists {
+			unknown = append(unknown, appName)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, messages.Error(messages.AppsNotInBundle, messages.Data{"Apps": strings.Join(unknown, ", ")})
+	}
+
+	selected := *bundle
+	selected.Apps = make(map[string]*config.AppConfig)
+
+	if len(include) == 0 {
+		for appName, appConfig := range bundle.Apps {
+			selected.Apps[appName] = appConfig
+		}
+	} else {
+		for _, appName := range include {
+			selected.Apps[appName] = bundle.Apps[appName]
+		}
+	}
+
+	for _, appName := range skip {
+		delete(selected.Apps, appName)
+	}
+
+	return &selected, nil
+}
+
+// HostOverride returns the overrides for a host from the local hosts section, or from the bundle's
+// when the local configuration has none for the host
+func HostOverride(bundle *config.DeploymentBundle, cfg *config.Config, host string) *config.HostOverride {
+	if override := cfg.Host(host); override != nil {
+		return override
+	}
+	return config.FindHost(bundle.Hosts, host)
+}
+
+// SkippedOnHost returns the applications of a bundle that a host's overrides keep off the host, sorted
+func SkippedOnHost(bundle *config.DeploymentBundle, override *config.HostOverride) []string {
+	var skipped []string
+	for appName := range bundle.Apps {
+		if !override.UsesApp(appName) {
+			skipped = append(skipped, appName)
+		}
+	}
+	sort.Strings(skipped)
+	return skipped
+}
@@ -0,0 +1,58 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestSelectApps(t *testing.T) {
+	bundle := &config.DeploymentBundle{
+		Version: BundleFormatVersion,
+		Apps: map[string]*config.AppConfig{
+			"git":    config.NewAppConfig("git", "Git"),
+			"vscode": config.NewAppConfig("vscode", "Visual Studio Code"),
+			"iterm2": config.NewAppConfig("iterm2", "iTerm2"),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		skip     []string
+		expected []string
+	}{
+		{"all", nil, nil, []string{"git", "iterm2", "vscode"}},
+		{"include", []string{"vscode", "git"}, nil, []string{"git", "vscode"}},
+		{"skip", nil, []string{"iterm2"}, []string{"git", "vscode"}},
+		{"include and skip", []string{"git", "iterm2"}, []string{"iterm2"}, []string{"git"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectApps(bundle, tt.include, tt.skip)
+			if err != nil {
+				t.Fatalf("SelectApps failed: %v", err)
+			}
+			if len(selected.Apps) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %d apps", tt.expected, len(selected.Apps))
+			}
+			for _, appName := range tt.expected {
+				if _, exists := selected.Apps[appName]; !exists {
+					t.Errorf("Expected %s to be selected", appName)
+				}
+			}
+		})
+	}
+
+	if len(bundle.Apps) != 3 {
+		t.Error("SelectApps must not modify the original bundle")
+	}
+
+	if _, err := SelectApps(bundle, []string{"firefox"}, nil); err == nil {
+		t.Error("Expected error for application not in bundle")
+	}
+	if _, err := SelectApps(bundle, nil, []string{"firefox"}); err == nil {
+		t.Error("Expected error for skipped application not in bundle")
+	}
+}
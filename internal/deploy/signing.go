@@ -0,0 +1,323 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+const (
+	// SignatureFile is the file in a bundle holding the signature of its metadata
+	SignatureFile = "bundle.sig"
+	// IntegrityAlgorithm is the hash algorithm used for the integrity manifest
+	IntegrityAlgorithm = "sha256"
+	// SignatureAlgorithm is the algorithm used to sign bundles
+	SignatureAlgorithm = "ed25519"
+)
+
+// BundleSignature is the signature of a bundle's metadata. Because the metadata includes
+// the hash of every file, the signature covers the whole bundle.
+type BundleSignature struct {
+	Algorithm string `yaml:"algorithm"`
+	KeyID     string `yaml:"key_id"`
+	Signature string `yaml:"signature"`
+}
+
+// SetSigningKey signs exported bundles with a private key
+func (m *Manager) SetSigningKey(key ed25519.PrivateKey) {
+	m.signingKey = key
+}
+
+// SetVerifyKey requires imported bundles to carry a valid signature from this public key
+func (m *Manager) SetVerifyKey(key ed25519.PublicKey) {
+	m.verifyKey = key
+}
+
+// GenerateSigningKey creates an Ed25519 key pair, writing the private key to privatePath
+// and the public key to publicPath in PEM format
+func GenerateSigningKey(privatePath, publicPath string) error {
+	for _, path := range []string{privatePath, publicPath} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("key file already exists: %s", path)
+		}
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(privatePath), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPrivateKey reads an Ed25519 private key from a PEM file
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 private key", path)
+	}
+
+	return privateKey, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key from a PEM file
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 public key", path)
+	}
+
+	return publicKey, nil
+}
+
+// KeyID returns a short fingerprint identifying a public key
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return ShortHash(hex.EncodeToString(sum[:]))
+}
+
+// Helper methods
+
+// addIntegrity records the hash of every file below the bundle's files directory
+func (m *Manager) addIntegrity(bundle *config.DeploymentBundle, bundleDir string) error {
+	files, err := hashBundleTree(m.fs, bundleDir)
+	if err != nil {
+		return fmt.Errorf("failed to hash bundle contents: %w", err)
+	}
+
+	bundle.Integrity = &config.BundleIntegrity{
+		Algorithm: IntegrityAlgorithm,
+		Files:     files,
+	}
+	return nil
+}
+
+// signBundle writes the signature of the bundle metadata when a signing key is set
+func (m *Manager) signBundle(bundleDir string) error {
+	if m.signingKey == nil {
+		return nil
+	}
+
+	data, err := m.fs.ReadFile(filepath.Join(bundleDir, "bundle.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read bundle metadata: %w", err)
+	}
+
+	out, err := m.signMetadata(data)
+	if err != nil {
+		return err
+	}
+	if err := m.fs.WriteFile(filepath.Join(bundleDir, SignatureFile), out, 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// signMetadata returns the encoded signature of bundle metadata, or nil when no signing key is set
+func (m *Manager) signMetadata(data []byte) ([]byte, error) {
+	if m.signingKey == nil {
+		return nil, nil
+	}
+
+	signature := BundleSignature{
+		Algorithm: SignatureAlgorithm,
+		KeyID:     KeyID(m.signingKey.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(m.signingKey, data)),
+	}
+
+	out, err := yaml.Marshal(signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature: %w", err)
+	}
+
+	if m.verbose {
+		fmt.Printf("Signed bundle with key %s\n", signature.KeyID)
+	}
+	return out, nil
+}
+
+// verifySignature checks the bundle metadata against the verification key, when one is set
+func (m *Manager) verifySignature(bundleDir string) error {
+	signaturePath := filepath.Join(bundleDir, SignatureFile)
+
+	if m.verifyKey == nil {
+		if m.verbose && m.pathExists(signaturePath) {
+			fmt.Println("Bundle is signed; pass --verify <public key> to check the signature")
+		}
+		return nil
+	}
+
+	data, err := m.fs.ReadFile(signaturePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("bundle is not signed")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	var signature BundleSignature
+	if err := yaml.Unmarshal(data, &signature); err != nil {
+		return fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if signature.Algorithm != SignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm: %s", signature.Algorithm)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	metadata, err := m.fs.ReadFile(filepath.Join(bundleDir, "bundle.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read bundle metadata: %w", err)
+	}
+
+	if !ed25519.Verify(m.verifyKey, metadata, raw) {
+		return fmt.Errorf("bundle signature is not valid for key %s (signed with key %s)", KeyID(m.verifyKey), signature.KeyID)
+	}
+
+	if m.verbose {
+		fmt.Printf("Verified bundle signature from key %s\n", signature.KeyID)
+	}
+	return nil
+}
+
+// verifyIntegrity checks that the extracted files match the bundle's integrity manifest exactly
+func (m *Manager) verifyIntegrity(bundle *config.DeploymentBundle, bundleDir string) error {
+	if bundle.Integrity == nil {
+		if m.verifyKey != nil {
+			return fmt.Errorf("bundle has no integrity manifest and cannot be verified")
+		}
+		if m.verbose {
+			fmt.Println("Warning: bundle has no integrity manifest; skipping integrity check")
+		}
+		return nil
+	}
+	if bundle.Integrity.Algorithm != IntegrityAlgorithm {
+		return fmt.Errorf("unsupported integrity algorithm: %s", bundle.Integrity.Algorithm)
+	}
+
+	files, err := hashBundleTree(m.fs, bundleDir)
+	if err != nil {
+		return fmt.Errorf("failed to hash bundle contents: %w", err)
+	}
+
+	var problems []string
+	for relPath, expected := range bundle.Integrity.Files {
+		actual, exists := files[relPath]
+		switch {
+		case !exists:
+			problems = append(problems, "missing "+relPath)
+		case actual != expected:
+			problems = append(problems, "modified "+relPath)
+		}
+	}
+	for relPath := range files {
+		if _, exists := bundle.Integrity.Files[relPath]; !exists {
+			problems = append(problems, "unexpected "+relPath)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("bundle integrity check failed: %v", problems)
+	}
+	return nil
+}
+
+// hashBundleTree hashes every file and symlink below a bundle's files directory, keyed by
+// bundle-relative path
+func hashBundleTree(files fsys.FS, bundleDir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	filesDir := filepath.Join(bundleDir, "files")
+	if _, err := files.Stat(filesDir); os.IsNotExist(err) {
+		return hashes, nil
+	}
+
+	err := fsys.Walk(files, filesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(bundleDir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashEntry(files, path, info)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(relPath)] = hash
+		return nil
+	})
+
+	return hashes, err
+}
+
+// readPEM reads the first PEM block of the expected type from a file
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a PEM %s", path, blockType)
+	}
+	return block, nil
+}
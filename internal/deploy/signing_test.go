@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// exportSignedBundle exports a one-app bundle signed with a freshly generated key
+func exportSignedBundle(t *testing.T) (*Manager, string, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "test.conf"), []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("/test/source.conf", "test.conf", config.PathTypeFile, false)
+	if err := configManager.AddApp(app); err != nil {
+		t.Fatalf("Failed to add app: %v", err)
+	}
+
+	keyPath := filepath.Join(tempDir, "keys", "bundle.key")
+	if err := GenerateSigningKey(keyPath, keyPath+".pub"); err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	privateKey, err := LoadPrivateKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey failed: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false)
+	manager.SetSigningKey(privateKey)
+
+	bundlePath := filepath.Join(tempDir, "bundle.tar.gz")
+	if err := manager.ExportBundle(bundlePath, nil, configManager); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	return manager, bundlePath, keyPath + ".pub"
+}
+
+func TestImportVerifiesSignedBundle(t *testing.T) {
+	manager, bundlePath, publicKeyPath := exportSignedBundle(t)
+
+	publicKey, err := LoadPublicKey(publicKeyPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKey failed: %v", err)
+	}
+	manager.SetVerifyKey(publicKey)
+
+	importDir := filepath.Join(t.TempDir(), "import")
+	bundle, err := manager.ImportBundle(bundlePath, importDir)
+	if err != nil {
+		t.Fatalf("Import of signed bundle failed: %v", err)
+	}
+	if bundle.Integrity == nil || bundle.Integrity.Files["files/testapp/test.conf"] == "" {
+		t.Errorf("Expected integrity manifest for bundle files, got %+v", bundle.Integrity)
+	}
+
+	// A file modified after export no longer matches the manifest
+	if err := os.WriteFile(filepath.Join(importDir, "files", "testapp", "test.conf"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := manager.verifyIntegrity(bundle, importDir); err == nil || !strings.Contains(err.Error(), "modified files/testapp/test.conf") {
+		t.Errorf("Expected modified file to be reported, got %v", err)
+	}
+
+	// Metadata changed after signing no longer matches the signature
+	metadataPath := filepath.Join(importDir, "bundle.yaml")
+	metadata, err := os.ReadFile(metadataPath)
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if err := os.WriteFile(metadataPath, append(metadata, '\n'), 0644); err != nil {
+		t.Fatalf("Failed to modify metadata: %v", err)
+	}
+	if err := manager.verifySignature(importDir); err == nil {
+		t.Error("Expected modified metadata to fail signature verification")
+	}
+}
+
+func TestImportRejectsWrongOrMissingSignature(t *testing.T) {
+	manager, bundlePath, _ := exportSignedBundle(t)
+
+	otherKey := filepath.Join(t.TempDir(), "other.key")
+	if err := GenerateSigningKey(otherKey, otherKey+".pub"); err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	if err := GenerateSigningKey(otherKey, otherKey+".pub"); err == nil {
+		t.Error("Expected GenerateSigningKey to refuse to overwrite keys")
+	}
+	otherPublic, err := LoadPublicKey(otherKey + ".pub")
+	if err != nil {
+		t.Fatalf("LoadPublicKey failed: %v", err)
+	}
+
+	manager.SetVerifyKey(otherPublic)
+	if _, err := manager.ImportBundle(bundlePath, filepath.Join(t.TempDir(), "import")); err == nil {
+		t.Error("Expected bundle signed with another key to be rejected")
+	}
+
+	// An unsigned bundle is rejected when verification is requested
+	importDir := filepath.Join(t.TempDir(), "import")
+	manager.SetVerifyKey(nil)
+	if _, err := manager.ImportBundle(bundlePath, importDir); err != nil {
+		t.Fatalf("Import without verification failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(importDir, SignatureFile)); err != nil {
+		t.Fatalf("Failed to remove signature: %v", err)
+	}
+	manager.SetVerifyKey(otherPublic)
+	if err := manager.verifySignature(importDir); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("Expected unsigned bundle to be rejected, got %v", err)
+	}
+
+	if _, err := LoadPrivateKey(otherKey + ".pub"); err == nil {
+		t.Error("Expected a public key file to be rejected as a private key")
+	}
+}
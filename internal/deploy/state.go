@@ -0,0 +1,175 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// StateFile is the file in an import directory that records what has been deployed from the bundle
+const StateFile = ".deploy-state.yaml"
+
+// Deployment states of an application
+const (
+	AppStateDeployed = "deployed"
+	AppStateFailed   = "failed"
+)
+
+// DeployState tracks the outcome of deploying each application of an imported bundle,
+// so re-running deploy can skip applications that are already deployed and unchanged
+type DeployState struct {
+	Apps map[string]*AppState `yaml:"apps"`
+	path string
+	fs   fsys.FS
+}
+
+// AppState records the last deployment of an application and the hashes of the files it deployed
+type AppState struct {
+	UpdatedAt time.Time         `yaml:"updated_at"`
+	Files     map[string]string `yaml:"files,omitempty"`
+	Status    string            `yaml:"status"`
+	Error     string            `yaml:"error,omitempty"`
+}
+
+// DeployResult summarizes a deployment run
+type DeployResult struct {
+	Deployed  []string
+	Retried   []string
+	Unchanged []string
+	Failed    []string
+}
+
+// LoadDeployState reads the deployment state of an import directory, returning an empty state if none exists
+func LoadDeployState(bundleDir string) (*DeployState, error) {
+	return loadDeployState(fsys.OS, bundleDir)
+}
+
+// loadDeployState implements LoadDeployState on a file system, which the state is saved to
+func loadDeployState(files fsys.FS, bundleDir string) (*DeployState, error) {
+	state := &DeployState{
+		Apps: make(map[string]*AppState),
+		path: filepath.Join(bundleDir, StateFile),
+		fs:   files,
+	}
+
+	data, err := files.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy state: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse deploy state: %w", err)
+	}
+	if state.Apps == nil {
+		state.Apps = make(map[string]*AppState)
+	}
+
+	return state, nil
+}
+
+// Save writes the deployment state
+func (s *DeployState) Save() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deploy state: %w", err)
+	}
+	if err := s.fs.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write deploy state: %w", err)
+	}
+	return nil
+}
+
+// Record stores the outcome of deploying an application
+func (s *DeployState) Record(appName string, files map[string]string, err error) {
+	appState := &AppState{
+		UpdatedAt: time.Now(),
+		Status:    AppStateDeployed,
+		Files:     files,
+	}
+	if err != nil {
+		appState.Status = AppStateFailed
+		appState.Error = err.Error()
+		appState.Files = nil
+	}
+	s.Apps[appName] = appState
+}
+
+// Failed reports whether the last deployment of an application failed
+func (s *DeployState) Failed(appName string) bool {
+	appState, exists := s.Apps[appName]
+	return exists && appState.Status == AppStateFailed
+}
+
+// DeployedCount returns how many applications of a bundle were last deployed successfully
+func (s *DeployState) DeployedCount(bundle *config.DeploymentBundle) int {
+	count := 0
+	for appName := range bundle.Apps {
+		if appState, exists := s.Apps[appName]; exists && appState.Status == AppStateDeployed {
+			count++
+		}
+	}
+	return count
+}
+
+// Unchanged reports whether an application was deployed with exactly these files
+// and the store still holds that content
+func (s *DeployState) Unchanged(appName string, files map[string]string, storeManifest *manifest.Manifest, storeDir string) bool {
+	appState, exists := s.Apps[appName]
+	if !exists || appState.Status != AppStateDeployed || len(appState.Files) != len(files) {
+		return false
+	}
+
+	for relPath, hash := range files {
+		if appState.Files[relPath] != hash {
+			return false
+		}
+		storeHash, err := storeManifest.Hash(filepath.Join(storeDir, filepath.FromSlash(relPath)))
+		if err != nil || storeHash != hash {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Helper methods
+
+// hashBundleFiles returns the content hash of every file an application would deploy, keyed by store-relative path
+func hashBundleFiles(files fsys.FS, bundleFilesDir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if _, err := files.Stat(bundleFilesDir); os.IsNotExist(err) {
+		return hashes, nil
+	}
+
+	err := fsys.Walk(files, bundleFilesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(bundleFilesDir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := manifest.HashFileFS(files, path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(relPath)] = hash
+		return nil
+	})
+
+	return hashes, err
+}
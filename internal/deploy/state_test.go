@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// setupImportedBundle creates an import directory containing one app with one file
+func setupImportedBundle(t *testing.T) (*Manager, *config.Manager, *config.DeploymentBundle, string, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	importDir := filepath.Join(tempDir, "import")
+
+	bundleFile := filepath.Join(importDir, "files", "testapp", "test.conf")
+	if err := os.MkdirAll(filepath.Dir(bundleFile), 0755); err != nil {
+		t.Fatalf("Failed to create bundle directory: %v", err)
+	}
+	if err := os.WriteFile(bundleFile, []byte("bundle content"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+
+	configManager := config.NewManager(tempDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config manager: %v", err)
+	}
+
+	app := config.NewAppConfig("testapp", "Test App")
+	app.AddPath("/test/source.conf", "test.conf", config.PathTypeFile, true)
+	bundle := &config.DeploymentBundle{
+		Version: "1.0",
+		Apps:    map[string]*config.AppConfig{"testapp": app},
+	}
+
+	return NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false), configManager, bundle, importDir, bundleFile
+}
+
+func deployOnce(t *testing.T, manager *Manager, configManager *config.Manager, bundle *config.DeploymentBundle, importDir string) *DeployResult {
+	t.Helper()
+	state, err := LoadDeployState(importDir)
+	if err != nil {
+		t.Fatalf("LoadDeployState failed: %v", err)
+	}
+	return manager.deployAllApplications(bundle, importDir, "abc123", configManager, state)
+}
+
+func TestDeploySkipsUnchangedApps(t *testing.T) {
+	manager, configManager, bundle, importDir, _ := setupImportedBundle(t)
+
+	first := deployOnce(t, manager, configManager, bundle, importDir)
+	if len(first.Deployed) != 1 {
+		t.Fatalf("Expected first run to deploy the app, got %+v", first)
+	}
+
+	second := deployOnce(t, manager, configManager, bundle, importDir)
+	if len(second.Unchanged) != 1 || len(second.Deployed) != 0 {
+		t.Errorf("Expected second run to skip the unchanged app, got %+v", second)
+	}
+
+	// A store file that no longer matches what was deployed is deployed again
+	if err := os.WriteFile(filepath.Join(manager.storeDir, "test.conf"), []byte("edited locally"), 0644); err != nil {
+		t.Fatalf("Failed to modify store file: %v", err)
+	}
+	third := deployOnce(t, manager, configManager, bundle, importDir)
+	if len(third.Deployed) != 1 {
+		t.Errorf("Expected modified app to be redeployed, got %+v", third)
+	}
+}
+
+func TestDeployRetriesFailedApps(t *testing.T) {
+	manager, configManager, bundle, importDir, bundleFile := setupImportedBundle(t)
+
+	// Remove the required file so the first run fails
+	content, err := os.ReadFile(bundleFile)
+	if err != nil {
+		t.Fatalf("Failed to read bundle file: %v", err)
+	}
+	if err := os.Remove(bundleFile); err != nil {
+		t.Fatalf("Failed to remove bundle file: %v", err)
+	}
+
+	first := deployOnce(t, manager, configManager, bundle, importDir)
+	if len(first.Failed) != 1 {
+		t.Fatalf("Expected first run to fail, got %+v", first)
+	}
+
+	state, err := LoadDeployState(importDir)
+	if err != nil {
+		t.Fatalf("LoadDeployState failed: %v", err)
+	}
+	if !state.Failed("testapp") {
+		t.Error("Expected failure to be recorded in deploy state")
+	}
+
+	if err := os.WriteFile(bundleFile, content, 0644); err != nil {
+		t.Fatalf("Failed to restore bundle file: %v", err)
+	}
+
+	second := deployOnce(t, manager, configManager, bundle, importDir)
+	if len(second.Retried) != 1 {
+		t.Errorf("Expected failed app to be retried, got %+v", second)
+	}
+}
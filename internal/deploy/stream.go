@@ -0,0 +1,440 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/brew"
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/tarball"
+)
+
+// Compression algorithms of archive bundles
+const (
+	CompressionNone = tarball.None // Stored as is, for stores of already compressed files
+	CompressionGzip = tarball.Gzip // The default, which every platform can open
+	CompressionZstd = tarball.Zstd // Faster and smaller than gzip, for tar bundles only
+)
+
+// ExportStats describes the last exported bundle
+type ExportStats struct {
+	Files       int    // Files written to the bundle
+	Size        int64  // Total size of the files
+	ArchiveSize int64  // Size of the bundle, including its metadata
+	Compression string // Compression of the archive, empty for dir bundles
+}
+
+// Ratio returns the size of the bundle relative to the size of its files
+func (s ExportStats) Ratio() float64 {
+	if s.Size == 0 {
+		return 1
+	}
+	return float64(s.ArchiveSize) / float64(s.Size)
+}
+
+// SetCompression sets the compression of exported archives and its level, where 0 is the
+// algorithm's default. An empty compression is chosen from the extension of the bundle path.
+func (m *Manager) SetCompression(compression string, level int) {
+	m.compression = compression
+	m.level = level
+}
+
+// ExportStats returns the statistics of the last exported bundle
+func (m *Manager) ExportStats() ExportStats {
+	return m.stats
+}
+
+// CheckCompression returns an error if a compression or level is not supported for a bundle
+// format. An empty compression stands for the default.
+func CheckCompression(format, compression string, level int) error {
+	if format == FormatDir {
+		if compression != "" || level != 0 {
+			return fmt.Errorf("dir bundles are not compressed")
+		}
+		return nil
+	}
+
+	if format == FormatZip && compression == CompressionZstd {
+		return fmt.Errorf("zip bundles cannot use zstd compression (use %s or %s)", CompressionGzip, CompressionNone)
+	}
+	return tarball.CheckLevel(compression, level)
+}
+
+// CompressionFromPath returns the compression suggested by a tar bundle path's extension
+func CompressionFromPath(bundlePath string) string {
+	name := strings.ToLower(bundlePath)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return CompressionNone
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".tzst"):
+		return CompressionZstd
+	default:
+		return CompressionGzip
+	}
+}
+
+// streamBundle writes an archive bundle straight from the store, hashing each file as it is
+// written so nothing is staged on disk. The metadata, which records the hashes, is written
+// after the files, followed by its signature.
+func (m *Manager) streamBundle(bundle *config.DeploymentBundle, bundlePath, format, compression string, configManager *config.Manager) error {
+	file, err := m.fs.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	counter := &countingWriter{w: file}
+	archive, err := newArchiveWriter(m.ctx, counter, format, compression, m.level)
+	if err != nil {
+		return err
+	}
+
+	m.stats = ExportStats{Compression: compression}
+	integrity := make(map[string]string)
+	checksums := make(map[string]string)
+
+	if err := addGeneratedDir(archive, "files"); err != nil {
+		return err
+	}
+	appNames := make([]string, 0, len(bundle.Apps))
+	for appName := range bundle.Apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for i, appName := range appNames {
+		if err := m.ctx.Err(); err != nil {
+			return err
+		}
+		err := m.streamAppFiles(archive, bundle.Apps[appName], integrity, checksums)
+		m.progress.App("export", appName, i+1, len(appNames), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.withBrewfile {
+		if err := addGenerated(archive, brew.BrewfileName, brew.Brewfile(bundle.Packages)); err != nil {
+			return err
+		}
+	}
+
+	// Record lineage, changes since the parent bundle, and the hash of every file
+	if err := m.addProvenance(bundle, checksums, configManager.GetConfigDir()); err != nil {
+		return err
+	}
+	bundle.Integrity = &config.BundleIntegrity{Algorithm: IntegrityAlgorithm, Files: integrity}
+	bundle.Info.TotalSize = m.stats.Size
+
+	metadata, err := yaml.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to save bundle metadata: %w", err)
+	}
+	if err := addGenerated(archive, BundleMetadataFile, metadata); err != nil {
+		return err
+	}
+	signature, err := m.signMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	if signature != nil {
+		if err := addGenerated(archive, SignatureFile, signature); err != nil {
+			return err
+		}
+	}
+
+	m.progress.Step("export", "Finishing bundle archive")
+	if err := archive.close(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	m.stats.ArchiveSize = counter.n
+	return nil
+}
+
+// streamAppFiles adds an application's store files to an archive, recording the hash of each
+// file by bundle path in integrity and of each configured path in checksums
+func (m *Manager) streamAppFiles(archive archiveWriter, appConfig *config.AppConfig, integrity, checksums map[string]string) error {
+	if err := addGeneratedDir(archive, "files/"+appConfig.Name); err != nil {
+		return err
+	}
+
+	for i := range appConfig.Paths {
+		appPath := &appConfig.Paths[i]
+		storePath := filepath.Join(m.storeDir, appPath.Destination)
+		if !m.pathExists(storePath) {
+			if m.verbose {
+				fmt.Printf("  Skipping missing file: %s\n", storePath)
+			}
+			continue
+		}
+
+		ignored, err := appConfig.IgnoreMatcher(storePath, m.includeCaches)
+		if err != nil {
+			return err
+		}
+		skip := func(relPath string, info os.FileInfo) bool {
+			if !appPath.IsExcluded(relPath) && !ignored.Match(relPath, info.IsDir()) {
+				return false
+			}
+			if m.verbose {
+				fmt.Printf("    Excluding: %s\n", relPath)
+			}
+			return true
+		}
+
+		name := "files/" + appConfig.Name + "/" + filepath.ToSlash(appPath.Destination)
+		hashes, err := m.addTree(archive, storePath, name, skip)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", storePath, err)
+		}
+		for _, file := range hashes {
+			integrity[path.Join(name, file.rel)] = file.hash
+		}
+		checksums[checksumKey(appConfig.Name, appPath.Destination)] = treeChecksum(hashes)
+
+		if m.verbose {
+			fmt.Printf("  Added: %s\n", appPath.Destination)
+		}
+	}
+	return nil
+}
+
+// hashedFile is the hash of a file added to an archive, by its slash-separated path relative
+// to the tree it belongs to
+type hashedFile struct {
+	rel  string
+	hash string
+}
+
+// addTree adds a file or directory to an archive under name, following root when it is a
+// symlink and leaving out the entries skip returns true for. It returns the hashes of the files
+// and symlinks it added in walk order.
+func (m *Manager) addTree(archive archiveWriter, root, name string, skip fsops.SkipFunc) ([]hashedFile, error) {
+	var hashes []hashedFile
+
+	if target, err := m.fs.Readlink(root); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(root), target)
+		}
+		root = target
+	}
+
+	err := fsys.Walk(m.fs, root, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := m.ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		if relPath != "." && skip != nil && skip(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entryName := path.Join(name, filepath.ToSlash(relPath))
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := m.fs.Readlink(current)
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, hashedFile{rel: filepath.ToSlash(relPath), hash: linkHash(target)})
+			return archive.add(entryName, info, target, nil)
+		case info.IsDir():
+			return archive.add(entryName, info, "", nil)
+		case info.Mode().IsRegular():
+			file, err := m.fs.Open(current)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			hash := sha256.New()
+			if err := archive.add(entryName, info, "", io.TeeReader(file, hash)); err != nil {
+				return err
+			}
+			hashes = append(hashes, hashedFile{rel: filepath.ToSlash(relPath), hash: hex.EncodeToString(hash.Sum(nil))})
+			m.stats.Files++
+			m.stats.Size += info.Size()
+			return nil
+		default:
+			return nil
+		}
+	})
+
+	// A single file is hashed by its content alone, like hashPath does
+	if err == nil && len(hashes) == 1 && hashes[0].rel == "." {
+		hashes[0].rel = ""
+	}
+	return hashes, err
+}
+
+// treeChecksum combines the hashes of a tree's files the way hashPath does
+func treeChecksum(hashes []hashedFile) string {
+	if len(hashes) == 1 && hashes[0].rel == "" {
+		return hashes[0].hash
+	}
+	hash := sha256.New()
+	for _, file := range hashes {
+		fmt.Fprintf(hash, "%s\x00%s\n", file.rel, file.hash)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// linkHash identifies a symlink in integrity and provenance hashes by its target
+func linkHash(target string) string {
+	sum := sha256.Sum256([]byte("symlink\x00" + target))
+	return hex.EncodeToString(sum[:])
+}
+
+// archiveWriter writes the entries of an archive bundle
+type archiveWriter interface {
+	// add writes an entry with a slash-separated name. content is read for regular files, and
+	// link is the target of a symlink.
+	add(name string, info fs.FileInfo, link string, content io.Reader) error
+	// close finishes the archive, flushing its compression
+	close() error
+}
+
+// newArchiveWriter starts an archive of a format with a compression and level
+func newArchiveWriter(ctx context.Context, w io.Writer, format, compression string, level int) (archiveWriter, error) {
+	if err := CheckCompression(format, compression, level); err != nil {
+		return nil, err
+	}
+
+	if format == FormatZip {
+		zipWriter := zip.NewWriter(w)
+		method := zip.Deflate
+		if compression == CompressionNone {
+			method = zip.Store
+		} else if level != 0 {
+			zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, level)
+			})
+		}
+		return &zipArchive{ctx: ctx, zw: zipWriter, method: method}, nil
+	}
+
+	writer, err := tarball.NewWriter(ctx, w, compression, level)
+	if err != nil {
+		return nil, err
+	}
+	return tarArchive{w: writer}, nil
+}
+
+// tarArchive writes a tar bundle
+type tarArchive struct {
+	w *tarball.Writer
+}
+
+func (a tarArchive) add(name string, info fs.FileInfo, link string, content io.Reader) error {
+	return a.w.Add(name, info, link, content)
+}
+
+func (a tarArchive) close() error {
+	return a.w.Close()
+}
+
+// zipArchive writes a zip bundle. Symlinks are stored with their target as content, as
+// Info-ZIP does.
+type zipArchive struct {
+	ctx    context.Context
+	zw     *zip.Writer
+	method uint16
+}
+
+func (a *zipArchive) add(name string, info fs.FileInfo, link string, content io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	} else {
+		header.Method = a.method
+	}
+
+	writer, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		_, err = io.WriteString(writer, link)
+	case content != nil && info.Mode().IsRegular():
+		_, err = fsutil.CopyContext(a.ctx, writer, content)
+	}
+	return err
+}
+
+func (a *zipArchive) close() error {
+	return a.zw.Close()
+}
+
+// addGenerated adds a file generated for the bundle, such as its metadata, to an archive
+func addGenerated(archive archiveWriter, name string, data []byte) error {
+	info := generatedInfo{name: path.Base(name), size: int64(len(data)), mode: 0644, modTime: time.Now()}
+	return archive.add(name, info, "", bytes.NewReader(data))
+}
+
+// addGeneratedDir adds a directory of the bundle layout to an archive
+func addGeneratedDir(archive archiveWriter, name string) error {
+	info := generatedInfo{name: path.Base(name), mode: fs.ModeDir | 0755, modTime: time.Now()}
+	return archive.add(name, info, "", nil)
+}
+
+// generatedInfo describes a file or directory generated for a bundle
+type generatedInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i generatedInfo) Name() string       { return i.name }
+func (i generatedInfo) Size() int64        { return i.size }
+func (i generatedInfo) Mode() fs.FileMode  { return i.mode }
+func (i generatedInfo) ModTime() time.Time { return i.modTime }
+func (i generatedInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i generatedInfo) Sys() any           { return nil }
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
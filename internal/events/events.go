@@ -0,0 +1,210 @@
+// Package events delivers structured events about configsync operations, such as syncs, added
+// apps, conflicts, and restores, to a webhook or a local unix socket so other automation can react.
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	SyncStarted      = "sync_started"
+	SyncCompleted    = "sync_completed"
+	AppAdded         = "app_added"
+	ConflictDetected = "conflict_detected"
+	RestorePerformed = "restore_performed"
+)
+
+const (
+	// DefaultAttempts is how many times delivery of an event to a sink is attempted
+	DefaultAttempts = 3
+	// DefaultBackoff is the wait before the first retry; it doubles with every further attempt
+	DefaultBackoff = 500 * time.Millisecond
+	// deliveryTimeout bounds a single delivery attempt
+	deliveryTimeout = 5 * time.Second
+	// queueSize is how many events may wait for delivery before Emit blocks
+	queueSize = 256
+)
+
+// Event is one structured event
+type Event struct {
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data,omitempty"`
+	Type string                 `json:"type"`
+	Host string                 `json:"host"`
+	App  string                 `json:"app,omitempty"`
+}
+
+// sink is a destination events are delivered to
+type sink interface {
+	deliver(payload []byte) error
+	String() string
+}
+
+// Emitter delivers events asynchronously in the order they were emitted, retrying failed
+// deliveries. A nil Emitter discards events, so callers need not check whether events are configured.
+type Emitter struct {
+	queue    chan Event
+	done     chan struct{}
+	host     string
+	sinks    []sink
+	failures []string
+	mu       sync.Mutex
+	attempts int
+	backoff  time.Duration
+}
+
+// NewEmitter creates an emitter for the configured webhook URL and unix socket path, either of
+// which may be empty. It returns nil when neither is set.
+func NewEmitter(webhook, socket string) *Emitter {
+	var sinks []sink
+	if webhook != "" {
+		sinks = append(sinks, &webhookSink{url: webhook, client: &http.Client{Timeout: deliveryTimeout}})
+	}
+	if socket != "" {
+		sinks = append(sinks, &socketSink{path: socket})
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return newEmitter(sinks)
+}
+
+// newEmitter starts delivering to the sinks
+func newEmitter(sinks []sink) *Emitter {
+	host, _ := os.Hostname()
+	e := &Emitter{
+		sinks:    sinks,
+		queue:    make(chan Event, queueSize),
+		done:     make(chan struct{}),
+		host:     host,
+		attempts: DefaultAttempts,
+		backoff:  DefaultBackoff,
+	}
+	go e.run()
+	return e
+}
+
+// Emit queues an event for delivery. App and data are optional.
+func (e *Emitter) Emit(eventType, app string, data map[string]interface{}) {
+	if e == nil {
+		return
+	}
+	e.queue <- Event{Time: time.Now(), Type: eventType, Host: e.host, App: app, Data: data}
+}
+
+// Close waits up to the timeout for queued events to be delivered and reports the events that
+// could not be delivered. The emitter must not be used afterwards.
+func (e *Emitter) Close(timeout time.Duration) error {
+	if e == nil {
+		return nil
+	}
+	close(e.queue)
+
+	select {
+	case <-e.done:
+	case <-time.After(timeout):
+		return fmt.Errorf("gave up delivering events after %s", timeout)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.failures) > 0 {
+		return fmt.Errorf("failed to deliver %d event(s): %s", len(e.failures), strings.Join(e.failures, "; "))
+	}
+	return nil
+}
+
+// run delivers queued events until the queue is closed
+func (e *Emitter) run() {
+	defer close(e.done)
+	for event := range e.queue {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			e.fail(event, "encoding", err)
+			continue
+		}
+		for _, s := range e.sinks {
+			if err := e.deliver(s, payload); err != nil {
+				e.fail(event, s.String(), err)
+			}
+		}
+	}
+}
+
+// deliver sends a payload to a sink, retrying with exponential backoff
+func (e *Emitter) deliver(s sink, payload []byte) error {
+	var err error
+	wait := e.backoff
+	for attempt := 1; attempt <= e.attempts; attempt++ {
+		if err = s.deliver(payload); err == nil {
+			return nil
+		}
+		if attempt < e.attempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	return err
+}
+
+// fail records an event that could not be delivered
+func (e *Emitter) fail(event Event, destination string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = append(e.failures, fmt.Sprintf("%s to %s: %v", event.Type, destination, err))
+}
+
+// webhookSink posts each event as JSON
+type webhookSink struct {
+	client *http.Client
+	url    string
+}
+
+func (s *webhookSink) deliver(payload []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) String() string {
+	return s.url
+}
+
+// socketSink writes each event as a line of JSON to a unix socket
+type socketSink struct {
+	path string
+}
+
+func (s *socketSink) deliver(payload []byte) error {
+	conn, err := net.DialTimeout("unix", s.path, deliveryTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(deliveryTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(append(payload, '\n'))
+	return err
+}
+
+func (s *socketSink) String() string {
+	return s.path
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNilEmitter(t *testing.T) {
+	if e := NewEmitter("", ""); e != nil {
+		t.Fatal("Expected no emitter without sinks")
+	}
+	var e *Emitter
+	e.Emit(SyncStarted, "", nil)
+	if err := e.Close(time.Second); err != nil {
+		t.Errorf("Expected a nil emitter to close cleanly, got %v", err)
+	}
+}
+
+func TestWebhookDeliveryInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	e := NewEmitter(server.URL, "")
+	e.Emit(SyncStarted, "", map[string]interface{}{"apps": []string{"vscode"}})
+	e.Emit(AppAdded, "vscode", nil)
+	e.Emit(SyncCompleted, "", nil)
+	if err := e.Close(5 * time.Second); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(received))
+	}
+	for i, eventType := range []string{SyncStarted, AppAdded, SyncCompleted} {
+		if received[i].Type != eventType {
+			t.Errorf("Event %d: expected %s, got %s", i, eventType, received[i].Type)
+		}
+	}
+	if received[1].App != "vscode" || received[0].Data["apps"] == nil || received[0].Time.IsZero() {
+		t.Errorf("Unexpected events: %+v", received)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	e := NewEmitter(server.URL, "")
+	e.backoff = time.Millisecond
+	e.Emit(RestorePerformed, "vscode", nil)
+	if err := e.Close(5 * time.Second); err != nil {
+		t.Fatalf("Expected delivery to succeed on the third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestDeliveryFailure(t *testing.T) {
+	e := NewEmitter("", filepath.Join(t.TempDir(), "missing.sock"))
+	e.backoff = time.Millisecond
+	e.Emit(ConflictDetected, "vscode", nil)
+
+	err := e.Close(5 * time.Second)
+	if err == nil || !strings.Contains(err.Error(), "conflict_detected") {
+		t.Errorf("Expected the undelivered event to be reported, got %v", err)
+	}
+}
+
+func TestSocketDelivery(t *testing.T) {
+	// Unix socket paths are limited in length, so avoid the long test temp directory
+	dir, err := os.MkdirTemp("", "events")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	socket := filepath.Join(dir, "events.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets are not available: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	e := NewEmitter("", socket)
+	e.Emit(AppAdded, "git", nil)
+	if err := e.Close(5 * time.Second); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(<-lines), &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if event.Type != AppAdded || event.App != "git" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+}
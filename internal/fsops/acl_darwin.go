@@ -0,0 +1,35 @@
+package fsops
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// aceLine matches an access control entry as listed by ls -le, such as
+// " 0: group:everyone deny delete"
+var aceLine = regexp.MustCompile(`^\s*\d+:\s+(.+)$`)
+
+// copyACL copies the access control list of src to dst. macOS keeps ACLs out of the extended
+// attributes, so they are read with ls -le and written with chmod -E. Entries chmod rejects are
+// left out rather than failing the copy.
+func copyACL(src, dst string) {
+	out, err := exec.Command("/bin/ls", "-led", src).Output()
+	if err != nil {
+		return
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(out), "\n")[1:] {
+		if match := aceLine.FindStringSubmatch(line); match != nil {
+			entries = append(entries, match[1])
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	cmd := exec.Command("/bin/chmod", "-E", dst)
+	cmd.Stdin = strings.NewReader(strings.Join(entries, "\n") + "\n")
+	_ = cmd.Run()
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !linux
+
+package fsops
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime returns the last access time of a file. Access times are only read on macOS and
+// Linux; elsewhere the modification time stands in for it.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
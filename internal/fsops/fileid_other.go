@@ -0,0 +1,23 @@
+//go:build !darwin && !linux
+
+package fsops
+
+import "io/fs"
+
+// FileID identifies a file on disk, so that hard links to it can be told apart from copies
+type FileID struct {
+	Device uint64
+	Inode  uint64
+}
+
+// HardLinked returns the identity of a regular file that has more than one hard link. Link
+// counts are only read on macOS and Linux, so elsewhere every file counts as a single link.
+func HardLinked(info fs.FileInfo) (FileID, bool) {
+	return FileID{}, false
+}
+
+// LinkCount returns the number of hard links to a regular file, which is only known on macOS
+// and Linux
+func LinkCount(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
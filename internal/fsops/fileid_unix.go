@@ -0,0 +1,32 @@
+//go:build darwin || linux
+
+package fsops
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// FileID identifies a file on disk, so that hard links to it can be told apart from copies
+type FileID struct {
+	Device uint64
+	Inode  uint64
+}
+
+// HardLinked returns the identity of a regular file that has more than one hard link
+func HardLinked(info fs.FileInfo) (FileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || !info.Mode().IsRegular() || stat.Nlink < 2 {
+		return FileID{}, false
+	}
+	return FileID{Device: uint64(stat.Dev), Inode: uint64(stat.Ino)}, true
+}
+
+// LinkCount returns the number of hard links to a regular file
+func LinkCount(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
@@ -0,0 +1,244 @@
+// Package fsops implements the file operations shared by the managers: copying files and
+// directory trees faithfully, and expanding home-relative paths.
+package fsops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// SkipFunc reports whether a directory entry is left out of a copy. rel is the entry's path
+// relative to the directory being copied; skipping a directory skips its contents.
+type SkipFunc func(rel string, info fs.FileInfo) bool
+
+// windowsFolders maps the environment variables that begin Windows paths to the folders they
+// name, relative to the home directory
+var windowsFolders = map[string]string{
+	"%USERPROFILE%":  "",
+	"%APPDATA%":      filepath.Join("AppData", "Roaming"),
+	"%LOCALAPPDATA%": filepath.Join("AppData", "Local"),
+}
+
+// ExpandHome replaces a leading "~/" in path with homeDir. A leading %USERPROFILE%, %APPDATA%, or
+// %LOCALAPPDATA%, followed by / or \, is replaced with homeDir or its AppData folder, so paths
+// copied from Windows documentation work as written.
+func ExpandHome(path, homeDir string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(homeDir, path[2:])
+	}
+	for variable, folder := range windowsFolders {
+		rest, ok := cutPrefixFold(path, variable)
+		if ok && (rest == "" || rest[0] == '/' || rest[0] == '\\') {
+			return filepath.Join(homeDir, folder, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/")))
+		}
+	}
+	return path
+}
+
+// cutPrefixFold is strings.CutPrefix ignoring case, as Windows does for environment variables
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// CopyPath copies a file or directory, following src when it is a symlink. Entries of a
+// directory for which skip returns true are left out; skip may be nil.
+func CopyPath(ctx context.Context, files fsys.FS, src, dst string, skip SkipFunc) error {
+	info, err := files.Stat(src)
+	if err != nil {
+		return err
+	}
+	c := &copier{ctx: ctx, files: files, skip: skip}
+	return c.copyEntry(src, dst, ".", info)
+}
+
+// CopyTree copies a file, symlink, or directory as it is, without following src when it is a
+// symlink. Entries of a directory for which skip returns true are left out; skip may be nil.
+func CopyTree(ctx context.Context, files fsys.FS, src, dst string, skip SkipFunc) error {
+	info, err := files.Lstat(src)
+	if err != nil {
+		return err
+	}
+	c := &copier{ctx: ctx, files: files, skip: skip}
+	return c.copyEntry(src, dst, ".", info)
+}
+
+// CopyFile copies a regular file, creating the parent directory of dst and keeping the
+// permissions, access and modification times, extended attributes, and ACLs of src. An
+// existing symlink at dst is replaced rather than written through, as is an existing file, so
+// files hard-linked to dst keep their contents. A partially written dst is removed on failure.
+func CopyFile(ctx context.Context, files fsys.FS, src, dst string) error {
+	info, err := files.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+	c := &copier{ctx: ctx, files: files}
+	return c.copyFile(src, dst, info)
+}
+
+// CopySymlink recreates the symlink src at dst with the same target, replacing whatever is at dst
+func CopySymlink(files fsys.FS, src, dst string) error {
+	target, err := files.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := prepareDestination(files, dst); err != nil {
+		return err
+	}
+	return files.Symlink(target, dst)
+}
+
+// copier holds the settings of one copy operation
+type copier struct {
+	ctx   context.Context
+	files fsys.FS
+	skip  SkipFunc
+	sync  bool // Flush each copied file to disk before closing it
+}
+
+// copyEntry copies one entry of a tree, recursing into directories. Directory metadata is
+// applied after the contents are copied, so writing the contents neither fails on a read-only
+// directory nor bumps its modification time.
+func (c *copier) copyEntry(src, dst, rel string, info fs.FileInfo) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	if rel != "." && c.skip != nil && c.skip(rel, info) {
+		return nil
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return CopySymlink(c.files, src, dst)
+	case info.IsDir():
+		if err := c.files.MkdirAll(dst, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		entries, err := c.files.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			childInfo, err := c.files.Lstat(filepath.Join(src, entry.Name()))
+			if err != nil {
+				return err
+			}
+			err = c.copyEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()),
+				filepath.Join(rel, entry.Name()), childInfo)
+			if err != nil {
+				return err
+			}
+		}
+		return copyMetadata(c.files, src, dst, info)
+	case info.Mode().IsRegular():
+		return c.copyFile(src, dst, info)
+	default:
+		// Sockets, pipes, and devices have no contents worth copying
+		return nil
+	}
+}
+
+// copyFile copies a regular file described by info
+func (c *copier) copyFile(src, dst string, info fs.FileInfo) error {
+	if err := prepareDestination(c.files, dst); err != nil {
+		return err
+	}
+
+	srcFile, err := c.files.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	dstFile, err := c.files.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.CopyContext(c.ctx, dstFile, srcFile)
+	if err == nil && c.sync {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = c.files.Remove(dst)
+		return err
+	}
+
+	return copyMetadata(c.files, src, dst, info)
+}
+
+// copyMetadata gives dst the extended attributes, ACLs, permissions, and access and
+// modification times of src, described by info. Times come last, since the other changes may
+// touch them, and extended attributes the file system refuses, such as security labels, are
+// left out.
+func copyMetadata(files fsys.FS, src, dst string, info fs.FileInfo) error {
+	if xattrs, ok := files.(fsys.XattrFS); ok {
+		if err := copyXattrs(xattrs, src, dst); err != nil {
+			return err
+		}
+	}
+	if files == fsys.OS {
+		copyACL(src, dst)
+	}
+
+	if err := files.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return files.Chtimes(dst, accessTime(info), info.ModTime())
+}
+
+// copyXattrs copies the extended attributes of src to dst
+func copyXattrs(files fsys.XattrFS, src, dst string) error {
+	attrs, err := files.ListXattrs(src)
+	if err != nil {
+		if unsupportedXattr(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, attr := range attrs {
+		value, err := files.GetXattr(src, attr)
+		if err == nil {
+			err = files.SetXattr(dst, attr, value)
+		}
+		if err != nil && !unsupportedXattr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsupportedXattr reports whether an extended attribute error means the attribute cannot be
+// copied, because the file system lacks support, the attribute is protected, or it vanished
+func unsupportedXattr(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported) || errors.Is(err, fs.ErrPermission) ||
+		errors.Is(err, fs.ErrNotExist)
+}
+
+// prepareDestination creates the parent directory of dst and removes a file or symlink at dst,
+// so the copy replaces it instead of writing through it
+func prepareDestination(files fsys.FS, dst string) error {
+	if err := files.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if info, err := files.Lstat(dst); err == nil && !info.IsDir() {
+		return files.Remove(dst)
+	}
+	return nil
+}
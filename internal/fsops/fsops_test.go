@@ -0,0 +1,303 @@
+package fsops
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+func TestExpandHome(t *testing.T) {
+	home := filepath.Join(string(filepath.Separator), "home", "user")
+	tests := map[string]string{
+		"~/.gitconfig":   filepath.Join(home, ".gitconfig"),
+		"/etc/hosts":     "/etc/hosts",
+		"~other/.vimrc":  "~other/.vimrc",
+		"relative/~/dir": "relative/~/dir",
+
+		`%APPDATA%\Code\User\settings.json`: filepath.Join(home, "AppData", "Roaming", "Code", "User", "settings.json"),
+		"%LocalAppData%/nvim/init.lua":      filepath.Join(home, "AppData", "Local", "nvim", "init.lua"),
+		"%USERPROFILE%/.gitconfig":          filepath.Join(home, ".gitconfig"),
+		"%APPDATA%Code":                     "%APPDATA%Code",
+	}
+	for path, want := range tests {
+		if got := ExpandHome(path, home); got != want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCopyPath(t *testing.T) {
+	for name, newFS := range map[string]func(t *testing.T) (fsys.FS, string){
+		"os": func(t *testing.T) (fsys.FS, string) { return fsys.OS, t.TempDir() },
+		"mem": func(t *testing.T) (fsys.FS, string) {
+			return fsys.NewMem(), filepath.Join(string(filepath.Separator), "tmp")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, root := newFS(t)
+			src := filepath.Join(root, "src")
+			mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			mustWrite(t, files, filepath.Join(src, "settings.json"), "{}", 0600)
+			mustWrite(t, files, filepath.Join(src, "cache", "blob"), "cached", 0644)
+			mustWrite(t, files, filepath.Join(src, "nested", "keys.conf"), "keys", 0644)
+			if err := files.Symlink("settings.json", filepath.Join(src, "current.json")); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+			if err := files.Chtimes(filepath.Join(src, "settings.json"), mtime, mtime); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+			if err := files.Chtimes(filepath.Join(src, "nested"), mtime, mtime); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+
+			dst := filepath.Join(root, "dst")
+			skip := func(rel string, info fs.FileInfo) bool { return rel == "cache" }
+			if err := CopyPath(context.Background(), files, src, dst, skip); err != nil {
+				t.Fatalf("CopyPath failed: %v", err)
+			}
+
+			info, err := files.Stat(filepath.Join(dst, "settings.json"))
+			if err != nil {
+				t.Fatalf("Expected settings.json to be copied: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("Expected file modification time %v, got %v", mtime, info.ModTime())
+			}
+			if info, err := files.Stat(filepath.Join(dst, "nested")); err != nil || !info.ModTime().Equal(mtime) {
+				t.Errorf("Expected directory modification time to be kept, got %v, %v", info, err)
+			}
+
+			if target, err := files.Readlink(filepath.Join(dst, "current.json")); err != nil || target != "settings.json" {
+				t.Errorf("Expected the symlink to be recreated, got %q, %v", target, err)
+			}
+			if fsys.Exists(files, filepath.Join(dst, "cache")) {
+				t.Error("Expected the skipped directory to be left out")
+			}
+		})
+	}
+}
+
+func TestCopyFileKeepsXattrs(t *testing.T) {
+	files := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "tmp")
+	src := filepath.Join(root, "src", "prefs.plist")
+	mustWrite(t, files, src, "plist", 0644)
+	if err := files.SetXattr(src, "com.apple.FinderInfo", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("SetXattr failed: %v", err)
+	}
+	if err := files.SetXattr(filepath.Dir(src), "com.apple.quarantine", []byte("0081;")); err != nil {
+		t.Fatalf("SetXattr failed: %v", err)
+	}
+
+	dst := filepath.Join(root, "dst")
+	if err := CopyPath(context.Background(), files, filepath.Dir(src), dst, nil); err != nil {
+		t.Fatalf("CopyPath failed: %v", err)
+	}
+
+	if value, err := files.GetXattr(filepath.Join(dst, "prefs.plist"), "com.apple.FinderInfo"); err != nil || string(value) != "\x01\x02\x03" {
+		t.Errorf("Expected the file attribute to be copied, got %q, %v", value, err)
+	}
+	if value, err := files.GetXattr(dst, "com.apple.quarantine"); err != nil || string(value) != "0081;" {
+		t.Errorf("Expected the directory attribute to be copied, got %q, %v", value, err)
+	}
+}
+
+func TestCopyFileKeepsOSMetadata(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "app.conf")
+	mustWrite(t, fsys.OS, src, "settings", 0640)
+	atime := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+	mtime := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(src, atime, mtime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	xattrs, _ := fsys.OS.(fsys.XattrFS)
+	hasXattrs := xattrs != nil && xattrs.SetXattr(src, "user.configsync.test", []byte("kept")) == nil
+
+	dst := filepath.Join(root, "copy", "app.conf")
+	if err := CopyFile(context.Background(), fsys.OS, src, dst); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0640 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mode 0640 and modification time %v, got %v and %v", mtime, info.Mode().Perm(), info.ModTime())
+	}
+	if got := accessTime(info); (runtime.GOOS == "linux" || runtime.GOOS == "darwin") && !got.Equal(atime) {
+		t.Errorf("Expected access time %v, got %v", atime, got)
+	}
+	if hasXattrs {
+		if value, err := xattrs.GetXattr(dst, "user.configsync.test"); err != nil || string(value) != "kept" {
+			t.Errorf("Expected the extended attribute to be copied, got %q, %v", value, err)
+		}
+	}
+}
+
+func TestCopyFileReplacesSymlink(t *testing.T) {
+	files := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "tmp")
+	mustWrite(t, files, filepath.Join(root, "new.conf"), "new", 0644)
+	mustWrite(t, files, filepath.Join(root, "elsewhere.conf"), "keep", 0644)
+
+	dst := filepath.Join(root, "app.conf")
+	if err := files.Symlink(filepath.Join(root, "elsewhere.conf"), dst); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if err := CopyFile(context.Background(), files, filepath.Join(root, "new.conf"), dst); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	if fsys.IsSymlink(files, dst) {
+		t.Error("Expected the symlink to be replaced by a file")
+	}
+	if data, _ := files.ReadFile(filepath.Join(root, "elsewhere.conf")); string(data) != "keep" {
+		t.Errorf("Expected the symlink target to be left alone, got %q", data)
+	}
+}
+
+func TestCopyPathCanceled(t *testing.T) {
+	files := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "tmp")
+	mustWrite(t, files, filepath.Join(root, "src", "a.conf"), "a", 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CopyPath(ctx, files, filepath.Join(root, "src"), filepath.Join(root, "dst"), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if fsys.Exists(files, filepath.Join(root, "dst", "a.conf")) {
+		t.Error("Expected nothing to be copied")
+	}
+}
+
+func mustWrite(t *testing.T, files fsys.FS, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := files.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+// crossVolumeFS fails renames out of a directory as if it were on another volume
+type crossVolumeFS struct {
+	fsys.FS
+	volume string
+}
+
+func (c crossVolumeFS) Rename(oldName, newName string) error {
+	if strings.HasPrefix(oldName, c.volume) {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: syscall.EXDEV}
+	}
+	return c.FS.Rename(oldName, newName)
+}
+
+func TestMoveAcrossVolumes(t *testing.T) {
+	mem := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "tmp")
+	src := filepath.Join(root, "home", "App")
+	mustWrite(t, mem, filepath.Join(src, "settings.json"), "{}", 0600)
+	if err := mem.Symlink("settings.json", filepath.Join(src, "current.json")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	files := crossVolumeFS{FS: mem, volume: filepath.Join(root, "home")}
+
+	dst := filepath.Join(root, "external", "store", "App")
+	if err := mem.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := Move(context.Background(), files, src, dst); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	if fsys.Exists(mem, src) {
+		t.Error("Expected the source to be removed after the copy")
+	}
+	if fsys.Exists(mem, dst+movingSuffix) {
+		t.Error("Expected the staging copy to be renamed into place")
+	}
+	if data, err := mem.ReadFile(filepath.Join(dst, "settings.json")); err != nil || string(data) != "{}" {
+		t.Errorf("Expected the file to be moved, got %q, %v", data, err)
+	}
+	if target, err := mem.Readlink(filepath.Join(dst, "current.json")); err != nil || target != "settings.json" {
+		t.Errorf("Expected the symlink to be moved, got %q, %v", target, err)
+	}
+}
+
+func TestMoveKeepsSourceOnFailure(t *testing.T) {
+	mem := fsys.NewMem()
+	root := filepath.Join(string(filepath.Separator), "tmp")
+	src := filepath.Join(root, "home", "app.conf")
+	mustWrite(t, mem, src, "settings", 0644)
+	files := crossVolumeFS{FS: mem, volume: filepath.Join(root, "home")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dst := filepath.Join(root, "external", "app.conf")
+	if err := mem.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := Move(ctx, files, src, dst); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if !fsys.Exists(mem, src) {
+		t.Error("Expected the source to be kept when the copy fails")
+	}
+	if fsys.Exists(mem, dst) || fsys.Exists(mem, dst+movingSuffix) {
+		t.Error("Expected no partial copy to be left behind")
+	}
+}
+
+func TestChownTree(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing owners needs root")
+	}
+	root := filepath.Join(t.TempDir(), "app")
+	nested := filepath.Join(root, "themes", "dark.json")
+	if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(nested, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("/etc/hosts", filepath.Join(root, "hosts")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	if err := ChownTree(fsys.OS, root, 4242, 4343); err != nil {
+		t.Fatalf("ChownTree failed: %v", err)
+	}
+	for _, path := range []string{root, filepath.Dir(nested), nested, filepath.Join(root, "hosts")} {
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if uid, gid, _ := Owner(info); uid != 4242 || gid != 4343 {
+			t.Errorf("Expected %s to belong to 4242:4343, got %d:%d", path, uid, gid)
+		}
+	}
+	if info, _ := os.Stat("/etc/hosts"); info != nil {
+		if uid, _, _ := Owner(info); uid == 4242 {
+			t.Error("Expected the symlink target to be left alone")
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package fsops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// movingSuffix marks the copy of a path being moved across volumes until it is complete
+const movingSuffix = ".configsync-moving"
+
+// Move renames src to dst. When they are on different volumes, where a rename fails with
+// EXDEV, src is copied next to dst with every file flushed to disk, the copy is verified
+// against src and renamed into place, and only then is src removed.
+func Move(ctx context.Context, files fsys.FS, src, dst string) error {
+	err := files.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	return moveAcross(ctx, files, src, dst)
+}
+
+// moveAcross moves src to dst on another volume by copying and deleting it
+func moveAcross(ctx context.Context, files fsys.FS, src, dst string) error {
+	info, err := files.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	staging := dst + movingSuffix
+	if err := files.RemoveAll(staging); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", staging, err)
+	}
+	c := &copier{ctx: ctx, files: files, sync: true}
+	if err := c.copyEntry(src, staging, ".", info); err != nil {
+		_ = files.RemoveAll(staging)
+		return fmt.Errorf("failed to copy %s to another volume: %w", src, err)
+	}
+	if err := verifyCopy(ctx, files, src, staging); err != nil {
+		_ = files.RemoveAll(staging)
+		return fmt.Errorf("copy of %s failed verification: %w", src, err)
+	}
+
+	if err := files.Rename(staging, dst); err != nil {
+		_ = files.RemoveAll(staging)
+		return err
+	}
+	syncDir(files, filepath.Dir(dst))
+
+	if err := files.RemoveAll(src); err != nil {
+		return fmt.Errorf("copied %s to %s but failed to remove it: %w", src, dst, err)
+	}
+	return nil
+}
+
+// SameTree reports whether a and b, files or directories, hold the same entries, symlink
+// targets, and file content
+func SameTree(ctx context.Context, files fsys.FS, a, b string) bool {
+	return verifyCopy(ctx, files, a, b) == nil && verifyCopy(ctx, files, b, a) == nil
+}
+
+// verifyCopy checks that every entry of src exists in dst with the same type, symlink target,
+// and file content
+func verifyCopy(ctx context.Context, files fsys.FS, src, dst string) error {
+	return fsys.Walk(files, src, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		copied := filepath.Join(dst, rel)
+
+		copiedInfo, err := files.Lstat(copied)
+		if err != nil {
+			return err
+		}
+		if copiedInfo.Mode().Type() != info.Mode().Type() {
+			return fmt.Errorf("%s has a different type", copied)
+		}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := files.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if copiedTarget, err := files.Readlink(copied); err != nil || copiedTarget != target {
+				return fmt.Errorf("%s points elsewhere", copied)
+			}
+		case info.Mode().IsRegular():
+			if copiedInfo.Size() != info.Size() {
+				return fmt.Errorf("%s has a different size", copied)
+			}
+			srcHash, err := hashFile(ctx, files, path)
+			if err != nil {
+				return err
+			}
+			copiedHash, err := hashFile(ctx, files, copied)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(srcHash, copiedHash) {
+				return fmt.Errorf("%s has different content", copied)
+			}
+		}
+		return nil
+	})
+}
+
+// hashFile returns the SHA-256 hash of a file's content
+func hashFile(ctx context.Context, files fsys.FS, path string) ([]byte, error) {
+	file, err := files.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := fsutil.CopyContext(ctx, hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// syncDir flushes a directory entry to disk, so a rename into it survives a crash. File
+// systems that cannot sync directories are left as they are.
+func syncDir(files fsys.FS, dir string) {
+	file, err := files.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = file.Sync()
+	_ = file.Close()
+}
@@ -0,0 +1,59 @@
+package fsops
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// ChownTree gives path, and everything below it when it is a directory, to the user uid and
+// group gid, without following symlinks. Entries that already belong to them are left alone, as
+// are file systems without owners.
+func ChownTree(files fsys.FS, path string, uid, gid int) error {
+	owners, ok := files.(fsys.OwnerFS)
+	if !ok {
+		return nil
+	}
+	info, err := files.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if entryUID, entryGID, known := Owner(info); known && (entryUID != uid || entryGID != gid) {
+		if err := owners.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := files.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ChownTree(files, filepath.Join(path, entry.Name()), uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GiveToOwnerOf gives path, and everything below it, to the owner of reference, such as the home
+// directory, when configsync runs as root. Files written under sudo then belong to the user
+// rather than to root. Nothing changes when reference is missing or belongs to root.
+func GiveToOwnerOf(files fsys.FS, path, reference string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	info, err := files.Stat(reference)
+	if err != nil {
+		return nil
+	}
+	uid, gid, ok := Owner(info)
+	if !ok || uid == 0 {
+		return nil
+	}
+	return ChownTree(files, path, uid, gid)
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package fsops
+
+import "io/fs"
+
+// Owner returns the user and group IDs a file belongs to, which are only read on macOS and Linux
+func Owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
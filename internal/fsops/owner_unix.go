@@ -0,0 +1,17 @@
+//go:build darwin || linux
+
+package fsops
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// Owner returns the user and group IDs a file belongs to
+func Owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
@@ -2,7 +2,16 @@
 package fsutil
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/fsys"
 )
 
 // PathExists checks if a path exists on the filesystem
@@ -10,3 +19,109 @@ func PathExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// Size returns the total size in bytes of a file or of all files within a directory
+func Size(path string) (int64, error) {
+	return SizeFS(fsys.OS, path)
+}
+
+// SizeFS is Size for a path on files
+func SizeFS(files fsys.FS, path string) (int64, error) {
+	info, err := files.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var size int64
+	err = fsys.Walk(files, path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// LatestModTime returns the most recent modification time of a file or of any file within a directory
+func LatestModTime(path string) (time.Time, error) {
+	var latest time.Time
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
+
+// FormatSize formats a byte count as a human-readable string (e.g. "1.5 GB")
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseSize parses a byte count such as "512", "100MB", or "1.5 GB". Units are powers of 1024,
+// as in FormatSize, and may be written as K, KB, or KiB.
+func ParseSize(text string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(text))
+	number := strings.TrimRight(value, "KMGTPIB ")
+	unit := strings.TrimSpace(value[len(number):])
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+
+	exp := 0
+	if unit != "" {
+		exp = strings.Index("KMGTP", unit) + 1
+		if exp == 0 || len(unit) != 1 {
+			return 0, fmt.Errorf("invalid size %q: unknown unit", text)
+		}
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid size %q: expected a number such as 500MB", text)
+	}
+	for ; exp > 0; exp-- {
+		amount *= 1024
+	}
+	return int64(amount), nil
+}
+
+// CopyContext copies from src to dst like io.Copy, but stops with the context's error once
+// ctx is canceled or its deadline passes
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, &contextReader{ctx: ctx, reader: src})
+}
+
+// contextReader fails reads once its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
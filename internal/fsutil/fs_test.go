@@ -1,8 +1,12 @@
 package fsutil
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -133,3 +137,108 @@ func BenchmarkPathExistsNonExistent(b *testing.B) {
 		PathExists(nonExistentFile)
 	}
 }
+
+func TestSize(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "a.txt"), make([]byte, 100), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "sub", "b.txt"), make([]byte, 50), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	size, err := Size(tempDir)
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != 150 {
+		t.Errorf("Expected directory size 150, got %d", size)
+	}
+
+	size, err = Size(filepath.Join(tempDir, "a.txt"))
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != 100 {
+		t.Errorf("Expected file size 100, got %d", size)
+	}
+
+	if _, err := Size(filepath.Join(tempDir, "missing")); err == nil {
+		t.Error("Expected error for missing path")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		expected string
+		size     int64
+	}{
+		{"0 B", 0},
+		{"512 B", 512},
+		{"1.0 KB", 1024},
+		{"1.5 MB", 1536 * 1024},
+		{"2.0 GB", 2 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := FormatSize(tt.size); got != tt.expected {
+				t.Errorf("FormatSize(%d) = %s, expected %s", tt.size, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int64
+	}{
+		{"512", 512},
+		{"100 B", 100},
+		{"1K", 1024},
+		{"1.5MB", 1536 * 1024},
+		{"2 GiB", 2 << 30},
+		{"100mb", 100 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseSize(tt.text)
+			if err != nil {
+				t.Fatalf("ParseSize(%q) failed: %v", tt.text, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseSize(%q) = %d, expected %d", tt.text, got, tt.expected)
+			}
+		})
+	}
+
+	for _, text := range []string{"", "MB", "10 XB", "-1", "lots"} {
+		if _, err := ParseSize(text); err == nil {
+			t.Errorf("Expected ParseSize(%q) to fail", text)
+		}
+	}
+}
+
+func TestCopyContext(t *testing.T) {
+	var out bytes.Buffer
+	n, err := CopyContext(context.Background(), &out, strings.NewReader("settings"))
+	if err != nil || n != 8 || out.String() != "settings" {
+		t.Fatalf("CopyContext() = %d, %v, %q", n, err, out.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	if _, err := CopyContext(ctx, &out, strings.NewReader("settings")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing copied after cancellation, got %q", out.String())
+	}
+}
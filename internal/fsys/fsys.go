@@ -0,0 +1,220 @@
+// Package fsys provides the file system abstraction used by the managers, with an implementation
+// backed by the operating system and an in-memory one for tests.
+package fsys
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is an open file of a file system
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Closer
+	Stat() (fs.FileInfo, error)
+	Sync() error
+}
+
+// FS is the set of file system operations the managers use. Paths are native, absolute paths,
+// and errors match those of the os package, so os.IsNotExist and errors.Is work on them.
+type FS interface {
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldName, newName string) error
+	Symlink(target, name string) error
+	Link(oldName, newName string) error
+	Readlink(name string) (string, error)
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// XattrFS is implemented by file systems that support extended attributes. On Linux, ACLs are
+// extended attributes too.
+type XattrFS interface {
+	ListXattrs(name string) ([]string, error)
+	GetXattr(name, attr string) ([]byte, error)
+	SetXattr(name, attr string, value []byte) error
+}
+
+// OwnerFS is implemented by file systems whose files belong to a user and group
+type OwnerFS interface {
+	Lchown(name string, uid, gid int) error
+}
+
+// OS is the file system of the operating system
+var OS FS = osFS{}
+
+// osFS implements FS with the os package
+type osFS struct{}
+
+func (osFS) Open(name string) (File, error) { return os.Open(name) }
+
+func (osFS) Create(name string) (File, error) { return os.Create(name) }
+
+func (osFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+func (osFS) RemoveAll(name string) error { return os.RemoveAll(name) }
+
+func (osFS) Rename(oldName, newName string) error { return os.Rename(oldName, newName) }
+
+func (osFS) Symlink(target, name string) error { return symlink(target, name) }
+
+func (osFS) Link(oldName, newName string) error { return os.Link(oldName, newName) }
+
+func (osFS) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+func (osFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Exists reports whether a path exists, following symlinks
+func Exists(fsys FS, name string) bool {
+	_, err := fsys.Stat(name)
+	return err == nil
+}
+
+// IsSymlink reports whether a path is a symlink
+func IsSymlink(fsys FS, name string) bool {
+	info, err := fsys.Lstat(name)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// Walk walks the tree rooted at root like filepath.Walk, calling fn for every file and directory
+// in lexical order without following symlinks
+func Walk(fsys FS, root string, fn filepath.WalkFunc) error {
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fsys, root, info, fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walk(fsys FS, path string, info fs.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err := fn(path, info, err); err != nil || entries == nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childInfo, err := fsys.Lstat(child)
+		if err != nil {
+			if err := fn(child, nil, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+			continue
+		}
+		if err := walk(fsys, child, childInfo, fn); err != nil {
+			if !childInfo.IsDir() || !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MkdirTemp creates a new directory in dir, or the default temporary directory when dir is
+// empty, named after pattern with its last "*" replaced by a random string
+func MkdirTemp(fsys FS, dir, pattern string) (string, error) {
+	if fsys == OS {
+		return os.MkdirTemp(dir, pattern)
+	}
+
+	var name string
+	err := tempName(fsys, "mkdirtemp", dir, pattern, func(candidate string) error {
+		name = candidate
+		return fsys.MkdirAll(candidate, 0700)
+	})
+	return name, err
+}
+
+// CreateTemp creates a new file for reading and writing in dir, or the default temporary
+// directory when dir is empty, named like MkdirTemp names directories. It returns the file and
+// its name.
+func CreateTemp(fsys FS, dir, pattern string) (File, string, error) {
+	if fsys == OS {
+		file, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, "", err
+		}
+		return file, file.Name(), nil
+	}
+
+	var file File
+	var name string
+	err := tempName(fsys, "createtemp", dir, pattern, func(candidate string) error {
+		var err error
+		file, err = fsys.OpenFile(candidate, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		name = candidate
+		return err
+	})
+	return file, name, err
+}
+
+// tempName calls create with random names made from pattern until one does not exist yet
+func tempName(fsys FS, op, dir, pattern string, create func(name string) error) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		if Exists(fsys, name) {
+			continue
+		}
+		return create(name)
+	}
+	return &fs.PathError{Op: op, Path: filepath.Join(dir, pattern), Err: fs.ErrExist}
+}
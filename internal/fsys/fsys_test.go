@@ -0,0 +1,213 @@
+package fsys
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRoot returns an absolute directory for paths of the in-memory file system
+func testRoot() string {
+	return filepath.Join(string(filepath.Separator), "home", "user")
+}
+
+func TestMemFiles(t *testing.T) {
+	mem := NewMem()
+	root := testRoot()
+
+	if err := mem.WriteFile(filepath.Join(root, "a.conf"), []byte("x"), 0644); !os.IsNotExist(err) {
+		t.Errorf("Expected writing into a missing directory to fail with not exist, got %v", err)
+	}
+
+	if err := mem.MkdirAll(root, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	path := filepath.Join(root, "a.conf")
+	if err := mem.WriteFile(path, []byte("settings"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, err := mem.ReadFile(path)
+	if err != nil || string(data) != "settings" {
+		t.Fatalf("ReadFile() = %q, %v", data, err)
+	}
+	info, err := mem.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != 8 || info.Mode().Perm() != 0600 || info.IsDir() {
+		t.Errorf("Unexpected file info: size %d, mode %v", info.Size(), info.Mode())
+	}
+
+	if err := mem.Remove(root); err == nil {
+		t.Error("Expected removing a non-empty directory to fail")
+	}
+	if err := mem.Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := mem.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected removed file not to exist, got %v", err)
+	}
+}
+
+func TestMemSymlinks(t *testing.T) {
+	mem := NewMem()
+	root := testRoot()
+	store := filepath.Join(root, ".configsync", "store")
+	if err := mem.MkdirAll(store, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := mem.WriteFile(filepath.Join(store, "app.conf"), []byte("stored"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	link := filepath.Join(root, "app.conf")
+	if err := mem.Symlink(filepath.Join(store, "app.conf"), link); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if !IsSymlink(mem, link) {
+		t.Error("Expected Lstat to report a symlink")
+	}
+	if data, err := mem.ReadFile(link); err != nil || string(data) != "stored" {
+		t.Errorf("Expected reads to follow the symlink, got %q, %v", data, err)
+	}
+
+	// Relative links resolve against the link's directory, also in the middle of a path
+	if err := mem.Symlink(filepath.Join(".configsync", "store"), filepath.Join(root, "store")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if !Exists(mem, filepath.Join(root, "store", "app.conf")) {
+		t.Error("Expected a path through a directory symlink to exist")
+	}
+
+	if err := mem.Remove(link); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if !Exists(mem, filepath.Join(store, "app.conf")) {
+		t.Error("Expected removing a symlink to leave its target alone")
+	}
+
+	loop := filepath.Join(root, "loop")
+	if err := mem.Symlink(loop, loop); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if _, err := mem.Stat(loop); err == nil {
+		t.Error("Expected a symlink loop to fail")
+	}
+}
+
+func TestMemRename(t *testing.T) {
+	mem := NewMem()
+	root := testRoot()
+	src := filepath.Join(root, "Library", "App")
+	if err := mem.MkdirAll(filepath.Join(src, "nested"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := mem.WriteFile(filepath.Join(src, "nested", "prefs.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	dst := filepath.Join(root, "store", "App")
+	if err := mem.Rename(src, dst); !os.IsNotExist(err) {
+		t.Errorf("Expected renaming into a missing directory to fail, got %v", err)
+	}
+	if err := mem.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := mem.Rename(src, dst); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if Exists(mem, src) {
+		t.Error("Expected the old path to be gone")
+	}
+	if data, err := mem.ReadFile(filepath.Join(dst, "nested", "prefs.json")); err != nil || string(data) != "{}" {
+		t.Errorf("Expected the directory contents to move, got %q, %v", data, err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	for name, fsys := range map[string]func(t *testing.T) (FS, string){
+		"os": func(t *testing.T) (FS, string) { return OS, t.TempDir() },
+		"mem": func(t *testing.T) (FS, string) {
+			return NewMem(), testRoot()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, root := fsys(t)
+			for _, dir := range []string{"a", filepath.Join("a", "skip"), "b"} {
+				if err := files.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+					t.Fatalf("MkdirAll failed: %v", err)
+				}
+			}
+			for _, file := range []string{filepath.Join("a", "1"), filepath.Join("a", "skip", "2"), filepath.Join("b", "3")} {
+				if err := files.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+			}
+
+			var visited []string
+			err := Walk(files, root, func(path string, info fs.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				if info.IsDir() && info.Name() == "skip" {
+					return filepath.SkipDir
+				}
+				visited = append(visited, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+
+			want := []string{".", "a", "a/1", "b", "b/3"}
+			if !reflect.DeepEqual(visited, want) {
+				t.Errorf("Walk visited %v, want %v", visited, want)
+			}
+		})
+	}
+}
+
+func TestCreateTemp(t *testing.T) {
+	for name, files := range map[string]FS{"os": OS, "mem": NewMem()} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := files.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("MkdirAll failed: %v", err)
+			}
+
+			file, path, err := CreateTemp(files, dir, "settings.*.tmp")
+			if err != nil {
+				t.Fatalf("CreateTemp failed: %v", err)
+			}
+			if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "settings.") || !strings.HasSuffix(path, ".tmp") {
+				t.Errorf("Unexpected temporary file name %s", path)
+			}
+			if _, err := file.Write([]byte("settings")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			file, err = files.Open(path)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer func() { _ = file.Close() }()
+			buf := make([]byte, 4)
+			if n, err := file.ReadAt(buf, 4); n != 4 || string(buf) != "ings" {
+				t.Errorf("ReadAt() = %q, %v", buf[:n], err)
+			}
+			if _, err := file.ReadAt(buf, 6); err != io.EOF {
+				t.Errorf("Expected reading past the end to return io.EOF, got %v", err)
+			}
+		})
+	}
+}
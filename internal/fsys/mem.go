@@ -0,0 +1,630 @@
+package fsys
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinkHops bounds how many symlinks are followed while resolving a path, like ELOOP
+const maxSymlinkHops = 40
+
+// Mem is an in-memory file system for tests. It supports regular files, directories, and
+// symlinks, and starts with only the root directory.
+type Mem struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+// memNode is a file, directory, or symlink of a Mem file system
+type memNode struct {
+	data    []byte
+	target  string
+	mode    fs.FileMode
+	modTime time.Time
+	xattrs  map[string][]byte
+}
+
+// NewMem creates an empty in-memory file system
+func NewMem() *Mem {
+	root := filepath.VolumeName(os.TempDir()) + string(filepath.Separator)
+	return &Mem{nodes: map[string]*memNode{
+		root: {mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+// Open opens a file for reading
+func (m *Mem) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates a file for writing
+func (m *Mem) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens a file with the os.O_* flags. Writes become visible when the file is closed.
+func (m *Mem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	node, exists := m.nodes[path]
+	switch {
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, pathError("open", name, fs.ErrNotExist)
+	case !exists:
+		if err := m.checkParent("open", name, path); err != nil {
+			return nil, err
+		}
+		node = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[path] = node
+	case exists && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, pathError("open", name, fs.ErrExist)
+	case node.mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		return nil, pathError("open", name, errIsDir)
+	}
+
+	file := &memFile{fs: m, path: path, name: filepath.Base(path), node: node, flag: flag}
+	if !node.mode.IsDir() {
+		file.buf = *bytes.NewBuffer(append([]byte(nil), node.data...))
+		if flag&os.O_TRUNC != 0 {
+			file.buf.Reset()
+			file.dirty = true
+		}
+	}
+	return file, nil
+}
+
+// ReadFile returns the contents of a file
+func (m *Mem) ReadFile(name string) ([]byte, error) {
+	file, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return io.ReadAll(file)
+}
+
+// WriteFile writes a file, creating it with perm if needed
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	file, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Stat describes a path, following symlinks
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+// Lstat describes a path without following a final symlink
+func (m *Mem) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *Mem) stat(op, name string, follow bool) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve(op, name, follow)
+	if err != nil {
+		return nil, err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return nil, pathError(op, name, fs.ErrNotExist)
+	}
+	return node.info(filepath.Base(path)), nil
+}
+
+// ReadDir lists a directory sorted by name
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return nil, pathError("readdir", name, fs.ErrNotExist)
+	}
+	if !node.mode.IsDir() {
+		return nil, pathError("readdir", name, errNotDir)
+	}
+
+	var entries []fs.DirEntry
+	for _, child := range m.children(path) {
+		entries = append(entries, fs.FileInfoToDirEntry(m.nodes[child].info(filepath.Base(child))))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// MkdirAll creates a directory and any missing parents
+func (m *Mem) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	if node, exists := m.nodes[path]; exists {
+		if node.mode.IsDir() {
+			return nil
+		}
+		return pathError("mkdir", name, errNotDir)
+	}
+
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if node, exists := m.nodes[dir]; exists {
+			if !node.mode.IsDir() {
+				return pathError("mkdir", name, errNotDir)
+			}
+			break
+		}
+		missing = append(missing, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		m.nodes[missing[i]] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// Remove removes a file, a symlink, or an empty directory
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return pathError("remove", name, fs.ErrNotExist)
+	}
+	if node.mode.IsDir() && len(m.children(path)) > 0 {
+		return pathError("remove", name, errNotEmpty)
+	}
+	delete(m.nodes, path)
+	return nil
+}
+
+// RemoveAll removes a path and everything below it. A missing path is not an error.
+func (m *Mem) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("removeall", name, false)
+	if err != nil {
+		return nil
+	}
+	for existing := range m.nodes {
+		if existing == path || isBelow(existing, path) {
+			delete(m.nodes, existing)
+		}
+	}
+	return nil
+}
+
+// Rename moves a path, replacing a file or empty directory at the new path
+func (m *Mem) Rename(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldPath, err := m.resolve("rename", oldName, false)
+	if err != nil {
+		return err
+	}
+	newPath, err := m.resolve("rename", newName, false)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[oldPath]
+	if !exists {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent("rename", newName, newPath); err != nil {
+		return err
+	}
+	if existing, ok := m.nodes[newPath]; ok && existing.mode.IsDir() && len(m.children(newPath)) > 0 {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: errNotEmpty}
+	}
+	if oldPath == newPath {
+		return nil
+	}
+
+	moved := map[string]*memNode{newPath: node}
+	if node.mode.IsDir() {
+		for existing, child := range m.nodes {
+			if isBelow(existing, oldPath) {
+				moved[newPath+existing[len(oldPath):]] = child
+			}
+		}
+	}
+	for existing := range m.nodes {
+		if existing == oldPath || isBelow(existing, oldPath) {
+			delete(m.nodes, existing)
+		}
+	}
+	for path, moving := range moved {
+		m.nodes[path] = moving
+	}
+	return nil
+}
+
+// Symlink creates name as a symlink to target
+func (m *Mem) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("symlink", name, false)
+	if err != nil {
+		return err
+	}
+	if _, exists := m.nodes[path]; exists {
+		return &os.LinkError{Op: "symlink", Old: target, New: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("symlink", name, path); err != nil {
+		return err
+	}
+	m.nodes[path] = &memNode{target: target, mode: fs.ModeSymlink | 0777, modTime: time.Now()}
+	return nil
+}
+
+// Link creates newName as a hard link to the file oldName, following symlinks in oldName
+func (m *Mem) Link(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldPath, err := m.resolve("link", oldName, true)
+	if err != nil {
+		return err
+	}
+	newPath, err := m.resolve("link", newName, false)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[oldPath]
+	if !exists {
+		return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: fs.ErrPermission}
+	}
+	if _, exists := m.nodes[newPath]; exists {
+		return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("link", newName, newPath); err != nil {
+		return err
+	}
+	m.nodes[newPath] = node
+	return nil
+}
+
+// Readlink returns the target of a symlink
+func (m *Mem) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return "", pathError("readlink", name, fs.ErrNotExist)
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", pathError("readlink", name, fs.ErrInvalid)
+	}
+	return node.target, nil
+}
+
+// Chmod changes the permission bits of a path, following symlinks
+func (m *Mem) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return pathError("chmod", name, fs.ErrNotExist)
+	}
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+// Chtimes changes the modification time of a path, following symlinks. Access times are not
+// tracked.
+func (m *Mem) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return pathError("chtimes", name, fs.ErrNotExist)
+	}
+	node.modTime = mtime
+	return nil
+}
+
+// ListXattrs returns the names of the extended attributes of a path, following symlinks
+func (m *Mem) ListXattrs(name string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]string, 0, len(node.xattrs))
+	for attr := range node.xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	return attrs, nil
+}
+
+// GetXattr returns the value of an extended attribute of a path, following symlinks
+func (m *Mem) GetXattr(name, attr string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	value, exists := node.xattrs[attr]
+	if !exists {
+		return nil, pathError("getxattr", name, fs.ErrNotExist)
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// SetXattr sets an extended attribute of a path, following symlinks
+func (m *Mem) SetXattr(name, attr string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("setxattr", name)
+	if err != nil {
+		return err
+	}
+	if node.xattrs == nil {
+		node.xattrs = map[string][]byte{}
+	}
+	node.xattrs[attr] = append([]byte(nil), value...)
+	return nil
+}
+
+// lookup returns the node of an existing path, following symlinks
+func (m *Mem) lookup(op, name string) (*memNode, error) {
+	path, err := m.resolve(op, name, true)
+	if err != nil {
+		return nil, err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return nil, pathError(op, name, fs.ErrNotExist)
+	}
+	return node, nil
+}
+
+// resolve cleans a path and follows the symlinks in its directories, and in its final element
+// when follow is set. The resolved path need not exist.
+func (m *Mem) resolve(op, name string, follow bool) (string, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", pathError(op, name, err)
+	}
+
+	for hops := 0; ; hops++ {
+		if hops > maxSymlinkHops {
+			return "", pathError(op, name, errLoop)
+		}
+
+		resolved, link, linkAt := m.firstLink(path, follow)
+		if link == nil {
+			return resolved, nil
+		}
+
+		target := link.target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(linkAt), target)
+		}
+		path = filepath.Join(target, resolved[len(linkAt):])
+	}
+}
+
+// firstLink finds the first symlink in a path that has to be followed, returning it and the
+// path up to it. Components below a missing directory are not examined.
+func (m *Mem) firstLink(path string, follow bool) (string, *memNode, string) {
+	volume := filepath.VolumeName(path)
+	parts := strings.Split(strings.TrimPrefix(path[len(volume):], string(filepath.Separator)), string(filepath.Separator))
+
+	current := volume + string(filepath.Separator)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		current = filepath.Join(current, part)
+		node, exists := m.nodes[current]
+		if !exists {
+			return path, nil, ""
+		}
+		last := i == len(parts)-1
+		if node.mode&fs.ModeSymlink != 0 && (!last || follow) {
+			return path, node, current
+		}
+	}
+	return path, nil, ""
+}
+
+// checkParent returns an error unless the parent of a resolved path is an existing directory
+func (m *Mem) checkParent(op, name, path string) error {
+	parent, exists := m.nodes[filepath.Dir(path)]
+	if !exists {
+		return pathError(op, name, fs.ErrNotExist)
+	}
+	if !parent.mode.IsDir() {
+		return pathError(op, name, errNotDir)
+	}
+	return nil
+}
+
+// children returns the paths directly inside a directory
+func (m *Mem) children(dir string) []string {
+	var children []string
+	for path := range m.nodes {
+		if path != dir && filepath.Dir(path) == dir {
+			children = append(children, path)
+		}
+	}
+	return children
+}
+
+// isBelow reports whether path is inside dir
+func isBelow(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (n *memNode) info(name string) fs.FileInfo {
+	size := int64(len(n.data))
+	if n.mode&fs.ModeSymlink != 0 {
+		size = int64(len(n.target))
+	}
+	return &memInfo{name: name, size: size, mode: n.mode, modTime: n.modTime}
+}
+
+// memFile is an open file of a Mem file system
+type memFile struct {
+	fs    *Mem
+	node  *memNode
+	path  string
+	name  string
+	buf   bytes.Buffer
+	flag  int
+	dirty bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.node.mode.IsDir() {
+		return 0, pathError("read", f.path, errIsDir)
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, pathError("read", f.path, fs.ErrPermission)
+	}
+	return f.buf.Read(p)
+}
+
+// ReadAt reads from the contents the file had when it was last closed, like reads of a file
+// written through another handle
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.node.mode.IsDir() {
+		return 0, pathError("read", f.path, errIsDir)
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, pathError("read", f.path, fs.ErrPermission)
+	}
+	if off < 0 {
+		return 0, pathError("read", f.path, fs.ErrInvalid)
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, pathError("write", f.path, fs.ErrPermission)
+	}
+	f.dirty = true
+	return f.buf.Write(p)
+}
+
+func (f *memFile) Close() error {
+	if !f.dirty {
+		return nil
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.node.data = append([]byte(nil), f.buf.Bytes()...)
+	f.node.modTime = time.Now()
+	f.dirty = false
+	return nil
+}
+
+// Sync does nothing, since written data becomes visible when the file is closed
+func (f *memFile) Sync() error { return nil }
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.node.info(f.name), nil
+}
+
+// memInfo describes a path of a Mem file system
+type memInfo struct {
+	modTime time.Time
+	name    string
+	size    int64
+	mode    fs.FileMode
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.modTime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() any           { return nil }
+
+func pathError(op, path string, err error) error {
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
+var (
+	errIsDir    error = syscall.EISDIR
+	errNotDir   error = syscall.ENOTDIR
+	errNotEmpty error = syscall.ENOTEMPTY
+	errLoop     error = syscall.ELOOP
+)
@@ -0,0 +1,7 @@
+//go:build darwin || linux
+
+package fsys
+
+import "os"
+
+func (osFS) Lchown(name string, uid, gid int) error { return os.Lchown(name, uid, gid) }
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fsys
+
+import "os"
+
+// symlink creates name as a symlink to target
+func symlink(target, name string) error { return os.Symlink(target, name) }
@@ -0,0 +1,43 @@
+package fsys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errPrivilegeNotHeld is ERROR_PRIVILEGE_NOT_HELD, returned when symlinks need Developer Mode or
+// an elevated prompt
+const errPrivilegeNotHeld = syscall.Errno(1314)
+
+// symlink creates name as a symlink to target. Without the privilege to create symlinks, a
+// directory is linked with a junction instead, which needs none; files cannot be.
+func symlink(target, name string) error {
+	err := os.Symlink(target, name)
+	if err == nil || !errors.Is(err, errPrivilegeNotHeld) {
+		return err
+	}
+
+	resolved := target
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(filepath.Dir(name), resolved)
+	}
+	if abs, absErr := filepath.Abs(resolved); absErr == nil {
+		resolved = abs
+	}
+	// Junctions only link directories, and need an absolute target
+	info, statErr := os.Stat(resolved)
+	if statErr != nil || !info.IsDir() {
+		return fmt.Errorf("%w; turn on Developer Mode in Settings to let ConfigSync link files", err)
+	}
+
+	output, mklinkErr := exec.Command("cmd", "/c", "mklink", "/J", name, resolved).CombinedOutput()
+	if mklinkErr != nil {
+		return &os.LinkError{Op: "mklink /J", Old: resolved, New: name, Err: fmt.Errorf("%w: %s", mklinkErr, strings.TrimSpace(string(output)))}
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package fsys
+
+import "golang.org/x/sys/unix"
+
+// errNoXattr is the error getxattr returns for a missing attribute
+const errNoXattr = unix.ENODATA
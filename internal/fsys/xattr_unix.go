@@ -0,0 +1,64 @@
+//go:build darwin || linux
+
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func (osFS) ListXattrs(name string) ([]string, error) {
+	buf, err := readXattr(func(dest []byte) (int, error) { return unix.Listxattr(name, dest) })
+	if err != nil {
+		return nil, &fs.PathError{Op: "listxattr", Path: name, Err: err}
+	}
+
+	var attrs []string
+	for _, attr := range strings.Split(string(buf), "\x00") {
+		if attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs, nil
+}
+
+func (osFS) GetXattr(name, attr string) ([]byte, error) {
+	value, err := readXattr(func(dest []byte) (int, error) { return unix.Getxattr(name, attr, dest) })
+	if errors.Is(err, errNoXattr) {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: err}
+	}
+	return value, nil
+}
+
+func (osFS) SetXattr(name, attr string, value []byte) error {
+	if err := unix.Setxattr(name, attr, value, 0); err != nil {
+		return &fs.PathError{Op: "setxattr", Path: name, Err: err}
+	}
+	return nil
+}
+
+// readXattr calls read with a buffer of the size it reports, retrying when the value grows
+// between the two calls
+func readXattr(read func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := read(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
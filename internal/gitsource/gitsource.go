@@ -0,0 +1,190 @@
+// Package gitsource checks out the git repositories that applications' configurations are taken
+// from, such as the nvim directory of a dotfiles repository. Only the directory a path uses is
+// checked out, with a sparse checkout of a partial clone.
+package gitsource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultDir is the directory in the config directory that keeps the checkouts
+const DefaultDir = "sources"
+
+// CommandRunner executes a command in a directory and returns its standard output
+type CommandRunner func(dir, name string, args ...string) ([]byte, error)
+
+// RunCommand runs a command, returning its standard error in the error when it fails
+func RunCommand(dir, name string, args ...string) ([]byte, error) {
+	command := exec.Command(name, args...)
+	command.Dir = dir
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return output, fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// Repo is a git repository, or a directory in one
+type Repo struct {
+	URL    string // What git clones, e.g. https://github.com/me/dotfiles
+	Subdir string // Directory in the repository, with forward slashes; empty for the whole repository
+}
+
+// scpLike matches the user@host:path form of SSH URLs
+var scpLike = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+
+// Parse parses a repository given as a URL with an optional //subdirectory, e.g.
+// github.com/me/dotfiles//nvim. URLs without a scheme are cloned over HTTPS unless they are
+// local paths or SSH URLs such as git@github.com:me/dotfiles.
+func Parse(spec string) (*Repo, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("empty repository")
+	}
+
+	url, subdir := spec, ""
+	start := 0
+	if i := strings.Index(spec, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(spec[start:], "//"); i >= 0 {
+		url, subdir = spec[:start+i], spec[start+i+2:]
+	}
+	if url == "" {
+		return nil, fmt.Errorf("repository %q has no URL", spec)
+	}
+
+	if subdir != "" {
+		subdir = path.Clean(strings.Trim(subdir, "/"))
+		if subdir == "." || subdir == ".." || strings.HasPrefix(subdir, "../") {
+			return nil, fmt.Errorf("directory %q of repository %q must be inside the repository", subdir, spec)
+		}
+	}
+
+	isLocal := strings.HasPrefix(url, "/") || strings.HasPrefix(url, "~") || strings.HasPrefix(url, ".")
+	if !strings.Contains(url, "://") && !isLocal && !scpLike.MatchString(url) {
+		url = "https://" + url
+	}
+	return &Repo{URL: url, Subdir: subdir}, nil
+}
+
+// String returns the repository as Parse accepts it
+func (r *Repo) String() string {
+	if r.Subdir == "" {
+		return r.URL
+	}
+	return r.URL + "//" + r.Subdir
+}
+
+// Manager keeps checkouts of repositories in a directory
+type Manager struct {
+	run CommandRunner
+	dir string
+}
+
+// NewManager creates a manager keeping checkouts in dir, which runs git through run
+func NewManager(dir string, run CommandRunner) *Manager {
+	return &Manager{dir: dir, run: run}
+}
+
+// CheckoutDir returns the directory a repository is checked out to at a ref, where an empty ref
+// is the default branch. Each directory of a repository used by a path has its own checkout.
+func (m *Manager) CheckoutDir(repo *Repo, ref string) string {
+	sum := sha256.Sum256([]byte(repo.URL + "\x00" + repo.Subdir + "\x00" + ref))
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(repo.URL, "/")), ".git")
+	if repo.Subdir != "" {
+		name += "-" + path.Base(repo.Subdir)
+	}
+	return filepath.Join(m.dir, name+"-"+hex.EncodeToString(sum[:])[:12])
+}
+
+// Checkout clones a repository at a ref unless it is checked out already, and returns the
+// directory of the checkout holding the repository's directory
+func (m *Manager) Checkout(repo *Repo, ref string) (string, error) {
+	dir := m.CheckoutDir(repo, ref)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return filepath.Join(dir, filepath.FromSlash(repo.Subdir)), nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", m.dir, err)
+	}
+	// A checkout left behind by a failed clone is started over
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+
+	args := []string{"clone", "--quiet", "--filter=blob:none"}
+	if repo.Subdir != "" {
+		args = append(args, "--sparse")
+	}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	if _, err := m.run(m.dir, "git", append(args, repo.URL, dir)...); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to clone %s: %w", repo.URL, err)
+	}
+
+	if repo.Subdir != "" {
+		if _, err := m.run(dir, "git", "sparse-checkout", "set", repo.Subdir); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to check out %s: %w", repo, err)
+		}
+	}
+
+	subdir := filepath.Join(dir, filepath.FromSlash(repo.Subdir))
+	if info, err := os.Stat(subdir); err != nil || !info.IsDir() {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("%s has no directory %s", repo.URL, repo.Subdir)
+	}
+	return subdir, nil
+}
+
+// Fetch downloads the upstream changes of a checked out repository without applying them, and
+// returns how many commits the checkout is behind
+func (m *Manager) Fetch(repo *Repo, ref string) (int, error) {
+	dir := m.CheckoutDir(repo, ref)
+	if _, err := m.run(dir, "git", "fetch", "--quiet"); err != nil {
+		return 0, fmt.Errorf("failed to fetch %s: %w", repo.URL, err)
+	}
+	output, err := m.run(dir, "git", "rev-list", "--count", "HEAD..@{upstream}")
+	if err != nil {
+		// Tags have no upstream to fall behind
+		return 0, nil
+	}
+	behind, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output from git rev-list: %q", output)
+	}
+	return behind, nil
+}
+
+// Pull applies the upstream changes fetched by Fetch. Checkouts are never changed locally, so
+// the changes always fast-forward.
+func (m *Manager) Pull(repo *Repo, ref string) error {
+	if _, err := m.run(m.CheckoutDir(repo, ref), "git", "merge", "--quiet", "--ff-only", "@{upstream}"); err != nil {
+		return fmt.Errorf("failed to update %s: %w", repo.URL, err)
+	}
+	return nil
+}
+
+// Revision returns the abbreviated commit a repository is checked out at
+func (m *Manager) Revision(repo *Repo, ref string) (string, error) {
+	output, err := m.run(m.CheckoutDir(repo, ref), "git", "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the revision of %s: %w", repo.URL, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,107 @@
+package gitsource
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec   string
+		url    string
+		subdir string
+	}{
+		{"github.com/me/dotfiles//nvim", "https://github.com/me/dotfiles", "nvim"},
+		{"https://github.com/me/dotfiles.git//config/nvim/", "https://github.com/me/dotfiles.git", "config/nvim"},
+		{"git@github.com:me/dotfiles//zsh", "git@github.com:me/dotfiles", "zsh"},
+		{"ssh://git@github.com/me/dotfiles", "ssh://git@github.com/me/dotfiles", ""},
+		{"/srv/dotfiles//nvim", "/srv/dotfiles", "nvim"},
+	}
+	for _, tt := range tests {
+		repo, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if repo.URL != tt.url || repo.Subdir != tt.subdir {
+			t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.spec, repo.URL, repo.Subdir, tt.url, tt.subdir)
+		}
+	}
+
+	for _, spec := range []string{"", "//nvim", "github.com/me/dotfiles//../etc"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", spec)
+		}
+	}
+}
+
+// git runs git in a directory, failing the test when it fails
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+	if _, err := RunCommand(dir, "git", args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tempDir := t.TempDir()
+	upstream := filepath.Join(tempDir, "dotfiles")
+	for relPath, content := range map[string]string{"nvim/init.lua": "set number", "zsh/.zshrc": "export EDITOR=nvim"} {
+		path := filepath.Join(upstream, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", relPath, err)
+		}
+	}
+	git(t, upstream, "init", "--quiet")
+	git(t, upstream, "add", ".")
+	git(t, upstream, "commit", "--quiet", "-m", "Initial")
+
+	m := NewManager(filepath.Join(tempDir, DefaultDir), RunCommand)
+	repo, err := Parse(upstream + "//nvim")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	checkout, err := m.Checkout(repo, "")
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(checkout, "init.lua")); err != nil || string(data) != "set number" {
+		t.Errorf("Expected the directory to be checked out, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(m.CheckoutDir(repo, ""), "zsh")); !os.IsNotExist(err) {
+		t.Errorf("Expected other directories to be left out of the sparse checkout, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(upstream, "nvim", "init.lua"), []byte("set relativenumber"), 0644); err != nil {
+		t.Fatalf("Failed to change upstream: %v", err)
+	}
+	git(t, upstream, "commit", "--quiet", "-am", "Relative numbers")
+
+	behind, err := m.Fetch(repo, "")
+	if err != nil || behind != 1 {
+		t.Fatalf("Expected the checkout to be one commit behind, got %d, %v", behind, err)
+	}
+	if err := m.Pull(repo, ""); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(checkout, "init.lua")); string(data) != "set relativenumber" {
+		t.Errorf("Expected the upstream change to be pulled, got %q", data)
+	}
+	if revision, err := m.Revision(repo, ""); err != nil || revision == "" {
+		t.Errorf("Expected a revision, got %q, %v", revision, err)
+	}
+
+	if _, err := m.Checkout(&Repo{URL: upstream, Subdir: "vim"}, ""); err == nil {
+		t.Error("Expected a missing directory to fail the checkout")
+	}
+}
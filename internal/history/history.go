@@ -0,0 +1,187 @@
+// Package history keeps an append-only journal of the operations that changed managed
+// configurations, such as syncs, restores, deploys, and removals, so 'configsync history' can
+// answer when, by whom, and how an application's settings were changed.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the journal in the configuration directory
+const FileName = "history.jsonl"
+
+// Operations recorded in the journal
+const (
+	Add           = "add"
+	Sync          = "sync"
+	Restore       = "restore"
+	Deploy        = "deploy"
+	Remove        = "remove"
+	Enable        = "enable"
+	Disable       = "disable"
+	StoreMove     = "store-move"
+	StoreLockdown = "store-lockdown"
+	StoreUnlock   = "store-unlock"
+	PeerSync      = "peer-sync"
+	Upgrade       = "upgrade"
+	Undo          = "undo"
+)
+
+// maxLineSize bounds a single journal entry when reading, so a damaged journal cannot exhaust memory
+const maxLineSize = 1 << 20
+
+// Entry is one recorded operation
+type Entry struct {
+	Time      time.Time         `json:"time" yaml:"time"`
+	Details   map[string]string `json:"details,omitempty" yaml:"details,omitempty"`
+	Operation string            `json:"operation" yaml:"operation"`
+	App       string            `json:"app,omitempty" yaml:"app,omitempty"`
+	User      string            `json:"user" yaml:"user"`
+	Host      string            `json:"host" yaml:"host"`
+	Error     string            `json:"error,omitempty" yaml:"error,omitempty"` // Set when the operation failed, possibly after changing some paths
+	Undo      string            `json:"undo,omitempty" yaml:"undo,omitempty"`   // The undo point that reverts the operation, when it can be undone
+	Paths     []string          `json:"paths,omitempty" yaml:"paths,omitempty"`
+}
+
+// Filter selects journal entries. Zero fields match everything.
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	App       string // Matched case-insensitively
+	Operation string
+	Path      string // Matched as a substring of any affected path
+	Limit     int    // Keep only the most recent entries
+}
+
+// Matches reports whether an entry passes the filter
+func (f Filter) Matches(entry Entry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	if f.App != "" && !strings.EqualFold(entry.App, f.App) {
+		return false
+	}
+	if f.Operation != "" && entry.Operation != f.Operation {
+		return false
+	}
+	if f.Path != "" {
+		for _, path := range entry.Paths {
+			if strings.Contains(path, f.Path) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Journal appends entries to the history file. A nil Journal discards entries, so callers need
+// not check whether history is being recorded, as in dry runs.
+type Journal struct {
+	path string
+	user string
+	host string
+}
+
+// Open returns the journal in the configuration directory
+func Open(configDir string) *Journal {
+	host, _ := os.Hostname()
+	return &Journal{path: filepath.Join(configDir, FileName), user: currentUser(), host: host}
+}
+
+// Path returns the location of the journal file
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record appends entries to the journal, filling in the time, user, and host where they are unset
+func (j *Journal) Record(entries ...Entry) error {
+	if j == nil || len(entries) == 0 {
+		return nil
+	}
+
+	var data []byte
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.Time.IsZero() {
+			entry.Time = now
+		}
+		if entry.User == "" {
+			entry.User = j.user
+		}
+		if entry.Host == "" {
+			entry.Host = j.host
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	// A single write keeps the entries of one call together when several processes append at once
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Read returns the journal entries matching the filter, oldest first. Lines that cannot be
+// parsed, such as one cut short by a crash, are skipped.
+func (j *Journal) Read(filter Filter) ([]Entry, error) {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// currentUser returns the name of the user running configsync
+func currentUser() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,144 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	journal := Open(dir)
+	monday := time.Date(2026, 10, 12, 9, 0, 0, 0, time.Local)
+
+	err := journal.Record(
+		Entry{Time: monday, Operation: Sync, App: "iterm2", Paths: []string{"~/Library/Preferences/com.googlecode.iterm2.plist"}},
+		Entry{Time: monday.Add(24 * time.Hour), Operation: Restore, App: "iterm2", Details: map[string]string{"version": "2"}},
+		Entry{Time: monday.Add(25 * time.Hour), Operation: Sync, App: "vscode", Error: "permission denied"},
+	)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := journal.Record(Entry{Operation: Remove, App: "git"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, FileName)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the journal to be readable only by the owner, got %v %v", info, err)
+	}
+
+	all, err := journal.Read(Filter{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(all) != 4 || all[3].Operation != Remove || all[3].Time.IsZero() || all[3].Host == "" {
+		t.Fatalf("Unexpected entries: %+v", all)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"app ignores case", Filter{App: "iTerm2"}, 2},
+		{"operation", Filter{Operation: Sync}, 2},
+		{"path substring", Filter{Path: "iterm2.plist"}, 1},
+		{"one day", Filter{Since: monday.Add(24 * time.Hour), Until: monday.Add(25 * time.Hour)}, 1},
+		{"limit keeps the newest", Filter{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := journal.Read(tt.filter)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("Expected %d entries, got %+v", tt.want, entries)
+			}
+		})
+	}
+
+	if newest, _ := journal.Read(Filter{Limit: 1}); len(newest) != 1 || newest[0].App != "git" {
+		t.Errorf("Expected the limit to keep the most recent entry, got %+v", newest)
+	}
+}
+
+func TestReadSkipsDamagedLines(t *testing.T) {
+	dir := t.TempDir()
+	journal := Open(dir)
+	if err := journal.Record(Entry{Operation: Sync, App: "git"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	file, err := os.OpenFile(journal.Path(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	_, _ = file.WriteString(`{"operation":"sy` + "\n")
+	_ = file.Close()
+
+	entries, err := journal.Read(Filter{})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected the damaged line to be skipped, got %+v %v", entries, err)
+	}
+}
+
+func TestNilJournalDiscards(t *testing.T) {
+	var journal *Journal
+	if err := journal.Record(Entry{Operation: Sync}); err != nil {
+		t.Errorf("Expected a nil journal to discard entries, got %v", err)
+	}
+}
+
+func TestMissingJournalIsEmpty(t *testing.T) {
+	entries, err := Open(t.TempDir()).Read(Filter{})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries, got %+v %v", entries, err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	// A Thursday afternoon
+	now := time.Date(2026, 10, 15, 15, 30, 0, 0, time.Local)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		want  time.Time
+		value string
+	}{
+		{day(15), "today"},
+		{day(14), "yesterday"},
+		{day(13), "Tuesday"},
+		{day(13), "tue"},
+		{day(8), "thursday"},
+		{day(1), "2026-10-01"},
+		{day(1).Add(9*time.Hour + 30*time.Minute), "2026-10-01 09:30"},
+		{now.Add(-36 * time.Hour), "36h"},
+		{now.AddDate(0, 0, -7), "7d"},
+		{now.AddDate(0, 0, -14), "2w"},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.value, now)
+		if err != nil {
+			t.Errorf("ParseTime(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	if got, err := ParseTime("2026-10-01T09:30:00Z", now); err != nil || !got.Equal(time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected RFC 3339 time: %v %v", got, err)
+	}
+	for _, value := range []string{"", "last week", "-3d", "13/10/2026"} {
+		if _, err := ParseTime(value, now); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+
+	start, end, err := ParseDay("tuesday", now)
+	if err != nil || !start.Equal(day(13)) || !end.Equal(day(14)) {
+		t.Errorf("Unexpected day: %v %v %v", start, end, err)
+	}
+}
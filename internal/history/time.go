@@ -0,0 +1,95 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the absolute times ParseTime accepts, in local time unless they carry a zone
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses a point in time given as a date ("2024-05-14", "2024-05-14 09:30"), an age
+// ("90m", "36h", "7d", "2w"), "today", "yesterday", or a weekday, which means the most recent
+// such day before today. Days resolve to their start.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	today := startOfDay(now)
+
+	switch value {
+	case "":
+		return time.Time{}, fmt.Errorf("empty time")
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			back := (int(now.Weekday()) - int(day) + 7) % 7
+			if back == 0 {
+				back = 7
+			}
+			return today.AddDate(0, 0, -back), nil
+		}
+	}
+
+	if age, err := parseAge(value); err == nil {
+		return now.Add(-age), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+		// Layouts are matched against the lowercased value, so retry RFC 3339 with its original case
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(value), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q; use a date such as 2024-05-14, an age such as 7d, today, yesterday, or a weekday", value)
+}
+
+// parseAge parses a duration, additionally accepting days ("7d") and weeks ("2w")
+func parseAge(value string) (time.Duration, error) {
+	if n := len(value); n > 1 && (value[n-1] == 'd' || value[n-1] == 'w') {
+		count, err := strconv.Atoi(value[:n-1])
+		if err != nil || count < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		days := count
+		if value[n-1] == 'w' {
+			days *= 7
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
+
+// startOfDay returns midnight at the start of the day of t
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// ParseDay parses a day in any form ParseTime accepts and returns its start and the start of the next day
+func ParseDay(value string, now time.Time) (time.Time, time.Time, error) {
+	t, err := ParseTime(value, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start := startOfDay(t)
+	return start, start.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,176 @@
+// Package ignore matches paths against gitignore-style patterns, used to leave caches and other
+// disposable entries of synced directories out of the store, backups, and bundles.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the file at the root of a synced directory that lists additional ignore patterns
+const FileName = ".configsyncignore"
+
+// CachePatterns match the cache, crash report, and log directories applications commonly keep
+// beside their settings. They are ignored unless caches are explicitly included, and an app can
+// re-include one with a negated pattern such as !logs/.
+var CachePatterns = []string{
+	"Cache/",
+	"Caches/",
+	"CachedData/",
+	"Code Cache/",
+	"GPUCache/",
+	"DawnCache/",
+	"GrShaderCache/",
+	"ShaderCache/",
+	"Crashpad/",
+	"Crash Reports/",
+	"logs/",
+	"Logs/",
+}
+
+// rule is one parsed pattern line
+type rule struct {
+	segments []string // Pattern split at slashes
+	negate   bool     // Pattern started with !, re-including what earlier rules ignored
+	dirOnly  bool     // Pattern ended with /, matching only directories
+	anchored bool     // Pattern contained a slash, so it matches from the root instead of any level
+}
+
+// Matcher decides which paths below a directory are ignored. A nil Matcher ignores nothing.
+type Matcher struct {
+	rules []rule
+}
+
+// New parses gitignore-style patterns. Blank lines and lines starting with # are skipped, a
+// leading ! re-includes paths an earlier pattern ignored, a trailing / matches directories only,
+// a pattern containing a slash is relative to the root, and ** matches any number of directories.
+func New(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, line := range patterns {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(pattern, "!") {
+			r.negate = true
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			r.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if strings.Contains(pattern, "/") {
+			r.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+		if pattern == "" {
+			continue
+		}
+
+		r.segments = strings.Split(pattern, "/")
+		for _, segment := range r.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+			}
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Check reports whether a single pattern is valid
+func Check(pattern string) error {
+	_, err := New([]string{pattern})
+	return err
+}
+
+// ReadFile returns the pattern lines of an ignore file, or none when it does not exist
+func ReadFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return lines, nil
+}
+
+// Empty reports whether the matcher has no rules
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.rules) == 0
+}
+
+// Match reports whether a path relative to the root is ignored. As in git, the contents of an
+// ignored directory stay ignored even if a later pattern would re-include them.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m.Empty() {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(relPath)), "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchParts(parts[:i], true) {
+			return true
+		}
+	}
+	return m.matchParts(parts, isDir)
+}
+
+// matchParts applies the rules to one path, the last matching rule deciding
+func (m *Matcher) matchParts(parts []string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		var matched bool
+		if r.anchored {
+			matched = matchSegments(r.segments, parts)
+		} else {
+			matched = matchSegments(r.segments, parts[len(parts)-1:])
+		}
+		if matched {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches path segments against pattern segments, where ** matches zero or more segments
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(parts); skip++ {
+				if matchSegments(pattern[1:], parts[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
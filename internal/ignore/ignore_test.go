@@ -0,0 +1,110 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	matcher, err := New([]string{
+		"# caches",
+		"Cache/",
+		"*.log",
+		"/GPUCache",
+		"Crashpad/**/pending",
+		"",
+		"logs/",
+		"!logs/keep.log",
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"Cache", true, true},
+		{"Default/Cache", true, true},
+		{"Default/Cache/data_0", false, true},
+		{"Cache", false, false}, // Directory-only pattern
+		{"debug.log", false, true},
+		{"Default/debug.log", false, true},
+		{"GPUCache", true, true},
+		{"Default/GPUCache", true, false}, // Anchored to the root
+		{"Crashpad/pending", true, true},
+		{"Crashpad/a/b/pending", true, true},
+		{"Preferences", false, false},
+		{"logs/keep.log", false, true}, // The ignored directory wins
+	}
+
+	for _, tt := range tests {
+		if got := matcher.Match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestMatchNegation(t *testing.T) {
+	matcher, err := New([]string{"*.json", "!Preferences.json"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !matcher.Match("state.json", false) {
+		t.Error("Expected state.json to be ignored")
+	}
+	if matcher.Match("Preferences.json", false) {
+		t.Error("Expected Preferences.json to be re-included")
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var matcher *Matcher
+	if !matcher.Empty() || matcher.Match("anything", false) {
+		t.Error("Expected a nil matcher to ignore nothing")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check("Cache/"); err != nil {
+		t.Errorf("Expected a valid pattern: %v", err)
+	}
+	if err := Check("[invalid"); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	lines, err := ReadFile(filepath.Join(dir, FileName))
+	if err != nil || lines != nil {
+		t.Errorf("Expected no patterns for a missing file, got %v (%v)", lines, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("Cache/\n# comment\n*.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+	lines, err = ReadFile(filepath.Join(dir, FileName))
+	if err != nil || len(lines) != 3 {
+		t.Errorf("Expected three lines, got %v (%v)", lines, err)
+	}
+}
+
+func TestCachePatterns(t *testing.T) {
+	matcher, err := New(CachePatterns)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, dir := range []string{"Cache", "Default/Code Cache", "Default/GPUCache", "Crashpad", "logs", "User/CachedData"} {
+		if !matcher.Match(dir, true) {
+			t.Errorf("Expected %s to be recognized as a cache directory", dir)
+		}
+	}
+	for _, file := range []string{"Default/Preferences", "User/settings.json", "Cache"} {
+		if matcher.Match(file, false) {
+			t.Errorf("Expected file %s not to be ignored", file)
+		}
+	}
+}
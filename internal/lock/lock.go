@@ -0,0 +1,249 @@
+// Package lock serializes operations on the same application across configsync processes,
+// such as a manual sync and a scheduled one, with per-app lock files under ~/.configsync/locks.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirName is the directory inside the configuration directory that holds the lock files
+const DirName = "locks"
+
+// DefaultTimeout is how long an operation waits for another process to release an app
+const DefaultTimeout = 30 * time.Second
+
+// pollInterval is how often a held lock is checked while waiting
+const pollInterval = 100 * time.Millisecond
+
+// takeoverFileName is the file in the lock directory that processes taking over stale locks
+// hold an OS lock on, one at a time
+const takeoverFileName = ".takeover"
+
+// Holder describes the process holding an app's lock
+type Holder struct {
+	Since     time.Time `json:"since"`
+	App       string    `json:"app"`
+	Operation string    `json:"operation"`
+	PID       int       `json:"pid"`
+}
+
+// String describes the holder for messages
+func (h Holder) String() string {
+	return fmt.Sprintf("configsync %s (pid %d, since %s)", h.Operation, h.PID, h.Since.Format("15:04:05"))
+}
+
+// HeldError is returned when an app stays locked by another process for longer than the timeout
+type HeldError struct {
+	Holder Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("application %s is locked by %s", e.Holder.App, e.Holder)
+}
+
+// Manager acquires and releases per-app locks
+type Manager struct {
+	out     io.Writer
+	dir     string
+	timeout time.Duration
+}
+
+// NewManager creates a lock manager for the configuration directory
+func NewManager(configDir string) *Manager {
+	return &Manager{
+		dir:     filepath.Join(configDir, DirName),
+		timeout: DefaultTimeout,
+		out:     os.Stderr,
+	}
+}
+
+// SetTimeout sets how long to wait for locks held by other processes. Zero fails immediately.
+func (m *Manager) SetTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// Acquire locks the apps for an operation, waiting for other processes to release them, and
+// returns a function that releases the locks. Apps are locked in name order so that two
+// processes locking overlapping sets cannot deadlock.
+func (m *Manager) Acquire(operation string, apps []string) (func(), error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	names := append([]string(nil), apps...)
+	sort.Strings(names)
+	for _, app := range names {
+		if app == "" || app != filepath.Base(app) || app == "." || app == ".." {
+			return nil, fmt.Errorf("invalid application name %q", app)
+		}
+	}
+
+	var held []string
+	release := func() {
+		for _, app := range held {
+			_ = os.Remove(m.lockPath(app))
+		}
+	}
+
+	deadline := time.Now().Add(m.timeout)
+	for i, app := range names {
+		if i > 0 && app == names[i-1] {
+			continue
+		}
+		if err := m.acquireOne(operation, app, deadline); err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, app)
+	}
+	return release, nil
+}
+
+// acquireOne creates an app's lock file, taking over locks left behind by processes that are no
+// longer running
+func (m *Manager) acquireOne(operation, app string, deadline time.Time) error {
+	waiting := false
+	for {
+		created, err := m.create(operation, app)
+		if err != nil || created {
+			return err
+		}
+
+		holder, err := m.Holder(app)
+		if err != nil {
+			return err
+		}
+		if holder == nil {
+			// Released in the meantime
+			continue
+		}
+		if !m.running(holder) {
+			// Left behind by a process that exited without releasing it
+			if err := m.removeStale(app); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			return &HeldError{Holder: *holder}
+		}
+		if !waiting {
+			_, _ = fmt.Fprintf(m.out, "Waiting for %s, which is locked by %s...\n", app, holder)
+			waiting = true
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// removeStale removes an app's lock file if its holder is no longer running. Processes taking
+// over locks do so one at a time and check the holder again once it is their turn, so a lock
+// one of them took over is never removed by another that found the same stale holder.
+func (m *Manager) removeStale(app string) error {
+	takeover, err := os.OpenFile(filepath.Join(m.dir, takeoverFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", app, err)
+	}
+	defer func() { _ = takeover.Close() }()
+	if err := lockFile(takeover); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", app, err)
+	}
+	defer func() { _ = unlockFile(takeover) }()
+
+	holder, err := m.Holder(app)
+	if err != nil || holder == nil || m.running(holder) {
+		return err
+	}
+	if err := os.Remove(m.lockPath(app)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale lock of %s: %w", app, err)
+	}
+	return nil
+}
+
+// create writes the lock file if no other process holds it
+func (m *Manager) create(operation, app string) (bool, error) {
+	file, err := os.OpenFile(m.lockPath(app), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock %s: %w", app, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	holder := Holder{App: app, PID: os.Getpid(), Operation: operation, Since: time.Now()}
+	if err := json.NewEncoder(file).Encode(holder); err != nil {
+		_ = os.Remove(m.lockPath(app))
+		return false, fmt.Errorf("failed to lock %s: %w", app, err)
+	}
+	return true, nil
+}
+
+// Holder returns the process holding an app's lock, or nil when it is not locked
+func (m *Manager) Holder(app string) (*Holder, error) {
+	data, err := os.ReadFile(m.lockPath(app))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock of %s: %w", app, err)
+	}
+
+	var holder Holder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		// A lock file being written by its holder is incomplete for a moment
+		return &Holder{App: app}, nil
+	}
+	holder.App = app
+	return &holder, nil
+}
+
+// Holders lists the locks currently held by running processes
+func (m *Manager) Holders() ([]Holder, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock directory: %w", err)
+	}
+
+	var holders []Holder
+	for _, entry := range entries {
+		app, ok := strings.CutSuffix(entry.Name(), ".lock")
+		if !ok {
+			continue
+		}
+		holder, err := m.Holder(app)
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil && m.running(holder) {
+			holders = append(holders, *holder)
+		}
+	}
+	return holders, nil
+}
+
+// running reports whether a lock's holder is still running. A lock without a process ID is
+// only considered held while it is new enough to still be being written.
+func (m *Manager) running(holder *Holder) bool {
+	if holder.PID == 0 {
+		info, err := os.Stat(m.lockPath(holder.App))
+		return err == nil && time.Since(info.ModTime()) < time.Second
+	}
+	return processRunning(holder.PID)
+}
+
+// lockPath returns the lock file of an app
+func (m *Manager) lockPath(app string) string {
+	return filepath.Join(m.dir, app+".lock")
+}
@@ -0,0 +1,180 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager(t.TempDir())
+	m.out = io.Discard
+	return m
+}
+
+// writeLock writes a lock file as another process would
+func writeLock(t *testing.T, m *Manager, holder Holder) {
+	t.Helper()
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		t.Fatalf("Failed to create lock directory: %v", err)
+	}
+	data, err := json.Marshal(holder)
+	if err != nil {
+		t.Fatalf("Failed to encode lock: %v", err)
+	}
+	if err := os.WriteFile(m.lockPath(holder.App), data, 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	m := newTestManager(t)
+
+	release, err := m.Acquire("sync", []string{"vscode", "git", "vscode"})
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	holder, err := m.Holder("git")
+	if err != nil || holder == nil {
+		t.Fatalf("Expected git to be locked, got %v (%v)", holder, err)
+	}
+	if holder.PID != os.Getpid() || holder.Operation != "sync" {
+		t.Errorf("Unexpected holder %+v", holder)
+	}
+	if holders, _ := m.Holders(); len(holders) != 2 {
+		t.Errorf("Expected 2 held locks, got %v", holders)
+	}
+
+	release()
+	if holders, _ := m.Holders(); len(holders) != 0 {
+		t.Errorf("Expected locks to be released, got %v", holders)
+	}
+}
+
+func TestAcquireHeldByAnotherProcess(t *testing.T) {
+	m := newTestManager(t)
+	m.SetTimeout(0)
+	writeLock(t, m, Holder{App: "vscode", PID: os.Getpid(), Operation: "backup", Since: time.Now()})
+
+	_, err := m.Acquire("sync", []string{"git", "vscode"})
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("Expected a HeldError, got %v", err)
+	}
+	if held.Holder.Operation != "backup" || held.Holder.PID != os.Getpid() {
+		t.Errorf("Unexpected holder %+v", held.Holder)
+	}
+
+	// Locks taken before the failure are released
+	if holder, _ := m.Holder("git"); holder != nil {
+		t.Error("Expected git to be released after failing to lock vscode")
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	m := newTestManager(t)
+	writeLock(t, m, Holder{App: "vscode", PID: os.Getpid(), Operation: "backup", Since: time.Now()})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = os.Remove(m.lockPath("vscode"))
+	}()
+
+	release, err := m.Acquire("sync", []string{"vscode"})
+	if err != nil {
+		t.Fatalf("Expected the lock once released, got %v", err)
+	}
+	release()
+}
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	m := newTestManager(t)
+	m.SetTimeout(0)
+
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("Failed to run a process: %v", err)
+	}
+	writeLock(t, m, Holder{App: "vscode", PID: exited.ProcessState.Pid(), Operation: "sync", Since: time.Now()})
+
+	release, err := m.Acquire("backup", []string{"vscode"})
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, got %v", err)
+	}
+	defer release()
+
+	if holder, _ := m.Holder("vscode"); holder == nil || holder.Operation != "backup" {
+		t.Errorf("Expected the lock to be held for backup, got %v", holder)
+	}
+}
+
+func TestConcurrentTakeoverOfStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("Failed to run a process: %v", err)
+	}
+
+	for round := 0; round < 3; round++ {
+		writeLock(t, &Manager{dir: filepath.Join(dir, DirName)}, Holder{App: "vscode", PID: exited.ProcessState.Pid(), Operation: "sync", Since: time.Now()})
+
+		// Every contender finds the same stale lock, but only one may hold the app at a time
+		var inside, overlaps atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m := NewManager(dir)
+				m.out = io.Discard
+				release, err := m.Acquire("backup", []string{"vscode"})
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+				if inside.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+				inside.Add(-1)
+				release()
+			}()
+		}
+		wg.Wait()
+		if overlaps.Load() > 0 {
+			t.Fatalf("Expected one holder at a time, %d processes held the lock together", overlaps.Load()+1)
+		}
+	}
+}
+
+func TestRemoveStaleKeepsTakenOverLock(t *testing.T) {
+	m := newTestManager(t)
+
+	// Another process found the same stale lock first and took it over before this one got
+	// its turn, which must not remove the new lock
+	writeLock(t, m, Holder{App: "vscode", PID: os.Getpid(), Operation: "backup", Since: time.Now()})
+	if err := m.removeStale("vscode"); err != nil {
+		t.Fatalf("removeStale failed: %v", err)
+	}
+	if holder, _ := m.Holder("vscode"); holder == nil || holder.Operation != "backup" {
+		t.Errorf("Expected the lock taken over to be kept, got %v", holder)
+	}
+}
+
+func TestAcquireRejectsInvalidNames(t *testing.T) {
+	m := newTestManager(t)
+	for _, name := range []string{"", "..", "../escape", filepath.Join("a", "b")} {
+		if _, err := m.Acquire("sync", []string{name}); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package lock
+
+import "os"
+
+// processRunning reports whether a process exists. Windows only finds running processes.
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,176 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ChecksumsFileName is the name of the file at the root of the store that records the
+// content of every store file as of the sync that last wrote it
+const ChecksumsFileName = ".configsync-checksums.yaml"
+
+// ObjectsDirName is the name of the directory at the root of the store that holds the blobs
+// and app manifests of the content-addressed store mode
+const ObjectsDirName = ".configsync-objects"
+
+// Checksums records the expected content hash of store files so changes made outside
+// of configsync, or corruption, can be detected later. Unlike the manifest, recorded
+// hashes are never refreshed from the files themselves.
+type Checksums struct {
+	UpdatedAt time.Time         `yaml:"updated_at"`
+	Files     map[string]string `yaml:"files"`
+	root      string
+}
+
+// VerifyReport lists the store files whose content differs from the recorded checksums
+type VerifyReport struct {
+	Modified  []string `json:"modified,omitempty" yaml:"modified,omitempty"`   // Content changed since it was recorded
+	Missing   []string `json:"missing,omitempty" yaml:"missing,omitempty"`     // Recorded but no longer in the store
+	Untracked []string `json:"untracked,omitempty" yaml:"untracked,omitempty"` // In the store but never recorded
+	Verified  int      `json:"verified" yaml:"verified"`                       // Files that match their checksum
+}
+
+// LoadChecksums reads the recorded checksums of a store, returning an empty record if none exists yet
+func LoadChecksums(root string) (*Checksums, error) {
+	c := &Checksums{
+		Files: make(map[string]string),
+		root:  filepath.Clean(root),
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.root, ChecksumsFileName))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse checksums: %w", err)
+	}
+	if c.Files == nil {
+		c.Files = make(map[string]string)
+	}
+
+	return c, nil
+}
+
+// Save writes the recorded checksums
+func (c *Checksums) Save() error {
+	c.UpdatedAt = time.Now()
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checksums: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(c.root, ChecksumsFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write checksums: %w", err)
+	}
+	return nil
+}
+
+// Record hashes the file or directory at a store-relative path, replacing anything recorded
+// for it before. A path missing from the store is forgotten.
+func (c *Checksums) Record(relPath string) error {
+	prefix := filepath.ToSlash(filepath.Clean(relPath))
+	for key := range c.Files {
+		if key == prefix || strings.HasPrefix(key, prefix+"/") {
+			delete(c.Files, key)
+		}
+	}
+
+	target := filepath.Join(c.root, relPath)
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		hash, err := HashFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		key, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		c.Files[filepath.ToSlash(key)] = hash
+		return nil
+	})
+}
+
+// Verify re-reads every file in the store and compares it with the recorded checksums
+func (c *Checksums) Verify() (*VerifyReport, error) {
+	report := &VerifyReport{}
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ObjectsDirName {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if key == FileName || key == ChecksumsFileName || key == PermissionsFileName {
+			return nil
+		}
+
+		expected, recorded := c.Files[key]
+		if !recorded {
+			report.Untracked = append(report.Untracked, key)
+			return nil
+		}
+		seen[key] = true
+
+		hash, err := HashFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		if hash != expected {
+			report.Modified = append(report.Modified, key)
+		} else {
+			report.Verified++
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to verify store: %w", err)
+	}
+
+	for key := range c.Files {
+		if !seen[key] {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+
+	sort.Strings(report.Modified)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Untracked)
+	return report, nil
+}
+
+// Clean reports whether verification found no differences
+func (r *VerifyReport) Clean() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Untracked) == 0
+}
@@ -0,0 +1,76 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumsVerify(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitconfig"), "[user]\n")
+	writeFile(t, filepath.Join(root, "Library", "Code", "settings.json"), "{}")
+	writeFile(t, filepath.Join(root, "Library", "Code", "keybindings.json"), "[]")
+
+	checksums, err := LoadChecksums(root)
+	if err != nil {
+		t.Fatalf("LoadChecksums failed: %v", err)
+	}
+	for _, relPath := range []string{".gitconfig", "Library/Code"} {
+		if err := checksums.Record(relPath); err != nil {
+			t.Fatalf("Record(%s) failed: %v", relPath, err)
+		}
+	}
+	if err := checksums.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	checksums, err = LoadChecksums(root)
+	if err != nil {
+		t.Fatalf("LoadChecksums failed: %v", err)
+	}
+	report, err := checksums.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.Clean() || report.Verified != 3 {
+		t.Fatalf("Expected 3 verified files and no differences, got %+v", report)
+	}
+
+	// Out-of-band changes are reported
+	writeFile(t, filepath.Join(root, ".gitconfig"), "[user]\n\tname = edited\n")
+	if err := os.Remove(filepath.Join(root, "Library", "Code", "keybindings.json")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "Library", "Code", "extra.json"), "{}")
+
+	report, err = checksums.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if strings.Join(report.Modified, ",") != ".gitconfig" {
+		t.Errorf("Expected .gitconfig to be modified, got %v", report.Modified)
+	}
+	if strings.Join(report.Missing, ",") != "Library/Code/keybindings.json" {
+		t.Errorf("Expected keybindings.json to be missing, got %v", report.Missing)
+	}
+	if strings.Join(report.Untracked, ",") != "Library/Code/extra.json" {
+		t.Errorf("Expected extra.json to be untracked, got %v", report.Untracked)
+	}
+
+	// Recording a path again replaces its entries
+	if err := checksums.Record("Library/Code"); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := checksums.Record(".gitconfig"); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	report, err = checksums.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Errorf("Expected no differences after recording again, got %+v", report)
+	}
+}
@@ -0,0 +1,296 @@
+// Package manifest provides per-file content hashes for incremental copying of store contents.
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the manifest file kept at the root of the store
+const FileName = ".configsync-manifest.yaml"
+
+// Entry records the content hash of a file along with the metadata it was computed from
+type Entry struct {
+	ModTime time.Time `yaml:"mod_time"`
+	Hash    string    `yaml:"hash"`
+	Size    int64     `yaml:"size"`
+}
+
+// Manifest caches content hashes for files below a root directory.
+// A cached hash is reused as long as the file's size and modification time are unchanged.
+type Manifest struct {
+	Files   map[string]Entry `yaml:"files"`
+	root    string
+	fs      fsys.FS
+	mu      sync.Mutex
+	changed map[string]bool // Keys hashed since the manifest was loaded or saved
+}
+
+// saveLocks holds a mutex for each manifest root, so manifests of the same store loaded by
+// parallel syncs save one at a time instead of overwriting each other's entries
+var saveLocks sync.Map
+
+// CopyStats summarizes an incremental copy
+type CopyStats struct {
+	Copied  int
+	Skipped int
+}
+
+// Load reads the manifest for a root directory, returning an empty manifest if none exists yet
+func Load(root string) (*Manifest, error) {
+	return LoadFS(fsys.OS, root)
+}
+
+// LoadFS is Load for a root directory on files. The manifest reads, hashes, and copies files
+// through it too.
+func LoadFS(files fsys.FS, root string) (*Manifest, error) {
+	m := &Manifest{root: filepath.Clean(root), fs: files, changed: make(map[string]bool)}
+	entries, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+	m.Files = entries
+	return m, nil
+}
+
+// read returns the entries of the manifest file, or none if it does not exist yet
+func (m *Manifest) read() (map[string]Entry, error) {
+	data, err := m.fs.ReadFile(m.path())
+	if os.IsNotExist(err) {
+		return make(map[string]Entry), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var saved Manifest
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if saved.Files == nil {
+		saved.Files = make(map[string]Entry)
+	}
+	return saved.Files, nil
+}
+
+// Save adds the entries hashed since the manifest was loaded to the manifest file, keeping the
+// entries other manifests of the same root saved in the meantime. The file is replaced with a
+// rename, so it is never read half-written.
+func (m *Manifest) Save() error {
+	lock, _ := saveLocks.LoadOrStore(m.root, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.changed) == 0 {
+		return nil
+	}
+
+	files, err := m.read()
+	if err != nil {
+		return err
+	}
+	for key := range m.changed {
+		files[key] = m.Files[key]
+	}
+	data, err := yaml.Marshal(&Manifest{Files: files})
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := m.fs.MkdirAll(m.root, 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	temp, tempPath, err := fsys.CreateTemp(m.fs, m.root, FileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	defer func() { _ = m.fs.Remove(tempPath) }()
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = m.fs.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = m.fs.Rename(tempPath, m.path())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	m.Files = files
+	m.changed = make(map[string]bool)
+	return nil
+}
+
+// Hash returns the content hash of a file, using the cached value for files below the root when still valid
+func (m *Manifest) Hash(path string) (string, error) {
+	info, err := m.fs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	key, tracked := m.key(path)
+	if tracked {
+		m.mu.Lock()
+		entry, exists := m.Files[key]
+		m.mu.Unlock()
+
+		if exists && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			return entry.Hash, nil
+		}
+	}
+
+	hash, err := HashFileFS(m.fs, path)
+	if err != nil {
+		return "", err
+	}
+
+	if tracked {
+		m.mu.Lock()
+		m.Files[key] = Entry{Hash: hash, Size: info.Size(), ModTime: info.ModTime()}
+		m.changed[key] = true
+		m.mu.Unlock()
+	}
+
+	return hash, nil
+}
+
+// CopyFile copies src to dst unless dst already has identical content. It reports whether a copy was made.
+func (m *Manifest) CopyFile(src, dst string) (bool, error) {
+	same, err := m.sameContent(src, dst)
+	if err != nil {
+		return false, err
+	}
+	if same {
+		return false, nil
+	}
+
+	if err := fsops.CopyFile(context.Background(), m.fs, src, dst); err != nil {
+		return false, err
+	}
+
+	// Refresh the cached hash of the new copy if it is tracked
+	if _, tracked := m.key(dst); tracked {
+		if _, err := m.Hash(dst); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// CopyDir copies the contents of src into dst, transferring only files whose content differs
+func (m *Manifest) CopyDir(src, dst string) (CopyStats, error) {
+	var stats CopyStats
+
+	err := fsys.Walk(m.fs, src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+
+		if info.IsDir() {
+			return m.fs.MkdirAll(dstPath, info.Mode())
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fsops.CopySymlink(m.fs, path, dstPath)
+		}
+
+		copied, err := m.CopyFile(path, dstPath)
+		if err != nil {
+			return err
+		}
+		if copied {
+			stats.Copied++
+		} else {
+			stats.Skipped++
+		}
+		return nil
+	})
+
+	return stats, err
+}
+
+// HashFile computes the SHA-256 hash of a file's content
+func HashFile(path string) (string, error) {
+	return HashFileFS(fsys.OS, path)
+}
+
+// HashFileFS is HashFile for a file on files
+func HashFileFS(files fsys.FS, path string) (string, error) {
+	file, err := files.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Helper methods
+
+func (m *Manifest) path() string {
+	return filepath.Join(m.root, FileName)
+}
+
+// key returns the manifest key for a path and whether the path lies below the root
+func (m *Manifest) key(path string) (string, bool) {
+	rel, err := filepath.Rel(m.root, filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// sameContent reports whether dst exists with the same content as src
+func (m *Manifest) sameContent(src, dst string) (bool, error) {
+	dstInfo, err := m.fs.Lstat(dst)
+	if err != nil || !dstInfo.Mode().IsRegular() {
+		return false, nil
+	}
+
+	srcInfo, err := m.fs.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if srcInfo.Size() != dstInfo.Size() {
+		return false, nil
+	}
+
+	srcHash, err := m.Hash(src)
+	if err != nil {
+		return false, err
+	}
+	dstHash, err := m.Hash(dst)
+	if err != nil {
+		return false, err
+	}
+
+	return srcHash == dstHash, nil
+}
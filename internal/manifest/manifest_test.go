@@ -0,0 +1,239 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadMissingManifest(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(m.Files) != 0 {
+		t.Errorf("Expected empty manifest, got %d entries", len(m.Files))
+	}
+}
+
+func TestHashCachesEntriesBelowRoot(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "Library", "settings.json")
+	writeFile(t, file, constants.TestConfiguration)
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	hash, err := m.Hash(file)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	entry, exists := m.Files["Library/settings.json"]
+	if !exists || entry.Hash != hash {
+		t.Fatalf("Expected cached entry for tracked file, got %+v", m.Files)
+	}
+
+	// Files outside the root are hashed but not tracked
+	outside := filepath.Join(t.TempDir(), "other.txt")
+	writeFile(t, outside, constants.TestConfiguration)
+	outsideHash, err := m.Hash(outside)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if outsideHash != hash {
+		t.Error("Expected identical content to produce identical hashes")
+	}
+	if len(m.Files) != 1 {
+		t.Errorf("Expected only tracked files in manifest, got %d entries", len(m.Files))
+	}
+}
+
+func TestHashDetectsChanges(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config")
+	writeFile(t, file, "original")
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	first, err := m.Hash(file)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	writeFile(t, file, "modified content")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("Failed to update times: %v", err)
+	}
+
+	second, err := m.Hash(file)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if first == second {
+		t.Error("Expected hash to change after content changed")
+	}
+}
+
+func TestSaveAndReload(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config")
+	writeFile(t, file, constants.TestHelloWorld)
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, err := m.Hash(file); err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := Load(root)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if reloaded.Files["config"].Hash != m.Files["config"].Hash {
+		t.Error("Expected reloaded manifest to contain saved entry")
+	}
+}
+
+func TestConcurrentSavesKeepEveryEntry(t *testing.T) {
+	root := t.TempDir()
+	const apps = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*apps)
+	for i := 0; i < apps; i++ {
+		file := filepath.Join(root, fmt.Sprintf("app%d", i), "settings")
+		writeFile(t, file, fmt.Sprintf("settings %d", i))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m, err := Load(root)
+			if err == nil {
+				_, err = m.Hash(file)
+			}
+			if err == nil {
+				err = m.Save()
+			}
+			errs <- err
+		}()
+		go func() {
+			// A manifest read while others are saved is never half-written
+			defer wg.Done()
+			_, err := Load(root)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent load or save failed: %v", err)
+		}
+	}
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(m.Files) != apps {
+		t.Errorf("Expected the entries of all %d saves, got %v", apps, m.Files)
+	}
+}
+
+func TestCopyDirSkipsUnchangedFiles(t *testing.T) {
+	root := t.TempDir()
+	storeDir := filepath.Join(root, "Library", "Firefox")
+	writeFile(t, filepath.Join(storeDir, "prefs.js"), "prefs")
+	writeFile(t, filepath.Join(storeDir, "profile", "places.sqlite"), "places")
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "Firefox")
+
+	stats, err := m.CopyDir(storeDir, target)
+	if err != nil {
+		t.Fatalf("CopyDir failed: %v", err)
+	}
+	if stats.Copied != 2 || stats.Skipped != 0 {
+		t.Errorf("Expected 2 copied files on first copy, got %+v", stats)
+	}
+
+	// Change one file and copy again
+	writeFile(t, filepath.Join(storeDir, "prefs.js"), "PREFS")
+
+	stats, err = m.CopyDir(storeDir, target)
+	if err != nil {
+		t.Fatalf("CopyDir failed: %v", err)
+	}
+	if stats.Copied != 1 || stats.Skipped != 1 {
+		t.Errorf("Expected only the changed file to be copied, got %+v", stats)
+	}
+
+	data, err := os.ReadFile(filepath.Join(target, "prefs.js"))
+	if err != nil {
+		t.Fatalf("Failed to read copied file: %v", err)
+	}
+	if string(data) != "PREFS" {
+		t.Errorf("Expected updated content, got %q", string(data))
+	}
+}
+
+func TestCopyFileIntoRootUpdatesManifest(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "bundle.json")
+	writeFile(t, src, constants.TestConfiguration)
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	dst := filepath.Join(root, "bundle.json")
+	copied, err := m.CopyFile(src, dst)
+	if err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if !copied {
+		t.Error("Expected first copy to transfer the file")
+	}
+	if _, exists := m.Files["bundle.json"]; !exists {
+		t.Error("Expected copied file to be recorded in manifest")
+	}
+
+	copied, err = m.CopyFile(src, dst)
+	if err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if copied {
+		t.Error("Expected identical file to be skipped")
+	}
+}
@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// PermissionsFileName is the name of the file at the root of the store that records the owner
+// and mode of every path as it was before it was moved into the store
+const PermissionsFileName = ".configsync-permissions.yaml"
+
+// permissionsMu serializes updates of the permissions file by paths synced in parallel
+var permissionsMu sync.Mutex
+
+// Ownership is the owner and permission bits of a file or directory
+type Ownership struct {
+	Mode string `yaml:"mode"` // Permission bits in octal, e.g. 0600
+	UID  int    `yaml:"uid"`
+	GID  int    `yaml:"gid"`
+}
+
+// OwnershipOf returns the owner and permission bits of a file, which are only known on macOS
+// and Linux
+func OwnershipOf(info fs.FileInfo) (Ownership, bool) {
+	uid, gid, ok := fsops.Owner(info)
+	if !ok {
+		return Ownership{}, false
+	}
+	return Ownership{Mode: fmt.Sprintf("%04o", info.Mode().Perm()), UID: uid, GID: gid}, true
+}
+
+// Perm returns the recorded permission bits
+func (o Ownership) Perm() (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(o.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q", o.Mode)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// Permissions records the original owner and mode of store paths, keyed by their path relative
+// to the store, so that the store copies and files restored from them can be repaired
+type Permissions struct {
+	Paths map[string]Ownership `yaml:"paths"`
+	// Locked holds the permission bits in octal of the entries 'configsync store lockdown' took
+	// the write permission off, keyed like Paths, so unlocking the store can give them back
+	Locked map[string]string `yaml:"locked,omitempty"`
+	root   string
+}
+
+// LoadPermissions reads the recorded permissions of a store, returning an empty record if none
+// exists yet
+func LoadPermissions(root string) (*Permissions, error) {
+	p := &Permissions{
+		Paths: make(map[string]Ownership),
+		root:  filepath.Clean(root),
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.root, PermissionsFileName))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permissions: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse permissions: %w", err)
+	}
+	if p.Paths == nil {
+		p.Paths = make(map[string]Ownership)
+	}
+
+	return p, nil
+}
+
+// Save writes the recorded permissions
+func (p *Permissions) Save() error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permissions: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(p.root, PermissionsFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write permissions: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the recorded owner and mode of a store-relative path
+func (p *Permissions) Lookup(relPath string) (Ownership, bool) {
+	ownership, ok := p.Paths[filepath.ToSlash(filepath.Clean(relPath))]
+	return ownership, ok
+}
+
+// LockedPerm returns the permission bits a store-relative path had before the store was locked down
+func (p *Permissions) LockedPerm(relPath string) (fs.FileMode, bool) {
+	mode, ok := p.Locked[filepath.ToSlash(filepath.Clean(relPath))]
+	if !ok {
+		return 0, false
+	}
+	perm, err := Ownership{Mode: mode}.Perm()
+	return perm, err == nil
+}
+
+// RecordOwnership records the owner and mode a path had before it was moved to relPath in the
+// store at root, described by info. Nothing is recorded where files have no owner.
+func RecordOwnership(root, relPath string, info fs.FileInfo) error {
+	ownership, ok := OwnershipOf(info)
+	if !ok {
+		return nil
+	}
+
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+
+	permissions, err := LoadPermissions(root)
+	if err != nil {
+		return err
+	}
+	key := filepath.ToSlash(filepath.Clean(relPath))
+	if permissions.Paths[key] == ownership {
+		return nil
+	}
+	permissions.Paths[key] = ownership
+	return permissions.Save()
+}
@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordOwnership(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(t.TempDir(), ".netrc")
+	writeFile(t, source, "machine example.com")
+	if err := os.Chmod(source, 0600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	expected, ok := OwnershipOf(info)
+	if !ok {
+		t.Skip("Files have no owner on this platform")
+	}
+
+	if err := RecordOwnership(root, ".netrc", info); err != nil {
+		t.Fatalf("RecordOwnership failed: %v", err)
+	}
+	permissions, err := LoadPermissions(root)
+	if err != nil {
+		t.Fatalf("LoadPermissions failed: %v", err)
+	}
+	recorded, ok := permissions.Lookup(".netrc")
+	if !ok || recorded != expected {
+		t.Fatalf("Expected %+v to be recorded, got %+v", expected, recorded)
+	}
+	if mode, err := recorded.Perm(); err != nil || mode != 0600 {
+		t.Errorf("Expected mode 0600, got %04o %v", mode, err)
+	}
+	if _, ok := permissions.Lookup("missing"); ok {
+		t.Error("Expected no record of a path never moved into the store")
+	}
+}
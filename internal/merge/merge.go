@@ -0,0 +1,218 @@
+// Package merge provides three-way merging of configuration files.
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Policy decides how changes made on both sides to the same value are resolved
+type Policy string
+
+const (
+	// PolicyReport leaves conflicting changes unresolved and reports them
+	PolicyReport Policy = ""
+	// PolicyPreferLocal resolves conflicts with the local value
+	PolicyPreferLocal Policy = "prefer-local"
+	// PolicyPreferIncoming resolves conflicts with the incoming value
+	PolicyPreferIncoming Policy = "prefer-incoming"
+)
+
+// Result is the outcome of a merge
+type Result struct {
+	Content   []byte   // Merged content; with unresolved conflicts, local values are kept in their place
+	Conflicts []string // Keys or line ranges changed differently on both sides and not resolved by the policy
+}
+
+// Merge combines the changes made locally and incoming since a common base version of a file.
+// JSON, YAML, and property list files are merged key by key; other files line by line.
+// An empty base means the file has no common ancestor. An error means the content could not
+// be parsed as the expected format, and the caller must fall back to choosing a whole file.
+func Merge(path string, base, local, incoming []byte, policy Policy) (*Result, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return mergeJSON(base, local, incoming, policy)
+	case ".yaml", ".yml":
+		return mergeYAML(base, local, incoming, policy)
+	case ".plist":
+		return mergePlist(base, local, incoming, policy)
+	default:
+		return mergeText(base, local, incoming, policy), nil
+	}
+}
+
+// missing stands for a key that does not exist on one side of a merge
+type missing struct{}
+
+// merger merges decoded documents and collects the conflicts it could not resolve
+type merger struct {
+	policy    Policy
+	conflicts []string
+}
+
+// value merges one value; maps are merged key by key, anything else as a whole
+func (m *merger) value(base, local, incoming interface{}, path string) interface{} {
+	switch {
+	case reflect.DeepEqual(local, incoming):
+		return local
+	case reflect.DeepEqual(base, local):
+		return incoming
+	case reflect.DeepEqual(base, incoming):
+		return local
+	}
+
+	localMap, localIsMap := local.(map[string]interface{})
+	incomingMap, incomingIsMap := incoming.(map[string]interface{})
+	if localIsMap && incomingIsMap {
+		baseMap, _ := base.(map[string]interface{})
+		return m.maps(baseMap, localMap, incomingMap, path)
+	}
+
+	return m.conflict(local, incoming, path)
+}
+
+// maps merges two maps key by key against their common base
+func (m *merger) maps(base, local, incoming map[string]interface{}, path string) map[string]interface{} {
+	keys := make(map[string]bool)
+	for _, values := range []map[string]interface{}{base, local, incoming} {
+		for key := range values {
+			keys[key] = true
+		}
+	}
+
+	merged := make(map[string]interface{})
+	for key := range keys {
+		baseValue := lookup(base, key)
+		localValue := lookup(local, key)
+		incomingValue := lookup(incoming, key)
+
+		value := m.value(baseValue, localValue, incomingValue, joinPath(path, key))
+		if _, removed := value.(missing); !removed {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// conflict resolves a value changed differently on both sides according to the policy
+func (m *merger) conflict(local, incoming interface{}, path string) interface{} {
+	switch m.policy {
+	case PolicyPreferLocal:
+		return local
+	case PolicyPreferIncoming:
+		return incoming
+	default:
+		if path == "" {
+			path = "whole file"
+		}
+		m.conflicts = append(m.conflicts, path)
+		return local
+	}
+}
+
+// result sorts the collected conflicts into a merge result
+func (m *merger) result(content []byte) *Result {
+	sort.Strings(m.conflicts)
+	return &Result{Content: content, Conflicts: m.conflicts}
+}
+
+// mergeJSON merges JSON documents key by key
+func mergeJSON(base, local, incoming []byte, policy Policy) (*Result, error) {
+	decode := func(data []byte) (interface{}, error) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return missing{}, nil
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return value, nil
+	}
+
+	baseValue, localValue, incomingValue, err := decodeAll(decode, base, local, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{policy: policy}
+	merged := m.value(baseValue, localValue, incomingValue, "")
+
+	content, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return m.result(append(content, '\n')), nil
+}
+
+// mergeYAML merges YAML documents key by key
+func mergeYAML(base, local, incoming []byte, policy Policy) (*Result, error) {
+	decode := func(data []byte) (interface{}, error) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return missing{}, nil
+		}
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		return value, nil
+	}
+
+	baseValue, localValue, incomingValue, err := decodeAll(decode, base, local, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{policy: policy}
+	merged := m.value(baseValue, localValue, incomingValue, "")
+
+	content, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return m.result(content), nil
+}
+
+// Helper functions
+
+// decodeAll decodes the three versions of a document
+func decodeAll(decode func([]byte) (interface{}, error), base, local, incoming []byte) (interface{}, interface{}, interface{}, error) {
+	baseValue, err := decode(base)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	localValue, err := decode(local)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	incomingValue, err := decode(incoming)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return baseValue, localValue, incomingValue, nil
+}
+
+// lookup returns a map value, or missing when the map has no such key
+func lookup(values map[string]interface{}, key string) interface{} {
+	if value, exists := values[key]; exists {
+		return value
+	}
+	return missing{}
+}
+
+// joinPath appends a key to a dotted key path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,237 @@
+package merge
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMergeJSON(t *testing.T) {
+	base := []byte(`{"theme": "light", "fontSize": 12, "editor": {"tabSize": 4, "wrap": false}}`)
+	local := []byte(`{"theme": "light", "fontSize": 14, "editor": {"tabSize": 4, "wrap": false}}`)
+	incoming := []byte(`{"theme": "dark", "fontSize": 12, "editor": {"tabSize": 2, "wrap": false}, "telemetry": false}`)
+
+	result, err := Merge("settings.json", base, local, incoming, PolicyReport)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", result.Conflicts)
+	}
+
+	content := string(result.Content)
+	for _, want := range []string{`"theme": "dark"`, `"fontSize": 14`, `"tabSize": 2`, `"telemetry": false`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected merged JSON to contain %s, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestMergeJSONConflicts(t *testing.T) {
+	base := []byte(`{"theme": "light", "editor": {"tabSize": 4}}`)
+	local := []byte(`{"theme": "solarized", "editor": {"tabSize": 8}}`)
+	incoming := []byte(`{"theme": "dark", "editor": {"tabSize": 2}}`)
+
+	tests := []struct {
+		policy    Policy
+		want      string
+		conflicts []string
+	}{
+		{PolicyReport, `"theme": "solarized"`, []string{"editor.tabSize", "theme"}},
+		{PolicyPreferLocal, `"theme": "solarized"`, nil},
+		{PolicyPreferIncoming, `"theme": "dark"`, nil},
+	}
+
+	for _, tt := range tests {
+		result, err := Merge("settings.json", base, local, incoming, tt.policy)
+		if err != nil {
+			t.Fatalf("Merge(%q) failed: %v", tt.policy, err)
+		}
+		if strings.Join(result.Conflicts, ",") != strings.Join(tt.conflicts, ",") {
+			t.Errorf("Merge(%q) conflicts = %v, want %v", tt.policy, result.Conflicts, tt.conflicts)
+		}
+		if !strings.Contains(string(result.Content), tt.want) {
+			t.Errorf("Merge(%q) expected %s, got:\n%s", tt.policy, tt.want, result.Content)
+		}
+	}
+}
+
+func TestMergeJSONInvalid(t *testing.T) {
+	if _, err := Merge("settings.json", nil, []byte("{"), []byte("{}"), PolicyReport); err == nil {
+		t.Error("Expected invalid JSON to fail the merge")
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	base := []byte("name: test\nsettings:\n  color: auto\n  pager: less\n")
+	local := []byte("name: test\nsettings:\n  color: always\n  pager: less\n")
+	incoming := []byte("name: test\nsettings:\n  color: auto\n  pager: delta\n")
+
+	result, err := Merge("config.yml", base, local, incoming, PolicyReport)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", result.Conflicts)
+	}
+	if !strings.Contains(string(result.Content), "color: always") || !strings.Contains(string(result.Content), "pager: delta") {
+		t.Errorf("Expected both changes in merged YAML, got:\n%s", result.Content)
+	}
+}
+
+func TestMergeText(t *testing.T) {
+	base := []byte("[user]\n\tname = Test\n[core]\n\teditor = vim\n[pull]\n\trebase = false\n")
+	local := []byte("[user]\n\tname = Local\n[core]\n\teditor = vim\n[pull]\n\trebase = false\n")
+	incoming := []byte("[user]\n\tname = Test\n[core]\n\teditor = vim\n[pull]\n\trebase = true\n[push]\n\tdefault = current\n")
+
+	result, err := Merge(".gitconfig", base, local, incoming, PolicyReport)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", result.Conflicts)
+	}
+
+	want := "[user]\n\tname = Local\n[core]\n\teditor = vim\n[pull]\n\trebase = true\n[push]\n\tdefault = current\n"
+	if string(result.Content) != want {
+		t.Errorf("Unexpected merged text:\n%s\nwant:\n%s", result.Content, want)
+	}
+}
+
+func TestMergeTextConflicts(t *testing.T) {
+	base := []byte("a\nb\nc\n")
+	local := []byte("a\nlocal\nc\n")
+	incoming := []byte("a\nincoming\nc\n")
+
+	result, _ := Merge("file.conf", base, local, incoming, PolicyReport)
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "lines 2-2" {
+		t.Errorf("Expected one conflict on line 2, got %v", result.Conflicts)
+	}
+	if string(result.Content) != string(local) {
+		t.Errorf("Expected local lines to be kept on conflict, got %q", result.Content)
+	}
+
+	result, _ = Merge("file.conf", base, local, incoming, PolicyPreferIncoming)
+	if len(result.Conflicts) != 0 || string(result.Content) != string(incoming) {
+		t.Errorf("Expected incoming lines with prefer-incoming, got %q (%v)", result.Content, result.Conflicts)
+	}
+}
+
+func TestMergeLargeText(t *testing.T) {
+	// Local changes every other line and incoming appends one, so little is matched up front
+	var base, local, incoming strings.Builder
+	for i := 0; i <= 5000; i++ {
+		fmt.Fprintf(&base, "line %d\n", i)
+		if i%2 == 0 {
+			fmt.Fprintf(&local, "line %d\n", i)
+		} else {
+			fmt.Fprintf(&local, "local %d\n", i)
+		}
+	}
+	incoming.WriteString(base.String() + "incoming\n")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	result, err := Merge("file.conf", []byte(base.String()), []byte(local.String()), []byte(incoming.String()), PolicyReport)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 || string(result.Content) != local.String()+"incoming\n" {
+		t.Errorf("Expected both sides' changes to be merged, got conflicts %v", result.Conflicts)
+	}
+
+	// A table of every pair of lines would take 100 MB
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Expected merging to need memory linear in the number of lines, allocated %d bytes", allocated)
+	}
+}
+
+func TestMergeTextWithoutBase(t *testing.T) {
+	result, _ := Merge("file.conf", nil, []byte("local\n"), []byte("incoming\n"), PolicyReport)
+	if len(result.Conflicts) != 1 {
+		t.Errorf("Expected differing files without a base to conflict, got %v", result.Conflicts)
+	}
+}
+
+func TestMergePlist(t *testing.T) {
+	plist := func(body string) []byte {
+		return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>` + body + `</dict>
+</plist>
+`)
+	}
+
+	base := plist(`<key>ShowHidden</key><false/><key>IconSize</key><integer>48</integer>`)
+	local := plist(`<key>ShowHidden</key><true/><key>IconSize</key><integer>48</integer>`)
+	incoming := plist(`<key>ShowHidden</key><false/><key>IconSize</key><integer>64</integer><key>Token</key><data>aGVsbG8=</data><key>Seen</key><date>2024-01-02T03:04:05Z</date>`)
+
+	result, err := Merge("com.example.app.plist", base, local, incoming, PolicyReport)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", result.Conflicts)
+	}
+
+	merged, err := decodePlist(result.Content)
+	if err != nil {
+		t.Fatalf("Merged property list does not parse: %v\n%s", err, result.Content)
+	}
+	values := merged.(map[string]interface{})
+	if values["ShowHidden"] != true || values["IconSize"] != int64(64) {
+		t.Errorf("Expected both changes in merged property list, got %v", values)
+	}
+	if string(values["Token"].(plistData)) != "hello" {
+		t.Errorf("Expected data value to round-trip, got %v", values["Token"])
+	}
+	if _, ok := values["Seen"].(plistDate); !ok {
+		t.Errorf("Expected date value to round-trip, got %T", values["Seen"])
+	}
+}
+
+func TestMergeBinaryPlist(t *testing.T) {
+	var formats []string
+	original := convertPlist
+	convertPlist = func(data []byte, format string) ([]byte, error) {
+		formats = append(formats, format)
+		if format == "binary1" {
+			return append([]byte(binaryPlistHeader), data...), nil
+		}
+		return data[len(binaryPlistHeader):], nil
+	}
+	defer func() { convertPlist = original }()
+
+	xmlPlist := []byte(`<plist version="1.0"><dict><key>A</key><string>1</string></dict></plist>`)
+	binary := append([]byte(binaryPlistHeader), xmlPlist...)
+
+	result, err := Merge("prefs.plist", binary, binary, xmlPlist, PolicyReport)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !strings.HasPrefix(string(result.Content), binaryPlistHeader) {
+		t.Error("Expected merged property list to be written back in binary format")
+	}
+	if formats[len(formats)-1] != "binary1" {
+		t.Errorf("Expected final conversion to binary1, got %v", formats)
+	}
+}
+
+func TestMergeBinaryFilesAsWhole(t *testing.T) {
+	base := []byte("a\x00b\n")
+	local := []byte("a\x00local\n")
+	incoming := []byte("a\x00incoming\n")
+
+	result, _ := Merge("cache.db", base, local, incoming, PolicyReport)
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "whole file" {
+		t.Errorf("Expected binary files to conflict as a whole, got %v", result.Conflicts)
+	}
+
+	result, _ = Merge("cache.db", base, base, incoming, PolicyReport)
+	if len(result.Conflicts) != 0 || string(result.Content) != string(incoming) {
+		t.Errorf("Expected unchanged local binary file to take the incoming version, got %q", result.Content)
+	}
+}
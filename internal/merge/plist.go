@@ -0,0 +1,277 @@
+package merge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binaryPlistHeader starts every binary property list
+const binaryPlistHeader = "bplist00"
+
+// plistDate and plistData keep property list dates and data apart from strings
+type (
+	plistDate time.Time
+	plistData []byte
+)
+
+// convertPlist converts a property list between formats ("xml1" or "binary1") with plutil.
+// It is a variable so tests can run without plutil.
+var convertPlist = func(data []byte, format string) ([]byte, error) {
+	cmd := exec.Command("plutil", "-convert", format, "-o", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert property list to %s: %w", format, err)
+	}
+	return output, nil
+}
+
+// mergePlist merges property lists key by key. Binary property lists are converted to XML
+// for merging, and the result is written in the format of the local file.
+func mergePlist(base, local, incoming []byte, policy Policy) (*Result, error) {
+	decode := func(data []byte) (interface{}, error) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return missing{}, nil
+		}
+		if bytes.HasPrefix(data, []byte(binaryPlistHeader)) {
+			converted, err := convertPlist(data, "xml1")
+			if err != nil {
+				return nil, err
+			}
+			data = converted
+		}
+		return decodePlist(data)
+	}
+
+	baseValue, localValue, incomingValue, err := decodeAll(decode, base, local, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{policy: policy}
+	merged := m.value(baseValue, localValue, incomingValue, "")
+
+	content, err := encodePlist(merged)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(local, []byte(binaryPlistHeader)) {
+		if content, err = convertPlist(content, "binary1"); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.result(content), nil
+}
+
+// decodePlist parses an XML property list into maps, slices, and scalar values
+func decodePlist(data []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse property list: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "plist" {
+				return nil, fmt.Errorf("failed to parse property list: unexpected <%s>", start.Name.Local)
+			}
+			break
+		}
+	}
+
+	start, err := nextElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+	return decodePlistValue(decoder, start)
+}
+
+// decodePlistValue decodes the value starting with the given element
+func decodePlistValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		values := make(map[string]interface{})
+		for {
+			keyStart, err := nextElement(decoder)
+			if err == io.EOF {
+				return values, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if keyStart.Name.Local != "key" {
+				return nil, fmt.Errorf("failed to parse property list: expected <key>, found <%s>", keyStart.Name.Local)
+			}
+			key, err := elementText(decoder)
+			if err != nil {
+				return nil, err
+			}
+			valueStart, err := nextElement(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse property list: missing value for key %s", key)
+			}
+			value, err := decodePlistValue(decoder, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+	case "array":
+		values := []interface{}{}
+		for {
+			valueStart, err := nextElement(decoder)
+			if err == io.EOF {
+				return values, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodePlistValue(decoder, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	text, err := elementText(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "date":
+		date, err := time.Parse(time.RFC3339, strings.TrimSpace(text))
+		return plistDate(date), err
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+		return plistData(data), err
+	default:
+		return nil, fmt.Errorf("failed to parse property list: unsupported element <%s>", start.Name.Local)
+	}
+}
+
+// nextElement returns the next start element, or io.EOF when the enclosing element ends first
+func nextElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("failed to parse property list: %w", err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			return element, nil
+		case xml.EndElement:
+			return xml.StartElement{}, io.EOF
+		}
+	}
+}
+
+// elementText reads the text of the current element up to its end
+func elementText(decoder *xml.Decoder) (string, error) {
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse property list: %w", err)
+		}
+		switch element := token.(type) {
+		case xml.CharData:
+			text.Write(element)
+		case xml.EndElement:
+			return text.String(), nil
+		case xml.StartElement:
+			return "", fmt.Errorf("failed to parse property list: unexpected <%s>", element.Name.Local)
+		}
+	}
+}
+
+// encodePlist writes a value as an XML property list
+func encodePlist(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString(`<plist version="1.0">` + "\n")
+	if err := encodePlistValue(&buf, value, ""); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</plist>\n")
+	return buf.Bytes(), nil
+}
+
+// encodePlistValue writes one value at the given indentation
+func encodePlistValue(buf *bytes.Buffer, value interface{}, indent string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteString(indent + "<dict>\n")
+		for _, key := range keys {
+			buf.WriteString(indent + "\t<key>" + escapeXML(key) + "</key>\n")
+			if err := encodePlistValue(buf, v[key], indent+"\t"); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(indent + "</dict>\n")
+	case []interface{}:
+		buf.WriteString(indent + "<array>\n")
+		for _, item := range v {
+			if err := encodePlistValue(buf, item, indent+"\t"); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(indent + "</array>\n")
+	case string:
+		buf.WriteString(indent + "<string>" + escapeXML(v) + "</string>\n")
+	case int64:
+		buf.WriteString(indent + "<integer>" + strconv.FormatInt(v, 10) + "</integer>\n")
+	case float64:
+		buf.WriteString(indent + "<real>" + strconv.FormatFloat(v, 'g', -1, 64) + "</real>\n")
+	case bool:
+		if v {
+			buf.WriteString(indent + "<true/>\n")
+		} else {
+			buf.WriteString(indent + "<false/>\n")
+		}
+	case plistDate:
+		buf.WriteString(indent + "<date>" + time.Time(v).UTC().Format(time.RFC3339) + "</date>\n")
+	case plistData:
+		buf.WriteString(indent + "<data>" + base64.StdEncoding.EncodeToString(v) + "</data>\n")
+	case missing:
+		buf.WriteString(indent + "<dict>\n" + indent + "</dict>\n")
+	default:
+		return fmt.Errorf("cannot encode %T in a property list", value)
+	}
+	return nil
+}
+
+// escapeXML escapes text for use in XML element content
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
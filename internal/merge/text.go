@@ -0,0 +1,216 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// maxTextLines bounds the size of files merged line by line; larger files are treated as one conflicting block
+const maxTextLines = 20000
+
+// mergeText merges text files line by line (diff3): regions changed on only one side take that
+// side's lines, and regions changed differently on both sides are conflicts.
+// Binary files are never merged line by line.
+func mergeText(base, local, incoming []byte, policy Policy) *Result {
+	m := &merger{policy: policy}
+	if isBinary(base) || isBinary(local) || isBinary(incoming) {
+		return m.result(m.value(base, local, incoming, "").([]byte))
+	}
+
+	baseLines := splitLines(string(base))
+	localLines := splitLines(string(local))
+	incomingLines := splitLines(string(incoming))
+
+	if len(baseLines) > maxTextLines || len(localLines) > maxTextLines || len(incomingLines) > maxTextLines {
+		chosen := m.conflict(localLines, incomingLines, "whole file").([]string)
+		return m.result([]byte(strings.Join(chosen, "")))
+	}
+
+	localMatch := matchLines(baseLines, localLines)
+	incomingMatch := matchLines(baseLines, incomingLines)
+
+	var merged []string
+	baseStart, localStart, incomingStart := 0, 0, 0
+	for i := 0; i <= len(baseLines); i++ {
+		// Find the next base line kept unchanged on both sides, or the end of the file
+		if i < len(baseLines) && (localMatch[i] < 0 || incomingMatch[i] < 0) {
+			continue
+		}
+
+		localEnd, incomingEnd := len(localLines), len(incomingLines)
+		if i < len(baseLines) {
+			localEnd, incomingEnd = localMatch[i], incomingMatch[i]
+		}
+
+		baseChunk := baseLines[baseStart:i]
+		localChunk := localLines[localStart:localEnd]
+		incomingChunk := incomingLines[incomingStart:incomingEnd]
+
+		switch {
+		case equalLines(localChunk, incomingChunk), equalLines(baseChunk, incomingChunk):
+			merged = append(merged, localChunk...)
+		case equalLines(baseChunk, localChunk):
+			merged = append(merged, incomingChunk...)
+		default:
+			chosen := m.conflict(localChunk, incomingChunk, describeLines(localStart, localEnd)).([]string)
+			merged = append(merged, chosen...)
+		}
+
+		if i < len(baseLines) {
+			merged = append(merged, baseLines[i])
+			baseStart, localStart, incomingStart = i+1, localEnd+1, incomingEnd+1
+		}
+	}
+
+	return m.result([]byte(strings.Join(merged, "")))
+}
+
+// matchLines returns, for each base line, the index of the matching line in other
+// according to their longest common subsequence, or -1 when the line was changed or removed.
+// The lines both sides start and end with are matched first, and the rest with Hirschberg's
+// algorithm, which needs memory linear in the number of lines rather than their product.
+func matchLines(base, other []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+
+	prefix := 0
+	for prefix < len(base) && prefix < len(other) && base[prefix] == other[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(other)-prefix && base[len(base)-1-suffix] == other[len(other)-1-suffix] {
+		match[len(base)-1-suffix] = len(other) - 1 - suffix
+		suffix++
+	}
+
+	// Compare numbers standing for the lines rather than the lines themselves
+	ids := make(map[string]int32)
+	number := func(lines []string) []int32 {
+		numbered := make([]int32, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = int32(len(ids))
+				ids[line] = id
+			}
+			numbered[i] = id
+		}
+		return numbered
+	}
+	a := number(base[prefix : len(base)-suffix])
+	b := number(other[prefix : len(other)-suffix])
+	matchMiddle(a, b, prefix, prefix, match)
+
+	return match
+}
+
+// matchMiddle records in match the lines of b matched to the lines of a by their longest common
+// subsequence. aStart and bStart are the positions of a and b in the files.
+func matchMiddle(a, b []int32, aStart, bStart int, match []int) {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				match[aStart] = bStart + j
+				return
+			}
+		}
+		return
+	}
+
+	// Split b where the subsequences of the two halves of a together are longest
+	mid := len(a) / 2
+	forward := prefixLCS(a[:mid], b)
+	backward := suffixLCS(a[mid:], b)
+	split, best := 0, int32(-1)
+	for j := 0; j <= len(b); j++ {
+		if length := forward[j] + backward[j]; length > best {
+			split, best = j, length
+		}
+	}
+
+	matchMiddle(a[:mid], b[:split], aStart, bStart, match)
+	matchMiddle(a[mid:], b[split:], aStart+mid, bStart+split, match)
+}
+
+// prefixLCS returns, for each j, the length of the longest common subsequence of a and b[:j]
+func prefixLCS(a, b []int32) []int32 {
+	prev, cur := make([]int32, len(b)+1), make([]int32, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// suffixLCS returns, for each j, the length of the longest common subsequence of a and b[j:]
+func suffixLCS(a, b []int32) []int32 {
+	prev, cur := make([]int32, len(b)+1), make([]int32, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				cur[j] = prev[j+1] + 1
+			case prev[j] >= cur[j+1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j+1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// splitLines splits text into lines that keep their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// isBinary reports whether content looks like binary data rather than text
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// describeLines names a range of local lines in a conflict
+func describeLines(start, end int) string {
+	if end <= start {
+		return fmt.Sprintf("after line %d", start)
+	}
+	return fmt.Sprintf("lines %d-%d", start+1, end)
+}
+
+// equalLines reports whether two runs of lines are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
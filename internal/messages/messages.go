@@ -0,0 +1,295 @@
+// Package messages holds the user-facing messages of the commands, such as shared errors and the
+// results they print, in catalogs, one per language, looked up by ID in the style of go-i18n. English is built in and is the fallback
+// for messages a catalog leaves out; other languages are read from <language>.yaml files in the
+// locales directory of the configuration.
+package messages
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage is the language of the built-in catalog
+const DefaultLanguage = "en"
+
+// Message IDs
+const (
+	NotInitialized       = "not-initialized"
+	ConfigLoadFailed     = "config-load-failed"
+	ConfigSaveFailed     = "config-save-failed"
+	AppNotConfigured     = "app-not-configured" // Data: App
+	AppsNotInBundle      = "apps-not-in-bundle" // Data: Apps
+	NoAppsConfigured     = "no-apps-configured"
+	ConfirmationRequired = "confirmation-required" // Data: Action
+	StoreBusy            = "store-busy"
+	StoreReadOnly        = "store-read-only" // Data: Action
+
+	AppDetectFailed          = "app-detect-failed"   // Data: App, Error
+	AppAddRefused            = "app-add-refused"     // Data: App, Error
+	AppAddFailed             = "app-add-failed"      // Data: App, Error
+	AppAdded                 = "app-added"           // Data: App, Count
+	AppsAdded                = "apps-added"          // Data: Count
+	AppsAddFailed            = "apps-add-failed"     // Data: Count
+	BackupsExported          = "backups-exported"    // Data: Count, Size, Path
+	BackupsImported          = "backups-imported"    // Data: Count
+	SigningKeyCreated        = "signing-key-created" // Data: Path
+	BundleAlreadyMatches     = "bundle-already-matches"
+	CatalogInstalled         = "catalog-installed"   // Data: Path
+	CatalogUpdated           = "catalog-updated"     // Data: Path
+	CheckpointCreated        = "checkpoint-created"  // Data: Name
+	CheckpointRestored       = "checkpoint-restored" // Data: Name
+	CheckpointDeleted        = "checkpoint-deleted"  // Data: Name
+	NothingToClean           = "nothing-to-clean"
+	DeleteFailed             = "delete-failed" // Data: Path, Error
+	ItemsDeleted             = "items-deleted" // Data: Count, Size
+	AppsMoved                = "apps-moved"    // Data: Count, Path
+	ConfigValid              = "config-valid"
+	AppDiscovered            = "app-discovered"  // Data: App, Count
+	AppsDiscovered           = "apps-discovered" // Data: Count
+	ConfigValidAt            = "config-valid-at" // Data: Path
+	ConfigProblems           = "config-problems" // Data: Count, Path
+	FullDiskAccessGranted    = "full-disk-access-granted"
+	FullDiskAccessMissing    = "full-disk-access-missing"
+	FullDiskAccessUnknown    = "full-disk-access-unknown"
+	PathsAccessible          = "paths-accessible"
+	PermissionsOk            = "permissions-ok"
+	PermissionFixed          = "permission-fixed"      // Data: App, Path, Problem
+	PermissionFixFailed      = "permission-fix-failed" // Data: App, Path, Problem, Error
+	PermissionProblem        = "permission-problem"    // Data: App, Path, Problem
+	PathInaccessible         = "path-inaccessible"     // Data: App, Path, Reason
+	LargePaths               = "large-paths"           // Data: Count, Size
+	AppConfigSaved           = "app-config-saved"      // Data: App
+	AppUnsyncFailed          = "app-unsync-failed"     // Data: App, Error
+	RepoExported             = "repo-exported"         // Data: Path
+	StoreAllReferenced       = "store-all-referenced"
+	UnreferencedDeleted      = "unreferenced-deleted"    // Data: Count, Size
+	Initialized              = "initialized"             // Data: Path
+	ImportCleanupFailed      = "import-cleanup-failed"   // Data: App, Error
+	PackageImported          = "package-imported"        // Data: App, Paths
+	AppsSkipped              = "apps-skipped"            // Data: Count
+	AppsImported             = "apps-imported"           // Data: Count
+	PeerForgotten            = "peer-forgotten"          // Data: Name
+	PeerPaired               = "peer-paired"             // Data: Name, Address
+	ChecksumsRecordFailed    = "checksums-record-failed" // Data: Error
+	StoresMatch              = "stores-match"
+	PathBackupFailed         = "path-backup-failed"    // Data: Path, Error
+	AppBackedUp              = "app-backed-up"         // Data: App
+	AppsBackedUp             = "apps-backed-up"        // Data: Count
+	AppsBackupFailed         = "apps-backup-failed"    // Data: Count
+	BackupInvalid            = "backup-invalid"        // Data: App, Path, Error
+	BackupValid              = "backup-valid"          // Data: App, Path
+	BackupPruneFailed        = "backup-prune-failed"   // Data: App, Error
+	BundleExported           = "bundle-exported"       // Data: Path
+	BundleLineageFailed      = "bundle-lineage-failed" // Data: Error
+	BundleImported           = "bundle-imported"
+	ChecksumVerified         = "checksum-verified"
+	BundleNotPinned          = "bundle-not-pinned"
+	BackupListFailed         = "backup-list-failed"          // Data: Path, Error
+	PathRestoreFailed        = "path-restore-failed"         // Data: Path, Error
+	RestorePathUnmatched     = "restore-path-unmatched"      // Data: App, Paths
+	Restored                 = "restored"                    // Data: Name
+	AppsRestored             = "apps-restored"               // Data: Count
+	AppsRestoreFailed        = "apps-restore-failed"         // Data: Count
+	Provisioned              = "provisioned"                 // Data: Host, Bundle
+	AppRemoveFailed          = "app-remove-failed"           // Data: App, Error
+	AppRemoved               = "app-removed"                 // Data: App
+	BlobManifestRemoveFailed = "blob-manifest-remove-failed" // Data: App, Error
+	ScheduleInstalled        = "schedule-installed"          // Data: Schedule
+	ScheduleRemoved          = "schedule-removed"
+	SnapshotCreated          = "snapshot-created"          // Data: Name
+	SnapshotRestored         = "snapshot-restored"         // Data: Name
+	SnapshotPathsSkipped     = "snapshot-paths-skipped"    // Data: Count
+	DeploymentCompareFailed  = "deployment-compare-failed" // Data: App, Error
+	ConflictCheckFailed      = "conflict-check-failed"     // Data: Path, Error
+	SymlinkReplaced          = "symlink-replaced"          // Data: Path
+	LinkMisdirected          = "link-misdirected"          // Data: Path, Status
+	ConflictedCopy           = "conflicted-copy"           // Data: Path, Provider, Original
+	ChecksumsVerified        = "checksums-verified"        // Data: Count
+	VerifySection            = "verify-section"            // Data: Title, Count
+	StoreMoved               = "store-moved"               // Data: Path
+	NoConflictedCopies       = "no-conflicted-copies"
+	ConflictKeptCopy         = "conflict-kept-copy"          // Data: Path
+	ConflictKeptOriginal     = "conflict-kept-original"      // Data: Path
+	StoreDeduplicated        = "store-deduplicated"          // Data: Count, Blobs
+	BlobEditedInPlace        = "blob-edited-in-place"        // Data: Path
+	AppFilesRebuilt          = "app-files-rebuilt"           // Data: App, Count
+	StoreLockedDown          = "store-locked-down"           // Data: Path
+	StoreUnlocked            = "store-unlocked"              // Data: Path
+	LastSyncUpdateFailed     = "last-sync-update-failed"     // Data: Error
+	StoreDeduplicateFailed   = "store-deduplicate-failed"    // Data: Error
+	AppSyncFailed            = "app-sync-failed"             // Data: App, Error
+	AppSynced                = "app-synced"                  // Data: App, Duration
+	AppMetadataRefreshFailed = "app-metadata-refresh-failed" // Data: Error
+	SystemSettingsCaptured   = "system-settings-captured"    // Data: Count, Path
+	SystemSettingsApplied    = "system-settings-applied"     // Data: Count
+	SystemSettingsMatch      = "system-settings-match"
+	Undone                   = "undone"                 // Data: Operation
+	UndoneFromSnapshot       = "undone-from-snapshot"   // Data: Operation, Name
+	UndoPointFailed          = "undo-point-failed"      // Data: Operation, Error
+	ScheduleRemoveFailed     = "schedule-remove-failed" // Data: Error
+	Uninstalled              = "uninstalled"            // Data: Count
+	GitSourceCurrent         = "git-source-current"     // Data: App, Path, Revision
+	GitSourcePulled          = "git-source-pulled"      // Data: App, Count, Repo, Path, Revision
+	NoUpgradeMoves           = "no-upgrade-moves"
+	LinksCorrect             = "links-correct" // Data: Count
+	WizardEnterDays          = "wizard-enter-days"
+	WizardEnterNumber        = "wizard-enter-number"       // Data: Max
+	StoreGitInitFailed       = "store-git-init-failed"     // Data: Error
+	AppEnableFailed          = "app-enable-failed"         // Data: App, Error
+	AppDisableFailed         = "app-disable-failed"        // Data: App, Error
+	AppsEnabled              = "apps-enabled"              // Data: Count
+	AppsDisabled             = "apps-disabled"             // Data: Count
+	AppsRemoved              = "apps-removed"              // Data: Count
+	AppsWouldBeRemoved       = "apps-would-be-removed"     // Data: Count
+	AppsRemoveFailed         = "apps-remove-failed"        // Data: Count
+	AppsWouldFailToRemove    = "apps-would-fail-to-remove" // Data: Count
+	AppsSynced               = "apps-synced"               // Data: Count
+	AppsWouldBeSynced        = "apps-would-be-synced"      // Data: Count
+	AppsSyncFailed           = "apps-sync-failed"          // Data: Count
+	AppsWouldFailToSync      = "apps-would-fail-to-sync"   // Data: Count
+	AppDiverged              = "app-diverged"              // Data: App, Bundle, Date
+	NothingToExport          = "nothing-to-export"
+)
+
+//go:embed locales/*.yaml
+var builtIn embed.FS
+
+// Data fills in the fields of a message, such as App
+type Data map[string]interface{}
+
+// Catalog holds the messages of a language, falling back to English
+type Catalog struct {
+	language  string
+	templates map[string]*template.Template
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Catalog
+)
+
+// Language returns the language selected by CONFIGSYNC_LANG, or else by the locale in LC_ALL,
+// LC_MESSAGES, or LANG, as a lowercase tag such as "de" or "pt-br"
+func Language() string {
+	for _, name := range []string{"CONFIGSYNC_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		// Drop the encoding and modifier, as in de_DE.UTF-8@euro
+		value, _, _ = strings.Cut(value, ".")
+		value, _, _ = strings.Cut(value, "@")
+		if value == "C" || value == "POSIX" {
+			return DefaultLanguage
+		}
+		return strings.ToLower(strings.ReplaceAll(value, "_", "-"))
+	}
+	return DefaultLanguage
+}
+
+// NewCatalog loads the messages of a language from dir, falling back to the language without
+// its region (de for de-at) and then to English. dir may be empty to use English only. Messages
+// that cannot be parsed are left out, so a broken translation never hides a message.
+func NewCatalog(language, dir string) *Catalog {
+	c := &Catalog{language: language, templates: make(map[string]*template.Template)}
+
+	chain := []string{language}
+	if base, _, found := strings.Cut(language, "-"); found {
+		chain = append(chain, base)
+	}
+	for _, lang := range chain {
+		if dir == "" || lang == DefaultLanguage {
+			break
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, lang+".yaml")); err == nil {
+			c.add(data)
+		}
+	}
+
+	data, err := builtIn.ReadFile("locales/" + DefaultLanguage + ".yaml")
+	if err != nil {
+		panic(err) // The built-in catalog is embedded at build time
+	}
+	c.add(data)
+	return c
+}
+
+// add adds the messages in a catalog file that are not defined yet
+func (c *Catalog) add(data []byte) {
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return
+	}
+	for id, text := range entries {
+		if _, ok := c.templates[id]; ok {
+			continue
+		}
+		tmpl, err := template.New(id).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			continue
+		}
+		c.templates[id] = tmpl
+	}
+}
+
+// Language returns the language the catalog was loaded for
+func (c *Catalog) Language() string {
+	return c.language
+}
+
+// Localize returns a message filled in with data, or its ID if no catalog defines it
+func (c *Catalog) Localize(id string, data Data) string {
+	tmpl, ok := c.templates[id]
+	if !ok {
+		return id
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return id
+	}
+	return b.String()
+}
+
+// Use makes a catalog the one the package-level functions look messages up in
+func Use(c *Catalog) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = c
+}
+
+// catalog returns the catalog in use, loading English if none was selected
+func catalog() *Catalog {
+	currentMu.RLock()
+	c := current
+	currentMu.RUnlock()
+	if c != nil {
+		return c
+	}
+	c = NewCatalog(DefaultLanguage, "")
+	Use(c)
+	return c
+}
+
+// Localize returns a message in the catalog in use, filled in with data
+func Localize(id string, data Data) string {
+	return catalog().Localize(id, data)
+}
+
+// Error returns a message in the catalog in use as an error
+func Error(id string, data Data) error {
+	return errors.New(Localize(id, data))
+}
+
+// Wrap returns a message in the catalog in use as an error wrapping err, as "message: err"
+func Wrap(id string, data Data, err error) error {
+	return fmt.Errorf("%s: %w", Localize(id, data), err)
+}
@@ -0,0 +1,94 @@
+package messages
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltInCatalogDefinesEveryMessage(t *testing.T) {
+	// Every string constant declared in messages.go is a message ID
+	file, err := parser.ParseFile(token.NewFileSet(), "messages.go", nil, 0)
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	var ids []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			if lit, ok := value.Values[0].(*ast.BasicLit); ok && value.Names[0].Name != "DefaultLanguage" {
+				ids = append(ids, strings.Trim(lit.Value, `"`))
+			}
+		}
+	}
+	if len(ids) < 100 {
+		t.Fatalf("Expected the message IDs to be declared in messages.go, found %d", len(ids))
+	}
+
+	catalog := NewCatalog(DefaultLanguage, "")
+	for _, id := range ids {
+		if _, ok := catalog.templates[id]; !ok {
+			t.Errorf("Expected message %s to be defined", id)
+		}
+	}
+
+	if text := catalog.Localize(AppNotConfigured, Data{"App": "editor"}); text != "application editor is not configured" {
+		t.Errorf("Unexpected message: %q", text)
+	}
+}
+
+func TestCatalogFallback(t *testing.T) {
+	dir := t.TempDir()
+	translation := "app-not-configured: \"Anwendung {{.App}} ist nicht eingerichtet\"\nnot-initialized: \"{{.Broken\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "de.yaml"), []byte(translation), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// de-at falls back to de, and messages de leaves out or breaks fall back to English
+	catalog := NewCatalog("de-at", dir)
+	if text := catalog.Localize(AppNotConfigured, Data{"App": "editor"}); text != "Anwendung editor ist nicht eingerichtet" {
+		t.Errorf("Expected the German message, got %q", text)
+	}
+	if text := catalog.Localize(NotInitialized, nil); text != NewCatalog(DefaultLanguage, "").Localize(NotInitialized, nil) {
+		t.Errorf("Expected the English message for a broken translation, got %q", text)
+	}
+	if text := catalog.Localize("no-such-message", nil); text != "no-such-message" {
+		t.Errorf("Expected the ID of an unknown message, got %q", text)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		configsyncLang, lang, expected string
+	}{
+		{"", "de_DE.UTF-8", "de-de"},
+		{"", "C", DefaultLanguage},
+		{"fr", "de_DE.UTF-8", "fr"},
+		{"", "", DefaultLanguage},
+	} {
+		t.Setenv("CONFIGSYNC_LANG", tc.configsyncLang)
+		t.Setenv("LC_ALL", "")
+		t.Setenv("LC_MESSAGES", "")
+		t.Setenv("LANG", tc.lang)
+		if language := Language(); language != tc.expected {
+			t.Errorf("Expected %s for LANG=%q CONFIGSYNC_LANG=%q, got %s", tc.expected, tc.lang, tc.configsyncLang, language)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("permission denied")
+	err := Wrap(ConfigLoadFailed, nil, cause)
+	if !errors.Is(err, cause) || err.Error() != "failed to load configuration: permission denied" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
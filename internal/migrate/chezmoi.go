@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// chezmoiName holds what the prefixes and suffixes of a chezmoi source name say about its target
+type chezmoiName struct {
+	target     string
+	skipReason string // Why the entry cannot be imported as a plain file, if it cannot
+	private    bool
+	executable bool
+	readonly   bool
+}
+
+// ReadChezmoi reads a chezmoi source directory. Source names are translated to their targets
+// (dot_gitconfig to .gitconfig, private_dot_ssh to .ssh), and every top-level target becomes
+// one application, descending into shared directories such as ~/.config. Templates, scripts,
+// encrypted files, and other entries chezmoi generates at apply time are skipped.
+func ReadChezmoi(dir string) (*Result, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read chezmoi directory: %w", err)
+	}
+
+	result := &Result{}
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		// chezmoi ignores names starting with a dot in the source directory, and uses .chezmoi* for its own files
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target, name := chezmoiTarget(rel, d.IsDir())
+		if name.skipReason != "" {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %s", rel, name.skipReason))
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: not a regular file", rel))
+			return nil
+		}
+
+		file := File{Source: path, Target: target}
+		switch {
+		case name.private && name.executable:
+			file.Mode = 0700
+		case name.private:
+			file.Mode = 0600
+		case name.executable:
+			file.Mode = 0755
+		}
+		if name.readonly {
+			if file.Mode == 0 {
+				file.Mode = 0644
+			}
+			file.Mode &^= 0222
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chezmoi directory: %w", err)
+	}
+
+	packages := make(map[string]*Package)
+	for _, entry := range groupEntries(files) {
+		name := appName(entry.Target)
+		if packages[name] == nil {
+			packages[name] = &Package{Name: name}
+		}
+		packages[name].Entries = append(packages[name].Entries, entry)
+	}
+	for _, pkg := range packages {
+		result.Packages = append(result.Packages, *pkg)
+	}
+
+	sort.Slice(result.Packages, func(i, j int) bool { return result.Packages[i].Name < result.Packages[j].Name })
+	return result, nil
+}
+
+// chezmoiTarget translates a source path to its target path relative to the home directory.
+// The attributes returned are those of the last path component.
+func chezmoiTarget(rel string, dir bool) (string, chezmoiName) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	var name chezmoiName
+	for i, part := range parts {
+		name = parseChezmoiName(part, dir || i < len(parts)-1)
+		if name.skipReason != "" {
+			return "", name
+		}
+		parts[i] = name.target
+	}
+	name.target = filepath.FromSlash(strings.Join(parts, "/"))
+	return name.target, name
+}
+
+// parseChezmoiName reads the attribute prefixes and suffixes of one chezmoi source name.
+// Prefixes that make an entry something other than a plain file only count at the start of the
+// name, as in chezmoi, so executable_run_me is the executable file run_me.
+func parseChezmoiName(part string, dir bool) chezmoiName {
+	name := chezmoiName{}
+
+	switch {
+	case strings.HasPrefix(part, "encrypted_"):
+		name.skipReason = "encrypted; decrypt it with chezmoi and add it with 'configsync add --path'"
+	case strings.HasPrefix(part, "run_"):
+		name.skipReason = "script; configsync does not run scripts"
+	case strings.HasPrefix(part, "modify_"):
+		name.skipReason = "modify script; configsync does not run scripts"
+	case strings.HasPrefix(part, "symlink_"):
+		name.skipReason = "symlink template; recreate the link by hand"
+	case strings.HasPrefix(part, "remove_"):
+		name.skipReason = "removal marker"
+	case strings.HasPrefix(part, "external_") && dir:
+		name.skipReason = "external archive; chezmoi downloads it at apply time"
+	case strings.HasSuffix(part, ".tmpl") && !dir:
+		name.skipReason = "template; run 'chezmoi apply' and add the generated file with 'configsync add --path'"
+	}
+	if name.skipReason != "" {
+		return name
+	}
+
+	for stripped := true; stripped; {
+		switch {
+		case strings.HasPrefix(part, "private_"):
+			name.private = true
+		case strings.HasPrefix(part, "readonly_"):
+			name.readonly = true
+		case strings.HasPrefix(part, "executable_") && !dir:
+			name.executable = true
+		case strings.HasPrefix(part, "exact_") && dir, strings.HasPrefix(part, "empty_") && !dir, strings.HasPrefix(part, "create_") && !dir:
+		default:
+			stripped = false
+			continue
+		}
+		part = part[strings.Index(part, "_")+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(part, "dot_"):
+		part = "." + strings.TrimPrefix(part, "dot_")
+	case strings.HasPrefix(part, "literal_"):
+		part = strings.TrimPrefix(part, "literal_")
+	}
+
+	name.target = part
+	return name
+}
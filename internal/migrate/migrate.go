@@ -0,0 +1,134 @@
+// Package migrate reads dotfiles repositories managed by other tools (GNU Stow, chezmoi) and
+// turns their contents into application configurations and store files.
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// sharedDirs are home directories holding the configuration of many tools. Entries inside them
+// are managed as separate paths instead of linking the whole directory.
+var sharedDirs = map[string]bool{
+	".config":                     true,
+	".local":                      true,
+	".local/bin":                  true,
+	".local/share":                true,
+	"Library":                     true,
+	"Library/Application Support": true,
+	"Library/Containers":          true,
+	"Library/Group Containers":    true,
+	"Library/LaunchAgents":        true,
+	"Library/Preferences":         true,
+}
+
+// File is one file of a repository and where it belongs in the home directory
+type File struct {
+	Source string      // Path in the repository
+	Target string      // Path relative to the home directory
+	Mode   os.FileMode // Permissions to apply after copying, or 0 to keep the repository's
+}
+
+// Entry is a file or directory from a repository, managed as one configuration path
+type Entry struct {
+	Target string // Path relative to the home directory, e.g. .config/nvim
+	Type   config.PathType
+	Files  []File
+}
+
+// Package is a group of entries imported as one application
+type Package struct {
+	Name    string
+	Entries []Entry
+}
+
+// Result is what was read from a repository
+type Result struct {
+	Packages []Package
+	Skipped  []string // Repository entries that cannot be imported, with the reason
+}
+
+// AppConfig returns the application configuration managing a package's entries, with each
+// entry stored at its path relative to the home directory
+func (p *Package) AppConfig(homeDir string) *config.AppConfig {
+	appConfig := config.NewAppConfig(p.Name, p.Name)
+	for _, entry := range p.Entries {
+		appConfig.AddPath(filepath.Join(homeDir, entry.Target), entry.Target, entry.Type, false)
+	}
+	return appConfig
+}
+
+// Import copies a package's files into the store at their destinations
+func (p *Package) Import(storeDir string) error {
+	storeManifest, err := manifest.Load(storeDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range p.Entries {
+		for _, file := range entry.Files {
+			storePath := filepath.Join(storeDir, file.Target)
+			if _, err := storeManifest.CopyFile(file.Source, storePath); err != nil {
+				return fmt.Errorf("failed to import %s: %w", file.Source, err)
+			}
+			if file.Mode != 0 {
+				if err := os.Chmod(storePath, file.Mode); err != nil {
+					return fmt.Errorf("failed to set permissions of %s: %w", storePath, err)
+				}
+			}
+		}
+	}
+
+	return storeManifest.Save()
+}
+
+// groupEntries splits the files of a package into entries: one per top-level file or directory,
+// descending into shared directories such as ~/.config so that each tool gets its own path
+func groupEntries(files []File) []Entry {
+	byTarget := make(map[string]*Entry)
+	var order []string
+
+	for _, file := range files {
+		parts := strings.Split(filepath.ToSlash(file.Target), "/")
+		depth := 1
+		for depth < len(parts) && sharedDirs[strings.Join(parts[:depth], "/")] {
+			depth++
+		}
+
+		target := filepath.FromSlash(strings.Join(parts[:depth], "/"))
+		entry, exists := byTarget[target]
+		if !exists {
+			entry = &Entry{Target: target, Type: config.PathTypeFile}
+			byTarget[target] = entry
+			order = append(order, target)
+		}
+		if depth < len(parts) {
+			entry.Type = config.PathTypeDirectory
+		}
+		entry.Files = append(entry.Files, file)
+	}
+
+	sort.Strings(order)
+	entries := make([]Entry, 0, len(order))
+	for _, target := range order {
+		entries = append(entries, *byTarget[target])
+	}
+	return entries
+}
+
+// appName derives an application name from a path relative to the home directory,
+// e.g. .config/nvim becomes nvim and .gitconfig becomes gitconfig
+func appName(target string) string {
+	name := strings.TrimPrefix(filepath.Base(target), ".")
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Base(target), ".")
+	}
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
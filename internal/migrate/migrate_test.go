@@ -0,0 +1,216 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// writeFiles creates files below dir, given as relative path to content
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+}
+
+// findPackage returns the package with the given name from a result
+func findPackage(t *testing.T, result *Result, name string) *Package {
+	t.Helper()
+	for i := range result.Packages {
+		if result.Packages[i].Name == name {
+			return &result.Packages[i]
+		}
+	}
+	t.Fatalf("Expected package %s, got %+v", name, result.Packages)
+	return nil
+}
+
+func TestReadStow(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"git/.gitconfig":                    "[user]",
+		"git/README.md":                     "docs",
+		"nvim/dot-config/nvim/init.lua":     "-- init",
+		"nvim/dot-config/nvim/lua/keys.lua": "-- keys",
+		"zsh/.zshrc":                        "export A=1",
+		"zsh/.zshrc~":                       "backup",
+		".git/config":                       "[core]",
+	})
+
+	result, err := ReadStow(dir)
+	if err != nil {
+		t.Fatalf("ReadStow failed: %v", err)
+	}
+	if len(result.Packages) != 3 {
+		t.Fatalf("Expected 3 packages, got %+v", result.Packages)
+	}
+
+	git := findPackage(t, result, "git")
+	if len(git.Entries) != 1 || git.Entries[0].Target != ".gitconfig" || git.Entries[0].Type != config.PathTypeFile {
+		t.Errorf("Expected only .gitconfig in the git package, got %+v", git.Entries)
+	}
+
+	nvim := findPackage(t, result, "nvim")
+	if len(nvim.Entries) != 1 {
+		t.Fatalf("Expected one entry in the nvim package, got %+v", nvim.Entries)
+	}
+	entry := nvim.Entries[0]
+	if entry.Target != filepath.Join(".config", "nvim") || entry.Type != config.PathTypeDirectory || len(entry.Files) != 2 {
+		t.Errorf("Expected dot-config/nvim to become the .config/nvim directory, got %+v", entry)
+	}
+
+	zsh := findPackage(t, result, "zsh")
+	if len(zsh.Entries) != 1 || zsh.Entries[0].Target != ".zshrc" {
+		t.Errorf("Expected editor backups to be ignored, got %+v", zsh.Entries)
+	}
+}
+
+func TestReadStowMissingDir(t *testing.T) {
+	if _, err := ReadStow(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
+
+func TestReadChezmoi(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"dot_gitconfig":                     "[user]",
+		"private_dot_ssh/private_config":    "Host *",
+		"dot_config/nvim/init.lua":          "-- init",
+		"dot_local/bin/executable_greet":    "#!/bin/sh",
+		"dot_zshrc.tmpl":                    "{{ .name }}",
+		"run_once_install.sh":               "brew bundle",
+		"encrypted_private_dot_netrc.age":   "secret",
+		".chezmoiignore":                    "README.md",
+		".chezmoiscripts/run_after_hook.sh": "echo",
+	})
+
+	result, err := ReadChezmoi(dir)
+	if err != nil {
+		t.Fatalf("ReadChezmoi failed: %v", err)
+	}
+
+	names := make([]string, len(result.Packages))
+	for i, pkg := range result.Packages {
+		names[i] = pkg.Name
+	}
+	if strings.Join(names, ",") != "gitconfig,greet,nvim,ssh" {
+		t.Errorf("Expected packages gitconfig, greet, nvim, and ssh, got %v", names)
+	}
+
+	ssh := findPackage(t, result, "ssh")
+	if len(ssh.Entries) != 1 || ssh.Entries[0].Target != ".ssh" || ssh.Entries[0].Type != config.PathTypeDirectory {
+		t.Fatalf("Expected private_dot_ssh to become the .ssh directory, got %+v", ssh.Entries)
+	}
+	if file := ssh.Entries[0].Files[0]; file.Target != filepath.Join(".ssh", "config") || file.Mode != 0600 {
+		t.Errorf("Expected a private .ssh/config, got %+v", file)
+	}
+
+	greet := findPackage(t, result, "greet")
+	if file := greet.Entries[0].Files[0]; file.Target != filepath.Join(".local", "bin", "greet") || file.Mode != 0755 {
+		t.Errorf("Expected an executable .local/bin/greet, got %+v", file)
+	}
+
+	if len(result.Skipped) != 3 {
+		t.Errorf("Expected the template, script, and encrypted file to be skipped, got %v", result.Skipped)
+	}
+}
+
+func TestParseChezmoiName(t *testing.T) {
+	tests := []struct {
+		part       string
+		target     string
+		dir        bool
+		skip       bool
+		private    bool
+		executable bool
+		readonly   bool
+	}{
+		{part: "dot_bashrc", target: ".bashrc"},
+		{part: "private_readonly_dot_pgpass", target: ".pgpass", private: true, readonly: true},
+		{part: "executable_run_me", target: "run_me", executable: true},
+		{part: "literal_dot_keep", target: "dot_keep"},
+		{part: "exact_dot_vim", target: ".vim", dir: true},
+		{part: "empty_dot_hushlogin", target: ".hushlogin"},
+		{part: "symlink_dot_vimrc", skip: true},
+		{part: "modify_dot_npmrc", skip: true},
+		{part: "external_dot_oh-my-zsh", dir: true, skip: true},
+		{part: "dot_gitconfig.tmpl", skip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.part, func(t *testing.T) {
+			name := parseChezmoiName(tt.part, tt.dir)
+			if (name.skipReason != "") != tt.skip {
+				t.Fatalf("Expected skip=%v, got reason %q", tt.skip, name.skipReason)
+			}
+			if tt.skip {
+				return
+			}
+			if name.target != tt.target || name.private != tt.private || name.executable != tt.executable || name.readonly != tt.readonly {
+				t.Errorf("Unexpected result %+v", name)
+			}
+		})
+	}
+}
+
+func TestAppName(t *testing.T) {
+	tests := map[string]string{
+		".gitconfig":            "gitconfig",
+		".config/nvim":          "nvim",
+		".config/starship.toml": "starship",
+		"Library/Application Support/Sublime Text": "sublime-text",
+		".vim": "vim",
+	}
+	for target, expected := range tests {
+		if name := appName(filepath.FromSlash(target)); name != expected {
+			t.Errorf("appName(%q) = %q, expected %q", target, name, expected)
+		}
+	}
+}
+
+func TestPackageImport(t *testing.T) {
+	repoDir := t.TempDir()
+	storeDir := t.TempDir()
+	homeDir := t.TempDir()
+	writeFiles(t, repoDir, map[string]string{"ssh/config": "Host *"})
+
+	pkg := &Package{
+		Name: "ssh",
+		Entries: []Entry{{
+			Target: ".ssh",
+			Type:   config.PathTypeDirectory,
+			Files:  []File{{Source: filepath.Join(repoDir, "ssh", "config"), Target: filepath.Join(".ssh", "config"), Mode: 0600}},
+		}},
+	}
+
+	if err := pkg.Import(storeDir); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(storeDir, ".ssh", "config"))
+	if err != nil {
+		t.Fatalf("Expected the file in the store: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	appConfig := pkg.AppConfig(homeDir)
+	if len(appConfig.Paths) != 1 {
+		t.Fatalf("Expected one path, got %+v", appConfig.Paths)
+	}
+	path := appConfig.Paths[0]
+	if path.Source != filepath.Join(homeDir, ".ssh") || path.Destination != ".ssh" || path.Type != config.PathTypeDirectory {
+		t.Errorf("Unexpected path %+v", path)
+	}
+}
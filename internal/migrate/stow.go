@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stowIgnored are the names GNU Stow ignores in a package by default
+var stowIgnored = map[string]bool{
+	".git":               true,
+	".gitignore":         true,
+	".gitmodules":        true,
+	".stow-local-ignore": true,
+	".DS_Store":          true,
+	"CVS":                true,
+	".hg":                true,
+	".svn":               true,
+}
+
+// stowIgnoredAtRoot are name prefixes Stow ignores only at the top of a package
+var stowIgnoredAtRoot = []string{"README", "LICENSE", "COPYING"}
+
+// ReadStow reads a GNU Stow repository, where each top-level directory is a package whose
+// contents mirror the home directory. Names starting with dot- are read as starting with a dot,
+// as stow --dotfiles does. Each package becomes one application.
+func ReadStow(dir string) (*Result, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stow directory: %w", err)
+	}
+
+	result := &Result{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		var files []File
+		packageDir := filepath.Join(dir, entry.Name())
+		err := filepath.WalkDir(packageDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == packageDir {
+				return nil
+			}
+
+			rel, _ := filepath.Rel(packageDir, path)
+			if isStowIgnored(rel, d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s/%s: not a regular file", entry.Name(), rel))
+				return nil
+			}
+
+			files = append(files, File{Source: path, Target: stowTarget(rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stow package %s: %w", entry.Name(), err)
+		}
+
+		if len(files) > 0 {
+			result.Packages = append(result.Packages, Package{Name: strings.ToLower(entry.Name()), Entries: groupEntries(files)})
+		}
+	}
+
+	sort.Slice(result.Packages, func(i, j int) bool { return result.Packages[i].Name < result.Packages[j].Name })
+	return result, nil
+}
+
+// isStowIgnored reports whether stow would leave a package entry out
+func isStowIgnored(rel, name string) bool {
+	if stowIgnored[name] || strings.HasSuffix(name, "~") {
+		return true
+	}
+	if !strings.Contains(filepath.ToSlash(rel), "/") {
+		for _, prefix := range stowIgnoredAtRoot {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stowTarget translates dot- prefixes in a package path to dots
+func stowTarget(rel string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "dot-") {
+			parts[i] = "." + strings.TrimPrefix(part, "dot-")
+		}
+	}
+	return filepath.FromSlash(strings.Join(parts, "/"))
+}
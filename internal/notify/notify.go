@@ -0,0 +1,159 @@
+// Package notify reports the failures of unattended operations, such as scheduled syncs, with
+// desktop notifications and webhook requests.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Desktop notification backends
+const (
+	DesktopAuto             = "auto"
+	DesktopTerminalNotifier = "terminal-notifier"
+	DesktopOsascript        = "osascript"
+	DesktopOff              = "off"
+)
+
+// webhookTimeout bounds how long a webhook request may take
+const webhookTimeout = 10 * time.Second
+
+// terminalNotifierLocations are checked for terminal-notifier when it is not on PATH, as happens
+// in launchd jobs
+var terminalNotifierLocations = []string{"/opt/homebrew/bin/terminal-notifier", "/usr/local/bin/terminal-notifier"}
+
+// Notification is a failure reported to the user
+type Notification struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Host    string    `json:"host"`
+	Failed  []string  `json:"failed,omitempty"`
+}
+
+// Manager sends notifications to the configured desktop backend and webhook
+type Manager struct {
+	client     *http.Client
+	runCommand func(name string, args ...string) ([]byte, error)
+	lookPath   func(file string) (string, error)
+	desktop    string
+	webhook    string
+}
+
+// NewManager creates a notification manager. An empty desktop backend means auto, which uses
+// terminal-notifier when it is installed and osascript otherwise on macOS.
+func NewManager(desktop, webhook string) *Manager {
+	if desktop == "" {
+		desktop = DesktopAuto
+	}
+	return &Manager{
+		client:  &http.Client{Timeout: webhookTimeout},
+		desktop: desktop,
+		webhook: webhook,
+		runCommand: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).CombinedOutput()
+		},
+		lookPath: exec.LookPath,
+	}
+}
+
+// Send delivers a notification to every configured destination. All destinations are tried even
+// when one fails, and the failures are returned together.
+func (m *Manager) Send(n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if n.Host == "" {
+		n.Host, _ = os.Hostname()
+	}
+
+	var errs []string
+	if err := m.sendDesktop(n); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := m.sendWebhook(n); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send notification: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sendDesktop shows the notification in the notification center
+func (m *Manager) sendDesktop(n Notification) error {
+	switch backend := m.desktopBackend(); backend {
+	case DesktopTerminalNotifier:
+		path := m.terminalNotifier()
+		if path == "" {
+			return fmt.Errorf("terminal-notifier is not installed")
+		}
+		if output, err := m.runCommand(path, "-title", n.Title, "-message", n.Message, "-group", "configsync"); err != nil {
+			return fmt.Errorf("terminal-notifier failed: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	case DesktopOsascript:
+		// Passing the text as arguments avoids quoting it inside the script
+		script := []string{"-e", "on run argv", "-e", "display notification (item 2 of argv) with title (item 1 of argv)", "-e", "end run"}
+		if output, err := m.runCommand("osascript", append(script, n.Title, n.Message)...); err != nil {
+			return fmt.Errorf("osascript failed: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// desktopBackend resolves auto to the backend available on this system, or off when there is none
+func (m *Manager) desktopBackend() string {
+	if m.desktop != DesktopAuto {
+		return m.desktop
+	}
+	if m.terminalNotifier() != "" {
+		return DesktopTerminalNotifier
+	}
+	if runtime.GOOS == "darwin" {
+		return DesktopOsascript
+	}
+	return DesktopOff
+}
+
+// terminalNotifier returns the path of terminal-notifier, or "" when it is not installed
+func (m *Manager) terminalNotifier() string {
+	if path, err := m.lookPath("terminal-notifier"); err == nil {
+		return path
+	}
+	for _, path := range terminalNotifierLocations {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// sendWebhook posts the notification as JSON to the configured webhook
+func (m *Manager) sendWebhook(n Notification) error {
+	if m.webhook == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	resp, err := m.client.Post(m.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
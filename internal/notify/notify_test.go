@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestManager returns a manager that records the commands it would run
+func newTestManager(desktop, webhook string, installed bool) (*Manager, *[][]string) {
+	m := NewManager(desktop, webhook)
+	var commands [][]string
+	m.runCommand = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, append([]string{name}, args...))
+		return nil, nil
+	}
+	m.lookPath = func(file string) (string, error) {
+		if installed {
+			return "/usr/local/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	return m, &commands
+}
+
+func TestSendTerminalNotifier(t *testing.T) {
+	m, commands := newTestManager("", "", true)
+	if err := m.Send(Notification{Title: "Sync failed", Message: "2 apps failed"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(*commands) != 1 {
+		t.Fatalf("Expected one command, got %v", *commands)
+	}
+	command := strings.Join((*commands)[0], " ")
+	if !strings.HasPrefix(command, "/usr/local/bin/terminal-notifier -title Sync failed -message 2 apps failed") {
+		t.Errorf("Unexpected command: %s", command)
+	}
+}
+
+func TestSendOsascript(t *testing.T) {
+	m, commands := newTestManager(DesktopOsascript, "", true)
+	if err := m.Send(Notification{Title: `Say "hi"`, Message: "failed"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(*commands) != 1 || (*commands)[0][0] != "osascript" {
+		t.Fatalf("Expected osascript to run, got %v", *commands)
+	}
+	args := (*commands)[0]
+	if args[len(args)-2] != `Say "hi"` || args[len(args)-1] != "failed" {
+		t.Errorf("Expected the title and message as arguments, got %v", args)
+	}
+}
+
+func TestSendDesktopOff(t *testing.T) {
+	m, commands := newTestManager(DesktopOff, "", true)
+	if err := m.Send(Notification{Title: "Sync failed"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(*commands) != 0 {
+		t.Errorf("Expected no desktop notification, got %v", *commands)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON request, got %s", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	m, _ := newTestManager(DesktopOff, server.URL, false)
+	err := m.Send(Notification{Event: "sync_failed", Title: "Sync failed", Failed: []string{"Chrome"}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if received.Event != "sync_failed" || len(received.Failed) != 1 || received.Host == "" || received.Time.IsZero() {
+		t.Errorf("Unexpected webhook payload: %+v", received)
+	}
+}
+
+func TestSendWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	m, commands := newTestManager(DesktopTerminalNotifier, server.URL, true)
+	err := m.Send(Notification{Title: "Sync failed"})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the webhook status in the error, got %v", err)
+	}
+	if len(*commands) != 1 {
+		t.Error("Expected the desktop notification to be sent despite the webhook failure")
+	}
+}
@@ -0,0 +1,127 @@
+// Package output prints the results of commands consistently: successes, failures, and warnings
+// with the same marks everywhere, colored only when the output is a terminal that wants color,
+// and informational output left out in quiet mode.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Color modes
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI escape sequences for the colors used
+const (
+	green  = "\033[32m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+	reset  = "\033[0m"
+)
+
+// CheckColorMode returns an error for an unknown color mode
+func CheckColorMode(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		return nil
+	}
+	return fmt.Errorf("invalid color mode %q (expected %s, %s, or %s)", mode, ColorAuto, ColorAlways, ColorNever)
+}
+
+// ColorEnabled reports whether output written to a file should be colored. In auto mode it is
+// colored when the file is a terminal, unless NO_COLOR is set (see https://no-color.org) or
+// TERM is dumb.
+func ColorEnabled(mode string, file *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Printer writes command output. Successes and informational lines are left out in quiet mode,
+// where failures, warnings, and prompts go to the error stream instead, so they are still seen.
+type Printer struct {
+	out    io.Writer
+	errOut io.Writer
+	quiet  bool
+	color  bool
+}
+
+// New returns a printer writing to out, and to errOut in quiet mode
+func New(out, errOut io.Writer, quiet, color bool) *Printer {
+	return &Printer{out: out, errOut: errOut, quiet: quiet, color: color}
+}
+
+// Quiet reports whether informational output is left out
+func (p *Printer) Quiet() bool {
+	return p.quiet
+}
+
+// Success prints a line marked as succeeded. Like the other line methods, it adds the newline
+// itself, and prints leading newlines and spaces in the format before the mark, to set the line
+// off or indent it.
+func (p *Printer) Success(format string, args ...interface{}) {
+	if p.quiet {
+		return
+	}
+	p.line(p.out, green, "✓", format, args...)
+}
+
+// Failure prints a line marked as failed
+func (p *Printer) Failure(format string, args ...interface{}) {
+	p.line(p.notices(), red, "✗", format, args...)
+}
+
+// Warning prints a line starting with "Warning:"
+func (p *Printer) Warning(format string, args ...interface{}) {
+	p.line(p.notices(), yellow, "Warning:", format, args...)
+}
+
+// Info prints an informational line, left out in quiet mode
+func (p *Printer) Info(format string, args ...interface{}) {
+	if p.quiet {
+		return
+	}
+	_, _ = fmt.Fprintf(p.out, format+"\n", args...)
+}
+
+// Prompt prints a question without a newline, for an answer typed on the same line
+func (p *Printer) Prompt(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(p.notices(), format, args...)
+}
+
+// notices returns where failures, warnings, and prompts are written
+func (p *Printer) notices() io.Writer {
+	if p.quiet {
+		return p.errOut
+	}
+	return p.out
+}
+
+// line prints a marked line
+func (p *Printer) line(w io.Writer, color, mark, format string, args ...interface{}) {
+	text := strings.TrimLeft(format, "\n ")
+	leading := format[:len(format)-len(text)]
+	_, _ = fmt.Fprintf(w, "%s%s %s\n", leading, p.paint(color, mark), fmt.Sprintf(text, args...))
+}
+
+// paint colors text when color is enabled
+func (p *Printer) paint(color, text string) string {
+	if !p.color {
+		return text
+	}
+	return color + text + reset
+}
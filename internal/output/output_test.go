@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPrinterLines(t *testing.T) {
+	var out, errOut bytes.Buffer
+	printer := New(&out, &errOut, false, false)
+
+	printer.Success("Synced %d application(s)", 2)
+	printer.Failure("\n  Failed to sync %s", "Editor")
+	printer.Warning("store is %s", "read-only")
+	printer.Info("Next: %s", "configsync status")
+
+	expected := "✓ Synced 2 application(s)\n\n  ✗ Failed to sync Editor\nWarning: store is read-only\nNext: configsync status\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("Expected nothing on the error stream, got %q", errOut.String())
+	}
+}
+
+func TestPrinterQuietAndColor(t *testing.T) {
+	var out, errOut bytes.Buffer
+	printer := New(&out, &errOut, true, true)
+
+	printer.Success("Synced")
+	printer.Info("Details")
+	printer.Failure("Failed")
+	printer.Prompt("Continue? ")
+
+	if out.Len() != 0 {
+		t.Errorf("Expected quiet mode to print nothing on the output stream, got %q", out.String())
+	}
+	expected := red + "✗" + reset + " Failed\nContinue? "
+	if errOut.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, errOut.String())
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("CreateTemp failed: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if ColorEnabled(ColorAuto, file) {
+		t.Error("Expected no color for a file that is not a terminal")
+	}
+	if !ColorEnabled(ColorAlways, file) || ColorEnabled(ColorNever, file) {
+		t.Error("Expected always and never to override detection")
+	}
+	if err := CheckColorMode("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown color mode")
+	}
+}
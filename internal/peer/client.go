@@ -0,0 +1,225 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// requestTimeout bounds a single request to a peer, including transferring a file
+const requestTimeout = 2 * time.Minute
+
+// Result summarizes a sync with a peer
+type Result struct {
+	Received []string `json:"received" yaml:"received"` // Store files fetched from the peer
+	Sent     []string `json:"sent" yaml:"sent"`         // Store files sent to the peer
+}
+
+// Client talks to a peer's server
+type Client struct {
+	http        *http.Client
+	baseURL     string
+	fingerprint string
+}
+
+// NormalizeAddress adds the default port to an address without one
+func NormalizeAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(DefaultPort))
+}
+
+// Dial creates a client for the Mac at the address, which must present the certificate of a
+// paired Mac
+func Dial(identity *Identity, registry *Registry, address string) *Client {
+	return newClient(identity, address, func(fingerprint string) error {
+		if registry.Trusted(fingerprint) == nil {
+			return fmt.Errorf("the Mac at %s presented an unknown certificate; pair with it first, or again if it was reinstalled", address)
+		}
+		return nil
+	})
+}
+
+// Fingerprint returns the certificate fingerprint of the server, once connected
+func (c *Client) Fingerprint() string {
+	return c.fingerprint
+}
+
+// newClient creates a client whose server certificate is checked by verify
+func newClient(identity *Identity, address string, verify func(fingerprint string) error) *Client {
+	c := &Client{baseURL: "https://" + NormalizeAddress(address)}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{identity.Certificate},
+		MinVersion:   tls.VersionTLS13,
+		// Peers use self-signed certificates, which are checked against the pinned fingerprint instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("peer presented no certificate")
+			}
+			fingerprint := Fingerprint(rawCerts[0])
+			if err := verify(fingerprint); err != nil {
+				return err
+			}
+			c.fingerprint = fingerprint
+			return nil
+		},
+	}
+	c.http = &http.Client{Timeout: requestTimeout, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return c
+}
+
+// Pair pairs with the Mac at the address, which must be waiting with the same code, and returns it
+func Pair(identity *Identity, address, name, code string) (*Peer, error) {
+	client := newClient(identity, address, func(string) error { return nil })
+
+	// The server's certificate is only known once connected, so a first request learns it
+	probe, err := client.http.Get(client.baseURL + "/v1/pair")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	_ = probe.Body.Close()
+	serverFP := client.fingerprint
+
+	body, err := json.Marshal(pairRequest{Name: name, Proof: pairingProof(code, "client", identity.Fingerprint, serverFP)})
+	if err != nil {
+		return nil, err
+	}
+	var resp pairResponse
+	if err := client.do(http.MethodPost, "/v1/pair", nil, bytes.NewReader(body), &resp); err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(resp.Proof), []byte(pairingProof(code, "server", serverFP, identity.Fingerprint))) {
+		return nil, fmt.Errorf("%s could not prove it knows the pairing code", address)
+	}
+
+	return &Peer{Name: resp.Name, Address: NormalizeAddress(address), Fingerprint: serverFP, PairedAt: time.Now()}, nil
+}
+
+// Files lists the peer's store
+func (c *Client) Files() ([]FileState, error) {
+	var files []FileState
+	err := c.do(http.MethodGet, "/v1/files", nil, nil, &files)
+	return files, err
+}
+
+// Fetch downloads a store file from the peer into the local store
+func (c *Client) Fetch(state FileState, storeRoot, archiveDir string) error {
+	resp, err := c.http.Get(c.baseURL + "/v1/file?" + url.Values{"path": {state.Path}}.Encode())
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", state.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	return writeStoreFile(storeRoot, archiveDir, state, io.LimitReader(resp.Body, state.Size+1))
+}
+
+// Send uploads a local store file to the peer
+func (c *Client) Send(state FileState, storeRoot string) error {
+	path, err := storePath(storeRoot, state.Path)
+	if err != nil {
+		return err
+	}
+	file, err := openStoreFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", state.Path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	header, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPut, "/v1/file", map[string]string{fileHeader: string(header)}, file, nil)
+}
+
+// Sync exchanges the files that differ between the local store and the peer's. Files replaced in
+// the local store are archived in the backup directory. With dryRun, the files that would be
+// exchanged are returned without transferring them.
+func Sync(client *Client, storeRoot, backupPath string, dryRun bool) (*Result, error) {
+	remote, err := client.Files()
+	if err != nil {
+		return nil, err
+	}
+	local, err := ScanStore(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := Compare(local, remote)
+	result := &Result{Received: []string{}, Sent: []string{}}
+	archiveDir := filepath.Join(backupPath, archiveDirName, time.Now().Format("20060102-150405"))
+	for _, state := range delta.Pull {
+		if !dryRun {
+			if err := client.Fetch(state, storeRoot, archiveDir); err != nil {
+				return result, err
+			}
+		}
+		result.Received = append(result.Received, state.Path)
+	}
+	for _, state := range delta.Push {
+		if !dryRun {
+			if err := client.Send(state, storeRoot); err != nil {
+				return result, err
+			}
+		}
+		result.Sent = append(result.Sent, state.Path)
+	}
+	return result, nil
+}
+
+// do sends a request and decodes the JSON response into v, if given
+func (c *Client) do(method, path string, headers map[string]string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if body != nil && method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to peer failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("invalid response from peer: %w", err)
+		}
+	}
+	return nil
+}
+
+// responseError returns the error a peer reported, if any
+func responseError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	var body errorResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err == nil && body.Error != "" {
+		return fmt.Errorf("peer refused the request: %s", body.Error)
+	}
+	return fmt.Errorf("peer returned %s", resp.Status)
+}
@@ -0,0 +1,112 @@
+package peer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceType is the Bonjour service type peers advertise
+const ServiceType = "_configsync._tcp"
+
+// Service is a peer found on the network
+type Service struct {
+	Name    string `json:"name" yaml:"name"`
+	Address string `json:"address" yaml:"address"`
+}
+
+// lookupTimeout bounds resolving the address of one discovered peer
+const lookupTimeout = 2 * time.Second
+
+// reachablePattern matches the line dns-sd -L prints once it has resolved a service
+var reachablePattern = regexp.MustCompile(`can be reached at (\S+?)\.?:(\d+)`)
+
+// runFor runs a command that does not exit by itself for the duration and returns its output
+func runFor(duration time.Duration, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if ctx.Err() != nil {
+		// Being stopped at the deadline is how the command ends
+		return output, nil
+	}
+	return output, err
+}
+
+// Advertise announces the peer server on the network with Bonjour until the returned function is
+// called. It uses dns-sd, which ships with macOS.
+func Advertise(name string, port int) (func(), error) {
+	dnssd, err := exec.LookPath("dns-sd")
+	if err != nil {
+		return nil, fmt.Errorf("dns-sd is not available, so this Mac cannot be discovered; peers can still connect to its address")
+	}
+	cmd := exec.Command(dnssd, "-R", name, ServiceType, "local", strconv.Itoa(port))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to advertise with dns-sd: %w", err)
+	}
+	return func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}, nil
+}
+
+// Browse lists the peers advertising on the network within the timeout
+func Browse(timeout time.Duration) ([]Service, error) {
+	if _, err := exec.LookPath("dns-sd"); err != nil {
+		return nil, fmt.Errorf("dns-sd is not available; pass the other Mac's address instead")
+	}
+
+	output, err := runFor(timeout, "dns-sd", "-B", ServiceType, "local")
+	if err != nil {
+		return nil, fmt.Errorf("failed to browse with dns-sd: %w", err)
+	}
+
+	var services []Service
+	for _, name := range parseBrowse(output) {
+		lookup, err := runFor(lookupTimeout, "dns-sd", "-L", name, ServiceType, "local")
+		if err != nil {
+			continue
+		}
+		if address := parseLookup(lookup); address != "" {
+			services = append(services, Service{Name: name, Address: address})
+		}
+	}
+	return services, nil
+}
+
+// parseBrowse returns the instance names dns-sd -B reported as added, in order and without duplicates
+func parseBrowse(output []byte) []string {
+	var names []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// Timestamp A/R Flags if Domain Service-Type Instance-Name, where the name may contain spaces
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 || fields[1] != "Add" {
+			continue
+		}
+		start := strings.Index(scanner.Text(), fields[5]) + len(fields[5])
+		name := strings.TrimSpace(scanner.Text()[start:])
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseLookup returns the host:port dns-sd -L resolved a service to, or "" when it did not
+func parseLookup(output []byte) string {
+	match := reachablePattern.FindSubmatch(output)
+	if match == nil {
+		return ""
+	}
+	return net.JoinHostPort(string(match[1]), string(match[2]))
+}
@@ -0,0 +1,102 @@
+// Package peer syncs the store directly between two Macs on the same network. Each Mac has a
+// self-signed TLS identity; pairing pins the other Mac's certificate after both sides prove they
+// know a one-time code, and later syncs exchange only the store files that differ.
+package peer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DirName is the directory in the configuration directory holding the identity and peers
+	DirName = "peer"
+
+	certFile = "identity.crt"
+	keyFile  = "identity.key"
+)
+
+// identityLifetime is how long a generated certificate is valid
+const identityLifetime = 10 * 365 * 24 * time.Hour
+
+// Identity is this Mac's TLS certificate, which peers pin when pairing
+type Identity struct {
+	Certificate tls.Certificate
+	Fingerprint string
+}
+
+// LoadOrCreateIdentity reads the identity from the directory, generating one on first use
+func LoadOrCreateIdentity(dir string) (*Identity, error) {
+	certPath, keyPath := filepath.Join(dir, certFile), filepath.Join(dir, keyFile)
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		if err := generateIdentity(certPath, keyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load peer identity: %w", err)
+	}
+	return &Identity{Certificate: cert, Fingerprint: Fingerprint(cert.Certificate[0])}, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER-encoded certificate
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// generateIdentity writes a new self-signed certificate and its private key
+func generateIdentity(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate peer key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "configsync " + host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(identityLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create peer certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode peer key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return fmt.Errorf("failed to create peer directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write peer key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("failed to write peer certificate: %w", err)
+	}
+	return nil
+}
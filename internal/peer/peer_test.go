@@ -0,0 +1,327 @@
+package peer
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// testMac is one side of a peer sync
+type testMac struct {
+	identity *Identity
+	registry *Registry
+	store    string
+	backup   string
+}
+
+func newTestMac(t *testing.T) *testMac {
+	t.Helper()
+	dir := t.TempDir()
+	identity, err := LoadOrCreateIdentity(filepath.Join(dir, DirName))
+	if err != nil {
+		t.Fatalf("Failed to create identity: %v", err)
+	}
+	registry, err := LoadRegistry(filepath.Join(dir, DirName))
+	if err != nil {
+		t.Fatalf("Failed to load registry: %v", err)
+	}
+	return &testMac{identity: identity, registry: registry, store: filepath.Join(dir, "store"), backup: filepath.Join(dir, "backups")}
+}
+
+// startServer serves the Mac's store over TLS and returns its address
+func (m *testMac) startServer(t *testing.T, code string) string {
+	t.Helper()
+	server := NewServer(m.identity, m.registry, "server-mac", m.store, m.backup)
+	server.SetOutput(&strings.Builder{})
+	server.SetPairingCode(code)
+	ts := httptest.NewUnstartedServer(server)
+	ts.TLS = server.TLSConfig()
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts.Listener.Addr().String()
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set time of %s: %v", path, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestIdentityIsStable(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadOrCreateIdentity(dir)
+	if err != nil {
+		t.Fatalf("Failed to create identity: %v", err)
+	}
+	second, err := LoadOrCreateIdentity(dir)
+	if err != nil {
+		t.Fatalf("Failed to load identity: %v", err)
+	}
+	if first.Fingerprint == "" || first.Fingerprint != second.Fingerprint {
+		t.Errorf("Expected the same fingerprint, got %q and %q", first.Fingerprint, second.Fingerprint)
+	}
+	if info, err := os.Stat(filepath.Join(dir, keyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the private key to be readable only by the owner, got %v %v", info, err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	registry, _ := LoadRegistry(dir)
+	registry.Add(Peer{Name: "office", Address: "office.local:7447", Fingerprint: "aa"})
+	registry.Add(Peer{Name: "laptop", Fingerprint: "bb"})
+	registry.Add(Peer{Name: "office", Address: "office.local:7447", Fingerprint: "cc"})
+	if err := registry.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadRegistry(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Peers) != 2 {
+		t.Fatalf("Expected re-pairing to replace the old entry, got %+v", loaded.Peers)
+	}
+	for _, host := range []string{"office", "OFFICE", "office.local", "office.local:7447"} {
+		if peer := loaded.Find(host); peer == nil || peer.Fingerprint != "cc" {
+			t.Errorf("Expected %s to find the office Mac, got %+v", host, peer)
+		}
+	}
+	if loaded.Trusted("aa") != nil || loaded.Trusted("bb") == nil {
+		t.Error("Expected only current fingerprints to be trusted")
+	}
+	if !loaded.Remove("laptop") || loaded.Trusted("bb") != nil {
+		t.Error("Expected the laptop to be forgotten")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	local := []FileState{
+		{Path: "git/.gitconfig", Hash: "1", ModTime: older},
+		{Path: "vscode/settings.json", Hash: "2", ModTime: newer},
+		{Path: "zsh/.zshrc", Hash: "3", ModTime: older},
+		{Path: "only-local", Hash: "4", ModTime: older},
+	}
+	remote := []FileState{
+		{Path: "git/.gitconfig", Hash: "1", ModTime: newer},
+		{Path: "vscode/settings.json", Hash: "5", ModTime: older},
+		{Path: "zsh/.zshrc", Hash: "6", ModTime: newer},
+		{Path: "only-remote", Hash: "7", ModTime: older},
+	}
+
+	delta := Compare(local, remote)
+	if len(delta.Pull) != 2 || delta.Pull[0].Path != "only-remote" || delta.Pull[1].Path != "zsh/.zshrc" {
+		t.Errorf("Unexpected pulls: %+v", delta.Pull)
+	}
+	if len(delta.Push) != 2 || delta.Push[0].Path != "only-local" || delta.Push[1].Path != "vscode/settings.json" {
+		t.Errorf("Unexpected pushes: %+v", delta.Push)
+	}
+
+	// Both Macs must agree on the winner of a tie
+	a := []FileState{{Path: "f", Hash: "a", ModTime: older}}
+	b := []FileState{{Path: "f", Hash: "b", ModTime: older}}
+	if len(Compare(a, b).Pull) != 1 || len(Compare(b, a).Push) != 1 {
+		t.Error("Expected the version with the greater hash to win a tie on both sides")
+	}
+}
+
+func TestStorePathRejectsEscapes(t *testing.T) {
+	for _, rel := range []string{"", ".", "..", "../etc/passwd", "/etc/passwd", "a/../../b", ".configsync-checksums.yaml"} {
+		if _, err := storePath("/store", rel); err == nil {
+			t.Errorf("Expected %q to be rejected", rel)
+		}
+	}
+	if path, err := storePath("/store", "git/.gitconfig"); err != nil || path != filepath.Join("/store", "git", ".gitconfig") {
+		t.Errorf("Unexpected path: %s %v", path, err)
+	}
+}
+
+func TestScanStoreSkipsBookkeeping(t *testing.T) {
+	store := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(store, "git", ".gitconfig"), "[user]", now)
+	writeFile(t, filepath.Join(store, ".configsync-checksums.yaml"), "files: {}", now)
+
+	files, err := ScanStore(store)
+	if err != nil {
+		t.Fatalf("ScanStore failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "git/.gitconfig" || files[0].Hash == "" {
+		t.Errorf("Unexpected files: %+v", files)
+	}
+
+	if files, err := ScanStore(filepath.Join(store, "missing")); err != nil || len(files) != 0 {
+		t.Errorf("Expected a missing store to be empty, got %v %v", files, err)
+	}
+}
+
+func TestPairAndSync(t *testing.T) {
+	server, client := newTestMac(t), newTestMac(t)
+	older := time.Now().Add(-time.Hour).Truncate(time.Second)
+	newer := older.Add(30 * time.Minute)
+
+	writeFile(t, filepath.Join(server.store, "git", ".gitconfig"), "server git", newer)
+	writeFile(t, filepath.Join(server.store, "zsh", ".zshrc"), "server zsh", older)
+	writeFile(t, filepath.Join(client.store, "git", ".gitconfig"), "client git", older)
+	writeFile(t, filepath.Join(client.store, "vscode", "settings.json"), "client vscode", older)
+
+	address := server.startServer(t, "123456")
+
+	if _, err := Pair(client.identity, address, "client-mac", "654321"); err == nil {
+		t.Fatal("Expected pairing with a wrong code to fail")
+	}
+	peer, err := Pair(client.identity, address, "client-mac", "123456")
+	if err != nil {
+		t.Fatalf("Pair failed: %v", err)
+	}
+	if peer.Name != "server-mac" || peer.Fingerprint != server.identity.Fingerprint {
+		t.Errorf("Unexpected peer: %+v", peer)
+	}
+	if server.registry.Trusted(client.identity.Fingerprint) == nil {
+		t.Error("Expected the server to trust the client after pairing")
+	}
+	client.registry.Add(*peer)
+	if _, err := Pair(client.identity, address, "client-mac", "123456"); err == nil {
+		t.Error("Expected the pairing code to work only once")
+	}
+
+	result, err := Sync(Dial(client.identity, client.registry, address), client.store, client.backup, false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if strings.Join(result.Received, ",") != "git/.gitconfig,zsh/.zshrc" || strings.Join(result.Sent, ",") != "vscode/settings.json" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if got := readFile(t, filepath.Join(client.store, "git", ".gitconfig")); got != "server git" {
+		t.Errorf("Expected the newer server version, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(server.store, "vscode", "settings.json")); got != "client vscode" {
+		t.Errorf("Expected the client file on the server, got %q", got)
+	}
+	archived, _ := filepath.Glob(filepath.Join(client.backup, archiveDirName, "*", "git", ".gitconfig"))
+	if len(archived) != 1 || readFile(t, archived[0]) != "client git" {
+		t.Errorf("Expected the replaced version to be archived, got %v", archived)
+	}
+
+	again, err := Sync(Dial(client.identity, client.registry, address), client.store, client.backup, false)
+	if err != nil || len(again.Received)+len(again.Sent) != 0 {
+		t.Errorf("Expected the stores to match after syncing, got %+v %v", again, err)
+	}
+}
+
+func TestSyncRejectsSymlinkEscapes(t *testing.T) {
+	server, client := newTestMac(t), newTestMac(t)
+	now := time.Now().Truncate(time.Second)
+
+	// Links in the server's store that lead outside it
+	outside := filepath.Join(filepath.Dir(server.store), "outside")
+	writeFile(t, filepath.Join(outside, "secret"), "secret", now)
+	writeFile(t, filepath.Join(server.store, "git", ".gitconfig"), "server git", now)
+	if err := os.Symlink(outside, filepath.Join(server.store, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(server.store, "git", "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	address := server.startServer(t, "123456")
+	peer, err := Pair(client.identity, address, "client-mac", "123456")
+	if err != nil {
+		t.Fatalf("Pair failed: %v", err)
+	}
+	client.registry.Add(*peer)
+	remote := Dial(client.identity, client.registry, address)
+
+	secretHash, err := manifest.HashFile(filepath.Join(outside, "secret"))
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	for _, path := range []string{"escape/secret", "git/link"} {
+		state := FileState{Path: path, Hash: secretHash, Size: 6, ModTime: now}
+		if err := remote.Fetch(state, client.store, client.backup); err == nil {
+			t.Errorf("Expected fetching %s through a symlink to be refused", path)
+		}
+	}
+
+	// Files sent through the symlinked directory must not land outside the store
+	writeFile(t, filepath.Join(client.store, "escape", "evil"), "evil", now)
+	hash, err := manifest.HashFile(filepath.Join(client.store, "escape", "evil"))
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	if err := remote.Send(FileState{Path: "escape/evil", Hash: hash, Size: 4, ModTime: now}, client.store); err == nil {
+		t.Error("Expected sending a file below a symlink to be refused")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "evil")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the store, got %v", err)
+	}
+}
+
+func TestSyncRejectsUnknownMacs(t *testing.T) {
+	server, client, stranger := newTestMac(t), newTestMac(t), newTestMac(t)
+	address := server.startServer(t, "")
+
+	// The stranger trusts the server, but the server does not know the stranger
+	stranger.registry.Add(Peer{Name: "server-mac", Address: address, Fingerprint: server.identity.Fingerprint})
+	if _, err := Dial(stranger.identity, stranger.registry, address).Files(); err == nil || !strings.Contains(err.Error(), "not paired") {
+		t.Errorf("Expected an unpaired Mac to be refused, got %v", err)
+	}
+
+	// The client has paired with another Mac, not the one answering at the address
+	client.registry.Add(Peer{Name: "server-mac", Address: address, Fingerprint: stranger.identity.Fingerprint})
+	if _, err := Dial(client.identity, client.registry, address).Files(); err == nil || !strings.Contains(err.Error(), "unknown certificate") {
+		t.Errorf("Expected a server with another certificate to be refused, got %v", err)
+	}
+}
+
+func TestParseDNSSD(t *testing.T) {
+	browse := []byte(`Browsing for _configsync._tcp.local
+DATE: ---Thu 16 Oct 2026---
+12:00:00.000  ...STARTING...
+Timestamp     A/R    Flags  if Domain               Service Type         Instance Name
+12:00:00.123  Add        3   4 local.               _configsync._tcp.    Jane's MacBook Pro
+12:00:00.124  Add        2   5 local.               _configsync._tcp.    Jane's MacBook Pro
+12:00:00.125  Add        2   4 local.               _configsync._tcp.    studio
+12:00:01.000  Rmv        0   4 local.               _configsync._tcp.    old-mac
+`)
+	names := parseBrowse(browse)
+	if strings.Join(names, "|") != "Jane's MacBook Pro|studio" {
+		t.Errorf("Unexpected names: %q", names)
+	}
+
+	lookup := []byte(`Lookup studio._configsync._tcp.local
+DATE: ---Thu 16 Oct 2026---
+12:00:00.000  ...STARTING...
+12:00:00.200  studio._configsync._tcp.local. can be reached at studio.local.:7447 (interface 4)
+`)
+	if address := parseLookup(lookup); address != "studio.local:7447" {
+		t.Errorf("Unexpected address: %q", address)
+	}
+	if address := parseLookup([]byte("...STARTING...")); address != "" {
+		t.Errorf("Expected no address, got %q", address)
+	}
+}
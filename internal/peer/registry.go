@@ -0,0 +1,113 @@
+package peer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// registryFile is the name of the file in the peer directory listing the paired Macs
+const registryFile = "peers.yaml"
+
+// Peer is a paired Mac
+type Peer struct {
+	PairedAt    time.Time `json:"paired_at" yaml:"paired_at"`
+	LastSync    time.Time `json:"last_sync,omitempty" yaml:"last_sync,omitempty"`
+	Name        string    `json:"name" yaml:"name"`
+	Address     string    `json:"address,omitempty" yaml:"address,omitempty"` // host:port the peer listens on; empty for Macs that paired with this one
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"`
+}
+
+// Registry is the list of paired Macs
+type Registry struct {
+	path  string
+	Peers []Peer `yaml:"peers"`
+}
+
+// LoadRegistry reads the paired Macs from the peer directory
+func LoadRegistry(dir string) (*Registry, error) {
+	r := &Registry{path: filepath.Join(dir, registryFile)}
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peers: %w", err)
+	}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse peers: %w", err)
+	}
+	return r, nil
+}
+
+// Save writes the paired Macs
+func (r *Registry) Save() error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode peers: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create peer directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write peers: %w", err)
+	}
+	return nil
+}
+
+// Add records a paired Mac, replacing an earlier pairing with the same name or certificate
+func (r *Registry) Add(peer Peer) {
+	kept := r.Peers[:0]
+	for _, existing := range r.Peers {
+		if existing.Name != peer.Name && existing.Fingerprint != peer.Fingerprint {
+			kept = append(kept, existing)
+		}
+	}
+	r.Peers = append(kept, peer)
+}
+
+// Remove forgets the paired Mac with the name or address and reports whether there was one
+func (r *Registry) Remove(host string) bool {
+	target := r.Find(host)
+	if target == nil {
+		return false
+	}
+	fingerprint := target.Fingerprint
+	kept := r.Peers[:0]
+	for _, existing := range r.Peers {
+		if existing.Fingerprint != fingerprint {
+			kept = append(kept, existing)
+		}
+	}
+	r.Peers = kept
+	return true
+}
+
+// Find returns the paired Mac with the name, address, or address host, or nil when there is none
+func (r *Registry) Find(host string) *Peer {
+	for i := range r.Peers {
+		peer := &r.Peers[i]
+		if strings.EqualFold(peer.Name, host) || peer.Address == host {
+			return peer
+		}
+		if addressHost, _, err := net.SplitHostPort(peer.Address); err == nil && strings.EqualFold(addressHost, host) {
+			return peer
+		}
+	}
+	return nil
+}
+
+// Trusted reports whether a certificate fingerprint belongs to a paired Mac
+func (r *Registry) Trusted(fingerprint string) *Peer {
+	for i := range r.Peers {
+		if r.Peers[i].Fingerprint == fingerprint {
+			return &r.Peers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPort is the port peers listen on unless another one is given
+	DefaultPort = 7447
+	// codeAttempts is how many wrong pairing codes are accepted before pairing is disabled
+	codeAttempts = 5
+	// fileHeader carries the state of a file sent to a peer
+	fileHeader = "X-Configsync-File"
+	// archiveDirName is the directory in the backup directory holding files replaced by peer syncs
+	archiveDirName = "peer"
+)
+
+// pairRequest is sent by the Mac that starts pairing
+type pairRequest struct {
+	Name  string `json:"name"`
+	Proof string `json:"proof"`
+}
+
+// pairResponse is returned by the Mac that accepted the pairing
+type pairResponse struct {
+	Name  string `json:"name"`
+	Proof string `json:"proof"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server answers pairing requests and serves the store to paired Macs
+type Server struct {
+	out        io.Writer
+	identity   *Identity
+	registry   *Registry
+	lock       func() (func(), error)
+	received   func(path, peerName string)
+	mux        *http.ServeMux
+	name       string
+	storePath  string
+	backupPath string
+	code       string
+	attempts   int
+	mu         sync.Mutex
+}
+
+// NewServer creates a server for the store. Files it replaces are archived in the backup directory.
+func NewServer(identity *Identity, registry *Registry, name, storePath, backupPath string) *Server {
+	s := &Server{
+		out:        os.Stdout,
+		identity:   identity,
+		registry:   registry,
+		lock:       func() (func(), error) { return func() {}, nil },
+		received:   func(string, string) {},
+		mux:        http.NewServeMux(),
+		name:       name,
+		storePath:  storePath,
+		backupPath: backupPath,
+	}
+	s.mux.HandleFunc("POST /v1/pair", s.handlePair)
+	s.mux.HandleFunc("GET /v1/files", s.trusted(s.handleFiles))
+	s.mux.HandleFunc("GET /v1/file", s.trusted(s.handleFetch))
+	s.mux.HandleFunc("PUT /v1/file", s.trusted(s.handleReceive))
+	return s
+}
+
+// SetPairingCode enables pairing with the one-time code
+func (s *Server) SetPairingCode(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code = code
+	s.attempts = 0
+}
+
+// SetLocker sets the function that locks the store while a peer writes to it
+func (s *Server) SetLocker(lock func() (func(), error)) {
+	s.lock = lock
+}
+
+// SetReceived sets a function called with the store path of every file received from a peer, and
+// the name of the peer that sent it
+func (s *Server) SetReceived(received func(path, peerName string)) {
+	s.received = received
+}
+
+// SetOutput sets where the server reports pairings and received files
+func (s *Server) SetOutput(w io.Writer) {
+	s.out = w
+}
+
+// TLSConfig returns the server's TLS configuration. Every client must present a certificate,
+// which is checked against the paired Macs for everything but pairing.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{s.identity.Certificate},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}
+}
+
+// ServeHTTP handles a request from a peer
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		writeError(w, http.StatusUnauthorized, "a client certificate is required")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// trusted rejects requests from Macs that have not been paired
+func (s *Server) trusted(next func(http.ResponseWriter, *http.Request, *Peer)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		var peer *Peer
+		if trusted := s.registry.Trusted(clientFingerprint(r)); trusted != nil {
+			copied := *trusted
+			peer = &copied
+		}
+		s.mu.Unlock()
+		if peer == nil {
+			writeError(w, http.StatusForbidden, "this Mac is not paired; run 'configsync pair' first")
+			return
+		}
+		next(w, r, peer)
+	}
+}
+
+func (s *Server) handlePair(w http.ResponseWriter, r *http.Request) {
+	var req pairRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid pairing request")
+		return
+	}
+	clientFP := clientFingerprint(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.code == "" {
+		writeError(w, http.StatusForbidden, "pairing is not enabled; run 'configsync pair' on the other Mac")
+		return
+	}
+	if !hmac.Equal([]byte(req.Proof), []byte(pairingProof(s.code, "client", clientFP, s.identity.Fingerprint))) {
+		s.attempts++
+		if s.attempts >= codeAttempts {
+			s.code = ""
+			_, _ = fmt.Fprintln(s.out, "✗ Too many wrong pairing codes; pairing disabled")
+		}
+		writeError(w, http.StatusForbidden, "wrong pairing code")
+		return
+	}
+
+	s.registry.Add(Peer{Name: req.Name, Fingerprint: clientFP, PairedAt: time.Now()})
+	if err := s.registry.Save(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	code := s.code
+	s.code = ""
+	_, _ = fmt.Fprintf(s.out, "✓ Paired with %s\n", req.Name)
+
+	writeJSON(w, http.StatusOK, pairResponse{Name: s.name, Proof: pairingProof(code, "server", s.identity.Fingerprint, clientFP)})
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, _ *http.Request, _ *Peer) {
+	files, err := ScanStore(s.storePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if files == nil {
+		files = []FileState{}
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request, _ *Peer) {
+	path, err := storePath(s.storePath, r.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, err := openStoreFile(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s is not a file in the store", r.URL.Query().Get("path")))
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = io.Copy(w, file)
+}
+
+func (s *Server) handleReceive(w http.ResponseWriter, r *http.Request, peer *Peer) {
+	var state FileState
+	if err := json.Unmarshal([]byte(r.Header.Get(fileHeader)), &state); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid file state")
+		return
+	}
+
+	release, err := s.lock()
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	defer release()
+
+	archiveDir := filepath.Join(s.backupPath, archiveDirName, time.Now().Format("20060102-150405"))
+	if err := writeStoreFile(s.storePath, archiveDir, state, io.LimitReader(r.Body, state.Size+1)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.received(state.Path, peer.Name)
+	_, _ = fmt.Fprintf(s.out, "✓ Received %s from %s\n", state.Path, peer.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientFingerprint returns the fingerprint of the certificate the client presented
+func clientFingerprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return Fingerprint(r.TLS.PeerCertificates[0].Raw)
+}
+
+// pairingProof shows knowledge of the pairing code, bound to both certificates so the proof
+// cannot be replayed over another connection
+func pairingProof(code, role, ownFingerprint, otherFingerprint string) string {
+	mac := hmac.New(sha256.New, []byte(code))
+	mac.Write([]byte(role + ":" + ownFingerprint + ":" + otherFingerprint))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,220 @@
+package peer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/tarball"
+)
+
+// FileState describes one file in a store
+type FileState struct {
+	ModTime time.Time   `json:"mod_time"`
+	Path    string      `json:"path"` // Relative to the store, with forward slashes
+	Hash    string      `json:"hash"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// Delta lists the files two stores need to exchange to match
+type Delta struct {
+	Pull []FileState // Files to fetch from the peer
+	Push []FileState // Files to send to the peer
+}
+
+// Empty reports whether the stores already match
+func (d *Delta) Empty() bool {
+	return len(d.Pull) == 0 && len(d.Push) == 0
+}
+
+// ScanStore lists the regular files of a store with their content hashes. Configsync's own
+// bookkeeping files, which each Mac keeps for itself, and symlinks are left out.
+func ScanStore(root string) ([]FileState, error) {
+	var files []FileState
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isBookkeeping(rel) {
+			return nil
+		}
+
+		hash, err := manifest.HashFile(file)
+		if err != nil {
+			return err
+		}
+		files = append(files, FileState{
+			Path:    rel,
+			Hash:    hash,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+			Mode:    info.Mode().Perm(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan store: %w", err)
+	}
+	return files, nil
+}
+
+// isBookkeeping reports whether a store file is one configsync keeps about the store itself, or
+// a partial transfer
+func isBookkeeping(rel string) bool {
+	return strings.HasPrefix(path.Base(rel), ".configsync-")
+}
+
+// Compare works out which files to exchange. A file only one store has is copied to the other,
+// and of two different versions the more recently modified one wins. Deletions are not
+// propagated, since a file missing from one store cannot be told apart from one not yet synced.
+func Compare(local, remote []FileState) *Delta {
+	remoteByPath := make(map[string]FileState, len(remote))
+	for _, file := range remote {
+		remoteByPath[file.Path] = file
+	}
+
+	delta := &Delta{}
+	for _, localFile := range local {
+		remoteFile, exists := remoteByPath[localFile.Path]
+		delete(remoteByPath, localFile.Path)
+		switch {
+		case !exists:
+			delta.Push = append(delta.Push, localFile)
+		case localFile.Hash == remoteFile.Hash:
+		case newer(remoteFile, localFile):
+			delta.Pull = append(delta.Pull, remoteFile)
+		default:
+			delta.Push = append(delta.Push, localFile)
+		}
+	}
+	for _, remoteFile := range remoteByPath {
+		delta.Pull = append(delta.Pull, remoteFile)
+	}
+
+	sort.Slice(delta.Pull, func(i, j int) bool { return delta.Pull[i].Path < delta.Pull[j].Path })
+	sort.Slice(delta.Push, func(i, j int) bool { return delta.Push[i].Path < delta.Push[j].Path })
+	return delta
+}
+
+// newer reports whether version a of a file wins over version b. Ties are broken by hash so both
+// Macs reach the same decision.
+func newer(a, b FileState) bool {
+	if !a.ModTime.Equal(b.ModTime) {
+		return a.ModTime.After(b.ModTime)
+	}
+	return a.Hash > b.Hash
+}
+
+// storePath resolves a relative path received from a peer inside the store, rejecting paths that
+// would escape it, directly or through a symlinked directory, or overwrite bookkeeping files
+func storePath(root, rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if rel == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) || isBookkeeping(filepath.ToSlash(clean)) {
+		return "", fmt.Errorf("invalid store path %q", rel)
+	}
+	path := filepath.Join(root, clean)
+	if tarball.BelowSymlink(fsys.OS, root, path) {
+		return "", fmt.Errorf("invalid store path %q: it is below a symlink", rel)
+	}
+	return path, nil
+}
+
+// openStoreFile opens a store file to send to a peer. Symlinks are not followed, so a link in
+// the store cannot hand out a file outside it.
+func openStoreFile(path string) (*os.File, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// The file must not have been swapped for another since it was checked
+	if opened, err := file.Stat(); err != nil || !os.SameFile(info, opened) {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s changed while it was opened", path)
+	}
+	return file, nil
+}
+
+// writeStoreFile replaces a store file with content received from a peer, moving the version it
+// replaces into the archive directory. The file keeps the peer's modification time, so the two
+// stores compare equal afterwards.
+func writeStoreFile(root, archiveDir string, state FileState, content io.Reader) error {
+	target, err := storePath(root, state.Path)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(target); err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("invalid store path %q: it is not a file", state.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", state.Path, err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(target), ".configsync-peer-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", state.Path, err)
+	}
+	defer func() { _ = os.Remove(temp.Name()) }()
+
+	_, err = io.Copy(temp, content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", state.Path, err)
+	}
+	if hash, err := manifest.HashFile(temp.Name()); err != nil || hash != state.Hash {
+		return fmt.Errorf("content of %s does not match its hash", state.Path)
+	}
+
+	mode := state.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", state.Path, err)
+	}
+	if err := os.Chtimes(temp.Name(), state.ModTime, state.ModTime); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", state.Path, err)
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		archived := filepath.Join(archiveDir, filepath.FromSlash(state.Path))
+		if err := os.MkdirAll(filepath.Dir(archived), 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		if err := os.Rename(target, archived); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", state.Path, err)
+		}
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", state.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package permissions checks whether configsync can access configuration paths protected by
+// macOS privacy controls (TCC), which require Full Disk Access for the terminal running it.
+package permissions
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+)
+
+// Full Disk Access states
+const (
+	AccessGranted       = "granted"
+	AccessDenied        = "denied"
+	AccessUnknown       = "unknown"        // No protected file exists to test access with
+	AccessNotApplicable = "not_applicable" // The platform has no privacy controls
+)
+
+// SettingsURL opens the Full Disk Access pane of System Settings
+const SettingsURL = "x-apple.systempreferences:com.apple.preference.security?Privacy_AllFiles"
+
+// Remediation explains how to grant Full Disk Access to configsync
+const Remediation = `To give configsync Full Disk Access:
+  1. Open System Settings > Privacy & Security > Full Disk Access
+     (or run: open "` + SettingsURL + `")
+  2. Turn on the terminal app you run configsync from (e.g. Terminal or iTerm2),
+     adding it with + if it is not listed
+  3. Quit and reopen the terminal, then run 'configsync doctor' to check again`
+
+// protectedDirs are the locations in the home directory macOS only lets apps with Full Disk Access read
+var protectedDirs = []string{
+	"Library/Application Support/com.apple.TCC",
+	"Library/Application Support/AddressBook",
+	"Library/Application Support/CallHistoryDB",
+	"Library/Calendars",
+	"Library/Containers/com.apple.mail",
+	"Library/Containers/com.apple.Safari",
+	"Library/Containers/com.apple.stocks",
+	"Library/Cookies",
+	"Library/HomeKit",
+	"Library/IdentityServices",
+	"Library/Mail",
+	"Library/Messages",
+	"Library/Metadata/CoreSpotlight",
+	"Library/Reminders",
+	"Library/Safari",
+	"Library/Suggestions",
+}
+
+// fullDiskAccessProbes are protected files whose readability shows whether Full Disk Access was granted
+var fullDiskAccessProbes = []string{
+	"Library/Application Support/com.apple.TCC/TCC.db",
+	"Library/Safari",
+	"Library/Mail",
+	"Library/Messages",
+}
+
+// probe tries to read a path. It is a variable so tests can simulate denied access.
+var probe = func(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		_, err = f.Readdirnames(1)
+	} else {
+		_, err = f.Read(make([]byte, 1))
+	}
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Issue is a managed path configsync cannot access because of privacy protections
+type Issue struct {
+	App       string `json:"app" yaml:"app"`
+	Path      string `json:"path" yaml:"path"`
+	Reason    string `json:"reason" yaml:"reason"`
+	Protected bool   `json:"protected" yaml:"protected"` // Inside a location that requires Full Disk Access
+}
+
+// Report is the result of a permissions preflight check
+type Report struct {
+	FullDiskAccess string  `json:"full_disk_access" yaml:"full_disk_access"`
+	Issues         []Issue `json:"issues,omitempty" yaml:"issues,omitempty"`
+}
+
+// Checker checks access to the configuration paths of managed applications
+type Checker struct {
+	homeDir string
+}
+
+// NewChecker creates a permissions checker for a home directory
+func NewChecker(homeDir string) *Checker {
+	return &Checker{homeDir: homeDir}
+}
+
+// FullDiskAccess reports whether the running process has Full Disk Access
+func (c *Checker) FullDiskAccess() string {
+	if config.CurrentPlatform != config.PlatformDarwin {
+		return AccessNotApplicable
+	}
+
+	for _, rel := range fullDiskAccessProbes {
+		err := probe(filepath.Join(c.homeDir, rel))
+		switch {
+		case err == nil:
+			return AccessGranted
+		case os.IsPermission(err):
+			return AccessDenied
+		}
+	}
+	return AccessUnknown
+}
+
+// IsProtected reports whether a path is inside a location that requires Full Disk Access
+func (c *Checker) IsProtected(path string) bool {
+	path = c.expandPath(path)
+	for _, rel := range protectedDirs {
+		dir := filepath.Join(c.homeDir, rel)
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPath returns an issue when a path exists but cannot be read, or nil when it is accessible
+// or does not exist yet
+func (c *Checker) CheckPath(appName, path string) *Issue {
+	err := probe(c.expandPath(path))
+	if err == nil || !os.IsPermission(err) {
+		return nil
+	}
+
+	issue := &Issue{App: appName, Path: path, Reason: "permission denied", Protected: c.IsProtected(path)}
+	if issue.Protected {
+		issue.Reason = "protected by macOS privacy controls; Full Disk Access is required"
+	}
+	return issue
+}
+
+// CheckApps checks every path of the given applications that is used on this platform
+func (c *Checker) CheckApps(apps map[string]*config.AppConfig) *Report {
+	report := &Report{FullDiskAccess: c.FullDiskAccess()}
+
+	appNames := make([]string, 0, len(apps))
+	for appName := range apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		for _, path := range apps[appName].Paths {
+			if path.Type == config.PathTypeDefaults || !path.AppliesTo(config.CurrentPlatform) {
+				continue
+			}
+			if issue := c.CheckPath(appName, path.Source); issue != nil {
+				report.Issues = append(report.Issues, *issue)
+			}
+		}
+	}
+	return report
+}
+
+// NeedsFullDiskAccess reports whether granting Full Disk Access would resolve issues in the report
+func (r *Report) NeedsFullDiskAccess() bool {
+	for _, issue := range r.Issues {
+		if issue.Protected || r.FullDiskAccess == AccessDenied {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Checker) expandPath(path string) string {
+	return fsops.ExpandHome(path, c.homeDir)
+}
@@ -0,0 +1,115 @@
+package permissions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// denyProbe simulates macOS refusing access to paths below the given directories
+func denyProbe(t *testing.T, denied ...string) {
+	t.Helper()
+	original := probe
+	probe = func(path string) error {
+		for _, dir := range denied {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+			}
+		}
+		return original(path)
+	}
+	t.Cleanup(func() { probe = original })
+}
+
+// simulatePlatform makes the checker behave as on another platform for the duration of a test
+func simulatePlatform(t *testing.T, platform string) {
+	t.Helper()
+	original := config.CurrentPlatform
+	config.CurrentPlatform = platform
+	t.Cleanup(func() { config.CurrentPlatform = original })
+}
+
+func TestFullDiskAccess(t *testing.T) {
+	homeDir := t.TempDir()
+	checker := NewChecker(homeDir)
+
+	simulatePlatform(t, config.PlatformLinux)
+	if access := checker.FullDiskAccess(); access != AccessNotApplicable {
+		t.Errorf("Expected Full Disk Access not to apply on Linux, got %s", access)
+	}
+
+	simulatePlatform(t, config.PlatformDarwin)
+	if access := checker.FullDiskAccess(); access != AccessUnknown {
+		t.Errorf("Expected unknown access without protected files, got %s", access)
+	}
+
+	safari := filepath.Join(homeDir, "Library", "Safari")
+	if err := os.MkdirAll(safari, 0755); err != nil {
+		t.Fatalf("Failed to create Safari directory: %v", err)
+	}
+	if access := checker.FullDiskAccess(); access != AccessGranted {
+		t.Errorf("Expected access to be granted when Safari data is readable, got %s", access)
+	}
+
+	denyProbe(t, safari)
+	if access := checker.FullDiskAccess(); access != AccessDenied {
+		t.Errorf("Expected access to be denied when Safari data is not readable, got %s", access)
+	}
+}
+
+func TestIsProtected(t *testing.T) {
+	checker := NewChecker("/Users/test")
+
+	for _, path := range []string{"~/Library/Safari/Bookmarks.plist", "/Users/test/Library/Mail", "~/Library/Containers/com.apple.Safari/Data"} {
+		if !checker.IsProtected(path) {
+			t.Errorf("Expected %s to be protected", path)
+		}
+	}
+	for _, path := range []string{"~/Library/Preferences/com.apple.Safari.plist", "~/.gitconfig", "~/Library/SafariTechnologyPreview"} {
+		if checker.IsProtected(path) {
+			t.Errorf("Expected %s not to be protected", path)
+		}
+	}
+}
+
+func TestCheckApps(t *testing.T) {
+	simulatePlatform(t, config.PlatformDarwin)
+	homeDir := t.TempDir()
+
+	bookmarks := filepath.Join(homeDir, "Library", "Safari", "Bookmarks.plist")
+	gitconfig := filepath.Join(homeDir, ".gitconfig")
+	for _, file := range []string{bookmarks, gitconfig} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+	denyProbe(t, filepath.Join(homeDir, "Library", "Safari"))
+
+	safari := config.NewAppConfig("safari", "Safari")
+	safari.AddPath("~/Library/Safari/Bookmarks.plist", "Library/Safari/Bookmarks.plist", config.PathTypeFile, false)
+	git := config.NewAppConfig("git", "Git")
+	git.AddPath(gitconfig, ".gitconfig", config.PathTypeFile, false)
+	git.AddPath(filepath.Join(homeDir, ".missing"), ".missing", config.PathTypeFile, false)
+
+	report := NewChecker(homeDir).CheckApps(map[string]*config.AppConfig{"safari": safari, "git": git})
+
+	if report.FullDiskAccess != AccessDenied {
+		t.Errorf("Expected Full Disk Access to be denied, got %s", report.FullDiskAccess)
+	}
+	if len(report.Issues) != 1 {
+		t.Fatalf("Expected one issue, got %+v", report.Issues)
+	}
+	issue := report.Issues[0]
+	if issue.App != "safari" || issue.Path != "~/Library/Safari/Bookmarks.plist" || !issue.Protected {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+	if !report.NeedsFullDiskAccess() {
+		t.Error("Expected the report to call for Full Disk Access")
+	}
+}
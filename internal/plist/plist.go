@@ -0,0 +1,232 @@
+// Package plist reads the string values of property lists, such as the Info.plist of application bundles.
+package plist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// BinaryHeader starts every binary property list
+const BinaryHeader = "bplist00"
+
+// ErrUnsupported is returned for property lists in formats other than XML and binary, such as
+// the old ASCII format or XML in UTF-16, which plutil can still read
+var ErrUnsupported = errors.New("unsupported property list format")
+
+// binaryTrailerSize is the size of the trailer ending a binary property list
+const binaryTrailerSize = 32
+
+// Strings returns the string values of the top-level dictionary of an XML or binary property
+// list. Values of other types are left out.
+func Strings(data []byte) (map[string]string, error) {
+	if bytes.HasPrefix(data, []byte(BinaryHeader)) {
+		return binaryStrings(data)
+	}
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<?xml")) && !bytes.HasPrefix(trimmed, []byte("<!DOCTYPE")) && !bytes.HasPrefix(trimmed, []byte("<plist")) {
+		return nil, ErrUnsupported
+	}
+	return xmlStrings(data)
+}
+
+// xmlStrings reads the string values of the top-level dictionary of an XML property list
+func xmlStrings(data []byte) (map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Only UTF-8 is read natively; other declared encodings are left to plutil
+	unsupported := false
+	decoder.CharsetReader = func(label string, _ io.Reader) (io.Reader, error) {
+		unsupported = true
+		return nil, fmt.Errorf("%s encoding", label)
+	}
+	depth := 0
+	values := make(map[string]string)
+	key := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil && unsupported {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse property list: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			depth++
+			// <plist> is at depth 1 and its dictionary at depth 2, so its keys and values are at 3
+			if depth == 2 && element.Name.Local != "dict" {
+				return nil, fmt.Errorf("failed to parse property list: top-level <%s> is not a dictionary", element.Name.Local)
+			}
+			if depth != 3 {
+				continue
+			}
+			switch element.Name.Local {
+			case "key":
+				var text string
+				if err := decoder.DecodeElement(&text, &element); err != nil {
+					return nil, fmt.Errorf("failed to parse property list: %w", err)
+				}
+				key = text
+				depth--
+			case "string":
+				var text string
+				if err := decoder.DecodeElement(&text, &element); err != nil {
+					return nil, fmt.Errorf("failed to parse property list: %w", err)
+				}
+				values[key] = text
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// binaryPlist is a binary property list being read
+type binaryPlist struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+}
+
+// binaryStrings reads the string values of the top-level dictionary of a binary property list
+func binaryStrings(data []byte) (map[string]string, error) {
+	if len(data) < len(BinaryHeader)+binaryTrailerSize {
+		return nil, fmt.Errorf("failed to parse property list: binary property list is truncated")
+	}
+
+	trailer := data[len(data)-binaryTrailerSize:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:32])
+	// Each bound is checked on its own first, so the table's end cannot overflow and wrap around
+	dataSize := uint64(len(data) - binaryTrailerSize)
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 || topObject >= numObjects ||
+		numObjects > dataSize || tableOffset > dataSize || numObjects*uint64(offsetSize) > dataSize-tableOffset {
+		return nil, fmt.Errorf("failed to parse property list: invalid binary trailer")
+	}
+
+	p := &binaryPlist{data: data, refSize: refSize, offsets: make([]uint64, numObjects)}
+	for i := range p.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		p.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+
+	marker, start, err := p.object(topObject)
+	if err != nil {
+		return nil, err
+	}
+	if marker>>4 != 0xD {
+		return nil, fmt.Errorf("failed to parse property list: top-level object is not a dictionary")
+	}
+	count, start, err := p.count(marker, start)
+	if err != nil {
+		return nil, err
+	}
+	if start+2*count*uint64(refSize) > uint64(len(data)) {
+		return nil, fmt.Errorf("failed to parse property list: dictionary is truncated")
+	}
+
+	values := make(map[string]string)
+	for i := uint64(0); i < count; i++ {
+		keyRef := readUint(data[start+i*uint64(refSize) : start+(i+1)*uint64(refSize)])
+		valueRef := readUint(data[start+(count+i)*uint64(refSize) : start+(count+i+1)*uint64(refSize)])
+		key, ok, err := p.string(keyRef)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		value, ok, err := p.string(valueRef)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// object returns the marker byte of an object and the offset of the data following it
+func (p *binaryPlist) object(ref uint64) (byte, uint64, error) {
+	if ref >= uint64(len(p.offsets)) || p.offsets[ref] >= uint64(len(p.data)-binaryTrailerSize) {
+		return 0, 0, fmt.Errorf("failed to parse property list: invalid object reference %d", ref)
+	}
+	offset := p.offsets[ref]
+	return p.data[offset], offset + 1, nil
+}
+
+// count returns the length stored in a marker, which is followed by an integer object for
+// lengths of 15 and more, and the offset of the contents
+func (p *binaryPlist) count(marker byte, start uint64) (uint64, uint64, error) {
+	if marker&0x0F != 0x0F {
+		return uint64(marker & 0x0F), start, nil
+	}
+	if start >= uint64(len(p.data)) || p.data[start]>>4 != 0x1 {
+		return 0, 0, fmt.Errorf("failed to parse property list: invalid length")
+	}
+	size := uint64(1) << (p.data[start] & 0x0F)
+	if size > 8 || start+1+size > uint64(len(p.data)) {
+		return 0, 0, fmt.Errorf("failed to parse property list: invalid length")
+	}
+	count := readUint(p.data[start+1 : start+1+size])
+	if count > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("failed to parse property list: invalid length")
+	}
+	return count, start + 1 + size, nil
+}
+
+// string returns the value of a string object, and whether the object is a string
+func (p *binaryPlist) string(ref uint64) (string, bool, error) {
+	marker, start, err := p.object(ref)
+	if err != nil {
+		return "", false, err
+	}
+	kind := marker >> 4
+	if kind != 0x5 && kind != 0x6 {
+		return "", false, nil
+	}
+	count, start, err := p.count(marker, start)
+	if err != nil {
+		return "", false, err
+	}
+
+	if kind == 0x5 {
+		// ASCII
+		if start+count > uint64(len(p.data)) {
+			return "", false, fmt.Errorf("failed to parse property list: string is truncated")
+		}
+		return string(p.data[start : start+count]), true, nil
+	}
+
+	// UTF-16BE, counted in code units
+	if start+2*count > uint64(len(p.data)) {
+		return "", false, fmt.Errorf("failed to parse property list: string is truncated")
+	}
+	units := make([]uint16, count)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(p.data[start+uint64(2*i):])
+	}
+	return string(utf16.Decode(units)), true, nil
+}
+
+// readUint reads a big-endian unsigned integer of up to 8 bytes
+func readUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
@@ -0,0 +1,174 @@
+package plist
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+const xmlInfoPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>com.example.editor</string>
+	<key>CFBundleDocumentTypes</key>
+	<array>
+		<dict>
+			<key>CFBundleTypeName</key>
+			<string>Nested</string>
+		</dict>
+	</array>
+	<key>LSRequiresNativeExecution</key>
+	<true/>
+	<key>CFBundleShortVersionString</key>
+	<string>4.1 &amp; later</string>
+</dict>
+</plist>
+`
+
+func TestStringsXML(t *testing.T) {
+	values, err := Strings([]byte(xmlInfoPlist))
+	if err != nil {
+		t.Fatalf("Strings failed: %v", err)
+	}
+	expected := map[string]string{"CFBundleIdentifier": "com.example.editor", "CFBundleShortVersionString": "4.1 & later"}
+	if len(values) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s = %q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestStringsBinary(t *testing.T) {
+	long := "com.example.a-bundle-identifier-longer-than-fifteen"
+	data := encodeBinary(t, []binaryEntry{
+		{"CFBundleIdentifier", long},
+		{"CFBundleName", "Café"},
+		{"CFBundleVersion", 42},
+	})
+
+	values, err := Strings(data)
+	if err != nil {
+		t.Fatalf("Strings failed: %v", err)
+	}
+	if values["CFBundleIdentifier"] != long || values["CFBundleName"] != "Café" {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if _, exists := values["CFBundleVersion"]; exists {
+		t.Error("Expected the integer value to be left out")
+	}
+
+	if _, err := Strings(data[:len(data)-40]); err == nil {
+		t.Error("Expected a truncated binary property list to fail")
+	}
+}
+
+func TestStringsBinaryTableOverflow(t *testing.T) {
+	// A trailer whose offset table starts so far out that its end wraps around to a small number
+	data := make([]byte, len(BinaryHeader)+binaryTrailerSize+1)
+	copy(data, BinaryHeader)
+	trailer := data[len(data)-binaryTrailerSize:]
+	trailer[6], trailer[7] = 8, 1
+	binary.BigEndian.PutUint64(trailer[8:16], 1)
+	binary.BigEndian.PutUint64(trailer[24:32], 1<<64-8)
+
+	if _, err := Strings(data); err == nil {
+		t.Error("Expected an offset table beyond the end of the data to fail")
+	}
+}
+
+func FuzzBinaryStrings(f *testing.F) {
+	f.Add(encodeBinary(f, []binaryEntry{
+		{"CFBundleIdentifier", "com.example.a-bundle-identifier-longer-than-fifteen"},
+		{"CFBundleName", "Café"},
+		{"CFBundleVersion", 42},
+	}))
+	f.Add([]byte(BinaryHeader))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Malformed data must fail with an error rather than panic
+		_, _ = binaryStrings(data)
+	})
+}
+
+func TestStringsUnsupported(t *testing.T) {
+	for name, data := range map[string]string{
+		"ascii":  `{ CFBundleIdentifier = "com.example.old"; }`,
+		"utf-16": `<?xml version="1.0" encoding="UTF-16"?><plist version="1.0"><dict></dict></plist>`,
+	} {
+		if _, err := Strings([]byte(data)); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected %s property list to be unsupported, got %v", name, err)
+		}
+	}
+}
+
+// binaryEntry is a key and a string or integer value of a dictionary written by encodeBinary
+type binaryEntry struct {
+	key   string
+	value interface{}
+}
+
+// encodeBinary writes a dictionary as a binary property list with one-byte references, writing
+// strings with non-ASCII characters in UTF-16
+func encodeBinary(t testing.TB, entries []binaryEntry) []byte {
+	t.Helper()
+	var objects [][]byte
+	marker := func(kind byte, count int) []byte {
+		if count < 15 {
+			return []byte{kind<<4 | byte(count)}
+		}
+		return []byte{kind<<4 | 0x0F, 0x10, byte(count)}
+	}
+	str := func(text string) []byte {
+		for _, r := range text {
+			if r > 0x7F {
+				units := utf16.Encode([]rune(text))
+				object := marker(0x6, len(units))
+				for _, unit := range units {
+					object = binary.BigEndian.AppendUint16(object, unit)
+				}
+				return object
+			}
+		}
+		return append(marker(0x5, len(text)), text...)
+	}
+
+	dict := marker(0xD, len(entries))
+	objects = append(objects, nil)
+	for i, entry := range entries {
+		dict = append(dict, byte(1+i))
+		objects = append(objects, str(entry.key))
+	}
+	for i, entry := range entries {
+		dict = append(dict, byte(1+len(entries)+i))
+		switch value := entry.value.(type) {
+		case string:
+			objects = append(objects, str(value))
+		case int:
+			objects = append(objects, []byte{0x10, byte(value)})
+		default:
+			t.Fatalf("Unsupported value %T", value)
+		}
+	}
+	objects[0] = dict
+
+	data := []byte(BinaryHeader)
+	var offsets []byte
+	for _, object := range objects {
+		offsets = append(offsets, byte(len(data)))
+		data = append(data, object...)
+	}
+	tableOffset := len(data)
+	data = append(data, offsets...)
+
+	trailer := make([]byte, binaryTrailerSize)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(objects)))
+	binary.BigEndian.PutUint64(trailer[16:], 0)
+	binary.BigEndian.PutUint64(trailer[24:], uint64(tableOffset))
+	return append(data, trailer...)
+}
@@ -0,0 +1,229 @@
+// Package plugins runs the external executables that extend configsync without patching it:
+// detectors that describe where niche applications keep their configuration, and sync strategies
+// that export settings kept outside plain files, such as in a database, into the store and import
+// them back. A plugin is an executable in the plugins directory that reads one JSON request on its
+// standard input and writes one JSON response to its standard output.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirName is the directory in the ConfigSync config directory holding plugins
+const DirName = "plugins"
+
+// ProtocolVersion is the version of the request and response format sent in every request
+const ProtocolVersion = 1
+
+// DefaultTimeout bounds how long a plugin may take to answer a request
+const DefaultTimeout = 30 * time.Second
+
+// Capabilities a plugin declares in its describe response
+const (
+	// CapabilityDetect plugins answer detect requests with catalog entries for installed applications
+	CapabilityDetect = "detect"
+	// CapabilitySync plugins answer exists, export, and import requests for paths naming them
+	CapabilitySync = "sync"
+)
+
+// Actions a request asks a plugin to perform
+const (
+	ActionDescribe = "describe"
+	ActionDetect   = "detect"
+	ActionExists   = "exists"
+	ActionExport   = "export"
+	ActionImport   = "import"
+)
+
+// Request is what a plugin reads on its standard input
+type Request struct {
+	Protocol int    `json:"protocol"`
+	Action   string `json:"action"`
+	Home     string `json:"home"`
+	Platform string `json:"platform"`
+	App      string `json:"app,omitempty"`    // Application of the path, for exists, export, and import
+	Source   string `json:"source,omitempty"` // Path source with ~/ expanded; what it names is up to the plugin
+	Store    string `json:"store,omitempty"`  // Absolute path of the store copy to write or read
+}
+
+// Response is what a plugin writes to its standard output. A plugin that cannot perform a request
+// sets Error, or exits with a non-zero status and explains why on its standard error.
+type Response struct {
+	Error        string          `json:"error,omitempty"`
+	Description  string          `json:"description,omitempty"`  // describe: one line about the plugin
+	Capabilities []string        `json:"capabilities,omitempty"` // describe: detect, sync, or both
+	Apps         json.RawMessage `json:"apps,omitempty"`         // detect: catalog entries of installed applications
+	Exists       bool            `json:"exists,omitempty"`       // exists: whether the source has settings to export
+}
+
+// Runner runs a plugin executable with stdin as its standard input and returns its standard output
+type Runner func(ctx context.Context, path string, stdin []byte) ([]byte, error)
+
+// Plugin is an executable in the plugins directory
+type Plugin struct {
+	run      Runner
+	Name     string
+	Path     string
+	homeDir  string
+	platform string
+	timeout  time.Duration
+}
+
+// New returns the plugin at path, named after its file without an extension
+func New(path, homeDir string) *Plugin {
+	return &Plugin{
+		run:      RunExecutable,
+		Name:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Path:     path,
+		homeDir:  homeDir,
+		platform: runtime.GOOS,
+		timeout:  DefaultTimeout,
+	}
+}
+
+// WithRunner returns the plugin running its executable through run instead, e.g. in tests
+func (p *Plugin) WithRunner(run Runner) *Plugin {
+	p.run = run
+	return p
+}
+
+// Find returns the plugins in dir, sorted by name. A missing directory has none.
+func Find(dir, homeDir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+
+	var found []*Plugin
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !isExecutable(entry.Name(), info) {
+			continue
+		}
+		found = append(found, New(filepath.Join(dir, entry.Name()), homeDir))
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, nil
+}
+
+// Open returns the plugin named name in dir
+func Open(dir, name, homeDir string) (*Plugin, error) {
+	found, err := Find(dir, homeDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, plugin := range found {
+		if plugin.Name == name {
+			return plugin, nil
+		}
+	}
+	return nil, fmt.Errorf("plugin %s is not installed; put an executable named %s in %s", name, name, dir)
+}
+
+// isExecutable reports whether a directory entry is a plugin: a regular file that is executable,
+// or on Windows one with an extension Windows runs
+func isExecutable(name string, info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return slices.Contains([]string{".exe", ".bat", ".cmd"}, strings.ToLower(filepath.Ext(name)))
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// RunExecutable runs a plugin, returning its standard error in the error when it fails
+func RunExecutable(ctx context.Context, path string, stdin []byte) ([]byte, error) {
+	command := exec.CommandContext(ctx, path)
+	command.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return output, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// Call sends a request to the plugin and reads its response
+func (p *Plugin) Call(request Request) (*Response, error) {
+	request.Protocol = ProtocolVersion
+	request.Home = p.homeDir
+	request.Platform = p.platform
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	output, err := p.run(ctx, p.Path, data)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s did not answer %s within %s", p.Name, request.Action, p.timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed to %s: %w", p.Name, request.Action, err)
+	}
+
+	var response Response
+	if err := json.Unmarshal(output, &response); err != nil {
+		return nil, fmt.Errorf("plugin %s answered %s with invalid JSON: %w", p.Name, request.Action, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s failed to %s: %s", p.Name, request.Action, response.Error)
+	}
+	return &response, nil
+}
+
+// Describe asks the plugin what it is and what it can do
+func (p *Plugin) Describe() (*Response, error) {
+	return p.Call(Request{Action: ActionDescribe})
+}
+
+// Detect asks the plugin for the applications it finds installed, as a catalog document in JSON
+// that apps.ParseCatalog reads
+func (p *Plugin) Detect() ([]byte, error) {
+	response, err := p.Call(Request{Action: ActionDetect})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Apps json.RawMessage `json:"apps"`
+	}{response.Apps})
+}
+
+// Exists asks the plugin whether a path's source has settings to export
+func (p *Plugin) Exists(appName, source, store string) (bool, error) {
+	response, err := p.Call(Request{Action: ActionExists, App: appName, Source: source, Store: store})
+	if err != nil {
+		return false, err
+	}
+	return response.Exists, nil
+}
+
+// Export asks the plugin to write the settings of a path's source to its store copy
+func (p *Plugin) Export(appName, source, store string) error {
+	_, err := p.Call(Request{Action: ActionExport, App: appName, Source: source, Store: store})
+	return err
+}
+
+// Import asks the plugin to apply a path's store copy to its source
+func (p *Plugin) Import(appName, source, store string) error {
+	_, err := p.Call(Request{Action: ActionImport, App: appName, Source: source, Store: store})
+	return err
+}
@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows plugins are found by extension rather than permissions")
+	}
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{"sqlite-settings": 0755, "notes.txt": 0644, ".hidden": 0755, "detect-jetbrains.sh": 0700} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	found, err := Find(dir, "/home/test")
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	var names []string
+	for _, plugin := range found {
+		names = append(names, plugin.Name)
+	}
+	if strings.Join(names, ",") != "detect-jetbrains,sqlite-settings" {
+		t.Errorf("Expected the two executables, got %v", names)
+	}
+
+	if found, err := Find(filepath.Join(dir, "missing"), "/home/test"); err != nil || len(found) != 0 {
+		t.Errorf("Expected no plugins in a missing directory, got %v, %v", found, err)
+	}
+	if _, err := Open(dir, "notes", "/home/test"); err == nil {
+		t.Error("Expected an error opening a file that is not a plugin")
+	}
+}
+
+func TestCall(t *testing.T) {
+	var received Request
+	plugin := New("/plugins/sqlite-settings", "/home/test").WithRunner(func(_ context.Context, _ string, stdin []byte) ([]byte, error) {
+		if err := json.Unmarshal(stdin, &received); err != nil {
+			return nil, err
+		}
+		if received.Action == ActionImport {
+			return []byte(`{"error":"database is locked"}`), nil
+		}
+		return []byte(`{"exists":true}`), nil
+	})
+
+	exists, err := plugin.Exists("dbeaver", "/home/test/.dbeaver/settings.db", "/store/dbeaver/settings.json")
+	if err != nil || !exists {
+		t.Fatalf("Expected the source to exist, got %t, %v", exists, err)
+	}
+	if received.Protocol != ProtocolVersion || received.Home != "/home/test" || received.App != "dbeaver" || received.Store != "/store/dbeaver/settings.json" {
+		t.Errorf("Unexpected request %+v", received)
+	}
+
+	err = plugin.Import("dbeaver", "/home/test/.dbeaver/settings.db", "/store/dbeaver/settings.json")
+	if err == nil || !strings.Contains(err.Error(), "plugin sqlite-settings failed to import: database is locked") {
+		t.Errorf("Expected the plugin's error, got %v", err)
+	}
+
+	plugin.run = func(context.Context, string, []byte) ([]byte, error) { return []byte("not json"), nil }
+	if _, err := plugin.Describe(); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	}
+
+	plugin.timeout = time.Millisecond
+	plugin.run = func(ctx context.Context, _ string, _ []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, errors.New("killed")
+	}
+	if _, err := plugin.Describe(); err == nil || !strings.Contains(err.Error(), "did not answer describe") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+// Package progress implements a line-delimited JSON protocol for reporting the progress of
+// long-running operations to wrapper applications.
+package progress
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types emitted by the protocol
+const (
+	EventStart  = "start"
+	EventStep   = "step"
+	EventApp    = "app"
+	EventPrompt = "prompt"
+	EventAnswer = "answer"
+	EventFinish = "finish"
+	EventError  = "error"
+)
+
+// Statuses reported for applications and operations
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Event is a single line of the progress protocol
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Operation string    `json:"operation,omitempty"`
+	App       string    `json:"app,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Done      int       `json:"done,omitempty"`
+	Total     int       `json:"total,omitempty"`
+	Succeeded int       `json:"succeeded,omitempty"`
+	Failed    int       `json:"failed,omitempty"`
+}
+
+// Answer is a response to a prompt event, read as one line from the input stream
+type Answer struct {
+	ID     string `json:"id"`
+	Answer string `json:"answer"`
+}
+
+// Emitter writes progress events as JSON lines and reads prompt answers.
+// All methods are safe to call on a nil Emitter, in which case they do nothing.
+type Emitter struct {
+	out io.Writer
+	in  *bufio.Reader
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// NewEmitter creates an emitter that writes events to out and reads prompt answers from in
+func NewEmitter(out io.Writer, in io.Reader) *Emitter {
+	emitter := &Emitter{
+		out: out,
+		now: time.Now,
+	}
+	if in != nil {
+		emitter.in = bufio.NewReader(in)
+	}
+	return emitter
+}
+
+// Enabled reports whether events are being emitted
+func (e *Emitter) Enabled() bool {
+	return e != nil
+}
+
+// Start reports the beginning of an operation over total items
+func (e *Emitter) Start(operation string, total int) {
+	e.emit(Event{Type: EventStart, Operation: operation, Total: total})
+}
+
+// Step reports an informational step within an operation
+func (e *Emitter) Step(operation, message string) {
+	e.emit(Event{Type: EventStep, Operation: operation, Message: message})
+}
+
+// App reports that one application has been processed
+func (e *Emitter) App(operation, app string, done, total int, err error) {
+	event := Event{Type: EventApp, Operation: operation, App: app, Status: StatusOK, Done: done, Total: total}
+	if err != nil {
+		event.Status = StatusFailed
+		event.Error = err.Error()
+	}
+	e.emit(event)
+}
+
+// Finish reports the end of an operation
+func (e *Emitter) Finish(operation string, succeeded, failed int) {
+	status := StatusOK
+	if failed > 0 && succeeded == 0 {
+		status = StatusFailed
+	}
+	e.emit(Event{Type: EventFinish, Operation: operation, Status: status, Succeeded: succeeded, Failed: failed})
+}
+
+// Error reports that the command failed
+func (e *Emitter) Error(err error) {
+	if err == nil {
+		return
+	}
+	e.emit(Event{Type: EventError, Error: err.Error()})
+}
+
+// Confirm emits a yes/no prompt and waits for an answer line on the input stream.
+// The answer may be a JSON Answer object or a bare "yes"/"no". Anything else, including
+// end of input, is treated as no.
+func (e *Emitter) Confirm(id, question string) bool {
+	if e == nil {
+		return false
+	}
+
+	e.emit(Event{Type: EventPrompt, ID: id, Message: question})
+	if e.in == nil {
+		return false
+	}
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+
+	answer := strings.TrimSpace(line)
+	var parsed Answer
+	if json.Unmarshal([]byte(answer), &parsed) == nil && parsed.Answer != "" {
+		answer = parsed.Answer
+	}
+
+	accepted := isYes(answer)
+	status := "no"
+	if accepted {
+		status = "yes"
+	}
+	e.emit(Event{Type: EventAnswer, ID: id, Status: status})
+
+	return accepted
+}
+
+// Helper methods
+
+func (e *Emitter) emit(event Event) {
+	if e == nil {
+		return
+	}
+
+	event.Time = e.now().UTC()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = fmt.Fprintf(e.out, "%s\n", data)
+}
+
+func isYes(answer string) bool {
+	switch strings.ToLower(answer) {
+	case "y", "yes", "true":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,102 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeEvents(t *testing.T, data string) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEmitterWritesOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewEmitter(&buf, nil)
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	emitter.now = func() time.Time { return fixed }
+
+	emitter.Start("sync", 2)
+	emitter.App("sync", "vscode", 1, 2, nil)
+	emitter.App("sync", "iterm2", 2, 2, errors.New("permission denied"))
+	emitter.Finish("sync", 1, 1)
+
+	events := decodeEvents(t, buf.String())
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	if events[0].Type != EventStart || events[0].Total != 2 {
+		t.Errorf("Unexpected start event: %+v", events[0])
+	}
+	if events[1].Status != StatusOK || events[1].App != "vscode" || events[1].Done != 1 {
+		t.Errorf("Unexpected app event: %+v", events[1])
+	}
+	if events[2].Status != StatusFailed || events[2].Error != "permission denied" {
+		t.Errorf("Expected failed app event, got %+v", events[2])
+	}
+	if events[3].Type != EventFinish || events[3].Status != StatusOK || events[3].Failed != 1 {
+		t.Errorf("Unexpected finish event: %+v", events[3])
+	}
+	if !events[0].Time.Equal(fixed) {
+		t.Errorf("Expected timestamp %v, got %v", fixed, events[0].Time)
+	}
+}
+
+func TestNilEmitterIsNoop(t *testing.T) {
+	var emitter *Emitter
+
+	if emitter.Enabled() {
+		t.Error("Expected nil emitter to be disabled")
+	}
+	emitter.Start("sync", 1)
+	emitter.App("sync", "vscode", 1, 1, nil)
+	emitter.Error(errors.New("boom"))
+	if emitter.Confirm("large", "Continue?") {
+		t.Error("Expected nil emitter to decline prompts")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{"json yes", `{"id":"large","answer":"yes"}` + "\n", true},
+		{"bare yes", "y\n", true},
+		{"json no", `{"id":"large","answer":"no"}` + "\n", false},
+		{"end of input", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			emitter := NewEmitter(&buf, strings.NewReader(tt.input))
+
+			if got := emitter.Confirm("large", "Move it anyway?"); got != tt.expected {
+				t.Errorf("Expected %t, got %t", tt.expected, got)
+			}
+
+			events := decodeEvents(t, buf.String())
+			if len(events) == 0 || events[0].Type != EventPrompt || events[0].ID != "large" {
+				t.Errorf("Expected prompt event first, got %+v", events)
+			}
+		})
+	}
+}
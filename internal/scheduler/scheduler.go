@@ -0,0 +1,265 @@
+// Package scheduler provides functionality for scheduling periodic syncs via launchd.
+package scheduler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLabel is the launchd job label used for scheduled syncs
+	DefaultLabel = "com.dotbrains.configsync.sync"
+	// DefaultInterval is the default interval between scheduled syncs
+	DefaultInterval = time.Hour
+	// MinInterval is the smallest interval accepted for scheduled syncs
+	MinInterval = time.Minute
+)
+
+// Schedule describes when the scheduled sync job runs
+type Schedule struct {
+	Interval time.Duration
+	AtLogin  bool
+}
+
+// Status represents the current state of the scheduled sync job
+type Status struct {
+	PlistPath string
+	LogPath   string
+	Schedule  Schedule
+	Installed bool
+	Loaded    bool
+}
+
+// Manager handles installation and removal of the launchd sync job
+type Manager struct {
+	runCommand func(name string, args ...string) ([]byte, error)
+	homeDir    string
+	binaryPath string
+	logDir     string
+	label      string
+	verbose    bool
+	env        map[string]string
+}
+
+// NewManager creates a new scheduler manager
+func NewManager(homeDir, binaryPath, logDir string, verbose bool) *Manager {
+	return &Manager{
+		homeDir:    homeDir,
+		binaryPath: binaryPath,
+		logDir:     logDir,
+		label:      DefaultLabel,
+		verbose:    verbose,
+		runCommand: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).CombinedOutput()
+		},
+	}
+}
+
+// SetEnvironment sets environment variables for the scheduled sync, such as the
+// CONFIGSYNC_* variables that relocate the configuration
+func (m *Manager) SetEnvironment(env map[string]string) {
+	m.env = env
+}
+
+// PlistPath returns the path of the launchd agent plist
+func (m *Manager) PlistPath() string {
+	return filepath.Join(m.homeDir, "Library", "LaunchAgents", m.label+".plist")
+}
+
+// LogPath returns the path that scheduled sync output is written to
+func (m *Manager) LogPath() string {
+	return filepath.Join(m.logDir, "scheduled-sync.log")
+}
+
+// GeneratePlist renders the launchd agent plist for the given schedule
+func (m *Manager) GeneratePlist(schedule Schedule) ([]byte, error) {
+	if err := validateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+
+	writeKeyString(&buf, "Label", m.label)
+
+	buf.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range []string{m.binaryPath, "sync", "--notify", "--home", m.homeDir} {
+		fmt.Fprintf(&buf, "\t\t<string>%s</string>\n", escapeXML(arg))
+	}
+	buf.WriteString("\t</array>\n")
+
+	if len(m.env) > 0 {
+		buf.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+		names := make([]string, 0, len(m.env))
+		for name := range m.env {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", escapeXML(name), escapeXML(m.env[name]))
+		}
+		buf.WriteString("\t</dict>\n")
+	}
+
+	if schedule.Interval > 0 {
+		fmt.Fprintf(&buf, "\t<key>StartInterval</key>\n\t<integer>%d</integer>\n", int64(schedule.Interval/time.Second))
+	}
+
+	buf.WriteString("\t<key>RunAtLoad</key>\n")
+	if schedule.AtLogin {
+		buf.WriteString("\t<true/>\n")
+	} else {
+		buf.WriteString("\t<false/>\n")
+	}
+
+	writeKeyString(&buf, "StandardOutPath", m.LogPath())
+	writeKeyString(&buf, "StandardErrorPath", m.LogPath())
+
+	buf.WriteString("</dict>\n</plist>\n")
+	return buf.Bytes(), nil
+}
+
+// Install writes the launchd agent plist and loads it with launchctl
+func (m *Manager) Install(schedule Schedule) error {
+	data, err := m.GeneratePlist(schedule)
+	if err != nil {
+		return err
+	}
+
+	plistPath := m.PlistPath()
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.MkdirAll(m.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	// Unload any previous version so the new schedule takes effect
+	if m.isInstalled() {
+		if m.verbose {
+			fmt.Printf("Unloading existing schedule: %s\n", plistPath)
+		}
+		_, _ = m.runCommand("launchctl", "unload", plistPath)
+	}
+
+	if m.verbose {
+		fmt.Printf("Writing launchd agent: %s\n", plistPath)
+	}
+	if err := os.WriteFile(plistPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write launchd agent: %w", err)
+	}
+
+	if output, err := m.runCommand("launchctl", "load", "-w", plistPath); err != nil {
+		return fmt.Errorf("failed to load launchd agent: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Remove unloads the launchd agent and deletes its plist
+func (m *Manager) Remove() error {
+	plistPath := m.PlistPath()
+	if !m.isInstalled() {
+		return fmt.Errorf("no scheduled sync is installed")
+	}
+
+	if output, err := m.runCommand("launchctl", "unload", "-w", plistPath); err != nil {
+		if m.verbose {
+			fmt.Printf("Warning: failed to unload launchd agent: %v: %s\n", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	if err := os.Remove(plistPath); err != nil {
+		return fmt.Errorf("failed to remove launchd agent: %w", err)
+	}
+
+	return nil
+}
+
+// Status reports whether the launchd agent is installed and loaded
+func (m *Manager) Status() (*Status, error) {
+	status := &Status{
+		PlistPath: m.PlistPath(),
+		LogPath:   m.LogPath(),
+	}
+
+	if !m.isInstalled() {
+		return status, nil
+	}
+	status.Installed = true
+
+	data, err := os.ReadFile(status.PlistPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read launchd agent: %w", err)
+	}
+	status.Schedule = parseSchedule(data)
+
+	if _, err := m.runCommand("launchctl", "list", m.label); err == nil {
+		status.Loaded = true
+	}
+
+	return status, nil
+}
+
+// Helper methods
+
+func (m *Manager) isInstalled() bool {
+	_, err := os.Stat(m.PlistPath())
+	return err == nil
+}
+
+func validateSchedule(schedule Schedule) error {
+	if schedule.Interval == 0 && !schedule.AtLogin {
+		return fmt.Errorf("schedule must specify an interval, run at login, or both")
+	}
+	if schedule.Interval < 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if schedule.Interval > 0 && schedule.Interval < MinInterval {
+		return fmt.Errorf("interval must be at least %s", MinInterval)
+	}
+	return nil
+}
+
+// parseSchedule extracts the schedule from a plist previously written by GeneratePlist
+func parseSchedule(data []byte) Schedule {
+	var schedule Schedule
+	content := string(data)
+
+	if idx := strings.Index(content, "<key>StartInterval</key>"); idx >= 0 {
+		rest := content[idx:]
+		start := strings.Index(rest, "<integer>")
+		end := strings.Index(rest, "</integer>")
+		if start >= 0 && end > start {
+			var seconds int64
+			if _, err := fmt.Sscanf(rest[start+len("<integer>"):end], "%d", &seconds); err == nil {
+				schedule.Interval = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	if idx := strings.Index(content, "<key>RunAtLoad</key>"); idx >= 0 {
+		schedule.AtLogin = strings.HasPrefix(strings.TrimSpace(content[idx+len("<key>RunAtLoad</key>"):]), "<true/>")
+	}
+
+	return schedule
+}
+
+func writeKeyString(buf *bytes.Buffer, key, value string) {
+	fmt.Fprintf(buf, "\t<key>%s</key>\n\t<string>%s</string>\n", key, escapeXML(value))
+}
+
+func escapeXML(value string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
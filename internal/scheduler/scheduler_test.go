@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManager creates a manager whose launchctl calls are recorded instead of executed
+func newTestManager(t *testing.T) (*Manager, *[]string) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, "/usr/local/bin/configsync", filepath.Join(tempDir, ".configsync", "logs"), false)
+
+	var calls []string
+	manager.runCommand = func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		return nil, nil
+	}
+
+	return manager, &calls
+}
+
+func TestGeneratePlist(t *testing.T) {
+	manager, _ := newTestManager(t)
+
+	data, err := manager.GeneratePlist(Schedule{Interval: time.Hour, AtLogin: true})
+	if err != nil {
+		t.Fatalf("GeneratePlist failed: %v", err)
+	}
+
+	content := string(data)
+	expected := []string{
+		"<string>" + DefaultLabel + "</string>",
+		"<string>/usr/local/bin/configsync</string>",
+		"<string>sync</string>",
+		"<string>--notify</string>",
+		"<key>StartInterval</key>\n\t<integer>3600</integer>",
+		"<key>RunAtLoad</key>\n\t<true/>",
+		"scheduled-sync.log",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected plist to contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestGeneratePlistEnvironment(t *testing.T) {
+	manager, _ := newTestManager(t)
+	manager.SetEnvironment(map[string]string{"CONFIGSYNC_STORE": "/Volumes/Data/store"})
+
+	data, err := manager.GeneratePlist(Schedule{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("GeneratePlist failed: %v", err)
+	}
+
+	want := "<key>EnvironmentVariables</key>\n\t<dict>\n\t\t<key>CONFIGSYNC_STORE</key>\n\t\t<string>/Volumes/Data/store</string>"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("Expected plist to set the environment, got:\n%s", data)
+	}
+}
+
+func TestGeneratePlistInvalidSchedule(t *testing.T) {
+	manager, _ := newTestManager(t)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"empty schedule", Schedule{}},
+		{"negative interval", Schedule{Interval: -time.Hour}},
+		{"interval too short", Schedule{Interval: time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.GeneratePlist(tt.schedule); err == nil {
+				t.Error("Expected error for invalid schedule")
+			}
+		})
+	}
+}
+
+func TestInstallAndStatus(t *testing.T) {
+	manager, calls := newTestManager(t)
+
+	schedule := Schedule{Interval: 30 * time.Minute}
+	if err := manager.Install(schedule); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	if _, err := os.Stat(manager.PlistPath()); err != nil {
+		t.Fatalf("Expected plist to be written: %v", err)
+	}
+
+	if len(*calls) != 1 || !strings.HasPrefix((*calls)[0], "launchctl load") {
+		t.Errorf("Expected launchctl load call, got %v", *calls)
+	}
+
+	status, err := manager.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	if !status.Installed || !status.Loaded {
+		t.Errorf("Expected installed and loaded status, got %+v", status)
+	}
+
+	if status.Schedule != schedule {
+		t.Errorf("Expected schedule %+v, got %+v", schedule, status.Schedule)
+	}
+}
+
+func TestInstallReplacesExisting(t *testing.T) {
+	manager, calls := newTestManager(t)
+
+	if err := manager.Install(Schedule{Interval: time.Hour}); err != nil {
+		t.Fatalf("First install failed: %v", err)
+	}
+	if err := manager.Install(Schedule{AtLogin: true}); err != nil {
+		t.Fatalf("Second install failed: %v", err)
+	}
+
+	if len(*calls) != 3 || !strings.HasPrefix((*calls)[1], "launchctl unload") {
+		t.Errorf("Expected existing agent to be unloaded before reinstall, got %v", *calls)
+	}
+
+	status, err := manager.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Schedule.Interval != 0 || !status.Schedule.AtLogin {
+		t.Errorf("Expected login-only schedule, got %+v", status.Schedule)
+	}
+}
+
+func TestInstallLoadFailure(t *testing.T) {
+	manager, _ := newTestManager(t)
+	manager.runCommand = func(_ string, _ ...string) ([]byte, error) {
+		return []byte("service already loaded"), fmt.Errorf("exit status 1")
+	}
+
+	err := manager.Install(Schedule{Interval: time.Hour})
+	if err == nil {
+		t.Fatal("Expected error when launchctl load fails")
+	}
+	if !strings.Contains(err.Error(), "service already loaded") {
+		t.Errorf("Expected launchctl output in error, got: %v", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	manager, calls := newTestManager(t)
+
+	if err := manager.Remove(); err == nil {
+		t.Error("Expected error when removing a schedule that is not installed")
+	}
+
+	if err := manager.Install(Schedule{Interval: time.Hour}); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	if err := manager.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if _, err := os.Stat(manager.PlistPath()); !os.IsNotExist(err) {
+		t.Error("Expected plist to be removed")
+	}
+
+	last := (*calls)[len(*calls)-1]
+	if !strings.HasPrefix(last, "launchctl unload") {
+		t.Errorf("Expected launchctl unload call, got %s", last)
+	}
+}
+
+func TestStatusNotInstalled(t *testing.T) {
+	manager, _ := newTestManager(t)
+
+	status, err := manager.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	if status.Installed || status.Loaded {
+		t.Errorf("Expected not installed status, got %+v", status)
+	}
+}
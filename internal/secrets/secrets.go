@@ -0,0 +1,189 @@
+// Package secrets keeps the content of sensitive files, such as API token files, in the macOS
+// Keychain or 1Password instead of the store. The store, and the bundles exported from it, only
+// hold a reference naming where the content is kept.
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Secret backends
+const (
+	// Keychain keeps secrets as generic passwords in the login keychain, through the security tool
+	Keychain = "keychain"
+	// OnePassword keeps secrets as documents in 1Password, through the op CLI
+	OnePassword = "1password"
+)
+
+// Backends lists the supported secret backends
+var Backends = []string{Keychain, OnePassword}
+
+// keychainService is the service of the keychain items configsync creates
+const keychainService = "configsync"
+
+// ErrNotFound is returned when a backend has no secret for an item
+var ErrNotFound = errors.New("secret not found")
+
+// CommandRunner executes an external command with data on its standard input and returns its
+// standard output
+type CommandRunner func(stdin []byte, name string, args ...string) ([]byte, error)
+
+// Backend reads and writes the content of secrets by item name
+type Backend interface {
+	// Read returns the content of an item, or ErrNotFound
+	Read(item string) ([]byte, error)
+	// Write creates an item or replaces its content
+	Write(item string, content []byte) error
+}
+
+// New returns the backend with a name, which runs its command line tool through run
+func New(name string, run CommandRunner) (Backend, error) {
+	switch name {
+	case Keychain:
+		return &keychain{run: run}, nil
+	case OnePassword:
+		return &onePassword{run: run}, nil
+	}
+	return nil, fmt.Errorf("unknown secret backend %q (use %s)", name, strings.Join(Backends, " or "))
+}
+
+// RunCommand runs a command line tool, returning its standard error in the error when it fails
+func RunCommand(stdin []byte, name string, args ...string) ([]byte, error) {
+	command := exec.Command(name, args...)
+	if stdin != nil {
+		command.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return output, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// ItemName returns the name of the item that keeps the content of an application's path
+func ItemName(appName, destination string) string {
+	return "configsync/" + appName + "/" + strings.ReplaceAll(destination, `\`, "/")
+}
+
+// keychain keeps secrets base64-encoded, since generic passwords are text
+type keychain struct {
+	run CommandRunner
+}
+
+func (k *keychain) Read(item string) ([]byte, error) {
+	output, err := k.run(nil, "security", "find-generic-password", "-s", keychainService, "-a", item, "-w")
+	if err != nil {
+		if strings.Contains(err.Error(), "could not be found") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read %s from the keychain: %w", item, err)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("keychain item %s was not written by configsync: %w", item, err)
+	}
+	return content, nil
+}
+
+func (k *keychain) Write(item string, content []byte) error {
+	// The command is given on standard input, so the secret does not show up in the process list
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		quote(keychainService), quote(item), base64.StdEncoding.EncodeToString(content))
+	output, err := k.run([]byte(command), "security", "-i")
+	if err == nil && strings.Contains(string(output), "security: ") {
+		err = errors.New(strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s to the keychain: %w", item, err)
+	}
+	return nil
+}
+
+// quote quotes an argument for the interactive mode of the security tool
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// onePassword keeps secrets as documents titled with the item name
+type onePassword struct {
+	run CommandRunner
+}
+
+func (o *onePassword) Read(item string) ([]byte, error) {
+	output, err := o.run(nil, "op", "document", "get", item)
+	if err != nil {
+		if isOnePasswordNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read %s from 1Password: %w", item, err)
+	}
+	return output, nil
+}
+
+func (o *onePassword) Write(item string, content []byte) error {
+	_, err := o.run(content, "op", "document", "edit", item, "-")
+	if err != nil && isOnePasswordNotFound(err) {
+		_, err = o.run(content, "op", "document", "create", "-", "--title", item, "--file-name", fileName(item))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s to 1Password: %w", item, err)
+	}
+	return nil
+}
+
+// isOnePasswordNotFound reports whether op failed because an item does not exist
+func isOnePasswordNotFound(err error) bool {
+	return strings.Contains(err.Error(), "isn't an item") || strings.Contains(err.Error(), "not found")
+}
+
+// fileName returns the last element of an item name
+func fileName(item string) string {
+	return item[strings.LastIndex(item, "/")+1:]
+}
+
+// Reference is kept in the store in place of a secret's content
+type Reference struct {
+	UpdatedAt time.Time `yaml:"updated_at"` // When the content in the backend last changed
+	Backend   string    `yaml:"backend"`
+	Item      string    `yaml:"item"`
+}
+
+// referenceFile is the layout of a reference in the store
+type referenceFile struct {
+	Secret *Reference `yaml:"configsync_secret"`
+}
+
+// referenceHeader explains a reference to someone who opens it in the store
+const referenceHeader = "# The content of this file is kept in %s, not in the store. configsync writes it\n# at its source when syncing.\n"
+
+// ErrNotReference is returned by ParseReference for a store file holding content rather than a reference
+var ErrNotReference = errors.New("not a secret reference")
+
+// ParseReference decodes a reference kept in the store. It returns ErrNotReference when the
+// file holds content instead, such as from syncing the path before it was made a secret.
+func ParseReference(data []byte) (*Reference, error) {
+	var file referenceFile
+	if err := yaml.Unmarshal(data, &file); err != nil || file.Secret == nil || file.Secret.Item == "" {
+		return nil, ErrNotReference
+	}
+	return file.Secret, nil
+}
+
+// Encode returns the reference as it is kept in the store
+func (r *Reference) Encode() ([]byte, error) {
+	data, err := yaml.Marshal(referenceFile{Secret: r})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secret reference: %w", err)
+	}
+	return append([]byte(fmt.Sprintf(referenceHeader, r.Backend)), data...), nil
+}
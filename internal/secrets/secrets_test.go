@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeKeychain emulates the security tool, keeping generic passwords by account
+type fakeKeychain struct {
+	passwords map[string]string
+	calls     []string
+}
+
+func (f *fakeKeychain) run(stdin []byte, name string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	if args[0] == "-i" {
+		// add-generic-password -U -s "configsync" -a "<item>" -w <password>
+		fields := strings.Fields(string(stdin))
+		f.passwords[strings.Trim(fields[5], `"`)] = fields[7]
+		return nil, nil
+	}
+	password, ok := f.passwords[args[4]]
+	if !ok {
+		return nil, fmt.Errorf("security failed: exit status 44: security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain.")
+	}
+	return []byte(password + "\n"), nil
+}
+
+func TestKeychain(t *testing.T) {
+	fake := &fakeKeychain{passwords: make(map[string]string)}
+	backend, err := New(Keychain, fake.run)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	item := ItemName("gh", ".config/gh/hosts.yml")
+	if _, err := backend.Read(item); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing item, got %v", err)
+	}
+
+	content := []byte("github.com:\n    oauth_token: gho_secret\n")
+	if err := backend.Write(item, content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, call := range fake.calls {
+		if strings.Contains(call, "gho_secret") {
+			t.Errorf("Expected the secret to be kept out of the command line, got %q", call)
+		}
+	}
+	if read, err := backend.Read(item); err != nil || string(read) != string(content) {
+		t.Errorf("Expected the content back, got %q, %v", read, err)
+	}
+
+	if _, err := New("vault", fake.run); err == nil {
+		t.Error("Expected an unknown backend to be refused")
+	}
+}
+
+func TestOnePasswordCreatesMissingDocument(t *testing.T) {
+	documents := make(map[string]string)
+	run := func(stdin []byte, name string, args ...string) ([]byte, error) {
+		switch args[1] {
+		case "get", "edit":
+			content, ok := documents[args[2]]
+			if !ok {
+				return nil, fmt.Errorf("op failed: exit status 1: [ERROR] %q isn't an item", args[2])
+			}
+			if args[1] == "edit" {
+				documents[args[2]] = string(stdin)
+			}
+			return []byte(content), nil
+		case "create":
+			documents[args[4]] = string(stdin)
+		}
+		return nil, nil
+	}
+
+	backend, _ := New(OnePassword, run)
+	if err := backend.Write("configsync/npm/.npmrc", []byte("token=1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Write("configsync/npm/.npmrc", []byte("token=2")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if content, err := backend.Read("configsync/npm/.npmrc"); err != nil || string(content) != "token=2" {
+		t.Errorf("Expected the edited document, got %q, %v", content, err)
+	}
+}
+
+func TestReference(t *testing.T) {
+	ref := &Reference{Backend: Keychain, Item: "configsync/gh/hosts.yml", UpdatedAt: time.Now().Truncate(time.Second)}
+	data, err := ref.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	parsed, err := ParseReference(data)
+	if err != nil || parsed.Item != ref.Item || parsed.Backend != ref.Backend || !parsed.UpdatedAt.Equal(ref.UpdatedAt) {
+		t.Errorf("Expected the reference back, got %+v, %v", parsed, err)
+	}
+
+	if _, err := ParseReference([]byte("github.com:\n    oauth_token: gho_secret\n")); !errors.Is(err, ErrNotReference) {
+		t.Errorf("Expected ErrNotReference for content, got %v", err)
+	}
+}
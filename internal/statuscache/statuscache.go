@@ -0,0 +1,151 @@
+// Package statuscache remembers the sync status of every configured path together with the
+// modification times of the files it was worked out from, so 'configsync status' only rechecks
+// the paths that changed since the last sync or status check.
+package statuscache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of the cache in the configuration directory
+const FileName = "status-cache.json"
+
+// Stamp records the state of a file as its status was worked out. A path that did not exist is
+// recorded with Exists false.
+type Stamp struct {
+	ModTime time.Time   `json:"mod_time"`
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	Exists  bool        `json:"exists"`
+}
+
+// StampOf records the current state of a path, without following a symlink at it
+func StampOf(path string) Stamp {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Stamp{Path: path}
+	}
+	return Stamp{ModTime: info.ModTime(), Path: path, Size: info.Size(), Mode: info.Mode(), Exists: true}
+}
+
+// Current reports whether the path is still in the recorded state
+func (s Stamp) Current() bool {
+	now := StampOf(s.Path)
+	return now.Exists == s.Exists && now.Mode == s.Mode && now.Size == s.Size && now.ModTime.Equal(s.ModTime)
+}
+
+// Link is the cached status of one of a path's links
+type Link struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// Entry is the cached status of one configured path
+type Entry struct {
+	Fingerprint string  `json:"fingerprint"` // Identifies the path's configuration the status was worked out for
+	Status      string  `json:"status"`
+	Links       []Link  `json:"links,omitempty"`
+	Stamps      []Stamp `json:"stamps"` // The files the status depends on
+}
+
+// Cache holds the cached path statuses, keyed by application and path. Entries that are neither
+// looked up nor stored between Load and Save are dropped when it is saved, so the cache only
+// keeps the paths still configured.
+type Cache struct {
+	UpdatedAt time.Time         `json:"updated_at"`
+	Entries   map[string]*Entry `json:"entries"`
+	Rebuild   bool              `json:"-"` // When set, Lookup finds nothing, so every status is worked out and stored again
+	path      string
+	used      map[string]bool
+	changed   bool
+}
+
+// Load reads the cache in the configuration directory. A missing or unreadable cache is treated
+// as empty, since every status can be worked out again.
+func Load(configDir string) *Cache {
+	c := &Cache{path: filepath.Join(configDir, FileName)}
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			c.Entries = nil
+			c.changed = true
+		}
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*Entry)
+	}
+	c.used = make(map[string]bool)
+	return c
+}
+
+// Key returns the cache key of one of an application's paths
+func Key(appName, destination string) string {
+	return appName + "/" + destination
+}
+
+// Lookup returns the cached entry for a key if it was worked out for the same configuration and
+// none of the files it depends on have changed since
+func (c *Cache) Lookup(key, fingerprint string) (*Entry, bool) {
+	c.used[key] = true
+	entry, ok := c.Entries[key]
+	if c.Rebuild || !ok || entry.Fingerprint != fingerprint {
+		return nil, false
+	}
+	for _, stamp := range entry.Stamps {
+		if !stamp.Current() {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+// Store caches the entry for a key, replacing any cached before
+func (c *Cache) Store(key string, entry *Entry) {
+	c.used[key] = true
+	c.Entries[key] = entry
+	c.changed = true
+}
+
+// Forget drops the cached entry for a key, for statuses that must be worked out every time
+func (c *Cache) Forget(key string) {
+	if _, ok := c.Entries[key]; ok {
+		delete(c.Entries, key)
+		c.changed = true
+	}
+}
+
+// Save writes the cache if anything changed, dropping the entries that were not used
+func (c *Cache) Save() error {
+	for key := range c.Entries {
+		if !c.used[key] {
+			delete(c.Entries, key)
+			c.changed = true
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+
+	c.UpdatedAt = time.Now()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode status cache: %w", err)
+	}
+
+	// Write a temporary file and rename it, so a status check running at the same time never
+	// reads a partial cache
+	temp := c.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write status cache: %w", err)
+	}
+	if err := os.Rename(temp, c.path); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("failed to write status cache: %w", err)
+	}
+	c.changed = false
+	return nil
+}
@@ -0,0 +1,66 @@
+package statuscache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookupInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "settings.json")
+	if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cache := Load(dir)
+	cache.Store(Key("editor", "settings.json"), &Entry{Fingerprint: "a", Status: "synced", Stamps: []Stamp{StampOf(file)}})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cache = Load(dir)
+	if _, ok := cache.Lookup(Key("editor", "settings.json"), "a"); !ok {
+		t.Error("Expected the unchanged entry to be found")
+	}
+	if _, ok := cache.Lookup(Key("editor", "settings.json"), "b"); ok {
+		t.Error("Expected an entry for another configuration not to be found")
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if _, ok := cache.Lookup(Key("editor", "settings.json"), "a"); ok {
+		t.Error("Expected a modified file to invalidate the entry")
+	}
+}
+
+func TestSaveDropsUnusedEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	cache := Load(dir)
+	cache.Store(Key("editor", "a"), &Entry{Status: "synced"})
+	cache.Store(Key("removed", "b"), &Entry{Status: "synced"})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cache = Load(dir)
+	cache.Lookup(Key("editor", "a"), "")
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if cache = Load(dir); len(cache.Entries) != 1 || cache.Entries[Key("editor", "a")] == nil {
+		t.Errorf("Expected only the used entry to be kept, got %v", cache.Entries)
+	}
+
+	// A damaged cache is treated as empty
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if cache = Load(dir); len(cache.Entries) != 0 {
+		t.Errorf("Expected an empty cache, got %v", cache.Entries)
+	}
+}
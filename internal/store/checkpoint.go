@@ -0,0 +1,572 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// CheckpointDir is the directory in the configuration directory that holds named checkpoints
+const CheckpointDir = "checkpoints"
+
+const (
+	checkpointInfoFile = "checkpoint.yaml" // Written last, so a checkpoint without it is incomplete
+	// checkpointStaged and checkpointAside suffix the paths a restore builds beside each live path
+	// and moves each replaced path to until the restore is complete
+	checkpointStaged = ".configsync-checkpoint"
+	checkpointAside  = ".configsync-replaced"
+)
+
+// Checkpoint is a named restore point of the configuration, the store, and every live location of
+// the managed paths
+type Checkpoint struct {
+	CreatedAt time.Time        `json:"created_at" yaml:"created_at"`
+	Name      string           `json:"name" yaml:"name"`
+	StorePath string           `json:"store_path" yaml:"store_path"`
+	Apps      []string         `json:"apps" yaml:"apps"`
+	Paths     []CheckpointPath `json:"paths" yaml:"paths"`
+	Files     int              `json:"files" yaml:"files"`
+	Size      int64            `json:"size" yaml:"size"` // Total size of the saved files
+}
+
+// CheckpointPath is the state of one live location when a checkpoint was created
+type CheckpointPath struct {
+	App        string `json:"app" yaml:"app"`
+	Source     string `json:"source" yaml:"source"` // The location, with ~/ expanded
+	State      string `json:"state" yaml:"state"`   // missing, symlink, or file
+	LinkTarget string `json:"link_target,omitempty" yaml:"link_target,omitempty"`
+}
+
+// CheckpointResult summarizes a checkpoint restore
+type CheckpointResult struct {
+	Checkpoint *Checkpoint
+	Replaced   []string // Live locations changed to match the checkpoint
+	Unchanged  int      // Live locations that already matched it
+}
+
+// Checkpointer creates, lists, restores, and deletes named checkpoints
+type Checkpointer struct {
+	out     io.Writer
+	homeDir string
+	dir     string
+	verbose bool
+}
+
+// NewCheckpointer creates a checkpointer keeping checkpoints in the configuration directory
+func NewCheckpointer(homeDir, configDir string, verbose bool) *Checkpointer {
+	return &Checkpointer{
+		out:     os.Stdout,
+		homeDir: homeDir,
+		dir:     filepath.Join(configDir, CheckpointDir),
+		verbose: verbose,
+	}
+}
+
+// Dir returns the directory holding the checkpoints
+func (c *Checkpointer) Dir() string {
+	return c.dir
+}
+
+// CheckName returns an error if a checkpoint name cannot be used as a directory name
+func CheckName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("checkpoint name must not be empty")
+	case strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid checkpoint name %q: it must not contain slashes or start with a dot", name)
+	}
+	return nil
+}
+
+// Create saves the configuration, the store, and the state of every live location of the
+// managed paths as a checkpoint: where a symlink points, or a copy of a file or directory.
+// Store files unchanged since the newest checkpoint are hard-linked to its copy.
+func (c *Checkpointer) Create(configManager *config.Manager, name string) (*Checkpoint, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	checkpointPath := filepath.Join(c.dir, name)
+	if _, err := c.load(name); err == nil {
+		return nil, fmt.Errorf("checkpoint %s already exists (delete it first with 'configsync checkpoint delete %s')", name, name)
+	}
+	// An incomplete checkpoint left by an interrupted create is replaced
+	if err := os.RemoveAll(checkpointPath); err != nil {
+		return nil, fmt.Errorf("failed to clear %s: %w", checkpointPath, err)
+	}
+
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	// captureTree looks up unchanged files by their path below the checkpoint, store/ included
+	var previousCheckpoint string
+	if checkpoints, err := c.List(); err == nil && len(checkpoints) > 0 {
+		previousCheckpoint = filepath.Join(c.dir, checkpoints[0].Name)
+	}
+
+	checkpoint := &Checkpoint{
+		CreatedAt: time.Now(),
+		Name:      name,
+		StorePath: cfg.StorePath,
+		Apps:      appNames(cfg),
+	}
+
+	complete := false
+	defer func() {
+		if !complete {
+			_ = os.RemoveAll(checkpointPath)
+		}
+	}()
+
+	if err := os.MkdirAll(checkpointPath, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	configData, err := configManager.ConfigData()
+	if err == nil {
+		err = os.WriteFile(filepath.Join(checkpointPath, snapshotConfigFile), configData, 0644)
+	}
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+
+	if c.verbose {
+		fmt.Fprintf(c.out, "Saving store: %s\n", cfg.StorePath)
+	}
+	if exists(cfg.StorePath) {
+		if err := captureTree(cfg.StorePath, checkpointPath, previousCheckpoint, snapshotStoreDir); err != nil {
+			return nil, fmt.Errorf("failed to save store: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, appName := range checkpoint.Apps {
+		for _, path := range cfg.Apps[appName].Paths {
+			if path.IsExported() || !path.AppliesTo(config.CurrentPlatform) {
+				continue
+			}
+			for _, location := range path.LiveLocations() {
+				source := expandHomePath(c.homeDir, location)
+				if seen[source] {
+					continue
+				}
+				seen[source] = true
+
+				state, err := c.capture(checkpointPath, len(checkpoint.Paths), appName, source)
+				if err != nil {
+					return nil, fmt.Errorf("failed to save %s: %w", source, err)
+				}
+				checkpoint.Paths = append(checkpoint.Paths, state)
+			}
+		}
+	}
+
+	checkpoint.Files, checkpoint.Size = treeStats(checkpointPath)
+
+	data, err := yaml.Marshal(checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(checkpointPath, checkpointInfoFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	complete = true
+	return checkpoint, nil
+}
+
+// List returns the complete checkpoints, newest first
+func (c *Checkpointer) List() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	var checkpoints []*Checkpoint
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		checkpoint, err := c.load(entry.Name())
+		if err != nil {
+			if c.verbose {
+				fmt.Fprintf(c.out, "Warning: skipping checkpoint %s: %v\n", entry.Name(), err)
+			}
+			continue
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+	return checkpoints, nil
+}
+
+// Find returns the checkpoint with the given name
+func (c *Checkpointer) Find(name string) (*Checkpoint, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+	checkpoint, err := c.load(name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("checkpoint not found: %s (see 'configsync checkpoint list')", name)
+	}
+	return checkpoint, err
+}
+
+// Delete removes a checkpoint
+func (c *Checkpointer) Delete(configManager *config.Manager, name string) error {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := c.Find(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(c.dir, name))
+}
+
+// Restore returns the configuration, the store, and every live location recorded in a checkpoint
+// to their state at the time. Everything is copied beside the path it replaces first, so nothing
+// changes if the checkpoint cannot be read. The copies are then renamed into place, with the
+// replaced paths moved aside; if any step fails, every path swapped so far is put back. Managed
+// locations the checkpoint does not record are left alone, except that symlinks of theirs into
+// the store are replaced with the files they point to.
+func (c *Checkpointer) Restore(configManager *config.Manager, name string) (*CheckpointResult, error) {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	checkpoint, err := c.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	checkpointPath := filepath.Join(c.dir, checkpoint.Name)
+
+	data, err := os.ReadFile(filepath.Join(checkpointPath, snapshotConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved configuration: %w", err)
+	}
+	savedCfg, err := config.ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse saved configuration: %w", err)
+	}
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	storePath := filepath.Clean(cfg.StorePath)
+	if storePath != filepath.Clean(checkpoint.StorePath) {
+		return nil, fmt.Errorf("the store has moved from %s since the checkpoint was created", checkpoint.StorePath)
+	}
+
+	result := &CheckpointResult{Checkpoint: checkpoint}
+	swap := &pathSwap{}
+	defer swap.clearStaged()
+
+	// Stage every copy before touching anything
+	savedStore := filepath.Join(checkpointPath, snapshotStoreDir)
+	if exists(savedStore) {
+		if err := swap.stage(savedStore, storePath); err != nil {
+			return nil, fmt.Errorf("failed to stage the store: %w", err)
+		}
+	}
+	for i, path := range checkpoint.Paths {
+		if path.State != sourceFile {
+			continue
+		}
+		savedSource := filepath.Join(checkpointPath, undoSourcesDir, fmt.Sprint(i))
+		if info, err := os.Lstat(path.Source); err == nil && info.Mode()&os.ModeSymlink == 0 && sameTree(savedSource, path.Source) {
+			continue
+		}
+		if err := swap.stage(savedSource, path.Source); err != nil {
+			return nil, fmt.Errorf("failed to stage %s: %w", path.Source, err)
+		}
+	}
+
+	// Symlinks into the store of paths the checkpoint does not record would dangle once the store
+	// is swapped, so they get a copy of what they point to
+	recorded := make(map[string]bool)
+	for _, path := range checkpoint.Paths {
+		recorded[path.Source] = true
+	}
+	var orphans []string
+	for _, appName := range appNames(cfg) {
+		for _, path := range cfg.Apps[appName].Paths {
+			if path.IsExported() {
+				continue
+			}
+			target := filepath.Join(storePath, path.Destination)
+			for _, location := range path.LiveLocations() {
+				source := expandHomePath(c.homeDir, location)
+				if recorded[source] || swap.staged[source] || !pointsTo(source, target) || !exists(target) {
+					continue
+				}
+				if err := swap.stage(target, source); err != nil {
+					return nil, fmt.Errorf("failed to stage %s: %w", source, err)
+				}
+				orphans = append(orphans, source)
+			}
+		}
+	}
+
+	// Swap the store, then each live location, in quick succession
+	err = swap.replace(storePath, exists(savedStore), "")
+	for _, source := range orphans {
+		if err != nil {
+			break
+		}
+		if err = swap.replace(source, true, ""); err == nil {
+			result.Replaced = append(result.Replaced, source)
+		}
+	}
+	for _, path := range checkpoint.Paths {
+		if err != nil {
+			break
+		}
+		changed := c.differs(path, swap)
+		if !changed {
+			result.Unchanged++
+			continue
+		}
+		if c.verbose {
+			fmt.Fprintf(c.out, "  Restoring: %s\n", path.Source)
+		}
+		switch path.State {
+		case sourceSymlink:
+			err = swap.replace(path.Source, false, path.LinkTarget)
+		case sourceFile:
+			err = swap.replace(path.Source, true, "")
+		default:
+			err = swap.replace(path.Source, false, "")
+		}
+		if err == nil {
+			result.Replaced = append(result.Replaced, path.Source)
+		}
+	}
+	if err == nil {
+		savedCfg.StorePath = cfg.StorePath
+		if err = configManager.Save(savedCfg); err != nil {
+			err = fmt.Errorf("failed to save restored configuration: %w", err)
+		}
+	}
+	if err != nil {
+		if rollbackErr := swap.rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("%w; rolling back also failed: %v (replaced files are kept beside them with a %s suffix)", err, rollbackErr, checkpointAside)
+		}
+		return nil, fmt.Errorf("restore rolled back: %w", err)
+	}
+
+	if err := swap.commit(); err != nil {
+		fmt.Fprintf(c.out, "Warning: failed to remove replaced files: %v\n", err)
+	}
+	return result, nil
+}
+
+// Helper methods
+
+// capture records the state of a live location in a checkpoint, copying it when it is a file or
+// directory
+func (c *Checkpointer) capture(checkpointPath string, index int, appName, source string) (CheckpointPath, error) {
+	state := CheckpointPath{App: appName, Source: source, State: sourceMissing}
+
+	info, err := os.Lstat(source)
+	switch {
+	case os.IsNotExist(err):
+		return state, nil
+	case err != nil:
+		return state, err
+	case info.Mode()&os.ModeSymlink != 0:
+		state.State = sourceSymlink
+		state.LinkTarget, err = os.Readlink(source)
+		return state, err
+	}
+
+	state.State = sourceFile
+	return state, fsops.CopyTree(context.Background(), fsys.OS, source, filepath.Join(checkpointPath, undoSourcesDir, fmt.Sprint(index)), nil)
+}
+
+// differs reports whether a live location no longer matches its state in a checkpoint
+func (c *Checkpointer) differs(path CheckpointPath, swap *pathSwap) bool {
+	switch path.State {
+	case sourceSymlink:
+		link, err := os.Readlink(path.Source)
+		return err != nil || link != path.LinkTarget
+	case sourceFile:
+		return swap.staged[path.Source]
+	default:
+		return exists(path.Source)
+	}
+}
+
+// load reads the metadata of a checkpoint
+func (c *Checkpointer) load(name string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, name, checkpointInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := yaml.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	checkpoint.Name = name
+	return &checkpoint, nil
+}
+
+// appNames returns the names of the configured applications, sorted
+func appNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Apps))
+	for appName := range cfg.Apps {
+		names = append(names, appName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// treeStats returns the number and total size of the regular files below a directory
+func treeStats(root string) (int, int64) {
+	var files int
+	var size int64
+	_ = filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// pathSwap replaces a set of paths with staged copies or symlinks. Replaced paths are moved aside
+// rather than deleted until the swap is committed, so it can be rolled back.
+type pathSwap struct {
+	staged map[string]bool // Paths with a copy staged beside them
+	steps  []swapStep
+}
+
+// swapStep is one replaced path
+type swapStep struct {
+	path  string
+	aside string // Where the replaced path was moved, empty if there was nothing to replace
+}
+
+// stage copies src beside path, to be renamed into place by replace
+func (s *pathSwap) stage(src, path string) error {
+	staged := path + checkpointStaged
+	if err := os.RemoveAll(staged); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if s.staged == nil {
+		s.staged = make(map[string]bool)
+	}
+	s.staged[path] = true
+	return fsops.CopyTree(context.Background(), fsys.OS, src, staged, nil)
+}
+
+// replace moves whatever is at path aside and puts the staged copy of path there when useStaged
+// is set, or else a symlink to linkTarget when it is not empty, or else nothing
+func (s *pathSwap) replace(path string, useStaged bool, linkTarget string) error {
+	step := swapStep{path: path}
+	if exists(path) {
+		step.aside = path + checkpointAside
+		if err := os.RemoveAll(step.aside); err != nil {
+			return err
+		}
+		if err := os.Rename(path, step.aside); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", path, err)
+		}
+	}
+	s.steps = append(s.steps, step)
+
+	switch {
+	case useStaged:
+		if err := os.Rename(path+checkpointStaged, path); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+		delete(s.staged, path)
+	case linkTarget != "":
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(linkTarget, path); err != nil {
+			return fmt.Errorf("failed to link %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// rollback puts every replaced path back, newest first
+func (s *pathSwap) rollback() error {
+	var failed []string
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if err := os.RemoveAll(step.path); err != nil {
+			failed = append(failed, step.path)
+			continue
+		}
+		if step.aside != "" {
+			if err := os.Rename(step.aside, step.path); err != nil {
+				failed = append(failed, step.path)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not put back %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// commit removes the replaced paths
+func (s *pathSwap) commit() error {
+	var failed []string
+	for _, step := range s.steps {
+		if step.aside == "" {
+			continue
+		}
+		if err := os.RemoveAll(step.aside); err != nil {
+			failed = append(failed, step.aside)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not remove %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// clearStaged removes the staged copies that were not swapped in
+func (s *pathSwap) clearStaged() {
+	for path := range s.staged {
+		_ = os.RemoveAll(path + checkpointStaged)
+	}
+}
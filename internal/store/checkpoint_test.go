@@ -0,0 +1,189 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func newTestCheckpointer(homeDir string, configManager *config.Manager) *Checkpointer {
+	checkpointer := NewCheckpointer(homeDir, configManager.GetConfigDir(), false)
+	checkpointer.out = io.Discard
+	return checkpointer
+}
+
+// setupCheckpointApps adds an unsynced app with a live directory beside the synced app
+func setupCheckpointApps(t *testing.T) (string, *config.Manager, string, string) {
+	t.Helper()
+	homeDir, configManager, source := setupSnapshotApp(t)
+
+	liveDir := filepath.Join(homeDir, ".editor")
+	if err := os.MkdirAll(liveDir, 0755); err != nil {
+		t.Fatalf("Failed to create live directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(liveDir, "init.lua"), []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write live file: %v", err)
+	}
+
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Apps["editor"] = &config.AppConfig{
+		Name:    "editor",
+		Enabled: true,
+		Paths:   []config.Path{{Source: "~/.editor", Destination: ".editor", Type: config.PathTypeDirectory}},
+	}
+	if err := configManager.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	return homeDir, configManager, source, liveDir
+}
+
+func TestCheckpointCreateAndRestore(t *testing.T) {
+	homeDir, configManager, source, liveDir := setupCheckpointApps(t)
+	checkpointer := newTestCheckpointer(homeDir, configManager)
+
+	checkpoint, err := checkpointer.Create(configManager, "pre-deploy")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(checkpoint.Apps) != 2 || len(checkpoint.Paths) != 2 || checkpoint.Files != 3 {
+		t.Errorf("Expected two apps, two paths, and three files, got %+v", checkpoint)
+	}
+	if _, err := checkpointer.Create(configManager, "pre-deploy"); err == nil {
+		t.Error("Expected an error for an existing checkpoint name")
+	}
+	original, _ := os.ReadFile(source)
+
+	// Break things: edit the store file, unlink the synced path, replace the live directory, and
+	// drop an app
+	if err := os.WriteFile(source, []byte("broken"), 0644); err != nil {
+		t.Fatalf("Failed to change file: %v", err)
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.RemoveAll(liveDir); err != nil {
+		t.Fatalf("Failed to remove live directory: %v", err)
+	}
+	if err := os.WriteFile(liveDir, []byte("not a directory"), 0644); err != nil {
+		t.Fatalf("Failed to replace live directory: %v", err)
+	}
+	if err := configManager.RemoveApp("editor"); err != nil {
+		t.Fatalf("RemoveApp failed: %v", err)
+	}
+
+	result, err := checkpointer.Restore(configManager, "pre-deploy")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(result.Replaced) != 2 {
+		t.Errorf("Expected both live locations to be replaced, got %v", result.Replaced)
+	}
+
+	if data, err := os.ReadFile(source); err != nil || string(data) != string(original) {
+		t.Errorf("Expected the symlink and store file to be restored, got %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(liveDir, "init.lua")); err != nil || string(data) != "original" {
+		t.Errorf("Expected the live directory to be restored, got %q, %v", data, err)
+	}
+	if _, err := configManager.GetApp("editor"); err != nil {
+		t.Errorf("Expected the app configuration to be restored: %v", err)
+	}
+	assertNoLeftovers(t, homeDir)
+
+	// Restoring again changes nothing
+	again, err := checkpointer.Restore(configManager, "pre-deploy")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(again.Replaced) != 0 || again.Unchanged != 2 {
+		t.Errorf("Expected nothing to change, got %+v", again)
+	}
+}
+
+func TestPathSwapRollback(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	saved := filepath.Join(dir, "saved")
+	for path, content := range map[string]string{first: "live 1", second: "live 2", saved: "saved"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	swap := &pathSwap{}
+	defer swap.clearStaged()
+	for _, path := range []string{first, second} {
+		if err := swap.stage(saved, path); err != nil {
+			t.Fatalf("stage failed: %v", err)
+		}
+	}
+	if err := swap.replace(first, true, ""); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	// Losing the staged copy makes the second replacement fail
+	if err := os.Remove(second + checkpointStaged); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := swap.replace(second, true, ""); err == nil {
+		t.Fatal("Expected the replacement without a staged copy to fail")
+	}
+
+	if err := swap.rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	for path, content := range map[string]string{first: "live 1", second: "live 2"} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != content {
+			t.Errorf("Expected %s to be put back, got %q, %v", path, data, err)
+		}
+	}
+	swap.clearStaged()
+	assertNoLeftovers(t, dir)
+}
+
+func TestCheckpointNames(t *testing.T) {
+	homeDir, configManager, _ := setupSnapshotApp(t)
+	checkpointer := newTestCheckpointer(homeDir, configManager)
+
+	for _, name := range []string{"", "../escape", ".hidden"} {
+		if _, err := checkpointer.Create(configManager, name); err == nil {
+			t.Errorf("Expected an error for checkpoint name %q", name)
+		}
+	}
+
+	if _, err := checkpointer.Create(configManager, "first"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := checkpointer.Create(configManager, "second"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	checkpoints, err := checkpointer.List()
+	if err != nil || len(checkpoints) != 2 || checkpoints[0].Name != "second" {
+		t.Errorf("Expected both checkpoints newest first, got %+v (%v)", checkpoints, err)
+	}
+
+	if err := checkpointer.Delete(configManager, "first"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := checkpointer.Find("first"); err == nil {
+		t.Error("Expected the deleted checkpoint to be gone")
+	}
+}
+
+// assertNoLeftovers fails if a restore left staged or replaced copies behind
+func assertNoLeftovers(t *testing.T, root string) {
+	t.Helper()
+	_ = filepath.Walk(root, func(path string, _ os.FileInfo, err error) error {
+		if err == nil && (strings.HasSuffix(path, checkpointStaged) || strings.HasSuffix(path, checkpointAside)) {
+			t.Errorf("Expected no leftovers, found %s", path)
+		}
+		return nil
+	})
+}
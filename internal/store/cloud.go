@@ -0,0 +1,315 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cloud services a store can be kept in
+const (
+	ProviderICloud    = "iCloud Drive"
+	ProviderDropbox   = "Dropbox"
+	ProviderSyncthing = "Syncthing"
+	ProviderCloud     = "cloud storage" // Other services using the macOS File Provider (~/Library/CloudStorage)
+)
+
+// Strategies for resolving conflicted copies created by a cloud service
+const (
+	ConflictAsk          = "ask"           // Report conflicts and leave them for the user
+	ConflictKeepOriginal = "keep-original" // Keep the file with the original name and archive the copy
+	ConflictKeepCopy     = "keep-copy"     // Replace the original with the conflicted copy
+	ConflictKeepNewest   = "keep-newest"   // Keep whichever of the two was modified last
+)
+
+// DefaultMaterializeTimeout is how long to wait for a cloud placeholder to be downloaded
+const DefaultMaterializeTimeout = 2 * time.Minute
+
+// pollInterval is how often to check whether a placeholder has been downloaded
+var pollInterval = 500 * time.Millisecond
+
+// CloudConflict is a conflicted copy of a store file created by a cloud service
+type CloudConflict struct {
+	ModTime  time.Time `json:"mod_time" yaml:"mod_time"`
+	Path     string    `json:"path" yaml:"path"`         // The conflicted copy
+	Original string    `json:"original" yaml:"original"` // The file it conflicts with
+	Provider string    `json:"provider" yaml:"provider"`
+}
+
+// conflictPattern recognizes the names one cloud service gives conflicted copies
+type conflictPattern struct {
+	pattern  *regexp.Regexp
+	provider string
+	original string // Replacement template producing the original name
+}
+
+var conflictPatterns = []conflictPattern{
+	// "settings (Alice's conflicted copy 2024-01-02).json"
+	{regexp.MustCompile(`^(.+) \([^()]*conflicted copy[^()]*\)(\.[^.]+)?$`), ProviderDropbox, "$1$2"},
+	// "settings.sync-conflict-20240102-030405-ABCDEFG.json"
+	{regexp.MustCompile(`^(.+?)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]+)?(\.[^.]+)?$`), ProviderSyncthing, "$1$2"},
+}
+
+// iCloudConflictPattern matches the numbered copies iCloud Drive creates ("settings 2.json").
+// Such names are common, so they only count as conflicts in an iCloud store next to the original.
+var iCloudConflictPattern = regexp.MustCompile(`^(.+) \d+(\.[^.]+)?$`)
+
+// iCloudPlaceholderSuffix is appended to the hidden placeholder iCloud leaves for an evicted file
+const iCloudPlaceholderSuffix = ".icloud"
+
+// CloudProvider returns the cloud service that syncs a directory, or "" for a local directory
+func CloudProvider(dir string) string {
+	path := filepath.ToSlash(filepath.Clean(dir))
+	switch {
+	case strings.Contains(path, "/Library/Mobile Documents/"), strings.HasSuffix(path, "/Library/Mobile Documents"):
+		return ProviderICloud
+	case strings.Contains(path, "/Library/CloudStorage/Dropbox"), hasPathElement(path, "Dropbox"):
+		return ProviderDropbox
+	case strings.Contains(path, "/Library/CloudStorage/"):
+		return ProviderCloud
+	}
+
+	// Syncthing marks the folders it shares
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".stfolder")); err == nil {
+			return ProviderSyncthing
+		}
+		if filepath.Dir(current) == current {
+			return ""
+		}
+	}
+}
+
+// FindConflicts lists the conflicted copies below a store path, which may be a file or a directory.
+// A file's conflicts are its siblings named as conflicted copies of it.
+func FindConflicts(storeDir, path string) ([]CloudConflict, error) {
+	provider := CloudProvider(storeDir)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []CloudConflict
+	if !info.IsDir() {
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if conflict, ok := conflictFor(filepath.Join(filepath.Dir(path), entry.Name()), provider); ok && conflict.Original == path {
+				conflicts = append(conflicts, conflict)
+			}
+		}
+		return conflicts, nil
+	}
+
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if conflict, ok := conflictFor(file, provider); ok {
+			conflicts = append(conflicts, conflict)
+		}
+		return nil
+	})
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return conflicts, err
+}
+
+// ValidateConflictStrategy checks that a strategy can resolve conflicts
+func ValidateConflictStrategy(strategy string) error {
+	switch strategy {
+	case ConflictKeepOriginal, ConflictKeepCopy, ConflictKeepNewest:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict strategy %q (use %s, %s, or %s)", strategy, ConflictKeepOriginal, ConflictKeepCopy, ConflictKeepNewest)
+}
+
+// ResolveConflict settles a conflicted copy with a strategy and returns the path whose content
+// was kept. The file that loses is moved into archiveDir rather than deleted, so nothing is lost
+// if the wrong side was chosen.
+func ResolveConflict(conflict CloudConflict, strategy, archiveDir string) (string, error) {
+	if err := ValidateConflictStrategy(strategy); err != nil {
+		return "", err
+	}
+
+	keepCopy := strategy == ConflictKeepCopy
+	if strategy == ConflictKeepNewest {
+		original, err := os.Stat(conflict.Original)
+		if err != nil {
+			return "", err
+		}
+		keepCopy = conflict.ModTime.After(original.ModTime())
+	}
+
+	archiveDir = filepath.Join(archiveDir, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create conflict archive: %w", err)
+	}
+
+	if !keepCopy {
+		if err := os.Rename(conflict.Path, archivePath(archiveDir, conflict.Path)); err != nil {
+			return "", fmt.Errorf("failed to archive conflicted copy: %w", err)
+		}
+		return conflict.Original, nil
+	}
+
+	if err := os.Rename(conflict.Original, archivePath(archiveDir, conflict.Original)); err != nil {
+		return "", fmt.Errorf("failed to archive original: %w", err)
+	}
+	if err := os.Rename(conflict.Path, conflict.Original); err != nil {
+		return "", fmt.Errorf("failed to replace original with conflicted copy: %w", err)
+	}
+	return conflict.Path, nil
+}
+
+// IsEvicted reports whether a missing store path only exists as an iCloud placeholder
+func IsEvicted(path string) bool {
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		return false
+	}
+	_, err := os.Stat(placeholderFor(path))
+	return err == nil
+}
+
+// Materialize makes sure a store path evicted by a cloud service is available locally.
+// iCloud placeholders are downloaded, waiting up to timeout for the real file to appear,
+// and dataless files are read through so their content is fetched.
+func Materialize(path string, timeout time.Duration) error {
+	placeholder := placeholderFor(path)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(placeholder); err != nil {
+			return nil
+		}
+		if err := waitForDownload(path, placeholder, timeout); err != nil {
+			return err
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return readThrough(path, info)
+	}
+
+	return filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if !info.IsDir() && strings.HasPrefix(name, ".") && strings.HasSuffix(name, iCloudPlaceholderSuffix) {
+			evicted := filepath.Join(filepath.Dir(file), strings.TrimSuffix(strings.TrimPrefix(name, "."), iCloudPlaceholderSuffix))
+			return waitForDownload(evicted, file, timeout)
+		}
+		if info.Mode().IsRegular() {
+			return readThrough(file, info)
+		}
+		return nil
+	})
+}
+
+// Helper functions
+
+// conflictFor reports whether a file is a conflicted copy of an existing file
+func conflictFor(path, provider string) (CloudConflict, bool) {
+	name := filepath.Base(path)
+	dir := filepath.Dir(path)
+
+	candidates := conflictPatterns[:len(conflictPatterns):len(conflictPatterns)]
+	if provider == ProviderICloud {
+		candidates = append(candidates, conflictPattern{iCloudConflictPattern, ProviderICloud, "$1$2"})
+	}
+
+	for _, candidate := range candidates {
+		if !candidate.pattern.MatchString(name) {
+			continue
+		}
+		original := filepath.Join(dir, candidate.pattern.ReplaceAllString(name, candidate.original))
+		if _, err := os.Stat(original); err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		return CloudConflict{Path: path, Original: original, Provider: candidate.provider, ModTime: info.ModTime()}, true
+	}
+
+	return CloudConflict{}, false
+}
+
+// archivePath returns an unused name for a file moved into the conflict archive
+func archivePath(archiveDir, path string) string {
+	name := filepath.Base(path)
+	archived := filepath.Join(archiveDir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(archived); os.IsNotExist(err) {
+			return archived
+		}
+		archived = filepath.Join(archiveDir, fmt.Sprintf("%s.%d", name, i))
+	}
+}
+
+// placeholderFor returns the name of the iCloud placeholder of a file
+func placeholderFor(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+iCloudPlaceholderSuffix)
+}
+
+// waitForDownload asks for an evicted file to be downloaded and waits for it to appear
+func waitForDownload(path, placeholder string, timeout time.Duration) error {
+	if err := requestDownload(path); err != nil {
+		return fmt.Errorf("failed to download %s from the cloud: %w", path, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			if _, err := os.Stat(placeholder); os.IsNotExist(err) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s to download from the cloud", timeout, path)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// readThrough reads a dataless file so the cloud service fetches its content
+func readThrough(path string, info os.FileInfo) error {
+	if !isDataless(info) {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to download %s from the cloud: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		return fmt.Errorf("failed to download %s from the cloud: %w", path, err)
+	}
+	return nil
+}
+
+// hasPathElement reports whether a slash-separated path has an element with the given name
+func hasPathElement(path, name string) bool {
+	for _, element := range strings.Split(path, "/") {
+		if element == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeStoreFile writes a file below the store, creating its directory
+func writeStoreFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestCloudProvider(t *testing.T) {
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{"/Users/test/Library/Mobile Documents/com~apple~CloudDocs/configsync", ProviderICloud},
+		{"/Users/test/Dropbox/configsync", ProviderDropbox},
+		{"/Users/test/Library/CloudStorage/Dropbox/configsync", ProviderDropbox},
+		{"/Users/test/Library/CloudStorage/GoogleDrive-test@example.com/configsync", ProviderCloud},
+		{"/Users/test/.configsync/store", ""},
+	}
+
+	for _, tt := range tests {
+		if got := CloudProvider(tt.dir); got != tt.expected {
+			t.Errorf("CloudProvider(%q) = %q, want %q", tt.dir, got, tt.expected)
+		}
+	}
+
+	syncthingDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(syncthingDir, ".stfolder"), 0755); err != nil {
+		t.Fatalf("Failed to create Syncthing marker: %v", err)
+	}
+	if got := CloudProvider(filepath.Join(syncthingDir, "store")); got != ProviderSyncthing {
+		t.Errorf("Expected Syncthing folder to be detected, got %q", got)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	storeDir := filepath.Join(t.TempDir(), "Library", "Mobile Documents", "com~apple~CloudDocs", "store")
+	appDir := filepath.Join(storeDir, ".config", "app")
+
+	writeStoreFile(t, filepath.Join(appDir, "settings.json"), "original")
+	writeStoreFile(t, filepath.Join(appDir, "settings (Alice's conflicted copy 2024-01-02).json"), "dropbox")
+	writeStoreFile(t, filepath.Join(appDir, "settings.sync-conflict-20240102-030405-ABCDEFG.json"), "syncthing")
+	writeStoreFile(t, filepath.Join(appDir, "settings 2.json"), "icloud")
+	writeStoreFile(t, filepath.Join(appDir, "notes 2.txt"), "no original")
+	writeStoreFile(t, filepath.Join(storeDir, ".gitconfig"), "original")
+
+	conflicts, err := FindConflicts(storeDir, appDir)
+	if err != nil {
+		t.Fatalf("FindConflicts failed: %v", err)
+	}
+	if len(conflicts) != 3 {
+		t.Fatalf("Expected 3 conflicts, got %+v", conflicts)
+	}
+	providers := map[string]bool{}
+	for _, conflict := range conflicts {
+		if conflict.Original != filepath.Join(appDir, "settings.json") {
+			t.Errorf("Unexpected original %s for %s", conflict.Original, conflict.Path)
+		}
+		providers[conflict.Provider] = true
+	}
+	for _, provider := range []string{ProviderDropbox, ProviderSyncthing, ProviderICloud} {
+		if !providers[provider] {
+			t.Errorf("Expected a %s conflict", provider)
+		}
+	}
+
+	// A file's conflicts are its siblings
+	conflicts, err = FindConflicts(storeDir, filepath.Join(appDir, "settings.json"))
+	if err != nil || len(conflicts) != 3 {
+		t.Errorf("Expected 3 conflicts for the file, got %+v (%v)", conflicts, err)
+	}
+	conflicts, err = FindConflicts(storeDir, filepath.Join(storeDir, ".gitconfig"))
+	if err != nil || len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for .gitconfig, got %+v (%v)", conflicts, err)
+	}
+
+	// Numbered names are only iCloud conflicts in an iCloud store
+	localStore := t.TempDir()
+	writeStoreFile(t, filepath.Join(localStore, "settings.json"), "original")
+	writeStoreFile(t, filepath.Join(localStore, "settings 2.json"), "copy")
+	conflicts, err = FindConflicts(localStore, localStore)
+	if err != nil || len(conflicts) != 0 {
+		t.Errorf("Expected numbered file outside iCloud to be ignored, got %+v (%v)", conflicts, err)
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		strategy string
+		expected string
+	}{
+		{ConflictKeepOriginal, "original"},
+		{ConflictKeepCopy, "copy"},
+		{ConflictKeepNewest, "copy"},
+	}
+
+	for _, tt := range tests {
+		storeDir := filepath.Join(t.TempDir(), "Dropbox")
+		archiveDir := t.TempDir()
+		original := filepath.Join(storeDir, "settings.json")
+		copyPath := filepath.Join(storeDir, "settings (conflicted copy).json")
+		writeStoreFile(t, original, "original")
+		writeStoreFile(t, copyPath, "copy")
+		past := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(original, past, past); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+
+		conflicts, err := FindConflicts(storeDir, storeDir)
+		if err != nil || len(conflicts) != 1 {
+			t.Fatalf("%s: expected one conflict, got %+v (%v)", tt.strategy, conflicts, err)
+		}
+		if _, err := ResolveConflict(conflicts[0], tt.strategy, archiveDir); err != nil {
+			t.Fatalf("%s: ResolveConflict failed: %v", tt.strategy, err)
+		}
+
+		data, err := os.ReadFile(original)
+		if err != nil || string(data) != tt.expected {
+			t.Errorf("%s: expected %q to be kept, got %q (%v)", tt.strategy, tt.expected, data, err)
+		}
+		if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+			t.Errorf("%s: expected conflicted copy to be gone", tt.strategy)
+		}
+		archived, _ := filepath.Glob(filepath.Join(archiveDir, "*", "*"))
+		if len(archived) != 1 {
+			t.Errorf("%s: expected the losing file to be archived, got %v", tt.strategy, archived)
+		}
+	}
+
+	if _, err := ResolveConflict(CloudConflict{}, ConflictAsk, t.TempDir()); err == nil {
+		t.Error("Expected error for a strategy that does not resolve conflicts")
+	}
+}
+
+func TestMaterializeWaitsForPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	placeholder := filepath.Join(dir, ".settings.json.icloud")
+	writeStoreFile(t, placeholder, "")
+
+	originalRequest, originalInterval := requestDownload, pollInterval
+	t.Cleanup(func() { requestDownload, pollInterval = originalRequest, originalInterval })
+	pollInterval = time.Millisecond
+
+	requested := ""
+	requestDownload = func(file string) error {
+		requested = file
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = os.WriteFile(path, []byte("downloaded"), 0644)
+			_ = os.Remove(placeholder)
+		}()
+		return nil
+	}
+
+	if !IsEvicted(path) {
+		t.Error("Expected placeholder to be reported as evicted")
+	}
+	if err := Materialize(path, time.Second); err != nil {
+		t.Fatalf("Materialize failed: %v", err)
+	}
+	if requested != path {
+		t.Errorf("Expected download of %s to be requested, got %q", path, requested)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "downloaded" {
+		t.Errorf("Expected downloaded file, got %q (%v)", data, err)
+	}
+
+	// A placeholder that never downloads times out
+	stuck := filepath.Join(dir, "stuck.json")
+	writeStoreFile(t, filepath.Join(dir, ".stuck.json.icloud"), "")
+	requestDownload = func(string) error { return nil }
+	if err := Materialize(stuck, 20*time.Millisecond); err == nil {
+		t.Error("Expected timeout waiting for placeholder")
+	}
+}
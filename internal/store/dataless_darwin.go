@@ -0,0 +1,26 @@
+//go:build darwin
+
+package store
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sfDataless is the BSD file flag set on files whose content lives only in the cloud
+const sfDataless = 0x40000000
+
+// isDataless reports whether a file's content has been evicted by a cloud service
+func isDataless(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Flags&sfDataless != 0
+}
+
+// requestDownload asks iCloud Drive to download an evicted file
+var requestDownload = func(path string) error {
+	if _, err := exec.LookPath("brctl"); err != nil {
+		return nil
+	}
+	return exec.Command("brctl", "download", path).Run()
+}
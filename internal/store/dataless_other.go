@@ -0,0 +1,17 @@
+//go:build !darwin
+
+package store
+
+import "os"
+
+// isDataless reports whether a file's content has been evicted by a cloud service.
+// Only macOS marks evicted files, so elsewhere every file is local.
+func isDataless(info os.FileInfo) bool {
+	return false
+}
+
+// requestDownload asks the cloud service to download an evicted file. Other platforms
+// download on access, so there is nothing to ask for.
+var requestDownload = func(path string) error {
+	return nil
+}
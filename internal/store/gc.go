@@ -0,0 +1,185 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/ignore"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// storeMetadata are files configsync keeps in the store itself, which no application references
+var storeMetadata = map[string]bool{
+	manifest.FileName:            true,
+	manifest.ChecksumsFileName:   true,
+	manifest.PermissionsFileName: true,
+	ignore.FileName:              true,
+	manifest.ObjectsDirName:      true,
+	".DS_Store":                  true,
+}
+
+// storeSkeleton are the directories 'configsync init' creates in the store, which are kept even
+// when no application stores anything in them
+var storeSkeleton = map[string]bool{
+	"Library":                     true,
+	"Library/Preferences":         true,
+	"Library/Application Support": true,
+	".config":                     true,
+}
+
+// Orphan is a file or directory in the store that no configured path references, such as the
+// store copy of a removed application
+type Orphan struct {
+	Path string `json:"path" yaml:"path"` // Relative to the store
+	Size int64  `json:"size" yaml:"size"`
+	Dir  bool   `json:"dir,omitempty" yaml:"dir,omitempty"`
+}
+
+// FindOrphans lists the files and directories in the store that no path of any configured
+// application references, whether or not the application is enabled. An unreferenced directory is
+// listed once rather than file by file. The store's metadata files and the directories created by
+// 'configsync init' are not orphans, and neither are cloud conflicted copies and iCloud placeholders
+// of referenced files, so they can still be resolved.
+func FindOrphans(cfg *config.Config) ([]Orphan, error) {
+	referenced := make(map[string]bool)
+	ancestors := make(map[string]bool)
+	for _, appConfig := range cfg.Apps {
+		for _, path := range appConfig.Paths {
+			destination := filepath.Clean(path.Destination)
+			referenced[destination] = true
+			for dir := filepath.Dir(destination); dir != "."; dir = filepath.Dir(dir) {
+				ancestors[dir] = true
+			}
+		}
+	}
+
+	provider := CloudProvider(cfg.StorePath)
+	var orphans []Orphan
+	err := filepath.Walk(cfg.StorePath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.StorePath, file)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		switch {
+		case referenced[rel]:
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case ancestors[rel] || storeSkeleton[filepath.ToSlash(rel)]:
+			return nil
+		case storeMetadata[info.Name()]:
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case referencedPlaceholder(rel, referenced):
+			return nil
+		}
+		if conflict, ok := conflictFor(file, provider); ok {
+			if original, err := filepath.Rel(cfg.StorePath, conflict.Original); err == nil && referenced[original] {
+				return nil
+			}
+		}
+
+		size, err := fsutil.Size(file)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, Orphan{Path: rel, Size: size, Dir: info.IsDir()})
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan store: %w", err)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	return orphans, nil
+}
+
+// RemoveOrphans deletes orphans from the store while holding the store lock. Each is checked
+// again against the current configuration first, so nothing an application has claimed since the
+// orphans were found is deleted. The orphans actually removed are returned.
+func RemoveOrphans(configManager *config.Manager, orphans []Orphan) ([]Orphan, error) {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	current, err := FindOrphans(cfg)
+	if err != nil {
+		return nil, err
+	}
+	stillOrphaned := make(map[string]bool, len(current))
+	for _, orphan := range current {
+		stillOrphaned[orphan.Path] = true
+	}
+
+	var removed []Orphan
+	for _, orphan := range orphans {
+		if !stillOrphaned[orphan.Path] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cfg.StorePath, orphan.Path)); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", orphan.Path, err)
+		}
+		removed = append(removed, orphan)
+	}
+	return removed, nil
+}
+
+// PurgeDestinations deletes the store copies of an application's paths that no other configured
+// application references, and returns the destinations deleted
+func PurgeDestinations(cfg *config.Config, appName string, appConfig *config.AppConfig) ([]string, error) {
+	inUse := make(map[string]bool)
+	for otherName, other := range cfg.Apps {
+		if otherName == appName {
+			continue
+		}
+		for _, path := range other.Paths {
+			inUse[filepath.Clean(path.Destination)] = true
+		}
+	}
+
+	var purged []string
+	for _, path := range appConfig.Paths {
+		destination := filepath.Clean(path.Destination)
+		storeCopy := filepath.Join(cfg.StorePath, destination)
+		if inUse[destination] || !exists(storeCopy) {
+			continue
+		}
+		if err := os.RemoveAll(storeCopy); err != nil {
+			return purged, fmt.Errorf("failed to remove %s from the store: %w", path.Destination, err)
+		}
+		purged = append(purged, path.Destination)
+	}
+	return purged, nil
+}
+
+// referencedPlaceholder reports whether a store path is the iCloud placeholder of a referenced file
+func referencedPlaceholder(rel string, referenced map[string]bool) bool {
+	name := filepath.Base(rel)
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, iCloudPlaceholderSuffix) {
+		return false
+	}
+	evicted := strings.TrimSuffix(strings.TrimPrefix(name, "."), iCloudPlaceholderSuffix)
+	return referenced[filepath.Join(filepath.Dir(rel), evicted)]
+}
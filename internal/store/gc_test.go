@@ -0,0 +1,91 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+func TestFindAndRemoveOrphans(t *testing.T) {
+	_, configManager, _ := setupSyncedApp(t)
+	cfg, _ := configManager.Load()
+
+	files := map[string]string{
+		"Library/Preferences/com.old.app.plist": "old",
+		".config/oldapp/settings.json":          "{}",
+		".config/oldapp/themes/dark.json":       "{}",
+		manifest.ChecksumsFileName:              "",
+	}
+	for rel, content := range files {
+		path := filepath.Join(cfg.StorePath, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+
+	orphans, err := FindOrphans(cfg)
+	if err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	if len(orphans) != 2 || orphans[0].Path != filepath.Join(".config", "oldapp") || !orphans[0].Dir || orphans[0].Size != 4 ||
+		orphans[1].Path != filepath.Join("Library", "Preferences", "com.old.app.plist") {
+		t.Fatalf("Unexpected orphans: %+v", orphans)
+	}
+
+	removed, err := RemoveOrphans(configManager, orphans)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("Expected both orphans to be removed, got %+v %v", removed, err)
+	}
+	for _, rel := range []string{"Library/Preferences/com.test.app.plist", "Library/Application Support", manifest.ChecksumsFileName} {
+		if !exists(filepath.Join(cfg.StorePath, rel)) {
+			t.Errorf("Expected %s to be kept", rel)
+		}
+	}
+	if exists(filepath.Join(cfg.StorePath, ".config", "oldapp")) {
+		t.Error("Expected the orphaned directory to be removed")
+	}
+}
+
+func TestRemoveOrphansSkipsReclaimedPaths(t *testing.T) {
+	_, configManager, _ := setupSyncedApp(t)
+	cfg, _ := configManager.Load()
+
+	// A path that was orphaned when found, but has since been claimed by an application
+	stale := []Orphan{{Path: filepath.Join("Library", "Preferences", "com.test.app.plist")}}
+	removed, err := RemoveOrphans(configManager, stale)
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("Expected nothing to be removed, got %+v %v", removed, err)
+	}
+	if !exists(filepath.Join(cfg.StorePath, stale[0].Path)) {
+		t.Error("Expected the referenced store file to be kept")
+	}
+}
+
+func TestPurgeDestinations(t *testing.T) {
+	_, configManager, _ := setupSyncedApp(t)
+	cfg, _ := configManager.Load()
+	appConfig := cfg.Apps["testapp"]
+
+	purged, err := PurgeDestinations(cfg, "testapp", appConfig)
+	if err != nil || len(purged) != 1 {
+		t.Fatalf("Expected the store copy to be purged, got %v %v", purged, err)
+	}
+	if exists(filepath.Join(cfg.StorePath, appConfig.Paths[0].Destination)) {
+		t.Error("Expected the store copy to be deleted")
+	}
+
+	// Destinations another application references are kept
+	shared := *appConfig
+	cfg.Apps["other"] = &shared
+	if err := os.WriteFile(filepath.Join(cfg.StorePath, appConfig.Paths[0].Destination), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	if purged, err := PurgeDestinations(cfg, "testapp", appConfig); err != nil || len(purged) != 0 {
+		t.Errorf("Expected a shared destination to be kept, got %v %v", purged, err)
+	}
+}
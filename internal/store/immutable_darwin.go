@@ -0,0 +1,29 @@
+//go:build darwin
+
+package store
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setImmutable sets or clears the user immutable flag (chflags uchg) of a file or directory,
+// which keeps even its owner from changing, renaming, or deleting it
+func setImmutable(path string, info os.FileInfo, immutable bool) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	flags := int(stat.Flags)
+	if immutable {
+		flags |= unix.UF_IMMUTABLE
+	} else {
+		flags &^= unix.UF_IMMUTABLE
+	}
+	if flags == int(stat.Flags) {
+		return nil
+	}
+	return unix.Chflags(path, flags)
+}
@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package store
+
+import "os"
+
+// setImmutable sets or clears the immutable flag of a file or directory. Only macOS lets users
+// flag their own files immutable, so elsewhere a locked store relies on its permissions.
+func setImmutable(path string, info os.FileInfo, immutable bool) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// Lockdown makes the store read-only for 'configsync store lockdown': it takes the write
+// permission off every file and directory in it and, on macOS, flags them immutable, so neither
+// applications writing through their links nor other tools can change it. Files hard-linked
+// elsewhere, such as the blobs of a content-addressed store, are not flagged, so their other
+// links can still be deleted. The modes it changes are recorded in the store's
+// permissions file first, for Unlock to restore. It returns the number of entries it changed.
+func Lockdown(storeDir string) (int, error) {
+	permissions, err := manifest.LoadPermissions(storeDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var paths []string
+	infos := make(map[string]os.FileInfo)
+	err = filepath.Walk(storeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return err
+		}
+		paths = append(paths, path)
+		infos[path] = info
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Record the modes before the store, and with it the permissions file, becomes read-only
+	recorded := false
+	for _, path := range paths {
+		perm := infos[path].Mode().Perm()
+		if perm&0222 == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(storeDir, path)
+		if err != nil {
+			return 0, err
+		}
+		if permissions.Locked == nil {
+			permissions.Locked = make(map[string]string)
+		}
+		permissions.Locked[filepath.ToSlash(rel)] = fmt.Sprintf("%04o", perm)
+		recorded = true
+	}
+	if recorded {
+		// A permissions file written now is locked down with the rest, at the mode Save gives it
+		permissionsPath := filepath.Join(storeDir, manifest.PermissionsFileName)
+		_, walked := infos[permissionsPath]
+		if !walked {
+			permissions.Locked[manifest.PermissionsFileName] = "0644"
+		}
+		if err := permissions.Save(); err != nil {
+			return 0, err
+		}
+		if !walked {
+			info, err := os.Lstat(permissionsPath)
+			if err != nil {
+				return 0, err
+			}
+			paths = append(paths, permissionsPath)
+			infos[permissionsPath] = info
+		}
+	}
+
+	changed := 0
+	for _, path := range paths {
+		info := infos[path]
+		if perm := info.Mode().Perm(); perm&0222 != 0 {
+			if err := os.Chmod(path, perm&^0222); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+		if links, ok := fsops.LinkCount(info); ok && links > 1 {
+			continue
+		}
+		if err := setImmutable(path, info, true); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// Unlock undoes Lockdown: it clears the immutable flag of every file and directory in the store
+// and restores its mode: the one recorded before its path was synced, or else the one it had
+// before Lockdown. Entries with neither stay as they are, since they were read-only before,
+// except in stores locked down before modes were recorded, where their owner may write them
+// again. It returns the number of entries it changed.
+func Unlock(storeDir string) (int, error) {
+	permissions, err := manifest.LoadPermissions(storeDir)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	err = filepath.Walk(storeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return err
+		}
+		if err := setImmutable(path, info, false); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(storeDir, path)
+		if err != nil {
+			return err
+		}
+		perm := info.Mode().Perm()
+		mode, ok := unlockedMode(permissions, rel)
+		switch {
+		case ok:
+		case permissions.Locked == nil:
+			mode = perm | 0200
+		default:
+			return nil
+		}
+		if mode != perm {
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil || permissions.Locked == nil {
+		return changed, err
+	}
+
+	permissions.Locked = nil
+	return changed, permissions.Save()
+}
+
+// unlockedMode returns the mode a store entry gets back when the store is unlocked
+func unlockedMode(permissions *manifest.Permissions, relPath string) (os.FileMode, bool) {
+	if ownership, ok := permissions.Lookup(relPath); ok {
+		if perm, err := ownership.Perm(); err == nil {
+			return perm, true
+		}
+	}
+	return permissions.LockedPerm(relPath)
+}
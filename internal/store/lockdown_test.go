@@ -0,0 +1,105 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+func TestLockdownAndUnlock(t *testing.T) {
+	storeDir := filepath.Join(t.TempDir(), "store")
+	file := filepath.Join(storeDir, ".config", "app", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("settings.json", filepath.Join(filepath.Dir(file), "link.json")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	key := filepath.Join(storeDir, ".ssh", "id_ed25519")
+	if err := os.MkdirAll(filepath.Dir(key), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(key, []byte("key"), 0400); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	changed, err := Lockdown(storeDir)
+	if err != nil {
+		t.Fatalf("Lockdown failed: %v", err)
+	}
+	defer func() { _, _ = Unlock(storeDir) }()
+	// The store, its three directories, the file, and the permissions file recording their modes
+	if changed != 6 {
+		t.Errorf("Expected 6 entries to be locked down, got %d", changed)
+	}
+	for _, path := range []string{storeDir, filepath.Dir(file), file} {
+		if info, _ := os.Stat(path); info.Mode().Perm()&0222 != 0 {
+			t.Errorf("Expected %s to be read-only, got %04o", path, info.Mode().Perm())
+		}
+	}
+	if changed, _ := Lockdown(storeDir); changed != 0 {
+		t.Errorf("Expected locking down a locked store to change nothing, got %d", changed)
+	}
+
+	changed, err = Unlock(storeDir)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if changed != 6 {
+		t.Errorf("Expected 6 entries to be unlocked, got %d", changed)
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the file to be writable again, got %04o", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(key); info.Mode().Perm() != 0400 {
+		t.Errorf("Expected a file read-only before lockdown to stay read-only, got %04o", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Dir(key)); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected a directory to get its mode back, got %04o", info.Mode().Perm())
+	}
+	if err := os.WriteFile(file, []byte(`{"a": 1}`), 0644); err != nil {
+		t.Errorf("Expected the unlocked store to be writable: %v", err)
+	}
+}
+
+func TestUnlockRestoresRecordedMode(t *testing.T) {
+	storeDir := t.TempDir()
+	file := filepath.Join(storeDir, ".netrc")
+	if err := os.WriteFile(file, []byte("machine"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	info, _ := os.Lstat(file)
+	if _, _, ok := fsops.Owner(info); !ok {
+		t.Skip("Files have no owner on this platform")
+	}
+	// The file was 0600 before it was synced, and was later opened up by another tool
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	info, _ = os.Lstat(file)
+	if err := manifest.RecordOwnership(storeDir, ".netrc", info); err != nil {
+		t.Fatalf("RecordOwnership failed: %v", err)
+	}
+	if err := os.Chmod(file, 0644); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+
+	if _, err := Lockdown(storeDir); err != nil {
+		t.Fatalf("Lockdown failed: %v", err)
+	}
+	if _, err := Unlock(storeDir); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the recorded mode to be restored, got %04o", info.Mode().Perm())
+	}
+	if permissions, err := manifest.LoadPermissions(storeDir); err != nil || len(permissions.Locked) != 0 {
+		t.Errorf("Expected unlocking to clear the modes recorded by lockdown, got %+v %v", permissions, err)
+	}
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+// Kinds of permission problems
+const (
+	PermissionOwner      = "owner"      // Belongs to another user, such as root after running under sudo
+	PermissionUnreadable = "unreadable" // Its owner cannot read it, or list or enter a directory
+	PermissionMode       = "mode"       // Its mode differs from the one recorded before it was synced
+)
+
+// PermissionIssue is a store copy, or a copy at a path's source such as one restored from
+// the store, whose owner or mode keeps the user or configsync from using it
+type PermissionIssue struct {
+	App      string `json:"app" yaml:"app"`
+	Path     string `json:"path" yaml:"path"`
+	Kind     string `json:"kind" yaml:"kind"`
+	Problem  string `json:"problem" yaml:"problem"`
+	Fixed    bool   `json:"fixed,omitempty" yaml:"fixed,omitempty"`
+	FixError string `json:"fix_error,omitempty" yaml:"fix_error,omitempty"`
+
+	entries []string    // The entries to repair, the path itself and those below it
+	uid     int         // Owner to give the entries to
+	gid     int         // Group to give the entries to
+	mode    fs.FileMode // Permission bits to restore
+}
+
+// CheckPermissions checks the owner and mode of the store copies of the paths of apps, and of
+// the copies at their sources that are not links to the store. Every entry should belong to the
+// user who owned the path before it was moved into the store, or for paths synced before owners
+// were recorded, to the owner of homeDir; its owner should be able to read it; and each store copy
+// should keep its recorded mode, less its write permission while the store is locked down.
+// Nothing is checked where files have no owner, as on Windows.
+func CheckPermissions(cfg *config.Config, homeDir string, apps map[string]*config.AppConfig) ([]*PermissionIssue, error) {
+	homeInfo, err := os.Stat(homeDir)
+	if err != nil {
+		return nil, err
+	}
+	homeUID, homeGID, ok := fsops.Owner(homeInfo)
+	if !ok {
+		return nil, nil
+	}
+	permissions, err := manifest.LoadPermissions(cfg.StorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	appNames := make([]string, 0, len(apps))
+	for appName := range apps {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+
+	var issues []*PermissionIssue
+	for _, appName := range appNames {
+		for _, path := range apps[appName].Paths {
+			if !path.AppliesTo(config.CurrentPlatform) {
+				continue
+			}
+			expected := manifest.Ownership{UID: homeUID, GID: homeGID}
+			recorded, hasRecord := permissions.Lookup(path.Destination)
+			if hasRecord {
+				expected = recorded
+			}
+
+			storePath := filepath.Join(cfg.StorePath, path.Destination)
+			found, err := checkTree(appName, storePath, expected)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, found...)
+			if hasRecord {
+				if issue := checkMode(appName, storePath, recorded, cfg.Settings.StoreReadOnly()); issue != nil {
+					issues = append(issues, issue)
+				}
+			}
+
+			if path.IsExported() {
+				continue
+			}
+			for _, location := range path.LiveLocations() {
+				found, err := checkTree(appName, fsops.ExpandHome(location, homeDir), expected)
+				if err != nil {
+					return nil, err
+				}
+				issues = append(issues, found...)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// checkTree checks that a file or directory and everything below it belong to the expected
+// owner, who can read them. Missing paths and symlinks, such as a source linked to the store,
+// are skipped.
+func checkTree(appName, root string, expected manifest.Ownership) ([]*PermissionIssue, error) {
+	info, err := os.Lstat(root)
+	if os.IsNotExist(err) || (err == nil && info.Mode()&os.ModeSymlink != 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var misowned, unreadable []string
+	owners := make(map[int]bool)
+	err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			// A directory that cannot be listed is reported as unreadable by its owner below
+			if os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if uid, _, ok := fsops.Owner(info); ok && uid != expected.UID {
+			misowned = append(misowned, file)
+			owners[uid] = true
+		}
+		if info.Mode()&os.ModeSymlink == 0 && info.Mode().Perm()&ownerAccess(info) != ownerAccess(info) {
+			unreadable = append(unreadable, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []*PermissionIssue
+	if len(misowned) > 0 {
+		var names []string
+		for uid := range owners {
+			names = append(names, userName(uid))
+		}
+		sort.Strings(names)
+		issues = append(issues, &PermissionIssue{
+			App:     appName,
+			Path:    root,
+			Kind:    PermissionOwner,
+			Problem: fmt.Sprintf("%s owned by %s instead of %s", countEntries(len(misowned)), strings.Join(names, ", "), userName(expected.UID)),
+			entries: misowned,
+			uid:     expected.UID,
+			gid:     expected.GID,
+		})
+	}
+	if len(unreadable) > 0 {
+		issues = append(issues, &PermissionIssue{
+			App:     appName,
+			Path:    root,
+			Kind:    PermissionUnreadable,
+			Problem: fmt.Sprintf("%s their owner cannot read", countEntries(len(unreadable))),
+			entries: unreadable,
+		})
+	}
+	return issues, nil
+}
+
+// checkMode checks that a store copy keeps the mode its path had before it was synced, which
+// 'configsync store lockdown' takes the write permission off
+func checkMode(appName, storePath string, recorded manifest.Ownership, locked bool) *PermissionIssue {
+	info, err := os.Lstat(storePath)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	mode, err := recorded.Perm()
+	if err != nil {
+		return nil
+	}
+	if locked {
+		mode &^= 0222
+	}
+	if info.Mode().Perm() == mode {
+		return nil
+	}
+	return &PermissionIssue{
+		App:     appName,
+		Path:    storePath,
+		Kind:    PermissionMode,
+		Problem: fmt.Sprintf("mode is %04o instead of %04o, which it had before it was synced", info.Mode().Perm(), mode),
+		entries: []string{storePath},
+		mode:    mode,
+	}
+}
+
+// ownerAccess returns the permission bits the owner needs: reading a file, and listing and
+// entering a directory
+func ownerAccess(info os.FileInfo) os.FileMode {
+	if info.IsDir() {
+		return 0500
+	}
+	return 0400
+}
+
+// FixPermissions repairs the issues found by CheckPermissions, marking each one fixed or
+// recording why it could not be. Giving entries to another user needs root.
+func FixPermissions(issues []*PermissionIssue) {
+	for _, issue := range issues {
+		var err error
+		for _, entry := range issue.entries {
+			if err = fixEntry(issue, entry); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			issue.FixError = err.Error()
+			if os.IsPermission(err) {
+				issue.FixError += "; run it again with sudo"
+			}
+			continue
+		}
+		issue.Fixed = true
+	}
+}
+
+// fixEntry repairs one entry of a permission issue
+func fixEntry(issue *PermissionIssue, entry string) error {
+	switch issue.Kind {
+	case PermissionOwner:
+		return os.Lchown(entry, issue.uid, issue.gid)
+	case PermissionUnreadable:
+		info, err := os.Lstat(entry)
+		if err != nil {
+			return err
+		}
+		return os.Chmod(entry, info.Mode().Perm()|ownerAccess(info))
+	case PermissionMode:
+		return os.Chmod(entry, issue.mode)
+	}
+	return nil
+}
+
+// userName returns the name of a user, or its ID when it has none
+func userName(uid int) string {
+	if account, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return account.Username
+	}
+	return "uid " + strconv.Itoa(uid)
+}
+
+// countEntries describes a number of files and directories
+func countEntries(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+)
+
+func TestCheckAndFixPermissions(t *testing.T) {
+	homeDir, configManager, _ := setupSyncedApp(t)
+	cfg, _ := configManager.Load()
+	cfg.Apps["testapp"].Paths[0].Platforms = []string{config.CurrentPlatform}
+	storeFile := filepath.Join(cfg.StorePath, "Library", "Preferences", "com.test.app.plist")
+	if info, err := os.Stat(homeDir); err != nil {
+		t.Fatalf("Failed to stat home: %v", err)
+	} else if _, _, ok := fsops.Owner(info); !ok {
+		t.Skip("Files have no owner on this platform")
+	}
+
+	// The file was private before it was synced
+	if err := os.Chmod(storeFile, 0600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	info, _ := os.Lstat(storeFile)
+	if err := manifest.RecordOwnership(cfg.StorePath, "Library/Preferences/com.test.app.plist", info); err != nil {
+		t.Fatalf("RecordOwnership failed: %v", err)
+	}
+	issues, err := CheckPermissions(cfg, homeDir, cfg.Apps)
+	if err != nil || len(issues) != 0 {
+		t.Fatalf("Expected no issues, got %+v %v", issues, err)
+	}
+
+	tests := []struct {
+		name   string
+		breaks func() error
+		kind   string
+	}{
+		{name: "mode", breaks: func() error { return os.Chmod(storeFile, 0644) }, kind: PermissionMode},
+		{name: "unreadable", breaks: func() error { return os.Chmod(storeFile, 0200) }, kind: PermissionUnreadable},
+		{name: "owner", breaks: func() error {
+			if os.Geteuid() != 0 {
+				t.Skip("Changing owners needs root")
+			}
+			return os.Lchown(storeFile, 4242, 4242)
+		}, kind: PermissionOwner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.breaks(); err != nil {
+				t.Fatalf("Failed to break permissions: %v", err)
+			}
+			issues, err := CheckPermissions(cfg, homeDir, cfg.Apps)
+			if err != nil {
+				t.Fatalf("CheckPermissions failed: %v", err)
+			}
+			if len(issues) == 0 || issues[0].Kind != tt.kind || issues[0].Path != storeFile {
+				t.Fatalf("Expected a %s issue with %s, got %+v", tt.kind, storeFile, issues)
+			}
+
+			FixPermissions(issues)
+			for _, issue := range issues {
+				if !issue.Fixed {
+					t.Errorf("Expected %s to be fixed: %s", issue.Kind, issue.FixError)
+				}
+			}
+			if issues, err := CheckPermissions(cfg, homeDir, cfg.Apps); err != nil || len(issues) != 0 {
+				t.Errorf("Expected no issues after fixing, got %+v %v", issues, err)
+			}
+		})
+	}
+}
+
+func TestCheckPermissionsLockedDown(t *testing.T) {
+	homeDir, configManager, _ := setupSyncedApp(t)
+	cfg, _ := configManager.Load()
+	cfg.Apps["testapp"].Paths[0].Platforms = []string{config.CurrentPlatform}
+	storeFile := filepath.Join(cfg.StorePath, "Library", "Preferences", "com.test.app.plist")
+	info, _ := os.Lstat(storeFile)
+	if _, _, ok := fsops.Owner(info); !ok {
+		t.Skip("Files have no owner on this platform")
+	}
+	if err := manifest.RecordOwnership(cfg.StorePath, "Library/Preferences/com.test.app.plist", info); err != nil {
+		t.Fatalf("RecordOwnership failed: %v", err)
+	}
+
+	cfg.Settings.ReadOnlyStore = true
+	if _, err := Lockdown(cfg.StorePath); err != nil {
+		t.Fatalf("Lockdown failed: %v", err)
+	}
+	defer func() { _, _ = Unlock(cfg.StorePath) }()
+	if issues, err := CheckPermissions(cfg, homeDir, cfg.Apps); err != nil || len(issues) != 0 {
+		t.Errorf("Expected a locked-down store copy not to be reported, got %+v %v", issues, err)
+	}
+
+	cfg.Settings.ReadOnlyStore = false
+	if issues, err := CheckPermissions(cfg, homeDir, cfg.Apps); err != nil || len(issues) != 1 || issues[0].Kind != PermissionMode {
+		t.Errorf("Expected a read-only store copy of an unlocked store to be reported, got %+v %v", issues, err)
+	}
+}
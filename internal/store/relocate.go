@@ -0,0 +1,264 @@
+// Package store provides operations on the central configuration store as a whole.
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// LockFileName is the name of the lock file held while the store is being relocated, snapshotted, or restored
+const LockFileName = "store.lock"
+
+// RelocationResult summarizes a completed store relocation
+type RelocationResult struct {
+	OldStore string
+	NewStore string
+	Copied   int
+	Skipped  int
+	Relinked int
+}
+
+// Relocator moves the store to a new location while keeping app symlinks valid
+type Relocator struct {
+	out      io.Writer
+	homeDir  string
+	newStore string
+	verbose  bool
+}
+
+// relinkedPath records a symlink that was swapped so it can be rolled back
+type relinkedPath struct {
+	source    string
+	oldTarget string
+	newTarget string
+}
+
+// NewRelocator creates a new store relocator
+func NewRelocator(homeDir, newStore string, verbose bool) *Relocator {
+	return &Relocator{
+		out:      os.Stdout,
+		homeDir:  homeDir,
+		newStore: filepath.Clean(newStore),
+		verbose:  verbose,
+	}
+}
+
+// IsLocked reports whether a store relocation, snapshot, or restore is in progress
+func IsLocked(configDir string) bool {
+	_, err := os.Stat(filepath.Join(configDir, LockFileName))
+	return err == nil
+}
+
+// Relocate copies the store to the new location, swaps every app symlink to point at it,
+// and updates the configuration. Apps keep working throughout because each symlink is
+// replaced atomically and the old store stays in place until the relocation has been verified.
+// Any failure after the swap begins rolls back the symlinks and configuration.
+func (r *Relocator) Relocate(configManager *config.Manager) (*RelocationResult, error) {
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	if IsLocked(configManager.GetConfigDir()) {
+		return nil, fmt.Errorf("another store operation is in progress")
+	}
+
+	oldStore := filepath.Clean(cfg.StorePath)
+	if err := r.validateTarget(oldStore); err != nil {
+		return nil, err
+	}
+
+	result := &RelocationResult{OldStore: oldStore, NewStore: r.newStore}
+
+	// Pre-copy while apps keep using the old store
+	if r.verbose {
+		fmt.Fprintf(r.out, "Pre-copying store: %s -> %s\n", oldStore, r.newStore)
+	}
+	newManifest, err := manifest.Load(r.newStore)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := newManifest.CopyDir(oldStore, r.newStore); err != nil {
+		return nil, fmt.Errorf("failed to pre-copy store: %w", err)
+	}
+
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Catch up on anything apps wrote during the pre-copy
+	stats, err := newManifest.CopyDir(oldStore, r.newStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy store changes: %w", err)
+	}
+	if err := newManifest.Save(); err != nil {
+		return nil, err
+	}
+	result.Copied = stats.Copied
+	result.Skipped = stats.Skipped
+
+	relinked, err := r.swapSymlinks(cfg, oldStore)
+	if err != nil {
+		r.rollback(relinked)
+		return nil, err
+	}
+	result.Relinked = len(relinked)
+
+	if err := r.verify(relinked); err != nil {
+		r.rollback(relinked)
+		return nil, fmt.Errorf("verification failed: %w", err)
+	}
+
+	cfg.StorePath = r.newStore
+	if err := configManager.Save(cfg); err != nil {
+		r.rollback(relinked)
+		cfg.StorePath = oldStore
+		return nil, messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+
+	return result, nil
+}
+
+// Helper methods
+
+// validateTarget ensures the new store location is usable
+func (r *Relocator) validateTarget(oldStore string) error {
+	if r.newStore == oldStore {
+		return fmt.Errorf("store is already located at %s", oldStore)
+	}
+	if isWithin(r.newStore, oldStore) || isWithin(oldStore, r.newStore) {
+		return fmt.Errorf("new store location cannot be inside the current store or contain it")
+	}
+
+	entries, err := os.ReadDir(r.newStore)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read new store location: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("new store location is not empty: %s", r.newStore)
+	}
+
+	return nil
+}
+
+// lock creates the store lock file and returns a function that releases it
+func lock(configDir string) (func(), error) {
+	lockPath := filepath.Join(configDir, LockFileName)
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("another store operation is in progress (remove %s if it is stale)", lockPath)
+		}
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+	_, _ = fmt.Fprintf(file, "%d\n", os.Getpid())
+	_ = file.Close()
+
+	return func() { _ = os.Remove(lockPath) }, nil
+}
+
+// swapSymlinks points every app symlink into the old store at the new store instead
+func (r *Relocator) swapSymlinks(cfg *config.Config, oldStore string) ([]relinkedPath, error) {
+	var relinked []relinkedPath
+
+	for _, appConfig := range cfg.Apps {
+		for _, path := range appConfig.Paths {
+			if path.IsExported() {
+				continue
+			}
+
+			source := r.expandPath(path.Source)
+			oldTarget := filepath.Join(oldStore, path.Destination)
+			if !pointsTo(source, oldTarget) {
+				continue
+			}
+
+			newTarget := filepath.Join(r.newStore, path.Destination)
+			if r.verbose {
+				fmt.Fprintf(r.out, "  Relinking: %s -> %s\n", source, newTarget)
+			}
+
+			if err := replaceSymlink(source, newTarget); err != nil {
+				return relinked, fmt.Errorf("failed to relink %s: %w", source, err)
+			}
+			relinked = append(relinked, relinkedPath{source: source, oldTarget: oldTarget, newTarget: newTarget})
+		}
+	}
+
+	return relinked, nil
+}
+
+// verify checks that every swapped symlink resolves to existing content in the new store
+func (r *Relocator) verify(relinked []relinkedPath) error {
+	for _, link := range relinked {
+		if !pointsTo(link.source, link.newTarget) {
+			return fmt.Errorf("%s does not point to %s", link.source, link.newTarget)
+		}
+		if _, err := os.Stat(link.source); err != nil {
+			return fmt.Errorf("%s does not resolve: %w", link.source, err)
+		}
+	}
+	return nil
+}
+
+// rollback points swapped symlinks back at the old store
+func (r *Relocator) rollback(relinked []relinkedPath) {
+	for _, link := range relinked {
+		if err := replaceSymlink(link.source, link.oldTarget); err != nil {
+			fmt.Fprintf(r.out, "Warning: failed to roll back %s: %v\n", link.source, err)
+		}
+	}
+}
+
+func (r *Relocator) expandPath(path string) string {
+	return fsops.ExpandHome(path, r.homeDir)
+}
+
+// replaceSymlink atomically replaces the symlink at source with one pointing to target
+func replaceSymlink(source, target string) error {
+	tempLink := source + ".configsync-relink"
+	_ = os.Remove(tempLink)
+
+	if err := os.Symlink(target, tempLink); err != nil {
+		return err
+	}
+	if err := os.Rename(tempLink, source); err != nil {
+		_ = os.Remove(tempLink)
+		return err
+	}
+	return nil
+}
+
+// pointsTo reports whether source is a symlink to target
+func pointsTo(source, target string) bool {
+	link, err := os.Readlink(source)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(source), link)
+	}
+	return filepath.Clean(link) == filepath.Clean(target)
+}
+
+// isWithin reports whether path is inside dir
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
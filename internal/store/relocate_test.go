@@ -0,0 +1,192 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+// setupSyncedApp creates an initialized configuration with one app whose settings file is linked into the store
+func setupSyncedApp(t *testing.T) (string, *config.Manager, string) {
+	t.Helper()
+	homeDir := t.TempDir()
+
+	configManager := config.NewManager(homeDir)
+	if err := configManager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	storeFile := filepath.Join(cfg.StorePath, "Library", "Preferences", "com.test.app.plist")
+	if err := os.MkdirAll(filepath.Dir(storeFile), 0755); err != nil {
+		t.Fatalf("Failed to create store directory: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+
+	source := filepath.Join(homeDir, "Library", "Preferences", "com.test.app.plist")
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.Symlink(storeFile, source); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	cfg.Apps["testapp"] = &config.AppConfig{
+		Name:    "testapp",
+		Enabled: true,
+		Paths: []config.Path{
+			{
+				Source:      "~/Library/Preferences/com.test.app.plist",
+				Destination: "Library/Preferences/com.test.app.plist",
+				Type:        config.PathTypeFile,
+				Synced:      true,
+			},
+		},
+	}
+	if err := configManager.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	return homeDir, configManager, source
+}
+
+func newTestRelocator(homeDir, newStore string) *Relocator {
+	relocator := NewRelocator(homeDir, newStore, false)
+	relocator.out = io.Discard
+	return relocator
+}
+
+func TestRelocate(t *testing.T) {
+	homeDir, configManager, source := setupSyncedApp(t)
+	newStore := filepath.Join(t.TempDir(), "store")
+
+	result, err := newTestRelocator(homeDir, newStore).Relocate(configManager)
+	if err != nil {
+		t.Fatalf("Relocate failed: %v", err)
+	}
+
+	if result.Relinked != 1 {
+		t.Errorf("Expected 1 relinked path, got %d", result.Relinked)
+	}
+
+	expectedTarget := filepath.Join(newStore, "Library", "Preferences", "com.test.app.plist")
+	if !pointsTo(source, expectedTarget) {
+		link, _ := os.Readlink(source)
+		t.Errorf("Expected symlink to point to %s, got %s", expectedTarget, link)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Failed to read through relinked symlink: %v", err)
+	}
+	if string(data) != constants.TestConfiguration {
+		t.Errorf("Expected content to be preserved, got %q", string(data))
+	}
+
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if cfg.StorePath != newStore {
+		t.Errorf("Expected store path %s, got %s", newStore, cfg.StorePath)
+	}
+
+	if IsLocked(configManager.GetConfigDir()) {
+		t.Error("Expected lock to be released after relocation")
+	}
+
+	// The old store is left in place
+	if _, err := os.Stat(result.OldStore); err != nil {
+		t.Errorf("Expected old store to be kept: %v", err)
+	}
+}
+
+func TestRelocateRejectsInvalidTargets(t *testing.T) {
+	homeDir, configManager, _ := setupSyncedApp(t)
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	nonEmpty := t.TempDir()
+	if err := os.WriteFile(filepath.Join(nonEmpty, "existing"), []byte(constants.TestHelloWorld), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		newStore string
+	}{
+		{"same location", cfg.StorePath},
+		{"inside current store", filepath.Join(cfg.StorePath, "nested")},
+		{"non-empty directory", nonEmpty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestRelocator(homeDir, tt.newStore).Relocate(configManager); err == nil {
+				t.Error("Expected error for invalid target")
+			}
+		})
+	}
+}
+
+func TestRelocateFailsWhileLocked(t *testing.T) {
+	homeDir, configManager, source := setupSyncedApp(t)
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	oldTarget, _ := os.Readlink(source)
+
+	lockPath := filepath.Join(configManager.GetConfigDir(), LockFileName)
+	if err := os.WriteFile(lockPath, []byte("1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create lock: %v", err)
+	}
+
+	if _, err := newTestRelocator(homeDir, filepath.Join(t.TempDir(), "store")).Relocate(configManager); err == nil {
+		t.Fatal("Expected error while store is locked")
+	}
+
+	if !pointsTo(source, oldTarget) {
+		t.Error("Expected symlink to be untouched")
+	}
+	reloaded, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if reloaded.StorePath != cfg.StorePath {
+		t.Error("Expected store path to be unchanged")
+	}
+}
+
+func TestRollbackRestoresSymlinks(t *testing.T) {
+	homeDir, _, source := setupSyncedApp(t)
+	oldTarget, _ := os.Readlink(source)
+	newTarget := filepath.Join(t.TempDir(), "missing.plist")
+
+	if err := replaceSymlink(source, newTarget); err != nil {
+		t.Fatalf("replaceSymlink failed: %v", err)
+	}
+
+	relocator := newTestRelocator(homeDir, filepath.Dir(newTarget))
+	relinked := []relinkedPath{{source: source, oldTarget: oldTarget, newTarget: newTarget}}
+
+	if err := relocator.verify(relinked); err == nil {
+		t.Error("Expected verification to fail for a dangling symlink")
+	}
+
+	relocator.rollback(relinked)
+	if !pointsTo(source, oldTarget) {
+		t.Error("Expected rollback to restore the original symlink")
+	}
+}
@@ -0,0 +1,484 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// SnapshotDir is the directory in the configuration directory that holds store snapshots
+const SnapshotDir = "snapshots"
+
+// SnapshotIDFormat is the timestamp layout used for snapshot identifiers
+const SnapshotIDFormat = "20060102-150405"
+
+const (
+	snapshotInfoFile   = "snapshot.yaml" // Written last, so a snapshot without it is incomplete
+	snapshotConfigFile = "config.yaml"
+	snapshotStoreDir   = "store"
+)
+
+// Snapshot describes a copy of the whole store and configuration taken at one point in time
+type Snapshot struct {
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	ID        string    `json:"id" yaml:"id"`
+	Label     string    `json:"label,omitempty" yaml:"label,omitempty"`
+	StorePath string    `json:"store_path" yaml:"store_path"`
+	Apps      []string  `json:"apps" yaml:"apps"`
+	Files     int       `json:"files" yaml:"files"`
+	Linked    int       `json:"linked" yaml:"linked"` // Files shared with the previous snapshot through hard links
+	Size      int64     `json:"size" yaml:"size"`     // Total size of the files, including shared ones
+}
+
+// RestoreResult summarizes a completed snapshot restore
+type RestoreResult struct {
+	Snapshot *Snapshot
+	Safety   *Snapshot // Snapshot of the state before the restore, for undoing it
+	Skipped  []string  // Sources left alone because they exist and are not symlinks
+	Relinked int       // Symlinks created or repointed at the store
+	Copied   int       // Sources of copy-mode paths replaced with the restored store copy
+	Unlinked int       // Symlinks of paths the snapshot does not manage, replaced with their files
+}
+
+// Snapshotter creates, lists, and restores snapshots of the store
+type Snapshotter struct {
+	out     io.Writer
+	homeDir string
+	dir     string
+	verbose bool
+}
+
+// NewSnapshotter creates a snapshotter keeping snapshots in the configuration directory
+func NewSnapshotter(homeDir, configDir string, verbose bool) *Snapshotter {
+	return &Snapshotter{
+		out:     os.Stdout,
+		homeDir: homeDir,
+		dir:     filepath.Join(configDir, SnapshotDir),
+		verbose: verbose,
+	}
+}
+
+// Dir returns the directory holding the snapshots
+func (s *Snapshotter) Dir() string {
+	return s.dir
+}
+
+// Create snapshots the store and configuration. Files unchanged since the previous snapshot are
+// hard-linked to its copy instead of being copied again, so each snapshot only takes up space for
+// what changed. The live store is never linked into, since apps may edit its files in place.
+func (s *Snapshotter) Create(configManager *config.Manager, label string) (*Snapshot, error) {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return s.create(configManager, label)
+}
+
+// List returns the complete snapshots, newest first
+func (s *Snapshotter) List() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := s.load(entry.Name())
+		if err != nil {
+			if s.verbose {
+				fmt.Fprintf(s.out, "Warning: skipping snapshot %s: %v\n", entry.Name(), err)
+			}
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// Find returns the snapshot with the given ID or unique ID prefix
+func (s *Snapshotter) Find(id string) (*Snapshot, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+		if strings.HasPrefix(snapshot.ID, id) {
+			matches = append(matches, snapshot)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("snapshot not found: %s (see 'configsync snapshot list')", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("snapshot %s is ambiguous: it matches %d snapshots", id, len(matches))
+	}
+}
+
+// Restore rolls the store and configuration back to a snapshot. The current state is snapshotted
+// first so the restore can be undone. The restored store is built beside the current one and
+// swapped in with a rename, then symlinks are made to match the restored configuration: synced
+// paths are linked into the store again, and symlinks of paths the snapshot does not manage are
+// replaced with the files they pointed to.
+func (s *Snapshotter) Restore(configManager *config.Manager, id string) (*RestoreResult, error) {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snapshot, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	restoredCfg, err := s.loadConfig(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	currentCfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	storePath := filepath.Clean(currentCfg.StorePath)
+
+	safety, err := s.create(configManager, "before restoring "+snapshot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot the current state: %w", err)
+	}
+	result := &RestoreResult{Snapshot: snapshot, Safety: safety}
+
+	// Build the restored store beside the current one so the swap is a rename
+	restoringPath := storePath + ".configsync-restore"
+	previousPath := storePath + ".configsync-previous"
+	for _, path := range []string{restoringPath, previousPath} {
+		if err := os.RemoveAll(path); err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", path, err)
+		}
+	}
+	if err := fsops.CopyTree(context.Background(), fsys.OS, filepath.Join(s.dir, snapshot.ID, snapshotStoreDir), restoringPath, nil); err != nil {
+		_ = os.RemoveAll(restoringPath)
+		return nil, fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+
+	if err := os.Rename(storePath, previousPath); err != nil && !os.IsNotExist(err) {
+		_ = os.RemoveAll(restoringPath)
+		return nil, fmt.Errorf("failed to move the current store aside: %w", err)
+	}
+	if err := os.Rename(restoringPath, storePath); err != nil {
+		_ = os.Rename(previousPath, storePath)
+		_ = os.RemoveAll(restoringPath)
+		return nil, fmt.Errorf("failed to swap in the restored store: %w", err)
+	}
+
+	// The snapshot keeps its own store location only if the store has not moved since
+	restoredCfg.StorePath = storePath
+	if err := configManager.Save(restoredCfg); err != nil {
+		return nil, fmt.Errorf("failed to save restored configuration (the previous state is snapshot %s): %w", safety.ID, err)
+	}
+
+	s.unlinkOrphans(currentCfg, restoredCfg, storePath, previousPath, result)
+	s.relink(restoredCfg, storePath, result)
+
+	if err := os.RemoveAll(previousPath); err != nil {
+		fmt.Fprintf(s.out, "Warning: failed to remove the replaced store %s: %v\n", previousPath, err)
+	}
+
+	return result, nil
+}
+
+// Helper methods
+
+// create snapshots the store and configuration; the caller holds the store lock
+func (s *Snapshotter) create(configManager *config.Manager, label string) (*Snapshot, error) {
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var previousStore string
+	if len(snapshots) > 0 {
+		previousStore = filepath.Join(s.dir, snapshots[0].ID, snapshotStoreDir)
+	}
+
+	now := time.Now()
+	snapshot := &Snapshot{
+		CreatedAt: now,
+		ID:        uniqueID(s.dir, now),
+		Label:     label,
+		StorePath: cfg.StorePath,
+		Apps:      []string{},
+	}
+	for appName := range cfg.Apps {
+		snapshot.Apps = append(snapshot.Apps, appName)
+	}
+	sort.Strings(snapshot.Apps)
+
+	snapshotPath := filepath.Join(s.dir, snapshot.ID)
+	complete := false
+	defer func() {
+		if !complete {
+			_ = os.RemoveAll(snapshotPath)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(snapshotPath, snapshotStoreDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	// Saved as a single file, with any applications of the apps directory merged in
+	configData, err := configManager.ConfigData()
+	if err == nil {
+		err = os.WriteFile(filepath.Join(snapshotPath, snapshotConfigFile), configData, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot configuration: %w", err)
+	}
+
+	if s.verbose {
+		fmt.Fprintf(s.out, "Snapshotting store: %s\n", cfg.StorePath)
+	}
+	err = filepath.Walk(cfg.StorePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.StorePath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		dst := filepath.Join(snapshotPath, snapshotStoreDir, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			return fsops.CopySymlink(fsys.OS, path, dst)
+		case info.Mode().IsRegular():
+			snapshot.Files++
+			snapshot.Size += info.Size()
+			linked, err := snapshotFile(path, dst, previousStore, rel, info)
+			if linked {
+				snapshot.Linked++
+			}
+			return err
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot store: %w", err)
+	}
+
+	data, err := yaml.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot info: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(snapshotPath, snapshotInfoFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot info: %w", err)
+	}
+
+	complete = true
+	return snapshot, nil
+}
+
+// uniqueID returns an unused identifier in a directory for a creation time
+func uniqueID(dir string, createdAt time.Time) string {
+	base := createdAt.Format(SnapshotIDFormat)
+	id := base
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(filepath.Join(dir, id)); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// load reads the metadata of a snapshot
+func (s *Snapshotter) load(id string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id, snapshotInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot info: %w", err)
+	}
+	snapshot.ID = id
+	return &snapshot, nil
+}
+
+// loadConfig reads the configuration saved in a snapshot
+func (s *Snapshotter) loadConfig(snapshot *Snapshot) (*config.Config, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshot.ID, snapshotConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot configuration: %w", err)
+	}
+
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// unlinkOrphans replaces symlinks into the store of paths the restored configuration does not
+// sync with a copy of the files they pointed to, taken from the replaced store
+func (s *Snapshotter) unlinkOrphans(currentCfg, restoredCfg *config.Config, storePath, previousPath string, result *RestoreResult) {
+	restored := make(map[string]bool)
+	for _, appConfig := range restoredCfg.Apps {
+		for _, path := range appConfig.Paths {
+			if path.Synced {
+				restored[s.expandPath(path.Source)] = true
+			}
+		}
+	}
+
+	for _, appConfig := range currentCfg.Apps {
+		for _, path := range appConfig.Paths {
+			source := s.expandPath(path.Source)
+			if path.IsExported() || restored[source] || !pointsTo(source, filepath.Join(storePath, path.Destination)) {
+				continue
+			}
+
+			previous := filepath.Join(previousPath, path.Destination)
+			if _, err := os.Lstat(previous); err != nil {
+				continue
+			}
+			if err := os.Remove(source); err != nil {
+				fmt.Fprintf(s.out, "Warning: failed to unlink %s: %v\n", source, err)
+				continue
+			}
+			if err := fsops.CopyTree(context.Background(), fsys.OS, previous, source, nil); err != nil {
+				fmt.Fprintf(s.out, "Warning: failed to restore %s: %v\n", source, err)
+				continue
+			}
+			if s.verbose {
+				fmt.Fprintf(s.out, "  Unlinked: %s\n", source)
+			}
+			result.Unlinked++
+		}
+	}
+}
+
+// relink points the source of every synced path in the restored configuration at the store
+func (s *Snapshotter) relink(restoredCfg *config.Config, storePath string, result *RestoreResult) {
+	for _, appConfig := range restoredCfg.Apps {
+		for i := range appConfig.Paths {
+			path := &appConfig.Paths[i]
+			if !path.Synced || path.IsExported() || path.IsSecret() || !path.AppliesTo(config.CurrentPlatform) {
+				continue
+			}
+
+			source := s.expandPath(path.Source)
+			target := filepath.Join(storePath, path.Destination)
+			if _, err := os.Lstat(target); err != nil {
+				continue
+			}
+
+			if path.IsCopyMode() {
+				if err := os.RemoveAll(source); err != nil {
+					fmt.Fprintf(s.out, "Warning: failed to replace %s: %v\n", source, err)
+					continue
+				}
+				if err := fsops.CopyTree(context.Background(), fsys.OS, target, source, nil); err != nil {
+					fmt.Fprintf(s.out, "Warning: failed to restore %s: %v\n", source, err)
+					continue
+				}
+				result.Copied++
+				continue
+			}
+
+			if pointsTo(source, target) {
+				continue
+			}
+
+			info, err := os.Lstat(source)
+			switch {
+			case os.IsNotExist(err):
+				if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+					fmt.Fprintf(s.out, "Warning: failed to link %s: %v\n", source, err)
+					continue
+				}
+				err = os.Symlink(target, source)
+				if err != nil {
+					fmt.Fprintf(s.out, "Warning: failed to link %s: %v\n", source, err)
+					continue
+				}
+			case err == nil && info.Mode()&os.ModeSymlink != 0:
+				if err := replaceSymlink(source, target); err != nil {
+					fmt.Fprintf(s.out, "Warning: failed to relink %s: %v\n", source, err)
+					continue
+				}
+			default:
+				result.Skipped = append(result.Skipped, source)
+				continue
+			}
+
+			if s.verbose {
+				fmt.Fprintf(s.out, "  Linked: %s -> %s\n", source, target)
+			}
+			result.Relinked++
+		}
+	}
+	sort.Strings(result.Skipped)
+}
+
+// expandPath resolves a path starting with ~/ against the home directory
+func (s *Snapshotter) expandPath(path string) string {
+	return fsops.ExpandHome(path, s.homeDir)
+}
+
+// snapshotFile adds a store file to a snapshot, hard-linking the previous snapshot's copy when
+// it has the same size, modification time, and permissions. It reports whether a link was made.
+func snapshotFile(src, dst, previousStore, rel string, info os.FileInfo) (bool, error) {
+	if previousStore != "" {
+		previous := filepath.Join(previousStore, rel)
+		prevInfo, err := os.Lstat(previous)
+		if err == nil && prevInfo.Mode().IsRegular() && prevInfo.Size() == info.Size() &&
+			prevInfo.ModTime().Equal(info.ModTime()) && prevInfo.Mode().Perm() == info.Mode().Perm() {
+			if err := os.Link(previous, dst); err == nil {
+				return true, nil
+			}
+		}
+	}
+	return false, fsops.CopyFile(context.Background(), fsys.OS, src, dst)
+}
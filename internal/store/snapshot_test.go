@@ -0,0 +1,168 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func newTestSnapshotter(homeDir string, configManager *config.Manager) *Snapshotter {
+	snapshotter := NewSnapshotter(homeDir, configManager.GetConfigDir(), false)
+	snapshotter.out = io.Discard
+	return snapshotter
+}
+
+// setupSnapshotApp sets up a synced app whose path applies to the platform the tests run on,
+// since restore only links paths for the current platform
+func setupSnapshotApp(t *testing.T) (string, *config.Manager, string) {
+	t.Helper()
+	homeDir, configManager, source := setupSyncedApp(t)
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Apps["testapp"].Paths[0].Platforms = []string{config.CurrentPlatform}
+	if err := configManager.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	return homeDir, configManager, source
+}
+
+func TestSnapshotCreateAndList(t *testing.T) {
+	homeDir, configManager, _ := setupSnapshotApp(t)
+	snapshotter := newTestSnapshotter(homeDir, configManager)
+
+	first, err := snapshotter.Create(configManager, "first")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if first.Label != "first" || first.Files != 1 || first.Linked != 0 {
+		t.Errorf("Unexpected first snapshot: %+v", first)
+	}
+	if len(first.Apps) != 1 || first.Apps[0] != "testapp" {
+		t.Errorf("Expected the snapshot to list testapp, got %v", first.Apps)
+	}
+
+	// The unchanged file is shared with the first snapshot
+	second, err := snapshotter.Create(configManager, "")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Fatalf("Expected distinct snapshot IDs, got %s twice", first.ID)
+	}
+	if second.Linked != 1 {
+		t.Errorf("Expected the unchanged file to be hard linked, got %d linked", second.Linked)
+	}
+
+	snapshots, err := snapshotter.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != second.ID || snapshots[1].ID != first.ID {
+		t.Errorf("Expected both snapshots newest first, got %+v", snapshots)
+	}
+
+	found, err := snapshotter.Find(first.ID)
+	if err != nil || found.ID != first.ID {
+		t.Errorf("Expected to find %s, got %+v (%v)", first.ID, found, err)
+	}
+	if _, err := snapshotter.Find("missing"); err == nil {
+		t.Error("Expected an error for an unknown snapshot")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	homeDir, configManager, source := setupSnapshotApp(t)
+	snapshotter := newTestSnapshotter(homeDir, configManager)
+
+	snapshot, err := snapshotter.Create(configManager, "good")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	original, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+
+	// Break things: change the file through its symlink, drop the symlink and the app
+	if err := os.WriteFile(source, []byte("broken"), 0644); err != nil {
+		t.Fatalf("Failed to change file: %v", err)
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := configManager.RemoveApp("testapp"); err != nil {
+		t.Fatalf("RemoveApp failed: %v", err)
+	}
+
+	result, err := snapshotter.Restore(configManager, snapshot.ID)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if result.Relinked != 1 || len(result.Skipped) != 0 {
+		t.Errorf("Expected the symlink to be recreated, got %+v", result)
+	}
+	if result.Safety == nil || result.Safety.Label == "" {
+		t.Errorf("Expected a safety snapshot of the previous state, got %+v", result.Safety)
+	}
+
+	if _, err := configManager.GetApp("testapp"); err != nil {
+		t.Errorf("Expected the app configuration to be restored: %v", err)
+	}
+	if info, err := os.Lstat(source); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected the source to be a symlink again: %v", err)
+	}
+	restored, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(restored) != string(original) {
+		t.Errorf("Expected the original contents, got %q", restored)
+	}
+
+	// Editing the live store must not change the snapshot
+	if err := os.WriteFile(source, []byte("edited in place"), 0644); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+	snapshotFile := filepath.Join(snapshotter.Dir(), snapshot.ID, "store", "Library", "Preferences", "com.test.app.plist")
+	kept, err := os.ReadFile(snapshotFile)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot file: %v", err)
+	}
+	if string(kept) != string(original) {
+		t.Errorf("Expected the snapshot to keep its contents, got %q", kept)
+	}
+}
+
+func TestSnapshotRestoreLeavesRealFiles(t *testing.T) {
+	homeDir, configManager, source := setupSnapshotApp(t)
+	snapshotter := newTestSnapshotter(homeDir, configManager)
+
+	snapshot, err := snapshotter.Create(configManager, "")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.WriteFile(source, []byte("local"), 0644); err != nil {
+		t.Fatalf("Failed to write local file: %v", err)
+	}
+
+	result, err := snapshotter.Restore(configManager, snapshot.ID)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != source {
+		t.Errorf("Expected the real file to be skipped, got %v", result.Skipped)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil || string(data) != "local" {
+		t.Errorf("Expected the local file to be left alone, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,587 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// UndoDir is the directory in the configuration directory that holds undo points
+const UndoDir = "undo"
+
+// DefaultUndoPoints is how many undo points are kept; older ones are pruned as new ones are captured
+const DefaultUndoPoints = 20
+
+const (
+	undoInfoFile   = "undo.yaml" // Written last, so an undo point without it is incomplete
+	undoSourcesDir = "sources"
+	// undoArchiveDir is the directory in the backup directory holding files replaced by undos
+	undoArchiveDir = "undo"
+)
+
+// Source states recorded in an undo point
+const (
+	sourceMissing = "missing"
+	sourceSymlink = "symlink"
+	sourceFile    = "file" // A regular file or directory
+)
+
+// Actions reported for the changes an undo makes
+const (
+	UndoRelinked  = "relinked"
+	UndoRestored  = "restored"
+	UndoRemoved   = "removed"
+	UndoMovedBack = "moved back from the store"
+)
+
+// UndoPoint records the state of applications before an operation changed them: their
+// configuration entries, store copies, and sources
+type UndoPoint struct {
+	CreatedAt time.Time  `yaml:"created_at"`
+	ID        string     `yaml:"id"`
+	Operation string     `yaml:"operation"`
+	StorePath string     `yaml:"store_path"`
+	Apps      []string   `yaml:"apps"`
+	Paths     []UndoPath `yaml:"paths"`
+}
+
+// UndoPath is the state of one configured path when an undo point was captured
+type UndoPath struct {
+	App         string `yaml:"app"`
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+	State       string `yaml:"state"`
+	LinkTarget  string `yaml:"link_target,omitempty"`
+	Stored      bool   `yaml:"stored"` // The store copy existed and is kept in the undo point
+	Saved       bool   `yaml:"saved"`  // The source was a file or directory and is kept in the undo point
+}
+
+// UndoChange is one change made, or that would be made, by an undo
+type UndoChange struct {
+	Path   string `json:"path" yaml:"path"`
+	Action string `json:"action" yaml:"action"`
+}
+
+// UndoResult summarizes an undo
+type UndoResult struct {
+	Point      *UndoPoint   `json:"-" yaml:"-"`
+	ArchiveDir string       `json:"archive_dir,omitempty" yaml:"archive_dir,omitempty"` // Where files replaced by the undo were moved
+	Changes    []UndoChange `json:"changes" yaml:"changes"`
+	Failed     []string     `json:"failed,omitempty" yaml:"failed,omitempty"`
+}
+
+// Undoer captures undo points before operations and reverts operations to them
+type Undoer struct {
+	out     io.Writer
+	homeDir string
+	dir     string
+	keep    int
+	verbose bool
+}
+
+// NewUndoer creates an undoer keeping undo points in the configuration directory
+func NewUndoer(homeDir, configDir string, verbose bool) *Undoer {
+	return &Undoer{
+		out:     os.Stdout,
+		homeDir: homeDir,
+		dir:     filepath.Join(configDir, UndoDir),
+		keep:    DefaultUndoPoints,
+		verbose: verbose,
+	}
+}
+
+// Capture records the configuration entries, store copies, and sources of the applications
+// before an operation changes them. Applications that are not configured yet, such as those
+// about to be added or deployed, are recorded as absent from the configuration. Store files
+// unchanged since the previous undo point are hard-linked to its copy.
+func (u *Undoer) Capture(configManager *config.Manager, operation string, apps map[string]*config.AppConfig) (*UndoPoint, error) {
+	// The cached configuration is used, so changes the operation makes to it are still saved
+	storePath, err := configManager.GetStorePath()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+
+	ids, err := u.ids()
+	if err != nil {
+		return nil, err
+	}
+	var previousStore string
+	if len(ids) > 0 {
+		previousStore = filepath.Join(u.dir, ids[len(ids)-1], snapshotStoreDir)
+	}
+
+	now := time.Now()
+	point := &UndoPoint{
+		CreatedAt: now,
+		ID:        uniqueID(u.dir, now),
+		Operation: operation,
+		StorePath: storePath,
+		Apps:      []string{},
+	}
+	for appName := range apps {
+		point.Apps = append(point.Apps, appName)
+	}
+	sort.Strings(point.Apps)
+
+	pointPath := filepath.Join(u.dir, point.ID)
+	complete := false
+	defer func() {
+		if !complete {
+			_ = os.RemoveAll(pointPath)
+		}
+	}()
+
+	if err := os.MkdirAll(pointPath, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create undo point: %w", err)
+	}
+	configData, err := configManager.ConfigData()
+	if err == nil {
+		err = os.WriteFile(filepath.Join(pointPath, snapshotConfigFile), configData, 0644)
+	}
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigSaveFailed, nil, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, appName := range point.Apps {
+		for _, path := range apps[appName].Paths {
+			if path.IsExported() || !path.AppliesTo(config.CurrentPlatform) {
+				continue
+			}
+
+			// Each link is captured like a source of its own
+			for i, location := range path.LiveLocations() {
+				if seen[location] {
+					continue
+				}
+				seen[location] = true
+
+				located := path
+				located.Source = location
+				state, err := u.capturePath(pointPath, previousStore, storePath, len(point.Paths), appName, located, i > 0)
+				if err != nil {
+					return nil, fmt.Errorf("failed to save %s: %w", location, err)
+				}
+				point.Paths = append(point.Paths, state)
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(point)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal undo point: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(pointPath, undoInfoFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save undo point: %w", err)
+	}
+	complete = true
+
+	u.prune(append(ids, point.ID))
+	return point, nil
+}
+
+// Find returns the undo point with the given ID
+func (u *Undoer) Find(id string) (*UndoPoint, error) {
+	data, err := os.ReadFile(filepath.Join(u.dir, id, undoInfoFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("undo point %s is no longer available; only the last %d are kept", id, u.keep)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read undo point: %w", err)
+	}
+
+	var point UndoPoint
+	if err := yaml.Unmarshal(data, &point); err != nil {
+		return nil, fmt.Errorf("failed to parse undo point: %w", err)
+	}
+	point.ID = id
+	return &point, nil
+}
+
+// Revert returns the applications of an undo point to the state it recorded: store copies and
+// sources are restored, symlinks are recreated, and the applications' configuration entries are
+// put back or removed. Files the undo replaces are moved to the backup directory rather than
+// deleted. With dryRun, the changes are only reported.
+func (u *Undoer) Revert(configManager *config.Manager, id string, dryRun bool) (*UndoResult, error) {
+	unlock, err := lock(configManager.GetConfigDir())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	point, err := u.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	pointPath := filepath.Join(u.dir, point.ID)
+
+	data, err := os.ReadFile(filepath.Join(pointPath, snapshotConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved configuration: %w", err)
+	}
+	savedCfg, err := config.ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse saved configuration: %w", err)
+	}
+	cfg, err := configManager.Load()
+	if err != nil {
+		return nil, messages.Wrap(messages.ConfigLoadFailed, nil, err)
+	}
+	if filepath.Clean(cfg.StorePath) != filepath.Clean(point.StorePath) {
+		return nil, fmt.Errorf("the store has moved from %s since the undo point was captured", point.StorePath)
+	}
+
+	result := &UndoResult{Point: point, Changes: []UndoChange{}}
+	archiveDir := filepath.Join(cfg.BackupPath, undoArchiveDir, time.Now().Format(SnapshotIDFormat))
+	revert := &pathReverter{
+		out:        u.out,
+		homeDir:    u.homeDir,
+		pointPath:  pointPath,
+		storePath:  cfg.StorePath,
+		archiveDir: archiveDir,
+		result:     result,
+		dryRun:     dryRun,
+	}
+	for i, path := range point.Paths {
+		revert.revert(i, path)
+	}
+	if revert.archived {
+		result.ArchiveDir = archiveDir
+	}
+
+	for _, appName := range point.Apps {
+		saved, existed := savedCfg.Apps[appName]
+		_, exists := cfg.Apps[appName]
+		switch {
+		case existed && !sameApp(saved, cfg.Apps[appName]):
+			cfg.Apps[appName] = saved
+			result.Changes = append(result.Changes, UndoChange{Path: "configuration of " + appName, Action: UndoRestored})
+		case !existed && exists:
+			delete(cfg.Apps, appName)
+			result.Changes = append(result.Changes, UndoChange{Path: "configuration of " + appName, Action: UndoRemoved})
+		}
+	}
+	if !dryRun {
+		if err := configManager.Save(cfg); err != nil {
+			return nil, fmt.Errorf("failed to save restored configuration: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// Helper methods
+
+// capturePath saves the store copy and source of one path into an undo point. A file at a link is
+// always saved, since syncing archives it rather than moving it into the store.
+func (u *Undoer) capturePath(pointPath, previousStore, storePath string, index int, appName string, path config.Path, link bool) (UndoPath, error) {
+	state := UndoPath{App: appName, Source: path.Source, Destination: path.Destination, State: sourceMissing}
+	source := expandHomePath(u.homeDir, path.Source)
+	storeCopy := filepath.Join(storePath, path.Destination)
+
+	if info, err := os.Lstat(source); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			state.State = sourceSymlink
+			if state.LinkTarget, err = os.Readlink(source); err != nil {
+				return state, err
+			}
+		} else {
+			state.State = sourceFile
+		}
+	}
+
+	if _, err := os.Lstat(storeCopy); err == nil {
+		// The links of a path share its store copy, which only needs saving once
+		if !exists(filepath.Join(pointPath, snapshotStoreDir, path.Destination)) {
+			if err := captureTree(storeCopy, filepath.Join(pointPath, snapshotStoreDir), previousStore, path.Destination); err != nil {
+				return state, err
+			}
+		}
+		state.Stored = true
+	}
+
+	// A source without a store copy is only moved into the store by an operation, so the undo can
+	// move it back instead of keeping a copy of what may be a large directory
+	if state.State == sourceFile && (state.Stored || link) {
+		if err := fsops.CopyTree(context.Background(), fsys.OS, source, filepath.Join(pointPath, undoSourcesDir, fmt.Sprint(index)), nil); err != nil {
+			return state, err
+		}
+		state.Saved = true
+	}
+	return state, nil
+}
+
+// ids returns the IDs of the complete undo points, oldest first
+func (u *Undoer) ids() ([]string, error) {
+	entries, err := os.ReadDir(u.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read undo points: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(u.dir, entry.Name(), undoInfoFile)); entry.IsDir() && err == nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune removes the oldest undo points beyond the number kept
+func (u *Undoer) prune(ids []string) {
+	for len(ids) > u.keep {
+		if err := os.RemoveAll(filepath.Join(u.dir, ids[0])); err != nil {
+			fmt.Fprintf(u.out, "Warning: failed to remove undo point %s: %v\n", ids[0], err)
+		}
+		ids = ids[1:]
+	}
+}
+
+// pathReverter restores the paths of one undo point
+type pathReverter struct {
+	out        io.Writer
+	result     *UndoResult
+	homeDir    string
+	pointPath  string
+	storePath  string
+	archiveDir string
+	dryRun     bool
+	archived   bool
+}
+
+// revert restores the store copy and source of one path
+func (r *pathReverter) revert(index int, path UndoPath) {
+	source := expandHomePath(r.homeDir, path.Source)
+	storeCopy := filepath.Join(r.storePath, path.Destination)
+	savedStore := filepath.Join(r.pointPath, snapshotStoreDir, path.Destination)
+	savedSource := filepath.Join(r.pointPath, undoSourcesDir, fmt.Sprint(index))
+
+	// The operation moved the source into the store and linked it; move it back
+	if path.State == sourceFile && !path.Saved && !path.Stored {
+		if pointsTo(source, storeCopy) {
+			r.apply(source, UndoMovedBack, func() error {
+				if err := os.Remove(source); err != nil {
+					return err
+				}
+				return moveTree(storeCopy, source)
+			})
+		} else if exists(storeCopy) {
+			r.apply(storeCopy, UndoRemoved, func() error { return r.archive(storeCopy, "store", path.Destination) })
+		}
+		return
+	}
+
+	switch {
+	case path.Stored && !sameTree(savedStore, storeCopy):
+		r.apply(storeCopy, UndoRestored, func() error {
+			if exists(storeCopy) {
+				if err := r.archive(storeCopy, "store", path.Destination); err != nil {
+					return err
+				}
+			}
+			return fsops.CopyTree(context.Background(), fsys.OS, savedStore, storeCopy, nil)
+		})
+	case !path.Stored && exists(storeCopy):
+		r.apply(storeCopy, UndoRemoved, func() error { return r.archive(storeCopy, "store", path.Destination) })
+	}
+
+	switch path.State {
+	case sourceSymlink:
+		if link, err := os.Readlink(source); err == nil && link == path.LinkTarget {
+			return
+		}
+		r.apply(source, UndoRelinked, func() error {
+			if err := r.clearSource(source); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+				return err
+			}
+			return os.Symlink(path.LinkTarget, source)
+		})
+	case sourceFile:
+		if info, err := os.Lstat(source); err == nil && info.Mode()&os.ModeSymlink == 0 && sameTree(savedSource, source) {
+			return
+		}
+		r.apply(source, UndoRestored, func() error {
+			if err := r.clearSource(source); err != nil {
+				return err
+			}
+			return fsops.CopyTree(context.Background(), fsys.OS, savedSource, source, nil)
+		})
+	case sourceMissing:
+		if exists(source) {
+			r.apply(source, UndoRemoved, func() error { return r.clearSource(source) })
+		}
+	}
+}
+
+// apply makes one change, or only records it in a dry run
+func (r *pathReverter) apply(path, action string, change func() error) {
+	if !r.dryRun {
+		if err := change(); err != nil {
+			fmt.Fprintf(r.out, "Warning: failed to undo changes to %s: %v\n", path, err)
+			r.result.Failed = append(r.result.Failed, path)
+			return
+		}
+	}
+	r.result.Changes = append(r.result.Changes, UndoChange{Path: path, Action: action})
+}
+
+// clearSource removes a symlink at a source, or moves a file or directory there to the archive
+func (r *pathReverter) clearSource(source string) error {
+	info, err := os.Lstat(source)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		return os.Remove(source)
+	}
+
+	rel, err := filepath.Rel(r.homeDir, source)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = strings.TrimPrefix(source, string(filepath.Separator))
+	}
+	return r.archive(source, "home", rel)
+}
+
+// archive moves a file or directory replaced by the undo into the archive directory
+func (r *pathReverter) archive(path, area, rel string) error {
+	target := filepath.Join(r.archiveDir, area, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	if err := moveTree(path, archivePath(filepath.Dir(target), target)); err != nil {
+		return err
+	}
+	r.archived = true
+	return nil
+}
+
+// captureTree saves a store file or directory into an undo point, hard-linking files unchanged
+// since the previous undo point
+func captureTree(src, storeDir, previousStore, rel string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		sub, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		fileRel := filepath.Join(rel, sub)
+		dst := filepath.Join(storeDir, fileRel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			return fsops.CopySymlink(fsys.OS, path, dst)
+		case info.Mode().IsRegular():
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			_, err := snapshotFile(path, dst, previousStore, fileRel, info)
+			return err
+		default:
+			return nil
+		}
+	})
+}
+
+// moveTree moves a file or directory, copying it when a rename is not possible, such as
+// between volumes
+func moveTree(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := fsops.CopyTree(context.Background(), fsys.OS, src, dst, nil); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// sameTree reports whether two files or directories have the same entries, sizes, modification
+// times, and permissions; copies made for undo points keep all of them
+func sameTree(a, b string) bool {
+	signatures := func(root string) (map[string]string, error) {
+		result := make(map[string]string)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			switch {
+			case info.IsDir():
+				result[rel] = "dir"
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				result[rel] = "link:" + link
+			default:
+				result[rel] = fmt.Sprintf("%d:%d:%o", info.Size(), info.ModTime().UnixNano(), info.Mode().Perm())
+			}
+			return nil
+		})
+		return result, err
+	}
+
+	left, err := signatures(a)
+	if err != nil {
+		return false
+	}
+	right, err := signatures(b)
+	if err != nil || len(left) != len(right) {
+		return false
+	}
+	for rel, signature := range left {
+		if right[rel] != signature {
+			return false
+		}
+	}
+	return true
+}
+
+// sameApp reports whether two application configurations are the same
+func sameApp(a, b *config.AppConfig) bool {
+	left, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := yaml.Marshal(b)
+	return err == nil && string(left) == string(right)
+}
+
+// exists reports whether anything, including a dangling symlink, is at a path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// expandHomePath resolves a path starting with ~/ against the home directory
+func expandHomePath(homeDir, path string) string {
+	return fsops.ExpandHome(path, homeDir)
+}
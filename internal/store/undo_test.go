@@ -0,0 +1,220 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+func newTestUndoer(homeDir string, configManager *config.Manager) *Undoer {
+	undoer := NewUndoer(homeDir, configManager.GetConfigDir(), false)
+	undoer.out = io.Discard
+	return undoer
+}
+
+// captureApps captures an undo point for the named applications as they are configured
+func captureApps(t *testing.T, undoer *Undoer, configManager *config.Manager, apps map[string]*config.AppConfig) *UndoPoint {
+	t.Helper()
+	point, err := undoer.Capture(configManager, "test", apps)
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	return point
+}
+
+func TestUndoRemove(t *testing.T) {
+	homeDir, configManager, source := setupSnapshotApp(t)
+	undoer := newTestUndoer(homeDir, configManager)
+	cfg, _ := configManager.Load()
+	storeFile := filepath.Join(cfg.StorePath, cfg.Apps["testapp"].Paths[0].Destination)
+
+	point := captureApps(t, undoer, configManager, cfg.Apps)
+	if len(point.Paths) != 1 || point.Paths[0].State != sourceSymlink || !point.Paths[0].Stored || point.Paths[0].Saved {
+		t.Fatalf("Unexpected undo point: %+v", point.Paths)
+	}
+
+	// Remove the app the way remove does: restore the source, then drop the store copy and entry
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.WriteFile(source, []byte("restored"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.Remove(storeFile); err != nil {
+		t.Fatalf("Failed to remove store file: %v", err)
+	}
+	delete(cfg.Apps, "testapp")
+	if err := configManager.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	result, err := undoer.Revert(configManager, point.ID, false)
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if len(result.Failed) != 0 || len(result.Changes) != 3 {
+		t.Errorf("Unexpected changes: %+v", result)
+	}
+
+	if !pointsTo(source, storeFile) {
+		t.Error("Expected the source to be linked to the store again")
+	}
+	if data, err := os.ReadFile(storeFile); err != nil || string(data) != constants.TestConfiguration {
+		t.Errorf("Expected the store copy to be restored, got %q %v", data, err)
+	}
+	cfg, _ = configManager.Load()
+	if _, exists := cfg.Apps["testapp"]; !exists {
+		t.Error("Expected the configuration entry to be restored")
+	}
+	archived := filepath.Join(result.ArchiveDir, "home", "Library", "Preferences", "com.test.app.plist")
+	if data, err := os.ReadFile(archived); err != nil || string(data) != "restored" {
+		t.Errorf("Expected the replaced source to be archived, got %q %v", data, err)
+	}
+}
+
+func TestUndoMovesFirstSyncBack(t *testing.T) {
+	homeDir, configManager, _ := setupSnapshotApp(t)
+	undoer := newTestUndoer(homeDir, configManager)
+	cfg, _ := configManager.Load()
+
+	source := filepath.Join(homeDir, ".newrc")
+	if err := os.WriteFile(source, []byte("mine"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	app := &config.AppConfig{
+		Name:    "newapp",
+		Enabled: true,
+		Paths: []config.Path{
+			{Source: "~/.newrc", Destination: ".newrc", Type: config.PathTypeFile, Platforms: []string{config.CurrentPlatform}},
+		},
+	}
+	point := captureApps(t, undoer, configManager, map[string]*config.AppConfig{"newapp": app})
+
+	// Sync the app for the first time: move the source into the store and link it
+	storeFile := filepath.Join(cfg.StorePath, ".newrc")
+	if err := os.Rename(source, storeFile); err != nil {
+		t.Fatalf("Failed to move source: %v", err)
+	}
+	if err := os.Symlink(storeFile, source); err != nil {
+		t.Fatalf("Failed to link source: %v", err)
+	}
+	cfg.Apps["newapp"] = app
+	if err := configManager.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	if _, err := undoer.Revert(configManager, point.ID, false); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+
+	if info, err := os.Lstat(source); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Expected the source to be a regular file again, got %v %v", info, err)
+	}
+	if data, _ := os.ReadFile(source); string(data) != "mine" {
+		t.Errorf("Expected the source content to be moved back, got %q", data)
+	}
+	if exists(storeFile) {
+		t.Error("Expected the store copy to be moved out of the store")
+	}
+	cfg, _ = configManager.Load()
+	if _, exists := cfg.Apps["newapp"]; exists {
+		t.Error("Expected the configuration entry to be removed")
+	}
+	if _, exists := cfg.Apps["testapp"]; !exists {
+		t.Error("Expected other applications to be left alone")
+	}
+}
+
+func TestUndoRestoresLinks(t *testing.T) {
+	homeDir, configManager, _ := setupSnapshotApp(t)
+	undoer := newTestUndoer(homeDir, configManager)
+	cfg, _ := configManager.Load()
+
+	source := filepath.Join(homeDir, ".newrc")
+	legacy := filepath.Join(homeDir, ".oldrc")
+	for path, content := range map[string]string{source: "mine", legacy: "legacy"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	app := &config.AppConfig{
+		Name:    "newapp",
+		Enabled: true,
+		Paths: []config.Path{
+			{Source: "~/.newrc", Destination: ".newrc", Type: config.PathTypeFile, Links: []string{"~/.oldrc"}, Platforms: []string{config.CurrentPlatform}},
+		},
+	}
+	point := captureApps(t, undoer, configManager, map[string]*config.AppConfig{"newapp": app})
+	if len(point.Paths) != 2 || point.Paths[1].Source != "~/.oldrc" || !point.Paths[1].Saved {
+		t.Fatalf("Expected the link to be captured with its file, got %+v", point.Paths)
+	}
+
+	// Sync the app: move the source into the store and link both locations to it
+	storeFile := filepath.Join(cfg.StorePath, ".newrc")
+	if err := os.Rename(source, storeFile); err != nil {
+		t.Fatalf("Failed to move source: %v", err)
+	}
+	for _, location := range []string{source, legacy} {
+		_ = os.Remove(location)
+		if err := os.Symlink(storeFile, location); err != nil {
+			t.Fatalf("Failed to link %s: %v", location, err)
+		}
+	}
+
+	if _, err := undoer.Revert(configManager, point.ID, false); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	for path, content := range map[string]string{source: "mine", legacy: "legacy"} {
+		if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			t.Fatalf("Expected %s to be a regular file again, got %v %v", path, info, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", path, content, data)
+		}
+	}
+}
+
+func TestUndoDryRunChangesNothing(t *testing.T) {
+	homeDir, configManager, source := setupSnapshotApp(t)
+	undoer := newTestUndoer(homeDir, configManager)
+	cfg, _ := configManager.Load()
+
+	point := captureApps(t, undoer, configManager, cfg.Apps)
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+
+	result, err := undoer.Revert(configManager, point.ID, true)
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Action != UndoRelinked {
+		t.Errorf("Unexpected changes: %+v", result.Changes)
+	}
+	if exists(source) {
+		t.Error("Expected a dry run to leave the source alone")
+	}
+}
+
+func TestUndoPointsArePruned(t *testing.T) {
+	homeDir, configManager, _ := setupSnapshotApp(t)
+	undoer := newTestUndoer(homeDir, configManager)
+	undoer.keep = 2
+	cfg, _ := configManager.Load()
+
+	first := captureApps(t, undoer, configManager, cfg.Apps)
+	captureApps(t, undoer, configManager, cfg.Apps)
+	captureApps(t, undoer, configManager, cfg.Apps)
+
+	ids, err := undoer.ids()
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Expected 2 undo points, got %v %v", ids, err)
+	}
+	if _, err := undoer.Find(first.ID); err == nil {
+		t.Error("Expected the oldest undo point to be pruned")
+	}
+}
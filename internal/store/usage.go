@@ -0,0 +1,85 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+)
+
+// PathUsage is the space one path of an application takes up in the store
+type PathUsage struct {
+	Source      string `json:"source" yaml:"source"`
+	Destination string `json:"destination" yaml:"destination"`
+	Size        int64  `json:"size" yaml:"size"`
+	Large       bool   `json:"large,omitempty" yaml:"large,omitempty"` // Above the size warning threshold
+}
+
+// AppUsage is the space one application takes up in the store and in backups
+type AppUsage struct {
+	Name        string      `json:"name" yaml:"name"`
+	DisplayName string      `json:"display_name" yaml:"display_name"`
+	Paths       []PathUsage `json:"paths" yaml:"paths"` // Largest first
+	StoreSize   int64       `json:"store_size" yaml:"store_size"`
+	BackupSize  int64       `json:"backup_size" yaml:"backup_size"`
+	Total       int64       `json:"total" yaml:"total"`
+}
+
+// LargePaths returns the paths above the size warning threshold
+func (u *AppUsage) LargePaths() []PathUsage {
+	var large []PathUsage
+	for _, path := range u.Paths {
+		if path.Large {
+			large = append(large, path)
+		}
+	}
+	return large
+}
+
+// MeasureUsage measures how much space each configured application takes up in the store, and
+// in backups when backupSize is not nil. Paths whose store copy exceeds threshold are marked
+// large. Applications are returned largest first.
+func MeasureUsage(cfg *config.Config, threshold int64, backupSize func(appName string) (int64, error)) ([]*AppUsage, error) {
+	usages := make([]*AppUsage, 0, len(cfg.Apps))
+	for appName, appConfig := range cfg.Apps {
+		usage := &AppUsage{Name: appName, DisplayName: appConfig.DisplayName}
+
+		for _, path := range appConfig.Paths {
+			size, err := fsutil.Size(filepath.Join(cfg.StorePath, path.Destination))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to measure %s of %s: %w", path.Destination, appName, err)
+			}
+			usage.Paths = append(usage.Paths, PathUsage{
+				Source:      path.Source,
+				Destination: path.Destination,
+				Size:        size,
+				Large:       threshold > 0 && size > threshold,
+			})
+			usage.StoreSize += size
+		}
+		sort.SliceStable(usage.Paths, func(i, j int) bool {
+			return usage.Paths[i].Size > usage.Paths[j].Size
+		})
+
+		if backupSize != nil {
+			size, err := backupSize(appName)
+			if err != nil {
+				return nil, err
+			}
+			usage.BackupSize = size
+		}
+		usage.Total = usage.StoreSize + usage.BackupSize
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Total != usages[j].Total {
+			return usages[i].Total > usages[j].Total
+		}
+		return usages[i].Name < usages[j].Name
+	})
+	return usages, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestMeasureUsage(t *testing.T) {
+	_, configManager, _ := setupSyncedApp(t)
+	cfg, err := configManager.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	profile := filepath.Join(cfg.StorePath, "Chrome")
+	if err := os.MkdirAll(profile, 0755); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(profile, "Cache"), []byte(strings.Repeat("x", 4096)), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	cfg.Apps["chrome"] = &config.AppConfig{
+		Name: "chrome",
+		Paths: []config.Path{
+			{Source: "~/Chrome", Destination: "Chrome", Type: config.PathTypeDirectory},
+			{Source: "~/missing", Destination: "missing", Type: config.PathTypeFile},
+		},
+	}
+
+	backupSizes := map[string]int64{"testapp": 10}
+	usages, err := MeasureUsage(cfg, 1024, func(appName string) (int64, error) {
+		return backupSizes[appName], nil
+	})
+	if err != nil {
+		t.Fatalf("MeasureUsage failed: %v", err)
+	}
+	if len(usages) != 2 || usages[0].Name != "chrome" {
+		t.Fatalf("Expected chrome to be listed first, got %+v", usages)
+	}
+
+	chrome := usages[0]
+	if chrome.StoreSize != 4096 || chrome.Total != 4096 {
+		t.Errorf("Expected chrome to use 4096 bytes, got store %d, total %d", chrome.StoreSize, chrome.Total)
+	}
+	if large := chrome.LargePaths(); len(large) != 1 || large[0].Destination != "Chrome" {
+		t.Errorf("Expected the profile to be flagged as large, got %+v", large)
+	}
+
+	testapp := usages[1]
+	if testapp.BackupSize != 10 || testapp.Total != testapp.StoreSize+10 {
+		t.Errorf("Expected backups to be included in the total, got %+v", testapp)
+	}
+	if len(testapp.LargePaths()) != 0 {
+		t.Errorf("Expected no large paths for testapp, got %+v", testapp.LargePaths())
+	}
+}
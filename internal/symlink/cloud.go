@@ -0,0 +1,66 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/store"
+)
+
+// SetConflictStrategy sets how conflicted copies created by a cloud service in the store are
+// resolved while syncing (see the store.Conflict* strategies). "ask" or "" only reports them.
+func (m *Manager) SetConflictStrategy(strategy string) {
+	m.conflictStrategy = strategy
+}
+
+// SetEvents reports the conflicts found while syncing, cloud conflicted copies and replaced
+// symlinks alike, to an event emitter
+func (m *Manager) SetEvents(emitter *events.Emitter) {
+	m.events = emitter
+}
+
+// prepareCloudPath waits for a store path kept in a cloud-synced folder to be downloaded and
+// handles any conflicted copies the cloud service made of it. Local stores are left alone.
+func (m *Manager) prepareCloudPath(storePath string) error {
+	if m.cloudProvider == "" {
+		return nil
+	}
+
+	if !m.dryRun {
+		if err := store.Materialize(storePath, m.materializeTimeout); err != nil {
+			return err
+		}
+	}
+
+	conflicts, err := store.FindConflicts(m.storeDir, storePath)
+	if err != nil {
+		return fmt.Errorf("failed to check for %s conflicts: %w", m.cloudProvider, err)
+	}
+
+	for _, conflict := range conflicts {
+		rel, _ := filepath.Rel(m.storeDir, conflict.Path)
+		m.events.Emit(events.ConflictDetected, "", map[string]interface{}{
+			"kind":     "cloud_copy",
+			"provider": conflict.Provider,
+			"path":     rel,
+			"strategy": m.conflictStrategy,
+		})
+		if m.conflictStrategy == "" || m.conflictStrategy == store.ConflictAsk {
+			fmt.Fprintf(m.out, "    Warning: %s conflicted copy: %s (resolve with 'configsync store conflicts --resolve')\n", conflict.Provider, rel)
+			continue
+		}
+		if m.dryRun {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would resolve conflicted copy (%s): %s\n", m.conflictStrategy, rel)
+			continue
+		}
+		if _, err := store.ResolveConflict(conflict, m.conflictStrategy, filepath.Join(m.backupDir, ConflictArchiveDir)); err != nil {
+			return fmt.Errorf("failed to resolve conflicted copy %s: %w", rel, err)
+		}
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Resolved conflicted copy (%s): %s\n", m.conflictStrategy, rel)
+		}
+	}
+
+	return nil
+}
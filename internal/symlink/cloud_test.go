@@ -0,0 +1,79 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/store"
+)
+
+// newCloudTestManager creates a manager whose store is in a Dropbox folder holding a synced
+// dotfile and a conflicted copy of it
+func newCloudTestManager(t *testing.T) (*Manager, *config.AppConfig, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "Dropbox", "configsync")
+
+	storeFile := filepath.Join(storeDir, ".testrc")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, ".testrc (Other Mac's conflicted copy 2024-01-02)"), []byte("other mac"), 0644); err != nil {
+		t.Fatalf("Failed to write conflicted copy: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(filepath.Join(tempDir, ".testrc"), ".testrc", config.PathTypeFile, true)
+
+	return manager, appConfig, storeFile
+}
+
+func TestSyncAppReportsCloudConflicts(t *testing.T) {
+	manager, appConfig, storeFile := newCloudTestManager(t)
+	var out bytes.Buffer
+	manager.out = &out
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Dropbox conflicted copy") {
+		t.Errorf("Expected conflict warning, got %q", out.String())
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Error("Expected store file to be left alone when only reporting conflicts")
+	}
+}
+
+func TestSyncAppResolvesCloudConflicts(t *testing.T) {
+	manager, appConfig, storeFile := newCloudTestManager(t)
+	manager.SetConflictStrategy(store.ConflictKeepCopy)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(storeFile); string(data) != "other mac" {
+		t.Errorf("Expected conflicted copy to replace the store file, got %q", data)
+	}
+	conflicts, err := store.FindConflicts(manager.storeDir, manager.storeDir)
+	if err != nil || len(conflicts) != 0 {
+		t.Errorf("Expected conflicts to be resolved, got %+v (%v)", conflicts, err)
+	}
+	archived, _ := filepath.Glob(filepath.Join(manager.backupDir, ConflictArchiveDir, "*", ".testrc"))
+	if len(archived) != 1 {
+		t.Errorf("Expected replaced store file to be archived, got %v", archived)
+	}
+	if !isLink(t, filepath.Join(manager.homeDir, ".testrc")) {
+		t.Error("Expected path to be synced")
+	}
+}
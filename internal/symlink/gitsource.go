@@ -0,0 +1,221 @@
+package symlink
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/gitsource"
+)
+
+// SetGitSources sets the manager of the checkouts that paths with a repository are synced from.
+// Syncing such a path fails without one.
+func (m *Manager) SetGitSources(sources *gitsource.Manager) {
+	m.gitSources = sources
+}
+
+// GitSourceUpdate describes the update of a path's store copy from its repository
+type GitSourceUpdate struct {
+	Repo     string // Repository and directory the path is checked out from
+	Revision string // Commit the store copy matches after the update
+	Pulled   int    // Upstream commits applied; zero when the checkout was up to date
+}
+
+// syncGitSource puts the checkout of a path's repository in the store when it has no copy
+// there yet. Whatever is at the path's source is backed up and replaced, as the repository's
+// version of the configuration wins; the symlink to the store copy is then made as for any path.
+func (m *Manager) syncGitSource(appConfig *config.AppConfig, path *config.Path) error {
+	repo, err := m.parseRepo(path)
+	if err != nil {
+		return err
+	}
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Checking out: %s -> %s\n", repo, storePath)
+	}
+
+	if !m.pathExists(storePath) {
+		if m.dryRun {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would check out %s into %s\n", repo, storePath)
+		} else {
+			checkout, err := m.gitSources.Checkout(repo, path.Ref)
+			if err != nil {
+				return err
+			}
+			if err := m.replaceStoreCopy(checkout, storePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !m.pathExists(sourcePath) || m.isSymlink(sourcePath) {
+		return nil
+	}
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would replace %s with the checkout of %s\n", sourcePath, repo)
+		return nil
+	}
+	fmt.Fprintf(m.out, "    Replacing %s with the checkout of %s\n", sourcePath, repo)
+	if err := m.backupBefore(appConfig, path); err != nil {
+		return fmt.Errorf("failed to back up %s before replacing it: %w", sourcePath, err)
+	}
+	path.MarkBackedUp()
+	if err := m.fs.RemoveAll(sourcePath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", sourcePath, err)
+	}
+	return nil
+}
+
+// UpdateGitSource pulls the upstream changes of a path's repository into its store copy. A store
+// copy changed since it was checked out is kept, with an error, unless force is set; changes are
+// made in the repository, not the store.
+func (m *Manager) UpdateGitSource(path *config.Path, force bool) (*GitSourceUpdate, error) {
+	repo, err := m.parseRepo(path)
+	if err != nil {
+		return nil, err
+	}
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	update := &GitSourceUpdate{Repo: repo.String()}
+
+	checkout, err := m.gitSources.Checkout(repo, path.Ref)
+	if err != nil {
+		return nil, err
+	}
+	storeExists := m.pathExists(storePath)
+	if storeExists && !force {
+		same, err := m.sameTree(checkout, storePath)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return nil, fmt.Errorf("%s has local changes; make them in %s, or use --force to discard them", storePath, repo)
+		}
+	}
+
+	if update.Pulled, err = m.gitSources.Fetch(repo, path.Ref); err != nil {
+		return nil, err
+	}
+	if m.dryRun {
+		if update.Pulled > 0 {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would pull %d commit(s) of %s into %s\n", update.Pulled, repo, storePath)
+		}
+	} else {
+		if update.Pulled > 0 {
+			if err := m.gitSources.Pull(repo, path.Ref); err != nil {
+				return nil, err
+			}
+		}
+		if update.Pulled > 0 || force || !storeExists {
+			if err := m.replaceStoreCopy(checkout, storePath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if update.Revision, err = m.gitSources.Revision(repo, path.Ref); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+// parseRepo returns the repository a path is checked out from. Local repositories may be given
+// relative to the home directory.
+func (m *Manager) parseRepo(path *config.Path) (*gitsource.Repo, error) {
+	if m.gitSources == nil {
+		return nil, fmt.Errorf("%s is checked out from a repository, but git sources are not set up", path.Source)
+	}
+	repo, err := gitsource.Parse(path.Repo)
+	if err != nil {
+		return nil, err
+	}
+	repo.URL = fsops.ExpandHome(repo.URL, m.homeDir)
+	return repo, nil
+}
+
+// replaceStoreCopy replaces a store copy with the files of a checkout, leaving out its git
+// metadata. The copy is made next to the store copy first, so a failed copy keeps the old one.
+func (m *Manager) replaceStoreCopy(checkout, storePath string) error {
+	if err := m.fs.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	tempPath := storePath + ".checkout"
+	if err := m.fs.RemoveAll(tempPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", tempPath, err)
+	}
+	if err := fsops.CopyTree(m.ctx, m.fs, checkout, tempPath, skipGitMetadata); err != nil {
+		_ = m.fs.RemoveAll(tempPath)
+		return fmt.Errorf("failed to copy checkout to the store: %w", err)
+	}
+	if err := m.fs.RemoveAll(storePath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", storePath, err)
+	}
+	if err := m.fs.Rename(tempPath, storePath); err != nil {
+		return fmt.Errorf("failed to move checkout into the store: %w", err)
+	}
+	return nil
+}
+
+// skipGitMetadata leaves the .git directory of a checkout out of a copy
+func skipGitMetadata(rel string, info fs.FileInfo) bool {
+	return rel == ".git"
+}
+
+// sameTree reports whether a store copy has the same files as a checkout, ignoring its git metadata
+func (m *Manager) sameTree(checkout, storePath string) (bool, error) {
+	want, err := m.treeContents(checkout)
+	if err != nil {
+		return false, err
+	}
+	have, err := m.treeContents(storePath)
+	if err != nil {
+		return false, err
+	}
+	if len(want) != len(have) {
+		return false, nil
+	}
+	for relPath, content := range want {
+		if other, exists := have[relPath]; !exists || !bytes.Equal(content, other) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// treeContents returns the content of the files in a directory, and the targets of its symlinks,
+// by their paths relative to it
+func (m *Manager) treeContents(dir string) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	err := fsys.Walk(m.fs, dir, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, current)
+		if err != nil || relPath == "." {
+			return err
+		}
+		switch {
+		case skipGitMetadata(filepath.ToSlash(relPath), info):
+			return filepath.SkipDir
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := m.fs.Readlink(current)
+			contents[relPath] = []byte("-> " + target)
+			return err
+		case info.Mode().IsRegular():
+			content, err := m.fs.ReadFile(current)
+			contents[relPath] = content
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	return contents, nil
+}
@@ -0,0 +1,113 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/gitsource"
+)
+
+// commitFile writes a file to a git repository, creating the repository when needed, and commits it
+func commitFile(t *testing.T, repoDir, relPath, content string) {
+	t.Helper()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+		if _, err := gitsource.RunCommand(repoDir, "git", args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(repoDir, 0755); err != nil {
+			t.Fatalf("Failed to create repository: %v", err)
+		}
+		git("init", "--quiet")
+	}
+	path := filepath.Join(repoDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", relPath, err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "Update "+relPath)
+}
+
+func TestSyncGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tempDir := t.TempDir()
+	upstream := filepath.Join(tempDir, "dotfiles")
+	commitFile(t, upstream, "nvim/init.lua", "set number")
+	commitFile(t, upstream, "zsh/.zshrc", "export EDITOR=nvim")
+
+	sourceDir := filepath.Join(tempDir, ".config", "nvim")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "init.vim"), []byte("set nonumber"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	storeDir := filepath.Join(tempDir, "store")
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceDir, "nvim", config.PathTypeDirectory, false)
+	appConfig.Paths[0].Repo = upstream + "//nvim"
+
+	if err := manager.SyncApp(appConfig); err == nil || !strings.Contains(err.Error(), "git sources") {
+		t.Fatalf("Expected an error without git sources, got %v", err)
+	}
+
+	manager.SetGitSources(gitsource.NewManager(filepath.Join(tempDir, gitsource.DefaultDir), gitsource.RunCommand))
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if !manager.isCorrectSymlink(sourceDir, filepath.Join(storeDir, "nvim")) {
+		t.Fatal("Expected the source to link to the store copy")
+	}
+	if data, err := os.ReadFile(filepath.Join(sourceDir, "init.lua")); err != nil || string(data) != "set number" {
+		t.Errorf("Expected the repository's configuration, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "nvim", ".git")); !os.IsNotExist(err) {
+		t.Errorf("Expected the store copy to leave out the git metadata, got %v", err)
+	}
+	if !appConfig.Paths[0].BackedUp {
+		t.Error("Expected the replaced configuration to be backed up")
+	}
+
+	commitFile(t, upstream, "nvim/init.lua", "set relativenumber")
+	update, err := manager.UpdateGitSource(&appConfig.Paths[0], false)
+	if err != nil {
+		t.Fatalf("UpdateGitSource failed: %v", err)
+	}
+	if update.Pulled != 1 || update.Revision == "" {
+		t.Errorf("Expected one commit to be pulled, got %+v", update)
+	}
+	if data, _ := os.ReadFile(filepath.Join(sourceDir, "init.lua")); string(data) != "set relativenumber" {
+		t.Errorf("Expected the upstream change in the store copy, got %q", data)
+	}
+
+	// Changes made in the store instead of the repository are not overwritten without force
+	if err := os.WriteFile(filepath.Join(sourceDir, "init.lua"), []byte("set list"), 0644); err != nil {
+		t.Fatalf("Failed to change store copy: %v", err)
+	}
+	if _, err := manager.UpdateGitSource(&appConfig.Paths[0], false); err == nil || !strings.Contains(err.Error(), "local changes") {
+		t.Errorf("Expected local changes to be refused, got %v", err)
+	}
+	if _, err := manager.UpdateGitSource(&appConfig.Paths[0], true); err != nil {
+		t.Fatalf("UpdateGitSource with force failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(sourceDir, "init.lua")); string(data) != "set relativenumber" {
+		t.Errorf("Expected force to restore the repository's version, got %q", data)
+	}
+}
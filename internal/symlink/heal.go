@@ -0,0 +1,73 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// SetHeal sets whether syncing re-absorbs paths whose symlink an application replaced with a
+// regular file. Without it, such paths fail to sync so the store copy is not silently overwritten.
+func (m *Manager) SetHeal(heal bool) {
+	m.heal = heal
+}
+
+// IsReplaced reports whether a synced path's symlink has been replaced by a regular file or
+// directory, as applications that save their settings atomically do, orphaning the store copy
+func IsReplaced(sourcePath, storePath string, path *config.Path) bool {
+	return isReplaced(fsys.OS, sourcePath, storePath, path)
+}
+
+// isReplaced implements IsReplaced on a file system
+func isReplaced(files fsys.FS, sourcePath, storePath string, path *config.Path) bool {
+	if !path.Synced || path.IsCopyMode() || path.IsSecret() {
+		return false
+	}
+
+	if _, err := files.Lstat(sourcePath); err != nil || fsys.IsSymlink(files, sourcePath) {
+		return false
+	}
+	return fsys.Exists(files, storePath)
+}
+
+// healPath moves the file an application wrote in place of its symlink into the store and
+// relinks it. The orphaned store copy is archived in the backup directory rather than deleted.
+func (m *Manager) healPath(sourcePath, storePath string, path *config.Path) error {
+	if !m.heal {
+		return fmt.Errorf("symlink was replaced with a regular file; run 'configsync sync --heal' to move it into the store")
+	}
+	return m.absorbReplaced(sourcePath, storePath, path)
+}
+
+// absorbReplaced moves a replaced path into the store, archiving the store copy it replaced
+func (m *Manager) absorbReplaced(sourcePath, storePath string, path *config.Path) error {
+	if err := m.checkDirectorySize(sourcePath, nil); err != nil {
+		return err
+	}
+
+	archivePath := filepath.Join(m.backupDir, ReplacedArchiveDir, time.Now().Format("20060102-150405"), path.Destination)
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would archive replaced store copy: %s -> %s\n", storePath, archivePath)
+		fmt.Fprintf(m.out, "    [DRY RUN] Would move: %s -> %s\n", sourcePath, storePath)
+		return m.createFinalSymlink(sourcePath, storePath)
+	}
+
+	if err := m.fs.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := m.fs.Rename(storePath, archivePath); err != nil {
+		return fmt.Errorf("failed to archive replaced store copy: %w", err)
+	}
+
+	if err := m.moveToStore(sourcePath, storePath); err != nil {
+		// Put the store copy back so the path is left as it was found
+		_ = m.fs.Rename(archivePath, storePath)
+		return fmt.Errorf("failed to move to store: %w", err)
+	}
+
+	fmt.Fprintf(m.out, "    Healed %s: replaced symlink moved into the store (previous copy archived at %s)\n", path.Source, archivePath)
+	return m.createFinalSymlink(sourcePath, storePath)
+}
@@ -0,0 +1,149 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+// newReplacedTestManager syncs a dotfile and then replaces its symlink with a regular file,
+// as an application saving its settings atomically would
+func newReplacedTestManager(t *testing.T) (*Manager, *config.AppConfig, string, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	sourceFile := filepath.Join(tempDir, ".testrc")
+
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, ".testrc", config.PathTypeFile, true)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if err := os.Remove(sourceFile); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.WriteFile(sourceFile, []byte("rewritten by app"), 0644); err != nil {
+		t.Fatalf("Failed to replace symlink: %v", err)
+	}
+
+	return manager, appConfig, sourceFile, filepath.Join(storeDir, ".testrc")
+}
+
+func TestIsReplaced(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+	path := &appConfig.Paths[0]
+
+	if !IsReplaced(sourceFile, storeFile, path) {
+		t.Error("Expected a regular file over a synced path to be reported as replaced")
+	}
+
+	neverSynced := *path
+	neverSynced.Synced = false
+	if IsReplaced(sourceFile, storeFile, &neverSynced) {
+		t.Error("Expected a path that was never synced not to be reported as replaced")
+	}
+
+	manager.SetHeal(true)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if IsReplaced(sourceFile, storeFile, path) {
+		t.Error("Expected a relinked path not to be reported as replaced")
+	}
+}
+
+func TestSyncAppRefusesReplacedSymlink(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+
+	err := manager.SyncApp(appConfig)
+	if err == nil || !strings.Contains(err.Error(), "--heal") {
+		t.Fatalf("Expected an error suggesting --heal, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Error("Expected the store copy to be left alone without --heal")
+	}
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected the replaced file to be left alone without --heal")
+	}
+}
+
+func TestSyncAppHealsReplacedSymlink(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+	manager.SetHeal(true)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if !manager.isCorrectSymlink(sourceFile, storeFile) {
+		t.Error("Expected the path to be relinked to the store")
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "rewritten by app" {
+		t.Errorf("Expected the store to hold the file written by the app, got %q", data)
+	}
+
+	archived, _ := filepath.Glob(filepath.Join(manager.backupDir, ReplacedArchiveDir, "*", ".testrc"))
+	if len(archived) != 1 {
+		t.Fatalf("Expected the previous store copy to be archived, found %v", archived)
+	}
+	if data, _ := os.ReadFile(archived[0]); string(data) != constants.TestConfiguration {
+		t.Error("Expected the archive to hold the previous store copy")
+	}
+}
+
+func TestSyncAppHealDryRun(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+	manager.SetHeal(true)
+	manager.dryRun = true
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected dry run not to relink the path")
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Error("Expected dry run not to change the store copy")
+	}
+}
+
+func TestUnsyncAppMarksPathsUnsynced(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceFile := filepath.Join(tempDir, ".testrc")
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, ".testrc", config.PathTypeFile, true)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if err := manager.UnsyncApp(appConfig); err != nil {
+		t.Fatalf("UnsyncApp failed: %v", err)
+	}
+
+	if appConfig.Paths[0].Synced {
+		t.Error("Expected unsynced path not to be marked synced")
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Errorf("Expected an unsynced path to sync again without --heal, got %v", err)
+	}
+}
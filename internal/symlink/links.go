@@ -0,0 +1,120 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// LinkArchiveDir is the backup subdirectory that files found at a path's links are moved to when
+// they are replaced by a symlink to the store copy
+const LinkArchiveDir = "links"
+
+// adoptLink moves the file at one of a path's links into the store when neither the path's source
+// nor its store copy exists, so an application that only has its legacy location is still synced
+func (m *Manager) adoptLink(appConfig *config.AppConfig, path *config.Path) error {
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	if m.pathExists(sourcePath) || m.isSymlink(sourcePath) || m.pathExists(storePath) {
+		return nil
+	}
+
+	for _, link := range path.Links {
+		linkPath := m.expandPath(link)
+		if !m.pathExists(linkPath) || m.isSymlink(linkPath) {
+			continue
+		}
+
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Adopting %s, since %s does not exist\n", linkPath, sourcePath)
+		}
+		if err := m.ensureStoreDirectory(storePath); err != nil {
+			return err
+		}
+		adopted := *path
+		adopted.Source = link
+		if err := m.moveSourceToStore(appConfig, linkPath, storePath, &adopted); err != nil {
+			return err
+		}
+		path.BackedUp = path.BackedUp || adopted.BackedUp
+		return nil
+	}
+	return nil
+}
+
+// syncLinks points each of a path's links at its store copy. Files already at a link are archived
+// in the backup directory first, since the store copy takes their place.
+func (m *Manager) syncLinks(path *config.Path) error {
+	if len(path.Links) == 0 {
+		return nil
+	}
+
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	if !m.pathExists(storePath) && !(m.dryRun && m.pathExists(m.expandPath(path.Source))) {
+		return nil
+	}
+
+	var errors []string
+	for _, link := range path.Links {
+		if err := m.syncLink(m.expandPath(link), storePath, path); err != nil {
+			errors = append(errors, fmt.Sprintf("link %s: %v", link, err))
+		}
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "\n"))
+	}
+	return nil
+}
+
+// syncLink points a single link at the store copy
+func (m *Manager) syncLink(linkPath, storePath string, path *config.Path) error {
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Linking: %s -> %s\n", linkPath, storePath)
+	}
+
+	if m.isCorrectSymlink(linkPath, storePath) {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Already linked correctly\n")
+		}
+		return nil
+	}
+
+	if m.isSymlink(linkPath) {
+		if err := m.removeExistingSymlink(linkPath); err != nil {
+			return err
+		}
+	} else if m.pathExists(linkPath) {
+		if err := m.archiveLink(linkPath, path); err != nil {
+			return err
+		}
+	}
+
+	return m.createFinalSymlink(linkPath, storePath)
+}
+
+// archiveLink moves the file or directory at a link into the backup directory
+func (m *Manager) archiveLink(linkPath string, path *config.Path) error {
+	return m.archiveTo(LinkArchiveDir, linkPath, path.Destination)
+}
+
+// archiveTo moves a file or directory about to be replaced by a symlink to the store into a
+// timestamped subdirectory of the backup directory
+func (m *Manager) archiveTo(archiveDir, location, destination string) error {
+	archivePath := filepath.Join(m.backupDir, archiveDir, time.Now().Format("20060102-150405"), destination)
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would archive: %s -> %s\n", location, archivePath)
+		return nil
+	}
+
+	if err := m.fs.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := m.fs.Rename(location, archivePath); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", location, err)
+	}
+	fmt.Fprintf(m.out, "    Archived %s at %s before linking it to the store\n", location, archivePath)
+	return nil
+}
@@ -0,0 +1,119 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+// newLinkedTestManager configures a dotfile with a legacy location linked to the same store copy
+func newLinkedTestManager(t *testing.T) (*Manager, *config.AppConfig, string, string, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	sourceFile := filepath.Join(tempDir, ".config", "testapp", "config")
+	legacyFile := filepath.Join(tempDir, ".testrc")
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, "testapp/config", config.PathTypeFile, false)
+	appConfig.Paths[0].Links = []string{"~/.testrc"}
+
+	return manager, appConfig, sourceFile, legacyFile, filepath.Join(storeDir, "testapp", "config")
+}
+
+func TestSyncAppLinksEveryLocation(t *testing.T) {
+	manager, appConfig, sourceFile, legacyFile, storeFile := newLinkedTestManager(t)
+	writeTestFile(t, sourceFile, constants.TestConfiguration)
+	writeTestFile(t, legacyFile, "legacy settings")
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	for _, location := range []string{sourceFile, legacyFile} {
+		if !manager.isCorrectSymlink(location, storeFile) {
+			t.Errorf("Expected %s to link to the store copy", location)
+		}
+	}
+	if data, _ := os.ReadFile(legacyFile); string(data) != constants.TestConfiguration {
+		t.Errorf("Expected the legacy location to show the store copy, got %q", data)
+	}
+	archived, _ := filepath.Glob(filepath.Join(manager.backupDir, LinkArchiveDir, "*", "testapp", "config"))
+	if len(archived) != 1 {
+		t.Fatalf("Expected the legacy file to be archived, got %v", archived)
+	}
+	if data, _ := os.ReadFile(archived[0]); string(data) != "legacy settings" {
+		t.Errorf("Expected the archive to hold the legacy file, got %q", data)
+	}
+
+	checks := manager.VerifyLinks(appConfig)
+	if len(checks) != 2 || checks[0].State != LinkCorrect || checks[1].State != LinkCorrect || checks[1].Source != legacyFile {
+		t.Errorf("Expected both locations to verify, got %+v", checks)
+	}
+
+	if err := manager.UnsyncApp(appConfig); err != nil {
+		t.Fatalf("UnsyncApp failed: %v", err)
+	}
+	for _, location := range []string{sourceFile, legacyFile} {
+		if manager.isSymlink(location) {
+			t.Errorf("Expected %s to be a regular file after unsyncing", location)
+		}
+		if data, _ := os.ReadFile(location); string(data) != constants.TestConfiguration {
+			t.Errorf("Expected %s to hold the store copy, got %q", location, data)
+		}
+	}
+}
+
+func TestSyncAppAdoptsLink(t *testing.T) {
+	manager, appConfig, sourceFile, legacyFile, storeFile := newLinkedTestManager(t)
+	writeTestFile(t, legacyFile, "legacy settings")
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "legacy settings" {
+		t.Errorf("Expected the legacy file to be moved into the store, got %q", data)
+	}
+	for _, location := range []string{sourceFile, legacyFile} {
+		if !manager.isCorrectSymlink(location, storeFile) {
+			t.Errorf("Expected %s to link to the store copy", location)
+		}
+	}
+}
+
+func TestVerifyLinksReportsMissingLink(t *testing.T) {
+	manager, appConfig, sourceFile, legacyFile, _ := newLinkedTestManager(t)
+	writeTestFile(t, sourceFile, constants.TestConfiguration)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if err := os.Remove(legacyFile); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+
+	checks := manager.VerifyLinks(appConfig)
+	if len(checks) != 2 || checks[1].State != LinkMissing || checks[1].Repair != RepairRelink {
+		t.Fatalf("Expected the removed link to be missing, got %+v", checks)
+	}
+	if err := manager.RepairLink(appConfig, checks[1]); err != nil {
+		t.Fatalf("RepairLink failed: %v", err)
+	}
+	if !manager.isSymlink(legacyFile) {
+		t.Error("Expected the link to be recreated")
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,93 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/fsops"
+)
+
+// SetReadOnly sets whether the store is locked down. Syncing then only links or copies the
+// store copies of paths into place: local changes are reported as drift instead of being moved
+// or copied into the store, and paths whose store copy is written by configsync itself, such
+// as defaults domains, plugin settings, secrets, and git sources, are left alone.
+func (m *Manager) SetReadOnly(readOnly bool) {
+	m.readOnly = readOnly
+}
+
+// syncLockedPath syncs a path without changing its store copy, returning an error naming the
+// locations that drifted from it
+func (m *Manager) syncLockedPath(appConfig *config.AppConfig, path *config.Path) error {
+	if path.IsExported() || path.IsSecret() || path.Repo != "" {
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Skipping %s: the store is locked down\n", path.Source)
+		}
+		return nil
+	}
+
+	path = m.adaptToSandbox(appConfig, path)
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Checking: %s -> %s\n", sourcePath, storePath)
+	}
+
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
+	}
+	if !m.pathExists(storePath) {
+		if !m.pathExists(sourcePath) {
+			return m.handleMissingPath(sourcePath, path)
+		}
+		m.reportDrift(appConfig, path, []string{sourcePath})
+		return fmt.Errorf("%s is not in the store, which is locked down, so it was not added", sourcePath)
+	}
+
+	locations := path.LiveLocations()
+	if path.IsCopyMode() {
+		locations = []string{path.Source}
+	}
+	var drifted []string
+	for _, location := range locations {
+		locationPath := m.expandPath(location)
+		switch {
+		case m.isCorrectSymlink(locationPath, storePath):
+			continue
+		case !m.pathExists(locationPath) && !m.isSymlink(locationPath):
+			var err error
+			if path.IsCopyMode() {
+				err = m.copyBetween(storePath, locationPath)
+			} else {
+				err = m.createFinalSymlink(locationPath, storePath)
+			}
+			if err != nil {
+				return err
+			}
+		case path.IsCopyMode() && fsops.SameTree(m.ctx, m.fs, storePath, locationPath):
+			continue
+		default:
+			drifted = append(drifted, locationPath)
+		}
+	}
+
+	if len(drifted) == 0 {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Matches the store\n")
+		}
+		return nil
+	}
+	m.reportDrift(appConfig, path, drifted)
+	return fmt.Errorf("%s changed locally; the store is locked down, so the change was not synced into it", strings.Join(drifted, ", "))
+}
+
+// reportDrift emits a conflict event for locations that differ from a locked-down store
+func (m *Manager) reportDrift(appConfig *config.AppConfig, path *config.Path, locations []string) {
+	m.events.Emit(events.ConflictDetected, appConfig.Name, map[string]interface{}{
+		"kind":      "drift",
+		"path":      path.Source,
+		"locations": locations,
+	})
+}
@@ -0,0 +1,80 @@
+package symlink
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+func TestSyncAppReadOnlyReportsDrift(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+	manager.SetHeal(true)
+	manager.SetReadOnly(true)
+
+	err := manager.SyncApp(appConfig)
+	if err == nil || !strings.Contains(err.Error(), "locked down") {
+		t.Fatalf("Expected the local change to be reported as drift, got %v", err)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Error("Expected the locked-down store copy to be left alone, even with --heal")
+	}
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected the drifted file to be left alone")
+	}
+
+	// A path missing locally is linked to the store again
+	if err := os.Remove(sourceFile); err != nil {
+		t.Fatalf("Failed to remove drifted file: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if !manager.isCorrectSymlink(sourceFile, storeFile) {
+		t.Error("Expected the missing path to be linked to the store")
+	}
+}
+
+func TestSyncAppReadOnlyCopyMode(t *testing.T) {
+	manager, appConfig, sourceFile, storeFile := newReplacedTestManager(t)
+	appConfig.Paths[0].Mode = config.PathModeCopy
+	manager.SetReadOnly(true)
+
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("Expected a copy matching the store not to drift, got %v", err)
+	}
+
+	if err := os.WriteFile(sourceFile, []byte("changed locally"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err == nil {
+		t.Error("Expected a changed copy to be reported as drift")
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Error("Expected the locked-down store copy to be left alone")
+	}
+}
+
+func TestSyncAppReadOnlyDoesNotAddPaths(t *testing.T) {
+	manager, appConfig, _, _ := newReplacedTestManager(t)
+	manager.SetReadOnly(true)
+
+	newFile := filepath.Join(manager.homeDir, ".newrc")
+	if err := os.WriteFile(newFile, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	appConfig.Paths = []config.Path{{Source: newFile, Destination: ".newrc", Type: config.PathTypeFile}}
+
+	if err := manager.SyncApp(appConfig); err == nil {
+		t.Error("Expected a path missing from the locked-down store to be reported")
+	}
+	if manager.pathExists(filepath.Join(manager.storeDir, ".newrc")) {
+		t.Error("Expected the path not to be moved into the locked-down store")
+	}
+}
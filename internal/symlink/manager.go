@@ -2,55 +2,184 @@
 package symlink
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dotbrains/configsync/internal/backup"
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/defaults"
+	"github.com/dotbrains/configsync/internal/events"
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/ignore"
+	"github.com/dotbrains/configsync/internal/manifest"
+	"github.com/dotbrains/configsync/internal/plugins"
+	"github.com/dotbrains/configsync/internal/secrets"
+	"github.com/dotbrains/configsync/internal/store"
 )
 
+// ConflictArchiveDir is the backup subdirectory that conflicted copies are moved to when resolved
+const ConflictArchiveDir = "conflicts"
+
+// ReplacedArchiveDir is the backup subdirectory that orphaned store copies are moved to when healing
+const ReplacedArchiveDir = "replaced"
+
 // Manager handles symlink operations
 type Manager struct {
-	backupManager *backup.Manager
-	homeDir       string
-	storeDir      string
-	backupDir     string
-	dryRun        bool
-	verbose       bool
+	ctx                context.Context
+	fs                 fsys.FS
+	out                io.Writer
+	backupManager      *backup.Manager
+	events             *events.Emitter
+	defaultsManager    *defaults.Manager
+	host               *config.HostOverride
+	confirmLarge       func(path string, size int64) bool
+	confirmMu          *sync.Mutex
+	runShell           func(command string) ([]byte, error)
+	secretBackend      func(name string) (secrets.Backend, error)
+	plugin             func(name string) (*plugins.Plugin, error)
+	gitSources         *gitsource.Manager
+	homeDir            string
+	storeDir           string
+	backupDir          string
+	cloudProvider      string
+	conflictStrategy   string
+	maxDirSize         int64
+	materializeTimeout time.Duration
+	dryRun             bool
+	verbose            bool
+	heal               bool
+	includeCaches      bool
+	autoBackup         bool
+	readOnly           bool
 }
 
 // NewManager creates a new symlink manager
 func NewManager(homeDir, storeDir, backupDir string, dryRun, verbose bool) *Manager {
 	return &Manager{
-		homeDir:       homeDir,
-		storeDir:      storeDir,
-		backupDir:     backupDir,
-		dryRun:        dryRun,
-		verbose:       verbose,
-		backupManager: backup.NewManager(backupDir, homeDir, verbose),
+		ctx:                context.Background(),
+		fs:                 fsys.OS,
+		out:                os.Stdout,
+		confirmMu:          &sync.Mutex{},
+		homeDir:            homeDir,
+		storeDir:           storeDir,
+		backupDir:          backupDir,
+		maxDirSize:         config.DefaultMaxDirectorySize,
+		cloudProvider:      store.CloudProvider(storeDir),
+		materializeTimeout: store.DefaultMaterializeTimeout,
+		dryRun:             dryRun,
+		verbose:            verbose,
+		autoBackup:         true,
+		backupManager:      backup.NewManager(backupDir, homeDir, verbose),
+		defaultsManager:    defaults.NewManager(verbose),
+		runShell: func(command string) ([]byte, error) {
+			return exec.Command("sh", "-c", command).CombinedOutput()
+		},
+		secretBackend: func(name string) (secrets.Backend, error) {
+			return secrets.New(name, secrets.RunCommand)
+		},
 	}
 }
 
+// SetHost sets the overrides of the host being synced, whose path substitutions are applied to
+// source paths and links
+func (m *Manager) SetHost(host *config.HostOverride) {
+	m.host = host
+}
+
+// SetContext makes syncing stop with the context's error once ctx is canceled or times out.
+// Paths not reached yet are left as they are, and backups in progress are removed.
+func (m *Manager) SetContext(ctx context.Context) {
+	m.ctx = ctx
+	m.backupManager = m.backupManager.WithContext(ctx)
+}
+
+// SetFS makes the manager, and the backups it takes, work on files instead of the operating
+// system's file system
+func (m *Manager) SetFS(files fsys.FS) {
+	m.fs = files
+	m.backupManager = m.backupManager.WithFS(files)
+}
+
+// SetBackupCompression sets the compression of the backups taken before paths are moved into the
+// store; see backup.Manager.WithCompression
+func (m *Manager) SetBackupCompression(compression string) {
+	m.backupManager = m.backupManager.WithCompression(compression)
+}
+
+// SetAutoBackup sets whether paths are backed up before sync moves, replaces, or overwrites them.
+// Applications can also turn their backups off with BackupBefore.
+func (m *Manager) SetAutoBackup(enabled bool) {
+	m.autoBackup = enabled
+}
+
+// CreatedBackups returns the backups taken by the syncs so far
+func (m *Manager) CreatedBackups() []*config.BackupInfo {
+	return m.backupManager.Created()
+}
+
+// backupBefore backs up a path before sync moves, replaces, or overwrites it, unless backups are
+// turned off for its application or altogether. The backup is a full copy, ignored entries
+// included, so it can always bring back what was on disk.
+func (m *Manager) backupBefore(appConfig *config.AppConfig, path *config.Path) error {
+	if !m.autoBackup || !appConfig.BackupBefore {
+		return nil
+	}
+	return m.backupManager.BackupPath(appConfig.Name, path)
+}
+
+// SetIncludeCaches sets whether the common cache and log directories are moved into the store and
+// backed up like any other entry instead of being ignored
+func (m *Manager) SetIncludeCaches(include bool) {
+	m.includeCaches = include
+}
+
+// SetDirectorySizeLimit sets the size above which a directory is only moved into the store
+// if confirm returns true. A limit of zero or less disables the check.
+func (m *Manager) SetDirectorySizeLimit(limit int64, confirm func(path string, size int64) bool) {
+	m.maxDirSize = limit
+	m.confirmLarge = confirm
+}
+
 // SyncApp creates symlinks for all paths in an application configuration
 func (m *Manager) SyncApp(appConfig *config.AppConfig) error {
 	if !appConfig.IsEnabled() {
 		if m.verbose {
-			fmt.Printf("Skipping disabled app: %s\n", appConfig.DisplayName)
+			fmt.Fprintf(m.out, "Skipping disabled app: %s\n", appConfig.DisplayName)
 		}
 		return nil
 	}
 
 	if m.verbose {
-		fmt.Printf("Syncing %s...\n", appConfig.DisplayName)
+		fmt.Fprintf(m.out, "Syncing %s...\n", appConfig.DisplayName)
 	}
 
 	var errors []string
 	for i := range appConfig.Paths {
 		path := &appConfig.Paths[i]
 
-		if err := m.syncPath(path); err != nil {
+		if err := m.ctx.Err(); err != nil {
+			return fmt.Errorf("syncing %s interrupted: %w", appConfig.DisplayName, err)
+		}
+
+		if !path.AppliesTo(config.CurrentPlatform) {
+			if m.verbose {
+				fmt.Fprintf(m.out, "  Skipping %s (not used on %s)\n", path.Source, config.CurrentPlatform)
+			}
+			continue
+		}
+
+		if err := m.syncAppPath(appConfig, path); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", path.Source, err))
 			continue
 		}
@@ -63,24 +192,57 @@ func (m *Manager) SyncApp(appConfig *config.AppConfig) error {
 	if len(errors) > 0 {
 		return fmt.Errorf("errors syncing %s:\n%s", appConfig.DisplayName, strings.Join(errors, "\n"))
 	}
+	if !m.dryRun {
+		appConfig.LastSynced = time.Now()
+	}
+
+	m.runPostSync(appConfig)
 
 	return nil
 }
 
+// runPostSync runs an app's post-sync reload commands. Failures are reported but not fatal,
+// since the app may simply not be running.
+func (m *Manager) runPostSync(appConfig *config.AppConfig) {
+	for _, command := range appConfig.PostSync {
+		if m.dryRun {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would run: %s\n", command)
+			continue
+		}
+
+		if m.verbose {
+			fmt.Fprintf(m.out, "  Running post-sync: %s\n", command)
+		}
+		if output, err := m.runShell(command); err != nil && m.verbose {
+			fmt.Fprintf(m.out, "    Warning: post-sync command failed: %v: %s\n", err, strings.TrimSpace(string(output)))
+		}
+	}
+}
+
 // UnsyncApp removes symlinks for all paths in an application configuration
 func (m *Manager) UnsyncApp(appConfig *config.AppConfig) error {
 	if m.verbose {
-		fmt.Printf("Unsyncing %s...\n", appConfig.DisplayName)
+		fmt.Fprintf(m.out, "Unsyncing %s...\n", appConfig.DisplayName)
 	}
 
 	var errors []string
 	for i := range appConfig.Paths {
 		path := &appConfig.Paths[i]
+		if err := m.ctx.Err(); err != nil {
+			return fmt.Errorf("unsyncing %s interrupted: %w", appConfig.DisplayName, err)
+		}
+		if !path.AppliesTo(config.CurrentPlatform) {
+			continue
+		}
 
-		if err := m.unsyncPath(path); err != nil {
+		if err := m.unsyncAppPath(appConfig, path); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", path.Source, err))
 			continue
 		}
+
+		if !m.dryRun {
+			path.MarkUnsynced()
+		}
 	}
 
 	if len(errors) > 0 {
@@ -90,27 +252,153 @@ func (m *Manager) UnsyncApp(appConfig *config.AppConfig) error {
 	return nil
 }
 
+// syncAppPath syncs a single path using the strategy for its path type
+func (m *Manager) syncAppPath(appConfig *config.AppConfig, path *config.Path) error {
+	if m.readOnly {
+		return m.syncLockedPath(appConfig, path)
+	}
+	if path.Type == config.PathTypeDefaults {
+		return m.syncDefaultsPath(appConfig.PreferencesDomain(), path)
+	}
+	if path.Plugin != "" {
+		return m.syncPluginPath(appConfig, path)
+	}
+
+	if path.IsSecret() {
+		return m.syncSecretPath(appConfig, path)
+	}
+
+	if path.Repo != "" {
+		if err := m.syncGitSource(appConfig, path); err != nil {
+			return err
+		}
+	}
+
+	path, err := m.followVersion(path)
+	if err != nil {
+		return err
+	}
+	if err := m.adoptLink(appConfig, path); err != nil {
+		return err
+	}
+
+	path = m.adaptToSandbox(appConfig, path)
+	if path.IsCopyMode() {
+		err = m.syncCopyPath(appConfig, path)
+	} else {
+		err = m.syncPath(appConfig, path)
+	}
+	if err != nil {
+		return err
+	}
+	return m.syncLinks(path)
+}
+
+// unsyncAppPath unsyncs a single path using the strategy for its path type
+func (m *Manager) unsyncAppPath(appConfig *config.AppConfig, path *config.Path) error {
+	if path.Type == config.PathTypeDefaults {
+		return m.unsyncDefaultsPath(appConfig.PreferencesDomain(), path)
+	}
+	if path.Plugin != "" {
+		return m.unsyncPluginPath(appConfig, path)
+	}
+	if path.IsSecret() {
+		// The file at the source already holds the content, and the backend keeps its copy
+		return nil
+	}
+	return m.unsyncPath(path)
+}
+
+// syncDefaultsPath captures a user defaults domain into the store.
+// On a system where the domain does not exist yet, the stored copy is imported instead.
+func (m *Manager) syncDefaultsPath(domain string, path *config.Path) error {
+	if domain == "" {
+		return fmt.Errorf("no defaults domain configured")
+	}
+
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Syncing defaults: %s -> %s\n", domain, storePath)
+	}
+
+	domainExists := m.defaultsManager.DomainExists(domain)
+	if !domainExists && !m.pathExists(storePath) {
+		return m.handleMissingPath(domain, path)
+	}
+
+	if m.dryRun {
+		if domainExists {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would export defaults: %s -> %s\n", domain, storePath)
+		} else {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would import defaults: %s <- %s\n", domain, storePath)
+		}
+		return nil
+	}
+
+	if domainExists {
+		return m.defaultsManager.Export(domain, storePath)
+	}
+	return m.defaultsManager.Import(domain, storePath)
+}
+
+// unsyncDefaultsPath applies the stored copy of a user defaults domain back to the system
+func (m *Manager) unsyncDefaultsPath(domain string, path *config.Path) error {
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Unsyncing defaults: %s\n", domain)
+	}
+
+	if !m.pathExists(storePath) {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    No stored defaults, skipping\n")
+		}
+		return nil
+	}
+
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would import defaults: %s <- %s\n", domain, storePath)
+		return nil
+	}
+
+	return m.defaultsManager.Import(domain, storePath)
+}
+
 // syncPath creates a symlink for a single configuration path
-func (m *Manager) syncPath(path *config.Path) error {
+func (m *Manager) syncPath(appConfig *config.AppConfig, path *config.Path) error {
 	sourcePath := m.expandPath(path.Source)
 	storePath := filepath.Join(m.storeDir, path.Destination)
 
 	if m.verbose {
-		fmt.Printf("  Syncing: %s -> %s\n", sourcePath, storePath)
+		fmt.Fprintf(m.out, "  Syncing: %s -> %s\n", sourcePath, storePath)
+	}
+
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
 	}
 
 	if m.isCorrectSymlink(sourcePath, storePath) {
 		if m.verbose {
-			fmt.Printf("    Already synced correctly\n")
+			fmt.Fprintf(m.out, "    Already synced correctly\n")
 		}
 		return nil
 	}
 
+	if isReplaced(m.fs, sourcePath, storePath, path) {
+		m.events.Emit(events.ConflictDetected, appConfig.Name, map[string]interface{}{
+			"kind":   "replaced_symlink",
+			"path":   path.Source,
+			"healed": m.heal,
+		})
+		return m.healPath(sourcePath, storePath, path)
+	}
+
 	if err := m.ensureStoreDirectory(storePath); err != nil {
 		return err
 	}
 
-	if err := m.handleExistingSource(sourcePath, storePath, path); err != nil {
+	if err := m.handleExistingSource(appConfig, sourcePath, storePath, path); err != nil {
 		return err
 	}
 
@@ -121,46 +409,61 @@ func (m *Manager) syncPath(path *config.Path) error {
 	return m.createFinalSymlink(sourcePath, storePath)
 }
 
-// unsyncPath removes a symlink and restores the original file if backed up
+// unsyncPath replaces the symlinks at a path's source and links with copies of the store copy
 func (m *Manager) unsyncPath(path *config.Path) error {
-	sourcePath := m.expandPath(path.Source)
 	storePath := filepath.Join(m.storeDir, path.Destination)
+	for _, location := range path.LiveLocations() {
+		if err := m.unsyncLocation(m.expandPath(location), storePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// unsyncLocation removes a symlink to the store and copies the store copy in its place
+func (m *Manager) unsyncLocation(sourcePath, storePath string) error {
 	if m.verbose {
-		fmt.Printf("  Unsyncing: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "  Unsyncing: %s\n", sourcePath)
 	}
 
 	// Check if source is a symlink to the store
 	if !m.isCorrectSymlink(sourcePath, storePath) {
 		if m.verbose {
-			fmt.Printf("    Not a valid symlink, skipping\n")
+			fmt.Fprintf(m.out, "    Not a valid symlink, skipping\n")
 		}
 		return nil
 	}
 
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
+	}
+
 	// Remove the symlink
 	if m.verbose {
-		fmt.Printf("    Removing symlink: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "    Removing symlink: %s\n", sourcePath)
 	}
 	if !m.dryRun {
-		if err := os.Remove(sourcePath); err != nil {
+		if err := m.fs.Remove(sourcePath); err != nil {
 			return fmt.Errorf("failed to remove symlink: %w", err)
 		}
 	} else {
-		fmt.Printf("    [DRY RUN] Would remove symlink: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "    [DRY RUN] Would remove symlink: %s\n", sourcePath)
 	}
 
 	// Copy back from store if it exists
 	if m.pathExists(storePath) {
 		if m.verbose {
-			fmt.Printf("    Copying back from store: %s -> %s\n", storePath, sourcePath)
+			fmt.Fprintf(m.out, "    Copying back from store: %s -> %s\n", storePath, sourcePath)
 		}
 		if !m.dryRun {
 			if err := m.copyFromStore(storePath, sourcePath); err != nil {
 				return fmt.Errorf("failed to copy from store: %w", err)
 			}
+			if err := m.restoreOwner(storePath, sourcePath); err != nil {
+				return fmt.Errorf("failed to give %s back to its owner: %w", sourcePath, err)
+			}
 		} else {
-			fmt.Printf("    [DRY RUN] Would copy: %s -> %s\n", storePath, sourcePath)
+			fmt.Fprintf(m.out, "    [DRY RUN] Would copy: %s -> %s\n", storePath, sourcePath)
 		}
 	}
 
@@ -171,17 +474,17 @@ func (m *Manager) unsyncPath(path *config.Path) error {
 func (m *Manager) ensureStoreDirectory(storePath string) error {
 	storeDir := filepath.Dir(storePath)
 	if !m.dryRun {
-		if err := os.MkdirAll(storeDir, 0755); err != nil {
+		if err := m.fs.MkdirAll(storeDir, 0755); err != nil {
 			return fmt.Errorf("failed to create store directory: %w", err)
 		}
 	} else {
-		fmt.Printf("    [DRY RUN] Would create directory: %s\n", storeDir)
+		fmt.Fprintf(m.out, "    [DRY RUN] Would create directory: %s\n", storeDir)
 	}
 	return nil
 }
 
 // handleExistingSource processes an existing source file or symlink
-func (m *Manager) handleExistingSource(sourcePath, storePath string, path *config.Path) error {
+func (m *Manager) handleExistingSource(appConfig *config.AppConfig, sourcePath, storePath string, path *config.Path) error {
 	if !m.pathExists(sourcePath) {
 		return nil
 	}
@@ -190,55 +493,190 @@ func (m *Manager) handleExistingSource(sourcePath, storePath string, path *confi
 		return m.removeExistingSymlink(sourcePath)
 	}
 
-	return m.moveSourceToStore(sourcePath, storePath, path)
+	return m.moveSourceToStore(appConfig, sourcePath, storePath, path)
 }
 
 // removeExistingSymlink removes an existing symlink
 func (m *Manager) removeExistingSymlink(sourcePath string) error {
 	if m.verbose {
-		fmt.Printf("    Removing existing symlink: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "    Removing existing symlink: %s\n", sourcePath)
 	}
 	if !m.dryRun {
-		if err := os.Remove(sourcePath); err != nil {
+		if err := m.fs.Remove(sourcePath); err != nil {
 			return fmt.Errorf("failed to remove existing symlink: %w", err)
 		}
 	} else {
-		fmt.Printf("    [DRY RUN] Would remove symlink: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "    [DRY RUN] Would remove symlink: %s\n", sourcePath)
 	}
 	return nil
 }
 
-// moveSourceToStore moves the source file/directory to store with backup
-func (m *Manager) moveSourceToStore(sourcePath, storePath string, path *config.Path) error {
+// moveSourceToStore moves the source file/directory to store with backup. Entries of a directory
+// matching the app's ignore rules move with it, so the application still finds them through the
+// link, but do not count towards the size limit and are left out of bundles.
+func (m *Manager) moveSourceToStore(appConfig *config.AppConfig, sourcePath, storePath string, path *config.Path) error {
+	ignored, err := appConfig.IgnoreMatcher(sourcePath, m.includeCaches)
+	if err != nil {
+		return err
+	}
+
+	if err := m.checkDirectorySize(sourcePath, ignored); err != nil {
+		return err
+	}
+
 	if !m.dryRun {
-		if err := m.backupManager.BackupPath("temp", path); err != nil {
-			if m.verbose {
-				fmt.Printf("    Warning: backup failed: %v\n", err)
-			}
+		if err := m.backupBefore(appConfig, path); err != nil {
+			return fmt.Errorf("failed to back up %s before moving it to the store: %w", sourcePath, err)
 		}
 	}
 
 	if m.verbose {
-		fmt.Printf("    Moving to store: %s -> %s\n", sourcePath, storePath)
+		fmt.Fprintf(m.out, "    Moving to store: %s -> %s\n", sourcePath, storePath)
 	}
 	if !m.dryRun {
+		info, err := m.fs.Lstat(sourcePath)
+		if err != nil {
+			return err
+		}
 		if err := m.moveToStore(sourcePath, storePath); err != nil {
 			return fmt.Errorf("failed to move to store: %w", err)
 		}
 		path.MarkBackedUp()
-	} else {
-		fmt.Printf("    [DRY RUN] Would move: %s -> %s\n", sourcePath, storePath)
+		// Remember the owner and mode, so 'doctor --fix-permissions' can restore them
+		return manifest.RecordOwnership(m.storeDir, path.Destination, info)
 	}
+
+	fmt.Fprintf(m.out, "    [DRY RUN] Would move: %s -> %s\n", sourcePath, storePath)
 	return nil
 }
 
+// ignoredEntries lists the entries of a directory, relative to it, that its ignore rules match.
+// The contents of an ignored directory are not listed separately.
+func (m *Manager) ignoredEntries(dir string, ignored *ignore.Matcher) ([]string, error) {
+	if ignored.Empty() {
+		return nil, nil
+	}
+	if info, err := m.fs.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	var entries []string
+	err := fsys.Walk(m.fs, dir, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, current)
+		if err != nil || relPath == "." {
+			return err
+		}
+		if !ignored.Match(relPath, info.IsDir()) {
+			return nil
+		}
+		entries = append(entries, relPath)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// checkDirectorySize refuses to move directories above the size limit unless confirmed. Entries
+// matching the ignore rules do not count towards the size.
+func (m *Manager) checkDirectorySize(sourcePath string, ignored *ignore.Matcher) error {
+	if m.maxDirSize <= 0 {
+		return nil
+	}
+
+	info, err := m.fs.Stat(sourcePath)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+
+	size, err := fsutil.Size(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate directory size: %w", err)
+	}
+	entries, err := m.ignoredEntries(sourcePath, ignored)
+	if err != nil {
+		return fmt.Errorf("failed to apply ignore rules: %w", err)
+	}
+	for _, entry := range entries {
+		entrySize, err := fsutil.Size(filepath.Join(sourcePath, entry))
+		if err != nil {
+			return fmt.Errorf("failed to calculate directory size: %w", err)
+		}
+		size -= entrySize
+	}
+	if size <= m.maxDirSize {
+		return nil
+	}
+
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Directory is %s (limit %s) and would require confirmation: %s\n",
+			fsutil.FormatSize(size), fsutil.FormatSize(m.maxDirSize), sourcePath)
+		return nil
+	}
+
+	if m.confirmLarge != nil && m.confirm(sourcePath, size) {
+		return nil
+	}
+
+	return fmt.Errorf("directory is %s which exceeds the %s safety limit%s; sync with --allow-large or add specific sub-paths instead",
+		fsutil.FormatSize(size), fsutil.FormatSize(m.maxDirSize), m.describeLargestEntries(sourcePath))
+}
+
+// confirm asks for confirmation of a large directory, one question at a time across workers
+func (m *Manager) confirm(path string, size int64) bool {
+	m.confirmMu.Lock()
+	defer m.confirmMu.Unlock()
+	return m.confirmLarge(path, size)
+}
+
+// describeLargestEntries lists the largest direct children of a directory as sub-path suggestions
+func (m *Manager) describeLargestEntries(dir string) string {
+	entries, err := m.fs.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	type entrySize struct {
+		name string
+		size int64
+	}
+
+	var sizes []entrySize
+	for _, entry := range entries {
+		size, err := fsutil.Size(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		sizes = append(sizes, entrySize{name: entry.Name(), size: size})
+	}
+
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].size > sizes[j].size })
+	if len(sizes) > 3 {
+		sizes = sizes[:3]
+	}
+
+	var parts []string
+	for _, s := range sizes {
+		parts = append(parts, fmt.Sprintf("%s (%s)", s.name, fsutil.FormatSize(s.size)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return " (largest entries: " + strings.Join(parts, ", ") + ")"
+}
+
 // handleMissingPath handles the case where neither source nor store exists
 func (m *Manager) handleMissingPath(sourcePath string, path *config.Path) error {
 	if path.Required {
 		return fmt.Errorf("required path does not exist: %s", sourcePath)
 	}
 	if m.verbose {
-		fmt.Printf("    Skipping non-existent optional path: %s\n", sourcePath)
+		fmt.Fprintf(m.out, "    Skipping non-existent optional path: %s\n", sourcePath)
 	}
 	return nil
 }
@@ -246,14 +684,14 @@ func (m *Manager) handleMissingPath(sourcePath string, path *config.Path) error
 // createFinalSymlink creates the final symlink
 func (m *Manager) createFinalSymlink(sourcePath, storePath string) error {
 	if m.verbose {
-		fmt.Printf("    Creating symlink: %s -> %s\n", sourcePath, storePath)
+		fmt.Fprintf(m.out, "    Creating symlink: %s -> %s\n", sourcePath, storePath)
 	}
 	if !m.dryRun {
 		if err := m.createSymlink(storePath, sourcePath); err != nil {
 			return fmt.Errorf("failed to create symlink: %w", err)
 		}
 	} else {
-		fmt.Printf("    [DRY RUN] Would create symlink: %s -> %s\n", sourcePath, storePath)
+		fmt.Fprintf(m.out, "    [DRY RUN] Would create symlink: %s -> %s\n", sourcePath, storePath)
 	}
 	return nil
 }
@@ -261,23 +699,15 @@ func (m *Manager) createFinalSymlink(sourcePath, storePath string) error {
 // Helper methods
 
 func (m *Manager) expandPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		return filepath.Join(m.homeDir, path[2:])
-	}
-	return path
+	return m.host.SubstitutePath(path, m.homeDir)
 }
 
 func (m *Manager) pathExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+	return fsys.Exists(m.fs, path)
 }
 
 func (m *Manager) isSymlink(path string) bool {
-	info, err := os.Lstat(path)
-	if err != nil {
-		return false
-	}
-	return info.Mode()&os.ModeSymlink != 0
+	return fsys.IsSymlink(m.fs, path)
 }
 
 func (m *Manager) isCorrectSymlink(sourcePath, targetPath string) bool {
@@ -285,7 +715,7 @@ func (m *Manager) isCorrectSymlink(sourcePath, targetPath string) bool {
 		return false
 	}
 
-	link, err := os.Readlink(sourcePath)
+	link, err := m.fs.Readlink(sourcePath)
 	if err != nil {
 		return false
 	}
@@ -306,86 +736,83 @@ func (m *Manager) isCorrectSymlink(sourcePath, targetPath string) bool {
 func (m *Manager) createSymlink(target, source string) error {
 	// Ensure the source directory exists
 	sourceDir := filepath.Dir(source)
-	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(sourceDir, 0755); err != nil {
 		return fmt.Errorf("failed to create source directory: %w", err)
 	}
 
 	// Create the symlink
-	return os.Symlink(target, source)
+	return m.fs.Symlink(target, source)
 }
 
 func (m *Manager) moveToStore(sourcePath, storePath string) error {
 	// Ensure store directory exists
 	storeDir := filepath.Dir(storePath)
-	if err := os.MkdirAll(storeDir, 0755); err != nil {
+	if err := m.fs.MkdirAll(storeDir, 0755); err != nil {
 		return fmt.Errorf("failed to create store directory: %w", err)
 	}
 
-	// Move the file/directory
-	return os.Rename(sourcePath, storePath)
+	// Move the file/directory, copying it when the store is on another volume
+	return fsops.Move(m.ctx, m.fs, sourcePath, storePath)
 }
 
-func (m *Manager) copyFromStore(storePath, sourcePath string) error {
-	// Ensure source directory exists
-	sourceDir := filepath.Dir(sourcePath)
-	if err := os.MkdirAll(sourceDir, 0755); err != nil {
-		return fmt.Errorf("failed to create source directory: %w", err)
+// restoreOwner gives a copy made from the store, when running as root such as under sudo, to the
+// owner its path had before it was moved into the store, or else to the owner of the home
+// directory, so it does not end up belonging to root
+func (m *Manager) restoreOwner(storePath, sourcePath string) error {
+	if os.Geteuid() != 0 {
+		return nil
 	}
-
-	// Check if store path is a directory
-	info, err := os.Stat(storePath)
-	if err != nil {
-		return err
+	if rel, err := filepath.Rel(m.storeDir, storePath); err == nil {
+		permissions, err := manifest.LoadPermissions(m.storeDir)
+		if err != nil {
+			return err
+		}
+		if ownership, ok := permissions.Lookup(rel); ok && ownership.UID != 0 {
+			return fsops.ChownTree(m.fs, sourcePath, ownership.UID, ownership.GID)
+		}
 	}
+	return fsops.GiveToOwnerOf(m.fs, sourcePath, m.homeDir)
+}
 
-	if info.IsDir() {
-		return m.copyDir(storePath, sourcePath)
-	}
-	return m.copyFile(storePath, sourcePath)
+func (m *Manager) copyFromStore(storePath, sourcePath string) error {
+	return m.copyPath(storePath, sourcePath)
 }
 
-func (m *Manager) copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
+// copyPath copies a file or directory between the store and an application's location
+func (m *Manager) copyPath(src, dst string) error {
+	if err := m.ctx.Err(); err != nil {
 		return err
 	}
-	defer func() { _ = sourceFile.Close() }()
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	// Ensure destination directory exists
+	dstDir := filepath.Dir(dst)
+	if err := m.fs.MkdirAll(dstDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
 	}
-	defer func() { _ = destFile.Close() }()
 
-	// Copy file contents
-	if _, err := destFile.ReadFrom(sourceFile); err != nil {
+	// Check if the source is a directory
+	info, err := m.fs.Stat(src)
+	if err != nil {
 		return err
 	}
 
-	// Copy permissions
-	info, statErr := sourceFile.Stat()
-	if statErr != nil {
-		return statErr
+	// Use the store manifest so unchanged files are not copied again
+	storeManifest, err := manifest.LoadFS(m.fs, m.storeDir)
+	if err != nil {
+		return err
 	}
-	return os.Chmod(dst, info.Mode())
-}
 
-func (m *Manager) copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+	if info.IsDir() {
+		stats, err := storeManifest.CopyDir(src, dst)
 		if err != nil {
 			return err
 		}
-
-		// Calculate destination path
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Copied %d file(s), %d unchanged\n", stats.Copied, stats.Skipped)
 		}
-		destPath := filepath.Join(dst, relPath)
+	} else if _, err := storeManifest.CopyFile(src, dst); err != nil {
+		return err
+	}
 
-		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
-		}
-		return m.copyFile(path, destPath)
-	})
+	return storeManifest.Save()
 }
@@ -1,13 +1,21 @@
 package symlink
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/dotbrains/configsync/internal/config"
 	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/defaults"
+	"github.com/dotbrains/configsync/internal/fsys"
+	"github.com/dotbrains/configsync/internal/manifest"
 )
 
 func TestNewManager(t *testing.T) {
@@ -91,6 +99,17 @@ func TestSyncAppEnabled(t *testing.T) {
 		t.Errorf("Store content mismatch: expected %q, got %q", sourceContent, string(storeContent))
 	}
 
+	// Verify the original owner and mode were recorded where files have owners
+	permissions, err := manifest.LoadPermissions(storeDir)
+	if err != nil {
+		t.Fatalf("Failed to load permissions: %v", err)
+	}
+	if ownership, ok := permissions.Lookup("test.conf"); ok && ownership.Mode != "0644" {
+		t.Errorf("Expected mode 0644 to be recorded, got %+v", ownership)
+	} else if !ok && runtime.GOOS != "windows" {
+		t.Error("Expected the owner and mode of test.conf to be recorded")
+	}
+
 	// Verify symlink target
 	target, err := os.Readlink(sourceFile)
 	if err != nil {
@@ -113,6 +132,62 @@ func TestSyncAppEnabled(t *testing.T) {
 	}
 }
 
+func TestSyncAppInMemory(t *testing.T) {
+	files := fsys.NewMem()
+	homeDir := filepath.Join(string(filepath.Separator), "Users", "test")
+	storeDir := filepath.Join(homeDir, ".configsync", "store")
+	manager := NewManager(homeDir, storeDir, filepath.Join(homeDir, ".configsync", "backups"), false, false)
+	manager.SetFS(files)
+
+	sourceFile := filepath.Join(homeDir, ".config", "app", "settings.json")
+	if err := files.MkdirAll(filepath.Dir(sourceFile), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := files.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	appConfig := config.NewAppConfig("testapp", "Test Application")
+	appConfig.AddPath(sourceFile, "app/settings.json", config.PathTypeFile, false)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if !fsys.IsSymlink(files, sourceFile) {
+		t.Error("Expected source file to be a symlink")
+	}
+	data, err := files.ReadFile(filepath.Join(storeDir, "app", "settings.json"))
+	if err != nil || string(data) != constants.TestConfiguration {
+		t.Errorf("Expected the store copy to hold the settings, got %q, %v", data, err)
+	}
+	if _, err := os.Lstat(sourceFile); !os.IsNotExist(err) {
+		t.Error("Expected the real file system to be left alone")
+	}
+}
+
+func TestSyncAppCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	manager.SetContext(ctx)
+
+	sourceFile := filepath.Join(tempDir, "test.conf")
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	appConfig := config.NewAppConfig("testapp", "Test Application")
+	appConfig.AddPath(sourceFile, "test.conf", config.PathTypeFile, false)
+
+	if err := manager.SyncApp(appConfig); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected source file to be left alone after cancellation")
+	}
+}
+
 func TestSyncAppDisabled(t *testing.T) {
 	tempDir := t.TempDir()
 	homeDir := tempDir
@@ -642,6 +717,326 @@ func TestSyncAppErrors(t *testing.T) {
 	}
 }
 
+// newDefaultsTestManager creates a manager whose defaults commands operate on an in-memory domain map
+func newDefaultsTestManager(t *testing.T, domains map[string]string) *Manager {
+	// Defaults domains are only synced on macOS
+	platform := config.CurrentPlatform
+	config.CurrentPlatform = config.PlatformDarwin
+	t.Cleanup(func() { config.CurrentPlatform = platform })
+
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+	manager.defaultsManager = defaults.NewManagerWithRunner(func(_ string, args ...string) ([]byte, error) {
+		content, exists := domains[args[1]]
+		switch args[0] {
+		case "read":
+			if !exists {
+				return nil, fmt.Errorf("domain does not exist")
+			}
+			return []byte(content), nil
+		case "export":
+			return nil, os.WriteFile(args[2], []byte(content), 0644)
+		case "import":
+			data, err := os.ReadFile(args[2])
+			domains[args[1]] = string(data)
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected command")
+	}, false)
+	return manager
+}
+
+func TestSyncAppDefaultsExport(t *testing.T) {
+	domains := map[string]string{constants.TestBundleID: constants.TestConfiguration}
+	manager := newDefaultsTestManager(t, domains)
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.BundleID = constants.TestBundleID
+	appConfig.AddDefaultsPath()
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	storeFile := filepath.Join(manager.storeDir, "Defaults", constants.TestBundleID+".plist")
+	data, err := os.ReadFile(storeFile)
+	if err != nil {
+		t.Fatalf("Expected defaults to be exported to store: %v", err)
+	}
+	if string(data) != constants.TestConfiguration {
+		t.Errorf("Expected exported content %q, got %q", constants.TestConfiguration, string(data))
+	}
+
+	if !appConfig.Paths[0].Synced {
+		t.Error("Expected defaults path to be marked synced")
+	}
+}
+
+func TestSyncAppDefaultsImportOnNewSystem(t *testing.T) {
+	domains := map[string]string{}
+	manager := newDefaultsTestManager(t, domains)
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.DefaultsDomain = "com.test.custom"
+	appConfig.AddDefaultsPath()
+
+	storeFile := filepath.Join(manager.storeDir, appConfig.Paths[0].Destination)
+	if err := os.MkdirAll(filepath.Dir(storeFile), 0755); err != nil {
+		t.Fatalf("Failed to create store directory: %v", err)
+	}
+	if err := os.WriteFile(storeFile, []byte(constants.TestHelloWorld), 0644); err != nil {
+		t.Fatalf("Failed to create store file: %v", err)
+	}
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if domains["com.test.custom"] != constants.TestHelloWorld {
+		t.Errorf("Expected stored defaults to be imported, got %q", domains["com.test.custom"])
+	}
+}
+
+func TestSyncAppDefaultsMissingDomain(t *testing.T) {
+	manager := newDefaultsTestManager(t, map[string]string{})
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath("", "Defaults/none.plist", config.PathTypeDefaults, false)
+
+	if err := manager.SyncApp(appConfig); err == nil {
+		t.Error("Expected error when no defaults domain is configured")
+	}
+}
+
+func TestUnsyncAppDefaults(t *testing.T) {
+	domains := map[string]string{constants.TestBundleID: constants.TestConfiguration}
+	manager := newDefaultsTestManager(t, domains)
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.BundleID = constants.TestBundleID
+	appConfig.AddDefaultsPath()
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	domains[constants.TestBundleID] = "changed"
+
+	if err := manager.UnsyncApp(appConfig); err != nil {
+		t.Fatalf("UnsyncApp failed: %v", err)
+	}
+
+	if domains[constants.TestBundleID] != constants.TestConfiguration {
+		t.Errorf("Expected stored defaults to be restored, got %q", domains[constants.TestBundleID])
+	}
+}
+
+func TestSyncAppLargeDirectoryLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+
+	sourceDir := filepath.Join(tempDir, "bigdir")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "cache.db"), make([]byte, 2048), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceDir, "bigdir", config.PathTypeDirectory, false)
+
+	// Refused without confirmation
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.SetDirectorySizeLimit(1024, nil)
+
+	err := manager.SyncApp(appConfig)
+	if err == nil {
+		t.Fatal("Expected SyncApp to refuse directory above size limit")
+	}
+	if !strings.Contains(err.Error(), "cache.db") {
+		t.Errorf("Expected error to suggest largest entries, got: %v", err)
+	}
+	if manager.isSymlink(sourceDir) {
+		t.Error("Source directory should not be moved without confirmation")
+	}
+
+	// Allowed once confirmed
+	var confirmedPath string
+	manager.SetDirectorySizeLimit(1024, func(path string, _ int64) bool {
+		confirmedPath = path
+		return true
+	})
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed after confirmation: %v", err)
+	}
+	if confirmedPath != sourceDir {
+		t.Errorf("Expected confirmation for %s, got %s", sourceDir, confirmedPath)
+	}
+	if !manager.isSymlink(sourceDir) {
+		t.Error("Expected source directory to be symlinked after confirmation")
+	}
+}
+
+func TestSyncAppKeepsIgnoredEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	backupDir := filepath.Join(tempDir, "backup")
+
+	sourceDir := filepath.Join(tempDir, "profile")
+	for rel, size := range map[string]int{"Preferences": 16, "Cache/data_0": 4096, "GPUCache/index": 4096, "debug.log": 16} {
+		file := filepath.Join(sourceDir, rel)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, ".configsyncignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.Ignore = []string{"Cache/", "GPUCache/"}
+	appConfig.AddPath(sourceDir, "profile", config.PathTypeDirectory, false)
+
+	// The caches do not count towards the size limit
+	manager := NewManager(tempDir, storeDir, backupDir, false, false)
+	manager.SetDirectorySizeLimit(1024, nil)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if !manager.isSymlink(sourceDir) {
+		t.Fatal("Expected source directory to be symlinked")
+	}
+
+	storePath := filepath.Join(storeDir, "profile")
+	if !manager.pathExists(filepath.Join(storePath, "Preferences")) {
+		t.Error("Expected the settings file to be moved into the store")
+	}
+	// Ignored entries are not deleted: the application still finds them through the link
+	for _, rel := range []string{"Cache/data_0", "GPUCache/index", "debug.log"} {
+		if !manager.pathExists(filepath.Join(sourceDir, rel)) {
+			t.Errorf("Expected ignored %s to be kept", rel)
+		}
+	}
+
+	backups, err := filepath.Glob(filepath.Join(backupDir, "versions", constants.TestAppName, "*", "*"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected one backup version, got %v (%v)", backups, err)
+	}
+	if !manager.pathExists(filepath.Join(backups[0], "Cache", "data_0")) {
+		t.Error("Expected the backup taken before the sync to keep ignored entries")
+	}
+	if !manager.pathExists(filepath.Join(backups[0], "Preferences")) {
+		t.Error("Expected the settings file to be backed up")
+	}
+}
+
+func TestSyncAppBackupBefore(t *testing.T) {
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backup")
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), backupDir, false, false)
+	manager.out = io.Discard
+
+	syncFile := func(name string, backupBefore bool) {
+		t.Helper()
+		sourceFile := filepath.Join(tempDir, name)
+		if err := os.WriteFile(sourceFile, []byte("setting = 1"), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		appConfig := config.NewAppConfig(name, name)
+		appConfig.BackupBefore = backupBefore
+		appConfig.AddPath(sourceFile, name, config.PathTypeFile, false)
+		if err := manager.SyncApp(appConfig); err != nil {
+			t.Fatalf("SyncApp failed: %v", err)
+		}
+	}
+
+	syncFile("backed-up.conf", true)
+	syncFile("not-backed-up.conf", false)
+	manager.SetAutoBackup(false)
+	syncFile("auto-backup-off.conf", true)
+
+	created := manager.CreatedBackups()
+	if len(created) != 1 || created[0].AppName != "backed-up.conf" || created[0].OriginalPath != filepath.Join(tempDir, "backed-up.conf") {
+		t.Fatalf("Expected only the app with backups on to be backed up, got %+v", created)
+	}
+	if _, err := os.Stat(created[0].BackupPath); err != nil {
+		t.Errorf("Expected the backup under the app's name: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(backupDir, "versions")); len(entries) != 1 {
+		t.Errorf("Expected one app's backups, got %d", len(entries))
+	}
+}
+
+func TestSyncAppIncludeCaches(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+
+	sourceDir := filepath.Join(tempDir, "profile")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "Code Cache"), 0755); err != nil {
+		t.Fatalf("Failed to create cache directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "Code Cache", "index"), []byte("cache"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceDir, "profile", config.PathTypeDirectory, false)
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = io.Discard
+	manager.SetIncludeCaches(true)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if !manager.pathExists(filepath.Join(storeDir, "profile", "Code Cache", "index")) {
+		t.Error("Expected caches to be moved into the store when included")
+	}
+}
+
+func TestSyncAppRunsPostSync(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+
+	var commands []string
+	manager.runShell = func(command string) ([]byte, error) {
+		commands = append(commands, command)
+		return []byte("not running"), fmt.Errorf("exit status 1")
+	}
+
+	sourceFile := filepath.Join(tempDir, "app.conf")
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, "app.conf", config.PathTypeFile, false)
+	appConfig.PostSync = []string{"reload-app"}
+
+	// A failing reload command must not fail the sync
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if len(commands) != 1 || commands[0] != "reload-app" {
+		t.Errorf("Expected post-sync command to run once, got %v", commands)
+	}
+
+	// Dry run only reports the command
+	commands = nil
+	manager.dryRun = true
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp dry run failed: %v", err)
+	}
+	if len(commands) != 0 {
+		t.Errorf("Expected no commands to run in dry run, got %v", commands)
+	}
+}
+
 // Benchmark tests
 func BenchmarkSyncFile(b *testing.B) {
 	tempDir := b.TempDir()
@@ -672,3 +1067,70 @@ func BenchmarkSyncFile(b *testing.B) {
 		}
 	}
 }
+
+func TestSyncAppSkipsPathsForOtherPlatforms(t *testing.T) {
+	platform := config.CurrentPlatform
+	config.CurrentPlatform = config.PlatformLinux
+	defer func() { config.CurrentPlatform = platform }()
+
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+
+	macPrefs := filepath.Join(tempDir, "Library", "Preferences", "com.test.app.plist")
+	dotfile := filepath.Join(tempDir, ".testrc")
+	for _, path := range []string{macPrefs, dotfile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(constants.TestConfiguration), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(macPrefs, "Library/Preferences/com.test.app.plist", config.PathTypeFile, true)
+	appConfig.AddPath(dotfile, ".testrc", config.PathTypeFile, true)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if isLink(t, macPrefs) || appConfig.Paths[0].Synced {
+		t.Error("Expected macOS-only path to be skipped on Linux")
+	}
+	if !isLink(t, dotfile) || !appConfig.Paths[1].Synced {
+		t.Error("Expected cross-platform path to be synced")
+	}
+}
+
+func isLink(t *testing.T, path string) bool {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", path, err)
+	}
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+func TestSyncAppSubstitutesHostPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	hostSource := filepath.Join(tempDir, "Projects", ".editorconfig")
+	writeTestFile(t, hostSource, "root = true")
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = io.Discard
+	manager.SetHost(&config.HostOverride{Paths: []config.PathSubstitution{{From: "~/Work", To: "~/Projects"}}})
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath("~/Work/.editorconfig", ".editorconfig", config.PathTypeFile, false)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if !manager.isCorrectSymlink(hostSource, filepath.Join(storeDir, ".editorconfig")) {
+		t.Error("Expected the substituted location to be linked")
+	}
+	if appConfig.Paths[0].Source != "~/Work/.editorconfig" {
+		t.Errorf("Expected the shared source to be kept, got %q", appConfig.Paths[0].Source)
+	}
+}
@@ -0,0 +1,83 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// MovedArchiveDir is the backup subdirectory that files found at the new location of a moved path
+// are archived in, since the store copy takes their place
+const MovedArchiveDir = "moved"
+
+// MovePath moves a configured path of an application to a new source and store destination, e.g.
+// after an upgrade made the application keep its configuration elsewhere. The symlink at the old
+// source is removed, the store copy is moved to the new destination, and whatever the upgraded
+// application created at the new source is archived before it is linked to the store copy. The
+// path's backups are relinked to its new location, so restoring it still finds them.
+func (m *Manager) MovePath(appConfig *config.AppConfig, index int, source, destination string) error {
+	path := &appConfig.Paths[index]
+	oldSource, newSource := m.expandPath(path.Source), m.expandPath(source)
+	oldStore, newStore := filepath.Join(m.storeDir, path.Destination), filepath.Join(m.storeDir, destination)
+	fmt.Fprintf(m.out, "  Moving %s to %s\n", oldSource, newSource)
+
+	if oldStore != newStore && m.pathExists(oldStore) && m.pathExists(newStore) {
+		return fmt.Errorf("cannot move the store copy to %s: it already exists", newStore)
+	}
+	if m.dryRun {
+		if m.pathExists(oldStore) && m.pathExists(newSource) && !m.isSymlink(newSource) {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would archive %s and link it to the store copy\n", newSource)
+		}
+		return nil
+	}
+
+	if m.pathExists(oldStore) {
+		if err := m.moveStoreCopy(oldSource, oldStore, newStore); err != nil {
+			return err
+		}
+		if m.pathExists(newSource) && !m.isSymlink(newSource) {
+			if err := m.archiveTo(MovedArchiveDir, newSource, destination); err != nil {
+				return err
+			}
+		}
+	}
+
+	previous := *path
+	path.Source, path.Destination = source, destination
+	if _, err := m.backupManager.RelinkPath(appConfig.Name, &previous, path); err != nil {
+		fmt.Fprintf(m.out, "    Warning: failed to link the backups of %s to %s: %v\n", oldSource, newSource, err)
+	}
+	if !m.pathExists(newStore) {
+		// The path was never synced, so the next sync moves the new source into the store
+		path.Synced = false
+		return nil
+	}
+	return m.syncAppPath(appConfig, path)
+}
+
+// moveStoreCopy removes the symlink at a path's old source and moves its store copy to a new
+// store destination
+func (m *Manager) moveStoreCopy(oldSource, oldStore, newStore string) error {
+	linked := m.isCorrectSymlink(oldSource, oldStore)
+	if linked {
+		if err := m.fs.Remove(oldSource); err != nil {
+			return fmt.Errorf("failed to remove symlink: %w", err)
+		}
+	}
+	if oldStore == newStore {
+		return nil
+	}
+
+	if err := m.ensureStoreDirectory(newStore); err != nil {
+		return err
+	}
+	if err := m.fs.Rename(oldStore, newStore); err != nil {
+		if linked {
+			// Relink the old source so it is as it was found
+			_ = m.createSymlink(oldStore, oldSource)
+		}
+		return fmt.Errorf("failed to move the store copy to %s: %w", newStore, err)
+	}
+	return nil
+}
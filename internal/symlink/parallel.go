@@ -0,0 +1,143 @@
+package symlink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// AppResult represents the outcome of syncing a single application
+type AppResult struct {
+	Err      error
+	App      *config.AppConfig
+	Name     string
+	Output   string // Messages written while syncing the app, kept together to avoid interleaving
+	Duration time.Duration
+}
+
+// ProgressFunc is called after each application finishes syncing.
+// Calls are serialized, so implementations do not need their own locking.
+type ProgressFunc func(done, total int, result AppResult)
+
+// SyncApps syncs applications concurrently using a pool of workers. An application starts only
+// once the applications it depends on among apps have synced, and is not synced when one of them
+// failed; otherwise applications start in the order of config.SyncOrder. Results are returned
+// sorted by application name.
+func (m *Manager) SyncApps(apps map[string]*config.AppConfig, workers int, progress ProgressFunc) []AppResult {
+	order, err := config.SyncOrder(apps)
+	if err != nil {
+		// The configuration is validated when loaded, so only a cycle built in code gets here
+		fmt.Fprintf(m.out, "Warning: ignoring dependencies between applications: %v\n", err)
+		order = make([]string, 0, len(apps))
+		for name := range apps {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
+	waitsFor := make(map[string][]string, len(order))
+	if err == nil {
+		for _, name := range order {
+			for _, dep := range apps[name].DependsOn {
+				if _, ok := apps[dep]; ok && !slices.Contains(waitsFor[name], dep) {
+					waitsFor[name] = append(waitsFor[name], dep)
+				}
+			}
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]AppResult, 0, len(order))
+	finished := make(map[string]error, len(order))
+	started := make(map[string]bool, len(order))
+	done := make(chan AppResult)
+	running := 0
+
+	finish := func(result AppResult) {
+		results = append(results, result)
+		finished[result.Name] = result.Err
+		if progress != nil {
+			progress(len(results), len(order), result)
+		}
+	}
+	// ready returns whether every dependency of an application has finished, and the first that failed
+	ready := func(name string) (bool, string) {
+		failed := ""
+		for _, dep := range waitsFor[name] {
+			err, ok := finished[dep]
+			if !ok {
+				return false, ""
+			}
+			if err != nil && failed == "" {
+				failed = dep
+			}
+		}
+		return true, failed
+	}
+
+	for len(results) < len(order) {
+		for _, name := range order {
+			if running == workers {
+				break
+			}
+			if started[name] {
+				continue
+			}
+			ok, failed := ready(name)
+			if !ok {
+				continue
+			}
+			started[name] = true
+			if failed != "" {
+				finish(AppResult{Name: name, App: apps[name], Err: fmt.Errorf("not synced because %s, which it depends on, failed to sync", failed)})
+				continue
+			}
+			running++
+			go func(name string) {
+				done <- m.syncAppBuffered(name, apps[name])
+			}(name)
+		}
+		if running == 0 {
+			// Applications finished without syncing may have made others ready
+			continue
+		}
+		finish(<-done)
+		running--
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// syncAppBuffered syncs one application, capturing its output in the result
+func (m *Manager) syncAppBuffered(name string, appConfig *config.AppConfig) AppResult {
+	var buf bytes.Buffer
+	start := time.Now()
+
+	err := m.withOutput(&buf).SyncApp(appConfig)
+
+	return AppResult{
+		Name:     name,
+		App:      appConfig,
+		Err:      err,
+		Output:   buf.String(),
+		Duration: time.Since(start),
+	}
+}
+
+// withOutput returns a copy of the manager whose messages, including those of
+// its backup and defaults managers, are written to w
+func (m *Manager) withOutput(w io.Writer) *Manager {
+	clone := *m
+	clone.out = w
+	clone.backupManager = m.backupManager.WithOutput(w)
+	clone.defaultsManager = m.defaultsManager.WithOutput(w)
+	return &clone
+}
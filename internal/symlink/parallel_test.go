@@ -0,0 +1,145 @@
+package symlink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestSyncApps(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, true)
+
+	apps := make(map[string]*config.AppConfig)
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("app%d", i)
+		sourceFile := filepath.Join(tempDir, name+".conf")
+		if err := os.WriteFile(sourceFile, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+
+		appConfig := config.NewAppConfig(name, "App "+name)
+		appConfig.AddPath(sourceFile, name+".conf", config.PathTypeFile, false)
+		apps[name] = appConfig
+	}
+
+	// One app fails because its required path is missing
+	failing := config.NewAppConfig("broken", "Broken App")
+	failing.AddPath(filepath.Join(tempDir, "missing.conf"), "missing.conf", config.PathTypeFile, true)
+	apps["broken"] = failing
+
+	var progressCalls []int
+	results := manager.SyncApps(apps, 3, func(done, total int, _ AppResult) {
+		if total != len(apps) {
+			t.Errorf("Expected total %d, got %d", len(apps), total)
+		}
+		progressCalls = append(progressCalls, done)
+	})
+
+	if len(results) != len(apps) {
+		t.Fatalf("Expected %d results, got %d", len(apps), len(results))
+	}
+
+	for i, done := range progressCalls {
+		if done != i+1 {
+			t.Errorf("Expected progress calls to count up, got %v", progressCalls)
+			break
+		}
+	}
+
+	for i := 1; i < len(results); i++ {
+		if results[i-1].Name > results[i].Name {
+			t.Errorf("Expected results sorted by name, got %s before %s", results[i-1].Name, results[i].Name)
+		}
+	}
+
+	for _, result := range results {
+		if result.Name == "broken" {
+			if result.Err == nil {
+				t.Error("Expected broken app to fail")
+			}
+			continue
+		}
+
+		if result.Err != nil {
+			t.Errorf("Expected %s to sync, got %v", result.Name, result.Err)
+		}
+		if !manager.isSymlink(filepath.Join(tempDir, result.Name+".conf")) {
+			t.Errorf("Expected %s to be symlinked", result.Name)
+		}
+
+		// Verbose output for each app is captured whole, without other apps' messages
+		if !strings.Contains(result.Output, "Syncing App "+result.Name) {
+			t.Errorf("Expected output for %s to be captured, got %q", result.Name, result.Output)
+		}
+		for other := range apps {
+			if other != result.Name && strings.Contains(result.Output, "App "+other+"...") {
+				t.Errorf("Output for %s contains messages from %s", result.Name, other)
+			}
+		}
+	}
+}
+
+func TestSyncAppsEmpty(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+
+	results := manager.SyncApps(map[string]*config.AppConfig{}, 4, nil)
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %d", len(results))
+	}
+}
+
+func TestSyncAppsDependencies(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+
+	apps := make(map[string]*config.AppConfig)
+	for _, name := range []string{"zsh", "alacritty", "kitty"} {
+		sourceFile := filepath.Join(tempDir, name+".conf")
+		if err := os.WriteFile(sourceFile, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		apps[name] = config.NewAppConfig(name, name)
+		apps[name].AddPath(sourceFile, name+".conf", config.PathTypeFile, false)
+	}
+	apps["alacritty"].DependsOn = []string{"zsh"}
+
+	// kitty depends on an app that fails, so it is not synced
+	broken := config.NewAppConfig("broken", "Broken App")
+	broken.AddPath(filepath.Join(tempDir, "missing.conf"), "missing.conf", config.PathTypeFile, true)
+	apps["broken"] = broken
+	apps["kitty"].DependsOn = []string{"broken"}
+
+	var finished []string
+	results := manager.SyncApps(apps, 4, func(_, _ int, result AppResult) {
+		finished = append(finished, result.Name)
+	})
+	if len(results) != len(apps) {
+		t.Fatalf("Expected %d results, got %d", len(apps), len(results))
+	}
+	if slices.Index(finished, "zsh") > slices.Index(finished, "alacritty") {
+		t.Errorf("Expected zsh to finish before alacritty, got %v", finished)
+	}
+
+	for _, result := range results {
+		switch result.Name {
+		case "kitty":
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "broken") {
+				t.Errorf("Expected kitty to be skipped after broken failed, got %v", result.Err)
+			}
+			if manager.isSymlink(filepath.Join(tempDir, "kitty.conf")) {
+				t.Error("Expected kitty not to be synced")
+			}
+		case "zsh", "alacritty":
+			if result.Err != nil {
+				t.Errorf("Expected %s to sync, got %v", result.Name, result.Err)
+			}
+		}
+	}
+}
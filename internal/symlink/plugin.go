@@ -0,0 +1,94 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/plugins"
+)
+
+// SetPlugins sets how the plugins that sync plugin paths are opened by name; without it, plugin
+// paths fail to sync
+func (m *Manager) SetPlugins(open func(name string) (*plugins.Plugin, error)) {
+	m.plugin = open
+}
+
+// syncPluginPath has a plugin export the settings of a path's source into the store. Where the
+// plugin finds no settings at the source yet, as on a new machine, the store copy is imported.
+func (m *Manager) syncPluginPath(appConfig *config.AppConfig, path *config.Path) error {
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Syncing with plugin %s: %s -> %s\n", path.Plugin, sourcePath, storePath)
+	}
+
+	plugin, err := m.openPlugin(path.Plugin)
+	if err != nil {
+		return err
+	}
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
+	}
+	exists, err := plugin.Exists(appConfig.Name, sourcePath, storePath)
+	if err != nil {
+		return err
+	}
+	if !exists && !m.pathExists(storePath) {
+		return m.handleMissingPath(sourcePath, path)
+	}
+
+	if m.dryRun {
+		if exists {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would export with %s: %s -> %s\n", path.Plugin, sourcePath, storePath)
+		} else {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would import with %s: %s <- %s\n", path.Plugin, sourcePath, storePath)
+		}
+		return nil
+	}
+
+	if exists {
+		if err := m.ensureStoreDirectory(storePath); err != nil {
+			return err
+		}
+		return plugin.Export(appConfig.Name, sourcePath, storePath)
+	}
+	return plugin.Import(appConfig.Name, sourcePath, storePath)
+}
+
+// unsyncPluginPath has a plugin apply the store copy of a path back to its source
+func (m *Manager) unsyncPluginPath(appConfig *config.AppConfig, path *config.Path) error {
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Unsyncing with plugin %s: %s\n", path.Plugin, sourcePath)
+	}
+
+	if !m.pathExists(storePath) {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    No exported settings, skipping\n")
+		}
+		return nil
+	}
+
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would import with %s: %s <- %s\n", path.Plugin, sourcePath, storePath)
+		return nil
+	}
+
+	plugin, err := m.openPlugin(path.Plugin)
+	if err != nil {
+		return err
+	}
+	return plugin.Import(appConfig.Name, sourcePath, storePath)
+}
+
+// openPlugin opens the plugin a path names
+func (m *Manager) openPlugin(name string) (*plugins.Plugin, error) {
+	if m.plugin == nil {
+		return nil, fmt.Errorf("plugin %s is not available here", name)
+	}
+	return m.plugin(name)
+}
@@ -0,0 +1,82 @@
+package symlink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/plugins"
+)
+
+// databasePlugin is a sync plugin whose settings live in a map, as they would in a database
+type databasePlugin map[string]string
+
+func (d databasePlugin) run(_ context.Context, _ string, stdin []byte) ([]byte, error) {
+	var request plugins.Request
+	if err := json.Unmarshal(stdin, &request); err != nil {
+		return nil, err
+	}
+	response := plugins.Response{}
+	switch request.Action {
+	case plugins.ActionExists:
+		_, response.Exists = d[request.Source]
+	case plugins.ActionExport:
+		if err := os.WriteFile(request.Store, []byte(d[request.Source]), 0644); err != nil {
+			response.Error = err.Error()
+		}
+	case plugins.ActionImport:
+		data, err := os.ReadFile(request.Store)
+		if err != nil {
+			response.Error = err.Error()
+		}
+		d[request.Source] = string(data)
+	}
+	return json.Marshal(response)
+}
+
+func TestSyncPluginPath(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	source := filepath.Join(tempDir, ".dbeaver", "settings.db")
+	database := databasePlugin{source: "theme=dark"}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	manager.SetPlugins(func(name string) (*plugins.Plugin, error) {
+		return plugins.New(name, tempDir).WithRunner(database.run), nil
+	})
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(source, "dbeaver/settings.json", config.PathTypeFile, true)
+	appConfig.Paths[0].Plugin = "sqlite-settings"
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	storeFile := filepath.Join(storeDir, "dbeaver", "settings.json")
+	if data, _ := os.ReadFile(storeFile); string(data) != "theme=dark" {
+		t.Errorf("Expected the settings exported to the store, got %q", data)
+	}
+	if manager.pathExists(source) {
+		t.Error("Expected nothing to be linked at the source")
+	}
+
+	// A machine without the settings imports them from the store
+	delete(database, source)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp on a new machine failed: %v", err)
+	}
+	if database[source] != "theme=dark" {
+		t.Errorf("Expected the settings imported from the store, got %q", database[source])
+	}
+
+	// Without the plugin, the path fails to sync instead of being linked
+	manager.SetPlugins(nil)
+	if err := manager.SyncApp(appConfig); err == nil {
+		t.Error("Expected an error syncing a plugin path without its plugin")
+	}
+}
@@ -0,0 +1,130 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+)
+
+// adaptToSandbox follows a path into or out of its app's sandbox container when the app has moved
+// there since the path was added (e.g. after switching to the App Store version), and switches paths
+// inside a container to copy mode, since the sandbox keeps apps from following symlinks out of it.
+// In dry-run mode the path is left alone and an adapted copy is returned.
+func (m *Manager) adaptToSandbox(appConfig *config.AppConfig, path *config.Path) *config.Path {
+	if m.dryRun {
+		adapted := *path
+		path = &adapted
+	}
+
+	sourcePath := m.expandPath(path.Source)
+	if !m.pathExists(sourcePath) && !m.isSymlink(sourcePath) {
+		if containerPath := config.ContainerPath(m.homeDir, appConfig.BundleID, path.Source); containerPath != "" && m.pathExists(m.expandPath(containerPath)) {
+			fmt.Fprintf(m.out, "  %s is sandboxed; using %s\n", appConfig.DisplayName, containerPath)
+			path.Source = containerPath
+		} else if uncontainedPath, _ := config.UncontainedPath(m.homeDir, path.Source); uncontainedPath != "" && m.pathExists(m.expandPath(uncontainedPath)) {
+			fmt.Fprintf(m.out, "  %s is no longer sandboxed; using %s\n", appConfig.DisplayName, uncontainedPath)
+			path.Source = uncontainedPath
+		}
+	}
+
+	if !path.IsCopyMode() && config.IsContainerPath(m.homeDir, m.expandPath(path.Source)) {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    %s is inside a sandbox container, which blocks symlinks; copying instead\n", path.Source)
+		}
+		path.Mode = config.PathModeCopy
+	}
+
+	return path
+}
+
+// syncCopyPath keeps a copy-mode path and its store copy in step. Whichever side changed since
+// the last sync is copied over the other; when both changed, the most recent change wins and the
+// local copy is backed up first.
+func (m *Manager) syncCopyPath(appConfig *config.AppConfig, path *config.Path) error {
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Copying: %s <-> %s\n", sourcePath, storePath)
+	}
+
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
+	}
+
+	// A symlink left from syncing the path in symlink mode is replaced by a copy of the store
+	if m.isSymlink(sourcePath) {
+		if !m.isCorrectSymlink(sourcePath, storePath) {
+			return fmt.Errorf("%s is a symlink that does not point to the store", sourcePath)
+		}
+		if err := m.removeExistingSymlink(sourcePath); err != nil {
+			return err
+		}
+		return m.copyBetween(storePath, sourcePath)
+	}
+
+	sourceExists, storeExists := m.pathExists(sourcePath), m.pathExists(storePath)
+	switch {
+	case !sourceExists && !storeExists:
+		return m.handleMissingPath(sourcePath, path)
+	case !storeExists:
+		if err := m.checkDirectorySize(sourcePath, nil); err != nil {
+			return err
+		}
+		return m.copyBetween(sourcePath, storePath)
+	case !sourceExists:
+		return m.copyBetween(storePath, sourcePath)
+	}
+
+	sourceTime, err := fsutil.LatestModTime(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", sourcePath, err)
+	}
+	storeTime, err := fsutil.LatestModTime(storePath)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", storePath, err)
+	}
+
+	sourceChanged := path.SyncedAt.IsZero() || sourceTime.After(path.SyncedAt)
+	storeChanged := !path.SyncedAt.IsZero() && storeTime.After(path.SyncedAt)
+	switch {
+	case storeChanged && (!sourceChanged || storeTime.After(sourceTime)):
+		if sourceChanged {
+			fmt.Fprintf(m.out, "    Warning: %s and its store copy both changed; keeping the newer store copy\n", sourcePath)
+		}
+		if !m.dryRun {
+			if err := m.backupBefore(appConfig, path); err != nil && m.verbose {
+				fmt.Fprintf(m.out, "    Warning: backup failed: %v\n", err)
+			}
+		}
+		return m.copyBetween(storePath, sourcePath)
+	case sourceChanged:
+		if err := m.checkDirectorySize(sourcePath, nil); err != nil {
+			return err
+		}
+		return m.copyBetween(sourcePath, storePath)
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Already up to date\n")
+	}
+	return nil
+}
+
+// copyBetween copies a copy-mode path in one direction
+func (m *Manager) copyBetween(src, dst string) error {
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would copy: %s -> %s\n", src, dst)
+		return nil
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Copying: %s -> %s\n", src, dst)
+	}
+	if err := m.copyPath(src, dst); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return nil
+}
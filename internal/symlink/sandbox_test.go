@@ -0,0 +1,143 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+// newSandboxTestManager creates a manager for a sandboxed app whose preferences exist only in its container
+func newSandboxTestManager(t *testing.T) (*Manager, *config.AppConfig, string, string) {
+	t.Helper()
+	// Paths in ~/Library are only synced on macOS
+	platform := config.CurrentPlatform
+	config.CurrentPlatform = config.PlatformDarwin
+	t.Cleanup(func() { config.CurrentPlatform = platform })
+
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+
+	containerFile := filepath.Join(tempDir, "Library", "Containers", "com.test.app", "Data", "Library", "Preferences", "com.test.app.plist")
+	if err := os.MkdirAll(filepath.Dir(containerFile), 0755); err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	if err := os.WriteFile(containerFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write container file: %v", err)
+	}
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.BundleID = "com.test.app"
+	appConfig.AddPath("~/Library/Preferences/com.test.app.plist", "Library/Preferences/com.test.app.plist", config.PathTypeFile, true)
+
+	return manager, appConfig, containerFile, filepath.Join(storeDir, "Library", "Preferences", "com.test.app.plist")
+}
+
+func TestSyncAppFollowsPathIntoContainer(t *testing.T) {
+	manager, appConfig, containerFile, storeFile := newSandboxTestManager(t)
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	path := appConfig.Paths[0]
+	if path.Source != "~/Library/Containers/com.test.app/Data/Library/Preferences/com.test.app.plist" {
+		t.Errorf("Expected the source to be translated into the container, got %s", path.Source)
+	}
+	if !path.IsCopyMode() {
+		t.Error("Expected a container path to fall back to copy mode")
+	}
+	if manager.isSymlink(containerFile) {
+		t.Error("Expected the container file not to be replaced by a symlink")
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != constants.TestConfiguration {
+		t.Errorf("Expected the container file to be copied into the store, got %q", data)
+	}
+}
+
+func TestSyncAppDryRunLeavesSandboxedPathAlone(t *testing.T) {
+	manager, appConfig, _, storeFile := newSandboxTestManager(t)
+	manager.dryRun = true
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if appConfig.Paths[0].Source != "~/Library/Preferences/com.test.app.plist" || appConfig.Paths[0].IsCopyMode() {
+		t.Errorf("Expected dry run not to change the path, got %+v", appConfig.Paths[0])
+	}
+	if manager.pathExists(storeFile) {
+		t.Error("Expected dry run not to copy into the store")
+	}
+}
+
+func TestSyncCopyPathDirection(t *testing.T) {
+	manager, appConfig, containerFile, storeFile := newSandboxTestManager(t)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	path := &appConfig.Paths[0]
+
+	// A change in the store, e.g. from another machine, is copied to the app
+	later := path.SyncedAt.Add(time.Minute)
+	if err := os.WriteFile(storeFile, []byte("from another mac"), 0644); err != nil {
+		t.Fatalf("Failed to update store file: %v", err)
+	}
+	if err := os.Chtimes(storeFile, later, later); err != nil {
+		t.Fatalf("Failed to set store file time: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if data, _ := os.ReadFile(containerFile); string(data) != "from another mac" {
+		t.Errorf("Expected the store change to be copied to the app, got %q", data)
+	}
+
+	// A change made by the app is copied to the store
+	later = path.SyncedAt.Add(time.Minute)
+	if err := os.WriteFile(containerFile, []byte("changed by app"), 0644); err != nil {
+		t.Fatalf("Failed to update container file: %v", err)
+	}
+	if err := os.Chtimes(containerFile, later, later); err != nil {
+		t.Fatalf("Failed to set container file time: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if data, _ := os.ReadFile(storeFile); string(data) != "changed by app" {
+		t.Errorf("Expected the app change to be copied to the store, got %q", data)
+	}
+}
+
+func TestSyncAppReplacesSymlinkInCopyMode(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceFile := filepath.Join(tempDir, ".testrc")
+	if err := os.WriteFile(sourceFile, []byte(constants.TestConfiguration), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	manager := NewManager(tempDir, filepath.Join(tempDir, "store"), filepath.Join(tempDir, "backup"), false, false)
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, ".testrc", config.PathTypeFile, true)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	appConfig.Paths[0].Mode = config.PathModeCopy
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected switching to copy mode to replace the symlink with a copy")
+	}
+	if data, _ := os.ReadFile(sourceFile); string(data) != constants.TestConfiguration {
+		t.Errorf("Expected the store copy at the source, got %q", data)
+	}
+}
@@ -0,0 +1,190 @@
+package symlink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/secrets"
+)
+
+// SetSecretBackends replaces how the backends that keep the content of secret paths are opened,
+// e.g. with in-memory backends in tests
+func (m *Manager) SetSecretBackends(open func(name string) (secrets.Backend, error)) {
+	m.secretBackend = open
+}
+
+// syncSecretPath keeps the content of a secret path in its backend, and a reference to it in
+// the store. As with copy-mode paths, the side that changed since the last sync wins: a changed
+// file is written to the backend, and content another machine wrote to the backend is written
+// to the file, after backing it up.
+func (m *Manager) syncSecretPath(appConfig *config.AppConfig, path *config.Path) error {
+	sourcePath := m.expandPath(path.Source)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "  Syncing secret: %s <-> %s\n", sourcePath, path.Secret)
+	}
+
+	if err := m.prepareCloudPath(storePath); err != nil {
+		return err
+	}
+
+	// A path synced before it was made a secret has its content in the store, which is moved
+	// to the source and then into the backend
+	if m.isSymlink(sourcePath) {
+		if !m.isCorrectSymlink(sourcePath, storePath) {
+			return fmt.Errorf("%s is a symlink that does not point to the store", sourcePath)
+		}
+		if err := m.removeExistingSymlink(sourcePath); err != nil {
+			return err
+		}
+	}
+	ref, err := m.readSecretReference(storePath)
+	if errors.Is(err, secrets.ErrNotReference) {
+		if !m.pathExists(sourcePath) || m.isSymlink(sourcePath) {
+			if err := m.copyBetween(storePath, sourcePath); err != nil {
+				return err
+			}
+		}
+		return m.storeSecret(appConfig, path, nil, sourcePath, storePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	sourceExists := m.pathExists(sourcePath)
+	switch {
+	case !sourceExists && ref == nil:
+		return m.handleMissingPath(sourcePath, path)
+	case ref == nil || ref.Backend != path.Secret:
+		if !sourceExists {
+			return m.materializeSecret(ref, sourcePath)
+		}
+		return m.storeSecret(appConfig, path, nil, sourcePath, storePath)
+	case !sourceExists:
+		return m.materializeSecret(ref, sourcePath)
+	}
+
+	info, err := m.fs.Stat(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", sourcePath, err)
+	}
+	sourceChanged := path.SyncedAt.IsZero() || info.ModTime().After(path.SyncedAt)
+	backendChanged := !path.SyncedAt.IsZero() && ref.UpdatedAt.After(path.SyncedAt)
+	switch {
+	case backendChanged && (!sourceChanged || ref.UpdatedAt.After(info.ModTime())):
+		if sourceChanged {
+			fmt.Fprintf(m.out, "    Warning: %s and its secret in %s both changed; keeping the newer secret\n", sourcePath, ref.Backend)
+		}
+		if !m.dryRun {
+			if err := m.backupBefore(appConfig, path); err != nil && m.verbose {
+				fmt.Fprintf(m.out, "    Warning: backup failed: %v\n", err)
+			}
+		}
+		return m.materializeSecret(ref, sourcePath)
+	case sourceChanged:
+		return m.storeSecret(appConfig, path, ref, sourcePath, storePath)
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Already up to date\n")
+	}
+	return nil
+}
+
+// storeSecret writes the content of a secret path to its backend, unless the backend already
+// has it, and the reference to it to the store
+func (m *Manager) storeSecret(appConfig *config.AppConfig, path *config.Path, ref *secrets.Reference, sourcePath, storePath string) error {
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would store %s in %s\n", sourcePath, path.Secret)
+		return nil
+	}
+
+	backend, err := m.secretBackend(path.Secret)
+	if err != nil {
+		return err
+	}
+	content, err := m.fs.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sourcePath, err)
+	}
+
+	item := secrets.ItemName(appConfig.Name, path.Destination)
+	if ref != nil {
+		item = ref.Item
+	}
+	if current, err := backend.Read(item); err == nil && bytes.Equal(current, content) && ref != nil {
+		if m.verbose {
+			fmt.Fprintf(m.out, "    Already up to date\n")
+		}
+		return nil
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Storing in %s: %s\n", path.Secret, item)
+	}
+	if err := backend.Write(item, content); err != nil {
+		return err
+	}
+
+	data, err := (&secrets.Reference{Backend: path.Secret, Item: item, UpdatedAt: time.Now()}).Encode()
+	if err != nil {
+		return err
+	}
+	if err := m.ensureStoreDirectory(storePath); err != nil {
+		return err
+	}
+	if err := m.fs.WriteFile(storePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write secret reference: %w", err)
+	}
+	return nil
+}
+
+// materializeSecret writes the content a reference points to at a secret path's source,
+// readable only by the user
+func (m *Manager) materializeSecret(ref *secrets.Reference, sourcePath string) error {
+	if m.dryRun {
+		fmt.Fprintf(m.out, "    [DRY RUN] Would write %s from %s\n", sourcePath, ref.Backend)
+		return nil
+	}
+
+	backend, err := m.secretBackend(ref.Backend)
+	if err != nil {
+		return err
+	}
+	content, err := backend.Read(ref.Item)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return fmt.Errorf("%s has no item %s; sync the path on a machine that has the file first", ref.Backend, ref.Item)
+	}
+	if err != nil {
+		return err
+	}
+
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Writing from %s: %s\n", ref.Backend, sourcePath)
+	}
+	if err := m.fs.MkdirAll(filepath.Dir(sourcePath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", sourcePath, err)
+	}
+	if err := m.fs.WriteFile(sourcePath, content, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sourcePath, err)
+	}
+	return nil
+}
+
+// readSecretReference reads the reference in the store at storePath, or returns nil when there
+// is none. It returns secrets.ErrNotReference when the store copy holds content instead.
+func (m *Manager) readSecretReference(storePath string) (*secrets.Reference, error) {
+	if !m.pathExists(storePath) {
+		return nil, nil
+	}
+	data, err := m.fs.ReadFile(storePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", storePath, err)
+	}
+	return secrets.ParseReference(data)
+}
@@ -0,0 +1,111 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+	"github.com/dotbrains/configsync/internal/secrets"
+)
+
+// memorySecrets is a secret backend that keeps items in memory
+type memorySecrets map[string][]byte
+
+func (s memorySecrets) Read(item string) ([]byte, error) {
+	content, ok := s[item]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+	return content, nil
+}
+
+func (s memorySecrets) Write(item string, content []byte) error {
+	s[item] = append([]byte(nil), content...)
+	return nil
+}
+
+func TestSyncSecretPath(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	sourceFile := filepath.Join(tempDir, ".npmrc")
+	if err := os.WriteFile(sourceFile, []byte("//registry.npmjs.org/:_authToken=secret"), 0600); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	keychain := memorySecrets{}
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	manager.SetSecretBackends(func(string) (secrets.Backend, error) { return keychain, nil })
+
+	// A path synced before it was made a secret moves its content out of the store
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath(sourceFile, ".npmrc", config.PathTypeFile, true)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	appConfig.Paths[0].Secret = secrets.Keychain
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	storeFile := filepath.Join(storeDir, ".npmrc")
+	data, _ := os.ReadFile(storeFile)
+	if _, err := secrets.ParseReference(data); err != nil || strings.Contains(string(data), "_authToken") {
+		t.Errorf("Expected the store to hold a reference only, got %q", data)
+	}
+	if manager.isSymlink(sourceFile) {
+		t.Error("Expected the symlink to be replaced by the file")
+	}
+	item := secrets.ItemName(constants.TestAppName, ".npmrc")
+	if string(keychain[item]) != "//registry.npmjs.org/:_authToken=secret" {
+		t.Errorf("Expected the content in the keychain, got %q", keychain[item])
+	}
+
+	// Another machine with only the store reference gets the file from the backend
+	otherHome := t.TempDir()
+	other := NewManager(otherHome, storeDir, filepath.Join(otherHome, "backup"), false, false)
+	other.out = &bytes.Buffer{}
+	other.SetSecretBackends(func(string) (secrets.Backend, error) { return keychain, nil })
+	otherApp := config.NewAppConfig(constants.TestAppName, "Test Application")
+	otherApp.AddPath(filepath.Join(otherHome, ".npmrc"), ".npmrc", config.PathTypeFile, true)
+	otherApp.Paths[0].Secret = secrets.Keychain
+	if err := other.SyncApp(otherApp); err != nil {
+		t.Fatalf("SyncApp on the other machine failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(otherHome, ".npmrc"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the file to be written readable only by the user, got %v", err)
+	}
+
+	// A change there reaches the first machine through the backend
+	later := otherApp.Paths[0].SyncedAt.Add(time.Minute)
+	if err := os.WriteFile(filepath.Join(otherHome, ".npmrc"), []byte("rotated"), 0600); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(otherHome, ".npmrc"), later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if err := other.SyncApp(otherApp); err != nil {
+		t.Fatalf("SyncApp on the other machine failed: %v", err)
+	}
+	ref, err := manager.readSecretReference(storeFile)
+	if err != nil {
+		t.Fatalf("Failed to read reference: %v", err)
+	}
+	appConfig.Paths[0].SyncedAt = ref.UpdatedAt.Add(-time.Minute)
+	past := ref.UpdatedAt.Add(-2 * time.Minute)
+	if err := os.Chtimes(sourceFile, past, past); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	if data, _ := os.ReadFile(sourceFile); string(data) != "rotated" {
+		t.Errorf("Expected the rotated secret, got %q", data)
+	}
+}
@@ -0,0 +1,145 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// LinkState classifies a managed path by comparing it with the symlink sync would create
+type LinkState string
+
+const (
+	// LinkCorrect is a symlink to the path's store copy
+	LinkCorrect LinkState = "correct"
+	// LinkBroken is a symlink whose target does not exist
+	LinkBroken LinkState = "broken"
+	// LinkWrongTarget is a symlink to an existing file or directory other than the store copy
+	LinkWrongTarget LinkState = "wrong_target"
+	// LinkReplaced is a regular file or directory an application wrote in place of its symlink
+	LinkReplaced LinkState = "replaced"
+	// LinkMissing is a path that does not exist although its store copy does
+	LinkMissing LinkState = "missing"
+	// LinkNotSynced is a path that has not been moved into the store yet, or exists in neither place
+	LinkNotSynced LinkState = "not_synced"
+)
+
+// RepairAction is the action that fixes a path's link
+type RepairAction string
+
+const (
+	// RepairNone means there is nothing to repair, or it cannot be repaired automatically
+	RepairNone RepairAction = ""
+	// RepairRelink replaces whatever is at the path with a symlink to the store copy
+	RepairRelink RepairAction = "relink"
+	// RepairHeal moves the file at the path into the store, archives the previous store copy, and relinks
+	RepairHeal RepairAction = "heal"
+)
+
+// LinkCheck is the state of one managed path and the action that repairs it
+type LinkCheck struct {
+	App       string       `json:"app" yaml:"app"`
+	Source    string       `json:"source" yaml:"source"`
+	StorePath string       `json:"store_path" yaml:"store_path"`
+	Target    string       `json:"target,omitempty" yaml:"target,omitempty"`
+	State     LinkState    `json:"state" yaml:"state"`
+	Repair    RepairAction `json:"repair,omitempty" yaml:"repair,omitempty"`
+	Hint      string       `json:"hint,omitempty" yaml:"hint,omitempty"`
+	index     int
+}
+
+// VerifyLinks classifies the symlinked paths of an application used on this platform, and each of
+// their links. Defaults, secret, and copy-mode paths have no symlink at their source, so only the
+// links of copy-mode paths are checked.
+func (m *Manager) VerifyLinks(appConfig *config.AppConfig) []LinkCheck {
+	var checks []LinkCheck
+	for i := range appConfig.Paths {
+		path := &appConfig.Paths[i]
+		if !path.AppliesTo(config.CurrentPlatform) || path.IsExported() || path.IsSecret() {
+			continue
+		}
+
+		locations := path.Links
+		if !m.adaptToSandbox(appConfig, path).IsCopyMode() {
+			locations = path.LiveLocations()
+		}
+		for _, location := range locations {
+			check := m.checkLink(m.expandPath(location), path)
+			check.App = appConfig.Name
+			check.index = i
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// checkLink classifies one location of a path
+func (m *Manager) checkLink(sourcePath string, path *config.Path) LinkCheck {
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	check := LinkCheck{Source: sourcePath, StorePath: storePath}
+	storeExists := m.pathExists(storePath)
+
+	if m.isSymlink(sourcePath) {
+		check.Target, _ = m.fs.Readlink(sourcePath)
+		switch {
+		case m.isCorrectSymlink(sourcePath, storePath) && storeExists:
+			check.State = LinkCorrect
+			return check
+		case m.pathExists(sourcePath):
+			check.State = LinkWrongTarget
+		default:
+			check.State = LinkBroken
+		}
+		if storeExists {
+			check.Repair = RepairRelink
+		} else {
+			check.Hint = "the store copy is missing; restore it with 'configsync restore' or 'configsync snapshot restore'"
+		}
+		return check
+	}
+
+	switch {
+	case isReplaced(m.fs, sourcePath, storePath, path):
+		check.State = LinkReplaced
+		check.Repair = RepairHeal
+	case !m.pathExists(sourcePath) && storeExists:
+		check.State = LinkMissing
+		check.Repair = RepairRelink
+	default:
+		check.State = LinkNotSynced
+		check.Hint = "run 'configsync sync' to move it into the store"
+	}
+	return check
+}
+
+// RepairLink applies a check's repair action to the application's path and marks it synced
+func (m *Manager) RepairLink(appConfig *config.AppConfig, check LinkCheck) error {
+	if check.index >= len(appConfig.Paths) {
+		return fmt.Errorf("path %s is not configured for %s", check.Source, appConfig.DisplayName)
+	}
+	path := &appConfig.Paths[check.index]
+
+	var err error
+	switch check.Repair {
+	case RepairRelink:
+		if m.isSymlink(check.Source) {
+			if err = m.removeExistingSymlink(check.Source); err != nil {
+				return err
+			}
+		}
+		err = m.createFinalSymlink(check.Source, check.StorePath)
+	case RepairHeal:
+		err = m.absorbReplaced(check.Source, check.StorePath, path)
+	default:
+		return fmt.Errorf("%s cannot be repaired automatically", check.Source)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !m.dryRun {
+		path.MarkSynced()
+	}
+	return nil
+}
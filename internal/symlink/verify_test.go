@@ -0,0 +1,92 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+func TestVerifyLinks(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	for _, name := range []string{"correct", "broken", "wrong", "missing", "replaced"} {
+		source := filepath.Join(tempDir, name)
+		if err := os.WriteFile(source, []byte(constants.TestConfiguration), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		appConfig.AddPath(source, name, config.PathTypeFile, false)
+	}
+	appConfig.AddPath(filepath.Join(tempDir, "unsynced"), "unsynced", config.PathTypeFile, false)
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+
+	other := filepath.Join(tempDir, "other")
+	if err := os.WriteFile(other, []byte(constants.TestHelloWorld), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	steps := []func() error{
+		func() error { return os.Remove(filepath.Join(storeDir, "broken")) },
+		func() error { return os.Remove(filepath.Join(tempDir, "wrong")) },
+		func() error { return os.Symlink(other, filepath.Join(tempDir, "wrong")) },
+		func() error { return os.Remove(filepath.Join(tempDir, "missing")) },
+		func() error { return os.Remove(filepath.Join(tempDir, "replaced")) },
+		func() error {
+			return os.WriteFile(filepath.Join(tempDir, "replaced"), []byte("rewritten by app"), 0644)
+		},
+		func() error { return os.WriteFile(filepath.Join(tempDir, "unsynced"), []byte("new"), 0644) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Failed to set up links: %v", err)
+		}
+	}
+
+	want := map[string]struct {
+		state  LinkState
+		repair RepairAction
+	}{
+		"correct":  {LinkCorrect, RepairNone},
+		"broken":   {LinkBroken, RepairNone},
+		"wrong":    {LinkWrongTarget, RepairRelink},
+		"missing":  {LinkMissing, RepairRelink},
+		"replaced": {LinkReplaced, RepairHeal},
+		"unsynced": {LinkNotSynced, RepairNone},
+	}
+	checks := manager.VerifyLinks(appConfig)
+	if len(checks) != len(want) {
+		t.Fatalf("Expected %d checks, got %d", len(want), len(checks))
+	}
+	for _, check := range checks {
+		expected := want[filepath.Base(check.Source)]
+		if check.State != expected.state || check.Repair != expected.repair {
+			t.Errorf("%s: got %s/%q, expected %s/%q", check.Source, check.State, check.Repair, expected.state, expected.repair)
+		}
+		if check.Repair == RepairNone {
+			continue
+		}
+		if err := manager.RepairLink(appConfig, check); err != nil {
+			t.Errorf("RepairLink(%s) failed: %v", check.Source, err)
+		}
+	}
+
+	for _, check := range manager.VerifyLinks(appConfig) {
+		if want[filepath.Base(check.Source)].repair != RepairNone && check.State != LinkCorrect {
+			t.Errorf("Expected %s to be repaired, got %s", check.Source, check.State)
+		}
+	}
+
+	// The replaced file was moved into the store
+	content, err := os.ReadFile(filepath.Join(storeDir, "replaced"))
+	if err != nil || string(content) != "rewritten by app" {
+		t.Errorf("Expected the replaced file in the store, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,62 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// followVersion moves a versioned path's source to the newest location matching its versions
+// pattern, e.g. from GoLand2024.1 to GoLand2024.2 after an IDE upgrade. The store copy is handed
+// back to the version that was linked, as a regular directory, so the new version's own settings
+// are moved into the store and linked in their place. In dry-run mode the path is left alone and
+// an updated copy is returned.
+func (m *Manager) followVersion(path *config.Path) (*config.Path, error) {
+	if path.Versions == "" {
+		return path, nil
+	}
+	newest := config.NewestVersion(m.homeDir, path.Versions)
+	if newest == "" || m.expandPath(newest) == m.expandPath(path.Source) {
+		return path, nil
+	}
+
+	currentPath := m.expandPath(path.Source)
+	newestPath := m.expandPath(newest)
+	storePath := filepath.Join(m.storeDir, path.Destination)
+	fmt.Fprintf(m.out, "  Found newer version %s; following it from %s\n", filepath.Base(newestPath), filepath.Base(currentPath))
+
+	if m.dryRun {
+		if m.isCorrectSymlink(currentPath, storePath) && m.pathExists(newestPath) && !m.isSymlink(newestPath) {
+			fmt.Fprintf(m.out, "    [DRY RUN] Would move the store copy back to %s\n", currentPath)
+		}
+		followed := *path
+		followed.Source = newest
+		return &followed, nil
+	}
+
+	if m.isCorrectSymlink(currentPath, storePath) && m.pathExists(newestPath) && !m.isSymlink(newestPath) {
+		if err := m.handBack(currentPath, storePath); err != nil {
+			return nil, err
+		}
+	}
+	path.Source = newest
+	return path, nil
+}
+
+// handBack replaces the symlink of the version being left with the store copy, emptying the store
+// for the new version's settings
+func (m *Manager) handBack(currentPath, storePath string) error {
+	if m.verbose {
+		fmt.Fprintf(m.out, "    Moving the store copy back to %s\n", currentPath)
+	}
+	if err := m.fs.Remove(currentPath); err != nil {
+		return fmt.Errorf("failed to remove symlink: %w", err)
+	}
+	if err := m.fs.Rename(storePath, currentPath); err != nil {
+		// Relink the version being left so it is as it was found
+		_ = m.createSymlink(storePath, currentPath)
+		return fmt.Errorf("failed to move the store copy back to %s: %w", currentPath, err)
+	}
+	return nil
+}
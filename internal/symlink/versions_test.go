@@ -0,0 +1,54 @@
+package symlink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/constants"
+)
+
+func TestSyncAppFollowsNewerVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	storeDir := filepath.Join(tempDir, "store")
+	oldVersion := filepath.Join(tempDir, ".config", "JetBrains", "GoLand2024.1")
+	newVersion := filepath.Join(tempDir, ".config", "JetBrains", "GoLand2024.2")
+	writeTestFile(t, filepath.Join(oldVersion, "options.xml"), "old settings")
+
+	manager := NewManager(tempDir, storeDir, filepath.Join(tempDir, "backup"), false, false)
+	manager.out = &bytes.Buffer{}
+	appConfig := config.NewAppConfig(constants.TestAppName, "Test Application")
+	appConfig.AddPath("~/.config/JetBrains/GoLand2024.1", ".config/JetBrains/GoLand", config.PathTypeDirectory, false)
+	appConfig.Paths[0].Versions = "~/.config/JetBrains/GoLand*"
+
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed: %v", err)
+	}
+	storePath := filepath.Join(storeDir, ".config", "JetBrains", "GoLand")
+	if !manager.isCorrectSymlink(oldVersion, storePath) {
+		t.Fatal("Expected the installed version to be linked")
+	}
+
+	writeTestFile(t, filepath.Join(newVersion, "options.xml"), "new settings")
+	if err := manager.SyncApp(appConfig); err != nil {
+		t.Fatalf("SyncApp failed after upgrade: %v", err)
+	}
+
+	if appConfig.Paths[0].Source != "~/.config/JetBrains/GoLand2024.2" {
+		t.Errorf("Expected the source to follow the new version, got %q", appConfig.Paths[0].Source)
+	}
+	if !manager.isCorrectSymlink(newVersion, storePath) {
+		t.Error("Expected the new version to be linked")
+	}
+	if manager.isSymlink(oldVersion) {
+		t.Error("Expected the old version to be a regular directory again")
+	}
+	if data, _ := os.ReadFile(filepath.Join(oldVersion, "options.xml")); string(data) != "old settings" {
+		t.Errorf("Expected the old version to get its settings back, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(storePath, "options.xml")); string(data) != "new settings" {
+		t.Errorf("Expected the store to hold the new version's settings, got %q", data)
+	}
+}
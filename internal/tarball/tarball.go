@@ -0,0 +1,435 @@
+// Package tarball writes and extracts tar archives, compressed with gzip or zstd or left
+// uncompressed, as used by bundles and compressed backups.
+package tarball
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/dotbrains/configsync/internal/fsops"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/fsys"
+)
+
+// Compression algorithms
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CheckLevel returns an error if a compression is unknown or does not support a level, where 0
+// is the algorithm's default. An empty compression stands for gzip.
+func CheckLevel(compression string, level int) error {
+	switch compression {
+	case "", Gzip:
+		if level != 0 && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+			return fmt.Errorf("gzip compression level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+	case Zstd:
+		if level != 0 && (level < 1 || level > 22) {
+			return fmt.Errorf("zstd compression level must be between 1 and 22")
+		}
+	case None:
+		if level != 0 {
+			return fmt.Errorf("a compression level needs gzip or zstd compression")
+		}
+	default:
+		return fmt.Errorf("unsupported compression: %s (use %s, %s, or %s)", compression, None, Gzip, Zstd)
+	}
+	return nil
+}
+
+// Extension returns the file extension of a tar archive with a compression
+func Extension(compression string) string {
+	switch compression {
+	case None:
+		return ".tar"
+	case Zstd:
+		return ".tar.zst"
+	default:
+		return ".tar.gz"
+	}
+}
+
+// CompressionOf returns the compression of a tar archive named with one of the extensions
+// Extension returns, and false for other names
+func CompressionOf(name string) (string, bool) {
+	for _, compression := range []string{Gzip, Zstd, None} {
+		if strings.HasSuffix(name, Extension(compression)) {
+			return compression, true
+		}
+	}
+	return "", false
+}
+
+// DetectCompression returns the compression of an archive from its first bytes
+func DetectCompression(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return Zstd
+	default:
+		return None
+	}
+}
+
+// Writer writes a tar archive. Symlinks are stored as links, a file hard-linked to one already
+// stored as a hard link to it, and headers in PAX format so long paths and sub-second
+// modification times survive. Ownership is left out, since archives are extracted by other
+// users on other machines.
+type Writer struct {
+	ctx        context.Context
+	tw         *tar.Writer
+	compressor io.WriteCloser // Nil for uncompressed archives
+	stored     map[fsops.FileID]string
+}
+
+// NewWriter starts a tar archive written to w with a compression and level, where 0 is the
+// algorithm's default. An empty compression stands for gzip.
+func NewWriter(ctx context.Context, w io.Writer, compression string, level int) (*Writer, error) {
+	if err := CheckLevel(compression, level); err != nil {
+		return nil, err
+	}
+
+	writer := &Writer{ctx: ctx, stored: make(map[fsops.FileID]string)}
+	switch compression {
+	case None:
+		writer.tw = tar.NewWriter(w)
+	case Zstd:
+		var options []zstd.EOption
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		encoder, err := zstd.NewWriter(w, options...)
+		if err != nil {
+			return nil, err
+		}
+		writer.compressor = encoder
+		writer.tw = tar.NewWriter(encoder)
+	default:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gzWriter, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		writer.compressor = gzWriter
+		writer.tw = tar.NewWriter(gzWriter)
+	}
+	return writer, nil
+}
+
+// Add writes an entry with a slash-separated name. content is read for regular files, and link
+// is the target of a symlink.
+func (w *Writer) Add(name string, info fs.FileInfo, link string, content io.Reader) error {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	header.Format = tar.FormatPAX
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+
+	if id, linked := fsops.HardLinked(info); linked {
+		if first, ok := w.stored[id]; ok {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = first
+			header.Size = 0
+			if err := w.tw.WriteHeader(header); err != nil {
+				return err
+			}
+			// Read the content anyway, since the caller may be hashing it
+			if content != nil {
+				_, err = fsutil.CopyContext(w.ctx, io.Discard, content)
+			}
+			return err
+		}
+		w.stored[id] = header.Name
+	}
+
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if content == nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	_, err = fsutil.CopyContext(w.ctx, w.tw, content)
+	return err
+}
+
+// AddFile writes a regular file with a slash-separated name that is generated rather than read
+// from disk
+func (w *Writer) AddFile(name string, data []byte) error {
+	header := &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Format:   tar.FormatPAX,
+	}
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(data)
+	return err
+}
+
+// AddTree adds a file, symlink, or directory as it is under name, leaving out the entries skip
+// returns true for; skip may be nil
+func (w *Writer) AddTree(files fsys.FS, root, name string, skip fsops.SkipFunc) error {
+	return fsys.Walk(files, root, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		if relPath != "." && skip != nil && skip(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entryName := path.Join(name, filepath.ToSlash(relPath))
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := files.Readlink(current)
+			if err != nil {
+				return err
+			}
+			return w.Add(entryName, info, target, nil)
+		case info.IsDir():
+			return w.Add(entryName, info, "", nil)
+		case info.Mode().IsRegular():
+			file, err := files.Open(current)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+			return w.Add(entryName, info, "", file)
+		default:
+			return nil
+		}
+	})
+}
+
+// Close finishes the archive, flushing its compression
+func (w *Writer) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
+
+// NewReader reads a tar archive, decompressing it according to its magic bytes. The returned
+// function releases the decompressor.
+func NewReader(r io.Reader) (*tar.Reader, func(), error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch DetectCompression(magic) {
+	case Gzip:
+		gzReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gzReader), func() { _ = gzReader.Close() }, nil
+	case Zstd:
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(decoder), decoder.Close, nil
+	default:
+		return tar.NewReader(buffered), func() {}, nil
+	}
+}
+
+// Verify reads every entry of a tar archive of any compression, returning an error if it is
+// truncated or does not decompress
+func Verify(ctx context.Context, r io.Reader) error {
+	tarReader, release, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for {
+		_, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := fsutil.CopyContext(ctx, io.Discard, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// Extract unpacks a tar archive of any compression into targetDir, streaming each file.
+// Symlinks are created after every other entry, so no entry can be written through one, and
+// directory permissions and modification times are applied last, so writing their contents
+// does not change them.
+func Extract(ctx context.Context, files fsys.FS, r io.Reader, targetDir string) error {
+	tarReader, release, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var symlinks, dirs []*tar.Header
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path, err := EntryPath(targetDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := files.MkdirAll(path, os.FileMode(header.Mode).Perm()|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, header)
+		case tar.TypeReg:
+			if err := extractFile(ctx, files, tarReader, header, path); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			target, err := EntryPath(targetDir, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := files.Link(target, path); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			symlinks = append(symlinks, header)
+		}
+	}
+
+	for _, header := range symlinks {
+		path, _ := EntryPath(targetDir, header.Name)
+		if BelowSymlink(files, targetDir, path) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		if err := files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := files.Symlink(header.Linkname, path); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path, _ := EntryPath(targetDir, dirs[i].Name)
+		if err := files.Chmod(path, os.FileMode(dirs[i].Mode).Perm()); err != nil {
+			return err
+		}
+		if err := files.Chtimes(path, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractFile writes the regular file at the reader's current entry to path, with the entry's
+// permissions and modification time
+func extractFile(ctx context.Context, files fsys.FS, tarReader *tar.Reader, header *tar.Header, path string) error {
+	if err := files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(header.Mode).Perm()
+	file, err := files.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.CopyContext(ctx, file, tarReader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = files.Remove(path)
+		return err
+	}
+
+	if err := files.Chmod(path, mode); err != nil {
+		return err
+	}
+	return files.Chtimes(path, header.ModTime, header.ModTime)
+}
+
+// EntryPath returns where an archive entry is extracted in targetDir, rejecting names that
+// would escape it
+func EntryPath(targetDir, name string) (string, error) {
+	root := filepath.Clean(targetDir)
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if path != root && !strings.HasPrefix(path, root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid path in archive: %s", name)
+	}
+	return path, nil
+}
+
+// BelowSymlink reports whether a directory between targetDir and path is a symlink, through
+// which creating path would land outside targetDir
+func BelowSymlink(files fsys.FS, targetDir, path string) bool {
+	root := filepath.Clean(targetDir)
+	for dir := filepath.Dir(path); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if fsys.IsSymlink(files, dir) {
+			return true
+		}
+	}
+	return false
+}
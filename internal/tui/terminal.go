@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Key is a key press read from the terminal
+type Key string
+
+// Keys with a name of their own; any other key is the character it types
+const (
+	KeyUp     Key = "up"
+	KeyDown   Key = "down"
+	KeyLeft   Key = "left"
+	KeyRight  Key = "right"
+	KeyEnter  Key = "enter"
+	KeyEscape Key = "esc"
+	KeySpace  Key = "space"
+	KeyCtrlC  Key = "ctrl+c"
+)
+
+// ANSI escape sequences used to draw the interface
+const (
+	clearScreen     = "\x1b[H\x1b[2J"
+	enterAltScreen  = "\x1b[?1049h"
+	leaveAltScreen  = "\x1b[?1049l"
+	hideCursor      = "\x1b[?25l"
+	showCursor      = "\x1b[?25h"
+	reverseVideo    = "\x1b[7m"
+	resetAttributes = "\x1b[0m"
+)
+
+// Terminal reads single key presses and draws full screens on a terminal
+type Terminal struct {
+	in    *bufio.Reader
+	out   io.Writer
+	file  *os.File
+	saved string // stty settings to restore when leaving raw mode
+}
+
+// NewTerminal creates a terminal reading keys from in and drawing to out.
+// The reader should be shared with any other prompts reading the same input.
+func NewTerminal(file *os.File, in *bufio.Reader, out io.Writer) *Terminal {
+	return &Terminal{
+		in:   in,
+		out:  out,
+		file: file,
+	}
+}
+
+// Start switches the terminal to the alternate screen and reads keys without waiting for Enter
+func (t *Terminal) Start() error {
+	saved, err := t.stty("-g")
+	if err != nil {
+		return fmt.Errorf("failed to read terminal settings: %w", err)
+	}
+	t.saved = strings.TrimSpace(saved)
+
+	// Keep output processing so newlines still return the cursor, but deliver
+	// keys (including Ctrl+C) immediately and without echo
+	if _, err := t.stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return fmt.Errorf("failed to configure terminal: %w", err)
+	}
+
+	_, _ = io.WriteString(t.out, enterAltScreen+hideCursor)
+	return nil
+}
+
+// Stop restores the terminal settings and screen saved by Start
+func (t *Terminal) Stop() error {
+	_, _ = io.WriteString(t.out, showCursor+leaveAltScreen)
+	if t.saved == "" {
+		return nil
+	}
+
+	if _, err := t.stty(t.saved); err != nil {
+		return fmt.Errorf("failed to restore terminal settings: %w", err)
+	}
+	t.saved = ""
+	return nil
+}
+
+// Draw replaces the screen with the given content
+func (t *Terminal) Draw(screen string) {
+	_, _ = io.WriteString(t.out, clearScreen+screen)
+}
+
+// ReadKey waits for the next key press
+func (t *Terminal) ReadKey() (Key, error) {
+	return readKey(t.in)
+}
+
+// stty runs stty on the terminal and returns its output
+func (t *Terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.file
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+// readKey decodes one key press, including the escape sequences sent for arrow keys
+func readKey(in *bufio.Reader) (Key, error) {
+	r, _, err := in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return KeyEnter, nil
+	case ' ':
+		return KeySpace, nil
+	case 0x03:
+		return KeyCtrlC, nil
+	case 0x1b:
+		// A lone Escape arrives by itself; arrow keys arrive as one sequence
+		if in.Buffered() == 0 {
+			return KeyEscape, nil
+		}
+		next, err := in.ReadByte()
+		if err != nil {
+			return KeyEscape, nil
+		}
+		if next != '[' && next != 'O' {
+			_ = in.UnreadByte()
+			return KeyEscape, nil
+		}
+		code, err := in.ReadByte()
+		if err != nil {
+			return KeyEscape, nil
+		}
+		switch code {
+		case 'A':
+			return KeyUp, nil
+		case 'B':
+			return KeyDown, nil
+		case 'C':
+			return KeyRight, nil
+		case 'D':
+			return KeyLeft, nil
+		}
+		// Skip the rest of sequences we do not handle, such as "\x1b[3~"
+		for code >= '0' && code <= '9' || code == ';' {
+			if code, err = in.ReadByte(); err != nil {
+				break
+			}
+		}
+		return KeyEscape, nil
+	}
+
+	return Key(string(r)), nil
+}
+
+// Run shows the interface until the user quits. Tasks are run with the terminal
+// restored so their output and prompts work as they do outside the interface.
+func Run(term *Terminal, model *Model) (err error) {
+	if err := term.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		if stopErr := term.Stop(); err == nil {
+			err = stopErr
+		}
+	}()
+
+	for !model.Quit() {
+		term.Draw(model.View())
+
+		key, err := term.ReadKey()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		task := model.Update(key)
+		if task == nil {
+			continue
+		}
+
+		if err := term.Stop(); err != nil {
+			return err
+		}
+		fmt.Fprintf(term.out, "%s...\n\n", task.Title)
+		if taskErr := task.Run(); taskErr != nil {
+			model.SetMessage(fmt.Sprintf("✗ %s failed: %v", task.Title, taskErr))
+		} else {
+			model.SetMessage(fmt.Sprintf("✓ %s finished", task.Title))
+		}
+		fmt.Fprint(term.out, "\nPress Enter to return")
+		_, _ = term.in.ReadString('\n')
+
+		if err := term.Start(); err != nil {
+			return err
+		}
+		if err := model.Reload(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadKey(t *testing.T) {
+	input := "\x1b[A\x1b[B\x1b[C\x1b[D\r\n x\x03\x1b[3~q"
+	want := []Key{KeyUp, KeyDown, KeyRight, KeyLeft, KeyEnter, KeyEnter, KeySpace, "x", KeyCtrlC, KeyEscape, "q"}
+
+	in := bufio.NewReader(strings.NewReader(input))
+	for i, expected := range want {
+		key, err := readKey(in)
+		if err != nil {
+			t.Fatalf("readKey() #%d error = %v", i, err)
+		}
+		if key != expected {
+			t.Errorf("readKey() #%d = %q, want %q", i, key, expected)
+		}
+	}
+
+	if _, err := readKey(in); err != io.EOF {
+		t.Errorf("readKey() at end of input error = %v, want EOF", err)
+	}
+}
+
+func TestReadKeyLoneEscape(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("\x1b"))
+	key, err := readKey(in)
+	if err != nil {
+		t.Fatalf("readKey() error = %v", err)
+	}
+	if key != KeyEscape {
+		t.Errorf("readKey() = %q, want %q", key, KeyEscape)
+	}
+}
@@ -0,0 +1,353 @@
+// Package tui provides an interactive terminal interface for browsing and managing synced applications.
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dotbrains/configsync/internal/messages"
+)
+
+// State is the status of the managed applications shown in the interface
+type State struct {
+	StorePath     string
+	CloudProvider string
+	Apps          []App
+}
+
+// App is the sync status of one managed application
+type App struct {
+	Name        string
+	DisplayName string
+	Paths       []Path
+	Conflicts   []string // Conflicted copies in the store, relative to the store
+	Synced      int
+	Enabled     bool
+}
+
+// Path is the sync status of one configuration path
+type Path struct {
+	Source      string
+	Destination string
+	Status      string
+}
+
+// Backup is one backup of a configuration path
+type Backup struct {
+	CreatedAt time.Time
+	Source    string
+	Version   string
+	Size      int64
+}
+
+// Handler loads the application status and performs the actions chosen in the interface
+type Handler interface {
+	Load() (*State, error)
+	SetEnabled(app string, enabled bool) error
+	Backups(app string) ([]Backup, error)
+	// Sync and Restore write their progress to the terminal, so they are run outside the interface
+	Sync(app string) error
+	Restore(app string, backup *Backup) error
+}
+
+// Task is an action that writes to the terminal and runs with the interface suspended
+type Task struct {
+	Run   func() error
+	Title string
+}
+
+// view is a screen of the interface
+type view int
+
+const (
+	viewApps view = iota
+	viewPaths
+	viewBackups
+)
+
+// Model holds what the interface shows and updates it in response to key presses
+type Model struct {
+	state   *State
+	handler Handler
+	confirm *Task // Task waiting for the user to confirm
+	message string
+	backups []Backup
+	view    view
+	cursor  int
+	backup  int
+	quit    bool
+}
+
+// NewModel creates a model showing the state loaded by the handler
+func NewModel(handler Handler) (*Model, error) {
+	m := &Model{handler: handler}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reload refreshes the application status, keeping the selection where possible
+func (m *Model) Reload() error {
+	state, err := m.handler.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load status: %w", err)
+	}
+	m.state = state
+	if m.cursor >= len(state.Apps) {
+		m.cursor = max(len(state.Apps)-1, 0)
+	}
+	if m.view == viewBackups {
+		m.loadBackups()
+	}
+	return nil
+}
+
+// Quit reports whether the user asked to leave the interface
+func (m *Model) Quit() bool {
+	return m.quit
+}
+
+// SetMessage shows a message in the status line
+func (m *Model) SetMessage(message string) {
+	m.message = message
+}
+
+// Update handles a key press. It returns a task when the key asked for an action
+// that has to run outside the interface.
+func (m *Model) Update(key Key) *Task {
+	if key == KeyCtrlC {
+		m.quit = true
+		return nil
+	}
+
+	if m.confirm != nil {
+		task := m.confirm
+		m.confirm = nil
+		if key == "y" || key == "Y" {
+			return task
+		}
+		m.message = "Cancelled"
+		return nil
+	}
+
+	m.message = ""
+	switch m.view {
+	case viewPaths:
+		return m.updatePaths(key)
+	case viewBackups:
+		return m.updateBackups(key)
+	}
+	return m.updateApps(key)
+}
+
+// View renders the current screen
+func (m *Model) View() string {
+	var b strings.Builder
+
+	b.WriteString("ConfigSync\n")
+	if m.state.CloudProvider != "" {
+		fmt.Fprintf(&b, "Store: %s (synced by %s)\n\n", m.state.StorePath, m.state.CloudProvider)
+	} else {
+		fmt.Fprintf(&b, "Store: %s\n\n", m.state.StorePath)
+	}
+
+	switch m.view {
+	case viewPaths:
+		m.viewPaths(&b)
+	case viewBackups:
+		m.viewBackups(&b)
+	default:
+		m.viewApps(&b)
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.confirm != nil:
+		fmt.Fprintf(&b, "%s? [y/N]\n", m.confirm.Title)
+	case m.message != "":
+		b.WriteString(m.message + "\n")
+	}
+	return b.String()
+}
+
+func (m *Model) updateApps(key Key) *Task {
+	switch key {
+	case KeyUp, "k":
+		m.cursor = max(m.cursor-1, 0)
+	case KeyDown, "j":
+		m.cursor = min(m.cursor+1, max(len(m.state.Apps)-1, 0))
+	case KeyEnter, KeyRight, "l":
+		if m.selected() != nil {
+			m.view = viewPaths
+		}
+	case "q", KeyEscape:
+		m.quit = true
+	default:
+		return m.appAction(key)
+	}
+	return nil
+}
+
+func (m *Model) updatePaths(key Key) *Task {
+	switch key {
+	case KeyEscape, KeyLeft, "h", "q":
+		m.view = viewApps
+		return nil
+	}
+	return m.appAction(key)
+}
+
+func (m *Model) updateBackups(key Key) *Task {
+	switch key {
+	case KeyUp, "k":
+		m.backup = max(m.backup-1, 0)
+	case KeyDown, "j":
+		m.backup = min(m.backup+1, max(len(m.backups)-1, 0))
+	case KeyEscape, KeyLeft, "h", "q":
+		m.view = viewApps
+	case KeyEnter, "r":
+		app := m.selected()
+		if app == nil || len(m.backups) == 0 {
+			return nil
+		}
+		name, backup := app.Name, m.backups[m.backup]
+		m.confirm = &Task{
+			Title: fmt.Sprintf("Restore %s from the backup of %s", backup.Source, backup.CreatedAt.Format("2006-01-02 15:04:05")),
+			Run:   func() error { return m.handler.Restore(name, &backup) },
+		}
+	}
+	return nil
+}
+
+// appAction handles the keys acting on the selected application, shared by the apps and paths screens
+func (m *Model) appAction(key Key) *Task {
+	app := m.selected()
+	if app == nil {
+		return nil
+	}
+	name := app.Name
+
+	switch key {
+	case KeySpace, "e":
+		verb := "Enabled"
+		if app.Enabled {
+			verb = "Disabled"
+		}
+		message := fmt.Sprintf("✓ %s %s", verb, app.DisplayName)
+		if err := m.handler.SetEnabled(app.Name, !app.Enabled); err != nil {
+			m.message = fmt.Sprintf("✗ %v", err)
+			return nil
+		}
+		if err := m.Reload(); err != nil {
+			m.message = fmt.Sprintf("✗ %v", err)
+			return nil
+		}
+		m.message = message
+	case "s":
+		return &Task{
+			Title: fmt.Sprintf("Syncing %s", app.DisplayName),
+			Run:   func() error { return m.handler.Sync(name) },
+		}
+	case "r":
+		m.confirm = &Task{
+			Title: fmt.Sprintf("Restore %s from its latest backup", app.DisplayName),
+			Run:   func() error { return m.handler.Restore(name, nil) },
+		}
+	case "b":
+		m.view = viewBackups
+		m.backup = 0
+		m.loadBackups()
+	}
+	return nil
+}
+
+func (m *Model) viewApps(b *strings.Builder) {
+	if len(m.state.Apps) == 0 {
+		b.WriteString(messages.Localize(messages.NoAppsConfigured, nil) + "\n")
+	}
+
+	for i, app := range m.state.Apps {
+		enabled := "[ ]"
+		if app.Enabled {
+			enabled = "[x]"
+		}
+		line := fmt.Sprintf("%s %-28s %d/%d paths synced", enabled, app.DisplayName, app.Synced, len(app.Paths))
+		if len(app.Conflicts) > 0 {
+			line += fmt.Sprintf("  ✗ %d conflict(s)", len(app.Conflicts))
+		}
+		writeRow(b, line, i == m.cursor)
+	}
+
+	b.WriteString("\n↑/↓ move  enter paths  space enable/disable  s sync  r restore  b backups  q quit\n")
+}
+
+func (m *Model) viewPaths(b *strings.Builder) {
+	app := m.selected()
+	fmt.Fprintf(b, "%s (%s)\n\n", app.DisplayName, app.Name)
+	for _, path := range app.Paths {
+		fmt.Fprintf(b, "  %-14s %s -> %s\n", path.Status, path.Source, path.Destination)
+	}
+
+	if len(app.Conflicts) > 0 {
+		b.WriteString("\nCloud sync conflicts:\n")
+		for _, conflict := range app.Conflicts {
+			fmt.Fprintf(b, "  ✗ %s\n", conflict)
+		}
+		b.WriteString("Run 'configsync store conflicts --resolve <strategy>' to resolve them\n")
+	}
+
+	b.WriteString("\nesc back  space enable/disable  s sync  r restore  b backups\n")
+}
+
+func (m *Model) viewBackups(b *strings.Builder) {
+	app := m.selected()
+	fmt.Fprintf(b, "Backups of %s\n\n", app.DisplayName)
+	if len(m.backups) == 0 {
+		b.WriteString("No backups found.\n")
+	}
+
+	for i, backup := range m.backups {
+		line := fmt.Sprintf("%s  %-40s %d bytes", backup.CreatedAt.Format("2006-01-02 15:04:05"), backup.Source, backup.Size)
+		writeRow(b, line, i == m.backup)
+	}
+
+	b.WriteString("\n↑/↓ move  enter restore  esc back\n")
+}
+
+// selected returns the application under the cursor, or nil when there are none
+func (m *Model) selected() *App {
+	if m.cursor >= len(m.state.Apps) {
+		return nil
+	}
+	return &m.state.Apps[m.cursor]
+}
+
+// loadBackups lists the backups of the selected application
+func (m *Model) loadBackups() {
+	m.backups = nil
+	app := m.selected()
+	if app == nil {
+		return
+	}
+
+	backups, err := m.handler.Backups(app.Name)
+	if err != nil {
+		m.message = fmt.Sprintf("✗ Failed to list backups: %v", err)
+		return
+	}
+	m.backups = backups
+	if m.backup >= len(backups) {
+		m.backup = max(len(backups)-1, 0)
+	}
+}
+
+// writeRow writes a list row, highlighting it when it is selected
+func writeRow(b *strings.Builder, line string, selected bool) {
+	if selected {
+		b.WriteString(reverseVideo + "> " + line + resetAttributes + "\n")
+		return
+	}
+	b.WriteString("  " + line + "\n")
+}
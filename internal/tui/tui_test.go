@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHandler records the actions requested by the model
+type fakeHandler struct {
+	state    *State
+	backups  []Backup
+	restored []string
+	synced   []string
+	failSet  bool
+}
+
+func (h *fakeHandler) Load() (*State, error) {
+	return h.state, nil
+}
+
+func (h *fakeHandler) SetEnabled(app string, enabled bool) error {
+	if h.failSet {
+		return fmt.Errorf("read-only configuration")
+	}
+	for i := range h.state.Apps {
+		if h.state.Apps[i].Name == app {
+			h.state.Apps[i].Enabled = enabled
+		}
+	}
+	return nil
+}
+
+func (h *fakeHandler) Backups(string) ([]Backup, error) {
+	return h.backups, nil
+}
+
+func (h *fakeHandler) Sync(app string) error {
+	h.synced = append(h.synced, app)
+	return nil
+}
+
+func (h *fakeHandler) Restore(app string, backup *Backup) error {
+	if backup != nil {
+		app += "@" + backup.Version
+	}
+	h.restored = append(h.restored, app)
+	return nil
+}
+
+func newTestModel(t *testing.T) (*Model, *fakeHandler) {
+	t.Helper()
+	handler := &fakeHandler{
+		state: &State{
+			StorePath:     "/store",
+			CloudProvider: "Dropbox",
+			Apps: []App{
+				{Name: "git", DisplayName: "Git", Enabled: true, Synced: 1, Paths: []Path{{Source: "~/.gitconfig", Destination: "git/.gitconfig", Status: "synced"}}},
+				{Name: "vscode", DisplayName: "VS Code", Synced: 0, Paths: []Path{{Source: "~/Library/Application Support/Code/User/settings.json", Destination: "vscode/settings.json", Status: "not_synced"}},
+					Conflicts: []string{"vscode/settings (Alice's conflicted copy).json"}},
+			},
+		},
+		backups: []Backup{
+			{CreatedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), Source: "/home/user/.gitconfig", Version: "20240302-100000", Size: 120},
+			{CreatedAt: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), Source: "/home/user/.gitconfig", Version: "20240301-090000", Size: 100},
+		},
+	}
+
+	model, err := NewModel(handler)
+	if err != nil {
+		t.Fatalf("NewModel() error = %v", err)
+	}
+	return model, handler
+}
+
+func TestModelNavigation(t *testing.T) {
+	model, _ := newTestModel(t)
+
+	model.Update(KeyUp)
+	if model.cursor != 0 {
+		t.Errorf("cursor = %d after moving up from the top, want 0", model.cursor)
+	}
+
+	model.Update(KeyDown)
+	model.Update("j")
+	if model.cursor != 1 {
+		t.Errorf("cursor = %d after moving past the bottom, want 1", model.cursor)
+	}
+
+	model.Update(KeyEnter)
+	if model.view != viewPaths {
+		t.Fatalf("view = %v after enter, want paths", model.view)
+	}
+	screen := model.View()
+	for _, want := range []string{"VS Code (vscode)", "not_synced", "conflicted copy", "store conflicts --resolve"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("paths screen missing %q:\n%s", want, screen)
+		}
+	}
+
+	model.Update(KeyEscape)
+	if model.view != viewApps || model.Quit() {
+		t.Errorf("escape on the paths screen should return to the apps screen")
+	}
+
+	model.Update("q")
+	if !model.Quit() {
+		t.Errorf("q on the apps screen should quit")
+	}
+}
+
+func TestModelViewApps(t *testing.T) {
+	model, _ := newTestModel(t)
+
+	screen := model.View()
+	for _, want := range []string{"/store (synced by Dropbox)", "[x] Git", "[ ] VS Code", "1/1 paths synced", "1 conflict(s)"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("apps screen missing %q:\n%s", want, screen)
+		}
+	}
+	if !strings.Contains(screen, reverseVideo+"> [x] Git") {
+		t.Errorf("selected app should be highlighted:\n%s", screen)
+	}
+}
+
+func TestModelToggleEnabled(t *testing.T) {
+	model, handler := newTestModel(t)
+
+	if task := model.Update(KeySpace); task != nil {
+		t.Fatalf("toggling should not need a task")
+	}
+	if handler.state.Apps[0].Enabled {
+		t.Errorf("Git should have been disabled")
+	}
+	if !strings.Contains(model.View(), "✓ Disabled Git") {
+		t.Errorf("missing confirmation message:\n%s", model.View())
+	}
+
+	handler.failSet = true
+	model.Update("e")
+	if !strings.Contains(model.View(), "✗ read-only configuration") {
+		t.Errorf("missing error message:\n%s", model.View())
+	}
+}
+
+func TestModelSyncAndRestore(t *testing.T) {
+	model, handler := newTestModel(t)
+
+	task := model.Update("s")
+	if task == nil {
+		t.Fatal("sync should return a task")
+	}
+	if err := task.Run(); err != nil {
+		t.Fatalf("sync task error = %v", err)
+	}
+	if len(handler.synced) != 1 || handler.synced[0] != "git" {
+		t.Errorf("synced = %v, want [git]", handler.synced)
+	}
+
+	// Restoring asks for confirmation first
+	if task := model.Update("r"); task != nil {
+		t.Fatal("restore should ask for confirmation before returning a task")
+	}
+	if !strings.Contains(model.View(), "Restore Git from its latest backup? [y/N]") {
+		t.Errorf("missing confirmation prompt:\n%s", model.View())
+	}
+	if task := model.Update("n"); task != nil {
+		t.Fatal("declining should not return a task")
+	}
+	if len(handler.restored) != 0 {
+		t.Errorf("restored = %v after declining", handler.restored)
+	}
+
+	model.Update("r")
+	task = model.Update("y")
+	if task == nil {
+		t.Fatal("confirming should return the restore task")
+	}
+	_ = task.Run()
+	if len(handler.restored) != 1 || handler.restored[0] != "git" {
+		t.Errorf("restored = %v, want [git]", handler.restored)
+	}
+}
+
+func TestModelBrowseBackups(t *testing.T) {
+	model, handler := newTestModel(t)
+
+	model.Update("b")
+	if model.view != viewBackups {
+		t.Fatalf("view = %v after b, want backups", model.view)
+	}
+	screen := model.View()
+	if !strings.Contains(screen, "Backups of Git") || !strings.Contains(screen, "2024-03-01 09:00:00") {
+		t.Errorf("backups screen missing backups:\n%s", screen)
+	}
+
+	model.Update(KeyDown)
+	model.Update(KeyEnter)
+	task := model.Update("y")
+	if task == nil {
+		t.Fatal("confirming should return the restore task")
+	}
+	_ = task.Run()
+	if len(handler.restored) != 1 || handler.restored[0] != "git@20240301-090000" {
+		t.Errorf("restored = %v, want [git@20240301-090000]", handler.restored)
+	}
+
+	model.Update(KeyLeft)
+	if model.view != viewApps {
+		t.Errorf("left on the backups screen should return to the apps screen")
+	}
+}
+
+func TestModelNoApps(t *testing.T) {
+	handler := &fakeHandler{state: &State{StorePath: "/store"}}
+	model, err := NewModel(handler)
+	if err != nil {
+		t.Fatalf("NewModel() error = %v", err)
+	}
+
+	for _, key := range []Key{KeyDown, KeyEnter, KeySpace, "s", "r", "b"} {
+		if task := model.Update(key); task != nil {
+			t.Errorf("key %q returned a task without apps", key)
+		}
+	}
+	if !strings.Contains(model.View(), "No applications configured") {
+		t.Errorf("missing empty message:\n%s", model.View())
+	}
+}
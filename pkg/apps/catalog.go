@@ -0,0 +1,326 @@
+package apps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+const (
+	// CatalogDirName is the directory in the ConfigSync config directory holding user catalogs
+	CatalogDirName = "catalog"
+	// CommunityCatalogFile is the file in the catalog directory written by catalog updates
+	CommunityCatalogFile = "community.yaml"
+	// DefaultCatalogURL is where community catalog updates are downloaded from
+	DefaultCatalogURL = "https://raw.githubusercontent.com/dotbrains/configsync/main/pkg/apps/catalog.yaml"
+	// OriginBuiltin marks definitions from the catalog embedded in the binary
+	OriginBuiltin = "builtin"
+
+	maxCatalogSize = 10 << 20
+)
+
+// Catalog is the file format of an application catalog
+type Catalog struct {
+	Apps []*AppInfo `yaml:"apps"`
+}
+
+//go:embed catalog.yaml
+var builtinCatalog []byte
+
+// knownApps contains configuration information for commonly used macOS applications
+var knownApps = mustParseCatalog(builtinCatalog, OriginBuiltin)
+
+// ParseCatalog parses and validates a catalog, returning its definitions keyed by normalized name
+func ParseCatalog(data []byte, origin string) (map[string]*AppInfo, error) {
+	var catalog Catalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+
+	apps := make(map[string]*AppInfo, len(catalog.Apps))
+	for i, app := range catalog.Apps {
+		if app == nil {
+			return nil, fmt.Errorf("catalog entry %d is empty", i+1)
+		}
+		if err := validateAppInfo(app); err != nil {
+			return nil, err
+		}
+		app.Name = normalizeAppName(app.Name)
+		if app.DisplayName == "" {
+			app.DisplayName = app.Name
+		}
+		app.Origin = origin
+		apps[app.Name] = app
+	}
+
+	return apps, nil
+}
+
+// LoadCatalogDir reads the user catalogs in a directory. The community catalog is read first
+// so that the user's own files override it; other files are read in name order.
+func LoadCatalogDir(dir string) (map[string]*AppInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalogs: %w", err)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return filepath.Base(files[i]) == CommunityCatalogFile && filepath.Base(files[j]) != CommunityCatalogFile
+	})
+
+	apps := make(map[string]*AppInfo)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read catalog %s: %w", file, err)
+		}
+		parsed, err := ParseCatalog(data, strings.TrimSuffix(filepath.Base(file), ".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid catalog %s: %w", file, err)
+		}
+		for name, app := range parsed {
+			apps[name] = app
+		}
+	}
+
+	return apps, nil
+}
+
+// LoadUserCatalog adds the catalogs in a directory to the detector's definitions,
+// replacing built-in definitions with the same name
+func (d *AppDetector) LoadUserCatalog(dir string) error {
+	userApps, err := LoadCatalogDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(userApps) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]*AppInfo, len(d.catalog)+len(userApps))
+	for name, app := range d.catalog {
+		merged[name] = app
+	}
+	for name, app := range userApps {
+		merged[name] = app
+	}
+	d.catalog = merged
+
+	return nil
+}
+
+// Catalog returns the application definitions known to the detector, sorted by name
+func (d *AppDetector) Catalog() []*AppInfo {
+	apps := make([]*AppInfo, 0, len(d.catalog))
+	for _, app := range d.catalog {
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps
+}
+
+// FetchCatalog downloads a catalog and validates it before it is installed. Catalogs are only
+// downloaded over HTTPS unless digest pins them to a SHA-256 digest, which is then verified.
+func FetchCatalog(catalogURL, digest string) ([]byte, error) {
+	parsed, err := url.Parse(catalogURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog URL %s: %w", catalogURL, err)
+	}
+	if parsed.Scheme != "https" && digest == "" {
+		return nil, fmt.Errorf("refusing to download %s without HTTPS or a SHA-256 digest to verify it; use HTTPS or pass --sha256", catalogURL)
+	}
+	if decoded, err := hex.DecodeString(digest); digest != "" && (err != nil || len(decoded) != sha256.Size) {
+		return nil, fmt.Errorf("invalid SHA-256 digest %q (expected %d hex characters)", digest, 2*sha256.Size)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(catalogURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download catalog: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download catalog: %w", err)
+	}
+
+	if sum := sha256.Sum256(data); digest != "" && !strings.EqualFold(hex.EncodeToString(sum[:]), digest) {
+		return nil, fmt.Errorf("catalog checksum mismatch: expected %s, got %x", digest, sum)
+	}
+
+	if _, err := ParseCatalog(data, ""); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// CatalogHooks returns the post-sync commands of a catalog's entries that differ from the
+// built-in definitions, keyed by normalized application name. 'configsync add' configures
+// them, and sync runs them in a shell, so they are reviewed before the catalog is installed.
+func CatalogHooks(data []byte) (map[string][]string, error) {
+	parsed, err := ParseCatalog(data, "")
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := make(map[string][]string)
+	for name, app := range parsed {
+		if len(app.PostSync) == 0 {
+			continue
+		}
+		if builtin, ok := knownApps[name]; ok && slices.Equal(builtin.PostSync, app.PostSync) {
+			continue
+		}
+		hooks[name] = app.PostSync
+	}
+	return hooks, nil
+}
+
+// StripCatalogHooks removes the post-sync commands of the named entries from a catalog
+func StripCatalogHooks(data []byte, names []string) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return data, nil
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "apps" {
+			continue
+		}
+		for _, entry := range root.Content[i+1].Content {
+			stripEntryHooks(entry, names)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, fmt.Errorf("failed to write catalog: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write catalog: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// InstallCatalog validates a catalog and writes it to the catalog directory under the given file name
+func InstallCatalog(dir, fileName string, data []byte) (string, error) {
+	if _, err := ParseCatalog(data, ""); err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(fileName, ".yaml") {
+		fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".yaml"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write catalog: %w", err)
+	}
+
+	return path, nil
+}
+
+// Helper methods
+
+// validateAppInfo checks that a catalog entry can be used to configure an application
+func validateAppInfo(app *AppInfo) error {
+	if app.Name == "" {
+		return fmt.Errorf("catalog entry is missing a name")
+	}
+	if len(app.Paths) == 0 {
+		return fmt.Errorf("catalog entry %s has no paths", app.Name)
+	}
+
+	for _, path := range app.Paths {
+		if path.Source == "" || path.Destination == "" {
+			return fmt.Errorf("catalog entry %s has a path without source or destination", app.Name)
+		}
+		if filepath.IsAbs(path.Destination) || strings.HasPrefix(filepath.Clean(path.Destination), "..") {
+			return fmt.Errorf("catalog entry %s: destination %s must be relative to the store", app.Name, path.Destination)
+		}
+		switch path.Type {
+		case config.PathTypeFile, config.PathTypeDirectory, config.PathTypeGlob, config.PathTypeDefaults:
+		default:
+			return fmt.Errorf("catalog entry %s: invalid path type %q", app.Name, path.Type)
+		}
+		if path.Plugin != "" && path.Type != config.PathTypeFile && path.Type != config.PathTypeDirectory {
+			return fmt.Errorf("catalog entry %s: plugin path %s must be a file or directory", app.Name, path.Source)
+		}
+		for _, platform := range path.Platforms {
+			if !slices.Contains(config.Platforms, platform) {
+				return fmt.Errorf("catalog entry %s: unknown platform %q", app.Name, platform)
+			}
+		}
+		if _, err := filepath.Match(path.Source, ""); path.Versioned && err != nil {
+			return fmt.Errorf("catalog entry %s: versioned source %s is not a valid pattern", app.Name, path.Source)
+		}
+		for _, previous := range path.Previous {
+			if !filepath.IsAbs(previous) && !strings.HasPrefix(previous, "~/") {
+				return fmt.Errorf("catalog entry %s: previous location %s must be absolute or start with ~/", app.Name, previous)
+			}
+		}
+	}
+
+	return nil
+}
+
+// stripEntryHooks removes the post_sync key of a catalog entry node if it is one of the named entries
+func stripEntryHooks(entry *yaml.Node, names []string) {
+	if entry.Kind != yaml.MappingNode {
+		return
+	}
+	named := false
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if entry.Content[i].Value == "name" && slices.Contains(names, normalizeAppName(entry.Content[i+1].Value)) {
+			named = true
+		}
+	}
+	if !named {
+		return
+	}
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if entry.Content[i].Value == "post_sync" {
+			entry.Content = append(entry.Content[:i], entry.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// mustParseCatalog parses the embedded catalog, which is validated by the tests
+func mustParseCatalog(data []byte, origin string) map[string]*AppInfo {
+	apps, err := ParseCatalog(data, origin)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in catalog: %v", err))
+	}
+	return apps
+}
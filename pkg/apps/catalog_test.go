@@ -0,0 +1,188 @@
+package apps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCatalog = `apps:
+  - name: My App
+    bundle_id: com.foo.myapp
+    paths:
+      - source: ~/.myapprc
+        destination: .myapprc
+        type: file
+`
+
+func TestBuiltinCatalog(t *testing.T) {
+	if len(knownApps) == 0 {
+		t.Fatal("Expected built-in catalog to define applications")
+	}
+	for name, app := range knownApps {
+		if app.Origin != OriginBuiltin {
+			t.Errorf("Expected %s to come from the built-in catalog, got %q", name, app.Origin)
+		}
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	apps, err := ParseCatalog([]byte(testCatalog), "mine")
+	if err != nil {
+		t.Fatalf("ParseCatalog failed: %v", err)
+	}
+
+	app, exists := apps["myapp"]
+	if !exists {
+		t.Fatalf("Expected entry keyed by normalized name, got %v", apps)
+	}
+	if app.DisplayName != "myapp" || app.Origin != "mine" {
+		t.Errorf("Unexpected entry: %+v", app)
+	}
+
+	invalid := []string{
+		"apps: [{paths: [{source: ~/.a, destination: .a, type: file}]}]",
+		"apps: [{name: a}]",
+		"apps: [{name: a, paths: [{source: ~/.a, destination: /abs, type: file}]}]",
+		"apps: [{name: a, paths: [{source: ~/.a, destination: .a, type: socket}]}]",
+		"apps: [{name: a, paths: [{source: ~/.a, destination: .a, type: file, previous: [.b]}]}]",
+		"apps: {",
+	}
+	for _, data := range invalid {
+		if _, err := ParseCatalog([]byte(data), "test"); err == nil {
+			t.Errorf("Expected error for catalog %q", data)
+		}
+	}
+}
+
+func TestLoadUserCatalog(t *testing.T) {
+	tempDir := t.TempDir()
+	catalogDir := filepath.Join(tempDir, CatalogDirName)
+
+	override := `apps:
+  - name: git
+    display_name: Git (custom)
+    paths:
+      - source: ~/.config/git/config
+        destination: .config/git/config
+        type: file
+`
+	community := `apps:
+  - name: git
+    display_name: Git (community)
+    paths:
+      - source: ~/.gitconfig
+        destination: .gitconfig
+        type: file
+`
+	if _, err := InstallCatalog(catalogDir, "a-mine.yaml", []byte(override)); err != nil {
+		t.Fatalf("InstallCatalog failed: %v", err)
+	}
+	if _, err := InstallCatalog(catalogDir, CommunityCatalogFile, []byte(community)); err != nil {
+		t.Fatalf("InstallCatalog failed: %v", err)
+	}
+	if _, err := InstallCatalog(catalogDir, "myapp", []byte(testCatalog)); err != nil {
+		t.Fatalf("InstallCatalog failed: %v", err)
+	}
+
+	detector := NewAppDetector(tempDir)
+	if err := detector.LoadUserCatalog(catalogDir); err != nil {
+		t.Fatalf("LoadUserCatalog failed: %v", err)
+	}
+
+	git := detector.catalog["git"]
+	if git.DisplayName != "Git (custom)" || git.Origin != "a-mine" {
+		t.Errorf("Expected user catalog to override community and built-in entries, got %+v", git)
+	}
+	if _, exists := detector.catalog["myapp"]; !exists {
+		t.Error("Expected user catalog entry to be added")
+	}
+	if knownApps["git"].Origin != OriginBuiltin {
+		t.Error("Loading a user catalog must not modify the built-in catalog")
+	}
+
+	if err := os.WriteFile(filepath.Join(catalogDir, "broken.yaml"), []byte("apps: [{name: x}]"), 0644); err != nil {
+		t.Fatalf("Failed to write catalog: %v", err)
+	}
+	if err := NewAppDetector(tempDir).LoadUserCatalog(catalogDir); err == nil {
+		t.Error("Expected invalid user catalog to be reported")
+	}
+}
+
+func TestFetchCatalog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/catalog.yaml":
+			_, _ = w.Write([]byte(testCatalog))
+		case "/invalid.yaml":
+			_, _ = w.Write([]byte("apps: [{name: x}]"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte(testCatalog))
+	digest := hex.EncodeToString(sum[:])
+
+	data, err := FetchCatalog(server.URL+"/catalog.yaml", digest)
+	if err != nil {
+		t.Fatalf("FetchCatalog failed: %v", err)
+	}
+	if string(data) != testCatalog {
+		t.Errorf("Unexpected catalog content: %s", data)
+	}
+
+	if _, err := FetchCatalog(server.URL+"/catalog.yaml", ""); err == nil || !strings.Contains(err.Error(), "HTTPS") {
+		t.Errorf("Expected a plain HTTP catalog without a digest to be rejected, got %v", err)
+	}
+	if _, err := FetchCatalog(server.URL+"/catalog.yaml", strings.Repeat("0", 64)); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a catalog with another digest to be rejected, got %v", err)
+	}
+	if _, err := FetchCatalog(server.URL+"/invalid.yaml", digest); err == nil {
+		t.Error("Expected invalid catalog to be rejected")
+	}
+	if _, err := FetchCatalog(server.URL+"/missing.yaml", digest); err == nil {
+		t.Error("Expected error for missing catalog")
+	}
+}
+
+func TestStripCatalogHooks(t *testing.T) {
+	catalog := testCatalog + `    post_sync:
+      - curl https://example.com/setup.sh | sh
+  - name: karabiner-elements
+    paths:
+      - source: ~/.config/karabiner
+        destination: .config/karabiner
+        type: directory
+    post_sync:
+` + "      - " + knownApps["karabiner-elements"].PostSync[0] + "\n"
+
+	hooks, err := CatalogHooks([]byte(catalog))
+	if err != nil {
+		t.Fatalf("CatalogHooks failed: %v", err)
+	}
+	if len(hooks) != 1 || len(hooks["myapp"]) != 1 {
+		t.Fatalf("Expected only the commands that differ from the built-in catalog, got %v", hooks)
+	}
+
+	stripped, err := StripCatalogHooks([]byte(catalog), []string{"myapp"})
+	if err != nil {
+		t.Fatalf("StripCatalogHooks failed: %v", err)
+	}
+	parsed, err := ParseCatalog(stripped, "")
+	if err != nil {
+		t.Fatalf("Stripped catalog is invalid: %v", err)
+	}
+	if len(parsed["myapp"].PostSync) != 0 || parsed["myapp"].BundleID != "com.foo.myapp" {
+		t.Errorf("Expected only the post-sync commands to be removed, got %+v", parsed["myapp"])
+	}
+	if len(parsed["karabiner-elements"].PostSync) != 1 {
+		t.Error("Expected the commands of other entries to be kept")
+	}
+}
@@ -0,0 +1,242 @@
+package apps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/gitsource"
+	"github.com/dotbrains/configsync/internal/secrets"
+)
+
+// ParsePathSpec parses a path given on the command line, e.g. "~/.myapprc" or
+// "~/.config/myapp:type=directory:dest=.config/myapp:required". Each link= option adds
+// another location symlinked to the same store copy, versioned makes the path a glob of
+// versioned directories whose newest match is used, and secret= keeps a file's content in the
+// keychain or 1Password, and plugin= has a plugin export the path's settings instead of linking
+// it. repo= checks the directory out from a git repository, e.g.
+// "~/.config/nvim:ref=main:repo=github.com/me/dotfiles//nvim"; since URLs may contain colons,
+// it must be the last option. Without an explicit type, directories are detected from the
+// filesystem and everything else is a file.
+// Without an explicit destination, the path relative to the home directory is used.
+func (d *AppDetector) ParsePathSpec(appName, spec string) (PathInfo, error) {
+	parts := strings.Split(spec, ":")
+	if parts[0] == "" {
+		return PathInfo{}, fmt.Errorf("empty path in %q", spec)
+	}
+
+	info := PathInfo{Source: d.expandPath(parts[0])}
+options:
+	for i, option := range parts[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "type":
+			switch pathType := config.PathType(value); pathType {
+			case config.PathTypeFile, config.PathTypeDirectory, config.PathTypeGlob:
+				info.Type = pathType
+			default:
+				return PathInfo{}, fmt.Errorf("invalid path type %q in %q (expected file, directory, or glob)", value, spec)
+			}
+		case "dest":
+			if value == "" || filepath.IsAbs(value) {
+				return PathInfo{}, fmt.Errorf("destination in %q must be a relative path", spec)
+			}
+			info.Destination = filepath.Clean(value)
+		case "link":
+			link := d.expandPath(value)
+			if !filepath.IsAbs(link) {
+				return PathInfo{}, fmt.Errorf("link in %q must be absolute or start with ~/", spec)
+			}
+			info.Links = append(info.Links, link)
+		case "required":
+			info.Required = true
+		case "versioned":
+			info.Versioned = true
+		case "secret":
+			if value != secrets.Keychain && value != secrets.OnePassword {
+				return PathInfo{}, fmt.Errorf("invalid secret backend %q in %q (expected %s or %s)", value, spec, secrets.Keychain, secrets.OnePassword)
+			}
+			info.Secret = value
+		case "plugin":
+			if value == "" || value != filepath.Base(value) {
+				return PathInfo{}, fmt.Errorf("invalid plugin %q in %q (expected the name of a plugin)", value, spec)
+			}
+			info.Plugin = value
+		case "ref":
+			if value == "" {
+				return PathInfo{}, fmt.Errorf("empty ref in %q", spec)
+			}
+			info.Ref = value
+		case "repo":
+			repo := strings.Join(append([]string{value}, parts[i+2:]...), ":")
+			if _, err := gitsource.Parse(repo); err != nil {
+				return PathInfo{}, fmt.Errorf("invalid repo in %q: %w", spec, err)
+			}
+			info.Repo = repo
+			break options
+		default:
+			return PathInfo{}, fmt.Errorf("unknown path option %q in %q", key, spec)
+		}
+	}
+
+	if !filepath.IsAbs(info.Source) {
+		return PathInfo{}, fmt.Errorf("path %q must be absolute or start with ~/", parts[0])
+	}
+
+	if info.Versioned {
+		if _, err := filepath.Match(info.Source, ""); err != nil {
+			return PathInfo{}, fmt.Errorf("versioned path %q is not a valid pattern", parts[0])
+		}
+		if info.Destination == "" {
+			return PathInfo{}, fmt.Errorf("versioned path %q needs a dest= option, since its versions share one store copy", parts[0])
+		}
+	}
+
+	if info.Secret != "" && (info.Versioned || len(info.Links) > 0 || (info.Type != "" && info.Type != config.PathTypeFile)) {
+		return PathInfo{}, fmt.Errorf("secret path %q must be a single file without links or versions", parts[0])
+	}
+
+	if info.Plugin != "" && (info.Secret != "" || info.Versioned || len(info.Links) > 0 || (info.Type != "" && info.Type != config.PathTypeFile && info.Type != config.PathTypeDirectory)) {
+		return PathInfo{}, fmt.Errorf("plugin path %q must be a file or directory without secrets, links, or versions", parts[0])
+	}
+
+	if info.Ref != "" && info.Repo == "" {
+		return PathInfo{}, fmt.Errorf("ref in %q needs a repo= option", spec)
+	}
+
+	if info.Repo != "" {
+		if info.Secret != "" || info.Plugin != "" || info.Versioned || (info.Type != "" && info.Type != config.PathTypeDirectory) {
+			return PathInfo{}, fmt.Errorf("path %q checked out from a repository must be a directory without secrets or versions", parts[0])
+		}
+		info.Type = config.PathTypeDirectory
+	}
+
+	if info.Type == "" {
+		info.Type = config.PathTypeFile
+		source, _ := d.resolveVersion(info)
+		if stat, err := os.Stat(source); err == nil && stat.IsDir() {
+			info.Type = config.PathTypeDirectory
+		}
+	}
+
+	if info.Destination == "" {
+		info.Destination = d.defaultDestination(appName, info.Source)
+	}
+
+	return info, nil
+}
+
+// CustomApp builds an application configuration from user-supplied paths rather than detection.
+// When a bundle ID is given and its preferences file exists, the preferences file is included too.
+func (d *AppDetector) CustomApp(appName, bundleID string, paths []PathInfo) (*config.AppConfig, error) {
+	appConfig := config.NewAppConfig(normalizeAppName(appName), appName)
+	appConfig.BundleID = bundleID
+
+	if bundleID != "" {
+		prefsPath := filepath.Join(d.homeDir, "Library", "Preferences", bundleID+".plist")
+		if _, err := os.Stat(prefsPath); err == nil && !containsSource(paths, prefsPath) {
+			relPath := filepath.Join("Library", "Preferences", bundleID+".plist")
+			appConfig.AddPath(prefsPath, relPath, config.PathTypeFile, false)
+		}
+	}
+
+	for _, path := range paths {
+		source, versions := d.resolveVersion(path)
+		appConfig.AddPath(source, path.Destination, path.Type, path.Required)
+		appConfig.Paths[len(appConfig.Paths)-1].Links = path.Links
+		appConfig.Paths[len(appConfig.Paths)-1].Versions = versions
+		appConfig.Paths[len(appConfig.Paths)-1].Secret = path.Secret
+		appConfig.Paths[len(appConfig.Paths)-1].Repo = path.Repo
+		appConfig.Paths[len(appConfig.Paths)-1].Ref = path.Ref
+		appConfig.Paths[len(appConfig.Paths)-1].Plugin = path.Plugin
+	}
+
+	if len(appConfig.Paths) == 0 {
+		return nil, fmt.Errorf("no configuration paths given for app: %s", appName)
+	}
+
+	d.recordMetadata(appConfig)
+	return appConfig, nil
+}
+
+// CandidatePaths returns every configuration path that detection finds for an application,
+// for the user to accept or reject individually
+func (d *AppDetector) CandidatePaths(appName, bundleID string) []PathInfo {
+	var candidates []PathInfo
+	add := func(appConfig *config.AppConfig) {
+		if appConfig == nil {
+			return
+		}
+		for _, path := range appConfig.Paths {
+			if containsSource(candidates, path.Source) {
+				continue
+			}
+			candidate := PathInfo{
+				Source:      path.Source,
+				Destination: path.Destination,
+				Type:        path.Type,
+				Exclude:     path.Exclude,
+				Links:       path.Links,
+				Required:    path.Required,
+			}
+			if path.Versions != "" {
+				candidate.Source, candidate.Versioned = path.Versions, true
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if appConfig, err := d.DetectApp(appName); err == nil {
+		if bundleID == "" {
+			bundleID = appConfig.BundleID
+		}
+		add(appConfig)
+	}
+
+	add(d.smartDetectApp(InstalledApp{
+		Name:        normalizeAppName(appName),
+		DisplayName: appName,
+		BundleID:    bundleID,
+	}))
+
+	return candidates
+}
+
+// Helper methods
+
+// defaultDestination returns the store path for a source: relative to the home directory
+// when possible, otherwise under a directory named after the application
+func (d *AppDetector) defaultDestination(appName, source string) string {
+	if relPath, err := filepath.Rel(d.homeDir, source); err == nil && !strings.HasPrefix(relPath, "..") {
+		return relPath
+	}
+	return filepath.Join(normalizeAppName(appName), filepath.Base(source))
+}
+
+// normalizeAppName returns the configuration key for an application name
+func normalizeAppName(appName string) string {
+	return strings.ToLower(strings.ReplaceAll(appName, " ", ""))
+}
+
+// containsSource reports whether a path list already includes a source path
+func containsSource(paths []PathInfo, source string) bool {
+	for _, path := range paths {
+		if path.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// anyExists reports whether any of the paths exists
+func anyExists(paths []string) bool {
+	for _, path := range paths {
+		if fsutil.PathExists(path) {
+			return true
+		}
+	}
+	return false
+}
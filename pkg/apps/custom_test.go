@@ -0,0 +1,123 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestParsePathSpec(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, dir := range []string{filepath.Join(".config", "myapp"), filepath.Join(".config", "MyIDE2024.1")} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create config directory: %v", err)
+		}
+	}
+
+	detector := NewAppDetector(tempDir)
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected PathInfo
+	}{
+		{
+			name:     "file relative to home",
+			spec:     "~/.myapprc",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".myapprc"), Destination: ".myapprc", Type: config.PathTypeFile},
+		},
+		{
+			name:     "existing directory detected",
+			spec:     "~/.config/myapp",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".config", "myapp"), Destination: ".config/myapp", Type: config.PathTypeDirectory},
+		},
+		{
+			name:     "explicit options",
+			spec:     "~/.myapp:type=directory:dest=myapp/state:required",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".myapp"), Destination: "myapp/state", Type: config.PathTypeDirectory, Required: true},
+		},
+		{
+			name:     "links",
+			spec:     "~/.config/myapp/config:link=~/.myapprc:link=/etc/myapp.conf",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".config", "myapp", "config"), Destination: ".config/myapp/config", Type: config.PathTypeFile, Links: []string{filepath.Join(tempDir, ".myapprc"), "/etc/myapp.conf"}},
+		},
+		{
+			name:     "versioned directory",
+			spec:     "~/.config/MyIDE*:dest=myide:versioned",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".config", "MyIDE*"), Destination: "myide", Type: config.PathTypeDirectory, Versioned: true},
+		},
+		{
+			name:     "git repository",
+			spec:     "~/.config/nvim:ref=main:repo=https://github.com/me/dotfiles//nvim",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".config", "nvim"), Destination: ".config/nvim", Type: config.PathTypeDirectory, Repo: "https://github.com/me/dotfiles//nvim", Ref: "main"},
+		},
+		{
+			name:     "plugin",
+			spec:     "~/.dbeaver/settings.db:plugin=sqlite-settings:dest=dbeaver/settings.json",
+			expected: PathInfo{Source: filepath.Join(tempDir, ".dbeaver", "settings.db"), Destination: "dbeaver/settings.json", Type: config.PathTypeFile, Plugin: "sqlite-settings"},
+		},
+		{
+			name:     "outside home directory",
+			spec:     "/etc/myapp.conf",
+			expected: PathInfo{Source: "/etc/myapp.conf", Destination: "myapp/myapp.conf", Type: config.PathTypeFile},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := detector.ParsePathSpec("MyApp", tt.spec)
+			if err != nil {
+				t.Fatalf("ParsePathSpec failed: %v", err)
+			}
+			if info.Source != tt.expected.Source || info.Destination != tt.expected.Destination ||
+				info.Type != tt.expected.Type || info.Required != tt.expected.Required || info.Repo != tt.expected.Repo || info.Ref != tt.expected.Ref || info.Plugin != tt.expected.Plugin || strings.Join(info.Links, ",") != strings.Join(tt.expected.Links, ",") {
+				t.Errorf("Expected %+v, got %+v", tt.expected, info)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "relative/path", "~/.myapprc:type=socket", "~/.myapprc:dest=/abs", "~/.myapprc:mode=600", "~/.myapprc:link=relative", "~/.config/MyIDE*:versioned", "~/.config/nvim:ref=main", "~/.vimrc:type=file:repo=github.com/me/dotfiles", "~/.db:plugin=../sqlite", "~/.db:plugin=sqlite:secret=keychain"} {
+		if _, err := detector.ParsePathSpec("myapp", spec); err == nil {
+			t.Errorf("Expected error for spec %q", spec)
+		}
+	}
+}
+
+func TestCustomApp(t *testing.T) {
+	tempDir := t.TempDir()
+	prefsDir := filepath.Join(tempDir, "Library", "Preferences")
+	if err := os.MkdirAll(prefsDir, 0755); err != nil {
+		t.Fatalf("Failed to create prefs directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(prefsDir, "com.foo.myapp.plist"), []byte("plist"), 0644); err != nil {
+		t.Fatalf("Failed to create plist: %v", err)
+	}
+
+	detector := NewAppDetector(tempDir)
+	path, err := detector.ParsePathSpec("My App", "~/.myapprc")
+	if err != nil {
+		t.Fatalf("ParsePathSpec failed: %v", err)
+	}
+
+	appConfig, err := detector.CustomApp("My App", "com.foo.myapp", []PathInfo{path})
+	if err != nil {
+		t.Fatalf("CustomApp failed: %v", err)
+	}
+
+	if appConfig.Name != "myapp" || appConfig.DisplayName != "My App" || appConfig.BundleID != "com.foo.myapp" {
+		t.Errorf("Unexpected app identity: %+v", appConfig)
+	}
+	if len(appConfig.Paths) != 2 {
+		t.Fatalf("Expected preferences plist and custom path, got %+v", appConfig.Paths)
+	}
+	if appConfig.Paths[0].Destination != filepath.Join("Library", "Preferences", "com.foo.myapp.plist") {
+		t.Errorf("Expected preferences plist first, got %s", appConfig.Paths[0].Destination)
+	}
+
+	if _, err := detector.CustomApp("empty", "", nil); err == nil {
+		t.Error("Expected error for app without paths")
+	}
+}
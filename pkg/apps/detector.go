@@ -2,30 +2,43 @@
 package apps
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsops"
 	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/plugins"
 )
 
-// AppDetector handles detection and configuration of macOS applications
+// AppDetector handles detection and configuration of applications
 type AppDetector struct {
 	lastScanTime  time.Time
+	catalog       map[string]*AppInfo
+	listFormulae  func() []string                       // Installed Homebrew formulae, replaced in tests
+	findBundle    func(bundleID string) InstalledBundle // Installed application bundles, replaced in tests
 	homeDir       string
+	platform      string
+	scanCachePath string
 	installedApps []InstalledApp
+	plugins       []*plugins.Plugin // Plugins asked for the applications they detect
+	pluginApps    []string          // Applications detected by plugins, once pluginsAsked
+	pluginErrors  []error
+	pluginsAsked  bool
 	cacheDuration time.Duration
 }
 
 // NewAppDetector creates a new application detector
 func NewAppDetector(homeDir string) *AppDetector {
 	return &AppDetector{
+		catalog:       knownApps,
+		listFormulae:  installedFormulae,
+		findBundle:    findInstalledBundle,
 		homeDir:       homeDir,
+		platform:      config.CurrentPlatform,
 		installedApps: []InstalledApp{},
 		cacheDuration: 5 * time.Minute, // Cache for 5 minutes
 	}
@@ -37,6 +50,7 @@ func (d *AppDetector) DetectApp(appName string) (*config.AppConfig, error) {
 	normalizedName := strings.ToLower(strings.ReplaceAll(appName, " ", ""))
 
 	// Try to find app configuration using various strategies
+	d.detectPluginApps()
 	if appConfig := d.detectKnownApp(normalizedName); appConfig != nil {
 		return appConfig, nil
 	}
@@ -59,192 +73,50 @@ type InstalledApp struct {
 	Path        string `json:"path"`
 	Version     string `json:"version"`
 	DisplayName string `json:"display_name"`
+	CLI         bool   `json:"cli,omitempty"` // A command-line tool found on PATH or installed with Homebrew
 }
 
-// ScanInstalledApps scans the system for installed applications using system_profiler and mdfind
+// ScanInstalledApps scans the system for installed applications. On macOS it uses system_profiler,
+// mdfind, and the application folders; elsewhere desktop entries. Command-line tools are found on
+// every platform. With a scan cache set, a recent scan is reused; see SetScanCache.
 func (d *AppDetector) ScanInstalledApps() ([]InstalledApp, error) {
 	// Check cache first
 	if time.Since(d.lastScanTime) < d.cacheDuration && len(d.installedApps) > 0 {
 		return d.installedApps, nil
 	}
-
-	var allApps []InstalledApp
-	var scanCounts []int
-
-	// Method 1: Use system_profiler to get installed applications
-	if apps, err := d.scanWithSystemProfiler(); err == nil {
-		allApps = append(allApps, apps...)
-		scanCounts = append(scanCounts, len(apps))
-	} else {
-		scanCounts = append(scanCounts, 0)
-	}
-
-	// Method 2: Use mdfind to find .app bundles
-	if apps, err := d.scanWithMdfind(); err == nil {
-		allApps = append(allApps, apps...)
-		scanCounts = append(scanCounts, len(apps))
-	} else {
-		scanCounts = append(scanCounts, 0)
+	if cache, ok := d.loadScanCache(); ok {
+		d.installedApps = cache.Apps
+		d.lastScanTime = cache.ScannedAt
+		return cache.Apps, nil
 	}
 
-	// Method 3: Scan common application directories
-	apps := d.scanCommonDirectories()
-	allApps = append(allApps, apps...)
-	scanCounts = append(scanCounts, len(apps))
+	// Taken before scanning, so changes made during the scan invalidate the cache
+	watched := d.watchedModTimes()
+	scannedAt := time.Now()
 
-	beforeDeduplication := len(allApps)
+	// Detection methods differ per platform; see scan_darwin.go and scan_other.go. The
+	// command-line tools are scanned at the same time.
+	var cliTools []InstalledApp
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cliTools = d.ScanCLITools()
+	}()
+	allApps := d.scanPlatformApps()
+	<-done
+	allApps = append(allApps, cliTools...)
 
 	// Remove duplicates based on bundle ID
 	uniqueApps := d.removeDuplicateApps(allApps)
 
-	afterDeduplication := len(uniqueApps)
-	duplicatesRemoved := beforeDeduplication - afterDeduplication
-
-	// Debug info: only visible in verbose mode (would need to be passed down)
-	// For now, this is just internal tracking
-	_ = scanCounts
-	_ = duplicatesRemoved
-
 	// Cache the results
 	d.installedApps = uniqueApps
-	d.lastScanTime = time.Now()
+	d.lastScanTime = scannedAt
+	_ = d.saveScanCache(uniqueApps, scannedAt, watched)
 
 	return uniqueApps, nil
 }
 
-// scanWithSystemProfiler uses system_profiler to get application information
-func (d *AppDetector) scanWithSystemProfiler() ([]InstalledApp, error) {
-	cmd := exec.Command("system_profiler", "SPApplicationsDataType", "-json")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to run system_profiler: %v", err)
-	}
-
-	var result struct {
-		SPApplicationsDataType []struct {
-			Name    string `json:"_name"`
-			Path    string `json:"path"`
-			Version string `json:"version"`
-		} `json:"SPApplicationsDataType"`
-	}
-
-	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse system_profiler output: %v", err)
-	}
-
-	var apps []InstalledApp
-	for _, app := range result.SPApplicationsDataType {
-		if app.Name != "" {
-			installedApp := InstalledApp{
-				Name:        strings.ToLower(strings.ReplaceAll(app.Name, " ", "")),
-				DisplayName: app.Name,
-				Path:        app.Path,
-				Version:     app.Version,
-				BundleID:    d.extractBundleID(app.Path),
-			}
-			apps = append(apps, installedApp)
-		}
-	}
-
-	return apps, nil
-}
-
-// scanWithMdfind uses mdfind to locate .app bundles
-func (d *AppDetector) scanWithMdfind() ([]InstalledApp, error) {
-	cmd := exec.Command("mdfind", "kMDItemContentType == 'com.apple.application-bundle'")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to run mdfind: %v", err)
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var apps []InstalledApp
-
-	for _, line := range lines {
-		if line == "" || !strings.HasSuffix(line, ".app") {
-			continue
-		}
-
-		appName := filepath.Base(line)
-		appName = strings.TrimSuffix(appName, ".app")
-
-		installedApp := InstalledApp{
-			Name:        strings.ToLower(strings.ReplaceAll(appName, " ", "")),
-			DisplayName: appName,
-			Path:        line,
-			BundleID:    d.extractBundleID(line),
-		}
-
-		apps = append(apps, installedApp)
-	}
-
-	return apps, nil
-}
-
-// scanCommonDirectories scans common application installation directories
-func (d *AppDetector) scanCommonDirectories() []InstalledApp {
-	commonDirs := []string{
-		"/Applications",
-		filepath.Join(d.homeDir, "Applications"),
-		"/System/Applications",
-		"/System/Library/CoreServices",
-	}
-
-	var apps []InstalledApp
-
-	for _, dir := range commonDirs {
-		if !fsutil.PathExists(dir) {
-			continue
-		}
-
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			continue
-		}
-
-		for _, entry := range entries {
-			if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".app") {
-				continue
-			}
-
-			appPath := filepath.Join(dir, entry.Name())
-			appName := strings.TrimSuffix(entry.Name(), ".app")
-
-			installedApp := InstalledApp{
-				Name:        strings.ToLower(strings.ReplaceAll(appName, " ", "")),
-				DisplayName: appName,
-				Path:        appPath,
-				BundleID:    d.extractBundleID(appPath),
-			}
-
-			apps = append(apps, installedApp)
-		}
-	}
-
-	return apps
-}
-
-// extractBundleID extracts the bundle ID from an application path
-func (d *AppDetector) extractBundleID(appPath string) string {
-	if appPath == "" {
-		return ""
-	}
-
-	infoPath := filepath.Join(appPath, "Contents", "Info.plist")
-	if !fsutil.PathExists(infoPath) {
-		return ""
-	}
-
-	// Use plutil to extract bundle ID from Info.plist
-	cmd := exec.Command("plutil", "-extract", "CFBundleIdentifier", "raw", infoPath)
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-
-	return strings.TrimSpace(string(output))
-}
-
 // removeDuplicateApps removes duplicate apps based on bundle ID, name, and path
 func (d *AppDetector) removeDuplicateApps(apps []InstalledApp) []InstalledApp {
 	seen := make(map[string]bool)
@@ -339,12 +211,27 @@ func (d *AppDetector) AutoDetectApps() ([]*config.AppConfig, error) {
 			continue
 		}
 
+		// Command-line tools keep their configuration at conventional locations
+		if app.CLI {
+			if appConfig := d.detectCLITool(app); appConfig != nil {
+				detectedConfigs = append(detectedConfigs, appConfig)
+			}
+			continue
+		}
+
 		// Try smart detection using bundle ID and common patterns
 		if appConfig := d.smartDetectApp(app); appConfig != nil {
 			detectedConfigs = append(detectedConfigs, appConfig)
 		}
 	}
 
+	// Plugins describe where the applications they find keep their configuration
+	for _, appName := range d.detectPluginApps() {
+		if appConfig := d.detectKnownApp(appName); appConfig != nil {
+			detectedConfigs = append(detectedConfigs, appConfig)
+		}
+	}
+
 	// Remove duplicate configurations
 	deduplicatedConfigs := d.removeDuplicateConfigs(detectedConfigs)
 
@@ -401,9 +288,9 @@ func (d *AppDetector) smartDetectApp(app InstalledApp) *config.AppConfig {
 
 	var foundPaths []localPath
 
-	// Pattern 1: Check for preferences in ~/Library/Preferences/
+	// Pattern 1: Check for preferences in ~/Library/Preferences/, or in the app's container when sandboxed
 	if app.BundleID != "" {
-		prefsPath := filepath.Join(d.homeDir, "Library", "Preferences", app.BundleID+".plist")
+		prefsPath := d.resolveContainerPath(app.BundleID, filepath.Join(d.homeDir, "Library", "Preferences", app.BundleID+".plist"))
 		if fsutil.PathExists(prefsPath) {
 			relPath := filepath.Join("Library", "Preferences", app.BundleID+".plist")
 			foundPaths = append(foundPaths, localPath{
@@ -422,8 +309,10 @@ func (d *AppDetector) smartDetectApp(app InstalledApp) *config.AppConfig {
 	}
 
 	for _, appSupportPath := range appSupportPaths {
+		// The store keeps the uncontained layout, so sandboxed and unsandboxed installs share it
+		relPath, _ := filepath.Rel(d.homeDir, appSupportPath)
+		appSupportPath = d.resolveContainerPath(app.BundleID, appSupportPath)
 		if fsutil.PathExists(appSupportPath) {
-			relPath, _ := filepath.Rel(d.homeDir, appSupportPath)
 			foundPaths = append(foundPaths, localPath{
 				Source:      appSupportPath,
 				Destination: relPath,
@@ -434,8 +323,8 @@ func (d *AppDetector) smartDetectApp(app InstalledApp) *config.AppConfig {
 		}
 	}
 
-	// Pattern 3: Check for containers (sandboxed apps)
-	if app.BundleID != "" {
+	// Pattern 3: Check for containers (sandboxed apps), unless their contents were already found above
+	if app.BundleID != "" && !d.hasContainerPath(foundPaths) {
 		containerPaths := []string{
 			filepath.Join(d.homeDir, "Library", "Containers", app.BundleID),
 			filepath.Join(d.homeDir, "Library", "Group Containers", app.BundleID),
@@ -479,6 +368,7 @@ func (d *AppDetector) smartDetectApp(app InstalledApp) *config.AppConfig {
 	// Convert localPath to the expected Path format
 	for _, cp := range foundPaths {
 		appConfig.AddPath(cp.Source, cp.Destination, cp.Type, cp.Required)
+		appConfig.Paths[len(appConfig.Paths)-1].Mode = d.pathMode(cp.Source)
 	}
 
 	// Only return if we found at least one configuration path
@@ -505,7 +395,7 @@ func (d *AppDetector) GetInstalledApps() []InstalledApp {
 // GetSupportedApps returns a list of known supported applications
 func (d *AppDetector) GetSupportedApps() []string {
 	var apps []string
-	for appName := range knownApps {
+	for appName := range d.catalog {
 		apps = append(apps, appName)
 	}
 	return apps
@@ -513,7 +403,7 @@ func (d *AppDetector) GetSupportedApps() []string {
 
 // detectKnownApp detects configuration for known applications
 func (d *AppDetector) detectKnownApp(normalizedName string) *config.AppConfig {
-	appInfo, exists := knownApps[normalizedName]
+	appInfo, exists := d.catalog[normalizedName]
 	if !exists {
 		return nil
 	}
@@ -521,19 +411,39 @@ func (d *AppDetector) detectKnownApp(normalizedName string) *config.AppConfig {
 	appConfig := config.NewAppConfig(appInfo.Name, appInfo.DisplayName)
 	appConfig.BundleID = appInfo.BundleID
 
-	// Add paths from the known app configuration
+	appConfig.PostSync = append([]string(nil), appInfo.PostSync...)
+
+	// Add paths from the known app configuration that are used on this platform
 	for _, pathInfo := range appInfo.Paths {
-		sourcePath := d.expandPath(pathInfo.Source)
+		if !pathInfo.AppliesTo(d.platform) {
+			continue
+		}
+		sourcePath, versions := d.resolveVersion(pathInfo)
+		sourcePath = d.resolveContainerPath(appInfo.BundleID, sourcePath)
 		destPath := pathInfo.Destination
 
-		// Only add path if source exists (unless it's required)
-		if pathInfo.Required || fsutil.PathExists(sourcePath) {
+		links := make([]string, len(pathInfo.Links))
+		for i, link := range pathInfo.Links {
+			links[i] = d.expandPath(link)
+		}
+
+		// Only add path if source or one of its links exists (unless it's required)
+		if pathInfo.Required || fsutil.PathExists(sourcePath) || anyExists(links) {
 			appConfig.AddPath(sourcePath, destPath, pathInfo.Type, pathInfo.Required)
+			appConfig.Paths[len(appConfig.Paths)-1].Exclude = append([]string(nil), pathInfo.Exclude...)
+			appConfig.Paths[len(appConfig.Paths)-1].Platforms = append([]string(nil), pathInfo.Platforms...)
+			if len(links) > 0 {
+				appConfig.Paths[len(appConfig.Paths)-1].Links = links
+			}
+			appConfig.Paths[len(appConfig.Paths)-1].Versions = versions
+			appConfig.Paths[len(appConfig.Paths)-1].Mode = d.pathMode(sourcePath)
+			appConfig.Paths[len(appConfig.Paths)-1].Plugin = pathInfo.Plugin
 		}
 	}
 
 	// Only return if we found at least one valid path
 	if len(appConfig.Paths) > 0 {
+		d.recordMetadata(appConfig)
 		return appConfig
 	}
 
@@ -607,381 +517,82 @@ func (d *AppDetector) detectByPreferences(appName string) *config.AppConfig {
 	return nil
 }
 
-// expandPath expands ~ to home directory and other path expansions
-func (d *AppDetector) expandPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		return filepath.Join(d.homeDir, path[2:])
+// resolveContainerPath returns the location a sandboxed app keeps a ~/Library path at: inside its
+// container when the container has it, otherwise the path itself
+func (d *AppDetector) resolveContainerPath(bundleID, path string) string {
+	containerPath := config.ContainerPath(d.homeDir, bundleID, path)
+	if containerPath != "" && fsutil.PathExists(containerPath) {
+		return containerPath
 	}
 	return path
 }
 
+// pathMode returns the sync mode for a detected path. Paths inside a sandbox container are copied,
+// since the sandbox keeps the app from following a symlink out of its container.
+func (d *AppDetector) pathMode(path string) string {
+	if config.IsContainerPath(d.homeDir, path) {
+		return config.PathModeCopy
+	}
+	return ""
+}
+
+// hasContainerPath reports whether any detected path is inside a sandbox container
+func (d *AppDetector) hasContainerPath(paths []localPath) bool {
+	for _, path := range paths {
+		if config.IsContainerPath(d.homeDir, path.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveVersion returns the source of a path with ~/ expanded, and for versioned paths the newest
+// matching location together with the expanded pattern it was resolved from
+func (d *AppDetector) resolveVersion(pathInfo PathInfo) (string, string) {
+	source := d.expandPath(pathInfo.Source)
+	if !pathInfo.Versioned {
+		return source, ""
+	}
+	if newest := config.NewestVersion(d.homeDir, source); newest != "" {
+		return newest, source
+	}
+	return source, source
+}
+
+// expandPath expands ~ to home directory and other path expansions
+func (d *AppDetector) expandPath(path string) string {
+	return fsops.ExpandHome(path, d.homeDir)
+}
+
 // AppInfo represents information about a known application
 type AppInfo struct {
-	Name        string
-	DisplayName string
-	BundleID    string
-	Paths       []PathInfo
+	Name        string     `yaml:"name"`
+	DisplayName string     `yaml:"display_name"`
+	BundleID    string     `yaml:"bundle_id,omitempty"`
+	Origin      string     `yaml:"-"` // Catalog the definition was loaded from
+	Paths       []PathInfo `yaml:"paths"`
+	PostSync    []string   `yaml:"post_sync,omitempty"` // Shell commands that reload the app's configuration after syncing
 }
 
 // PathInfo represents a configuration path for an application
 type PathInfo struct {
-	Source      string
-	Destination string
-	Type        config.PathType
-	Required    bool
+	Source      string          `yaml:"source"`
+	Destination string          `yaml:"destination"`
+	Type        config.PathType `yaml:"type"`
+	Exclude     []string        `yaml:"exclude,omitempty"`   // Cache and state entries that should not be copied with the directory
+	Platforms   []string        `yaml:"platforms,omitempty"` // Platforms the path is used on; by default ~/Library paths are macOS-only
+	Links       []string        `yaml:"links,omitempty"`     // Other locations symlinked to the same store copy, such as a legacy path
+	Previous    []string        `yaml:"previous,omitempty"`  // Where older versions of the app kept the path; see DetectMoves
+	Secret      string          `yaml:"secret,omitempty"`    // Backend keeping the file's content instead of the store; see config.Path.Secret
+	Repo        string          `yaml:"repo,omitempty"`      // Git repository the directory is checked out from; see config.Path.Repo
+	Ref         string          `yaml:"ref,omitempty"`       // Branch or tag of Repo
+	Plugin      string          `yaml:"plugin,omitempty"`    // Plugin exporting the path's settings instead of a symlink; see config.Path.Plugin
+	Versioned   bool            `yaml:"versioned,omitempty"` // Source is a glob of versioned directories; the newest is used and followed on upgrades
+	Required    bool            `yaml:"required,omitempty"`
 }
 
-// knownApps contains configuration information for commonly used macOS applications
-var knownApps = map[string]*AppInfo{
-	"vscode": {
-		Name:        "vscode",
-		DisplayName: "Visual Studio Code",
-		BundleID:    "com.microsoft.VSCode",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Application Support/Code/User/settings.json",
-				Destination: "Library/Application Support/Code/User/settings.json",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Code/User/keybindings.json",
-				Destination: "Library/Application Support/Code/User/keybindings.json",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Code/User/snippets",
-				Destination: "Library/Application Support/Code/User/snippets",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"googlechrome": {
-		Name:        "googlechrome",
-		DisplayName: "Google Chrome",
-		BundleID:    "com.google.Chrome",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.google.Chrome.plist",
-				Destination: "Library/Preferences/com.google.Chrome.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Google/Chrome/Default/Preferences",
-				Destination: "Library/Application Support/Google/Chrome/Default/Preferences",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"firefox": {
-		Name:        "firefox",
-		DisplayName: "Firefox",
-		BundleID:    "org.mozilla.firefox",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/org.mozilla.firefox.plist",
-				Destination: "Library/Preferences/org.mozilla.firefox.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Firefox/Profiles",
-				Destination: "Library/Application Support/Firefox/Profiles",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"terminal": {
-		Name:        "terminal",
-		DisplayName: "Terminal",
-		BundleID:    "com.apple.Terminal",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.apple.Terminal.plist",
-				Destination: "Library/Preferences/com.apple.Terminal.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"iterm2": {
-		Name:        "iterm2",
-		DisplayName: "iTerm2",
-		BundleID:    "com.googlecode.iterm2",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.googlecode.iterm2.plist",
-				Destination: "Library/Preferences/com.googlecode.iterm2.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"sublimetext": {
-		Name:        "sublimetext",
-		DisplayName: "Sublime Text",
-		BundleID:    "com.sublimetext.4",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Application Support/Sublime Text/Packages/User",
-				Destination: "Library/Application Support/Sublime Text/Packages/User",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"git": {
-		Name:        "git",
-		DisplayName: "Git",
-		BundleID:    "",
-		Paths: []PathInfo{
-			{
-				Source:      "~/.gitconfig",
-				Destination: ".gitconfig",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/.gitignore_global",
-				Destination: ".gitignore_global",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"ssh": {
-		Name:        "ssh",
-		DisplayName: "SSH",
-		BundleID:    "",
-		Paths: []PathInfo{
-			{
-				Source:      "~/.ssh/config",
-				Destination: ".ssh/config",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"bartender4": {
-		Name:        "bartender4",
-		DisplayName: "Bartender 4",
-		BundleID:    "com.surteesstudios.Bartender",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.surteesstudios.Bartender.plist",
-				Destination: "Library/Preferences/com.surteesstudios.Bartender.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"rectangle": {
-		Name:        "rectangle",
-		DisplayName: "Rectangle",
-		BundleID:    "com.knollsoft.Rectangle",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.knollsoft.Rectangle.plist",
-				Destination: "Library/Preferences/com.knollsoft.Rectangle.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"alfred": {
-		Name:        "alfred",
-		DisplayName: "Alfred",
-		BundleID:    "com.runningwithcrayons.Alfred",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.runningwithcrayons.Alfred-Preferences.plist",
-				Destination: "Library/Preferences/com.runningwithcrayons.Alfred-Preferences.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Alfred",
-				Destination: "Library/Application Support/Alfred",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"1password": {
-		Name:        "1password",
-		DisplayName: "1Password 7 - Password Manager",
-		BundleID:    "com.1password.1password",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.1password.1password.plist",
-				Destination: "Library/Preferences/com.1password.1password.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Group Containers/2BUA8C4S2C.com.1password",
-				Destination: "Library/Group Containers/2BUA8C4S2C.com.1password",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"1password8": {
-		Name:        "1password8",
-		DisplayName: "1Password 8",
-		BundleID:    "com.1password.1password8",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.1password.1password8.plist",
-				Destination: "Library/Preferences/com.1password.1password8.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"finder": {
-		Name:        "finder",
-		DisplayName: "Finder",
-		BundleID:    "com.apple.finder",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.apple.finder.plist",
-				Destination: "Library/Preferences/com.apple.finder.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"dock": {
-		Name:        "dock",
-		DisplayName: "Dock",
-		BundleID:    "com.apple.dock",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.apple.dock.plist",
-				Destination: "Library/Preferences/com.apple.dock.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"spotify": {
-		Name:        "spotify",
-		DisplayName: "Spotify",
-		BundleID:    "com.spotify.client",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.spotify.client.plist",
-				Destination: "Library/Preferences/com.spotify.client.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Spotify",
-				Destination: "Library/Application Support/Spotify",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"slack": {
-		Name:        "slack",
-		DisplayName: "Slack",
-		BundleID:    "com.tinyspeck.slackmacgap",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.tinyspeck.slackmacgap.plist",
-				Destination: "Library/Preferences/com.tinyspeck.slackmacgap.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/Slack",
-				Destination: "Library/Application Support/Slack",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"discord": {
-		Name:        "discord",
-		DisplayName: "Discord",
-		BundleID:    "com.hnc.Discord",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.hnc.Discord.plist",
-				Destination: "Library/Preferences/com.hnc.Discord.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/Library/Application Support/discord",
-				Destination: "Library/Application Support/discord",
-				Type:        config.PathTypeDirectory,
-				Required:    false,
-			},
-		},
-	},
-	"homebrew": {
-		Name:        "homebrew",
-		DisplayName: "Homebrew",
-		BundleID:    "",
-		Paths: []PathInfo{
-			{
-				Source:      "~/.zprofile",
-				Destination: ".zprofile",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/.zshrc",
-				Destination: ".zshrc",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/.bashrc",
-				Destination: ".bashrc",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-			{
-				Source:      "~/.bash_profile",
-				Destination: ".bash_profile",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"magnet": {
-		Name:        "magnet",
-		DisplayName: "Magnet",
-		BundleID:    "com.crowdcafe.windowmagnet",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.crowdcafe.windowmagnet.plist",
-				Destination: "Library/Preferences/com.crowdcafe.windowmagnet.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
-	"cleanmymac": {
-		Name:        "cleanmymac",
-		DisplayName: "CleanMyMac X",
-		BundleID:    "com.macpaw.CleanMyMac4",
-		Paths: []PathInfo{
-			{
-				Source:      "~/Library/Preferences/com.macpaw.CleanMyMac4.plist",
-				Destination: "Library/Preferences/com.macpaw.CleanMyMac4.plist",
-				Type:        config.PathTypeFile,
-				Required:    false,
-			},
-		},
-	},
+// AppliesTo reports whether the path is used on a platform, following config.Path.AppliesTo
+func (p PathInfo) AppliesTo(platform string) bool {
+	path := config.Path{Source: p.Source, Type: p.Type, Platforms: p.Platforms}
+	return path.AppliesTo(platform)
 }
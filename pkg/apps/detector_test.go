@@ -331,6 +331,7 @@ func TestDetectKnownApp(t *testing.T) {
 	}
 
 	detector := NewAppDetector(tempDir)
+	detector.platform = config.PlatformDarwin
 
 	// Test detecting VS Code
 	appConfig := detector.detectKnownApp("vscode")
@@ -356,6 +357,20 @@ func TestDetectKnownApp(t *testing.T) {
 		t.Error("Expected at least one configuration path")
 	}
 
+	// On Linux, the XDG location is used instead
+	linuxSettings := filepath.Join(tempDir, ".config", "Code", "User", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(linuxSettings), 0755); err != nil {
+		t.Fatalf("Failed to create VS Code config directory: %v", err)
+	}
+	if err := os.WriteFile(linuxSettings, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create VS Code settings file: %v", err)
+	}
+	detector.platform = config.PlatformLinux
+	linuxConfig := detector.detectKnownApp("vscode")
+	if linuxConfig == nil || len(linuxConfig.Paths) != 1 || linuxConfig.Paths[0].Source != linuxSettings {
+		t.Errorf("Expected only the XDG settings path on Linux, got %+v", linuxConfig)
+	}
+
 	// Test app that doesn't exist
 	nonExistentConfig := detector.detectKnownApp("nonexistentapp")
 	if nonExistentConfig != nil {
@@ -441,6 +456,39 @@ func TestSmartDetectApp(t *testing.T) {
 	}
 }
 
+func TestSmartDetectSandboxedApp(t *testing.T) {
+	tempDir := t.TempDir()
+
+	containerPrefs := filepath.Join(tempDir, "Library", "Containers", "com.test.app", "Data", "Library", "Preferences")
+	if err := os.MkdirAll(containerPrefs, 0755); err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	plist := filepath.Join(containerPrefs, "com.test.app.plist")
+	if err := os.WriteFile(plist, []byte("prefs"), 0644); err != nil {
+		t.Fatalf("Failed to create plist: %v", err)
+	}
+
+	detector := NewAppDetector(tempDir)
+	appConfig := detector.smartDetectApp(InstalledApp{Name: "testapp", DisplayName: "TestApp", BundleID: "com.test.app", Path: "/Applications/TestApp.app"})
+	if appConfig == nil {
+		t.Fatal("Expected to detect the sandboxed app")
+	}
+
+	if len(appConfig.Paths) != 1 {
+		t.Fatalf("Expected only the container preferences, got %+v", appConfig.Paths)
+	}
+	path := appConfig.Paths[0]
+	if path.Source != plist {
+		t.Errorf("Expected the container preferences as source, got %s", path.Source)
+	}
+	if path.Destination != filepath.Join("Library", "Preferences", "com.test.app.plist") {
+		t.Errorf("Expected the uncontained store destination, got %s", path.Destination)
+	}
+	if !path.IsCopyMode() {
+		t.Error("Expected container paths to be synced in copy mode")
+	}
+}
+
 func TestGetSupportedApps(t *testing.T) {
 	detector := NewAppDetector("/test/home")
 
@@ -573,3 +621,82 @@ func BenchmarkRemoveDuplicateApps(b *testing.B) {
 		detector.removeDuplicateApps(apps)
 	}
 }
+
+func TestDeveloperToolDefinitions(t *testing.T) {
+	devTools := []string{
+		"tmux", "neovim", "vim", "zsh", "ohmyzsh", "starship", "gh",
+		"docker", "kubectl", "jetbrainstoolbox", "raycast", "warp", "karabiner-elements",
+	}
+
+	for _, name := range devTools {
+		t.Run(name, func(t *testing.T) {
+			appInfo, exists := knownApps[name]
+			if !exists {
+				t.Fatalf("Expected %s to be a known app", name)
+			}
+
+			if appInfo.Name != name {
+				t.Errorf("Expected name %s to match map key, got %s", appInfo.Name, name)
+			}
+			if appInfo.DisplayName == "" {
+				t.Error("Expected display name to be set")
+			}
+			if len(appInfo.Paths) == 0 {
+				t.Fatal("Expected at least one path")
+			}
+
+			for _, pathInfo := range appInfo.Paths {
+				if !strings.HasPrefix(pathInfo.Source, "~/") {
+					t.Errorf("Expected source %s to be relative to home", pathInfo.Source)
+				}
+				if pathInfo.Destination != strings.TrimPrefix(pathInfo.Source, "~/") {
+					t.Errorf("Expected destination %s to mirror source %s", pathInfo.Destination, pathInfo.Source)
+				}
+				if len(pathInfo.Exclude) > 0 && pathInfo.Type != config.PathTypeDirectory {
+					t.Errorf("Exclusions only apply to directories, got %s for %s", pathInfo.Type, pathInfo.Source)
+				}
+				for _, pattern := range pathInfo.Exclude {
+					if _, err := filepath.Match(pattern, ""); err != nil {
+						t.Errorf("Invalid exclude pattern %q: %v", pattern, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDetectKnownAppCarriesExclusionsAndPostSync(t *testing.T) {
+	tempDir := t.TempDir()
+
+	karabinerDir := filepath.Join(tempDir, ".config", "karabiner", "automatic_backups")
+	if err := os.MkdirAll(karabinerDir, 0755); err != nil {
+		t.Fatalf("Failed to create karabiner directory: %v", err)
+	}
+
+	detector := NewAppDetector(tempDir)
+
+	appConfig := detector.detectKnownApp("karabiner-elements")
+	if appConfig == nil {
+		t.Fatal("Expected to detect Karabiner-Elements configuration")
+	}
+
+	if len(appConfig.PostSync) != 1 || !strings.Contains(appConfig.PostSync[0], "karabiner_console_user_server") {
+		t.Errorf("Expected karabiner reload command, got %v", appConfig.PostSync)
+	}
+
+	if len(appConfig.Paths) != 1 {
+		t.Fatalf("Expected 1 path, got %d", len(appConfig.Paths))
+	}
+	if !appConfig.Paths[0].IsExcluded("automatic_backups/karabiner_20240101.json") {
+		t.Error("Expected automatic backups to be excluded")
+	}
+	if appConfig.Paths[0].IsExcluded("karabiner.json") {
+		t.Error("Expected karabiner.json not to be excluded")
+	}
+
+	// Mutating the detected config must not alter the built-in definition
+	appConfig.Paths[0].Exclude[0] = "changed"
+	if knownApps["karabiner-elements"].Paths[0].Exclude[0] != "automatic_backups" {
+		t.Error("Expected known app definition to be copied, not shared")
+	}
+}
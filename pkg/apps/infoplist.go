@@ -0,0 +1,50 @@
+package apps
+
+import (
+	"runtime"
+	"sync"
+)
+
+// resolveBundleIDs fills in the bundle IDs of the applications from their bundles with a pool
+// of workers, reading each bundle once even when several scan methods found it
+func resolveBundleIDs(apps []InstalledApp, lookup func(appPath string) string) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, app := range apps {
+		if app.BundleID == "" && app.Path != "" && !seen[app.Path] {
+			seen[app.Path] = true
+			paths = append(paths, app.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+
+	bundleIDs := make([]string, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.NumCPU(), len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				bundleIDs[i] = lookup(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	byPath := make(map[string]string, len(paths))
+	for i, path := range paths {
+		byPath[path] = bundleIDs[i]
+	}
+	for i := range apps {
+		if apps[i].BundleID == "" {
+			apps[i].BundleID = byPath[apps[i].Path]
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package apps
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestResolveBundleIDs(t *testing.T) {
+	apps := []InstalledApp{
+		{Name: "editor", Path: "/Applications/Editor.app"},
+		{Name: "editor", Path: "/Applications/Editor.app"},
+		{Name: "terminal", Path: "/Applications/Terminal.app", BundleID: "com.example.kept"},
+		{Name: "player", Path: "/Applications/Player.app"},
+		{Name: "nopath"},
+	}
+
+	var mu sync.Mutex
+	lookups := make(map[string]int)
+	resolveBundleIDs(apps, func(appPath string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		lookups[appPath]++
+		return "id:" + appPath
+	})
+
+	if lookups["/Applications/Editor.app"] != 1 || len(lookups) != 2 {
+		t.Errorf("Expected each bundle to be read once, got %v", lookups)
+	}
+	expected := []string{"id:/Applications/Editor.app", "id:/Applications/Editor.app", "com.example.kept", "id:/Applications/Player.app", ""}
+	for i, app := range apps {
+		if app.BundleID != expected[i] {
+			t.Errorf("Expected %s to have bundle ID %q, got %q", app.Name, expected[i], app.BundleID)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package apps
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/dotbrains/configsync/internal/plugins"
+)
+
+// OriginPluginPrefix starts the origin of definitions detected by a plugin, followed by its name
+const OriginPluginPrefix = "plugin "
+
+// SetPlugins sets the plugins asked for the applications they find installed. Their definitions
+// are added to the catalog the first time detection needs them.
+func (d *AppDetector) SetPlugins(list []*plugins.Plugin) {
+	d.plugins = list
+	d.pluginApps, d.pluginErrors, d.pluginsAsked = nil, nil, false
+}
+
+// PluginErrors returns why plugins failed to detect applications; detection goes on without them
+func (d *AppDetector) PluginErrors() []error {
+	return d.pluginErrors
+}
+
+// detectPluginApps asks each plugin that detects applications for the ones it finds installed,
+// once, and adds their definitions to the catalog, replacing definitions with the same name. It
+// returns the names of the applications detected.
+func (d *AppDetector) detectPluginApps() []string {
+	if d.pluginsAsked || len(d.plugins) == 0 {
+		return d.pluginApps
+	}
+	d.pluginsAsked = true
+
+	detected := make(map[string]*AppInfo)
+	for _, plugin := range d.plugins {
+		description, err := plugin.Describe()
+		if err != nil {
+			d.pluginErrors = append(d.pluginErrors, err)
+			continue
+		}
+		if !slices.Contains(description.Capabilities, plugins.CapabilityDetect) {
+			continue
+		}
+		data, err := plugin.Detect()
+		if err == nil {
+			var parsed map[string]*AppInfo
+			if parsed, err = ParseCatalog(data, OriginPluginPrefix+plugin.Name); err == nil {
+				maps.Copy(detected, parsed)
+				continue
+			}
+		}
+		d.pluginErrors = append(d.pluginErrors, err)
+	}
+
+	merged := maps.Clone(d.catalog)
+	maps.Copy(merged, detected)
+	d.catalog = merged
+	d.pluginApps = slices.Sorted(maps.Keys(detected))
+	return d.pluginApps
+}
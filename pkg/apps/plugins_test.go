@@ -0,0 +1,56 @@
+package apps
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/plugins"
+)
+
+func TestDetectPluginApps(t *testing.T) {
+	homeDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(homeDir, ".fleet"), 0755); err != nil {
+		t.Fatalf("Failed to create configuration directory: %v", err)
+	}
+
+	detector := NewAppDetector(homeDir)
+	fleet := plugins.New("/plugins/detect-fleet", homeDir).WithRunner(func(_ context.Context, _ string, stdin []byte) ([]byte, error) {
+		var request plugins.Request
+		if err := json.Unmarshal(stdin, &request); err != nil {
+			return nil, err
+		}
+		if request.Action == plugins.ActionDescribe {
+			return []byte(`{"description":"JetBrains Fleet","capabilities":["detect"]}`), nil
+		}
+		return []byte(`{"apps":[{"name":"Fleet","display_name":"JetBrains Fleet","paths":[` +
+			`{"source":"~/.fleet","destination":".fleet","type":"directory"},` +
+			`{"source":"~/.fleet/state.db","destination":"fleet/state.json","type":"file","plugin":"sqlite-settings","required":true}]}]}`), nil
+	})
+	broken := plugins.New("/plugins/broken", homeDir).WithRunner(func(context.Context, string, []byte) ([]byte, error) {
+		return []byte(`{"error":"not configured"}`), nil
+	})
+	detector.SetPlugins([]*plugins.Plugin{broken, fleet})
+
+	appConfig, err := detector.DetectApp("fleet")
+	if err != nil {
+		t.Fatalf("Expected the plugin's application to be detected: %v", err)
+	}
+	if appConfig.DisplayName != "JetBrains Fleet" || len(appConfig.Paths) != 2 || appConfig.Paths[1].Plugin != "sqlite-settings" {
+		t.Errorf("Unexpected configuration %+v", appConfig)
+	}
+	if origin := detector.catalog["fleet"].Origin; origin != OriginPluginPrefix+"detect-fleet" {
+		t.Errorf("Expected the definition to come from the plugin, got %q", origin)
+	}
+	if errs := detector.PluginErrors(); len(errs) != 1 {
+		t.Errorf("Expected the broken plugin's error, got %v", errs)
+	}
+
+	// Plugins are asked once
+	_, _ = detector.DetectApp("fleet")
+	if errs := detector.PluginErrors(); len(errs) != 1 {
+		t.Errorf("Expected plugins to be asked once, got %v", errs)
+	}
+}
@@ -0,0 +1,118 @@
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ScanCacheFileName is the file in the ConfigSync config directory caching the installed-app scan
+	ScanCacheFileName = "apps-cache.json"
+	// ScanCacheTTL is how long a cached scan is used while none of the watched directories change
+	ScanCacheTTL = 24 * time.Hour
+)
+
+// scanCache is the file format of the cached installed-app scan
+type scanCache struct {
+	ScannedAt time.Time            `json:"scanned_at"`
+	Watched   map[string]time.Time `json:"watched"` // Modification times of the directories whose changes invalidate the scan
+	Apps      []InstalledApp       `json:"apps"`
+}
+
+// SetScanCache sets the file the installed-app scan is kept in between runs, so system_profiler
+// only runs again once the cache expires or applications are installed or removed
+func (d *AppDetector) SetScanCache(path string) {
+	d.scanCachePath = path
+}
+
+// LastScan returns when the installed applications were last scanned, which is earlier than the
+// current run when the scan came from the cache
+func (d *AppDetector) LastScan() time.Time {
+	return d.lastScanTime
+}
+
+// InvalidateScanCache discards the cached scan, so the next scan runs again
+func (d *AppDetector) InvalidateScanCache() error {
+	d.installedApps = []InstalledApp{}
+	d.lastScanTime = time.Time{}
+	if d.scanCachePath == "" {
+		return nil
+	}
+	if err := os.Remove(d.scanCachePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove scan cache: %w", err)
+	}
+	return nil
+}
+
+// loadScanCache returns the cached scan while it has not expired and the watched directories are
+// unchanged
+func (d *AppDetector) loadScanCache() (*scanCache, bool) {
+	if d.scanCachePath == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(d.scanCachePath)
+	if err != nil {
+		return nil, false
+	}
+	var cache scanCache
+	if err := json.Unmarshal(data, &cache); err != nil || time.Since(cache.ScannedAt) > ScanCacheTTL {
+		return nil, false
+	}
+
+	watched := d.watchedModTimes()
+	if len(watched) != len(cache.Watched) {
+		return nil, false
+	}
+	for dir, modTime := range watched {
+		if cached, exists := cache.Watched[dir]; !exists || !cached.Equal(modTime) {
+			return nil, false
+		}
+	}
+	return &cache, true
+}
+
+// saveScanCache writes a scan to the cache file. A cache that cannot be written, e.g. before
+// 'configsync init' created its directory, only makes the next scan slower.
+func (d *AppDetector) saveScanCache(apps []InstalledApp, scannedAt time.Time, watched map[string]time.Time) error {
+	if d.scanCachePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(scanCache{ScannedAt: scannedAt, Watched: watched, Apps: apps}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scan cache: %w", err)
+	}
+	tmpPath := d.scanCachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scan cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, d.scanCachePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write scan cache: %w", err)
+	}
+	return nil
+}
+
+// watchedModTimes returns the modification times of the directories that change when applications
+// or command-line tools are installed or removed: the platform's application folders, the
+// directories on PATH, and ~/.config. Missing directories have the zero time.
+func (d *AppDetector) watchedModTimes() map[string]time.Time {
+	dirs := d.platformWatchDirs()
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	dirs = append(dirs, filepath.Join(d.homeDir, ".config"))
+
+	watched := make(map[string]time.Time, len(dirs))
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		var modTime time.Time
+		if info, err := os.Stat(dir); err == nil {
+			modTime = info.ModTime()
+		}
+		watched[filepath.Clean(dir)] = modTime
+	}
+	return watched
+}
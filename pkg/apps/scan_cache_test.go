@@ -0,0 +1,53 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanCache(t *testing.T) {
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+	cachePath := filepath.Join(t.TempDir(), ScanCacheFileName)
+
+	detector := NewAppDetector(t.TempDir())
+	detector.SetScanCache(cachePath)
+	apps := []InstalledApp{{Name: "tmux", DisplayName: "tmux", Path: "/usr/bin/tmux", CLI: true}}
+	scannedAt := time.Now().Add(-time.Hour)
+	if err := detector.saveScanCache(apps, scannedAt, detector.watchedModTimes()); err != nil {
+		t.Fatalf("saveScanCache failed: %v", err)
+	}
+
+	cached, err := detector.ScanInstalledApps()
+	if err != nil {
+		t.Fatalf("ScanInstalledApps failed: %v", err)
+	}
+	if len(cached) != 1 || cached[0] != apps[0] || !detector.LastScan().Equal(scannedAt) {
+		t.Fatalf("Expected the cached scan, got %+v from %v", cached, detector.LastScan())
+	}
+
+	// Installing a tool changes a directory on PATH
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(binDir, later, later); err != nil {
+		t.Fatalf("Failed to touch PATH directory: %v", err)
+	}
+	if _, ok := detector.loadScanCache(); ok {
+		t.Error("Expected a change to a watched directory to invalidate the cache")
+	}
+
+	if err := detector.saveScanCache(apps, time.Now().Add(-ScanCacheTTL-time.Minute), detector.watchedModTimes()); err != nil {
+		t.Fatalf("saveScanCache failed: %v", err)
+	}
+	if _, ok := detector.loadScanCache(); ok {
+		t.Error("Expected an expired cache to be ignored")
+	}
+
+	if err := detector.InvalidateScanCache(); err != nil {
+		t.Fatalf("InvalidateScanCache failed: %v", err)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the cache file to be removed, got %v", err)
+	}
+}
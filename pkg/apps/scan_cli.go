@@ -0,0 +1,150 @@
+package apps
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotbrains/configsync/internal/brew"
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+// catalogCommands names the executable of catalog apps whose command differs from the app name
+var catalogCommands = map[string]string{
+	"googlechrome": "google-chrome",
+	"neovim":       "nvim",
+	"sublimetext":  "subl",
+	"vscode":       "code",
+}
+
+// ScanCLITools finds installed command-line tools: catalog apps whose command is on PATH or
+// that are installed as Homebrew formulae, and tools with a configuration at a conventional
+// location, ~/.config/<tool> or ~/.<tool>rc, that are on PATH or installed with Homebrew
+func (d *AppDetector) ScanCLITools() []InstalledApp {
+	formulae := make(map[string]bool)
+	for _, formula := range d.listFormulae() {
+		formulae[formula] = true
+	}
+
+	tools := d.scanCatalogCommands()
+	for _, name := range d.catalogNames() {
+		if formulae[name] || formulae[catalogCommand(name)] {
+			tools = append(tools, InstalledApp{Name: name, DisplayName: d.catalog[name].DisplayName, CLI: true})
+		}
+	}
+	for _, tool := range d.conventionTools() {
+		path, err := exec.LookPath(tool)
+		if err == nil || formulae[tool] {
+			tools = append(tools, InstalledApp{Name: tool, DisplayName: tool, Path: path, CLI: true})
+		}
+	}
+
+	// A tool is found once, through the first of the methods above that finds it
+	seen := make(map[string]bool)
+	var unique []InstalledApp
+	for _, tool := range tools {
+		if !seen[tool.Name] {
+			seen[tool.Name] = true
+			unique = append(unique, tool)
+		}
+	}
+	return unique
+}
+
+// scanCatalogCommands reports catalog apps whose command-line tool is installed
+func (d *AppDetector) scanCatalogCommands() []InstalledApp {
+	var apps []InstalledApp
+	for _, name := range d.catalogNames() {
+		path, err := exec.LookPath(catalogCommand(name))
+		if err != nil {
+			continue
+		}
+		apps = append(apps, InstalledApp{
+			Name:        name,
+			DisplayName: d.catalog[name].DisplayName,
+			Path:        path,
+			CLI:         true,
+		})
+	}
+
+	return apps
+}
+
+// catalogNames returns the names of the catalog apps in order
+func (d *AppDetector) catalogNames() []string {
+	names := make([]string, 0, len(d.catalog))
+	for name := range d.catalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// catalogCommand returns the executable of a catalog app
+func catalogCommand(name string) string {
+	if command, exists := catalogCommands[name]; exists {
+		return command
+	}
+	return name
+}
+
+// conventionTools lists the tool names suggested by the entries of ~/.config and the ~/.<tool>rc
+// files in the home directory
+func (d *AppDetector) conventionTools() []string {
+	var tools []string
+	if entries, err := os.ReadDir(filepath.Join(d.homeDir, ".config")); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				tools = append(tools, entry.Name())
+			}
+		}
+	}
+
+	rcFiles, _ := filepath.Glob(filepath.Join(d.homeDir, ".?*rc"))
+	for _, rcFile := range rcFiles {
+		if info, err := os.Stat(rcFile); err == nil && !info.IsDir() {
+			tools = append(tools, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(rcFile), "."), "rc"))
+		}
+	}
+
+	sort.Strings(tools)
+	return tools
+}
+
+// detectCLITool proposes a configuration for a command-line tool from its conventional locations
+func (d *AppDetector) detectCLITool(app InstalledApp) *config.AppConfig {
+	appConfig := config.NewAppConfig(app.Name, app.DisplayName)
+	candidates := []struct {
+		destination string
+		pathType    config.PathType
+	}{
+		{filepath.Join(".config", app.Name), config.PathTypeDirectory},
+		{"." + app.Name + "rc", config.PathTypeFile},
+	}
+	for _, candidate := range candidates {
+		source := filepath.Join(d.homeDir, candidate.destination)
+		if info, err := os.Stat(source); err == nil && info.IsDir() == (candidate.pathType == config.PathTypeDirectory) {
+			appConfig.AddPath(source, candidate.destination, candidate.pathType, false)
+		}
+	}
+
+	if len(appConfig.Paths) == 0 {
+		return nil
+	}
+	return appConfig
+}
+
+// installedFormulae lists the installed Homebrew formulae, or none when Homebrew is not installed
+func installedFormulae() []string {
+	brewManager := brew.NewManager(false)
+	if !brewManager.Available() {
+		return nil
+	}
+	formulae, err := brewManager.Formulae()
+	if err != nil {
+		return nil
+	}
+	return formulae
+}
@@ -0,0 +1,65 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestScanCLITools(t *testing.T) {
+	homeDir := t.TempDir()
+	binDir := t.TempDir()
+	for _, command := range []string{"fish", "tmux", "mysh"} {
+		if err := os.WriteFile(filepath.Join(binDir, command), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatalf("Failed to write fake command: %v", err)
+		}
+	}
+	t.Setenv("PATH", binDir)
+	for _, dir := range []string{"fish", "brewtool", "orphan"} {
+		if err := os.MkdirAll(filepath.Join(homeDir, ".config", dir), 0755); err != nil {
+			t.Fatalf("Failed to create config directory: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, ".myshrc"), []byte("set -o vi\n"), 0644); err != nil {
+		t.Fatalf("Failed to write rc file: %v", err)
+	}
+
+	detector := NewAppDetector(homeDir)
+	detector.listFormulae = func() []string { return []string{"starship", "brewtool"} }
+
+	found := make(map[string]InstalledApp)
+	for _, tool := range detector.ScanCLITools() {
+		if _, exists := found[tool.Name]; exists {
+			t.Errorf("Expected %s to be found once", tool.Name)
+		}
+		found[tool.Name] = tool
+	}
+	for _, name := range []string{"fish", "tmux", "mysh", "starship", "brewtool"} {
+		if tool, exists := found[name]; !exists || !tool.CLI {
+			t.Errorf("Expected %s to be found as a CLI tool, got %+v", name, found)
+		}
+	}
+	if _, exists := found["orphan"]; exists {
+		t.Error("Expected a configuration directory without a tool to be skipped")
+	}
+
+	tests := []struct {
+		name     string
+		expected config.Path
+	}{
+		{"fish", config.Path{Source: filepath.Join(homeDir, ".config", "fish"), Destination: ".config/fish", Type: config.PathTypeDirectory}},
+		{"mysh", config.Path{Source: filepath.Join(homeDir, ".myshrc"), Destination: ".myshrc", Type: config.PathTypeFile}},
+	}
+	for _, tt := range tests {
+		appConfig := detector.detectCLITool(found[tt.name])
+		if appConfig == nil || len(appConfig.Paths) != 1 {
+			t.Fatalf("Expected one path for %s, got %+v", tt.name, appConfig)
+		}
+		path := appConfig.Paths[0]
+		if path.Source != tt.expected.Source || path.Destination != tt.expected.Destination || path.Type != tt.expected.Type {
+			t.Errorf("Expected %+v for %s, got %+v", tt.expected, tt.name, path)
+		}
+	}
+}
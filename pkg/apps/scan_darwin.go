@@ -0,0 +1,221 @@
+//go:build darwin
+
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dotbrains/configsync/internal/fsutil"
+	"github.com/dotbrains/configsync/internal/plist"
+)
+
+// scanPlatformApps finds installed macOS applications with system_profiler, mdfind,
+// and a scan of the common application folders, running the three methods concurrently
+func (d *AppDetector) scanPlatformApps() []InstalledApp {
+	var profiled, found, scanned []InstalledApp
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	// Method 1: Use system_profiler to get installed applications
+	go func() {
+		defer wg.Done()
+		if apps, err := d.scanWithSystemProfiler(); err == nil {
+			profiled = apps
+		}
+	}()
+
+	// Method 2: Use mdfind to find .app bundles
+	go func() {
+		defer wg.Done()
+		if apps, err := d.scanWithMdfind(); err == nil {
+			found = apps
+		}
+	}()
+
+	// Method 3: Scan common application directories
+	go func() {
+		defer wg.Done()
+		scanned = d.scanCommonDirectories()
+	}()
+
+	wg.Wait()
+	allApps := append(profiled, found...)
+	allApps = append(allApps, scanned...)
+	resolveBundleIDs(allApps, d.extractBundleID)
+	return allApps
+}
+
+// platformWatchDirs returns the application folders whose changes mean applications were
+// installed or removed
+func (d *AppDetector) platformWatchDirs() []string {
+	return []string{"/Applications", filepath.Join(d.homeDir, "Applications")}
+}
+
+// scanWithSystemProfiler uses system_profiler to get application information
+func (d *AppDetector) scanWithSystemProfiler() ([]InstalledApp, error) {
+	cmd := exec.Command("system_profiler", "SPApplicationsDataType", "-json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run system_profiler: %v", err)
+	}
+
+	var result struct {
+		SPApplicationsDataType []struct {
+			Name    string `json:"_name"`
+			Path    string `json:"path"`
+			Version string `json:"version"`
+		} `json:"SPApplicationsDataType"`
+	}
+
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse system_profiler output: %v", err)
+	}
+
+	var apps []InstalledApp
+	for _, app := range result.SPApplicationsDataType {
+		if app.Name != "" {
+			installedApp := InstalledApp{
+				Name:        strings.ToLower(strings.ReplaceAll(app.Name, " ", "")),
+				DisplayName: app.Name,
+				Path:        app.Path,
+				Version:     app.Version,
+			}
+			apps = append(apps, installedApp)
+		}
+	}
+
+	return apps, nil
+}
+
+// scanWithMdfind uses mdfind to locate .app bundles
+func (d *AppDetector) scanWithMdfind() ([]InstalledApp, error) {
+	cmd := exec.Command("mdfind", "kMDItemContentType == 'com.apple.application-bundle'")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run mdfind: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var apps []InstalledApp
+
+	for _, line := range lines {
+		if line == "" || !strings.HasSuffix(line, ".app") {
+			continue
+		}
+
+		appName := filepath.Base(line)
+		appName = strings.TrimSuffix(appName, ".app")
+
+		installedApp := InstalledApp{
+			Name:        strings.ToLower(strings.ReplaceAll(appName, " ", "")),
+			DisplayName: appName,
+			Path:        line,
+		}
+
+		apps = append(apps, installedApp)
+	}
+
+	return apps, nil
+}
+
+// scanCommonDirectories scans common application installation directories
+func (d *AppDetector) scanCommonDirectories() []InstalledApp {
+	commonDirs := []string{
+		"/Applications",
+		filepath.Join(d.homeDir, "Applications"),
+		"/System/Applications",
+		"/System/Library/CoreServices",
+	}
+
+	var apps []InstalledApp
+
+	for _, dir := range commonDirs {
+		if !fsutil.PathExists(dir) {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".app") {
+				continue
+			}
+
+			appPath := filepath.Join(dir, entry.Name())
+			appName := strings.TrimSuffix(entry.Name(), ".app")
+
+			installedApp := InstalledApp{
+				Name:        strings.ToLower(strings.ReplaceAll(appName, " ", "")),
+				DisplayName: appName,
+				Path:        appPath,
+			}
+
+			apps = append(apps, installedApp)
+		}
+	}
+
+	return apps
+}
+
+// extractBundleID extracts the bundle ID from an application path
+func (d *AppDetector) extractBundleID(appPath string) string {
+	return readInfoPlistKey(appPath, "CFBundleIdentifier")
+}
+
+// findInstalledBundle finds an application bundle with Spotlight and reads its version and icon
+func findInstalledBundle(bundleID string) InstalledBundle {
+	cmd := exec.Command("mdfind", fmt.Sprintf("kMDItemCFBundleIdentifier == '%s'", bundleID))
+	output, err := cmd.Output()
+	if err != nil {
+		return InstalledBundle{}
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if !strings.HasSuffix(line, ".app") {
+			continue
+		}
+		bundle := InstalledBundle{Path: line, Version: readInfoPlistKey(line, "CFBundleShortVersionString")}
+		if icon := readInfoPlistKey(line, "CFBundleIconFile"); icon != "" {
+			if filepath.Ext(icon) == "" {
+				icon += ".icns"
+			}
+			bundle.Icon = filepath.Join(line, "Contents", "Resources", icon)
+		}
+		return bundle
+	}
+	return InstalledBundle{}
+}
+
+// readInfoPlistKey reads a string from the Info.plist of an application bundle
+func readInfoPlistKey(appPath, key string) string {
+	if appPath == "" {
+		return ""
+	}
+
+	infoPath := filepath.Join(appPath, "Contents", "Info.plist")
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		return ""
+	}
+	if values, err := plist.Strings(data); err == nil {
+		return strings.TrimSpace(values[key])
+	}
+
+	// Fall back to plutil for encodings that are not read natively
+	cmd := exec.Command("plutil", "-extract", key, "raw", infoPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,94 @@
+//go:build !darwin && !windows
+
+package apps
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scanPlatformApps finds installed applications without the macOS tools, from the desktop
+// entries in the XDG data directories
+func (d *AppDetector) scanPlatformApps() []InstalledApp {
+	return d.scanDesktopEntries()
+}
+
+// platformWatchDirs returns the directories holding desktop entries, whose changes mean
+// applications were installed or removed
+func (d *AppDetector) platformWatchDirs() []string {
+	return d.desktopEntryDirs()
+}
+
+// desktopEntryDirs returns the applications directories of the XDG data directories
+func (d *AppDetector) desktopEntryDirs() []string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(d.homeDir, ".local", "share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	var dirs []string
+	for _, dir := range append([]string{dataHome}, filepath.SplitList(dataDirs)...) {
+		dirs = append(dirs, filepath.Join(dir, "applications"))
+	}
+	return dirs
+}
+
+// scanDesktopEntries reads the .desktop files of installed graphical applications
+func (d *AppDetector) scanDesktopEntries() []InstalledApp {
+	var apps []InstalledApp
+	for _, dir := range d.desktopEntryDirs() {
+		entries, err := filepath.Glob(filepath.Join(dir, "*.desktop"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if name := readDesktopEntryName(entry); name != "" {
+				apps = append(apps, InstalledApp{
+					Name:        strings.ToLower(strings.ReplaceAll(name, " ", "")),
+					DisplayName: name,
+					Path:        entry,
+				})
+			}
+		}
+	}
+
+	return apps
+}
+
+// findInstalledBundle returns no bundle, since applications are not bundles here
+func findInstalledBundle(_ string) InstalledBundle {
+	return InstalledBundle{}
+}
+
+// readDesktopEntryName returns the name of a visible application from a .desktop file
+func readDesktopEntryName(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = file.Close() }()
+
+	var name string
+	inEntry := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "["):
+			inEntry = line == "[Desktop Entry]"
+		case !inEntry:
+		case line == "NoDisplay=true" || line == "Hidden=true":
+			return ""
+		case strings.HasPrefix(line, "Name=") && name == "":
+			name = strings.TrimPrefix(line, "Name=")
+		}
+	}
+
+	return name
+}
@@ -0,0 +1,51 @@
+//go:build !darwin && !windows
+
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanDesktopEntries(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("XDG_DATA_DIRS", t.TempDir())
+
+	entries := map[string]string{
+		"code.desktop":   "[Desktop Entry]\nName=Visual Studio Code\nExec=code\n[Desktop Action new]\nName=New Window\n",
+		"hidden.desktop": "[Desktop Entry]\nName=Hidden Helper\nNoDisplay=true\n",
+		"readme.txt":     "Name=Not an entry\n",
+	}
+	for name, content := range entries {
+		path := filepath.Join(dataHome, "applications", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create applications directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	apps := NewAppDetector(t.TempDir()).scanDesktopEntries()
+	if len(apps) != 1 {
+		t.Fatalf("Expected one visible desktop entry, got %+v", apps)
+	}
+	if apps[0].Name != "visualstudiocode" || apps[0].DisplayName != "Visual Studio Code" {
+		t.Errorf("Unexpected desktop entry %+v", apps[0])
+	}
+}
+
+func TestScanCatalogCommands(t *testing.T) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "nvim"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake command: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	apps := NewAppDetector(t.TempDir()).scanCatalogCommands()
+	if len(apps) != 1 || apps[0].Name != "neovim" {
+		t.Errorf("Expected neovim to be found through its nvim command, got %+v", apps)
+	}
+}
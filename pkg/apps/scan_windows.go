@@ -0,0 +1,71 @@
+package apps
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// startMenuPrograms is where shortcuts to installed applications are kept, relative to the
+// roaming AppData folder of a user or to ProgramData for every user
+var startMenuPrograms = filepath.Join("Microsoft", "Windows", "Start Menu", "Programs")
+
+// scanPlatformApps finds installed Windows applications from the shortcuts in the Start Menu
+func (d *AppDetector) scanPlatformApps() []InstalledApp {
+	var apps []InstalledApp
+	for _, dir := range d.startMenuDirs() {
+		_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".lnk") {
+				return nil
+			}
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(path))
+			if isHelperShortcut(name) {
+				return nil
+			}
+			apps = append(apps, InstalledApp{
+				Name:        strings.ToLower(strings.ReplaceAll(name, " ", "")),
+				DisplayName: name,
+				Path:        path,
+			})
+			return nil
+		})
+	}
+	return apps
+}
+
+// platformWatchDirs returns the Start Menu folders, whose changes mean applications were
+// installed or removed
+func (d *AppDetector) platformWatchDirs() []string {
+	return d.startMenuDirs()
+}
+
+// startMenuDirs returns the Start Menu folders of the user and of every user
+func (d *AppDetector) startMenuDirs() []string {
+	appData := os.Getenv("APPDATA")
+	if appData == "" {
+		appData = filepath.Join(d.homeDir, "AppData", "Roaming")
+	}
+	dirs := []string{filepath.Join(appData, startMenuPrograms)}
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		dirs = append(dirs, filepath.Join(programData, startMenuPrograms))
+	}
+	return dirs
+}
+
+// isHelperShortcut reports whether a Start Menu shortcut opens an uninstaller or documentation
+// rather than an application
+func isHelperShortcut(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range []string{"uninstall", "readme", "help", "website"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// findInstalledBundle returns no bundle, since applications are not bundles here
+func findInstalledBundle(_ string) InstalledBundle {
+	return InstalledBundle{}
+}
@@ -0,0 +1,32 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanStartMenu(t *testing.T) {
+	appData := t.TempDir()
+	t.Setenv("APPDATA", appData)
+	t.Setenv("ProgramData", t.TempDir())
+
+	programs := filepath.Join(appData, startMenuPrograms)
+	for _, name := range []string{"Visual Studio Code/Visual Studio Code.lnk", "Visual Studio Code/Uninstall Visual Studio Code.lnk", "notes.txt"} {
+		path := filepath.Join(programs, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create Start Menu folder: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	apps := NewAppDetector(t.TempDir()).scanPlatformApps()
+	if len(apps) != 1 {
+		t.Fatalf("Expected one application shortcut, got %+v", apps)
+	}
+	if apps[0].Name != "visualstudiocode" || apps[0].DisplayName != "Visual Studio Code" {
+		t.Errorf("Unexpected shortcut %+v", apps[0])
+	}
+}
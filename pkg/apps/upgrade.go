@@ -0,0 +1,119 @@
+package apps
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/dotbrains/configsync/internal/config"
+	"github.com/dotbrains/configsync/internal/fsutil"
+)
+
+// PathMove is a configured path that the application's catalog entry now keeps elsewhere, because
+// a newer version of the application moved it, e.g. Sublime Text 3 to 4
+type PathMove struct {
+	From            string `json:"from" yaml:"from"`                         // Configured source
+	To              string `json:"to" yaml:"to"`                             // Source the installed version uses
+	FromDestination string `json:"from_destination" yaml:"from_destination"` // Configured store destination
+	ToDestination   string `json:"to_destination" yaml:"to_destination"`     // Store destination of the catalog path
+	Index           int    `json:"-" yaml:"-"`                               // Index of the path in the application's configuration
+}
+
+// InstalledBundle is the installed application bundle with a bundle identifier
+type InstalledBundle struct {
+	Path    string // Path of the .app bundle
+	Version string // CFBundleShortVersionString of the bundle
+	Icon    string // Path of the icon file in the bundle
+}
+
+// InstalledBundle returns the installed application bundle with a bundle identifier, which is
+// empty when it is not installed or applications are not bundles on this platform
+func (d *AppDetector) InstalledBundle(bundleID string) InstalledBundle {
+	if bundleID == "" {
+		return InstalledBundle{}
+	}
+	return d.findBundle(bundleID)
+}
+
+// InstalledVersion returns the version of the installed application with a bundle identifier, or ""
+// when it is not installed or its version cannot be read
+func (d *AppDetector) InstalledVersion(bundleID string) string {
+	return d.InstalledBundle(bundleID).Version
+}
+
+// RefreshMetadata records the installed version, bundle path, and icon of a configured application
+// in its metadata. A recorded version is kept while paths moved by an upgrade are not migrated, so
+// the upgrades command still reports the version they moved in.
+func (d *AppDetector) RefreshMetadata(appConfig *config.AppConfig) {
+	recorded := appConfig.Metadata[config.MetadataAppVersion]
+	d.recordMetadata(appConfig)
+	if recorded != "" && len(d.DetectMoves(appConfig)) > 0 {
+		appConfig.Metadata[config.MetadataAppVersion] = recorded
+	}
+}
+
+// recordMetadata stores the installed version, bundle path, and icon of an application in its
+// metadata, so an upgrade can be noticed later and bundles can be compared with the installed version
+func (d *AppDetector) recordMetadata(appConfig *config.AppConfig) {
+	bundle := d.InstalledBundle(appConfig.BundleID)
+	if appConfig.Metadata == nil {
+		appConfig.Metadata = make(map[string]string)
+	}
+	for key, value := range map[string]string{
+		config.MetadataAppVersion: bundle.Version,
+		config.MetadataAppPath:    bundle.Path,
+		config.MetadataAppIcon:    bundle.Icon,
+	} {
+		if value != "" {
+			appConfig.Metadata[key] = value
+		}
+	}
+}
+
+// DetectMoves re-detects the catalog paths of a configured application and returns the configured
+// paths found at one of their previous locations while the current location exists, i.e. whose
+// application was upgraded to a version that keeps its configuration elsewhere
+func (d *AppDetector) DetectMoves(appConfig *config.AppConfig) []PathMove {
+	appInfo, exists := d.catalog[appConfig.Name]
+	if !exists {
+		return nil
+	}
+
+	var moves []PathMove
+	for _, pathInfo := range appInfo.Paths {
+		if len(pathInfo.Previous) == 0 || pathInfo.Versioned || !pathInfo.AppliesTo(d.platform) {
+			continue
+		}
+		current := d.resolveContainerPath(appInfo.BundleID, d.expandPath(pathInfo.Source))
+		if !fsutil.PathExists(current) || d.configuresSource(appConfig, current) {
+			continue
+		}
+
+		previous := make([]string, len(pathInfo.Previous))
+		for i, location := range pathInfo.Previous {
+			previous[i] = filepath.Clean(d.expandPath(location))
+		}
+		for i, path := range appConfig.Paths {
+			if !slices.Contains(previous, filepath.Clean(d.expandPath(path.Source))) {
+				continue
+			}
+			moves = append(moves, PathMove{
+				From:            path.Source,
+				To:              current,
+				FromDestination: path.Destination,
+				ToDestination:   pathInfo.Destination,
+				Index:           i,
+			})
+		}
+	}
+	return moves
+}
+
+// configuresSource reports whether one of an application's paths already uses a source
+func (d *AppDetector) configuresSource(appConfig *config.AppConfig, source string) bool {
+	for _, path := range appConfig.Paths {
+		if filepath.Clean(d.expandPath(path.Source)) == filepath.Clean(source) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotbrains/configsync/internal/config"
+)
+
+func TestDetectMoves(t *testing.T) {
+	tempDir := t.TempDir()
+	detector := NewAppDetector(tempDir)
+	detector.catalog = map[string]*AppInfo{
+		"myeditor": {
+			Name: "myeditor",
+			Paths: []PathInfo{{
+				Source:      "~/.config/myeditor/User",
+				Destination: ".config/myeditor/User",
+				Type:        config.PathTypeDirectory,
+				Previous:    []string{"~/.config/myeditor-3/User"},
+			}},
+		},
+	}
+
+	oldSource := filepath.Join(tempDir, ".config", "myeditor-3", "User")
+	newSource := filepath.Join(tempDir, ".config", "myeditor", "User")
+	appConfig := config.NewAppConfig("myeditor", "My Editor")
+	appConfig.AddPath("~/.config/myeditor-3/User", ".config/myeditor-3/User", config.PathTypeDirectory, false)
+
+	if moves := detector.DetectMoves(appConfig); len(moves) != 0 {
+		t.Fatalf("Expected no moves before the new location exists, got %+v", moves)
+	}
+
+	if err := os.MkdirAll(newSource, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	moves := detector.DetectMoves(appConfig)
+	if len(moves) != 1 {
+		t.Fatalf("Expected one move, got %+v", moves)
+	}
+	expected := PathMove{From: "~/.config/myeditor-3/User", To: newSource, FromDestination: ".config/myeditor-3/User", ToDestination: ".config/myeditor/User"}
+	if moves[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, moves[0])
+	}
+
+	appConfig.AddPath(newSource, ".config/myeditor/User", config.PathTypeDirectory, false)
+	if moves := detector.DetectMoves(appConfig); len(moves) != 0 {
+		t.Errorf("Expected no moves once the new location is configured, got %+v", moves)
+	}
+
+	other := config.NewAppConfig("other", "Other")
+	other.AddPath(oldSource, ".config/myeditor-3/User", config.PathTypeDirectory, false)
+	if moves := detector.DetectMoves(other); len(moves) != 0 {
+		t.Errorf("Expected no moves for an app outside the catalog, got %+v", moves)
+	}
+}
+
+func TestRefreshMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	detector := NewAppDetector(tempDir)
+	detector.catalog = map[string]*AppInfo{
+		"myeditor": {
+			Name: "myeditor",
+			Paths: []PathInfo{{
+				Source:      "~/.config/myeditor/User",
+				Destination: ".config/myeditor/User",
+				Type:        config.PathTypeDirectory,
+				Previous:    []string{"~/.config/myeditor-3/User"},
+			}},
+		},
+	}
+	installed := InstalledBundle{Path: "/Applications/My Editor.app", Version: "4.0", Icon: "/Applications/My Editor.app/Contents/Resources/editor.icns"}
+	detector.findBundle = func(bundleID string) InstalledBundle {
+		if bundleID != "com.example.editor" {
+			return InstalledBundle{}
+		}
+		return installed
+	}
+
+	appConfig := config.NewAppConfig("myeditor", "My Editor")
+	appConfig.BundleID = "com.example.editor"
+	appConfig.AddPath("~/.config/myeditor-3/User", ".config/myeditor-3/User", config.PathTypeDirectory, false)
+	appConfig.Metadata[config.MetadataAppVersion] = "3.2"
+
+	detector.RefreshMetadata(appConfig)
+	if appConfig.Metadata[config.MetadataAppPath] != installed.Path || appConfig.Metadata[config.MetadataAppIcon] != installed.Icon {
+		t.Errorf("Expected bundle path and icon to be recorded, got %v", appConfig.Metadata)
+	}
+	if appConfig.Metadata[config.MetadataAppVersion] != "4.0" {
+		t.Errorf("Expected installed version to be recorded, got %q", appConfig.Metadata[config.MetadataAppVersion])
+	}
+
+	// A path moved by the upgrade keeps the version it moved in until it is migrated
+	appConfig.Metadata[config.MetadataAppVersion] = "3.2"
+	if err := os.MkdirAll(filepath.Join(tempDir, ".config", "myeditor", "User"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	detector.RefreshMetadata(appConfig)
+	if appConfig.Metadata[config.MetadataAppVersion] != "3.2" {
+		t.Errorf("Expected recorded version to be kept while a path moved, got %q", appConfig.Metadata[config.MetadataAppVersion])
+	}
+
+	other := config.NewAppConfig("other", "Other")
+	detector.RefreshMetadata(other)
+	if len(other.Metadata) != 0 {
+		t.Errorf("Expected no metadata for an app without an installed bundle, got %v", other.Metadata)
+	}
+}